@@ -289,7 +289,7 @@ func BotOnMessageCreate(session *discordgo.Session, message *discordgo.MessageCr
 		switch {
 		case regexp.MustCompile("(?i)^HELP.*").Match(bmsg):
 			metrics.CommandsExecuted.Add(1)
-			sendHelp(message)
+			sendHelp(message, strings.TrimSpace(regexp.MustCompile("(?i)^HELP").ReplaceAllString(msg, "")))
 			return
 
 		case regexp.MustCompile("(?i)^PREFIX.*").Match(bmsg):
@@ -493,7 +493,7 @@ func BotOnMessageCreate(session *discordgo.Session, message *discordgo.MessageCr
 	// Check if the user calls for help
 	if cmd == "h" || cmd == "help" {
 		metrics.CommandsExecuted.Add(1)
-		sendHelp(message)
+		sendHelp(message, strings.TrimSpace(strings.Join(parts[1:], " ")))
 		return
 	}
 
@@ -611,12 +611,20 @@ func BotOnGuildCreate(session *discordgo.Session, guild *discordgo.GuildCreate)
 func BotOnGuildDelete(session *discordgo.Session, guild *discordgo.GuildDelete) {
 }
 
-func sendHelp(message *discordgo.MessageCreate) {
+// sendHelp sends the help for a single command if one is given and described, the general help otherwise
+func sendHelp(message *discordgo.MessageCreate, command string) {
 	channel, err := helpers.GetChannel(message.ChannelID)
 	if err != nil {
 		channel.GuildID = ""
 	}
 
+	if command != "" {
+		command = strings.TrimPrefix(strings.Fields(command)[0], helpers.GetPrefixForServer(channel.GuildID))
+		if modules.SendCommandHelp(message.ChannelID, channel.GuildID, command) {
+			return
+		}
+	}
+
 	helpers.SendMessage(
 		message.ChannelID,
 		helpers.GetTextF("bot.help", message.Author.ID, channel.GuildID),
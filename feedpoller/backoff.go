@@ -0,0 +1,26 @@
+package feedpoller
+
+import (
+	"math/rand"
+	"time"
+)
+
+// backoff doubles the interval for each consecutive failure, up to maxBackoff
+func backoff(interval, maxBackoff time.Duration, failures int) time.Duration {
+	delay := interval
+	for i := 0; i < failures && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxBackoff {
+		delay = maxBackoff
+	}
+	return delay
+}
+
+// jitter randomises the duration by up to the given fraction in both directions
+func jitter(duration time.Duration, fraction float64) time.Duration {
+	if fraction <= 0 {
+		return duration
+	}
+	return duration + time.Duration((rand.Float64()*2-1)*fraction*float64(duration))
+}
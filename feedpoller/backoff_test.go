@@ -0,0 +1,37 @@
+package feedpoller
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		failures int
+		expected time.Duration
+	}{
+		{0, time.Minute},
+		{1, 2 * time.Minute},
+		{3, 8 * time.Minute},
+		{10, time.Hour},
+	}
+
+	for _, test := range tests {
+		if delay := backoff(time.Minute, time.Hour, test.failures); delay != test.expected {
+			t.Errorf("feedpoller.backoff(1m, 1h, %d) returned %s, expected %s", test.failures, delay, test.expected)
+		}
+	}
+}
+
+func TestJitter(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		delay := jitter(time.Minute, 0.2)
+		if delay < 48*time.Second || delay > 72*time.Second {
+			t.Fatalf("feedpoller.jitter(1m, 0.2) returned %s, expected between 48s and 72s", delay)
+		}
+	}
+
+	if delay := jitter(time.Minute, 0); delay != time.Minute {
+		t.Errorf("feedpoller.jitter(1m, 0) returned %s, expected 1m0s", delay)
+	}
+}
@@ -0,0 +1,364 @@
+package feedpoller
+
+import (
+	"expvar"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Seklfreak/Robyul2/cache"
+	"github.com/Seklfreak/Robyul2/helpers"
+	"github.com/Seklfreak/Robyul2/metrics"
+	"github.com/go-redis/redis"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// lockKey is held by the instance that checks a target, from queueing the target until the check finished
+	lockKey = "robyul2-discord:feeds:%s:%s:lock"
+	// checkedKey is set once a target has been checked, it expires once the target is due again
+	checkedKey = "robyul2-discord:feeds:%s:%s:checked"
+	// lockTTL is the time after which the lock of a crashed instance expires, it is refreshed while the target is checked
+	lockTTL     = time.Minute
+	lockRefresh = 20 * time.Second
+	// scheduleTick is the time between looking for due targets
+	scheduleTick = 15 * time.Second
+)
+
+var (
+	lockOwner = getLockOwner()
+
+	// refreshLockScript extends the lock in KEYS[1] by ARGV[2] milliseconds, if it is still held by ARGV[1]
+	refreshLockScript = redis.NewScript(`
+if redis.call("get", KEYS[1]) == ARGV[1] then
+	return redis.call("pexpire", KEYS[1], ARGV[2])
+end
+return 0`)
+	// releaseLockScript deletes the lock in KEYS[1], if it is still held by ARGV[1]
+	releaseLockScript = redis.NewScript(`
+if redis.call("get", KEYS[1]) == ARGV[1] then
+	return redis.call("del", KEYS[1])
+end
+return 0`)
+)
+
+func getLockOwner() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+	return fmt.Sprintf("%s:%d", hostname, os.Getpid())
+}
+
+// Source is a feed plugin polled by a Poller
+// a target is a single account, channel or subreddit with all feeds posting it, for example a Twitch channel name
+type Source interface {
+	// Targets returns all targets that should be checked
+	Targets() (targets []string, err error)
+	// Check looks for new posts of the target and posts them to all feeds of the target
+	// sources keep track of the already posted posts themselves
+	Check(target string) (err error)
+}
+
+// RateLimitError is returned by sources if the API refused a check because of its rate limit
+// all checks of the source on the bot instance pause for RetryAfter, the target is checked again after it
+type RateLimitError struct {
+	Err        error
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return e.Err.Error()
+}
+
+// IntervalSource is implemented by sources with an interval that changes while running, for example because of an API quota
+// the interval replaces Options.Interval
+type IntervalSource interface {
+	Interval() time.Duration
+}
+
+type Options struct {
+	// Name is used for the claims, logs and metrics
+	Name string
+	// Interval is the time between checks of a target
+	Interval time.Duration
+	// Jitter is the part of the interval that is randomised, 0.2 spreads the checks between 80% and 120% of the interval
+	Jitter float64
+	// Workers is the number of targets checked in parallel by a bot instance
+	Workers int
+	// MaxBackoff is the longest time a failing target is not checked
+	MaxBackoff time.Duration
+	// ErrorBudget is the number of failed checks per interval after which all checks pause for an interval, 0 disables it
+	ErrorBudget int
+	// MinDelay is the shortest time between the starts of two checks on a bot instance, for APIs with a rate limit, 0 disables it
+	MinDelay time.Duration
+}
+
+// Poller checks the targets of a source, the targets are shared between all bot instances
+// an instance locks a target in redis while checking it, and marks it as checked until it is due again,
+// so each target is checked by a single instance
+type Poller struct {
+	sync.Mutex
+	source  Source
+	options Options
+	jobs    chan claim
+	targets *expvar.Int
+
+	// failures are the consecutive failed checks of the targets
+	failures map[string]int
+	// errors are the failed checks since errorsSince, for the error budget
+	errors      int
+	errorsSince time.Time
+	pausedUntil time.Time
+
+	// rateLock serializes the workers waiting for MinDelay and rate limits
+	rateLock         sync.Mutex
+	nextCheck        time.Time
+	rateLimitedUntil time.Time
+}
+
+// claim is a target locked by this instance, done stops refreshing the lock
+type claim struct {
+	target string
+	done   chan struct{}
+}
+
+// Start starts polling the source in the background
+func Start(source Source, options Options) *Poller {
+	if options.Workers < 1 {
+		options.Workers = 1
+	}
+	if options.MaxBackoff < options.Interval {
+		options.MaxBackoff = options.Interval
+	}
+
+	poller := &Poller{
+		source:   source,
+		options:  options,
+		jobs:     make(chan claim),
+		targets:  new(expvar.Int),
+		failures: make(map[string]int),
+	}
+	metrics.FeedTargets.Set(options.Name, poller.targets)
+
+	for i := 0; i < options.Workers; i++ {
+		go poller.worker()
+	}
+	go poller.run()
+
+	poller.logger().Infof("started feed poller (%s, %d workers)", options.Interval.String(), options.Workers)
+	return poller
+}
+
+func (p *Poller) logger() *logrus.Entry {
+	return cache.GetLogger().WithField("module", "feedpoller").WithField("feed", p.options.Name)
+}
+
+func (p *Poller) run() {
+	defer helpers.Recover()
+	defer func() {
+		go func() {
+			p.logger().Error("The feed poller died. Please investigate! Will be restarted in 60 seconds")
+			time.Sleep(60 * time.Second)
+			p.run()
+		}()
+	}()
+
+	for {
+		p.schedule()
+		time.Sleep(scheduleTick)
+	}
+}
+
+// schedule locks all due targets and queues them for the workers
+func (p *Poller) schedule() {
+	p.Lock()
+	paused := time.Now().Before(p.pausedUntil)
+	p.Unlock()
+	if paused {
+		return
+	}
+
+	targets, err := p.source.Targets()
+	if err != nil {
+		p.logger().WithError(err).Warn("getting targets failed")
+		return
+	}
+	p.targets.Set(int64(len(targets)))
+
+	for _, target := range targets {
+		claimed, err := p.claim(target)
+		if err != nil {
+			helpers.RelaxLog(err)
+			return
+		}
+		if claimed == nil {
+			continue
+		}
+
+		p.jobs <- *claimed
+	}
+}
+
+// claim locks the target if it is due, it returns nil if the target isn't due or locked by another instance
+// the lock is refreshed until claim.done is closed
+func (p *Poller) claim(target string) (claimed *claim, err error) {
+	redisClient := cache.GetRedisClient()
+
+	checked, err := redisClient.Exists(fmt.Sprintf(checkedKey, p.options.Name, target)).Result()
+	if err != nil || checked > 0 {
+		return nil, err
+	}
+
+	locked, err := redisClient.SetNX(fmt.Sprintf(lockKey, p.options.Name, target), lockOwner, lockTTL).Result()
+	if err != nil || !locked {
+		return nil, err
+	}
+
+	// another instance might have finished checking the target before we took the lock
+	checked, err = redisClient.Exists(fmt.Sprintf(checkedKey, p.options.Name, target)).Result()
+	if err != nil || checked > 0 {
+		p.release(target)
+		return nil, err
+	}
+
+	claimed = &claim{target: target, done: make(chan struct{})}
+	go p.refresh(*claimed)
+	return claimed, nil
+}
+
+// refresh extends the lock of the claim until the check finished
+func (p *Poller) refresh(claimed claim) {
+	defer helpers.Recover()
+
+	ticker := time.NewTicker(lockRefresh)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-claimed.done:
+			return
+		case <-ticker.C:
+			refreshed, err := refreshLockScript.Run(cache.GetRedisClient(),
+				[]string{fmt.Sprintf(lockKey, p.options.Name, claimed.target)},
+				lockOwner, int64(lockTTL/time.Millisecond),
+			).Int64()
+			if err != nil {
+				helpers.RelaxLog(err)
+				continue
+			}
+			if refreshed == 0 {
+				p.logger().Warnf("lost the lock of %s, it might be checked by another instance", claimed.target)
+				return
+			}
+		}
+	}
+}
+
+// release deletes the lock of the target, if it is still held by this instance
+func (p *Poller) release(target string) {
+	err := releaseLockScript.Run(cache.GetRedisClient(),
+		[]string{fmt.Sprintf(lockKey, p.options.Name, target)}, lockOwner).Err()
+	helpers.RelaxLog(err)
+}
+
+func (p *Poller) worker() {
+	for claimed := range p.jobs {
+		p.waitForRateLimit()
+		delay := p.check(claimed.target)
+
+		// marks the target as checked before releasing the lock, so no other instance checks it again
+		err := cache.GetRedisClient().Set(
+			fmt.Sprintf(checkedKey, p.options.Name, claimed.target), time.Now().Unix(), delay,
+		).Err()
+		helpers.RelaxLog(err)
+		close(claimed.done)
+		p.release(claimed.target)
+	}
+}
+
+// waitForRateLimit waits until MinDelay passed since the start of the last check, and until a rate limit ended
+func (p *Poller) waitForRateLimit() {
+	p.rateLock.Lock()
+	defer p.rateLock.Unlock()
+
+	next := p.nextCheck
+	if p.rateLimitedUntil.After(next) {
+		next = p.rateLimitedUntil
+	}
+	time.Sleep(time.Until(next))
+
+	p.nextCheck = time.Now().Add(p.options.MinDelay)
+}
+
+// check checks the target, returns the time until the target is due again
+// failed targets are backed off, rate limited targets are due once the rate limit ended
+func (p *Poller) check(target string) (delay time.Duration) {
+	start := time.Now()
+	err := p.checkRecovered(target)
+	metrics.FeedChecks.Add(p.options.Name, 1)
+	metrics.FeedCheckSeconds.AddFloat(p.options.Name, time.Since(start).Seconds())
+
+	if rateLimitErr, ok := err.(*RateLimitError); ok {
+		p.rateLock.Lock()
+		p.rateLimitedUntil = time.Now().Add(rateLimitErr.RetryAfter)
+		p.rateLock.Unlock()
+
+		metrics.FeedErrors.Add(p.options.Name, 1)
+		p.logger().Warnf("checking %s was rate limited, pausing for %s: %s",
+			target, rateLimitErr.RetryAfter.String(), rateLimitErr.Error())
+		return rateLimitErr.RetryAfter
+	}
+
+	p.Lock()
+	defer p.Unlock()
+
+	if err == nil {
+		delete(p.failures, target)
+		return jitter(p.interval(), p.options.Jitter)
+	}
+
+	metrics.FeedErrors.Add(p.options.Name, 1)
+	p.failures[target]++
+	delay = jitter(backoff(p.interval(), p.options.MaxBackoff, p.failures[target]), p.options.Jitter)
+	p.logger().Warnf("checking %s failed (%d times), retrying in %s: %s", target, p.failures[target], delay.String(), err.Error())
+
+	if p.options.ErrorBudget <= 0 {
+		return delay
+	}
+	if time.Since(p.errorsSince) > p.interval() {
+		p.errors = 0
+		p.errorsSince = time.Now()
+	}
+	p.errors++
+	if p.errors >= p.options.ErrorBudget {
+		p.pausedUntil = time.Now().Add(p.interval())
+		p.errors = 0
+		p.logger().Errorf("error budget of %d failed checks exceeded, pausing until %s",
+			p.options.ErrorBudget, p.pausedUntil.Format(time.RFC3339))
+	}
+	return delay
+}
+
+// checkRecovered checks the target, panics of the source are returned as errors
+func (p *Poller) checkRecovered(target string) (err error) {
+	panicked := true
+	func() {
+		defer helpers.Recover()
+
+		err = p.source.Check(target)
+		panicked = false
+	}()
+
+	if panicked {
+		return fmt.Errorf("check of %s panicked", target)
+	}
+	return err
+}
+
+func (p *Poller) interval() time.Duration {
+	if source, ok := p.source.(IntervalSource); ok {
+		return source.Interval()
+	}
+	return p.options.Interval
+}
@@ -0,0 +1,28 @@
+package feedpoller
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWaitForRateLimit(t *testing.T) {
+	poller := &Poller{options: Options{MinDelay: 50 * time.Millisecond}}
+
+	start := time.Now()
+	poller.waitForRateLimit()
+	if elapsed := time.Since(start); elapsed > 20*time.Millisecond {
+		t.Errorf("feedpoller.Poller.waitForRateLimit() waited %s before the first check", elapsed)
+	}
+
+	poller.waitForRateLimit()
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("feedpoller.Poller.waitForRateLimit() waited %s between two checks, expected at least MinDelay", elapsed)
+	}
+
+	poller.rateLimitedUntil = time.Now().Add(100 * time.Millisecond)
+	start = time.Now()
+	poller.waitForRateLimit()
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("feedpoller.Poller.waitForRateLimit() waited %s during a rate limit, expected until it ended", elapsed)
+	}
+}
@@ -10,8 +10,6 @@ import (
 	"strings"
 	"time"
 
-	"github.com/Jeffail/gabs"
-	"github.com/RichardKnop/machinery/v1/tasks"
 	"github.com/Seklfreak/Robyul2/cache"
 	"github.com/Seklfreak/Robyul2/models"
 	"github.com/bradfitz/slice"
@@ -486,25 +484,10 @@ func RemoveMuteRole(guildID string, userID string) (err error) {
 	return err
 }
 
+// RemoveMuteDatabase removes the member from the members muted before mutes were persisted with the persistency roles
 func RemoveMuteDatabase(guildID string, userID string) (err error) {
-	settings := GuildSettingsGetCached(guildID)
-
-	removedFromDb := false
-	newMutedMembers := make([]string, 0)
-	for _, mutedMember := range settings.MutedMembers {
-		if mutedMember != userID {
-			newMutedMembers = append(newMutedMembers, mutedMember)
-		} else {
-			removedFromDb = true
-		}
-	}
-
-	if removedFromDb {
-		settings.MutedMembers = newMutedMembers
-		err = GuildSettingsSet(guildID, settings)
-		return err
-	}
-	return nil
+	_, err = MdbCollection(models.MutedMembersTable).RemoveAll(bson.M{"guildid": guildID, "userid": userID})
+	return err
 }
 
 func RemoveMutePersistency(guildID string, userID string) (err error) {
@@ -516,39 +499,31 @@ func RemoveMutePersistency(guildID string, userID string) (err error) {
 	return persistencyRemoveCachedRole(guildID, userID, muteRole.ID)
 }
 
+// RemovePendingUnmutes ends the timed mutes of a member, and removes unmute tasks that have been scheduled with machinery
 func RemovePendingUnmutes(guildID string, userID string) (err error) {
-	key := "delayed_tasks"
-	delayedTasks, err := cache.GetMachineryRedisClient().ZCard(key).Result()
+	err = EndModActions(guildID, userID, models.ModActionTypeMute, "", models.ModActionEndReasonReverted, "")
 	if err != nil {
 		return err
 	}
 
-	tasksJson, err := cache.GetMachineryRedisClient().ZRange(key, 0, delayedTasks).Result()
+	return removeMachineryUnmutes(guildID, userID)
+}
+
+func removeMachineryUnmutes(guildID string, userID string) (err error) {
+	unmutes, err := getMachineryUnmutes()
 	if err != nil {
 		return err
 	}
 
-	for _, taskJson := range tasksJson {
-		task, err := gabs.ParseJSON([]byte(taskJson))
-		if err != nil {
-			return err
-		}
-
-		if task.Path("Name").Data().(string) != "unmute_user" {
+	for _, unmute := range unmutes {
+		if unmute.GuildID != guildID {
 			continue
 		}
-
-		unmuteGuildID := task.Path("Args").Index(0).Path("Value").Data().(string)
-		unmuteUserID := task.Path("Args").Index(1).Path("Value").Data().(string)
-
-		if unmuteGuildID != guildID {
-			continue
-		}
-		if unmuteUserID != userID {
+		if unmute.UserID != userID {
 			continue
 		}
 
-		_, err = cache.GetMachineryRedisClient().ZRem(key, taskJson).Result()
+		_, err = cache.GetMachineryRedisClient().ZRem("delayed_tasks", unmute.TaskJson).Result()
 		if err != nil {
 			return err
 		}
@@ -557,6 +532,7 @@ func RemovePendingUnmutes(guildID string, userID string) (err error) {
 	return nil
 }
 
+// UnmuteUserMachinery handles unmute_user tasks that have been scheduled with machinery before timed mutes moved to the mod actions
 func UnmuteUserMachinery(guildID string, userID string) (err error) {
 	err = UnmuteUser(guildID, userID)
 
@@ -592,25 +568,6 @@ func UnmuteUser(guildID string, userID string) (err error) {
 	}
 	return nil
 }
-func UnmuteUserSignature(guildID string, userID string) (signature *tasks.Signature) {
-	signature = &tasks.Signature{
-		Name: "unmute_user",
-		Args: []tasks.Arg{
-			{
-				Type:  "string",
-				Value: guildID,
-			},
-			{
-				Type:  "string",
-				Value: userID,
-			},
-		},
-	}
-	signature.RetryCount = 3
-	signature.OnError = []*tasks.Signature{{Name: "log_error"}}
-	return signature
-}
-
 func AddMuteRole(guildID string, userID string) (err error) {
 	muteRole, err := GetMuteRole(guildID)
 	if err != nil {
@@ -641,29 +598,28 @@ func AddMutePersistency(guildID, userID string) (err error) {
 	return nil
 }
 
-func CreatePendingUnmute(guildID string, userID string, unmuteAt time.Time) (err error) {
+// CreatePendingUnmute creates a timed mute that ends at unmuteAt, a zero unmuteAt ends the timed mutes of the member
+func CreatePendingUnmute(guildID, userID string, unmuteAt time.Time, mutedByUserID, reason string) (err error) {
 	if unmuteAt.IsZero() || !time.Now().Before(unmuteAt) {
-		return nil
-	}
-
-	timeToUnmuteAt := unmuteAt
-
-	signature := UnmuteUserSignature(guildID, userID)
-	signature.ETA = &timeToUnmuteAt
-
-	_, err = cache.GetMachineryServer().SendTask(signature)
-	if err != nil {
-		return err
+		return EndModActions(guildID, userID, models.ModActionTypeMute, "", models.ModActionEndReasonReplaced, mutedByUserID)
 	}
 
-	return nil
+	_, err = CreateModAction(models.ModActionEntry{
+		GuildID:         guildID,
+		UserID:          userID,
+		Type:            models.ModActionTypeMute,
+		Reason:          reason,
+		CreatedByUserID: mutedByUserID,
+		ExpiresAt:       unmuteAt,
+	})
+	return err
 }
 
-func MuteUser(guildID string, userID string, unmuteAt time.Time) (err error) {
+func MuteUser(guildID, userID string, unmuteAt time.Time, mutedByUserID, reason string) (err error) {
 	errRole := AddMuteRole(guildID, userID)
 	errAddMutePersistency := AddMutePersistency(guildID, userID)
-	errPendingUnmutes := RemovePendingUnmutes(guildID, userID)
-	errCreatePendingUnmute := CreatePendingUnmute(guildID, userID, unmuteAt)
+	errPendingUnmutes := removeMachineryUnmutes(guildID, userID)
+	errCreatePendingUnmute := CreatePendingUnmute(guildID, userID, unmuteAt, mutedByUserID, reason)
 
 	if errRole != nil {
 		return errRole
@@ -753,6 +709,53 @@ func persistencyAddCachedRole(GuildID string, UserID string, roleID string) (err
 	return err
 }
 
+// GetPersistencyMembersWithRole returns the IDs of the members that have a role according to the cached roles of persistency
+// the cache keeps the roles of members that left, and of roles that got deleted
+func GetPersistencyMembersWithRole(guildID, roleID string) (userIDs []string, err error) {
+	prefix := "robyul2-discord:persistency:" + guildID + ":"
+	redis := cache.GetRedisClient()
+
+	var cursor uint64
+	for {
+		var keys []string
+		keys, cursor, err = redis.Scan(cursor, prefix+"*:roles", 1000).Result()
+		if err != nil {
+			return nil, err
+		}
+
+		if len(keys) > 0 {
+			values, err := redis.MGet(keys...).Result()
+			if err != nil {
+				return nil, err
+			}
+
+			for i, value := range values {
+				marshalled, ok := value.(string)
+				if !ok {
+					continue
+				}
+
+				var redisRoleIDs []string
+				err = msgpack.Unmarshal([]byte(marshalled), &redisRoleIDs)
+				if err != nil {
+					continue
+				}
+
+				for _, redisRoleID := range redisRoleIDs {
+					if redisRoleID == roleID {
+						userIDs = append(userIDs, strings.TrimSuffix(strings.TrimPrefix(keys[i], prefix), ":roles"))
+						break
+					}
+				}
+			}
+		}
+
+		if cursor == 0 {
+			return userIDs, nil
+		}
+	}
+}
+
 func persistencyRemoveCachedRole(GuildID string, UserID string, roleID string) (err error) {
 	key := "robyul2-discord:persistency:" + GuildID + ":" + UserID + ":roles"
 	var redisRoleIDs []string
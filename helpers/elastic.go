@@ -706,6 +706,46 @@ func GetElasticPendingAuditLogBackfillEventlogs(createdAt time.Time, guildID, ta
 	}
 }
 
+// GetElasticEventlogsByUser returns the eventlog entries of actions by a user on a guild since a time, newest first
+func GetElasticEventlogsByUser(guildID, userID string, since time.Time, limit int) (result []GetElasticEventlogsResult, err error) {
+	boolQuery := elastic.NewBoolQuery().
+		Must(elastic.NewMatchQuery("GuildID", guildID)).
+		Must(elastic.NewMatchQuery("UserID", userID)).
+		Must(elastic.NewRangeQuery("CreatedAt").Gte(since))
+
+	searchResult, err := cache.GetElastic().Search().
+		Index(models.ElasticIndexEventlogs).
+		Type("doc").
+		Query(boolQuery).
+		Size(limit).
+		Sort("CreatedAt", false).
+		Do(context.Background())
+	if err != nil {
+		return result, err
+	}
+
+	result = make([]GetElasticEventlogsResult, 0)
+
+	for _, item := range searchResult.Hits.Hits {
+		if item == nil {
+			continue
+		}
+
+		var eventlog models.ElasticEventlog
+		err := json.Unmarshal(*item.Source, &eventlog)
+		if err != nil {
+			continue
+		}
+
+		result = append(result, GetElasticEventlogsResult{
+			ElasticID: item.Id,
+			Entry:     eventlog,
+		})
+	}
+
+	return result, nil
+}
+
 func GetMinTimeForInterval(interval string, count int) (minTime time.Time) {
 	switch interval {
 	case "second":
@@ -839,3 +879,23 @@ func GetLastPresenceUpdate(userID string, elasticsearch bool) (*models.ElasticPr
 	// we found nothing…
 	return nil, errors.New("no presence update found")
 }
+
+// ElasticDeleteOlderThan deletes the documents in an index created before a time, only of the guild if guildID is set
+func ElasticDeleteOlderThan(index, guildID string, before time.Time) (deleted int64, err error) {
+	boolQuery := elastic.NewBoolQuery().
+		Must(elastic.NewRangeQuery("CreatedAt").Lt(before))
+	if guildID != "" {
+		boolQuery.Must(elastic.NewMatchQuery("GuildID", guildID))
+	}
+
+	response, err := cache.GetElastic().DeleteByQuery(index).
+		Type("doc").
+		Query(boolQuery).
+		ProceedOnVersionConflict().
+		Do(context.Background())
+	if err != nil {
+		return 0, err
+	}
+
+	return response.Deleted, nil
+}
@@ -23,6 +23,12 @@ var (
 	AuditLogBackfillRequestsLock = sync.Mutex{}
 )
 
+const (
+	// the allowed retention of eventlog entries and messages in days, 0 keeps them forever
+	EventlogRetentionMinDays = 7
+	EventlogRetentionMaxDays = 3650
+)
+
 /*
 _, err = helpers.EventlogLog(time.Now(), channel.GuildID, targetID,
 	models.EventlogTargetType, msg.Author.ID,
@@ -80,13 +86,14 @@ func EventlogLog(createdAt time.Time, guildID, targetID, targetType, userID, act
 		)
 	*/
 
+	eventlogChannelIDs := getEventlogChannelIDs(guildID, targetID, targetType, userID, actionType, changes, options)
+
 	eventlogID, err := ElasticAddEventlog(createdAt, guildID, targetID, targetType, userID, actionType, reason, changes, options, waitingForAuditLogBackfill, nil)
 	if err != nil {
 		return false, err
 	}
 
 	messageIDs := make([]string, 0)
-	eventlogChannelIDs := GuildSettingsGetCached(guildID).EventlogChannelIDs
 	for _, eventlogChannelID := range eventlogChannelIDs {
 		messages, _ := SendEmbed(eventlogChannelID, getEventlogEmbed(eventlogID, createdAt, guildID, targetID, targetType, userID,
 			actionType, reason, cleanChanges(changes), cleanOptions(options), waitingForAuditLogBackfill))
@@ -116,20 +123,24 @@ func EventlogLogUpdate(elasticID string, UserID string,
 	reason string, auditLogBackfilled, reverted bool) (err error) {
 	eventlogItem, err := ElasticUpdateEventLog(elasticID, UserID, cleanOptions(options), cleanChanges(changes), reason,
 		auditLogBackfilled, reverted, nil)
-	if err != nil {
+	if err != nil || eventlogItem == nil {
 		return
 	}
 
-	if eventlogItem != nil && eventlogItem.EventlogMessages != nil && len(eventlogItem.EventlogMessages) > 0 {
-		embed := getEventlogEmbed(elasticID, eventlogItem.CreatedAt, eventlogItem.GuildID, eventlogItem.TargetID,
-			eventlogItem.TargetType, eventlogItem.UserID, eventlogItem.ActionType, eventlogItem.Reason,
-			eventlogItem.Changes, eventlogItem.Options, eventlogItem.WaitingFor.AuditLogBackfill)
-		for _, messageID := range eventlogItem.EventlogMessages {
-			if strings.Contains(messageID, "|") {
-				parts := strings.SplitN(messageID, "|", 2)
-				if len(parts) >= 2 {
-					EditEmbed(parts[0], parts[1], embed)
-				}
+	embed := getEventlogEmbed(elasticID, eventlogItem.CreatedAt, eventlogItem.GuildID, eventlogItem.TargetID,
+		eventlogItem.TargetType, eventlogItem.UserID, eventlogItem.ActionType, eventlogItem.Reason,
+		eventlogItem.Changes, eventlogItem.Options, eventlogItem.WaitingFor.AuditLogBackfill)
+
+	// rules can filter by the user, which some events only get from the audit log backfill
+	if auditLogBackfilled {
+		return rerouteEventlogItem(elasticID, eventlogItem, embed)
+	}
+
+	for _, messageID := range eventlogItem.EventlogMessages {
+		if strings.Contains(messageID, "|") {
+			parts := strings.SplitN(messageID, "|", 2)
+			if len(parts) >= 2 {
+				EditEmbed(parts[0], parts[1], embed)
 			}
 		}
 	}
@@ -137,6 +148,73 @@ func EventlogLogUpdate(elasticID string, UserID string,
 	return
 }
 
+// getEventlogChannelIDs returns the channels an event is posted to after applying the eventlog rules of the guild
+// dropped events are stored anyway, so they can still be searched, reverted and exported
+func getEventlogChannelIDs(guildID, targetID, targetType, userID, actionType string,
+	changes []models.ElasticEventlogChange, options []models.ElasticEventlogOption) []string {
+	rule := getEventlogRule(guildID, targetID, targetType, userID, actionType, changes, options)
+	if rule == nil {
+		return GuildSettingsGetCached(guildID).EventlogChannelIDs
+	}
+	if rule.Action == models.EventlogRuleActionDrop {
+		return nil
+	}
+	return []string{rule.ChannelID}
+}
+
+// rerouteEventlogItem applies the eventlog rules to an event again
+// messages in channels the event is still routed to are edited, the others are deleted and missing ones are posted
+func rerouteEventlogItem(elasticID string, eventlogItem *models.ElasticEventlog, embed *discordgo.MessageEmbed) (err error) {
+	eventlogChannelIDs := getEventlogChannelIDs(eventlogItem.GuildID, eventlogItem.TargetID, eventlogItem.TargetType,
+		eventlogItem.UserID, eventlogItem.ActionType, eventlogItem.Changes, eventlogItem.Options)
+
+	routedChannelIDs := make(map[string]bool)
+	for _, eventlogChannelID := range eventlogChannelIDs {
+		routedChannelIDs[eventlogChannelID] = false
+	}
+
+	var rerouted bool
+	messageIDs := make([]string, 0)
+	for _, messageID := range eventlogItem.EventlogMessages {
+		parts := strings.SplitN(messageID, "|", 2)
+		if len(parts) < 2 {
+			continue
+		}
+
+		if _, ok := routedChannelIDs[parts[0]]; ok {
+			EditEmbed(parts[0], parts[1], embed)
+			routedChannelIDs[parts[0]] = true
+			messageIDs = append(messageIDs, messageID)
+			continue
+		}
+
+		cache.GetSession().ChannelMessageDelete(parts[0], parts[1])
+		rerouted = true
+	}
+
+	for _, eventlogChannelID := range eventlogChannelIDs {
+		if routedChannelIDs[eventlogChannelID] {
+			continue
+		}
+
+		rerouted = true
+		messages, _ := SendEmbed(eventlogChannelID, embed)
+		if messages != nil && len(messages) >= 1 {
+			messageIDs = append(messageIDs, eventlogChannelID+"|"+messages[0].ID)
+			if CanRevert(*eventlogItem) {
+				cache.GetSession().MessageReactionAdd(eventlogChannelID, messages[0].ID, "↩")
+			}
+		}
+	}
+
+	if !rerouted {
+		return nil
+	}
+
+	_, err = ElasticUpdateEventLog(elasticID, "", nil, nil, "", false, false, messageIDs)
+	return err
+}
+
 func eventlogTargetsToText(guildID, targetType, idsText string) (names []string) {
 	names = make([]string, 0)
 	ids := strings.Split(idsText, ";")
@@ -314,9 +392,15 @@ func getEventlogEmbed(eventlogID string, createdAt time.Time, guildID, targetID,
 		actionType == models.EventlogTypeRobyulInstagramFeedRemove ||
 		actionType == models.EventlogTypeRobyulRedditFeedRemove ||
 		actionType == models.EventlogTypeRobyulFacebookFeedRemove ||
+		actionType == models.EventlogTypeRobyulFeedsFeedRemove ||
 		actionType == models.EventlogTypeRobyulCleanup ||
 		actionType == models.EventlogTypeRobyulMute ||
 		actionType == models.EventlogTypeRobyulUnmute ||
+		actionType == models.EventlogTypeRobyulUnban ||
+		actionType == models.EventlogTypeRobyulTempRoleRemove ||
+		actionType == models.EventlogTypeRobyulBatchRolesDelete ||
+		actionType == models.EventlogTypeRobyulAutomodRuleRemove ||
+		actionType == models.EventlogTypeRobyulAutomodAction ||
 		actionType == models.EventlogTypeRobyulChatlogUpdate ||
 		actionType == models.EventlogTypeRobyulBiasConfigDelete ||
 		actionType == models.EventlogTypeRobyulAutoroleRemove ||
@@ -1102,6 +1186,60 @@ func OnEventlogRoleUpdate(guildID string, oldRole, newRole *discordgo.Role) {
 	}
 }
 
+// OnEventlogRoleDelete logs a deleted role, role is the last known state of the role and may be nil
+func OnEventlogRoleDelete(guildID, roleID string, role *discordgo.Role) {
+	leftAt := time.Now()
+
+	var options []models.ElasticEventlogOption
+
+	if role != nil {
+		options = append(options, models.ElasticEventlogOption{
+			Key:   "role_name",
+			Value: role.Name,
+		})
+
+		options = append(options, models.ElasticEventlogOption{
+			Key:   "role_managed",
+			Value: StoreBoolAsString(role.Managed),
+		})
+
+		options = append(options, models.ElasticEventlogOption{
+			Key:   "role_mentionable",
+			Value: StoreBoolAsString(role.Mentionable),
+		})
+
+		options = append(options, models.ElasticEventlogOption{
+			Key:   "role_hoist",
+			Value: StoreBoolAsString(role.Hoist),
+		})
+
+		if role.Color > 0 {
+			options = append(options, models.ElasticEventlogOption{
+				Key:   "role_color",
+				Value: GetHexFromDiscordColor(role.Color),
+			})
+		}
+
+		options = append(options, models.ElasticEventlogOption{
+			Key:   "role_position",
+			Value: strconv.Itoa(role.Position),
+		})
+
+		options = append(options, models.ElasticEventlogOption{
+			Key:   "role_permissions",
+			Value: strconv.Itoa(role.Permissions),
+			Type:  models.EventlogTargetTypeRolePermissions,
+		})
+	}
+
+	added, err := EventlogLog(leftAt, guildID, roleID, models.EventlogTargetTypeRole, "", models.EventlogTypeRoleDelete, "", nil, options, true)
+	RelaxLog(err)
+	if added {
+		err := RequestAuditLogBackfill(guildID, models.AuditLogBackfillTypeRoleDelete, "")
+		RelaxLog(err)
+	}
+}
+
 func StoreBoolAsString(input bool) (output string) {
 	if input {
 		return "yes"
@@ -0,0 +1,282 @@
+package helpers
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Seklfreak/Robyul2/cache"
+	"github.com/Seklfreak/Robyul2/models"
+	"github.com/olivere/elastic"
+)
+
+type EventlogExportFormat string
+
+const (
+	EventlogExportFormatCSV    EventlogExportFormat = "csv"
+	EventlogExportFormatNDJSON EventlogExportFormat = "ndjson"
+
+	// EventlogExportMaxEntries is the maximum of entries in one export
+	EventlogExportMaxEntries = 100000
+	// EventlogExportLinkLifetime is the time a download link of an export is valid
+	EventlogExportLinkLifetime = 24 * time.Hour
+	// EventlogExportSource is the storage source of exports
+	EventlogExportSource   = "eventlog"
+	eventlogExportPageSize = 1000
+)
+
+var (
+	ErrEventlogExportEmpty           = errors.New("no eventlog entries in the given time range")
+	ErrEventlogExportLinkInvalid     = errors.New("invalid eventlog export link")
+	ErrEventlogExportLinkExpired     = errors.New("eventlog export link expired")
+	errEventlogExportLinkUnavailable = errors.New("eventlog.export_download_url is not configured")
+)
+
+// EventlogExportFilter selects the eventlog entries of an export, ActionTypes and UserID can be empty
+type EventlogExportFilter struct {
+	From        time.Time
+	To          time.Time
+	ActionTypes []string
+	UserID      string
+}
+
+// eventlogExportEntry is an eventlog entry as written in exports
+type eventlogExportEntry struct {
+	ID         string
+	CreatedAt  time.Time
+	ActionType string
+	TargetType string
+	TargetID   string
+	UserID     string
+	Reason     string
+	Changes    []models.ElasticEventlogChange
+	Options    []models.ElasticEventlogOption
+	Reverted   bool
+}
+
+// ExportEventlog writes the eventlog entries of a guild matching the filter, oldest first, to a private file
+// the entries are streamed into the upload, returns an expiring download link and the number of exported entries
+func ExportEventlog(guildID, userID string, filter EventlogExportFilter, format EventlogExportFormat) (link string, count int, err error) {
+	reader, writer := io.Pipe()
+	writeErr := make(chan error, 1)
+	go func() {
+		var err error
+		count, err = WriteEventlogExport(writer, guildID, filter, format)
+		writer.CloseWithError(err)
+		writeErr <- err
+	}()
+
+	objectName, err := AddFileFromReader("", reader, AddFileMetadata{
+		Filename: "eventlog-" + guildID + "-" + filter.From.UTC().Format("20060102") + "-" +
+			filter.To.UTC().Format("20060102") + "." + string(format),
+		GuildID: guildID,
+		UserID:  userID,
+	}, EventlogExportSource, false)
+	// stops the export if the upload failed before reading everything
+	reader.CloseWithError(err)
+	if exportErr := <-writeErr; exportErr != nil {
+		return "", 0, exportErr
+	}
+	if err != nil {
+		return "", 0, err
+	}
+
+	link, err = GetEventlogExportLink(objectName, time.Now().Add(EventlogExportLinkLifetime))
+	return link, count, err
+}
+
+// GetEventlogExportLink returns a download link for an export which is valid until expiresAt
+// the link is signed with the webkey, see CheckEventlogExportLink
+func GetEventlogExportLink(objectName string, expiresAt time.Time) (link string, err error) {
+	config := GetConfig()
+	if !config.ExistsP("eventlog.export_download_url") {
+		return "", errEventlogExportLinkUnavailable
+	}
+	baseURL, _ := config.Path("eventlog.export_download_url").Data().(string)
+	if baseURL == "" {
+		return "", errEventlogExportLinkUnavailable
+	}
+
+	expires := strconv.FormatInt(expiresAt.Unix(), 10)
+	signature, err := getEventlogExportSignature(objectName, expires)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSuffix(baseURL, "/") + "/" + url.PathEscape(objectName) +
+		"?expires=" + expires + "&signature=" + hex.EncodeToString(signature), nil
+}
+
+// CheckEventlogExportLink checks the expiry and signature of a download link created by GetEventlogExportLink
+func CheckEventlogExportLink(objectName, expires, signature string, now time.Time) (err error) {
+	expiresAt, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return ErrEventlogExportLinkInvalid
+	}
+
+	expected, err := getEventlogExportSignature(objectName, expires)
+	if err != nil {
+		return err
+	}
+	received, err := hex.DecodeString(signature)
+	if err != nil || !hmac.Equal(expected, received) {
+		return ErrEventlogExportLinkInvalid
+	}
+
+	if now.Unix() > expiresAt {
+		return ErrEventlogExportLinkExpired
+	}
+	return nil
+}
+
+// getEventlogExportSignature signs the object name and expiry of a download link with the webkey
+func getEventlogExportSignature(objectName, expires string) (signature []byte, err error) {
+	webkey, _ := GetConfig().Path("website.webkey").Data().(string)
+	if webkey == "" {
+		return nil, errors.New("website.webkey is not configured")
+	}
+
+	mac := hmac.New(sha256.New, []byte(webkey))
+	mac.Write([]byte(objectName + "|" + expires))
+	return mac.Sum(nil), nil
+}
+
+// WriteEventlogExport writes the eventlog entries of a guild matching the filter, oldest first, to writer
+// scrolls through the entries page by page, so only one page is kept in memory
+// returns ErrEventlogExportEmpty without writing anything if no entries match
+func WriteEventlogExport(writer io.Writer, guildID string, filter EventlogExportFilter, format EventlogExportFormat) (count int, err error) {
+	if !cache.HasElastic() {
+		return 0, errors.New("elastic is not available")
+	}
+	if format != EventlogExportFormatCSV && format != EventlogExportFormatNDJSON {
+		return 0, errors.New("unknown export format " + string(format))
+	}
+
+	boolQuery := elastic.NewBoolQuery().
+		Must(elastic.NewMatchQuery("GuildID", guildID)).
+		Must(elastic.NewRangeQuery("CreatedAt").Gte(filter.From).Lte(filter.To))
+	if filter.UserID != "" {
+		boolQuery.Must(elastic.NewMatchQuery("UserID", filter.UserID))
+	}
+	if len(filter.ActionTypes) > 0 {
+		actionTypesQuery := elastic.NewBoolQuery().MinimumNumberShouldMatch(1)
+		for _, actionType := range filter.ActionTypes {
+			actionTypesQuery.Should(elastic.NewMatchQuery("ActionType", actionType))
+		}
+		boolQuery.Must(actionTypesQuery)
+	}
+
+	total, err := cache.GetElastic().Count(models.ElasticIndexEventlogs).
+		Type("doc").
+		Query(boolQuery).
+		Do(context.Background())
+	if err != nil {
+		return 0, err
+	}
+	if total <= 0 {
+		return 0, ErrEventlogExportEmpty
+	}
+
+	var csvWriter *csv.Writer
+	var jsonEncoder *json.Encoder
+	if format == EventlogExportFormatCSV {
+		csvWriter = csv.NewWriter(writer)
+		err = csvWriter.Write([]string{
+			"ID", "CreatedAt", "ActionType", "TargetType", "TargetID", "UserID", "Reason", "Changes", "Options", "Reverted"})
+		if err != nil {
+			return 0, err
+		}
+	} else {
+		jsonEncoder = json.NewEncoder(writer)
+	}
+
+	scroll := cache.GetElastic().Scroll(models.ElasticIndexEventlogs).
+		Type("doc").
+		Query(boolQuery).
+		Sort("CreatedAt", true).
+		Size(eventlogExportPageSize)
+	defer scroll.Clear(context.Background())
+
+ScrollLoop:
+	for {
+		searchResult, err := scroll.Do(context.Background())
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return count, err
+		}
+
+		for _, item := range searchResult.Hits.Hits {
+			if item == nil {
+				continue
+			}
+
+			var eventlog models.ElasticEventlog
+			err = json.Unmarshal(*item.Source, &eventlog)
+			if err != nil {
+				continue
+			}
+
+			err = writeEventlogExportEntry(csvWriter, jsonEncoder, eventlogExportEntry{
+				ID:         item.Id,
+				CreatedAt:  eventlog.CreatedAt.UTC(),
+				ActionType: eventlog.ActionType,
+				TargetType: eventlog.TargetType,
+				TargetID:   eventlog.TargetID,
+				UserID:     eventlog.UserID,
+				Reason:     eventlog.Reason,
+				Changes:    eventlog.Changes,
+				Options:    eventlog.Options,
+				Reverted:   eventlog.Reverted,
+			})
+			if err != nil {
+				return count, err
+			}
+
+			count++
+			if count >= EventlogExportMaxEntries {
+				break ScrollLoop
+			}
+		}
+
+		// writes the page instead of letting the csv writer buffer grow
+		if csvWriter != nil {
+			csvWriter.Flush()
+			if err = csvWriter.Error(); err != nil {
+				return count, err
+			}
+		}
+	}
+
+	if csvWriter != nil {
+		csvWriter.Flush()
+		if err = csvWriter.Error(); err != nil {
+			return count, err
+		}
+	}
+
+	return count, nil
+}
+
+// writeEventlogExportEntry writes an entry with the csv writer if it is set, or else with the json encoder
+func writeEventlogExportEntry(csvWriter *csv.Writer, jsonEncoder *json.Encoder, entry eventlogExportEntry) (err error) {
+	if csvWriter == nil {
+		return jsonEncoder.Encode(entry)
+	}
+
+	changes, _ := json.Marshal(entry.Changes)
+	options, _ := json.Marshal(entry.Options)
+	return csvWriter.Write([]string{
+		entry.ID, entry.CreatedAt.Format(time.RFC3339), entry.ActionType, entry.TargetType, entry.TargetID,
+		entry.UserID, entry.Reason, string(changes), string(options), strconv.FormatBool(entry.Reverted)})
+}
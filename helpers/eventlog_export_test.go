@@ -0,0 +1,55 @@
+package helpers
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/gabs"
+)
+
+func TestEventlogExportLink(t *testing.T) {
+	var err error
+	config, err = gabs.ParseJSON([]byte(`{"website": {"webkey": "secret"}, "eventlog": {"export_download_url": "https://api.example.com/v1/eventlog-exports/"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { config = nil }()
+
+	now := time.Now()
+	link, err := GetEventlogExportLink("object", now.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("helpers.GetEventlogExportLink() returned error: %v", err)
+	}
+	if !strings.HasPrefix(link, "https://api.example.com/v1/eventlog-exports/object?") {
+		t.Fatalf("helpers.GetEventlogExportLink() returned %s", link)
+	}
+
+	parsed, err := url.Parse(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expires, signature := parsed.Query().Get("expires"), parsed.Query().Get("signature")
+
+	tests := []struct {
+		objectName string
+		expires    string
+		signature  string
+		at         time.Time
+		expected   error
+	}{
+		{"object", expires, signature, now, nil},
+		{"object", expires, signature, now.Add(2 * time.Hour), ErrEventlogExportLinkExpired},
+		{"other", expires, signature, now, ErrEventlogExportLinkInvalid},
+		{"object", expires + "0", signature, now, ErrEventlogExportLinkInvalid},
+		{"object", expires, signature[:10], now, ErrEventlogExportLinkInvalid},
+		{"object", "never", signature, now, ErrEventlogExportLinkInvalid},
+	}
+
+	for _, test := range tests {
+		if err = CheckEventlogExportLink(test.objectName, test.expires, test.signature, test.at); err != test.expected {
+			t.Errorf("helpers.CheckEventlogExportLink(%s, %s) returned %v, expected %v", test.objectName, test.expires, err, test.expected)
+		}
+	}
+}
@@ -25,6 +25,11 @@ func CanRevert(item models.ElasticEventlog) bool {
 		return false
 	}
 
+	// bans are reverted by unbanning the user, they have no changes or options
+	if item.ActionType == models.EventlogTypeBanAdd {
+		return true
+	}
+
 	if len(item.Changes) <= 0 && len(item.Options) <= 0 {
 		return false
 	}
@@ -72,6 +77,20 @@ func CanRevert(item models.ElasticEventlog) bool {
 		) {
 			return true
 		}
+	case models.EventlogTypeRoleDelete:
+		// roles managed by an integration can not be created
+		for _, option := range item.Options {
+			if option.Key == "role_managed" && GetStringAsBool(option.Value) {
+				return false
+			}
+		}
+		if containsAllowedChangesOrOptions(
+			item,
+			nil,
+			[]string{"role_name", "role_permissions"},
+		) {
+			return true
+		}
 	case models.EventlogTypeChannelDelete:
 		if containsAllowedChangesOrOptions(
 			item,
@@ -206,14 +225,14 @@ func Revert(eventlogID, userID string, item models.ElasticEventlog) (err error)
 			switch option.Key {
 			case "member_roles_added":
 				for _, roleID := range strings.Split(option.Value, ";") {
-					err = cache.GetSession().GuildMemberRoleRemove(item.GuildID, item.TargetID, roleID)
+					err = revertMemberRoleChange(item.GuildID, item.TargetID, roleID, false)
 					if err != nil {
 						return err
 					}
 				}
 			case "member_roles_removed":
 				for _, roleID := range strings.Split(option.Value, ";") {
-					err = cache.GetSession().GuildMemberRoleAdd(item.GuildID, item.TargetID, roleID)
+					err = revertMemberRoleChange(item.GuildID, item.TargetID, roleID, true)
 					if err != nil {
 						return err
 					}
@@ -420,12 +439,109 @@ func Revert(eventlogID, userID string, item models.ElasticEventlog) (err error)
 			return err
 		}
 
+		return logRevert(item.GuildID, userID, eventlogID)
+	case models.EventlogTypeRoleDelete:
+		var roleName string
+		var roleColor, rolePermissions, rolePosition int
+		var roleHoist, roleMentionable bool
+
+		for _, option := range item.Options {
+			switch option.Key {
+			case "role_name":
+				roleName = option.Value
+			case "role_color":
+				roleColor = GetDiscordColorFromHex(option.Value)
+			case "role_hoist":
+				roleHoist = GetStringAsBool(option.Value)
+			case "role_mentionable":
+				roleMentionable = GetStringAsBool(option.Value)
+			case "role_permissions":
+				tempPermissions, err := strconv.Atoi(option.Value)
+				if err == nil {
+					rolePermissions = tempPermissions
+				}
+			case "role_position":
+				tempPosition, err := strconv.Atoi(option.Value)
+				if err == nil {
+					rolePosition = tempPosition
+				}
+			}
+		}
+
+		role, err := cache.GetSession().GuildRoleCreate(item.GuildID)
+		if err != nil {
+			return err
+		}
+
+		role, err = cache.GetSession().GuildRoleEdit(item.GuildID, role.ID, roleName, roleColor, roleHoist, rolePermissions, roleMentionable)
+		if err != nil {
+			return err
+		}
+
+		// Robyul can only move roles below its highest role, the role stays at the bottom in that case
+		if rolePosition > 0 {
+			_, err = cache.GetSession().GuildRoleReorder(item.GuildID, []*discordgo.Role{{ID: role.ID, Position: rolePosition}})
+			if err != nil && !isDiscordPermissionsError(err) {
+				RelaxLog(err)
+			}
+		}
+
+		// give the role back to the members that had it before
+		userIDs, err := GetPersistencyMembersWithRole(item.GuildID, item.TargetID)
+		RelaxLog(err)
+		for _, memberUserID := range userIDs {
+			if !GetIsInGuild(item.GuildID, memberUserID) {
+				continue
+			}
+
+			err = cache.GetSession().GuildMemberRoleAdd(item.GuildID, memberUserID, role.ID)
+			if err != nil && !isDiscordPermissionsError(err) {
+				RelaxLog(err)
+			}
+		}
+
+		return logRevert(item.GuildID, userID, eventlogID)
+	case models.EventlogTypeBanAdd:
+		err = cache.GetSession().GuildBanDelete(item.GuildID, item.TargetID)
+		if err != nil {
+			return err
+		}
+
+		// the mod plugin would try to unban the user again once a temp-ban expires
+		err = EndModActions(item.GuildID, item.TargetID, models.ModActionTypeBan, "", models.ModActionEndReasonReverted, userID)
+		RelaxLog(err)
+
 		return logRevert(item.GuildID, userID, eventlogID)
 	}
 
 	return errors.New("eventlog action type not supported")
 }
 
+// revertMemberRoleChange gives a role to or removes a role from a member
+// roles that have been deleted since, or that are managed by an integration, are skipped
+func revertMemberRoleChange(guildID, userID, roleID string, add bool) (err error) {
+	if roleID == "" {
+		return nil
+	}
+
+	role, err := cache.GetSession().State.Role(guildID, roleID)
+	if err != nil || role.Managed {
+		return nil
+	}
+
+	if add {
+		return cache.GetSession().GuildMemberRoleAdd(guildID, userID, roleID)
+	}
+	return cache.GetSession().GuildMemberRoleRemove(guildID, userID, roleID)
+}
+
+func isDiscordPermissionsError(err error) bool {
+	if errD, ok := err.(*discordgo.RESTError); ok && errD.Message != nil {
+		return errD.Message.Code == discordgo.ErrCodeMissingPermissions || errD.Message.Code == discordgo.ErrCodeMissingAccess
+	}
+	return false
+}
+
 func logRevert(guildID, userID, eventlogID string) error {
 	// add new eventlog entry for revert
 	_, err := EventlogLog(time.Now(), guildID, eventlogID,
@@ -0,0 +1,293 @@
+package helpers
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/Seklfreak/Robyul2/models"
+)
+
+const (
+	// EventlogRulesMax is the maximum of eventlog rules per guild
+	EventlogRulesMax = 25
+)
+
+var (
+	errEventlogRuleEmpty         = errors.New("eventlog rule has no filters")
+	errEventlogRuleInvalidAction = errors.New("eventlog rule action has to be drop or a channel")
+	errEventlogRuleInvalidFilter = errors.New("eventlog rule filters have to be key=value,value")
+)
+
+// ParseEventlogRule parses a rule like "drop type=Member_Join,Member_Leave user=<id>" or "<#channel> role=<id>"
+// known filters are type, target, user, role, channel and change
+func ParseEventlogRule(text string) (rule models.EventlogRule, err error) {
+	parts := strings.Fields(text)
+	if len(parts) < 1 {
+		return rule, errEventlogRuleInvalidAction
+	}
+
+	if strings.ToLower(parts[0]) == string(models.EventlogRuleActionDrop) {
+		rule.Action = models.EventlogRuleActionDrop
+	} else {
+		rule.Action = models.EventlogRuleActionRoute
+		rule.ChannelID = cleanEventlogRuleID(parts[0])
+		if !isEventlogRuleID(rule.ChannelID) {
+			return rule, errEventlogRuleInvalidAction
+		}
+	}
+
+	for _, filter := range parts[1:] {
+		filterParts := strings.SplitN(filter, "=", 2)
+		if len(filterParts) < 2 {
+			return rule, errEventlogRuleInvalidFilter
+		}
+
+		values := make([]string, 0)
+		for _, value := range strings.Split(filterParts[1], ",") {
+			if value = strings.TrimSpace(value); value != "" {
+				values = append(values, value)
+			}
+		}
+		if len(values) <= 0 {
+			return rule, errEventlogRuleInvalidFilter
+		}
+
+		switch strings.ToLower(filterParts[0]) {
+		case "type", "types":
+			rule.ActionTypes = append(rule.ActionTypes, values...)
+		case "target", "targets":
+			rule.TargetTypes = append(rule.TargetTypes, values...)
+		case "change", "changes":
+			rule.ChangeKeys = append(rule.ChangeKeys, values...)
+		case "user", "users", "role", "roles", "channel", "channels":
+			for i := range values {
+				values[i] = cleanEventlogRuleID(values[i])
+				if !isEventlogRuleID(values[i]) {
+					return rule, errEventlogRuleInvalidFilter
+				}
+			}
+			switch strings.TrimSuffix(strings.ToLower(filterParts[0]), "s") {
+			case "user":
+				rule.UserIDs = append(rule.UserIDs, values...)
+			case "role":
+				rule.RoleIDs = append(rule.RoleIDs, values...)
+			case "channel":
+				rule.ChannelIDs = append(rule.ChannelIDs, values...)
+			}
+		default:
+			return rule, errEventlogRuleInvalidFilter
+		}
+	}
+
+	if len(rule.ActionTypes) <= 0 && len(rule.TargetTypes) <= 0 && len(rule.UserIDs) <= 0 &&
+		len(rule.RoleIDs) <= 0 && len(rule.ChannelIDs) <= 0 && len(rule.ChangeKeys) <= 0 {
+		return rule, errEventlogRuleEmpty
+	}
+
+	return rule, nil
+}
+
+// FormatEventlogRule returns the rule in the format ParseEventlogRule accepts
+func FormatEventlogRule(rule models.EventlogRule) (text string) {
+	if rule.Action == models.EventlogRuleActionDrop {
+		text = string(models.EventlogRuleActionDrop)
+	} else {
+		text = "<#" + rule.ChannelID + ">"
+	}
+
+	for _, filter := range []struct {
+		key    string
+		values []string
+	}{
+		{"type", rule.ActionTypes},
+		{"target", rule.TargetTypes},
+		{"user", rule.UserIDs},
+		{"role", rule.RoleIDs},
+		{"channel", rule.ChannelIDs},
+		{"change", rule.ChangeKeys},
+	} {
+		if len(filter.values) > 0 {
+			text += " " + filter.key + "=" + strings.Join(filter.values, ",")
+		}
+	}
+
+	return text
+}
+
+// IsEventlogChannel returns true if eventlog events of the guild are posted in the channel, including routed events
+func IsEventlogChannel(guildID, channelID string) bool {
+	settings := GuildSettingsGetCached(guildID)
+	for _, eventlogChannelID := range settings.EventlogChannelIDs {
+		if eventlogChannelID == channelID {
+			return true
+		}
+	}
+	for _, rule := range settings.EventlogRules {
+		if rule.Action == models.EventlogRuleActionRoute && rule.ChannelID == channelID {
+			return true
+		}
+	}
+	return false
+}
+
+// getEventlogRule returns the first rule of the guild matching the event, or nil if no rule matches
+func getEventlogRule(guildID, targetID, targetType, userID, actionType string,
+	changes []models.ElasticEventlogChange, options []models.ElasticEventlogOption) *models.EventlogRule {
+	rules := GuildSettingsGetCached(guildID).EventlogRules
+	if len(rules) <= 0 {
+		return nil
+	}
+
+	targetIDs := strings.Split(targetID, ";")
+
+	// IDs referenced by the changes and options, by type
+	referencedIDs := make(map[string][]string)
+	for _, change := range changes {
+		referencedIDs[change.Type] = append(referencedIDs[change.Type], strings.Split(change.OldValue, ";")...)
+		referencedIDs[change.Type] = append(referencedIDs[change.Type], strings.Split(change.NewValue, ";")...)
+	}
+	for _, option := range options {
+		referencedIDs[option.Type] = append(referencedIDs[option.Type], strings.Split(option.Value, ";")...)
+	}
+
+	userIDs := []string{userID}
+	channelIDs := referencedIDs[models.EventlogTargetTypeChannel]
+	roleIDs := referencedIDs[models.EventlogTargetTypeRole]
+	switch targetType {
+	case models.EventlogTargetTypeUser:
+		userIDs = append(userIDs, targetIDs...)
+	case models.EventlogTargetTypeChannel:
+		channelIDs = append(channelIDs, targetIDs...)
+	case models.EventlogTargetTypeRole:
+		roleIDs = append(roleIDs, targetIDs...)
+	}
+
+	var rolesLoaded bool
+	for i, rule := range rules {
+		if len(rule.ActionTypes) > 0 && !eventlogRuleMatchesFold(rule.ActionTypes, actionType) {
+			continue
+		}
+		if len(rule.TargetTypes) > 0 && !eventlogRuleMatchesFold(rule.TargetTypes, targetType) {
+			continue
+		}
+		if len(rule.ChangeKeys) > 0 && !eventlogRuleMatchesChangeKey(rule.ChangeKeys, changes, options) {
+			continue
+		}
+		if len(rule.UserIDs) > 0 && !eventlogRuleMatchesAny(rule.UserIDs, userIDs) {
+			continue
+		}
+		if len(rule.ChannelIDs) > 0 && !eventlogRuleMatchesAny(rule.ChannelIDs, channelIDs) {
+			continue
+		}
+		if len(rule.RoleIDs) > 0 {
+			// roles of the involved members are only looked up once a rule needs them
+			if !rolesLoaded {
+				for _, memberID := range userIDs {
+					member, err := GetGuildMemberWithoutApi(guildID, memberID)
+					if err == nil && member != nil {
+						roleIDs = append(roleIDs, member.Roles...)
+					}
+				}
+				rolesLoaded = true
+			}
+			if !eventlogRuleMatchesAny(rule.RoleIDs, roleIDs) {
+				continue
+			}
+		}
+		return &rules[i]
+	}
+
+	return nil
+}
+
+func eventlogRuleMatchesFold(values []string, value string) bool {
+	for _, ruleValue := range values {
+		if strings.EqualFold(ruleValue, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func eventlogRuleMatchesAny(values, ids []string) bool {
+	for _, ruleValue := range values {
+		for _, id := range ids {
+			if id != "" && ruleValue == id {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func eventlogRuleMatchesChangeKey(values []string, changes []models.ElasticEventlogChange, options []models.ElasticEventlogOption) bool {
+	for _, change := range changes {
+		if eventlogRuleMatchesFold(values, change.Key) {
+			return true
+		}
+	}
+	for _, option := range options {
+		if eventlogRuleMatchesFold(values, option.Key) {
+			return true
+		}
+	}
+	return false
+}
+
+// cleanEventlogRuleID removes the mention formatting around user, role and channel IDs
+func cleanEventlogRuleID(text string) string {
+	text = strings.TrimSuffix(text, ">")
+	for _, prefix := range []string{"<@&", "<@!", "<@", "<#"} {
+		if strings.HasPrefix(text, prefix) {
+			return strings.TrimPrefix(text, prefix)
+		}
+	}
+	return text
+}
+
+func isEventlogRuleID(text string) bool {
+	if text == "" {
+		return false
+	}
+	for _, character := range text {
+		if character < '0' || character > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// SetEventlogRules replaces the eventlog rules of the guild and logs the change
+func SetEventlogRules(guildID, userID string, rules []models.EventlogRule) (err error) {
+	if len(rules) > EventlogRulesMax {
+		return errors.New("too many eventlog rules")
+	}
+
+	settings := GuildSettingsGetCached(guildID)
+
+	oldRules := make([]string, 0, len(settings.EventlogRules))
+	for _, rule := range settings.EventlogRules {
+		oldRules = append(oldRules, FormatEventlogRule(rule))
+	}
+	newRules := make([]string, 0, len(rules))
+	for _, rule := range rules {
+		newRules = append(newRules, FormatEventlogRule(rule))
+	}
+
+	_, err = EventlogLog(time.Now(), guildID, guildID,
+		models.EventlogTargetTypeGuild, userID,
+		models.EventlogTypeRobyulEventlogConfigUpdate, "",
+		[]models.ElasticEventlogChange{
+			{
+				Key:      "eventlog_rules",
+				OldValue: strings.Join(oldRules, "\n"),
+				NewValue: strings.Join(newRules, "\n"),
+			},
+		},
+		nil, false)
+	RelaxLog(err)
+
+	settings.EventlogRules = rules
+	return GuildSettingsSet(guildID, settings)
+}
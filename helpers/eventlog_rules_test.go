@@ -0,0 +1,101 @@
+package helpers
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Seklfreak/Robyul2/cache"
+	"github.com/Seklfreak/Robyul2/models"
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestParseEventlogRule(t *testing.T) {
+	tests := []struct {
+		text    string
+		rule    models.EventlogRule
+		wantErr bool
+	}{
+		{"drop type=Member_Join,Member_Leave", models.EventlogRule{Action: models.EventlogRuleActionDrop, ActionTypes: []string{"Member_Join", "Member_Leave"}}, false},
+		{"<#100> user=<@!200> role=<@&300>", models.EventlogRule{Action: models.EventlogRuleActionRoute, ChannelID: "100", UserIDs: []string{"200"}, RoleIDs: []string{"300"}}, false},
+		{"100 channels=<#400>,500 change=role_name", models.EventlogRule{Action: models.EventlogRuleActionRoute, ChannelID: "100", ChannelIDs: []string{"400", "500"}, ChangeKeys: []string{"role_name"}}, false},
+		{"drop targets=user", models.EventlogRule{Action: models.EventlogRuleActionDrop, TargetTypes: []string{"user"}}, false},
+		{"", models.EventlogRule{}, true},
+		{"#general type=Member_Join", models.EventlogRule{}, true},
+		{"drop", models.EventlogRule{}, true},
+		{"drop type", models.EventlogRule{}, true},
+		{"drop type=,", models.EventlogRule{}, true},
+		{"drop user=someone", models.EventlogRule{}, true},
+		{"drop colour=red", models.EventlogRule{}, true},
+	}
+
+	for _, test := range tests {
+		rule, err := ParseEventlogRule(test.text)
+		if (err != nil) != test.wantErr {
+			t.Errorf("helpers.ParseEventlogRule(%q) returned error %v, want error: %t", test.text, err, test.wantErr)
+			continue
+		}
+		if !test.wantErr && !reflect.DeepEqual(rule, test.rule) {
+			t.Errorf("helpers.ParseEventlogRule(%q) returned %+v, want %+v", test.text, rule, test.rule)
+		}
+	}
+}
+
+func TestGetEventlogRule(t *testing.T) {
+	const guildID = "1"
+
+	state := discordgo.NewState()
+	state.GuildAdd(&discordgo.Guild{ID: guildID})
+	state.MemberAdd(&discordgo.Member{GuildID: guildID, User: &discordgo.User{ID: "200"}, Roles: []string{"300"}})
+	cache.SetSession(&discordgo.Session{State: state})
+
+	rules := []models.EventlogRule{
+		{Action: models.EventlogRuleActionDrop, ActionTypes: []string{models.EventlogTypeMemberJoin}},
+		{Action: models.EventlogRuleActionRoute, ChannelID: "10", UserIDs: []string{"201"}},
+		{Action: models.EventlogRuleActionRoute, ChannelID: "11", RoleIDs: []string{"300"}},
+		{Action: models.EventlogRuleActionRoute, ChannelID: "12", ChannelIDs: []string{"400"}},
+		{Action: models.EventlogRuleActionRoute, ChannelID: "13", ChangeKeys: []string{"role_name"}, TargetTypes: []string{models.EventlogTargetTypeRole}},
+	}
+	cacheMutex.Lock()
+	guildSettingsCache[guildID] = models.Config{EventlogRules: rules}
+	cacheMutex.Unlock()
+
+	tests := []struct {
+		targetID   string
+		targetType string
+		userID     string
+		actionType string
+		changes    []models.ElasticEventlogChange
+		options    []models.ElasticEventlogOption
+		rule       int
+	}{
+		{"200", models.EventlogTargetTypeUser, "", models.EventlogTypeMemberJoin, nil, nil, 0},
+		{"202", models.EventlogTargetTypeUser, "201", models.EventlogTypeBanAdd, nil, nil, 1},
+		{"202", models.EventlogTargetTypeUser, "", models.EventlogTypeBanAdd, nil, nil, -1},
+		{"202", models.EventlogTargetTypeUser, "200", models.EventlogTypeMemberUpdate, nil, nil, 2},
+		{"300", models.EventlogTargetTypeRole, "", models.EventlogTypeRoleDelete, nil, nil, 2},
+		{"400", models.EventlogTargetTypeChannel, "", models.EventlogTypeChannelDelete, nil, nil, 3},
+		{"202", models.EventlogTargetTypeUser, "", models.EventlogTypeMemberUpdate, nil,
+			[]models.ElasticEventlogOption{{Key: "channel", Value: "500;400", Type: models.EventlogTargetTypeChannel}}, 3},
+		{"301", models.EventlogTargetTypeRole, "", models.EventlogTypeRoleUpdate,
+			[]models.ElasticEventlogChange{{Key: "role_name", OldValue: "a", NewValue: "b"}}, nil, 4},
+		{"202", models.EventlogTargetTypeUser, "", models.EventlogTypeMemberUpdate,
+			[]models.ElasticEventlogChange{{Key: "role_name", OldValue: "a", NewValue: "b"}}, nil, -1},
+	}
+
+	for _, test := range tests {
+		rule := getEventlogRule(guildID, test.targetID, test.targetType, test.userID, test.actionType, test.changes, test.options)
+		if test.rule < 0 {
+			if rule != nil {
+				t.Errorf("helpers.getEventlogRule() returned %+v for %+v, want no rule", *rule, test)
+			}
+			continue
+		}
+		if rule == nil || !reflect.DeepEqual(*rule, rules[test.rule]) {
+			t.Errorf("helpers.getEventlogRule() returned %+v for %+v, want %+v", rule, test, rules[test.rule])
+		}
+	}
+
+	if rule := getEventlogRule("2", "200", models.EventlogTargetTypeUser, "", models.EventlogTypeMemberJoin, nil, nil); rule != nil {
+		t.Errorf("helpers.getEventlogRule() returned %+v for a guild without rules", *rule)
+	}
+}
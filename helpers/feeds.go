@@ -0,0 +1,285 @@
+package helpers
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/Seklfreak/Robyul2/cache"
+	"github.com/Seklfreak/Robyul2/models"
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	// feedRegexFiltersCacheSize is the number of compiled regex filters after which the cache is cleared
+	feedRegexFiltersCacheSize = 1000
+)
+
+var (
+	// feedRegexFilters caches the compiled regex filters by filter, nil for invalid filters
+	feedRegexFilters     = make(map[string]*regexp.Regexp)
+	feedRegexFiltersLock sync.RWMutex
+)
+
+// FeedPost is a post of a social feed, its values are used by the filters and as placeholders in embed codes
+type FeedPost struct {
+	Author   string
+	Title    string
+	URL      string
+	Content  string
+	MediaURL string
+}
+
+// ParseFeedOptions parses the feed options in the arguments of an add command
+// include:<filters>, exclude:<filters> and mention:<role> are single arguments, filters are comma separated keywords
+// or /regular expressions/, underscores in keywords are spaces
+// everything after embed: is the embed code, it is taken from the content to keep its spacing, args have to be the last fields of content
+// rest are the arguments without the feed options
+func ParseFeedOptions(guildID string, args []string, content string) (options models.FeedOptions, rest []string, err error) {
+	for i, arg := range args {
+		switch {
+		case strings.HasPrefix(arg, "embed:"):
+			options.EmbedCode = strings.TrimSpace(strings.TrimPrefix(feedOptionsRemainder(args, i, content), "embed:"))
+			if options.EmbedCode == "" {
+				return options, rest, errors.New("the embed code is empty")
+			}
+			return options, rest, nil
+		case strings.HasPrefix(arg, "include:"):
+			filters, err := parseFeedFilters(strings.TrimPrefix(arg, "include:"))
+			if err != nil {
+				return options, rest, err
+			}
+			options.IncludeFilters = append(options.IncludeFilters, filters...)
+		case strings.HasPrefix(arg, "exclude:"):
+			filters, err := parseFeedFilters(strings.TrimPrefix(arg, "exclude:"))
+			if err != nil {
+				return options, rest, err
+			}
+			options.ExcludeFilters = append(options.ExcludeFilters, filters...)
+		case strings.HasPrefix(arg, "mention:"):
+			role, err := getFeedMentionRole(guildID, strings.TrimPrefix(arg, "mention:"))
+			if err != nil {
+				return options, rest, err
+			}
+			options.MentionRoleID = role.ID
+		default:
+			rest = append(rest, arg)
+		}
+	}
+
+	return options, rest, nil
+}
+
+// feedOptionsRemainder returns the content from the argument at index on, args are the last fields of content
+// falls back to joining the arguments if they don't match the content
+func feedOptionsRemainder(args []string, index int, content string) string {
+	var fieldStarts []int
+	inField := false
+	for position, character := range content {
+		if unicode.IsSpace(character) {
+			inField = false
+			continue
+		}
+		if !inField {
+			fieldStarts = append(fieldStarts, position)
+			inField = true
+		}
+	}
+
+	field := len(fieldStarts) - len(args) + index
+	if field >= 0 && field < len(fieldStarts) && strings.HasPrefix(content[fieldStarts[field]:], args[index]) {
+		return content[fieldStarts[field]:]
+	}
+	return strings.Join(args[index:], " ")
+}
+
+func parseFeedFilters(text string) (filters []string, err error) {
+	for _, filter := range strings.Split(text, ",") {
+		filter = strings.TrimSpace(filter)
+		if filter == "" {
+			continue
+		}
+
+		if isFeedRegexFilter(filter) {
+			_, err = compileFeedRegexFilter(filter)
+			if err != nil {
+				return nil, fmt.Errorf("invalid regular expression %s", filter)
+			}
+			filters = append(filters, filter)
+			continue
+		}
+
+		filters = append(filters, strings.ToLower(strings.Replace(filter, "_", " ", -1)))
+	}
+
+	if len(filters) <= 0 {
+		return nil, errors.New("the filter is empty")
+	}
+	return filters, nil
+}
+
+func getFeedMentionRole(guildID, roleText string) (role *discordgo.Role, err error) {
+	roleText = strings.TrimSuffix(strings.TrimPrefix(roleText, "<@&"), ">")
+
+	guild, err := GetGuild(guildID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, guildRole := range guild.Roles {
+		if guildRole.ID == roleText || strings.ToLower(guildRole.Name) == strings.ToLower(roleText) {
+			return guildRole, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unable to find the role %s", roleText)
+}
+
+func isFeedRegexFilter(filter string) bool {
+	return len(filter) > 2 && strings.HasPrefix(filter, "/") && strings.HasSuffix(filter, "/")
+}
+
+func compileFeedRegexFilter(filter string) (*regexp.Regexp, error) {
+	return regexp.Compile("(?i)" + filter[1:len(filter)-1])
+}
+
+// getFeedRegexFilter returns the compiled regex filter, compiling it only once, nil if the filter is invalid
+func getFeedRegexFilter(filter string) *regexp.Regexp {
+	feedRegexFiltersLock.RLock()
+	regex, ok := feedRegexFilters[filter]
+	feedRegexFiltersLock.RUnlock()
+	if ok {
+		return regex
+	}
+
+	regex, err := compileFeedRegexFilter(filter)
+	if err != nil {
+		regex = nil
+	}
+
+	feedRegexFiltersLock.Lock()
+	defer feedRegexFiltersLock.Unlock()
+	if len(feedRegexFilters) >= feedRegexFiltersCacheSize {
+		feedRegexFilters = make(map[string]*regexp.Regexp)
+	}
+	feedRegexFilters[filter] = regex
+	return regex
+}
+
+// FeedPostMatches checks if the post matches one of the include filters, if set, and none of the exclude filters
+// the filters are matched against the title and content of the post
+func FeedPostMatches(options models.FeedOptions, post FeedPost) bool {
+	text := post.Title + "\n" + post.Content
+
+	for _, filter := range options.ExcludeFilters {
+		if feedFilterMatches(filter, text) {
+			return false
+		}
+	}
+
+	if len(options.IncludeFilters) <= 0 {
+		return true
+	}
+	for _, filter := range options.IncludeFilters {
+		if feedFilterMatches(filter, text) {
+			return true
+		}
+	}
+	return false
+}
+
+func feedFilterMatches(filter, text string) bool {
+	if isFeedRegexFilter(filter) {
+		regex := getFeedRegexFilter(filter)
+		if regex == nil {
+			return false
+		}
+		return regex.MatchString(text)
+	}
+
+	return strings.Contains(strings.ToLower(text), filter)
+}
+
+// FeedMessageSend returns the message for the post, the embed code of the options replaces the default message if set
+// the embed code can use the placeholders {AUTHOR}, {TITLE}, {URL}, {CONTENT} and {MEDIA_URL}
+// the role of the options is mentioned above the message
+func FeedMessageSend(options models.FeedOptions, post FeedPost, defaultMessage *discordgo.MessageSend) *discordgo.MessageSend {
+	message := defaultMessage
+	if options.EmbedCode != "" {
+		message = &discordgo.MessageSend{
+			Content: options.EmbedCode,
+		}
+		if IsEmbedCode(options.EmbedCode) {
+			ptext, embed, err := ParseEmbedCode(options.EmbedCode)
+			if err == nil {
+				message.Content = ptext
+				message.Embed = embed
+			}
+		}
+
+		message = ReplaceMessageSend(message, []*ReplaceValues{
+			{Before: "{AUTHOR}", After: post.Author},
+			{Before: "{TITLE}", After: post.Title},
+			{Before: "{URL}", After: post.URL},
+			{Before: "{CONTENT}", After: post.Content},
+			{Before: "{MEDIA_URL}", After: post.MediaURL},
+		})
+		if message.Embed != nil && message.Embed.Image != nil && message.Embed.Image.URL == "" {
+			message.Embed.Image = nil
+		}
+	}
+
+	if options.MentionRoleID != "" {
+		message.Content = fmt.Sprintf("<@&%s>\n", options.MentionRoleID) + message.Content
+	}
+
+	return message
+}
+
+// FeedOptionsText describes the feed options for lists, roles are named instead of mentioned
+func FeedOptionsText(guildID string, options models.FeedOptions) (text string) {
+	if options.MentionRoleID != "" {
+		roleName := "#" + options.MentionRoleID
+		role, err := cache.GetSession().State.Role(guildID, options.MentionRoleID)
+		if err == nil {
+			roleName = role.Name
+		}
+		text += fmt.Sprintf(" mentioning `@%s`", roleName)
+	}
+	if len(options.IncludeFilters) > 0 {
+		text += " including `" + strings.Join(options.IncludeFilters, "`, `") + "`"
+	}
+	if len(options.ExcludeFilters) > 0 {
+		text += " excluding `" + strings.Join(options.ExcludeFilters, "`, `") + "`"
+	}
+	if options.EmbedCode != "" {
+		text += " using a custom embed"
+	}
+	return text
+}
+
+// FeedOptionsEventlogOptions returns the feed options as eventlog options, the keys are prefixed with the module
+func FeedOptionsEventlogOptions(prefix string, options models.FeedOptions) []models.ElasticEventlogOption {
+	return []models.ElasticEventlogOption{
+		{
+			Key:   prefix + "_mentionroleid",
+			Value: options.MentionRoleID,
+			Type:  models.EventlogTargetTypeRole,
+		},
+		{
+			Key:   prefix + "_include",
+			Value: strings.Join(options.IncludeFilters, ","),
+		},
+		{
+			Key:   prefix + "_exclude",
+			Value: strings.Join(options.ExcludeFilters, ","),
+		},
+		{
+			Key:   prefix + "_embedcode",
+			Value: options.EmbedCode,
+		},
+	}
+}
@@ -0,0 +1,207 @@
+package helpers
+
+import (
+	"errors"
+	"time"
+
+	"github.com/Jeffail/gabs"
+	"github.com/Seklfreak/Robyul2/cache"
+	"github.com/Seklfreak/Robyul2/models"
+	"github.com/globalsign/mgo/bson"
+)
+
+// machineryUnmute is an unmute_user task that has been scheduled with machinery
+type machineryUnmute struct {
+	GuildID  string
+	UserID   string
+	ETA      time.Time
+	TaskJson string
+}
+
+// CreateModAction stores a timed moderation action, an active action of the same type for the same target is replaced
+func CreateModAction(action models.ModActionEntry) (models.ModActionEntry, error) {
+	if action.GuildID == "" || action.Type == "" || action.ExpiresAt.IsZero() {
+		return action, errors.New("invalid mod action")
+	}
+	if action.UserID == "" && action.Type != models.ModActionTypeBatchRole {
+		return action, errors.New("invalid mod action")
+	}
+
+	err := EndModActions(action.GuildID, action.UserID, action.Type, action.RoleID,
+		models.ModActionEndReasonReplaced, action.CreatedByUserID)
+	if err != nil {
+		return action, err
+	}
+
+	action.ID = ""
+	action.Active = true
+	action.Attempts = 0
+	if action.CreatedAt.IsZero() {
+		action.CreatedAt = time.Now()
+	}
+
+	action.ID, err = MDbInsert(models.ModActionsTable, action)
+	return action, err
+}
+
+// GetModAction returns an action of a guild by its human readable ID
+func GetModAction(guildID, id string) (action models.ModActionEntry, err error) {
+	err = MdbOne(
+		MdbCollection(models.ModActionsTable).Find(bson.M{"_id": HumanToMdbId(id), "guildid": guildID}),
+		&action,
+	)
+	return action, err
+}
+
+// GetActiveModActions returns the active actions of a guild sorted by their expiry, actionType and userID are optional filters
+func GetActiveModActions(guildID string, actionType models.ModActionType, userID string) (actions []models.ModActionEntry, err error) {
+	query := bson.M{"guildid": guildID, "active": true}
+	if actionType != "" {
+		query["type"] = actionType
+	}
+	if userID != "" {
+		query["userid"] = userID
+	}
+
+	err = MDbIter(MdbCollection(models.ModActionsTable).Find(query).Sort("expiresat")).All(&actions)
+	return actions, err
+}
+
+// ExtendModAction moves the expiry of an active action
+func ExtendModAction(action models.ModActionEntry, expiresAt time.Time) (err error) {
+	return MDbUpdateQuery(
+		models.ModActionsTable,
+		bson.M{"_id": action.ID, "active": true},
+		bson.M{"$set": bson.M{"expiresat": expiresAt, "attempts": 0}},
+	)
+}
+
+// EndModAction marks an action as ended, returns a not found error if the action has ended already
+// it does not revert the action on the guild, expired actions are reverted by the mod plugin
+func EndModAction(action models.ModActionEntry, reason models.ModActionEndReason, endedByUserID string) (err error) {
+	return MDbUpdateQuery(
+		models.ModActionsTable,
+		bson.M{"_id": action.ID, "active": true},
+		bson.M{"$set": bson.M{
+			"active":        false,
+			"endedat":       time.Now(),
+			"endedbyuserid": endedByUserID,
+			"endreason":     reason,
+		}},
+	)
+}
+
+// EndModActions marks all active actions of a type for a target as ended
+func EndModActions(guildID, userID string, actionType models.ModActionType, roleID string, reason models.ModActionEndReason, endedByUserID string) (err error) {
+	_, err = MdbCollection(models.ModActionsTable).UpdateAll(
+		bson.M{"guildid": guildID, "userid": userID, "type": actionType, "roleid": roleID, "active": true},
+		bson.M{"$set": bson.M{
+			"active":        false,
+			"endedat":       time.Now(),
+			"endedbyuserid": endedByUserID,
+			"endreason":     reason,
+		}},
+	)
+	return err
+}
+
+// EndRevertedModActions marks all active actions of a type for a target as reverted, after they have been reverted outside of the bot
+// actions which are being reverted by the mod actions scheduler are left to it, so they end as expired
+func EndRevertedModActions(guildID, userID string, actionType models.ModActionType, roleID string) (err error) {
+	_, err = MdbCollection(models.ModActionsTable).UpdateAll(
+		bson.M{
+			"guildid":  guildID,
+			"userid":   userID,
+			"type":     actionType,
+			"roleid":   roleID,
+			"active":   true,
+			"attempts": bson.M{"$in": []interface{}{0, nil}},
+		},
+		bson.M{"$set": bson.M{
+			"active":    false,
+			"endedat":   time.Now(),
+			"endreason": models.ModActionEndReasonReverted,
+		}},
+	)
+	return err
+}
+
+// MigrateMachineryUnmutes moves unmute tasks scheduled with machinery to the mod actions, returns the number of moved tasks
+func MigrateMachineryUnmutes() (migrated int, err error) {
+	unmutes, err := getMachineryUnmutes()
+	if err != nil {
+		return 0, err
+	}
+
+	for _, unmute := range unmutes {
+		_, err = CreateModAction(models.ModActionEntry{
+			GuildID:   unmute.GuildID,
+			UserID:    unmute.UserID,
+			Type:      models.ModActionTypeMute,
+			ExpiresAt: unmute.ETA,
+		})
+		if err != nil {
+			return migrated, err
+		}
+
+		err = cache.GetMachineryRedisClient().ZRem("delayed_tasks", unmute.TaskJson).Err()
+		if err != nil {
+			return migrated, err
+		}
+		migrated++
+	}
+
+	return migrated, nil
+}
+
+func getMachineryUnmutes() (unmutes []machineryUnmute, err error) {
+	key := "delayed_tasks"
+	delayedTasks, err := cache.GetMachineryRedisClient().ZCard(key).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	tasksJson, err := cache.GetMachineryRedisClient().ZRange(key, 0, delayedTasks).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	// malformed tasks are left in machinery, so they do not keep the other unmutes from being migrated
+	for _, taskJson := range tasksJson {
+		task, err := gabs.ParseJSON([]byte(taskJson))
+		if err != nil {
+			cache.GetLogger().WithField("module", "helpers/modactions").Warnf(
+				"skipping machinery task, unable to parse it: %s", err.Error(),
+			)
+			continue
+		}
+
+		if name, _ := task.Path("Name").Data().(string); name != "unmute_user" {
+			continue
+		}
+
+		unmute := machineryUnmute{TaskJson: taskJson}
+		unmute.GuildID, _ = task.Path("Args").Index(0).Path("Value").Data().(string)
+		unmute.UserID, _ = task.Path("Args").Index(1).Path("Value").Data().(string)
+		if unmute.GuildID == "" || unmute.UserID == "" {
+			cache.GetLogger().WithField("module", "helpers/modactions").Warnf(
+				"skipping machinery unmute task without guild or user: %s", taskJson,
+			)
+			continue
+		}
+
+		etaString, _ := task.Path("ETA").Data().(string)
+		unmute.ETA, err = time.Parse(time.RFC3339, etaString)
+		if err != nil {
+			cache.GetLogger().WithField("module", "helpers/modactions").Warnf(
+				"skipping machinery unmute task for user #%s on guild #%s, invalid ETA %s",
+				unmute.UserID, unmute.GuildID, etaString,
+			)
+			continue
+		}
+
+		unmutes = append(unmutes, unmute)
+	}
+
+	return unmutes, nil
+}
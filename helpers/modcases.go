@@ -0,0 +1,112 @@
+package helpers
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/Seklfreak/Robyul2/models"
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+)
+
+// CreateModCase stores a moderation case with the next case ID of the guild
+func CreateModCase(modCase models.ModCaseEntry) (models.ModCaseEntry, error) {
+	if modCase.GuildID == "" || modCase.UserID == "" || modCase.Type == "" {
+		return modCase, errors.New("invalid mod case")
+	}
+
+	var counter models.ModCaseCounterEntry
+	_, err := MdbCollection(models.ModCaseCountersTable).FindId(modCase.GuildID).Apply(mgo.Change{
+		Update:    bson.M{"$inc": bson.M{"lastid": 1}},
+		Upsert:    true,
+		ReturnNew: true,
+	}, &counter)
+	if err != nil {
+		return modCase, err
+	}
+
+	modCase.ID = ""
+	modCase.CaseID = counter.LastID
+	if modCase.CreatedAt.IsZero() {
+		modCase.CreatedAt = time.Now()
+	}
+	modCase.UpdatedAt = modCase.CreatedAt
+
+	modCase.ID, err = MDbInsert(models.ModCasesTable, modCase)
+	return modCase, err
+}
+
+// GetModCase returns a case of a guild by its case ID
+func GetModCase(guildID string, caseID int) (modCase models.ModCaseEntry, err error) {
+	err = MdbOne(
+		MdbCollection(models.ModCasesTable).Find(bson.M{"guildid": guildID, "caseid": caseID}),
+		&modCase,
+	)
+	return modCase, err
+}
+
+// GetModCases returns the cases of a guild, newest first, userID and caseType are optional filters
+// skip and limit are used for pagination, a limit of 0 returns all cases
+func GetModCases(guildID, userID string, caseType models.ModCaseType, skip, limit int) (modCases []models.ModCaseEntry, count int, err error) {
+	query := MdbCollection(models.ModCasesTable).Find(getModCasesQuery(guildID, userID, caseType))
+
+	count, err = query.Count()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	err = MDbIter(query.Sort("-caseid").Skip(skip).Limit(limit)).All(&modCases)
+	return modCases, count, err
+}
+
+// CountModCases returns the number of cases of a type for a member
+func CountModCases(guildID, userID string, caseType models.ModCaseType) (count int, err error) {
+	return MdbCollection(models.ModCasesTable).Find(getModCasesQuery(guildID, userID, caseType)).Count()
+}
+
+// UpdateModCaseReason changes the reason of a case, and logs the change to the eventlog
+func UpdateModCaseReason(modCase models.ModCaseEntry, reason, userID string) (err error) {
+	err = MDbUpdate(models.ModCasesTable, modCase.ID, bson.M{"$set": bson.M{
+		"reason":    reason,
+		"updatedat": time.Now(),
+	}})
+	if err != nil {
+		return err
+	}
+
+	_, err = EventlogLog(time.Now(), modCase.GuildID, strconv.Itoa(modCase.CaseID),
+		models.EventlogTargetTypeRobyulModCase, userID,
+		models.EventlogTypeRobyulModCaseUpdate, "",
+		[]models.ElasticEventlogChange{
+			{
+				Key:      "modcase_reason",
+				OldValue: modCase.Reason,
+				NewValue: reason,
+			},
+		},
+		[]models.ElasticEventlogOption{
+			{
+				Key:   "modcase_type",
+				Value: string(modCase.Type),
+			},
+			{
+				Key:   "modcase_userid",
+				Value: modCase.UserID,
+				Type:  models.EventlogTargetTypeUser,
+			},
+		}, false)
+	RelaxLog(err)
+	return nil
+}
+
+func getModCasesQuery(guildID, userID string, caseType models.ModCaseType) bson.M {
+	query := bson.M{"guildid": guildID}
+	if userID != "" {
+		query["userid"] = userID
+	}
+	if caseType != "" {
+		query["type"] = caseType
+	}
+	return query
+}
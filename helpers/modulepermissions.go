@@ -73,6 +73,7 @@ const (
 	ModulePermEventlog  // eventlog/
 	ModulePermCrypto    // crypto.go
 	ModulePermImgur     // imgur.go
+	ModulePermFeeds     // feeds/
 
 	ModulePermAll = ModulePermStats | ModulePermTranslator | ModulePermUrban | ModulePermWeather | ModulePermVLive |
 		ModulePermInstagram | ModulePermFacebook | ModulePermWolframAlpha | ModulePermLastFm | ModulePermTwitter |
@@ -84,7 +85,8 @@ const (
 		ModulePermAutoRole | ModulePermBias | ModulePermDiscordmoney | ModulePermGallery |
 		ModulePermGuildAnnouncements | ModulePermMirror | ModulePermMirror | ModulePermMod | ModulePermNotifications |
 		ModulePermNuke | ModulePermPersistency | ModulePermPing | ModulePermTroublemaker | ModulePermVanityInvite |
-		ModulePerm8ball | ModulePermFeedback | ModulePermEmbedPost | ModulePermEventlog | ModulePermCrypto | ModulePermImgur
+		ModulePerm8ball | ModulePermFeedback | ModulePermEmbedPost | ModulePermEventlog | ModulePermCrypto | ModulePermImgur |
+		ModulePermFeeds
 )
 
 var (
@@ -143,6 +145,7 @@ var (
 		{Names: []string{"eventlog"}, Permission: ModulePermEventlog},
 		{Names: []string{"crypto"}, Permission: ModulePermCrypto},
 		{Names: []string{"imgur"}, Permission: ModulePermImgur},
+		{Names: []string{"feeds"}, Permission: ModulePermFeeds},
 	}
 )
 
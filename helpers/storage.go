@@ -1,15 +1,10 @@
 package helpers
 
 import (
-	"sync"
-
 	"bytes"
-
-	"io/ioutil"
-
-	"os"
-
-	"path/filepath"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
 
 	"errors"
 
@@ -21,25 +16,36 @@ import (
 
 	"strconv"
 
-	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"os"
 
 	"mime"
 
 	"github.com/Seklfreak/Robyul2/cache"
 	"github.com/Seklfreak/Robyul2/models"
+	"github.com/Seklfreak/Robyul2/objectstorage"
+	"github.com/globalsign/mgo"
 	"github.com/globalsign/mgo/bson"
 	"github.com/kennygrant/sanitize"
-	minio "github.com/minio/minio-go"
 	uuid "github.com/satori/go.uuid"
 )
 
-var (
-	minioBucket string
-	minioClient *minio.Client
-	minioLock   sync.Mutex
+const (
+	// defaultStorageCacheSize is the size of the cache folder if storage.cache_size_mb is not set
+	defaultStorageCacheSize = 2048
+	// storageBlobGracePeriod is the time content stays stored after it isn't referenced anymore
+	storageBlobGracePeriod = 30 * time.Minute
+	storageSweepInterval   = 10 * time.Minute
+	// storageSweepRetries is the number of times adding a reference waits for the sweeper to finish deleting the content
+	storageSweepRetries = 10
 )
 
-// TODO: watch cache folder size
+var (
+	storageBackend objectstorage.Backend
+	storageCache   *objectstorage.Cache
+	storageLock    sync.Mutex
+)
 
 type AddFileMetadata struct {
 	Filename           string            // the actual file name, can be empty
@@ -49,14 +55,50 @@ type AddFileMetadata struct {
 	AdditionalMetadata map[string]string // additional metadata attached to the object
 }
 
-// TODO: prevent duplicates
-// Stores a file
+// Stores a file, the content is stored once for all files with the same content
 // name		: the name of the new object, can be empty to generate an unique name
 // data		: the file data
 // metadata	: metadata attached to the object
 // source	: the source name for the file, for example the module name, can not be empty
 // public	: if true file will be available via the website proxy
 func AddFile(name string, data []byte, metadata AddFileMetadata, source string, public bool) (objectName string, err error) {
+	// get filetype
+	filetype, _ := SniffMime(data)
+	return addFile(name, bytes.NewReader(data), int64(len(data)), getContentHash(data), filetype, metadata, source, public)
+}
+
+// Stores a file read from reader, the content is buffered in a temporary file instead of memory, see AddFile
+// name		: the name of the new object, can be empty to generate an unique name
+// reader	: the file data, read until EOF, an error returned by the reader aborts the upload
+// metadata	: metadata attached to the object
+// source	: the source name for the file, for example the module name, can not be empty
+// public	: if true file will be available via the website proxy
+func AddFileFromReader(name string, reader io.Reader, metadata AddFileMetadata, source string, public bool) (objectName string, err error) {
+	file, err := ioutil.TempFile("", "robyul-upload-")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	hasher := sha256.New()
+	size, err := io.Copy(io.MultiWriter(file, hasher), reader)
+	if err != nil {
+		return "", err
+	}
+
+	// get filetype from the first 512 bytes, like http.DetectContentType
+	head := make([]byte, 512)
+	n, err := file.ReadAt(head, 0)
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	filetype, _ := SniffMime(head[:n])
+
+	return addFile(name, file, size, hex.EncodeToString(hasher.Sum(nil)), filetype, metadata, source, public)
+}
+
+func addFile(name string, content io.ReadSeeker, filesize int64, contentHash, filetype string, metadata AddFileMetadata, source string, public bool) (objectName string, err error) {
 	// check if source is set
 	if source == "" {
 		return "", errors.New("source can not be empty")
@@ -81,10 +123,6 @@ func AddFile(name string, data []byte, metadata AddFileMetadata, source string,
 			guildID = channel.GuildID
 		}
 	}
-	// get filetype
-	filetype, _ := SniffMime(data)
-	// get filesize
-	filesize := binary.Size(data)
 	// update metadata
 	if metadata.AdditionalMetadata == nil {
 		metadata.AdditionalMetadata = make(map[string]string, 0)
@@ -95,16 +133,21 @@ func AddFile(name string, data []byte, metadata AddFileMetadata, source string,
 	metadata.AdditionalMetadata["channelid"] = metadata.ChannelID
 	metadata.AdditionalMetadata["source"] = source
 	metadata.AdditionalMetadata["mimetype"] = filetype
-	metadata.AdditionalMetadata["filesize"] = strconv.Itoa(filesize)
+	metadata.AdditionalMetadata["filesize"] = strconv.FormatInt(filesize, 10)
 	metadata.AdditionalMetadata["public"] = "no"
 	if public {
 		metadata.AdditionalMetadata["public"] = "yes"
 	}
-	// upload file
-	err = uploadFile(objectName, data, metadata.AdditionalMetadata)
+	// store content, if it isn't stored yet
+	err = addStorageBlobReference(contentHash, content, filesize, filetype)
 	if err != nil {
 		return "", err
 	}
+	// a replaced object doesn't reference its old content anymore
+	var previousInfo models.StorageEntry
+	if name != "" {
+		previousInfo, _ = RetrieveFileInformation(objectName)
+	}
 	// store in database
 	err = MDbUpsert(
 		models.StorageTable,
@@ -119,14 +162,19 @@ func AddFile(name string, data []byte, metadata AddFileMetadata, source string,
 			ChannelID:      metadata.ChannelID,
 			Source:         source,
 			MimeType:       filetype,
-			Filesize:       filesize,
+			Filesize:       int(filesize),
 			Public:         public,
 			Metadata:       metadata.AdditionalMetadata,
+			ContentHash:    contentHash,
 		},
 	)
 	if err != nil {
+		RelaxLog(releaseStorageBlobReference(contentHash))
 		return "", err
 	}
+	if previousInfo.ContentHash != "" {
+		RelaxLog(releaseStorageBlobReference(previousInfo.ContentHash))
+	}
 	// warm up cache for public files
 	if public {
 		go func() {
@@ -142,8 +190,8 @@ func AddFile(name string, data []byte, metadata AddFileMetadata, source string,
 		}()
 	}
 	cache.GetLogger().WithField("module", "storage").Infof(
-		"stored #%s for %s (%+v)",
-		objectName, source, metadata,
+		"stored #%s (%s) for %s (%+v)",
+		objectName, contentHash, source, metadata,
 	)
 	// return new objectName
 	return objectName, nil
@@ -162,72 +210,19 @@ func RetrieveFileInformation(objectName string) (info models.StorageEntry, err e
 // retrieves a file
 // objectName	: the name of the file to retrieve
 func RetrieveFile(objectName string) (data []byte, err error) {
-	// setup minioClient if not yet done
-	if minioClient == nil {
-		err = setupMinioClient()
-		if err != nil {
-			return data, err
-		}
-	}
-
-	// Increase MongoDB RetrievedCount
-	go func() {
-		defer Recover()
-		err := MDbUpdateQueryWithoutLogging(models.StorageTable, bson.M{"objectname": objectName}, bson.M{"$inc": bson.M{"retrievedcount": 1}})
-		if err != nil && !IsMdbNotFound(err) {
-			RelaxLog(err)
-		}
-	}()
-
-	data = getBucketCache(objectName)
-	if data != nil {
-		cache.GetLogger().WithField("module", "storage").Infof("retrieving " + objectName + " from minio cache")
-		return data, nil
-	}
-
-	cache.GetLogger().WithField("module", "storage").Infof("retrieving " + objectName + " from minio storage")
-
-	// retrieve the object
-	minioObject, err := minioClient.GetObject(minioBucket, sanitize.BaseName(objectName), minio.GetObjectOptions{})
-	if err != nil {
-		if strings.Contains(err.Error(), "Please reduce your request rate.") {
-			cache.GetLogger().WithField("module", "storage").Infof("object storage ratelimited, waiting for one second, then retrying")
-			time.Sleep(1 * time.Second)
-			return RetrieveFile(objectName)
-		}
-		if strings.Contains(err.Error(), "net/http") || strings.Contains(err.Error(), "timeout") {
-			cache.GetLogger().WithField("module", "storage").Infof("network error retrieving, waiting for one second, then retrying")
-			time.Sleep(1 * time.Second)
-			return RetrieveFile(objectName)
-		}
-		return data, err
-	}
-
-	// read the object into a byte slice
-	data, err = ioutil.ReadAll(minioObject)
-	if err != nil {
-		return data, err
-	}
-
-	go func() {
-		defer Recover()
-		cache.GetLogger().WithField("module", "storage").Infof("caching " + objectName + " into minio cache")
-		err := setBucketCache(objectName, data)
-		RelaxLog(err)
-	}()
-
-	return data, nil
+	return retrieveFile(objectName, true)
 }
 
 // retrieves a file without logging
 // objectName	: the name of the file to retrieve
 func RetrieveFileWithoutLogging(objectName string) (data []byte, err error) {
-	// setup minioClient if not yet done
-	if minioClient == nil {
-		err = setupMinioClient()
-		if err != nil {
-			return data, err
-		}
+	return retrieveFile(objectName, false)
+}
+
+func retrieveFile(objectName string, logging bool) (data []byte, err error) {
+	backend, objectCache, err := getStorage()
+	if err != nil {
+		return data, err
 	}
 
 	// Increase MongoDB RetrievedCount
@@ -239,36 +234,31 @@ func RetrieveFileWithoutLogging(objectName string) (data []byte, err error) {
 		}
 	}()
 
-	data = getBucketCache(objectName)
+	key := getStorageKey(objectName)
+
+	data = objectCache.Get(key)
 	if data != nil {
+		if logging {
+			cache.GetLogger().WithField("module", "storage").Infof("retrieving " + objectName + " from storage cache")
+		}
 		return data, nil
 	}
 
-	// retrieve the object
-	minioObject, err := minioClient.GetObject(minioBucket, sanitize.BaseName(objectName), minio.GetObjectOptions{})
-	if err != nil {
-		if strings.Contains(err.Error(), "Please reduce your request rate.") {
-			cache.GetLogger().WithField("module", "storage").Infof("object storage ratelimited, waiting for one second, then retrying")
-			time.Sleep(1 * time.Second)
-			return RetrieveFileWithoutLogging(objectName)
-		}
-		if strings.Contains(err.Error(), "net/http") || strings.Contains(err.Error(), "timeout") {
-			cache.GetLogger().WithField("module", "storage").Infof("network error retrieving, waiting for one second, then retrying")
-			time.Sleep(1 * time.Second)
-			return RetrieveFileWithoutLogging(objectName)
-		}
-		return data, err
+	if logging {
+		cache.GetLogger().WithField("module", "storage").Infof("retrieving " + objectName + " from storage")
 	}
 
-	// read the object into a byte slice
-	data, err = ioutil.ReadAll(minioObject)
+	data, err = backend.Get(key)
 	if err != nil {
 		return data, err
 	}
 
 	go func() {
 		defer Recover()
-		err := setBucketCache(objectName, data)
+		if logging {
+			cache.GetLogger().WithField("module", "storage").Infof("caching " + objectName + " into storage cache")
+		}
+		err := objectCache.Set(key, data)
 		RelaxLog(err)
 	}()
 
@@ -363,39 +353,35 @@ func GetFileLink(objectName string) (url string, err error) {
 	return url, nil
 }
 
-// Deletes a file
+// Deletes a file, the content is deleted once no other file uses it
 // objectName	: the name of the object
 func DeleteFile(objectName string) (err error) {
-	// setup minioClient if not yet done
-	if minioClient == nil {
-		err = setupMinioClient()
-		if err != nil {
-			return err
-		}
+	backend, objectCache, err := getStorage()
+	if err != nil {
+		return err
 	}
 
-	cache.GetLogger().WithField("module", "storage").Infof("deleting " + objectName + " from minio storage")
-
-	go func() {
-		defer Recover()
-		cache.GetLogger().WithField("module", "storage").Infof("deleting " + objectName + " from minio cache")
-		err = deleteBucketCache(objectName)
-		RelaxLog(err)
-	}()
+	info, err := RetrieveFileInformation(objectName)
+	if err != nil && !IsMdbNotFound(err) {
+		return err
+	}
 
-	// delete the object
-	err = minioClient.RemoveObject(minioBucket, sanitize.BaseName(objectName))
+	cache.GetLogger().WithField("module", "storage").Infof("deleting " + objectName + " from storage")
 
 	// delete mongo db entry
-	go func() {
-		defer Recover()
-		err := MdbDeleteQuery(models.StorageTable, bson.M{"objectname": objectName})
-		if err != nil && !IsMdbNotFound(err) {
-			RelaxLog(err)
-		}
-	}()
+	err = MdbDeleteQuery(models.StorageTable, bson.M{"objectname": objectName})
+	if err != nil && !IsMdbNotFound(err) {
+		return err
+	}
 
-	return err
+	if info.ContentHash != "" {
+		return releaseStorageBlobReference(info.ContentHash)
+	}
+
+	// objects stored before deduplication don't share their content
+	key := sanitize.BaseName(objectName)
+	RelaxLog(objectCache.Delete(key))
+	return backend.Delete(key)
 }
 
 // Gets a public link for a file
@@ -410,142 +396,248 @@ func GeneratePublicFileLink(filename, filehash string) (link string) {
 		filehash, filename)
 }
 
-// Checks if an object exists by checking cache for a file or requested metadata from minio
+// Checks if an object exists by checking the cache or the storage backend for its content
 // objectName	: the name of the file to retrieve
 func ObjectExists(objectName string) bool {
-
-	// setup minioClient if not yet done
-	if minioClient == nil {
-		err := setupMinioClient()
-		if err != nil {
-			return false
-		}
+	backend, objectCache, err := getStorage()
+	if err != nil {
+		return false
 	}
 
-	data := getBucketCache(objectName)
-	if data != nil {
+	key := getStorageKey(objectName)
+	if objectCache.Has(key) {
 		return true
 	}
 
-	// retrieve the object
-	minioStatObject, err := minioClient.StatObject(minioBucket, sanitize.BaseName(objectName), minio.StatObjectOptions{})
+	exists, err := backend.Exists(key)
+	return err == nil && exists
+}
+
+// increases the references of stored content, and stores it if it isn't stored yet
+// contentHash	: the hash of the content, see getContentHash
+// content		: the content
+// size			: the size of the content
+// mimeType		: the type of the content
+func addStorageBlobReference(contentHash string, content io.ReadSeeker, size int64, mimeType string) (err error) {
+	backend, _, err := getStorage()
 	if err != nil {
-		if strings.Contains(err.Error(), "Please reduce your request rate.") {
-			cache.GetLogger().WithField("module", "storage").Infof("object storage ratelimited, waiting for one second, then retrying")
-			time.Sleep(1 * time.Second)
-			return ObjectExists(objectName)
-		}
-		if strings.Contains(err.Error(), "net/http") || strings.Contains(err.Error(), "timeout") {
-			cache.GetLogger().WithField("module", "storage").Infof("network error retrieving, waiting for one second, then retrying")
-			time.Sleep(1 * time.Second)
-			return ObjectExists(objectName)
+		return err
+	}
+
+	var info *mgo.ChangeInfo
+	for i := 0; ; i++ {
+		info, err = MdbCollection(models.StorageBlobsTable).Upsert(
+			bson.M{"contenthash": contentHash, "sweeping": bson.M{"$ne": true}},
+			bson.M{
+				"$inc":   bson.M{"references": 1},
+				"$unset": bson.M{"deletedat": ""},
+				"$setOnInsert": bson.M{
+					"mimetype":  mimeType,
+					"filesize":  size,
+					"createdat": time.Now(),
+				},
+			},
+		)
+		// the sweeper is deleting the content, the unique index prevents inserting it again until the sweeper is done
+		if mgo.IsDup(err) && i < storageSweepRetries {
+			time.Sleep(time.Second)
+			continue
 		}
-		return false
+		break
+	}
+	if err != nil {
+		return err
 	}
 
-	// check if the returned object is nil
-	if minioStatObject.Size == 0 {
-		return false
+	// new blobs are always stored, the sweeper might have just deleted the content of a previous blob with the same hash
+	// for existing blobs, checking the backend instead of the references also repairs content of failed uploads
+	exists := false
+	if info.UpsertedId == nil {
+		exists, err = backend.Exists(contentHash)
+	}
+	if err == nil && !exists {
+		err = backend.PutReader(contentHash, content, size, mimeType)
+	}
+	if err != nil {
+		RelaxLog(releaseStorageBlobReference(contentHash))
+		return err
 	}
 
-	return true
+	return nil
 }
 
-// TODO: prevent overwrites
-// uploads a file to the minio object storage
-// objectName	: the name of the file to upload
-// data			: the data for the new object
-// metadata		: additional metadata attached to the object
-func uploadFile(objectName string, data []byte, metadata map[string]string) (err error) {
-	// setup minioClient if not yet done
-	if minioClient == nil {
-		err = setupMinioClient()
-		if err != nil {
-			return err
+// decreases the references of stored content, and marks it as deleted once it isn't referenced anymore
+// the content is deleted by the storage sweeper, if it isn't referenced again within storageBlobGracePeriod
+// contentHash	: the hash of the content, see getContentHash
+func releaseStorageBlobReference(contentHash string) (err error) {
+	var blob models.StorageBlobEntry
+	_, err = MdbCollection(models.StorageBlobsTable).Find(bson.M{"contenthash": contentHash}).Apply(
+		mgo.Change{Update: bson.M{"$inc": bson.M{"references": -1}}, ReturnNew: true},
+		&blob,
+	)
+	if err != nil {
+		if IsMdbNotFound(err) {
+			return nil
 		}
+		return err
 	}
 
-	options := minio.PutObjectOptions{}
-
-	// add content type
-	filetype, err := SniffMime(data)
-	if err == nil {
-		options.ContentType = filetype
+	if blob.References > 0 {
+		return nil
 	}
 
-	// add metadata
-	if metadata != nil && len(metadata) > 0 {
-		options.UserMetadata = metadata
+	// the content might have been referenced again in the meantime
+	err = MdbCollection(models.StorageBlobsTable).Update(
+		bson.M{"_id": blob.ID, "references": bson.M{"$lte": 0}},
+		bson.M{"$set": bson.M{"deletedat": time.Now()}},
+	)
+	if IsMdbNotFound(err) {
+		return nil
 	}
-
-	// upload the data
-	_, err = minioClient.PutObject(minioBucket, sanitize.BaseName(objectName), bytes.NewReader(data), -1, options)
 	return err
 }
 
-func getBucketCache(objectName string) (data []byte) {
-	var err error
+// Deletes the content which hasn't been referenced for storageBlobGracePeriod from storage, every storageSweepInterval
+func StorageSweeperLoop() {
+	defer Recover()
+	defer func() {
+		go func() {
+			cache.GetLogger().WithField("module", "storage").Error(
+				"The StorageSweeperLoop died. Please investigate! Will be restarted in 60 seconds")
+			time.Sleep(60 * time.Second)
+			StorageSweeperLoop()
+		}()
+	}()
 
-	if _, err = os.Stat(getObjectPath(objectName)); os.IsNotExist(err) {
-		return nil
+	for {
+		time.Sleep(storageSweepInterval)
+
+		swept, err := sweepStorageBlobs()
+		RelaxLog(err)
+		if swept > 0 {
+			cache.GetLogger().WithField("module", "storage").Infof("deleted %d unreferenced contents from storage", swept)
+		}
+	}
+}
+
+// deletes the content of blobs which are unreferenced since storageBlobGracePeriod
+// a blob is marked as sweeping before its content is deleted, so it can't be referenced again until its entry is removed,
+// blobs left sweeping by crashed instances are swept again
+func sweepStorageBlobs() (swept int, err error) {
+	backend, objectCache, err := getStorage()
+	if err != nil {
+		return 0, err
 	}
 
-	data, err = ioutil.ReadFile(getObjectPath(objectName))
+	sweepable := []bson.M{
+		{"sweeping": true},
+		{"references": bson.M{"$lte": 0}, "deletedat": bson.M{"$lte": time.Now().Add(-storageBlobGracePeriod)}},
+	}
+
+	var blobs []models.StorageBlobEntry
+	err = MDbIterWithoutLogging(MdbCollection(models.StorageBlobsTable).Find(bson.M{"$or": sweepable})).All(&blobs)
 	if err != nil {
-		return nil
+		return 0, err
 	}
 
-	return data
-}
+	for _, blob := range blobs {
+		err = MdbCollection(models.StorageBlobsTable).Update(
+			bson.M{"_id": blob.ID, "$or": sweepable},
+			bson.M{"$set": bson.M{"sweeping": true}},
+		)
+		if IsMdbNotFound(err) {
+			// referenced again in the meantime
+			continue
+		}
+		if err != nil {
+			return swept, err
+		}
 
-func setBucketCache(objectName string, data []byte) (err error) {
-	if _, err = os.Stat(filepath.Dir(getObjectPath(objectName))); os.IsNotExist(err) {
-		err = os.MkdirAll(filepath.Dir(getObjectPath(objectName)), os.ModePerm)
+		RelaxLog(objectCache.Delete(blob.ContentHash))
+		err = backend.Delete(blob.ContentHash)
 		if err != nil {
-			return err
+			return swept, err
 		}
+
+		err = MdbCollection(models.StorageBlobsTable).RemoveId(blob.ID)
+		if err != nil && !IsMdbNotFound(err) {
+			return swept, err
+		}
+		swept++
 	}
 
-	err = ioutil.WriteFile(getObjectPath(objectName), data, 0644)
-	return err
+	return swept, nil
 }
 
-func deleteBucketCache(objectName string) (err error) {
-	if _, err = os.Stat(getObjectPath(objectName)); os.IsNotExist(err) {
-		return nil
+// returns the key of an object in the storage backend, objects stored before deduplication use their object name
+// objectName	: the name of the object
+func getStorageKey(objectName string) (key string) {
+	info, err := RetrieveFileInformation(objectName)
+	if err == nil && info.ContentHash != "" {
+		return info.ContentHash
 	}
 
-	err = os.Remove(getObjectPath(objectName))
-	return err
+	return sanitize.BaseName(objectName)
 }
 
-func getObjectPath(objectName string) (path string) {
-	return GetConfig().Path("cache_folder").Data().(string) + "/minio-" + GetConfig().Path("s3.bucket").Data().(string) + "/" + sanitize.BaseName(objectName)
+func getContentHash(data []byte) (hash string) {
+	hasher := sha256.New()
+	hasher.Write(data)
+	return hex.EncodeToString(hasher.Sum(nil))
 }
 
-// Initialize the minio client object, and creates the bucket if it doesn't exist yet
-func setupMinioClient() (err error) {
-	minioLock.Lock()
-	minioBucket = GetConfig().Path("s3.bucket").Data().(string)
-	minioClient, err = minio.New(
-		GetConfig().Path("s3.endpoint").Data().(string),
-		GetConfig().Path("s3.access_key").Data().(string),
-		GetConfig().Path("s3.secret_secret_key").Data().(string),
-		true,
-	)
-	minioLock.Unlock()
+// Initializes the storage backend set in storage.backend and the cache, on first use
+// s3 (default)	: stores objects in the s3 bucket, cached in the cache folder up to storage.cache_size_mb
+// local		: stores objects in storage.local_folder, without cache
+func getStorage() (backend objectstorage.Backend, objectCache *objectstorage.Cache, err error) {
+	storageLock.Lock()
+	defer storageLock.Unlock()
 
-	bucketExists, err := minioClient.BucketExists(minioBucket)
-	if err != nil {
-		return err
+	if storageBackend != nil {
+		return storageBackend, storageCache, nil
+	}
+
+	config := GetConfig()
+
+	backendName := "s3"
+	if config.ExistsP("storage.backend") && config.Path("storage.backend").Data().(string) != "" {
+		backendName = config.Path("storage.backend").Data().(string)
 	}
 
-	if !bucketExists {
-		err = minioClient.MakeBucket(minioBucket, "ams3")
+	switch backendName {
+	case "s3":
+		bucket := config.Path("s3.bucket").Data().(string)
+		backend, err = objectstorage.NewMinioBackend(
+			config.Path("s3.endpoint").Data().(string),
+			config.Path("s3.access_key").Data().(string),
+			config.Path("s3.secret_secret_key").Data().(string),
+			bucket,
+		)
 		if err != nil {
-			return err
+			return nil, nil, err
 		}
+
+		cacheSize := float64(defaultStorageCacheSize)
+		if value, ok := config.Path("storage.cache_size_mb").Data().(float64); ok {
+			cacheSize = value
+		}
+		objectCache, err = objectstorage.NewCache(
+			config.Path("cache_folder").Data().(string)+"/minio-"+bucket,
+			int64(cacheSize*1024*1024),
+		)
+		if err != nil {
+			return nil, nil, err
+		}
+	case "local":
+		backend, err = objectstorage.NewLocalBackend(config.Path("storage.local_folder").Data().(string))
+		if err != nil {
+			return nil, nil, err
+		}
+	default:
+		return nil, nil, fmt.Errorf("unknown storage backend %s", backendName)
 	}
 
-	return err
+	storageBackend = backend
+	storageCache = objectCache
+	return storageBackend, storageCache, nil
 }
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"io/ioutil"
 	"math/rand"
@@ -42,12 +43,17 @@ import (
 
 var (
 	BotRuntimeChannel chan os.Signal
+
+	migrationsCommand = flag.String("migrations", "",
+		"runs a migrations command and exits: list, up, down (rolls back the last migration) or down:<version>")
 )
 
 // Entrypoint
 func main() {
 	var err error
 
+	flag.Parse()
+
 	log := logrus.New()
 	log.Out = os.Stdout
 	log.Level = logrus.DebugLevel
@@ -169,6 +175,13 @@ func main() {
 	}
 
 	// Run migrations
+	if *migrationsCommand != "" {
+		err = migrations.Command(*migrationsCommand)
+		if err != nil {
+			log.WithField("module", "launcher").Fatal("migrations failed: ", err.Error())
+		}
+		return
+	}
 	migrations.Run()
 
 	// stop after migrations?
@@ -349,12 +362,13 @@ func main() {
 			"http://robyul-web.local:8000",
 		},
 		AllowedHeaders: []string{"Content-Type", "Accept", "Origin", "X-CSRF-Token", "Authorization"},
-		AllowedMethods: []string{"GET", "POST"},
+		AllowedMethods: []string{"GET", "POST", "DELETE"},
 		MaxAge:         1000,
 		Container:      wsContainer,
 	}
 	wsContainer.Filter(cors.Filter)
 	wsContainer.Filter(wsContainer.OPTIONSFilter)
+	wsContainer.ServiceErrorHandler(rest.WriteServiceError)
 
 	for _, service := range rest.NewRestServices() {
 		wsContainer.Add(service)
@@ -419,6 +433,9 @@ func main() {
 	// start proxies healthcheck loop
 	go helpers.CachedProxiesHealthcheckLoop()
 
+	// start storage sweeper loop
+	go helpers.StorageSweeperLoop()
+
 	// Make a channel that waits for a os signal
 	BotRuntimeChannel = make(chan os.Signal, 1)
 	signal.Notify(BotRuntimeChannel, os.Interrupt, os.Kill)
@@ -47,24 +47,15 @@ var (
 	// VLiveRequests increases after each request to vlive.tv
 	VLiveRequests = expvar.NewInt("vlive_requests")
 
-	// VliveRefreshTime is the latest refresh time
-	VliveRefreshTime = expvar.NewFloat("vlive_refresh_time")
-
 	// TwitterAccountsCount counts all connected twitter accounts
 	TwitterAccountsCount = expvar.NewInt("twitter_accounts_count")
 
-	// TwitterRefreshTime is the latest refresh time
-	TwitterRefreshTime = expvar.NewFloat("twitter_refresh_time")
-
 	// InstagramAccountsCount counts all connected instagram accounts
 	InstagramAccountsCount = expvar.NewInt("instagram_accounts_count")
 
 	// InstagramRefreshTime is the latest Feeds and Story refresh time
 	InstagramRefreshTime = expvar.NewFloat("instagram_refresh_time")
 
-	// InstagramGraphQlRefreshTime is the latest GraphQL feed refresh time
-	InstagramGraphQlFeedRefreshTime = expvar.NewFloat("instagram_graphql_feed_refresh_time")
-
 	// FacebookPagesCount counts all connected instagram accounts
 	FacebookPagesCount = expvar.NewInt("facebook_pages_count")
 
@@ -83,6 +74,9 @@ var (
 	// KeywordNotificationsSentCount increased after every keyword notification sent
 	KeywordNotificationsSentCount = expvar.NewInt("keywordnotifications_sent_count")
 
+	// KeywordNotificationsDigestedCount increased after every keyword notification queued for a digest
+	KeywordNotificationsDigestedCount = expvar.NewInt("keywordnotifications_digested_count")
+
 	// GalleriesCount counts all galleries in the db
 	GalleriesCount = expvar.NewInt("galleries_count")
 
@@ -122,11 +116,23 @@ var (
 	// YoutubeLeftQuota counts how many left youtube quotas
 	YoutubeLeftQuota = expvar.NewInt("youtube_left_quota")
 
+	// YoutubeWebSubNotifications counts the received WebSub notifications of youtube videos
+	YoutubeWebSubNotifications = expvar.NewInt("youtube_websub_notifications")
+
 	// TwitchRefreshTime counts all connected twitch channels
 	TwitchChannelsCount = expvar.NewInt("twitch_channels_count")
 
-	// TwitchRefreshTime is the latest refresh time
-	TwitchRefreshTime = expvar.NewFloat("twitch_refresh_time")
+	// FeedTargets counts the targets of the feed pollers, by feed
+	FeedTargets = expvar.NewMap("feed_targets")
+
+	// FeedChecks counts the checks of the feed pollers, by feed
+	FeedChecks = expvar.NewMap("feed_checks")
+
+	// FeedErrors counts the failed checks of the feed pollers, by feed
+	FeedErrors = expvar.NewMap("feed_errors")
+
+	// FeedCheckSeconds is the total time spent checking, by feed
+	FeedCheckSeconds = expvar.NewMap("feed_check_seconds")
 
 	// VanityInvitesCount counts all vanity invites channels
 	VanityInvitesCount = expvar.NewInt("vanityinvites_count")
@@ -146,9 +152,21 @@ var (
 	// CloudVisionApiRequests counts all google cloud vision api requests made
 	CloudVisionApiRequests = expvar.NewInt("cloudvision_api_requests")
 
-	// LevelsStackSize is the size of the exp processing stack
+	// LevelsStackSize is the number of messages in the exp queue that have not been processed yet
 	LevelsStackSize = expvar.NewInt("levels_stack_size")
 
+	// LevelsExpQueuePending is the number of messages in the exp queue that are being processed or wait for a retry
+	LevelsExpQueuePending = expvar.NewInt("levels_exp_queue_pending")
+
+	// LevelsExpProcessed counts all messages processed from the exp queue
+	LevelsExpProcessed = expvar.NewInt("levels_exp_processed")
+
+	// LevelsExpBatches counts all batches of the exp queue written to the database
+	LevelsExpBatches = expvar.NewInt("levels_exp_batches")
+
+	// LevelsExpThroughput is the number of messages processed from the exp queue per second
+	LevelsExpThroughput = expvar.NewFloat("levels_exp_throughput")
+
 	// BiasgameImagesCount is the number of images in the biasgame
 	BiasgameImagesCount = expvar.NewInt("biasgame_images_count")
 
@@ -160,6 +178,21 @@ var (
 
 	// EventlogPendingAuditlogBackfills is the number of games completed
 	EventlogPendingAuditlogBackfills = expvar.NewInt("eventlog_pending_auditlog_backfills")
+
+	// ExtendedPluginEventsHandled counts the handled events per extended plugin
+	ExtendedPluginEventsHandled = expvar.NewMap("extended_plugin_events_handled")
+
+	// ExtendedPluginEventsDropped counts the events dropped because of a full queue per extended plugin
+	ExtendedPluginEventsDropped = expvar.NewMap("extended_plugin_events_dropped")
+
+	// ExtendedPluginErrors counts the panics while handling events per extended plugin
+	ExtendedPluginErrors = expvar.NewMap("extended_plugin_errors")
+
+	// ExtendedPluginTimeouts counts the events which took longer than the timeout per extended plugin
+	ExtendedPluginTimeouts = expvar.NewMap("extended_plugin_timeouts")
+
+	// ExtendedPluginLatencySeconds is the total time spent handling events per extended plugin
+	ExtendedPluginLatencySeconds = expvar.NewMap("extended_plugin_latency_seconds")
 )
 
 // Init starts a http server on 127.0.0.1:1337
@@ -1,5 +1,6 @@
 package migrations
 
-func m28_create_elastic_indexes() {
+func m28_create_elastic_indexes() error {
 	// moved to m45, m46, m47, m48
+	return nil
 }
@@ -1,5 +1,6 @@
 package migrations
 
-func m29_create_elastic_presence_update_index() {
+func m29_create_elastic_presence_update_index() error {
 	// move to m49
+	return nil
 }
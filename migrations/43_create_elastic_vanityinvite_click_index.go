@@ -1,5 +1,6 @@
 package migrations
 
-func m43_create_elastic_vanityinvite_click_index() {
+func m43_create_elastic_vanityinvite_click_index() error {
 	// moved to m50
+	return nil
 }
@@ -6,18 +6,14 @@ import (
 	"github.com/Seklfreak/Robyul2/cache"
 )
 
-func m45_create_elastic_index_messages() {
-	if !cache.HasElastic() {
-		return
-	}
-
+func m45_create_elastic_index_messages() error {
 	elastic := cache.GetElastic()
 	exists, err := elastic.IndexExists("robyul-messages-v2").Do(context.Background())
 	if err != nil {
-		panic(err)
+		return err
 	}
 	if exists {
-		return
+		return nil
 	}
 
 	messageMapping := map[string]interface{}{
@@ -69,9 +65,11 @@ func m45_create_elastic_index_messages() {
 
 	index, err := elastic.CreateIndex("robyul-messages-v2").BodyJson(messageMapping).Do(context.Background())
 	if err != nil {
-		panic(err)
+		return err
 	}
 	if !index.Acknowledged {
 		cache.GetLogger().WithField("module", "migrations").Error("ElasticSearch index not acknowledged")
 	}
+
+	return nil
 }
@@ -6,18 +6,14 @@ import (
 	"github.com/Seklfreak/Robyul2/cache"
 )
 
-func m46_create_elastic_index_joins() {
-	if !cache.HasElastic() {
-		return
-	}
-
+func m46_create_elastic_index_joins() error {
 	elastic := cache.GetElastic()
 	exists, err := elastic.IndexExists("robyul-joins").Do(context.Background())
 	if err != nil {
-		panic(err)
+		return err
 	}
 	if exists {
-		return
+		return nil
 	}
 
 	joinMapping := map[string]interface{}{
@@ -46,9 +42,11 @@ func m46_create_elastic_index_joins() {
 
 	index, err := elastic.CreateIndex("robyul-joins").BodyJson(joinMapping).Do(context.Background())
 	if err != nil {
-		panic(err)
+		return err
 	}
 	if !index.Acknowledged {
 		cache.GetLogger().WithField("module", "migrations").Error("ElasticSearch index not acknowledged")
 	}
+
+	return nil
 }
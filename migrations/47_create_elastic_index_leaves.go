@@ -6,18 +6,14 @@ import (
 	"github.com/Seklfreak/Robyul2/cache"
 )
 
-func m47_create_elastic_index_leaves() {
-	if !cache.HasElastic() {
-		return
-	}
-
+func m47_create_elastic_index_leaves() error {
 	elastic := cache.GetElastic()
 	exists, err := elastic.IndexExists("robyul-leaves").Do(context.Background())
 	if err != nil {
-		panic(err)
+		return err
 	}
 	if exists {
-		return
+		return nil
 	}
 
 	leaveMapping := map[string]interface{}{
@@ -40,9 +36,11 @@ func m47_create_elastic_index_leaves() {
 
 	index, err := elastic.CreateIndex("robyul-leaves").BodyJson(leaveMapping).Do(context.Background())
 	if err != nil {
-		panic(err)
+		return err
 	}
 	if !index.Acknowledged {
 		cache.GetLogger().WithField("module", "migrations").Error("ElasticSearch index not acknowledged")
 	}
+
+	return nil
 }
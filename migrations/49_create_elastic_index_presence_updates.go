@@ -6,18 +6,14 @@ import (
 	"github.com/Seklfreak/Robyul2/cache"
 )
 
-func m49_create_elastic_index_presence_updates() {
-	if !cache.HasElastic() {
-		return
-	}
-
+func m49_create_elastic_index_presence_updates() error {
 	elastic := cache.GetElastic()
 	exists, err := elastic.IndexExists("robyul-presence_updates").Do(context.Background())
 	if err != nil {
-		panic(err)
+		return err
 	}
 	if exists {
-		return
+		return nil
 	}
 
 	presenceUpdateMapping := map[string]interface{}{
@@ -52,9 +48,11 @@ func m49_create_elastic_index_presence_updates() {
 
 	index, err := elastic.CreateIndex("robyul-presence_updates").BodyJson(presenceUpdateMapping).Do(context.Background())
 	if err != nil {
-		panic(err)
+		return err
 	}
 	if !index.Acknowledged {
 		cache.GetLogger().WithField("module", "migrations").Error("ElasticSearch index not acknowledged")
 	}
+
+	return nil
 }
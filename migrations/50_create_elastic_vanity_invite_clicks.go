@@ -6,18 +6,14 @@ import (
 	"github.com/Seklfreak/Robyul2/cache"
 )
 
-func m50_create_elastic_vanity_invite_clicks() {
-	if !cache.HasElastic() {
-		return
-	}
-
+func m50_create_elastic_vanity_invite_clicks() error {
 	elastic := cache.GetElastic()
 	exists, err := elastic.IndexExists("robyul-vanity_invite_clicks").Do(context.Background())
 	if err != nil {
-		panic(err)
+		return err
 	}
 	if exists {
-		return
+		return nil
 	}
 
 	vanityInviteClickMapping := map[string]interface{}{
@@ -48,9 +44,11 @@ func m50_create_elastic_vanity_invite_clicks() {
 
 	index, err := elastic.CreateIndex("robyul-vanity_invite_clicks").BodyJson(vanityInviteClickMapping).Do(context.Background())
 	if err != nil {
-		panic(err)
+		return err
 	}
 	if !index.Acknowledged {
 		cache.GetLogger().WithField("module", "migrations").Error("ElasticSearch index not acknowledged")
 	}
+
+	return nil
 }
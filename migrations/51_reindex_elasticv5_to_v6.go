@@ -7,18 +7,14 @@ import (
 	"github.com/olivere/elastic"
 )
 
-func m51_reindex_elasticv5_to_v6() {
-	if !cache.HasElastic() {
-		return
-	}
-
+func m51_reindex_elasticv5_to_v6() error {
 	elasticClient := cache.GetElastic()
 	exists, err := elasticClient.IndexExists("robyul").Do(context.Background())
 	if err != nil {
-		panic(err)
+		return err
 	}
 	if !exists {
-		return
+		return nil
 	}
 
 	cache.GetLogger().WithField("module", "migrations").Info("reindexing ElasticSearch indexes")
@@ -27,7 +23,7 @@ func m51_reindex_elasticv5_to_v6() {
 	dst := elastic.NewReindexDestination().Index("robyul-messages-v2").Type("doc")
 	res, err := elasticClient.Reindex().Source(src).Destination(dst).Refresh("true").Do(context.Background())
 	if err != nil {
-		panic(err)
+		return err
 	}
 	cache.GetLogger().WithField("module", "migrations").Infof(
 		"Reindexed a total of %d message documents", res.Total)
@@ -36,7 +32,7 @@ func m51_reindex_elasticv5_to_v6() {
 	dst = elastic.NewReindexDestination().Index("robyul-joins").Type("doc")
 	res, err = elasticClient.Reindex().Source(src).Destination(dst).Refresh("true").Do(context.Background())
 	if err != nil {
-		panic(err)
+		return err
 	}
 	cache.GetLogger().WithField("module", "migrations").Infof(
 		"Reindexed a total of %d join documents", res.Total)
@@ -45,7 +41,7 @@ func m51_reindex_elasticv5_to_v6() {
 	dst = elastic.NewReindexDestination().Index("robyul-leaves").Type("doc")
 	res, err = elasticClient.Reindex().Source(src).Destination(dst).Refresh("true").Do(context.Background())
 	if err != nil {
-		panic(err)
+		return err
 	}
 	cache.GetLogger().WithField("module", "migrations").Infof(
 		"Reindexed a total of %d leave documents", res.Total)
@@ -54,7 +50,7 @@ func m51_reindex_elasticv5_to_v6() {
 	dst = elastic.NewReindexDestination().Index("robyul-reactions").Type("doc")
 	res, err = elasticClient.Reindex().Source(src).Destination(dst).Refresh("true").Do(context.Background())
 	if err != nil {
-		panic(err)
+		return err
 	}
 	cache.GetLogger().WithField("module", "migrations").Infof(
 		"Reindexed a total of %d reaction documents", res.Total)
@@ -63,7 +59,7 @@ func m51_reindex_elasticv5_to_v6() {
 	dst = elastic.NewReindexDestination().Index("robyul-presence_updates").Type("doc")
 	res, err = elasticClient.Reindex().Source(src).Destination(dst).Refresh("true").Do(context.Background())
 	if err != nil {
-		panic(err)
+		return err
 	}
 	cache.GetLogger().WithField("module", "migrations").Infof(
 		"Reindexed a total of %d presence update documents", res.Total)
@@ -72,16 +68,18 @@ func m51_reindex_elasticv5_to_v6() {
 	dst = elastic.NewReindexDestination().Index("robyul-vanity_invite_clicks").Type("doc")
 	res, err = elasticClient.Reindex().Source(src).Destination(dst).Refresh("true").Do(context.Background())
 	if err != nil {
-		panic(err)
+		return err
 	}
 	cache.GetLogger().WithField("module", "migrations").Infof(
 		"Reindexed a total of %d vanity invite click documents", res.Total)
 
 	index, err := elasticClient.DeleteIndex("robyul").Do(context.Background())
 	if err != nil {
-		panic(err)
+		return err
 	}
 	if !index.Acknowledged {
 		cache.GetLogger().WithField("module", "migrations").Error("ElasticSearch index not acknowledged")
 	}
+
+	return nil
 }
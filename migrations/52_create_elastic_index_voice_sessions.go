@@ -6,18 +6,14 @@ import (
 	"github.com/Seklfreak/Robyul2/cache"
 )
 
-func m52_create_elastic_index_voice_sessions() {
-	if !cache.HasElastic() {
-		return
-	}
-
+func m52_create_elastic_index_voice_sessions() error {
 	elastic := cache.GetElastic()
 	exists, err := elastic.IndexExists("robyul-voice_session").Do(context.Background())
 	if err != nil {
-		panic(err)
+		return err
 	}
 	if exists {
-		return
+		return nil
 	}
 
 	messageMapping := map[string]interface{}{
@@ -62,9 +58,11 @@ func m52_create_elastic_index_voice_sessions() {
 
 	index, err := elastic.CreateIndex("robyul-voice_session").BodyJson(messageMapping).Do(context.Background())
 	if err != nil {
-		panic(err)
+		return err
 	}
 	if !index.Acknowledged {
 		cache.GetLogger().WithField("module", "migrations").Error("ElasticSearch index not acknowledged")
 	}
+
+	return nil
 }
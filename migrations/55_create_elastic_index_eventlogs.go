@@ -6,18 +6,14 @@ import (
 	"github.com/Seklfreak/Robyul2/cache"
 )
 
-func m55_create_elastic_index_eventlogs() {
-	if !cache.HasElastic() {
-		return
-	}
-
+func m55_create_elastic_index_eventlogs() error {
 	elastic := cache.GetElastic()
 	exists, err := elastic.IndexExists("robyul-eventlogs").Do(context.Background())
 	if err != nil {
-		panic(err)
+		return err
 	}
 	if exists {
-		return
+		return nil
 	}
 
 	messageMapping := map[string]interface{}{
@@ -106,9 +102,11 @@ func m55_create_elastic_index_eventlogs() {
 
 	index, err := elastic.CreateIndex("robyul-eventlogs").BodyJson(messageMapping).Do(context.Background())
 	if err != nil {
-		panic(err)
+		return err
 	}
 	if !index.Acknowledged {
 		cache.GetLogger().WithField("module", "migrations").Error("ElasticSearch index not acknowledged")
 	}
+
+	return nil
 }
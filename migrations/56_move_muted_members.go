@@ -0,0 +1,66 @@
+package migrations
+
+import (
+	"github.com/Seklfreak/Robyul2/helpers"
+	"github.com/Seklfreak/Robyul2/models"
+	"github.com/globalsign/mgo/bson"
+)
+
+type m56GuildConfig struct {
+	ID           bson.ObjectId `bson:"_id"`
+	GuildID      string
+	MutedMembers []string
+}
+
+// m56_move_muted_members moves the deprecated MutedMembers of the guild configs to their own collection
+func m56_move_muted_members() error {
+	var guildConfig m56GuildConfig
+	iter := helpers.MdbCollection(models.GuildConfigTable).
+		Find(bson.M{"mutedmembers.0": bson.M{"$exists": true}}).
+		Select(bson.M{"guildid": 1, "mutedmembers": 1}).
+		Iter()
+	for iter.Next(&guildConfig) {
+		for _, userID := range guildConfig.MutedMembers {
+			// a rerun after an interrupted run leaves the muted members that have been moved already untouched
+			_, err := helpers.MdbCollection(models.MutedMembersTable).Upsert(
+				bson.M{"guildid": guildConfig.GuildID, "userid": userID},
+				bson.M{"$setOnInsert": models.MutedMemberEntry{GuildID: guildConfig.GuildID, UserID: userID}},
+			)
+			if err != nil {
+				iter.Close()
+				return err
+			}
+		}
+
+		err := helpers.MdbCollection(models.GuildConfigTable).UpdateId(guildConfig.ID, bson.M{"$unset": bson.M{"mutedmembers": ""}})
+		if err != nil {
+			iter.Close()
+			return err
+		}
+
+		guildConfig = m56GuildConfig{}
+	}
+	return iter.Close()
+}
+
+func m56_move_muted_members_down() error {
+	var mutedMember models.MutedMemberEntry
+	iter := helpers.MdbCollection(models.MutedMembersTable).Find(nil).Iter()
+	for iter.Next(&mutedMember) {
+		err := helpers.MdbCollection(models.GuildConfigTable).Update(
+			bson.M{"guildid": mutedMember.GuildID},
+			bson.M{"$addToSet": bson.M{"mutedmembers": mutedMember.UserID}},
+		)
+		if err != nil && !helpers.IsMdbNotFound(err) {
+			iter.Close()
+			return err
+		}
+	}
+	err := iter.Close()
+	if err != nil {
+		return err
+	}
+
+	_, err = helpers.MdbCollection(models.MutedMembersTable).RemoveAll(nil)
+	return err
+}
@@ -0,0 +1,41 @@
+package migrations
+
+import (
+	"strings"
+
+	"github.com/Seklfreak/Robyul2/helpers"
+	"github.com/Seklfreak/Robyul2/models"
+	"github.com/globalsign/mgo"
+)
+
+var m57Indexes = []struct {
+	collection models.MongoDbCollection
+	index      mgo.Index
+}{
+	{models.ApiTokensTable, mgo.Index{Key: []string{"tokenhash"}, Unique: true}},
+	{models.ApiTokenAuditTable, mgo.Index{Key: []string{"tokenid", "createdat"}}},
+	{models.NotificationsDigestTable, mgo.Index{Key: []string{"userid", "createdat"}}},
+	{models.MutedMembersTable, mgo.Index{Key: []string{"guildid", "userid"}, Unique: true}},
+	{models.MigrationsTable, mgo.Index{Key: []string{"version"}, Unique: true}},
+}
+
+func m57_create_mongo_indexes() error {
+	for _, entry := range m57Indexes {
+		err := helpers.MdbCollection(entry.collection).EnsureIndex(entry.index)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func m57_create_mongo_indexes_down() error {
+	for _, entry := range m57Indexes {
+		err := helpers.MdbCollection(entry.collection).DropIndex(entry.index.Key...)
+		// the index or the whole collection might not exist anymore
+		if err != nil && !strings.Contains(err.Error(), "not found") {
+			return err
+		}
+	}
+	return nil
+}
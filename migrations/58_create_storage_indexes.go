@@ -0,0 +1,38 @@
+package migrations
+
+import (
+	"strings"
+
+	"github.com/Seklfreak/Robyul2/helpers"
+	"github.com/Seklfreak/Robyul2/models"
+	"github.com/globalsign/mgo"
+)
+
+var m58Indexes = []struct {
+	collection models.MongoDbCollection
+	index      mgo.Index
+}{
+	{models.StorageBlobsTable, mgo.Index{Key: []string{"contenthash"}, Unique: true}},
+	{models.StorageTable, mgo.Index{Key: []string{"contenthash"}}},
+}
+
+func m58_create_storage_indexes() error {
+	for _, entry := range m58Indexes {
+		err := helpers.MdbCollection(entry.collection).EnsureIndex(entry.index)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func m58_create_storage_indexes_down() error {
+	for _, entry := range m58Indexes {
+		err := helpers.MdbCollection(entry.collection).DropIndex(entry.index.Key...)
+		// the index or the whole collection might not exist anymore
+		if err != nil && !strings.Contains(err.Error(), "not found") {
+			return err
+		}
+	}
+	return nil
+}
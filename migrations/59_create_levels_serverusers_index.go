@@ -0,0 +1,80 @@
+package migrations
+
+import (
+	"strings"
+
+	"github.com/Seklfreak/Robyul2/helpers"
+	"github.com/Seklfreak/Robyul2/models"
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+)
+
+var m59Index = mgo.Index{Key: []string{"guildid", "userid"}, Unique: true}
+
+type m59DuplicateServerusers struct {
+	IDs  []bson.ObjectId `bson:"ids"`
+	Exps []int64         `bson:"exps"`
+}
+
+// m59_create_levels_serverusers_index merges the EXP of duplicate members into one entry, and prevents new duplicates with a unique index
+// the oldest entry keeps the members merged into it until all duplicates are removed, so a rerun does not add their EXP twice
+func m59_create_levels_serverusers_index() error {
+	var duplicate m59DuplicateServerusers
+	iter := helpers.MdbCollection(models.LevelsServerusersTable).Pipe([]bson.M{
+		{"$sort": bson.M{"_id": 1}},
+		{"$group": bson.M{
+			"_id":   bson.M{"guildid": "$guildid", "userid": "$userid"},
+			"ids":   bson.M{"$push": "$_id"},
+			"exps":  bson.M{"$push": bson.M{"$ifNull": []interface{}{"$exp", 0}}},
+			"count": bson.M{"$sum": 1},
+		}},
+		{"$match": bson.M{"count": bson.M{"$gt": 1}}},
+	}).AllowDiskUse().Iter()
+	for iter.Next(&duplicate) {
+		for i, id := range duplicate.IDs[1:] {
+			err := helpers.MdbCollection(models.LevelsServerusersTable).Update(
+				bson.M{"_id": duplicate.IDs[0], "m59mergedids": bson.M{"$ne": id}},
+				bson.M{
+					"$inc":  bson.M{"exp": duplicate.Exps[i+1]},
+					"$push": bson.M{"m59mergedids": id},
+				},
+			)
+			if err != nil && !helpers.IsMdbNotFound(err) {
+				iter.Close()
+				return err
+			}
+
+			err = helpers.MdbCollection(models.LevelsServerusersTable).RemoveId(id)
+			if err != nil && !helpers.IsMdbNotFound(err) {
+				iter.Close()
+				return err
+			}
+		}
+
+		duplicate = m59DuplicateServerusers{}
+	}
+	err := iter.Close()
+	if err != nil {
+		return err
+	}
+
+	_, err = helpers.MdbCollection(models.LevelsServerusersTable).UpdateAll(
+		bson.M{"m59mergedids": bson.M{"$exists": true}},
+		bson.M{"$unset": bson.M{"m59mergedids": 1}},
+	)
+	if err != nil {
+		return err
+	}
+
+	return helpers.MdbCollection(models.LevelsServerusersTable).EnsureIndex(m59Index)
+}
+
+// m59_create_levels_serverusers_index_down drops the index, merged members are not split again
+func m59_create_levels_serverusers_index_down() error {
+	err := helpers.MdbCollection(models.LevelsServerusersTable).DropIndex(m59Index.Key...)
+	// the index or the whole collection might not exist anymore
+	if err != nil && !strings.Contains(err.Error(), "not found") {
+		return err
+	}
+	return nil
+}
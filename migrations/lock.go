@@ -0,0 +1,116 @@
+package migrations
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/Seklfreak/Robyul2/cache"
+	"github.com/Seklfreak/Robyul2/helpers"
+	"github.com/Seklfreak/Robyul2/models"
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+)
+
+const (
+	migrationsLockID = "migrations"
+	// migrationsLockTTL is the time after which the lock of a crashed instance is taken over, it is refreshed while migrations are running
+	migrationsLockTTL     = 2 * time.Minute
+	migrationsLockRefresh = 30 * time.Second
+	migrationsLockRetry   = 5 * time.Second
+	migrationsLockTimeout = 30 * time.Minute
+)
+
+var (
+	migrationsLockOwner = getMigrationsLockOwner()
+
+	errMigrationsLockLost = errors.New("lost the migrations lock to another instance")
+)
+
+func getMigrationsLockOwner() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+	return fmt.Sprintf("%s:%d", hostname, os.Getpid())
+}
+
+// lockMigrations waits until no other bot instance runs migrations and takes the lock
+// the returned unlock function releases the lock, lost is closed if another instance took over the lock
+// because it couldn't be refreshed in time, no further migrations may be run then
+func lockMigrations() (unlock func(), lost <-chan struct{}, err error) {
+	log := cache.GetLogger()
+	collection := helpers.MdbCollection(models.MigrationsLockTable)
+
+	waitingSince := time.Now()
+	for {
+		now := time.Now()
+		err = collection.Insert(models.MigrationLockEntry{
+			ID:        migrationsLockID,
+			Owner:     migrationsLockOwner,
+			LockedAt:  now,
+			ExpiresAt: now.Add(migrationsLockTTL),
+		})
+		if err == nil {
+			break
+		}
+		if !mgo.IsDup(err) {
+			return nil, nil, err
+		}
+
+		// take over expired locks of crashed instances
+		_, err = collection.RemoveAll(bson.M{"_id": migrationsLockID, "expiresat": bson.M{"$lt": now}})
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if time.Since(waitingSince) > migrationsLockTimeout {
+			return nil, nil, errors.New("timed out waiting for the migrations lock")
+		}
+		log.WithField("module", "migrator").Info("Waiting for another instance to finish migrations...")
+		time.Sleep(migrationsLockRetry)
+	}
+
+	done := make(chan struct{})
+	lostLock := make(chan struct{})
+	go func() {
+		defer helpers.Recover()
+
+		ticker := time.NewTicker(migrationsLockRefresh)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				err := collection.Update(
+					bson.M{"_id": migrationsLockID, "owner": migrationsLockOwner},
+					bson.M{"$set": bson.M{"expiresat": time.Now().Add(migrationsLockTTL)}},
+				)
+				if err == mgo.ErrNotFound {
+					log.WithField("module", "migrator").Error(errMigrationsLockLost.Error())
+					close(lostLock)
+					return
+				}
+				helpers.RelaxLog(err)
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		_, err := collection.RemoveAll(bson.M{"_id": migrationsLockID, "owner": migrationsLockOwner})
+		helpers.RelaxLog(err)
+	}, lostLock, nil
+}
+
+// isMigrationsLockLost checks if the lost channel returned by lockMigrations has been closed
+func isMigrationsLockLost(lost <-chan struct{}) bool {
+	select {
+	case <-lost:
+		return true
+	default:
+		return false
+	}
+}
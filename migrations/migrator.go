@@ -1,39 +1,290 @@
 package migrations
 
 import (
-	"reflect"
-	"runtime"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/Seklfreak/Robyul2/cache"
 	"github.com/Seklfreak/Robyul2/helpers"
+	"github.com/Seklfreak/Robyul2/models"
+	"github.com/globalsign/mgo/bson"
 )
 
-var migrations = []helpers.Callback{
-	m28_create_elastic_indexes,
-	m29_create_elastic_presence_update_index,
-	m43_create_elastic_vanityinvite_click_index,
-	m45_create_elastic_index_messages,
-	m46_create_elastic_index_joins,
-	m47_create_elastic_index_leaves,
-	m49_create_elastic_index_presence_updates,
-	m50_create_elastic_vanity_invite_clicks,
-	m51_reindex_elasticv5_to_v6,
-	m52_create_elastic_index_voice_sessions,
-	m55_create_elastic_index_eventlogs,
-}
-
-// Run executes all registered migrations
+// Migration is applied once and recorded in the migrations collection, Down reverts it
+// Down is nil if the migration can not be rolled back
+// Elastic migrations are skipped, and not recorded, as long as ElasticSearch is not configured
+type Migration struct {
+	Version int
+	Name    string
+	Elastic bool
+	Up      func() error
+	Down    func() error
+}
+
+// migrations have to be sorted by version
+// the migrations up to 55 have been run on every boot before they were recorded, so they have to stay idempotent,
+// and they can't be rolled back, as they would delete indexes with live data
+// later migrations have to be idempotent as well, an interrupted migration is applied again on the next run
+var migrations = []Migration{
+	{Version: 28, Name: "create_elastic_indexes", Elastic: true, Up: m28_create_elastic_indexes},
+	{Version: 29, Name: "create_elastic_presence_update_index", Elastic: true, Up: m29_create_elastic_presence_update_index},
+	{Version: 43, Name: "create_elastic_vanityinvite_click_index", Elastic: true, Up: m43_create_elastic_vanityinvite_click_index},
+	{Version: 45, Name: "create_elastic_index_messages", Elastic: true, Up: m45_create_elastic_index_messages},
+	{Version: 46, Name: "create_elastic_index_joins", Elastic: true, Up: m46_create_elastic_index_joins},
+	{Version: 47, Name: "create_elastic_index_leaves", Elastic: true, Up: m47_create_elastic_index_leaves},
+	{Version: 49, Name: "create_elastic_index_presence_updates", Elastic: true, Up: m49_create_elastic_index_presence_updates},
+	{Version: 50, Name: "create_elastic_vanity_invite_clicks", Elastic: true, Up: m50_create_elastic_vanity_invite_clicks},
+	{Version: 51, Name: "reindex_elasticv5_to_v6", Elastic: true, Up: m51_reindex_elasticv5_to_v6},
+	{Version: 52, Name: "create_elastic_index_voice_sessions", Elastic: true, Up: m52_create_elastic_index_voice_sessions},
+	{Version: 55, Name: "create_elastic_index_eventlogs", Elastic: true, Up: m55_create_elastic_index_eventlogs},
+	{Version: 56, Name: "move_muted_members", Up: m56_move_muted_members, Down: m56_move_muted_members_down},
+	{Version: 57, Name: "create_mongo_indexes", Up: m57_create_mongo_indexes, Down: m57_create_mongo_indexes_down},
+	{Version: 58, Name: "create_storage_indexes", Up: m58_create_storage_indexes, Down: m58_create_storage_indexes_down},
+	{Version: 59, Name: "create_levels_serverusers_index", Up: m59_create_levels_serverusers_index, Down: m59_create_levels_serverusers_index_down},
+}
+
+// Run applies all pending migrations, it panics if a migration fails
 func Run() {
 	log := cache.GetLogger()
 	log.WithField("module", "migrator").Info("Running migrations...")
-	for _, migration := range migrations {
-		migrationName := runtime.FuncForPC(
-			reflect.ValueOf(migration).Pointer(),
-		).Name()
 
-		log.WithField("module", "migrator").Info("Running " + migrationName)
-		migration()
+	err := Command("up")
+	if err != nil {
+		panic(err)
 	}
 
 	log.WithField("module", "migrator").Info("Migrations finished!")
 }
+
+// Command lists, applies or rolls back migrations
+// list: lists all migrations and if they have been applied
+// up: applies all pending migrations
+// down: rolls back the last applied migration
+// down:<version>: rolls back all applied migrations down to, and including, the version
+func Command(command string) (err error) {
+	err = validateMigrations(migrations)
+	if err != nil {
+		return err
+	}
+
+	unlock, lostLock, err := lockMigrations()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	applied, err := getAppliedMigrations()
+	if err != nil {
+		return err
+	}
+
+	args := strings.SplitN(command, ":", 2)
+	switch args[0] {
+	case "list":
+		listMigrations(applied)
+		return nil
+	case "up":
+		for _, migration := range pendingMigrations(migrations, applied, cache.HasElastic()) {
+			if isMigrationsLockLost(lostLock) {
+				return errMigrationsLockLost
+			}
+			if entry, ok := applied[migration.Version]; ok && entry.Applying {
+				cache.GetLogger().WithField("module", "migrator").Warnf(
+					"migration %d %s has been interrupted while being applied by %s, applying it again",
+					migration.Version, migration.Name, entry.AppliedBy,
+				)
+			}
+			err = applyMigration(migration)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	case "down":
+		toVersion := -1
+		if len(args) > 1 {
+			toVersion, err = strconv.Atoi(args[1])
+			if err != nil {
+				return fmt.Errorf("invalid migration version %s", args[1])
+			}
+		}
+
+		rollback, err := rollbackMigrations(migrations, applied, toVersion)
+		if err != nil {
+			return err
+		}
+		for _, migration := range rollback {
+			if isMigrationsLockLost(lostLock) {
+				return errMigrationsLockLost
+			}
+			err = rollbackMigration(migration)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	return fmt.Errorf("unknown migrations command %s, use list, up, down or down:<version>", command)
+}
+
+// validateMigrations checks that the migrations are sorted by version and complete
+func validateMigrations(migrations []Migration) error {
+	for i, migration := range migrations {
+		if migration.Name == "" || migration.Up == nil {
+			return fmt.Errorf("migration %d is missing its name or up step", migration.Version)
+		}
+		if i > 0 && migration.Version <= migrations[i-1].Version {
+			return fmt.Errorf("migration %d is not sorted by version", migration.Version)
+		}
+	}
+	return nil
+}
+
+func getAppliedMigrations() (applied map[int]models.MigrationEntry, err error) {
+	var entries []models.MigrationEntry
+	err = helpers.MdbCollection(models.MigrationsTable).Find(nil).All(&entries)
+	if err != nil {
+		return nil, err
+	}
+
+	applied = make(map[int]models.MigrationEntry, len(entries))
+	for _, entry := range entries {
+		applied[entry.Version] = entry
+	}
+	return applied, nil
+}
+
+// pendingMigrations returns the migrations that have not been applied yet, or have been interrupted, in order
+func pendingMigrations(migrations []Migration, applied map[int]models.MigrationEntry, hasElastic bool) (pending []Migration) {
+	for _, migration := range migrations {
+		if entry, ok := applied[migration.Version]; ok && !entry.Applying {
+			continue
+		}
+		if migration.Elastic && !hasElastic {
+			continue
+		}
+		pending = append(pending, migration)
+	}
+	return pending
+}
+
+// rollbackMigrations returns the applied migrations down to, and including, toVersion, newest first
+// if toVersion is negative only the last applied migration is returned
+func rollbackMigrations(migrations []Migration, applied map[int]models.MigrationEntry, toVersion int) (rollback []Migration, err error) {
+	for i := len(migrations) - 1; i >= 0; i-- {
+		migration := migrations[i]
+		if _, ok := applied[migration.Version]; !ok {
+			continue
+		}
+		if toVersion >= 0 && migration.Version < toVersion {
+			break
+		}
+		if migration.Down == nil {
+			return nil, fmt.Errorf("migration %d %s can not be rolled back", migration.Version, migration.Name)
+		}
+
+		rollback = append(rollback, migration)
+		if toVersion < 0 {
+			break
+		}
+	}
+
+	if len(rollback) <= 0 {
+		return nil, errors.New("no applied migrations to roll back")
+	}
+	return rollback, nil
+}
+
+func applyMigration(migration Migration) (err error) {
+	log := cache.GetLogger()
+	log.WithField("module", "migrator").Infof("Applying migration %d %s", migration.Version, migration.Name)
+
+	// the migration is recorded before it runs, so it is applied again if it is interrupted
+	_, err = helpers.MdbCollection(models.MigrationsTable).Upsert(
+		bson.M{"version": migration.Version},
+		models.MigrationEntry{
+			Version:   migration.Version,
+			Name:      migration.Name,
+			AppliedAt: time.Now(),
+			AppliedBy: migrationsLockOwner,
+			Applying:  true,
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	err = migration.Up()
+	if err != nil {
+		return fmt.Errorf("migration %d %s failed: %s", migration.Version, migration.Name, err.Error())
+	}
+
+	return helpers.MdbCollection(models.MigrationsTable).Update(
+		bson.M{"version": migration.Version},
+		bson.M{
+			"$set":   bson.M{"appliedat": time.Now()},
+			"$unset": bson.M{"applying": 1},
+		},
+	)
+}
+
+func rollbackMigration(migration Migration) (err error) {
+	log := cache.GetLogger()
+	if migration.Elastic && !cache.HasElastic() {
+		return fmt.Errorf("migration %d %s requires ElasticSearch", migration.Version, migration.Name)
+	}
+
+	log.WithField("module", "migrator").Infof("Rolling back migration %d %s", migration.Version, migration.Name)
+
+	err = migration.Down()
+	if err != nil {
+		return fmt.Errorf("rolling back migration %d %s failed: %s", migration.Version, migration.Name, err.Error())
+	}
+
+	_, err = helpers.MdbCollection(models.MigrationsTable).RemoveAll(bson.M{"version": migration.Version})
+	return err
+}
+
+func listMigrations(applied map[int]models.MigrationEntry) {
+	log := cache.GetLogger()
+
+	versions := make([]int, 0, len(applied))
+	for version := range applied {
+		versions = append(versions, version)
+	}
+	sort.Ints(versions)
+
+	known := make(map[int]bool, len(migrations))
+	for _, migration := range migrations {
+		known[migration.Version] = true
+
+		var rollback string
+		if migration.Down == nil {
+			rollback = ", can not be rolled back"
+		}
+
+		if entry, ok := applied[migration.Version]; ok && entry.Applying {
+			log.WithField("module", "migrator").Warnf("%d %s: interrupted while being applied at %s by %s%s",
+				migration.Version, migration.Name, entry.AppliedAt.Format(time.RFC3339), entry.AppliedBy, rollback)
+			continue
+		}
+		if entry, ok := applied[migration.Version]; ok {
+			log.WithField("module", "migrator").Infof("%d %s: applied at %s by %s%s",
+				migration.Version, migration.Name, entry.AppliedAt.Format(time.RFC3339), entry.AppliedBy, rollback)
+			continue
+		}
+		log.WithField("module", "migrator").Infof("%d %s: pending%s", migration.Version, migration.Name, rollback)
+	}
+
+	for _, version := range versions {
+		if !known[version] {
+			log.WithField("module", "migrator").Warnf("%d %s: applied at %s by %s, but unknown to this version",
+				version, applied[version].Name, applied[version].AppliedAt.Format(time.RFC3339), applied[version].AppliedBy)
+		}
+	}
+}
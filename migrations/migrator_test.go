@@ -0,0 +1,68 @@
+package migrations
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Seklfreak/Robyul2/models"
+)
+
+func TestValidateMigrations(t *testing.T) {
+	if err := validateMigrations(migrations); err != nil {
+		t.Errorf("migrations.validateMigrations() returned %v for the registered migrations", err)
+	}
+
+	up := func() error { return nil }
+	unsorted := []Migration{{Version: 2, Name: "b", Up: up}, {Version: 1, Name: "a", Up: up}}
+	if err := validateMigrations(unsorted); err == nil {
+		t.Errorf("migrations.validateMigrations() accepted unsorted migrations")
+	}
+}
+
+func TestPendingAndRollbackMigrations(t *testing.T) {
+	up := func() error { return nil }
+	testMigrations := []Migration{
+		{Version: 1, Name: "elastic", Elastic: true, Up: up, Down: up},
+		{Version: 2, Name: "irreversible", Up: up},
+		{Version: 3, Name: "mongo", Up: up, Down: up},
+		{Version: 4, Name: "mongo", Up: up, Down: up},
+	}
+	applied := map[int]models.MigrationEntry{2: {Version: 2}, 3: {Version: 3}}
+
+	versions := func(migrations []Migration) (versions []int) {
+		for _, migration := range migrations {
+			versions = append(versions, migration.Version)
+		}
+		return versions
+	}
+
+	if pending := versions(pendingMigrations(testMigrations, applied, false)); !reflect.DeepEqual(pending, []int{4}) {
+		t.Errorf("migrations.pendingMigrations() without ElasticSearch returned %v, expected [4]", pending)
+	}
+	if pending := versions(pendingMigrations(testMigrations, applied, true)); !reflect.DeepEqual(pending, []int{1, 4}) {
+		t.Errorf("migrations.pendingMigrations() with ElasticSearch returned %v, expected [1 4]", pending)
+	}
+
+	applied[3] = models.MigrationEntry{Version: 3, Applying: true}
+	if pending := versions(pendingMigrations(testMigrations, applied, false)); !reflect.DeepEqual(pending, []int{3, 4}) {
+		t.Errorf("migrations.pendingMigrations() with an interrupted migration returned %v, expected [3 4]", pending)
+	}
+	applied[3] = models.MigrationEntry{Version: 3}
+
+	applied[4] = models.MigrationEntry{Version: 4}
+	tests := []struct {
+		toVersion int
+		expected  []int
+		fails     bool
+	}{
+		{-1, []int{4}, false},
+		{3, []int{4, 3}, false},
+		{2, nil, true},
+	}
+	for _, test := range tests {
+		rollback, err := rollbackMigrations(testMigrations, applied, test.toVersion)
+		if (err != nil) != test.fails || !reflect.DeepEqual(versions(rollback), test.expected) {
+			t.Errorf("migrations.rollbackMigrations(%d) returned %v, %v, expected %v", test.toVersion, versions(rollback), err, test.expected)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package models
+
+import (
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+)
+
+const (
+	ApiTokensTable     MongoDbCollection = "api_tokens"
+	ApiTokenAuditTable MongoDbCollection = "api_token_audit"
+
+	// Redis_Key_Api_Session contains the session of a user, by the SHA-256 hash of the session token
+	Redis_Key_Api_Session = "robyul2-discord:api:session:%s"
+)
+
+type ApiTokenScope string
+
+const (
+	ApiTokenScopeReadRankings  ApiTokenScope = "read:rankings"
+	ApiTokenScopeReadEventlog  ApiTokenScope = "read:eventlog"
+	ApiTokenScopeWriteSettings ApiTokenScope = "write:settings"
+)
+
+// ApiTokenScopes are all valid API token scopes
+var ApiTokenScopes = []ApiTokenScope{
+	ApiTokenScopeReadRankings,
+	ApiTokenScopeReadEventlog,
+	ApiTokenScopeWriteSettings,
+}
+
+// ApiTokenEntry is an API token of a client, only the SHA-256 hash of the token is stored
+// a token without GuildIDs can access every guild, a token without ExpiresAt never expires
+type ApiTokenEntry struct {
+	ID              bson.ObjectId `bson:"_id,omitempty"`
+	Name            string
+	TokenHash       string
+	Scopes          []ApiTokenScope
+	GuildIDs        []string
+	CreatedByUserID string
+	CreatedAt       time.Time
+	ExpiresAt       time.Time
+	LastUsedAt      time.Time
+}
+
+type ApiTokenAuditAction string
+
+const (
+	ApiTokenAuditActionCreate ApiTokenAuditAction = "create"
+	ApiTokenAuditActionRevoke ApiTokenAuditAction = "revoke"
+	ApiTokenAuditActionUse    ApiTokenAuditAction = "use"
+	ApiTokenAuditActionDeny   ApiTokenAuditAction = "deny"
+)
+
+// ApiTokenAuditEntry records the management and every use of an API token
+type ApiTokenAuditEntry struct {
+	ID         bson.ObjectId `bson:"_id,omitempty"`
+	TokenID    bson.ObjectId
+	Action     ApiTokenAuditAction
+	Reason     string
+	Method     string
+	Path       string
+	RemoteAddr string
+	UserID     string
+	CreatedAt  time.Time
+}
+
+// ApiSession is a session of a user authenticated with Discord OAuth2
+type ApiSession struct {
+	UserID    string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
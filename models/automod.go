@@ -0,0 +1,58 @@
+package models
+
+import (
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+)
+
+const (
+	AutomodRulesTable MongoDbCollection = "automod_rules"
+)
+
+type AutomodRuleType string
+
+const (
+	// AutomodRuleTypeRegex matches messages with one of the regular expressions in Values
+	AutomodRuleTypeRegex AutomodRuleType = "regex"
+	// AutomodRuleTypeWords matches messages containing one of the words in Values
+	AutomodRuleTypeWords AutomodRuleType = "words"
+	// AutomodRuleTypeInvites matches Discord invites, invite codes in Values are allowed
+	AutomodRuleTypeInvites AutomodRuleType = "invites"
+	// AutomodRuleTypeMentions matches messages with at least Threshold user and role mentions
+	AutomodRuleTypeMentions AutomodRuleType = "mentions"
+	// AutomodRuleTypeDuplicates matches the Threshold-th identical message of a member within the duplicates window
+	AutomodRuleTypeDuplicates AutomodRuleType = "duplicates"
+	// AutomodRuleTypeCaps matches messages with at least Threshold percent upper case letters
+	AutomodRuleTypeCaps AutomodRuleType = "caps"
+	// AutomodRuleTypeAttachments matches attachments with one of the file extensions in Values
+	AutomodRuleTypeAttachments AutomodRuleType = "attachments"
+	// AutomodRuleTypeDomains matches links to one of the domains in Values, including their subdomains
+	AutomodRuleTypeDomains AutomodRuleType = "domains"
+)
+
+type AutomodActionType string
+
+const (
+	AutomodActionTypeDelete AutomodActionType = "delete"
+	AutomodActionTypeWarn   AutomodActionType = "warn"
+	// AutomodActionTypeMute mutes the member, for MuteDuration if it is set
+	AutomodActionTypeMute AutomodActionType = "mute"
+	// AutomodActionTypeLog only adds an entry to the eventlog, every other action is logged as well
+	AutomodActionTypeLog AutomodActionType = "log"
+)
+
+// AutomodRuleEntry is an automod rule of a guild, messages by moderators and in ExemptChannelIDs are never checked
+type AutomodRuleEntry struct {
+	ID               bson.ObjectId `bson:"_id,omitempty"`
+	GuildID          string
+	Type             AutomodRuleType
+	Values           []string
+	Threshold        int
+	Actions          []AutomodActionType
+	MuteDuration     string
+	ExemptChannelIDs []string
+	Enabled          bool
+	CreatedByUserID  string
+	CreatedAt        time.Time
+}
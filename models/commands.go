@@ -0,0 +1,44 @@
+package models
+
+type CommandPermission string
+
+const (
+	CommandPermissionEveryone  CommandPermission = ""
+	CommandPermissionMod       CommandPermission = "mod"
+	CommandPermissionAdmin     CommandPermission = "admin"
+	CommandPermissionRobyulMod CommandPermission = "robyulmod"
+	CommandPermissionBotAdmin  CommandPermission = "botadmin"
+)
+
+type CommandArgumentType string
+
+const (
+	CommandArgumentTypeText    CommandArgumentType = "text"
+	CommandArgumentTypeNumber  CommandArgumentType = "number"
+	CommandArgumentTypeUser    CommandArgumentType = "user"
+	CommandArgumentTypeChannel CommandArgumentType = "channel"
+	CommandArgumentTypeRole    CommandArgumentType = "role"
+)
+
+// CommandDescriptor describes a command a plugin reacts to
+// Usage is the argument part of the usage line, without prefix and command
+type CommandDescriptor struct {
+	Name        string
+	Aliases     []string
+	Description string
+	Usage       string
+	Arguments   []CommandArgument
+	Permission  CommandPermission
+	Module      ModulePermissionsModule
+	ModuleName  string
+}
+
+// CommandArgument describes a positional argument of a command
+// a Variadic argument consumes the rest of the arguments and has to be the last one
+type CommandArgument struct {
+	Name        string
+	Description string
+	Type        CommandArgumentType
+	Required    bool
+	Variadic    bool
+}
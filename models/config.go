@@ -29,6 +29,8 @@ type Config struct {
 	WelcomeNewUsersText    string
 
 	MutedRoleName string
+	// ModEscalations are applied automatically once a member received enough warnings
+	ModEscalations []ModEscalation
 
 	InspectTriggersEnabled InspectTriggersEnabled
 	InspectsChannel        string
@@ -41,8 +43,12 @@ type Config struct {
 	LevelsNotificationCode        string
 	LevelsNotificationDeleteAfter int
 	LevelsMaxBadges               int
-
-	MutedMembers []string // deprecated
+	LevelsCurve                   LevelsCurve
+	LevelsExpMin                  int
+	LevelsExpMax                  int
+	LevelsChannelMultipliers      []LevelsMultiplier
+	LevelsRoleMultipliers         []LevelsMultiplier
+	LevelsCooldownSeconds         int
 
 	TroublemakerIsParticipating bool
 	TroublemakerLogChannel      string
@@ -54,10 +60,13 @@ type Config struct {
 	StarboardMinimum   int
 	StarboardEmoji     []string
 
-	ChatlogDisabled bool
+	ChatlogDisabled      bool
+	ChatlogRetentionDays int // 0 keeps messages forever
 
-	EventlogDisabled   bool
-	EventlogChannelIDs []string
+	EventlogDisabled      bool
+	EventlogChannelIDs    []string
+	EventlogRules         []EventlogRule
+	EventlogRetentionDays int // 0 keeps eventlog entries forever
 
 	PersistencyBiasEnabled bool
 	PersistencyRoleIDs     []string
@@ -84,6 +93,30 @@ type InspectTriggersEnabled struct {
 	UserJoins                bool
 }
 
+type LevelsCurveType string
+
+const (
+	// LevelsCurveQuadratic requires (level / Factor)^2 EXP for a level, the default curve with a factor of 0.1
+	LevelsCurveQuadratic LevelsCurveType = "quadratic"
+	// LevelsCurveLinear requires level * Factor EXP for a level
+	LevelsCurveLinear LevelsCurveType = "linear"
+	// LevelsCurveTable requires Table[level - 1] EXP for a level
+	LevelsCurveTable LevelsCurveType = "table"
+)
+
+// LevelsCurve describes how much EXP is required for each level, an empty curve is the default quadratic curve
+type LevelsCurve struct {
+	Type   LevelsCurveType
+	Factor float64
+	Table  []int64
+}
+
+// LevelsMultiplier multiplies the EXP for messages in a channel, or by members with a role
+type LevelsMultiplier struct {
+	ID         string
+	Multiplier float64
+}
+
 type DelayedAutoRole struct {
 	RoleID string
 	Delay  time.Duration
@@ -19,7 +19,11 @@ type CustomCommandsEntry struct {
 	CreatedAt         time.Time
 	Triggered         int
 	Keyword           string
-	Content           string
+	Aliases           []string
+	Content           string // a template, see the customcommands plugin for the syntax
+	Cooldown          int    // seconds between two uses by the same user, 0 for no cooldown
+	AllowedRoleIDs    []string
+	AllowedChannelIDs []string
 	StorageObjectName string
 	StorageMimeType   string // deprecated
 	StorageHash       string // deprecated
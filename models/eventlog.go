@@ -45,6 +45,7 @@ const (
 	EventlogTypeRobyulLevelsRoleDelete              = "Robyul_Levels_Role_Delete"              // EventlogTargetTypeRole
 	EventlogTypeRobyulLevelsRoleGrant               = "Robyul_Levels_Role_Grant"               // EventlogTargetTypeUser
 	EventlogTypeRobyulLevelsRoleDeny                = "Robyul_Levels_Role_Deny"                // EventlogTargetTypeUser
+	EventlogTypeRobyulLevelsConfigUpdate            = "Robyul_Levels_Config_Update"            // EventlogTargetTypeGuild
 	EventlogTypeRobyulNotificationsChannelIgnore    = "Robyul_Notifications_Channel_Ignore"    // EventlogTargetTypeChannel
 	EventlogTypeRobyulVliveFeedAdd                  = "Robyul_Vlive_Feed_Add"                  // EventlogTargetTypeRobyulVliveFeed
 	EventlogTypeRobyulVliveFeedRemove               = "Robyul_Vlive_Feed_Remove"               // EventlogTargetTypeRobyulVliveFeed
@@ -58,6 +59,8 @@ const (
 	EventlogTypeRobyulRedditFeedUpdate              = "Robyul_Reddit_Feed_Update"              // EventlogTargetTypeRobyulRedditFeed
 	EventlogTypeRobyulFacebookFeedAdd               = "Robyul_Facebook_Feed_Add"               // EventlogTargetTypeRobyulFacebookFeed
 	EventlogTypeRobyulFacebookFeedRemove            = "Robyul_Facebook_Feed_Remove"            // EventlogTargetTypeRobyulFacebookFeed
+	EventlogTypeRobyulFeedsFeedAdd                  = "Robyul_Feeds_Feed_Add"                  // EventlogTargetTypeRobyulFeedsFeed
+	EventlogTypeRobyulFeedsFeedRemove               = "Robyul_Feeds_Feed_Remove"               // EventlogTargetTypeRobyulFeedsFeed
 	EventlogTypeRobyulCleanup                       = "Robyul_Cleanup"                         //
 	EventlogTypeRobyulMute                          = "Robyul_Mute"                            // EventlogTargetTypeUser
 	EventlogTypeRobyulUnmute                        = "Robyul_Unmute"                          // EventlogTargetTypeUser
@@ -117,6 +120,18 @@ const (
 	EventlogTypeRobyulTwitterFeedAdd                = "Robyul_Twitter_Feed_Add"                // EventlogTargetTypeRobyulTwitterFeed
 	EventlogTypeRobyulTwitterFeedRemove             = "Robyul_Twitter_Feed_Remove"             // EventlogTargetTypeRobyulTwitterFeed
 	EventlogTypeRobyulActionRevert                  = "Robyul_Action_Revert"                   // EventlogTargetTypeRobyulEventlogItem
+	EventlogTypeRobyulUnban                         = "Robyul_Unban"                           // EventlogTargetTypeUser
+	EventlogTypeRobyulTempRoleAdd                   = "Robyul_TempRole_Add"                    // EventlogTargetTypeUser
+	EventlogTypeRobyulTempRoleRemove                = "Robyul_TempRole_Remove"                 // EventlogTargetTypeUser
+	EventlogTypeRobyulBatchRolesDelete              = "Robyul_BatchRoles_Delete"               // EventlogTargetTypeRole
+	EventlogTypeRobyulModActionUpdate               = "Robyul_ModAction_Update"                // EventlogTargetTypeRobyulModAction
+	EventlogTypeRobyulWarn                          = "Robyul_Warn"                            // EventlogTargetTypeUser
+	EventlogTypeRobyulModCaseUpdate                 = "Robyul_ModCase_Update"                  // EventlogTargetTypeRobyulModCase
+	EventlogTypeRobyulModEscalationsUpdate          = "Robyul_ModEscalations_Update"           // EventlogTargetTypeGuild
+	EventlogTypeRobyulAutomodRuleAdd                = "Robyul_Automod_Rule_Add"                // EventlogTargetTypeRobyulAutomodRule
+	EventlogTypeRobyulAutomodRuleRemove             = "Robyul_Automod_Rule_Remove"             // EventlogTargetTypeRobyulAutomodRule
+	EventlogTypeRobyulAutomodRuleUpdate             = "Robyul_Automod_Rule_Update"             // EventlogTargetTypeRobyulAutomodRule
+	EventlogTypeRobyulAutomodAction                 = "Robyul_Automod_Action"                  // EventlogTargetTypeUser
 
 	EventlogTargetTypeRobyulBadge               = "robyul-badge"
 	EventlogTargetTypeRobyulVliveFeed           = "robyul-vlive-feed"
@@ -124,6 +139,7 @@ const (
 	EventlogTargetTypeRobyulInstagramFeed       = "robyul-instagram-feed"
 	EventlogTargetTypeRobyulRedditFeed          = "robyul-reddit-feed"
 	EventlogTargetTypeRobyulFacebookFeed        = "robyul-facebook-feed"
+	EventlogTargetTypeRobyulFeedsFeed           = "robyul-feeds-feed"
 	EventlogTargetTypeRobyulGallery             = "robyul-gallery"
 	EventlogTargetTypeRobyulMirror              = "robyul-mirror"
 	EventlogTargetTypeRobyulRandomPictureSource = "robyul-randompicture-source"
@@ -132,10 +148,35 @@ const (
 	EventlogTargetTypeRobyulPublicObject        = "robyul-public-object"
 	EventlogTargetTypeRobyulMirrorType          = "robyul-mirror-type"
 	EventlogTargetTypeRobyulEventlogItem        = "robyul-eventlog-item"
+	EventlogTargetTypeRobyulModAction           = "robyul-mod-action"
+	EventlogTargetTypeRobyulModCase             = "robyul-mod-case"
+	EventlogTargetTypeRobyulAutomodRule         = "robyul-automod-rule"
 
 	AuditLogBackfillRedisList = "robyul-discord:eventlog:auditlog-backfills:v2"
 )
 
+type EventlogRuleAction string
+
+const (
+	// EventlogRuleActionDrop does not post matching events to any eventlog channel, they are still stored
+	EventlogRuleActionDrop EventlogRuleAction = "drop"
+	// EventlogRuleActionRoute posts matching events in ChannelID instead of the eventlog channels
+	EventlogRuleActionRoute EventlogRuleAction = "route"
+)
+
+// EventlogRule drops or routes the eventlog messages of events, the first matching rule of a guild is applied
+// every filter that is set has to match, a filter matches if one of its values matches
+type EventlogRule struct {
+	Action      EventlogRuleAction
+	ChannelID   string
+	ActionTypes []string
+	TargetTypes []string
+	UserIDs     []string
+	RoleIDs     []string
+	ChannelIDs  []string
+	ChangeKeys  []string
+}
+
 type AuditLogBackfillType int
 
 const (
@@ -14,6 +14,7 @@ type FacebookEntry struct {
 	ChannelID   string
 	Username    string
 	PostedPosts []FacebookPostEntry
+	FeedOptions `bson:",inline"`
 }
 
 type FacebookPostEntry struct {
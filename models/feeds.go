@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+)
+
+const (
+	FeedsTable MongoDbCollection = "feeds"
+)
+
+// FeedEntry is a channel subscribed to a RSS, Atom or JSON Feed
+type FeedEntry struct {
+	ID            bson.ObjectId `bson:"_id,omitempty"`
+	GuildID       string
+	ChannelID     string
+	URL           string
+	Title         string
+	AddedByUserID string
+	AddedAt       time.Time
+	// PostedGUIDs are the GUIDs of the latest items, they are posted only once
+	PostedGUIDs []string
+	FeedOptions `bson:",inline"`
+}
+
+// FeedOptions are the options of an entry of any social feed, they are inlined into the entries
+type FeedOptions struct {
+	// EmbedCode replaces the default message of posts, see helpers.ParseEmbedCode
+	EmbedCode string
+	// MentionRoleID is mentioned above the posts
+	MentionRoleID string
+	// IncludeFilters are keywords or /regular expressions/, posts have to match one of them, if set
+	IncludeFilters []string
+	// ExcludeFilters are keywords or /regular expressions/, posts matching one of them are not posted
+	ExcludeFilters []string
+}
@@ -34,6 +34,7 @@ type InstagramEntry struct {
 	IsLive                bool
 	SendPostType          InstagramSendPostType
 	LastPostCheck         time.Time
+	FeedOptions           `bson:",inline"`
 }
 
 type InstagramPostEntry struct {
@@ -0,0 +1,94 @@
+package models
+
+import "github.com/bwmarrin/discordgo"
+
+type InteractionType int
+
+const (
+	InteractionTypePing               InteractionType = 1
+	InteractionTypeApplicationCommand InteractionType = 2
+)
+
+type InteractionResponseType int
+
+const (
+	InteractionResponseTypePong                     InteractionResponseType = 1
+	InteractionResponseTypeChannelMessageWithSource InteractionResponseType = 4
+)
+
+type InteractionCommandOptionType int
+
+const (
+	InteractionCommandOptionTypeSubCommand      InteractionCommandOptionType = 1
+	InteractionCommandOptionTypeSubCommandGroup InteractionCommandOptionType = 2
+	InteractionCommandOptionTypeString          InteractionCommandOptionType = 3
+	InteractionCommandOptionTypeInteger         InteractionCommandOptionType = 4
+	InteractionCommandOptionTypeBoolean         InteractionCommandOptionType = 5
+	InteractionCommandOptionTypeUser            InteractionCommandOptionType = 6
+	InteractionCommandOptionTypeChannel         InteractionCommandOptionType = 7
+	InteractionCommandOptionTypeRole            InteractionCommandOptionType = 8
+)
+
+const (
+	// InteractionResponseFlagEphemeral makes a response only visible to the invoking user
+	InteractionResponseFlagEphemeral = 1 << 6
+)
+
+// InteractionCommand describes the schema of a slash command a plugin reacts to,
+// Name has to match one of the commands returned by Commands()
+type InteractionCommand struct {
+	Name        string                     `json:"name"`
+	Description string                     `json:"description"`
+	Options     []InteractionCommandOption `json:"options,omitempty"`
+}
+
+type InteractionCommandOption struct {
+	Type        InteractionCommandOptionType     `json:"type"`
+	Name        string                           `json:"name"`
+	Description string                           `json:"description"`
+	Required    bool                             `json:"required,omitempty"`
+	Choices     []InteractionCommandOptionChoice `json:"choices,omitempty"`
+	Options     []InteractionCommandOption       `json:"options,omitempty"`
+}
+
+type InteractionCommandOptionChoice struct {
+	Name  string      `json:"name"`
+	Value interface{} `json:"value"`
+}
+
+// Interaction is an incoming interaction payload as sent by discord
+type Interaction struct {
+	ID            string            `json:"id"`
+	ApplicationID string            `json:"application_id"`
+	Type          InteractionType   `json:"type"`
+	Data          *InteractionData  `json:"data,omitempty"`
+	GuildID       string            `json:"guild_id,omitempty"`
+	ChannelID     string            `json:"channel_id,omitempty"`
+	Member        *discordgo.Member `json:"member,omitempty"`
+	User          *discordgo.User   `json:"user,omitempty"`
+	Token         string            `json:"token"`
+	Version       int               `json:"version"`
+}
+
+type InteractionData struct {
+	ID      string                  `json:"id"`
+	Name    string                  `json:"name"`
+	Options []InteractionDataOption `json:"options,omitempty"`
+}
+
+type InteractionDataOption struct {
+	Name    string                       `json:"name"`
+	Type    InteractionCommandOptionType `json:"type"`
+	Value   interface{}                  `json:"value,omitempty"`
+	Options []InteractionDataOption      `json:"options,omitempty"`
+}
+
+type InteractionResponse struct {
+	Type InteractionResponseType  `json:"type"`
+	Data *InteractionResponseData `json:"data,omitempty"`
+}
+
+type InteractionResponseData struct {
+	Content string `json:"content,omitempty"`
+	Flags   int    `json:"flags,omitempty"`
+}
@@ -15,4 +15,10 @@ type LevelsServerusersEntry struct {
 	UserID  string
 	GuildID string
 	Exp     int64
+	// ExpStreamID is the exp queue entry ID up to which all entries have been added to Exp
+	ExpStreamID string
+	// ExpAppliedIDs are the latest exp queue entry IDs after ExpStreamID added to Exp, they are not added again
+	ExpAppliedIDs []string `bson:",omitempty"`
+	// ExpVersion is increased with every update by the exp queue
+	ExpVersion int
 }
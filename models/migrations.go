@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+)
+
+const (
+	// MigrationsTable contains the applied migrations, by version
+	MigrationsTable MongoDbCollection = "migrations"
+	// MigrationsLockTable contains the lock held by the bot instance running migrations
+	MigrationsLockTable MongoDbCollection = "migrations_lock"
+)
+
+// MigrationEntry records an applied migration
+// Applying is set while the migration runs, it stays set if the migration has been interrupted
+type MigrationEntry struct {
+	ID        bson.ObjectId `bson:"_id,omitempty"`
+	Version   int
+	Name      string
+	AppliedAt time.Time
+	AppliedBy string
+	Applying  bool `bson:",omitempty"`
+}
+
+// MigrationLockEntry is the migrations lock, it is taken over by other instances once it expires
+type MigrationLockEntry struct {
+	ID        string `bson:"_id"`
+	Owner     string
+	LockedAt  time.Time
+	ExpiresAt time.Time
+}
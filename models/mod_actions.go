@@ -0,0 +1,57 @@
+package models
+
+import (
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+)
+
+const (
+	ModActionsTable MongoDbCollection = "mod_actions"
+)
+
+type ModActionType string
+
+const (
+	// ModActionTypeMute is a timed mute, the member is unmuted once it expires
+	ModActionTypeMute ModActionType = "mute"
+	// ModActionTypeBan is a temp-ban, the user is unbanned once it expires
+	ModActionTypeBan ModActionType = "ban"
+	// ModActionTypeRole is a temp-role, the role is removed from the member once it expires
+	ModActionTypeRole ModActionType = "role"
+	// ModActionTypeBatchRole is a role created by batch-roles, the role is deleted once it expires
+	ModActionTypeBatchRole ModActionType = "batch-role"
+)
+
+type ModActionEndReason string
+
+const (
+	ModActionEndReasonExpired ModActionEndReason = "expired"
+	// ModActionEndReasonCancelled is used if the expiry has been cancelled, the action stays in place
+	ModActionEndReasonCancelled ModActionEndReason = "cancelled"
+	// ModActionEndReasonReverted is used if the action has been reverted manually, for example by unmuting the member
+	ModActionEndReasonReverted ModActionEndReason = "reverted"
+	// ModActionEndReasonReplaced is used if a new action for the same target has been created
+	ModActionEndReasonReplaced ModActionEndReason = "replaced"
+	// ModActionEndReasonReconciled is used if the action does not exist on the guild anymore
+	ModActionEndReasonReconciled ModActionEndReason = "reconciled"
+)
+
+// ModActionEntry is a timed moderation action
+// RoleID is set for ModActionTypeRole and ModActionTypeBatchRole, UserID is empty for ModActionTypeBatchRole
+type ModActionEntry struct {
+	ID              bson.ObjectId `bson:"_id,omitempty"`
+	GuildID         string
+	UserID          string
+	RoleID          string
+	Type            ModActionType
+	Reason          string
+	CreatedByUserID string
+	CreatedAt       time.Time
+	ExpiresAt       time.Time
+	Active          bool
+	Attempts        int
+	EndedAt         time.Time
+	EndedByUserID   string
+	EndReason       ModActionEndReason
+}
@@ -0,0 +1,53 @@
+package models
+
+import (
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+)
+
+const (
+	ModCasesTable MongoDbCollection = "mod_cases"
+	// ModCaseCountersTable contains the last case ID of each guild, the guild ID is the _id
+	ModCaseCountersTable MongoDbCollection = "mod_case_counters"
+)
+
+type ModCaseType string
+
+const (
+	ModCaseTypeWarn ModCaseType = "warn"
+	// ModCaseTypeNote is only visible to moderators, the member is not notified
+	ModCaseTypeNote ModCaseType = "note"
+	ModCaseTypeMute ModCaseType = "mute"
+	ModCaseTypeKick ModCaseType = "kick"
+	ModCaseTypeBan  ModCaseType = "ban"
+)
+
+// ModCaseEntry is a moderation case, CaseID is sequential per guild
+// ExpiresAt is set for timed mutes and bans, Automatic is set for cases created by an escalation
+type ModCaseEntry struct {
+	ID              bson.ObjectId `bson:"_id,omitempty"`
+	GuildID         string
+	CaseID          int
+	Type            ModCaseType
+	UserID          string
+	ModeratorUserID string
+	Reason          string
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	ExpiresAt       time.Time
+	Automatic       bool
+}
+
+type ModCaseCounterEntry struct {
+	GuildID string `bson:"_id"`
+	LastID  int
+}
+
+// ModEscalation mutes, kicks or bans a member once they received Warns warnings
+// Duration is a tparse duration like 1h for mutes and bans, empty for permanent ones
+type ModEscalation struct {
+	Warns    int
+	Action   ModCaseType
+	Duration string
+}
@@ -1,17 +1,34 @@
 package models
 
 import (
+	"time"
+
 	"github.com/globalsign/mgo/bson"
 )
 
 const (
 	NotificationsTable                MongoDbCollection = "notifications"
 	NotificationsIgnoredChannelsTable MongoDbCollection = "notifications_ignored_channels"
+	NotificationsDigestTable          MongoDbCollection = "notifications_digest"
+)
+
+type NotificationsKeywordType string
+
+const (
+	// NotificationsKeywordTypeDefault matches the keyword surrounded by whitespace or punctuation
+	NotificationsKeywordTypeDefault NotificationsKeywordType = ""
+	// NotificationsKeywordTypePhrase matches the keyword as a whole phrase, words in scripts without spaces can be followed by other letters
+	NotificationsKeywordTypePhrase NotificationsKeywordType = "phrase"
+	// NotificationsKeywordTypeWildcard matches the keyword with * as any number and ? as one character other than whitespace
+	NotificationsKeywordTypeWildcard NotificationsKeywordType = "wildcard"
+	// NotificationsKeywordTypeRegex matches the keyword as case insensitive regular expression
+	NotificationsKeywordTypeRegex NotificationsKeywordType = "regex"
 )
 
 type NotificationsEntry struct {
 	ID                bson.ObjectId `bson:"_id,omitempty"`
 	Keyword           string
+	Type              NotificationsKeywordType
 	GuildID           string // can be "global" to affect every guild
 	UserID            string
 	Triggered         int
@@ -24,3 +41,38 @@ type NotificationsIgnoredChannelsEntry struct {
 	GuildID   string
 	ChannelID string
 }
+
+type NotificationsDigestReason string
+
+const (
+	// NotificationsDigestReasonDigest is used for notifications of users receiving digests
+	NotificationsDigestReasonDigest NotificationsDigestReason = "digest"
+	// NotificationsDigestReasonQuietHours is used for notifications during the quiet hours of a user
+	NotificationsDigestReasonQuietHours NotificationsDigestReason = "quiet_hours"
+	// NotificationsDigestReasonRateLimit is used for notifications of keywords or channels over the rate limit
+	NotificationsDigestReasonRateLimit NotificationsDigestReason = "rate_limit"
+)
+
+// NotificationsDigestEntry is a notification waiting to be sent in the next digest of a user
+type NotificationsDigestEntry struct {
+	ID              bson.ObjectId `bson:"_id,omitempty"`
+	UserID          string
+	GuildID         string
+	ChannelID       string
+	MessageID       string
+	AuthorUsername  string
+	Keywords        []string
+	Content         string
+	ContextMessages []NotificationsDigestMessage
+	Reason          NotificationsDigestReason
+	CreatedAt       time.Time
+	// ClaimID and ClaimedUntil are set while a digest with the notification is being sent
+	ClaimID      bson.ObjectId `bson:",omitempty"`
+	ClaimedUntil time.Time     `bson:",omitempty"`
+}
+
+type NotificationsDigestMessage struct {
+	AuthorUsername string
+	Content        string
+	CreatedAt      time.Time
+}
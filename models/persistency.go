@@ -4,6 +4,9 @@ import "github.com/globalsign/mgo/bson"
 
 const (
 	PersistencyRolesTable MongoDbCollection = "persistency_roles"
+	// MutedMembersTable contains the members muted before mutes were persisted with the persistency roles
+	// they have been moved out of the MutedMembers of the guild configs by migration 56
+	MutedMembersTable MongoDbCollection = "muted_members"
 )
 
 type PersistencyRolesEntry struct {
@@ -12,3 +15,9 @@ type PersistencyRolesEntry struct {
 	UserID  string
 	Roles   []string
 }
+
+type MutedMemberEntry struct {
+	ID      bson.ObjectId `bson:"_id,omitempty"`
+	GuildID string
+	UserID  string
+}
@@ -20,4 +20,5 @@ type RedditSubredditEntry struct {
 	AddedAt         time.Time
 	PostDelay       int
 	PostDirectLinks bool
+	FeedOptions     `bson:",inline"`
 }
@@ -1,9 +1,16 @@
 package models
 
-import "github.com/globalsign/mgo/bson"
+import (
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+)
 
 const (
-	RemindersTable MongoDbCollection = "reminders"
+	// RemindersTable contains the legacy reminders, grouped by user
+	// they are moved to ScheduledRemindersTable when the reminders plugin starts
+	RemindersTable          MongoDbCollection = "reminders"
+	ScheduledRemindersTable MongoDbCollection = "reminders_scheduled"
 )
 
 type RemindersEntry struct {
@@ -18,3 +25,35 @@ type RemindersReminderEntry struct {
 	GuildID   string
 	Timestamp int64
 }
+
+type ReminderDelivery string
+
+const (
+	ReminderDeliveryDM      ReminderDelivery = "dm"
+	ReminderDeliveryChannel ReminderDelivery = "channel"
+)
+
+// ScheduledReminderEntry is a single reminder
+// DueAt is the time the reminder will be delivered next, ScheduledAt the time of the next occurrence without snoozing
+// Recurrence is a tparse duration between occurrences, for example 1w, empty for reminders that are delivered once
+// reminders that are delivered once are kept for a while after their delivery so they can still be snoozed
+type ScheduledReminderEntry struct {
+	ID          bson.ObjectId `bson:"_id,omitempty"`
+	UserID      string
+	ChannelID   string
+	GuildID     string
+	Message     string
+	Delivery    ReminderDelivery
+	DueAt       time.Time
+	ScheduledAt time.Time
+	Recurrence  string
+	Timezone    string
+	CreatedAt   time.Time
+	Attempts    int
+	Delivered   bool
+	DeliveredAt time.Time
+	// ClaimedUntil is set while a scheduler delivers the reminder, other schedulers pick it up again once it expired
+	ClaimedUntil time.Time `bson:",omitempty"`
+	// LegacyID identifies reminders moved from the RemindersTable, so they are moved once
+	LegacyID string `bson:",omitempty"`
+}
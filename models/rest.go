@@ -127,8 +127,9 @@ type Rest_Status_Member struct {
 }
 
 type Rest_Ranking struct {
-	Ranks []Rest_Ranking_Rank_Item
-	Count int
+	Ranks      []Rest_Ranking_Rank_Item
+	Count      int
+	Pagination Rest_Pagination
 }
 
 type Rest_Ranking_Rank_Item struct {
@@ -233,12 +234,13 @@ type Rest_VanityInvite_Invite struct {
 }
 
 type Rest_Eventlog struct {
-	Channels []Rest_Channel
-	Users    []Rest_User
-	Roles    []Rest_Role
-	Entries  []Rest_Eventlog_Entry
-	Emoji    []Rest_Emoji
-	Guilds   []Rest_Guild
+	Channels   []Rest_Channel
+	Users      []Rest_User
+	Roles      []Rest_Role
+	Entries    []Rest_Eventlog_Entry
+	Emoji      []Rest_Emoji
+	Guilds     []Rest_Guild
+	Pagination Rest_Pagination
 }
 
 type Rest_Eventlog_Entry struct {
@@ -253,6 +255,11 @@ type Rest_Eventlog_Entry struct {
 	WaitingForData bool
 }
 
+type Rest_Eventlog_Export struct {
+	Link  string
+	Count int
+}
+
 type Rest_File struct {
 	FileType string
 	FileName string
@@ -265,7 +272,74 @@ type Rest_Background struct {
 	Tags []string
 }
 
+type Rest_Plugin_Status struct {
+	Name     string
+	Extended bool
+	Loaded   bool
+	Disabled bool
+	Failed   bool
+	Commands []string
+}
+
 const (
 	Redis_Key_Feature_Levels_Badges  = "robyul2-discord:feature:levels-badges:server:%s"
 	Redis_Key_Feature_RandomPictures = "robyul2-discord:feature:randompictures:server:%s"
 )
+
+type Rest_Mod_Cases struct {
+	Users []Rest_User
+	Cases []Rest_Mod_Case
+	Count int
+}
+
+type Rest_Mod_Case struct {
+	CaseID          int
+	Type            ModCaseType
+	UserID          string
+	ModeratorUserID string
+	Reason          string
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	ExpiresAt       time.Time
+	Automatic       bool
+}
+
+type Rest_ApiToken struct {
+	ID              string
+	Name            string
+	Scopes          []ApiTokenScope
+	GuildIDs        []string
+	CreatedByUserID string
+	CreatedAt       time.Time
+	ExpiresAt       time.Time
+	LastUsedAt      time.Time
+}
+
+// Rest_ApiToken_Created contains the token, it can not be retrieved again
+type Rest_ApiToken_Created struct {
+	Token    string
+	ApiToken Rest_ApiToken
+}
+
+type Rest_Session struct {
+	Token     string
+	UserID    string
+	ExpiresAt time.Time
+}
+
+// Rest_Error is the body of every error response
+type Rest_Error struct {
+	Error Rest_Error_Details
+}
+
+type Rest_Error_Details struct {
+	Status  int
+	Message string
+}
+
+// Rest_Pagination describes the page of a list, Page starts at 1
+type Rest_Pagination struct {
+	Page    int
+	PerPage int
+	Total   int
+}
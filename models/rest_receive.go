@@ -1,8 +1,27 @@
 package models
 
+import (
+	"time"
+)
+
 type Rest_Receive_SetSettings struct {
 	Strings []struct {
 		Key    string
 		Values []string
 	}
 }
+
+type Rest_Receive_ModCaseReason struct {
+	Reason string
+}
+
+type Rest_Receive_ApiToken struct {
+	Name      string
+	Scopes    []ApiTokenScope
+	GuildIDs  []string
+	ExpiresAt time.Time
+}
+
+type Rest_Receive_Session struct {
+	Code string
+}
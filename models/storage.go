@@ -7,7 +7,8 @@ import (
 )
 
 const (
-	StorageTable MongoDbCollection = "storage"
+	StorageTable      MongoDbCollection = "storage"
+	StorageBlobsTable MongoDbCollection = "storage_blobs"
 )
 
 type StorageEntry struct {
@@ -25,4 +26,17 @@ type StorageEntry struct {
 	Public         bool
 	Metadata       map[string]string
 	RetrievedCount int
+	ContentHash    string // the key of the StorageBlobEntry, empty for objects stored before deduplication
+}
+
+// StorageBlobEntry is the stored content of all StorageEntries with the same content hash
+type StorageBlobEntry struct {
+	ID          bson.ObjectId `bson:"_id,omitempty"`
+	ContentHash string        // the sha256 hash of the content, also the key in the storage backend
+	MimeType    string
+	Filesize    int // in bytes
+	References  int // the number of StorageEntries with this content
+	CreatedAt   time.Time
+	DeletedAt   time.Time `bson:",omitempty"` // set once the content isn't referenced anymore, the content is deleted later by the storage sweeper
+	Sweeping    bool      `bson:",omitempty"` // set while the storage sweeper deletes the content, the blob can't be referenced again meanwhile
 }
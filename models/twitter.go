@@ -19,10 +19,10 @@ type TwitterEntry struct {
 	AccountScreenName string
 	AccountID         string
 	PostedTweets      []TwitterTweetEntry
-	MentionRoleID     string
 	PostMode          TwitterPostMode
 	ExcludeRTs        bool
 	ExcludeMentions   bool
+	FeedOptions       `bson:",inline"`
 }
 
 type TwitterTweetEntry struct {
@@ -16,7 +16,7 @@ type VliveEntry struct {
 	PostedVOD      []VliveVideoInfo
 	PostedNotices  []VliveNoticeInfo
 	PostedCelebs   []VliveCelebInfo
-	MentionRoleID  string
+	FeedOptions    `bson:",inline"`
 }
 
 type VliveChannelInfo struct {
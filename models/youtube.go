@@ -19,6 +19,7 @@ type YoutubeChannelEntry struct {
 	YoutubeChannelID    string
 	YoutubeChannelName  string
 	YoutubePostedVideos []string
+	FeedOptions         `bson:",inline"`
 }
 
 type YoutubeQuota struct {
@@ -0,0 +1,354 @@
+package modules
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Seklfreak/Robyul2/cache"
+	"github.com/Seklfreak/Robyul2/helpers"
+	"github.com/Seklfreak/Robyul2/models"
+	"github.com/bwmarrin/discordgo"
+	"github.com/pkg/errors"
+)
+
+var (
+	commandCatalogue     map[string]*models.CommandDescriptor
+	commandAliases       map[string]string
+	commandCatalogueLock sync.RWMutex
+
+	commandArgumentUserRegex    = regexp.MustCompile(`^(<@!?[0-9]+>|[0-9]+)$`)
+	commandArgumentChannelRegex = regexp.MustCompile(`^(<#[0-9]+>|[0-9]+)$`)
+	commandArgumentRoleRegex    = regexp.MustCompile(`^(<@&[0-9]+>|[0-9]+)$`)
+)
+
+// initCommandCatalogue collects the command descriptors of all plugins implementing DescribedPlugin
+// has to be called after the plugin caches have been built
+func initCommandCatalogue() {
+	catalogue := make(map[string]*models.CommandDescriptor)
+	aliases := make(map[string]string)
+
+	collect := func(plugin BaseModule, pluginCommands []string) {
+		describedPlugin, ok := plugin.(DescribedPlugin)
+		if !ok {
+			return
+		}
+
+		t := helpers.Typeof(plugin)
+
+		for _, descriptor := range describedPlugin.CommandDescriptors() {
+			descriptor := descriptor
+
+			var reactsToCommand bool
+			for _, pluginCommand := range pluginCommands {
+				if pluginCommand == descriptor.Name {
+					reactsToCommand = true
+					break
+				}
+			}
+			if !reactsToCommand {
+				cache.GetLogger().WithField("module", "modules").Warnf(
+					"skipping command descriptor %s of %s, plugin does not react to this command",
+					descriptor.Name, t,
+				)
+				continue
+			}
+			if _, ok := catalogue[descriptor.Name]; ok {
+				cache.GetLogger().WithField("module", "modules").Warnf(
+					"skipping command descriptor %s of %s, command has already been described",
+					descriptor.Name, t,
+				)
+				continue
+			}
+
+			if descriptor.Module != 0 {
+				descriptor.ModuleName = helpers.GetModuleNameById(descriptor.Module)
+			}
+
+			if err := checkCommandAliases(descriptor, aliases, plugin); err != nil {
+				cache.GetLogger().WithField("module", "modules").Warnf(
+					"skipping command descriptor %s of %s, %s", descriptor.Name, t, err.Error(),
+				)
+				continue
+			}
+			for _, alias := range descriptor.Aliases {
+				aliases[alias] = descriptor.Name
+			}
+
+			catalogue[descriptor.Name] = &descriptor
+		}
+	}
+
+	forEachLoadedPlugin(collect)
+
+	commandCatalogueLock.Lock()
+	commandCatalogue = catalogue
+	commandAliases = aliases
+	commandCatalogueLock.Unlock()
+
+	cache.GetLogger().WithField("module", "modules").Infof(
+		"collected %d command descriptors with %d aliases", len(catalogue), len(aliases),
+	)
+}
+
+// checkCommandAliases returns an error if an alias of the descriptor is already registered or is a command of another plugin
+func checkCommandAliases(descriptor models.CommandDescriptor, aliases map[string]string, plugin BaseModule) (err error) {
+	for _, alias := range descriptor.Aliases {
+		if occupant, ok := aliases[alias]; ok {
+			return errors.New("alias " + alias + " has already been registered by " + occupant)
+		}
+		if !commandBelongsToPlugin(alias, plugin) && isPluginCommand(alias) {
+			return errors.New("alias " + alias + " is already a command")
+		}
+	}
+	return nil
+}
+
+func isPluginCommand(command string) bool {
+	pluginCacheLock.RLock()
+	defer pluginCacheLock.RUnlock()
+
+	if _, ok := pluginCache[command]; ok {
+		return true
+	}
+	_, ok := extendedPluginCache[command]
+	return ok
+}
+
+func commandBelongsToPlugin(command string, plugin BaseModule) bool {
+	pluginCacheLock.RLock()
+	defer pluginCacheLock.RUnlock()
+
+	if ref, ok := pluginCache[command]; ok && BaseModule(*ref) == plugin {
+		return true
+	}
+	if ref, ok := extendedPluginCache[command]; ok && BaseModule(*ref) == plugin {
+		return true
+	}
+	return false
+}
+
+// resolveCommandAlias returns the command an alias points to
+// commands plugins react to directly are returned unchanged
+func resolveCommandAlias(command string) string {
+	if isPluginCommand(command) {
+		return command
+	}
+
+	commandCatalogueLock.RLock()
+	defer commandCatalogueLock.RUnlock()
+
+	if name, ok := commandAliases[command]; ok {
+		return name
+	}
+	return command
+}
+
+// GetCommandDescriptor returns the descriptor of a command or alias, nil if it has not been described
+func GetCommandDescriptor(command string) *models.CommandDescriptor {
+	commandCatalogueLock.RLock()
+	defer commandCatalogueLock.RUnlock()
+
+	if name, ok := commandAliases[command]; ok {
+		command = name
+	}
+
+	return commandCatalogue[command]
+}
+
+// GetCommandDescriptors returns all described commands sorted by module and name
+func GetCommandDescriptors() (descriptors []models.CommandDescriptor) {
+	commandCatalogueLock.RLock()
+	descriptors = make([]models.CommandDescriptor, 0, len(commandCatalogue))
+	for _, descriptor := range commandCatalogue {
+		descriptors = append(descriptors, *descriptor)
+	}
+	commandCatalogueLock.RUnlock()
+
+	sort.Slice(descriptors, func(i, j int) bool {
+		if descriptors[i].ModuleName != descriptors[j].ModuleName {
+			return descriptors[i].ModuleName < descriptors[j].ModuleName
+		}
+		return descriptors[i].Name < descriptors[j].Name
+	})
+
+	return descriptors
+}
+
+// requireCommandPermission only calls $cb if the author has the permission the descriptor requires
+func requireCommandPermission(msg *discordgo.Message, descriptor *models.CommandDescriptor, cb helpers.Callback) {
+	switch descriptor.Permission {
+	case models.CommandPermissionEveryone:
+		cb()
+	case models.CommandPermissionMod:
+		helpers.RequireMod(msg, cb)
+	case models.CommandPermissionAdmin:
+		helpers.RequireAdmin(msg, cb)
+	case models.CommandPermissionRobyulMod:
+		helpers.RequireRobyulMod(msg, cb)
+	case models.CommandPermissionBotAdmin:
+		helpers.RequireBotAdmin(msg, cb)
+	default:
+		cache.GetLogger().WithField("module", "modules").Warnf(
+			"refusing command %s, unknown permission %s", descriptor.Name, descriptor.Permission,
+		)
+	}
+}
+
+// ValidateCommandArguments checks the content of a command against the arguments of its descriptor
+func ValidateCommandArguments(descriptor *models.CommandDescriptor, content string) (err error) {
+	args := strings.Fields(content)
+
+	for i, argument := range descriptor.Arguments {
+		if i >= len(args) {
+			if argument.Required {
+				return errors.New("missing argument " + argument.Name)
+			}
+			return nil
+		}
+
+		if argument.Variadic {
+			return nil
+		}
+
+		if !commandArgumentHasType(args[i], argument.Type) {
+			return errors.New("invalid argument " + argument.Name)
+		}
+	}
+
+	return nil
+}
+
+func commandArgumentHasType(arg string, argumentType models.CommandArgumentType) bool {
+	switch argumentType {
+	case models.CommandArgumentTypeNumber:
+		_, err := strconv.ParseFloat(arg, 64)
+		return err == nil
+	case models.CommandArgumentTypeUser:
+		return commandArgumentUserRegex.MatchString(arg)
+	case models.CommandArgumentTypeChannel:
+		return commandArgumentChannelRegex.MatchString(arg)
+	case models.CommandArgumentTypeRole:
+		return commandArgumentRoleRegex.MatchString(arg)
+	}
+	return true
+}
+
+// SendCommandHelp sends the help embed for a command or alias, returns false if the command has not been described
+func SendCommandHelp(channelID, guildID, command string) bool {
+	descriptor := GetCommandDescriptor(command)
+	if descriptor == nil {
+		return false
+	}
+
+	prefix := helpers.GetPrefixForServer(guildID)
+
+	helpEmbed := &discordgo.MessageEmbed{
+		Title:       helpers.GetTextF("bot.commands.help-embed-title", prefix+descriptor.Name),
+		Description: descriptor.Description,
+		Color:       0x0FADED,
+		Fields: []*discordgo.MessageEmbedField{
+			{
+				Name:  helpers.GetText("bot.commands.help-embed-field-usage"),
+				Value: "`" + strings.TrimSpace(prefix+descriptor.Name+" "+descriptor.Usage) + "`",
+			},
+		},
+	}
+
+	if len(descriptor.Aliases) > 0 {
+		helpEmbed.Fields = append(helpEmbed.Fields, &discordgo.MessageEmbedField{
+			Name:   helpers.GetText("bot.commands.help-embed-field-aliases"),
+			Value:  "`" + strings.Join(descriptor.Aliases, "`, `") + "`",
+			Inline: true,
+		})
+	}
+	if descriptor.Permission != models.CommandPermissionEveryone {
+		helpEmbed.Fields = append(helpEmbed.Fields, &discordgo.MessageEmbedField{
+			Name:   helpers.GetText("bot.commands.help-embed-field-permission"),
+			Value:  helpers.GetText("bot.commands.permissions." + string(descriptor.Permission)),
+			Inline: true,
+		})
+	}
+	if descriptor.ModuleName != "" {
+		helpEmbed.Fields = append(helpEmbed.Fields, &discordgo.MessageEmbedField{
+			Name:   helpers.GetText("bot.commands.help-embed-field-module"),
+			Value:  "`" + descriptor.ModuleName + "`",
+			Inline: true,
+		})
+	}
+	for _, argument := range descriptor.Arguments {
+		name := argument.Name
+		if !argument.Required {
+			name += " " + helpers.GetText("bot.commands.help-embed-optional")
+		}
+		helpEmbed.Fields = append(helpEmbed.Fields, &discordgo.MessageEmbedField{
+			Name:  name,
+			Value: argument.Description,
+		})
+	}
+
+	_, err := helpers.SendEmbed(channelID, helpEmbed)
+	helpers.RelaxLog(err)
+	return true
+}
+
+// sendCommandUsage tells the author how to use a command after the arguments failed validation
+func sendCommandUsage(msg *discordgo.Message, descriptor *models.CommandDescriptor) {
+	channel, err := helpers.GetChannelWithoutApi(msg.ChannelID)
+	if err != nil {
+		return
+	}
+
+	prefix := helpers.GetPrefixForServer(channel.GuildID)
+
+	_, err = helpers.SendMessage(msg.ChannelID, helpers.GetTextF("bot.commands.usage",
+		strings.TrimSpace(prefix+descriptor.Name+" "+descriptor.Usage), prefix, descriptor.Name))
+	helpers.RelaxLog(err)
+}
+
+// GetCommandReferenceMarkdown renders all described commands as a markdown document
+func GetCommandReferenceMarkdown() string {
+	var buffer bytes.Buffer
+
+	buffer.WriteString("# Commands\n")
+
+	var lastModuleName string
+	for i, descriptor := range GetCommandDescriptors() {
+		if i == 0 || descriptor.ModuleName != lastModuleName {
+			moduleName := descriptor.ModuleName
+			if moduleName == "" {
+				moduleName = "other"
+			}
+			buffer.WriteString(fmt.Sprintf("\n## %s\n", moduleName))
+			lastModuleName = descriptor.ModuleName
+		}
+
+		buffer.WriteString(fmt.Sprintf("\n### `%s`\n\n", descriptor.Name))
+		if descriptor.Description != "" {
+			buffer.WriteString(descriptor.Description + "\n\n")
+		}
+		buffer.WriteString(fmt.Sprintf("Usage: `%s`\n", strings.TrimSpace(descriptor.Name+" "+descriptor.Usage)))
+		if len(descriptor.Aliases) > 0 {
+			buffer.WriteString(fmt.Sprintf("\nAliases: `%s`\n", strings.Join(descriptor.Aliases, "`, `")))
+		}
+		if descriptor.Permission != models.CommandPermissionEveryone {
+			buffer.WriteString(fmt.Sprintf("\nPermission: %s\n", descriptor.Permission))
+		}
+		if len(descriptor.Arguments) > 0 {
+			buffer.WriteString("\n")
+			for _, argument := range descriptor.Arguments {
+				optional := ""
+				if !argument.Required {
+					optional = ", optional"
+				}
+				buffer.WriteString(fmt.Sprintf("- `%s` (%s%s): %s\n", argument.Name, argument.Type, optional, argument.Description))
+			}
+		}
+	}
+
+	return buffer.String()
+}
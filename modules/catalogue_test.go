@@ -0,0 +1,28 @@
+package modules
+
+import (
+	"testing"
+
+	"github.com/Seklfreak/Robyul2/models"
+)
+
+func TestCheckCommandAliases(t *testing.T) {
+	aliases := map[string]string{"pick": "choose"}
+
+	tests := []struct {
+		aliases []string
+		wantErr bool
+	}{
+		{[]string{"ud", "urban"}, false},
+		{[]string{"ud", "pick"}, true},
+		{nil, false},
+	}
+
+	for _, test := range tests {
+		descriptor := models.CommandDescriptor{Name: "test", Aliases: test.aliases}
+		err := checkCommandAliases(descriptor, aliases, nil)
+		if (err != nil) != test.wantErr {
+			t.Errorf("modules.checkCommandAliases(%v) returned %v, want error: %v", test.aliases, err, test.wantErr)
+		}
+	}
+}
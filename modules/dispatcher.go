@@ -0,0 +1,263 @@
+package modules
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Seklfreak/Robyul2/cache"
+	"github.com/Seklfreak/Robyul2/helpers"
+	"github.com/Seklfreak/Robyul2/metrics"
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	defaultExtendedPluginWorkers   = 4
+	defaultExtendedPluginQueueSize = 1000
+	defaultExtendedPluginTimeout   = 30 * time.Second
+)
+
+type extendedPluginEvent struct {
+	name string
+	// key orders the events, events with the same key are handled in the order they have been dispatched
+	key  string
+	call func(plugin ExtendedPlugin, session *discordgo.Session)
+}
+
+// extendedPluginDispatcher delivers events to a single extended plugin on its own bounded worker pool,
+// so a slow or panicking plugin can not delay or drop events for any other plugin
+// every worker has its own queue, events are assigned to the queues by their key, so the events of a guild stay in order
+type extendedPluginDispatcher struct {
+	plugin     ExtendedPlugin
+	name       string
+	queues     []chan extendedPluginEvent
+	timeout    time.Duration
+	getSession func() *discordgo.Session
+	// inFlight limits the running handlers, including handlers which exceeded the timeout and are still running
+	inFlight chan bool
+	workers  sync.WaitGroup
+}
+
+var (
+	extendedPluginDispatchers     = make(map[string]*extendedPluginDispatcher)
+	extendedPluginDispatchersLock sync.RWMutex
+)
+
+// getPluginName returns the name used for metrics, config and management of a plugin, for example levels.Levels
+func getPluginName(plugin BaseModule) string {
+	return strings.TrimPrefix(fmt.Sprintf("%T", plugin), "*")
+}
+
+func getExtendedPluginDispatcherConfig(key string, defaultValue float64) float64 {
+	config := helpers.GetConfig()
+	if config == nil || !config.Exists("modules", "extended_plugins", key) {
+		return defaultValue
+	}
+
+	if value, ok := config.Search("modules", "extended_plugins", key).Data().(float64); ok && value > 0 {
+		return value
+	}
+	return defaultValue
+}
+
+func getExtendedPluginTimeout(name string) time.Duration {
+	timeout := time.Duration(getExtendedPluginDispatcherConfig("timeout_seconds", defaultExtendedPluginTimeout.Seconds()) * float64(time.Second))
+
+	config := helpers.GetConfig()
+	if config != nil && config.Exists("modules", "extended_plugins", "timeouts", name) {
+		if value, ok := config.Search("modules", "extended_plugins", "timeouts", name).Data().(float64); ok && value > 0 {
+			timeout = time.Duration(value * float64(time.Second))
+		}
+	}
+
+	return timeout
+}
+
+func newExtendedPluginDispatcher(plugin ExtendedPlugin) *extendedPluginDispatcher {
+	name := getPluginName(plugin)
+	workers := int(getExtendedPluginDispatcherConfig("workers", defaultExtendedPluginWorkers))
+
+	return newExtendedPluginDispatcherWithLimits(plugin, name,
+		workers,
+		int(getExtendedPluginDispatcherConfig("queue_size", defaultExtendedPluginQueueSize)),
+		int(getExtendedPluginDispatcherConfig("max_in_flight", float64(2*workers))),
+		getExtendedPluginTimeout(name),
+	)
+}
+
+// newExtendedPluginDispatcherWithLimits splits the queue size between the workers
+func newExtendedPluginDispatcherWithLimits(plugin ExtendedPlugin, name string, workers, queueSize, maxInFlight int, timeout time.Duration) *extendedPluginDispatcher {
+	if workers < 1 {
+		workers = 1
+	}
+	if maxInFlight < workers {
+		maxInFlight = workers
+	}
+	workerQueueSize := queueSize / workers
+	if workerQueueSize < 1 {
+		workerQueueSize = 1
+	}
+
+	d := &extendedPluginDispatcher{
+		plugin:     plugin,
+		name:       name,
+		queues:     make([]chan extendedPluginEvent, workers),
+		timeout:    timeout,
+		getSession: cache.GetSession,
+		inFlight:   make(chan bool, maxInFlight),
+	}
+	for i := range d.queues {
+		d.queues[i] = make(chan extendedPluginEvent, workerQueueSize)
+	}
+	return d
+}
+
+func (d *extendedPluginDispatcher) start() {
+	for _, queue := range d.queues {
+		d.workers.Add(1)
+		go d.work(queue)
+	}
+}
+
+// stop closes the queues and waits for the workers to finish the queued events, or the timeout to pass
+func (d *extendedPluginDispatcher) stop() {
+	for _, queue := range d.queues {
+		close(queue)
+	}
+
+	finished := make(chan bool, 1)
+	go func() {
+		d.workers.Wait()
+		finished <- true
+	}()
+
+	select {
+	case <-finished:
+	case <-time.After(d.timeout):
+		cache.GetLogger().WithField("module", "modules").Warnf(
+			"stopped waiting for %s to finish its queued events after %s", d.name, d.timeout,
+		)
+	}
+}
+
+// dispatch queues an event on the queue of its key, it returns false if the queue is full
+func (d *extendedPluginDispatcher) dispatch(event extendedPluginEvent) bool {
+	hash := fnv.New32a()
+	hash.Write([]byte(event.key))
+
+	select {
+	case d.queues[hash.Sum32()%uint32(len(d.queues))] <- event:
+		return true
+	default:
+		return false
+	}
+}
+
+func (d *extendedPluginDispatcher) work(queue chan extendedPluginEvent) {
+	defer d.workers.Done()
+
+	for event := range queue {
+		d.handle(event)
+	}
+}
+
+// handle runs a single event with a panic boundary and records its latency
+// if the event takes longer than the timeout the worker stops waiting for it and continues with the next event,
+// the handler keeps its in flight slot until it returns, so stuck handlers block the workers instead of piling up
+func (d *extendedPluginDispatcher) handle(event extendedPluginEvent) {
+	d.inFlight <- true
+
+	started := time.Now()
+	done := make(chan bool, 1)
+
+	go func() {
+		defer func() {
+			<-d.inFlight
+			done <- true
+		}()
+		defer helpers.Recover()
+		defer func() {
+			if err := recover(); err != nil {
+				metrics.ExtendedPluginErrors.Add(d.name, 1)
+				panic(err)
+			}
+		}()
+
+		event.call(d.plugin, d.getSession())
+	}()
+
+	select {
+	case <-done:
+		metrics.ExtendedPluginEventsHandled.Add(d.name, 1)
+		metrics.ExtendedPluginLatencySeconds.AddFloat(d.name, time.Since(started).Seconds())
+	case <-time.After(d.timeout):
+		metrics.ExtendedPluginTimeouts.Add(d.name, 1)
+		cache.GetLogger().WithField("module", "modules").Warnf(
+			"%s took longer than %s to handle %s", d.name, d.timeout, event.name,
+		)
+	}
+}
+
+// startExtendedPluginDispatcher starts the dispatcher of an extended plugin, events are delivered from now on
+func startExtendedPluginDispatcher(plugin ExtendedPlugin) {
+	dispatcher := newExtendedPluginDispatcher(plugin)
+	dispatcher.start()
+
+	extendedPluginDispatchersLock.Lock()
+	previous := extendedPluginDispatchers[dispatcher.name]
+	extendedPluginDispatchers[dispatcher.name] = dispatcher
+	extendedPluginDispatchersLock.Unlock()
+
+	if previous != nil {
+		previous.stop()
+	}
+}
+
+// stopExtendedPluginDispatcher stops accepting events for an extended plugin and waits for its queued events to be handled
+func stopExtendedPluginDispatcher(name string) {
+	extendedPluginDispatchersLock.Lock()
+	dispatcher := extendedPluginDispatchers[name]
+	delete(extendedPluginDispatchers, name)
+	extendedPluginDispatchersLock.Unlock()
+
+	if dispatcher != nil {
+		dispatcher.stop()
+	}
+}
+
+// stopExtendedPluginDispatchers stops all dispatchers at once
+func stopExtendedPluginDispatchers() {
+	extendedPluginDispatchersLock.Lock()
+	dispatchers := extendedPluginDispatchers
+	extendedPluginDispatchers = make(map[string]*extendedPluginDispatcher)
+	extendedPluginDispatchersLock.Unlock()
+
+	var wg sync.WaitGroup
+	for _, dispatcher := range dispatchers {
+		wg.Add(1)
+		go func(dispatcher *extendedPluginDispatcher) {
+			defer wg.Done()
+
+			dispatcher.stop()
+		}(dispatcher)
+	}
+	wg.Wait()
+}
+
+// dispatchExtendedPluginEvent queues an event for every extended plugin
+// key is the guild ID, or the channel ID if there is no guild, events with the same key are handled in order
+// events for plugins with a full queue are dropped instead of blocking the other plugins
+func dispatchExtendedPluginEvent(name, key string, call func(plugin ExtendedPlugin, session *discordgo.Session)) {
+	event := extendedPluginEvent{name: name, key: key, call: call}
+
+	extendedPluginDispatchersLock.RLock()
+	defer extendedPluginDispatchersLock.RUnlock()
+
+	for _, dispatcher := range extendedPluginDispatchers {
+		if !dispatcher.dispatch(event) {
+			metrics.ExtendedPluginEventsDropped.Add(dispatcher.name, 1)
+		}
+	}
+}
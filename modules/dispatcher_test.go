@@ -0,0 +1,113 @@
+package modules
+
+import (
+	"expvar"
+	"io/ioutil"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Seklfreak/Robyul2/cache"
+	"github.com/Seklfreak/Robyul2/metrics"
+	"github.com/bwmarrin/discordgo"
+	"github.com/sirupsen/logrus"
+)
+
+func init() {
+	logger := logrus.New()
+	logger.Out = ioutil.Discard
+	cache.SetLogger(logger)
+}
+
+func newTestDispatcher(name string, workers, maxInFlight int, timeout time.Duration) *extendedPluginDispatcher {
+	d := newExtendedPluginDispatcherWithLimits(nil, name, workers, 1000, maxInFlight, timeout)
+	d.getSession = func() *discordgo.Session { return nil }
+	d.start()
+	return d
+}
+
+func getMetric(m *expvar.Map, name string) int64 {
+	if value, ok := m.Get(name).(*expvar.Int); ok {
+		return value.Value()
+	}
+	return 0
+}
+
+func TestDispatcherKeepsOrderPerKey(t *testing.T) {
+	d := newTestDispatcher("test.Order", 4, 4, time.Second)
+	handledBefore := getMetric(metrics.ExtendedPluginEventsHandled, "test.Order")
+
+	var lock sync.Mutex
+	handled := make(map[string][]int)
+	for i := 0; i < 50; i++ {
+		for _, key := range []string{"guild-a", "guild-b", "guild-c"} {
+			key, i := key, i
+			if !d.dispatch(extendedPluginEvent{name: "test", key: key, call: func(plugin ExtendedPlugin, session *discordgo.Session) {
+				lock.Lock()
+				handled[key] = append(handled[key], i)
+				lock.Unlock()
+			}}) {
+				t.Fatalf("extendedPluginDispatcher.dispatch() dropped event %d of %s", i, key)
+			}
+		}
+	}
+	d.stop()
+
+	for key, order := range handled {
+		if len(order) != 50 {
+			t.Errorf("extendedPluginDispatcher handled %d events of %s, expected 50", len(order), key)
+		}
+		for i, value := range order {
+			if value != i {
+				t.Errorf("extendedPluginDispatcher handled the events of %s out of order: %v", key, order)
+				break
+			}
+		}
+	}
+	if handledMetric := getMetric(metrics.ExtendedPluginEventsHandled, "test.Order") - handledBefore; handledMetric != 150 {
+		t.Errorf("extendedPluginDispatcher counted %d handled events, expected 150", handledMetric)
+	}
+}
+
+func TestDispatcherLimitsTimedOutHandlers(t *testing.T) {
+	d := newTestDispatcher("test.Timeout", 2, 2, 10*time.Millisecond)
+	handledBefore := getMetric(metrics.ExtendedPluginEventsHandled, "test.Timeout")
+	timeoutsBefore := getMetric(metrics.ExtendedPluginTimeouts, "test.Timeout")
+
+	release := make(chan bool)
+	var lock sync.Mutex
+	var running, maxRunning int
+	for i := 0; i < 6; i++ {
+		d.dispatch(extendedPluginEvent{name: "test", key: strconv.Itoa(i), call: func(plugin ExtendedPlugin, session *discordgo.Session) {
+			lock.Lock()
+			running++
+			if running > maxRunning {
+				maxRunning = running
+			}
+			lock.Unlock()
+
+			<-release
+
+			lock.Lock()
+			running--
+			lock.Unlock()
+		}})
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	d.stop()
+	d.workers.Wait()
+
+	if maxRunning > 2 {
+		t.Errorf("extendedPluginDispatcher ran %d handlers at once, expected at most 2", maxRunning)
+	}
+	timeouts := getMetric(metrics.ExtendedPluginTimeouts, "test.Timeout") - timeoutsBefore
+	if timeouts < 2 {
+		t.Errorf("extendedPluginDispatcher counted %d timeouts, expected at least 2", timeouts)
+	}
+	if handled := getMetric(metrics.ExtendedPluginEventsHandled, "test.Timeout") - handledBefore; handled+timeouts != 6 {
+		t.Errorf("extendedPluginDispatcher counted %d handled events, timed out events were counted as handled", handled)
+	}
+}
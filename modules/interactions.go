@@ -0,0 +1,290 @@
+package modules
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Seklfreak/Robyul2/cache"
+	"github.com/Seklfreak/Robyul2/helpers"
+	"github.com/Seklfreak/Robyul2/models"
+	"github.com/Seklfreak/Robyul2/ratelimits"
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	interactionCommandsEndpoint = "https://discord.com/api/v8/applications/%s/commands"
+)
+
+var (
+	interactionCommands     map[string]models.InteractionCommand
+	interactionCommandsLock sync.RWMutex
+)
+
+// initInteractions collects the slash command schemas of all plugins implementing InteractionPlugin
+// has to be called after the plugin caches have been built
+func initInteractions() {
+	commands := make(map[string]models.InteractionCommand)
+
+	collect := func(plugin BaseModule, pluginCommands []string) {
+		interactionPlugin, ok := plugin.(InteractionPlugin)
+		if !ok {
+			return
+		}
+
+		for _, command := range interactionPlugin.InteractionCommands() {
+			var reactsToCommand bool
+			for _, pluginCommand := range pluginCommands {
+				if pluginCommand == command.Name {
+					reactsToCommand = true
+					break
+				}
+			}
+			if !reactsToCommand {
+				cache.GetLogger().WithField("module", "modules").Warnf(
+					"skipping interaction command %s of %s, plugin does not react to this command",
+					command.Name, helpers.Typeof(plugin),
+				)
+				continue
+			}
+			if _, ok := commands[command.Name]; ok {
+				cache.GetLogger().WithField("module", "modules").Warnf(
+					"skipping interaction command %s of %s, command has already been registered",
+					command.Name, helpers.Typeof(plugin),
+				)
+				continue
+			}
+
+			commands[command.Name] = command
+		}
+	}
+
+	forEachLoadedPlugin(collect)
+
+	interactionCommandsLock.Lock()
+	interactionCommands = commands
+	interactionCommandsLock.Unlock()
+
+	cache.GetLogger().WithField("module", "modules").Infof(
+		"collected %d interaction commands", len(commands),
+	)
+}
+
+// GetInteractionCommands returns the schemas of all registered slash commands
+func GetInteractionCommands() (commands []models.InteractionCommand) {
+	interactionCommandsLock.RLock()
+	defer interactionCommandsLock.RUnlock()
+
+	commands = make([]models.InteractionCommand, 0, len(interactionCommands))
+	for _, command := range interactionCommands {
+		commands = append(commands, command)
+	}
+
+	return commands
+}
+
+// RegisterInteractionCommands overwrites the global slash commands of the application with all collected schemas
+func RegisterInteractionCommands(session *discordgo.Session) (err error) {
+	endpoint := fmt.Sprintf(interactionCommandsEndpoint, helpers.GetConfig().Path("discord.id").Data().(string))
+
+	_, err = session.RequestWithBucketID("PUT", endpoint, GetInteractionCommands(), endpoint)
+	return err
+}
+
+// InteractionsEnabled returns true if a public key to verify interactions has been configured
+func InteractionsEnabled() bool {
+	return helpers.GetConfig().ExistsP("discord.public_key") &&
+		helpers.GetConfig().Path("discord.public_key").Data().(string) != ""
+}
+
+// CallInteraction routes an incoming interaction to the plugin reacting to the command
+// the plugin is called asynchronously, the returned response acknowledges the interaction
+func CallInteraction(interaction *models.Interaction) (response *models.InteractionResponse) {
+	if interaction.Type == models.InteractionTypePing {
+		return &models.InteractionResponse{Type: models.InteractionResponseTypePong}
+	}
+
+	if interaction.Type != models.InteractionTypeApplicationCommand || interaction.Data == nil {
+		return newEphemeralInteractionResponse(helpers.GetText("bot.arguments.invalid"))
+	}
+
+	if interaction.GuildID == "" || interaction.Member == nil || interaction.Member.User == nil {
+		return newEphemeralInteractionResponse(helpers.GetText("dm.commands"))
+	}
+	author := interaction.Member.User
+	interaction.Member.GuildID = interaction.GuildID
+
+	if author.Bot || helpers.IsBlacklisted(author.ID) || helpers.IsBlacklistedGuild(interaction.GuildID) {
+		return newEphemeralInteractionResponse(helpers.GetText("bot.interactions.not-allowed"))
+	}
+
+	interactionCommandsLock.RLock()
+	command, ok := interactionCommands[interaction.Data.Name]
+	interactionCommandsLock.RUnlock()
+	if !ok {
+		return newEphemeralInteractionResponse(helpers.GetText("bot.interactions.unknown-command"))
+	}
+
+	arguments, err := ParseInteractionArguments(command.Options, interaction.Data.Options)
+	if err != nil {
+		return newEphemeralInteractionResponse(helpers.GetTextF("bot.interactions.invalid-arguments", err.Error()))
+	}
+
+	if !ratelimits.Container.HasKeys(author.ID) && !helpers.IsBotAdmin(author.ID) {
+		ratelimits.Container.Set(author.ID, -1)
+		return newEphemeralInteractionResponse(helpers.GetTextF("bot.ratelimit.hit", author.ID))
+	}
+
+	content := strings.Join(arguments, " ")
+
+	msg := &discordgo.Message{
+		ChannelID: interaction.ChannelID,
+		GuildID:   interaction.GuildID,
+		Content:   "/" + command.Name + " " + content,
+		Timestamp: discordgo.Timestamp(time.Now().Format(time.RFC3339)),
+		Author:    author,
+	}
+
+	cache.GetLogger().WithField("module", "modules").Debugf(
+		"%s (#%s): %s (interaction)", author.Username, author.ID, msg.Content,
+	)
+
+	go CallBotPlugin(command.Name, content, msg)
+
+	return newEphemeralInteractionResponse(helpers.GetTextF("bot.interactions.received", msg.Content))
+}
+
+// ParseInteractionArguments validates the options of an interaction against the command schema
+// and converts them into the positional arguments the plugin expects after the command
+func ParseInteractionArguments(schema []models.InteractionCommandOption, options []models.InteractionDataOption) (arguments []string, err error) {
+	arguments = make([]string, 0)
+
+	for _, option := range options {
+		if option.Type != models.InteractionCommandOptionTypeSubCommand &&
+			option.Type != models.InteractionCommandOptionTypeSubCommandGroup {
+			continue
+		}
+
+		subSchema := findInteractionCommandOption(schema, option.Name)
+		if subSchema == nil || subSchema.Type != option.Type {
+			return nil, fmt.Errorf("unknown subcommand %s", option.Name)
+		}
+
+		subArguments, err := ParseInteractionArguments(subSchema.Options, option.Options)
+		if err != nil {
+			return nil, err
+		}
+
+		return append([]string{option.Name}, subArguments...), nil
+	}
+
+	for _, optionSchema := range schema {
+		if optionSchema.Type == models.InteractionCommandOptionTypeSubCommand ||
+			optionSchema.Type == models.InteractionCommandOptionTypeSubCommandGroup {
+			return nil, errors.New("missing subcommand")
+		}
+
+		option := findInteractionDataOption(options, optionSchema.Name)
+		if option == nil {
+			if optionSchema.Required {
+				return nil, fmt.Errorf("missing option %s", optionSchema.Name)
+			}
+			continue
+		}
+
+		argument, err := formatInteractionArgument(optionSchema, option.Value)
+		if err != nil {
+			return nil, err
+		}
+		if argument == "" {
+			continue
+		}
+
+		arguments = append(arguments, argument)
+	}
+
+	return arguments, nil
+}
+
+func formatInteractionArgument(schema models.InteractionCommandOption, value interface{}) (argument string, err error) {
+	switch schema.Type {
+	case models.InteractionCommandOptionTypeString,
+		models.InteractionCommandOptionTypeUser,
+		models.InteractionCommandOptionTypeChannel,
+		models.InteractionCommandOptionTypeRole:
+		text, ok := value.(string)
+		if !ok {
+			return "", fmt.Errorf("option %s has to be a string", schema.Name)
+		}
+		argument = strings.TrimSpace(text)
+	case models.InteractionCommandOptionTypeInteger:
+		number, ok := value.(float64)
+		if !ok || number != float64(int64(number)) {
+			return "", fmt.Errorf("option %s has to be an integer", schema.Name)
+		}
+		argument = strconv.FormatInt(int64(number), 10)
+	case models.InteractionCommandOptionTypeBoolean:
+		boolean, ok := value.(bool)
+		if !ok {
+			return "", fmt.Errorf("option %s has to be a boolean", schema.Name)
+		}
+		argument = strconv.FormatBool(boolean)
+	default:
+		return "", fmt.Errorf("option %s has an unsupported type", schema.Name)
+	}
+
+	if len(schema.Choices) > 0 {
+		var isChoice bool
+		for _, choice := range schema.Choices {
+			if fmt.Sprint(choice.Value) == fmt.Sprint(value) {
+				isChoice = true
+				break
+			}
+		}
+		if !isChoice {
+			return "", fmt.Errorf("option %s has an invalid value", schema.Name)
+		}
+	}
+
+	switch schema.Type {
+	case models.InteractionCommandOptionTypeUser:
+		argument = "<@" + argument + ">"
+	case models.InteractionCommandOptionTypeChannel:
+		argument = "<#" + argument + ">"
+	case models.InteractionCommandOptionTypeRole:
+		argument = "<@&" + argument + ">"
+	}
+
+	return argument, nil
+}
+
+func findInteractionCommandOption(schema []models.InteractionCommandOption, name string) *models.InteractionCommandOption {
+	for i := range schema {
+		if schema[i].Name == name {
+			return &schema[i]
+		}
+	}
+	return nil
+}
+
+func findInteractionDataOption(options []models.InteractionDataOption, name string) *models.InteractionDataOption {
+	for i := range options {
+		if options[i].Name == name {
+			return &options[i]
+		}
+	}
+	return nil
+}
+
+func newEphemeralInteractionResponse(content string) *models.InteractionResponse {
+	return &models.InteractionResponse{
+		Type: models.InteractionResponseTypeChannelMessageWithSource,
+		Data: &models.InteractionResponseData{
+			Content: content,
+			Flags:   models.InteractionResponseFlagEphemeral,
+		},
+	}
+}
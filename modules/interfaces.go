@@ -1,6 +1,9 @@
 package modules
 
-import "github.com/bwmarrin/discordgo"
+import (
+	"github.com/Seklfreak/Robyul2/models"
+	"github.com/bwmarrin/discordgo"
+)
 
 type BaseModule interface{}
 
@@ -76,3 +79,22 @@ type ExtendedPlugin interface {
 		session *discordgo.Session,
 	)
 }
+
+// DescribedPlugin can be implemented by a Plugin or ExtendedPlugin
+// to describe the usage, arguments and permissions of its commands
+type DescribedPlugin interface {
+	CommandDescriptors() []models.CommandDescriptor
+}
+
+// InteractionPlugin can be implemented by a Plugin or ExtendedPlugin
+// to expose some of its commands as slash commands
+type InteractionPlugin interface {
+	InteractionCommands() []models.InteractionCommand
+}
+
+// ReloadablePlugin can be implemented by a Plugin or ExtendedPlugin
+// whose Init can run again after Uninit, without starting loops or handlers a second time
+// all other plugins are initialized once, unloading them only removes their commands and events
+type ReloadablePlugin interface {
+	Reloadable() bool
+}
@@ -0,0 +1,420 @@
+package modules
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/Seklfreak/Robyul2/cache"
+	"github.com/Seklfreak/Robyul2/generator"
+	"github.com/Seklfreak/Robyul2/helpers"
+	"github.com/Seklfreak/Robyul2/models"
+	"github.com/Seklfreak/Robyul2/modules/plugins/levels"
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	disabledPluginsBotConfigKey = "disabled_plugins"
+)
+
+var (
+	ErrPluginNotFound      = errors.New("plugin not found")
+	ErrPluginAmbiguous     = errors.New("plugin name is ambiguous")
+	ErrPluginProtected     = errors.New("plugin can not be unloaded")
+	ErrPluginAlreadyLoaded = errors.New("plugin is already loaded")
+	ErrPluginNotLoaded     = errors.New("plugin is not loaded")
+	ErrPluginDisabled      = errors.New("plugin is disabled")
+	ErrPluginNotReloadable = errors.New("plugin can not be reloaded, restart the bot instead")
+)
+
+// PluginInitError is returned if the Init of a plugin panicked, the plugin stays unloaded until it is loaded again
+type PluginInitError struct {
+	Name  string
+	Cause interface{}
+}
+
+func (e *PluginInitError) Error() string {
+	return fmt.Sprintf("initializing plugin %s failed: %v", e.Name, e.Cause)
+}
+
+// pluginState tracks whether a plugin from PluginList or PluginExtendedList is initialized and reacts to commands and events
+// plugins which are not reloadable can not be deinitialized, they stay initialized when they are unloaded
+// failed is set if the last Init panicked
+type pluginState struct {
+	name        string
+	pluginRef   *Plugin
+	extendedRef *ExtendedPlugin
+	initialized bool
+	loaded      bool
+	disabled    bool
+	failed      bool
+}
+
+var (
+	pluginStates     []*pluginState
+	pluginStatesLock sync.Mutex
+	pluginCacheLock  sync.RWMutex
+)
+
+func (s *pluginState) plugin() BaseModule {
+	if s.extendedRef != nil {
+		return *s.extendedRef
+	}
+	return *s.pluginRef
+}
+
+func (s *pluginState) commands() []string {
+	if s.extendedRef != nil {
+		return (*s.extendedRef).Commands()
+	}
+	return (*s.pluginRef).Commands()
+}
+
+// reloadable checks if the plugin can be deinitialized and initialized again, see ReloadablePlugin
+func (s *pluginState) reloadable() bool {
+	reloadablePlugin, ok := s.plugin().(ReloadablePlugin)
+	return ok && reloadablePlugin.Reloadable()
+}
+
+// init initializes the plugin, a panic in its Init marks the state failed and is returned as PluginInitError
+func (s *pluginState) init(session *discordgo.Session) (err error) {
+	defer func() {
+		if cause := recover(); cause != nil {
+			s.initialized = false
+			s.failed = true
+			err = &PluginInitError{Name: s.name, Cause: cause}
+		}
+	}()
+
+	if s.extendedRef != nil {
+		if helpers.Typeof(*s.extendedRef) == "*Levels" {
+			generator.SetProfileGenerator((*s.extendedRef).(*levels.Levels))
+		}
+
+		(*s.extendedRef).Init(session)
+	} else {
+		(*s.pluginRef).Init(session)
+	}
+	s.initialized = true
+	s.failed = false
+	return nil
+}
+
+func (s *pluginState) load(session *discordgo.Session) (err error) {
+	if !s.initialized {
+		err = s.init(session)
+		if err != nil {
+			return err
+		}
+	}
+	if s.extendedRef != nil {
+		startExtendedPluginDispatcher(*s.extendedRef)
+	}
+	s.loaded = true
+	return nil
+}
+
+func (s *pluginState) unload(session *discordgo.Session) {
+	s.loaded = false
+	if s.extendedRef != nil {
+		stopExtendedPluginDispatcher(s.name)
+	}
+
+	if !s.reloadable() {
+		return
+	}
+	if s.extendedRef != nil {
+		(*s.extendedRef).Uninit(session)
+	}
+	s.initialized = false
+}
+
+// initPluginStates creates the states for all plugins, disabled plugins will not be loaded
+func initPluginStates() {
+	disabledPlugins := getDisabledPlugins()
+
+	states := make([]*pluginState, 0, len(PluginList)+len(PluginExtendedList))
+	for i := range PluginList {
+		states = append(states, &pluginState{
+			name:      getPluginName(PluginList[i]),
+			pluginRef: &PluginList[i],
+		})
+	}
+	for i := range PluginExtendedList {
+		states = append(states, &pluginState{
+			name:        getPluginName(PluginExtendedList[i]),
+			extendedRef: &PluginExtendedList[i],
+		})
+	}
+
+	for _, state := range states {
+		for _, disabledPlugin := range disabledPlugins {
+			if state.name == disabledPlugin {
+				state.disabled = true
+			}
+		}
+	}
+
+	pluginStates = states
+}
+
+func getDisabledPlugins() (disabledPlugins []string) {
+	err := helpers.GetBotConfig(disabledPluginsBotConfigKey, &disabledPlugins)
+	if err != nil && !helpers.IsMdbNotFound(err) {
+		helpers.RelaxLog(err)
+	}
+	return disabledPlugins
+}
+
+func saveDisabledPlugins() (err error) {
+	disabledPlugins := make([]string, 0)
+	for _, state := range pluginStates {
+		if state.disabled {
+			disabledPlugins = append(disabledPlugins, state.name)
+		}
+	}
+
+	return helpers.SetBotConfig(disabledPluginsBotConfigKey, disabledPlugins)
+}
+
+// forEachLoadedPlugin calls cb for every loaded plugin with the commands it reacts to
+func forEachLoadedPlugin(cb func(plugin BaseModule, commands []string)) {
+	for _, state := range pluginStates {
+		if !state.loaded {
+			continue
+		}
+
+		cb(state.plugin(), state.commands())
+	}
+}
+
+// rebuildPluginCaches rebuilds the command caches, catalogue and interactions from the loaded plugins
+// the caches are swapped at once, so commands in flight keep using the old ones
+func rebuildPluginCaches() {
+	newPluginCache := make(map[string]*Plugin)
+	newExtendedPluginCache := make(map[string]*ExtendedPlugin)
+
+	for _, state := range pluginStates {
+		if !state.loaded {
+			continue
+		}
+
+		for _, cmd := range state.commands() {
+			if state.extendedRef != nil {
+				newExtendedPluginCache[cmd] = state.extendedRef
+			} else {
+				newPluginCache[cmd] = state.pluginRef
+			}
+		}
+	}
+
+	pluginCacheLock.Lock()
+	pluginCache = newPluginCache
+	extendedPluginCache = newExtendedPluginCache
+	pluginCacheLock.Unlock()
+
+	pluginCommands := make([]string, 0)
+	for k := range newPluginCache {
+		pluginCommands = append(pluginCommands, k)
+	}
+	cache.SetPluginList(pluginCommands)
+	extendedPluginCommands := make([]string, 0)
+	for k := range newExtendedPluginCache {
+		extendedPluginCommands = append(extendedPluginCommands, k)
+	}
+	cache.SetPluginExtendedList(extendedPluginCommands)
+
+	initCommandCatalogue()
+	initInteractions()
+}
+
+// findPluginState finds a plugin by its full name, for example levels.Levels, or by its type name if it is unique
+// has to be called while holding pluginStatesLock
+func findPluginState(name string) (state *pluginState, err error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+
+	var candidates []*pluginState
+	for _, state := range pluginStates {
+		stateName := strings.ToLower(state.name)
+		if stateName == name {
+			return state, nil
+		}
+		if strings.HasSuffix(stateName, "."+name) {
+			candidates = append(candidates, state)
+		}
+	}
+
+	switch len(candidates) {
+	case 0:
+		return nil, ErrPluginNotFound
+	case 1:
+		return candidates[0], nil
+	}
+	return nil, ErrPluginAmbiguous
+}
+
+func isProtectedPlugin(state *pluginState) bool {
+	_, ok := state.plugin().(*PluginManager)
+	return ok
+}
+
+// GetPluginStatuses returns the status of all plugins
+func GetPluginStatuses() (statuses []models.Rest_Plugin_Status) {
+	pluginStatesLock.Lock()
+	defer pluginStatesLock.Unlock()
+
+	statuses = make([]models.Rest_Plugin_Status, 0, len(pluginStates))
+	for _, state := range pluginStates {
+		statuses = append(statuses, models.Rest_Plugin_Status{
+			Name:     state.name,
+			Extended: state.extendedRef != nil,
+			Loaded:   state.loaded,
+			Disabled: state.disabled,
+			Failed:   state.failed,
+			Commands: state.commands(),
+		})
+	}
+
+	return statuses
+}
+
+// UnloadPlugin deinitializes a plugin and removes its commands until it is loaded again or the bot restarts
+func UnloadPlugin(name string) (err error) {
+	pluginStatesLock.Lock()
+	defer pluginStatesLock.Unlock()
+
+	state, err := findPluginState(name)
+	if err != nil {
+		return err
+	}
+	if isProtectedPlugin(state) {
+		return ErrPluginProtected
+	}
+	if !state.loaded {
+		return ErrPluginNotLoaded
+	}
+
+	defer rebuildPluginCaches()
+	state.unload(cache.GetSession())
+
+	cache.GetLogger().WithField("module", "modules").Infof("unloaded plugin %s", state.name)
+	return nil
+}
+
+// LoadPlugin initializes a plugin if required and adds its commands
+func LoadPlugin(name string) (err error) {
+	pluginStatesLock.Lock()
+	defer pluginStatesLock.Unlock()
+
+	state, err := findPluginState(name)
+	if err != nil {
+		return err
+	}
+	if state.disabled {
+		return ErrPluginDisabled
+	}
+	if state.loaded {
+		return ErrPluginAlreadyLoaded
+	}
+
+	defer rebuildPluginCaches()
+	err = state.load(cache.GetSession())
+	if err != nil {
+		return err
+	}
+
+	cache.GetLogger().WithField("module", "modules").Infof("loaded plugin %s", state.name)
+	return nil
+}
+
+// ReloadPlugin deinitializes and initializes a plugin again, only reloadable plugins can be reloaded
+func ReloadPlugin(name string) (err error) {
+	pluginStatesLock.Lock()
+	defer pluginStatesLock.Unlock()
+
+	state, err := findPluginState(name)
+	if err != nil {
+		return err
+	}
+	if isProtectedPlugin(state) {
+		return ErrPluginProtected
+	}
+	if state.disabled {
+		return ErrPluginDisabled
+	}
+	if !state.reloadable() {
+		return ErrPluginNotReloadable
+	}
+
+	defer rebuildPluginCaches()
+	session := cache.GetSession()
+	if state.loaded {
+		state.unload(session)
+	}
+	err = state.load(session)
+	if err != nil {
+		return err
+	}
+
+	cache.GetLogger().WithField("module", "modules").Infof("reloaded plugin %s", state.name)
+	return nil
+}
+
+// DisablePlugin unloads a plugin and keeps it unloaded across restarts
+func DisablePlugin(name string) (err error) {
+	pluginStatesLock.Lock()
+	defer pluginStatesLock.Unlock()
+
+	state, err := findPluginState(name)
+	if err != nil {
+		return err
+	}
+	if isProtectedPlugin(state) {
+		return ErrPluginProtected
+	}
+
+	state.disabled = true
+	err = saveDisabledPlugins()
+	if err != nil {
+		state.disabled = false
+		return err
+	}
+
+	if state.loaded {
+		defer rebuildPluginCaches()
+		state.unload(cache.GetSession())
+	}
+
+	cache.GetLogger().WithField("module", "modules").Infof("disabled plugin %s", state.name)
+	return nil
+}
+
+// EnablePlugin loads a disabled plugin and keeps it loaded across restarts
+func EnablePlugin(name string) (err error) {
+	pluginStatesLock.Lock()
+	defer pluginStatesLock.Unlock()
+
+	state, err := findPluginState(name)
+	if err != nil {
+		return err
+	}
+
+	state.disabled = false
+	err = saveDisabledPlugins()
+	if err != nil {
+		state.disabled = true
+		return err
+	}
+
+	if !state.loaded {
+		defer rebuildPluginCaches()
+		err = state.load(cache.GetSession())
+		if err != nil {
+			return err
+		}
+	}
+
+	cache.GetLogger().WithField("module", "modules").Infof("enabled plugin %s", state.name)
+	return nil
+}
@@ -0,0 +1,46 @@
+package modules
+
+import (
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+type testPlugin struct {
+	panics bool
+}
+
+func (p *testPlugin) Commands() []string {
+	return []string{"test"}
+}
+
+func (p *testPlugin) Init(session *discordgo.Session) {
+	if p.panics {
+		panic("init failed")
+	}
+}
+
+func (p *testPlugin) Action(command string, content string, msg *discordgo.Message, session *discordgo.Session) {
+}
+
+func TestPluginStateLoadRecoversInit(t *testing.T) {
+	plugin := Plugin(&testPlugin{panics: true})
+	state := &pluginState{name: "test.Plugin", pluginRef: &plugin}
+
+	err := state.load(nil)
+	if _, ok := err.(*PluginInitError); !ok {
+		t.Fatalf("pluginState.load() returned %v, want PluginInitError", err)
+	}
+	if state.loaded || state.initialized || !state.failed {
+		t.Errorf("pluginState.load() left loaded=%v initialized=%v failed=%v, want false false true", state.loaded, state.initialized, state.failed)
+	}
+
+	plugin.(*testPlugin).panics = false
+	err = state.load(nil)
+	if err != nil {
+		t.Fatalf("pluginState.load() returned %v", err)
+	}
+	if !state.loaded || !state.initialized || state.failed {
+		t.Errorf("pluginState.load() left loaded=%v initialized=%v failed=%v, want true true false", state.loaded, state.initialized, state.failed)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"github.com/Seklfreak/Robyul2/modules/plugins"
 	"github.com/Seklfreak/Robyul2/modules/plugins/biasgame"
 	"github.com/Seklfreak/Robyul2/modules/plugins/eventlog"
+	"github.com/Seklfreak/Robyul2/modules/plugins/feeds"
 	"github.com/Seklfreak/Robyul2/modules/plugins/idols"
 	"github.com/Seklfreak/Robyul2/modules/plugins/instagram"
 	"github.com/Seklfreak/Robyul2/modules/plugins/levels"
@@ -49,6 +50,7 @@ var (
 		&plugins.Friend{},
 		&plugins.Names{},
 		&plugins.Reddit{},
+		&feeds.Handler{},
 		&plugins.Color{},
 		&plugins.Dog{},
 		&plugins.Debug{},
@@ -73,6 +75,7 @@ var (
 		&plugins.Config{},
 		&plugins.Storage{},
 		&plugins.Mirror{},
+		&PluginManager{},
 	}
 
 	PluginExtendedList = []ExtendedPlugin{
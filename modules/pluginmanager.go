@@ -0,0 +1,82 @@
+package modules
+
+import (
+	"strings"
+
+	"github.com/Seklfreak/Robyul2/helpers"
+	"github.com/bwmarrin/discordgo"
+)
+
+// PluginManager lets bot admins unload, reload and disable plugins at runtime
+type PluginManager struct{}
+
+func (pm *PluginManager) Commands() []string {
+	return []string{
+		"plugins",
+	}
+}
+
+func (pm *PluginManager) Init(session *discordgo.Session) {
+}
+
+func (pm *PluginManager) Action(command string, content string, msg *discordgo.Message, session *discordgo.Session) {
+	helpers.RequireBotAdmin(msg, func() {
+		args := strings.Fields(content)
+
+		if len(args) < 1 || args[0] == "list" {
+			pm.actionList(msg)
+			return
+		}
+
+		if len(args) < 2 {
+			helpers.SendMessage(msg.ChannelID, helpers.GetText("bot.arguments.too-few"))
+			return
+		}
+
+		var err error
+		switch args[0] {
+		case "unload":
+			err = UnloadPlugin(args[1])
+		case "load":
+			err = LoadPlugin(args[1])
+		case "reload":
+			err = ReloadPlugin(args[1])
+		case "disable":
+			err = DisablePlugin(args[1])
+		case "enable":
+			err = EnablePlugin(args[1])
+		default:
+			helpers.SendMessage(msg.ChannelID, helpers.GetText("bot.arguments.invalid"))
+			return
+		}
+		if err != nil {
+			_, err = helpers.SendMessage(msg.ChannelID, helpers.GetTextF("plugins.plugins.action-error", err.Error()))
+			helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+			return
+		}
+
+		_, err = helpers.SendMessage(msg.ChannelID, helpers.GetTextF("plugins.plugins.action-success", args[0], args[1]))
+		helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+	})
+}
+
+func (pm *PluginManager) actionList(msg *discordgo.Message) {
+	var message string
+	for _, status := range GetPluginStatuses() {
+		state := helpers.GetText("plugins.plugins.list-loaded")
+		if status.Disabled {
+			state = helpers.GetText("plugins.plugins.list-disabled")
+		} else if status.Failed {
+			state = helpers.GetText("plugins.plugins.list-failed")
+		} else if !status.Loaded {
+			state = helpers.GetText("plugins.plugins.list-unloaded")
+		}
+
+		message += "`" + status.Name + "`: " + state + "\n"
+	}
+
+	for _, page := range helpers.Pagify(message, "\n") {
+		_, err := helpers.SendMessage(msg.ChannelID, page)
+		helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+	}
+}
@@ -39,6 +39,11 @@ func (a *AutoRoles) Uninit(session *discordgo.Session) {
 
 }
 
+// Reloadable is true, Init only sets up the date parser
+func (a *AutoRoles) Reloadable() bool {
+	return true
+}
+
 func (a *AutoRoles) Action(command string, content string, msg *discordgo.Message, session *discordgo.Session) {
 	if !helpers.ModuleIsAllowed(msg.ChannelID, msg.ID, msg.Author.ID, helpers.ModulePermAutoRole) {
 		return
@@ -37,6 +37,11 @@ func (m *Bias) Uninit(session *discordgo.Session) {
 
 }
 
+// Reloadable is true, Init only reloads the bias channels
+func (m *Bias) Reloadable() bool {
+	return true
+}
+
 func (m *Bias) Action(command string, content string, msg *discordgo.Message, session *discordgo.Session) {
 	if !helpers.ModuleIsAllowed(msg.ChannelID, msg.ID, msg.Author.ID, helpers.ModulePermBias) {
 		return
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/Seklfreak/Robyul2/helpers"
+	"github.com/Seklfreak/Robyul2/models"
 	"github.com/bwmarrin/discordgo"
 )
 
@@ -22,6 +23,58 @@ func (c *Choice) Commands() []string {
 	}
 }
 
+func (c *Choice) CommandDescriptors() []models.CommandDescriptor {
+	return []models.CommandDescriptor{
+		{
+			Name:        "choose",
+			Aliases:     []string{"choice"},
+			Description: "Chooses one of the given options. Options with spaces can be put in quotes.",
+			Usage:       "<option a> <option b> [...]",
+			Arguments: []models.CommandArgument{
+				{Name: "options", Description: "The options to choose from", Type: models.CommandArgumentTypeText, Required: true, Variadic: true},
+			},
+			Module: helpers.ModulePermChoice,
+		},
+		{
+			Name:        "roll",
+			Description: "Rolls a random number between 1 and the given maximum.",
+			Usage:       "[<max, default: 100>]",
+			Arguments: []models.CommandArgument{
+				{Name: "max", Description: "The highest possible number", Type: models.CommandArgumentTypeNumber},
+			},
+			Module: helpers.ModulePermChoice,
+		},
+	}
+}
+
+func (c *Choice) InteractionCommands() []models.InteractionCommand {
+	return []models.InteractionCommand{
+		{
+			Name:        "choose",
+			Description: "Chooses one of the given options",
+			Options: []models.InteractionCommandOption{
+				{
+					Type:        models.InteractionCommandOptionTypeString,
+					Name:        "options",
+					Description: "The options to choose from, separated by spaces",
+					Required:    true,
+				},
+			},
+		},
+		{
+			Name:        "roll",
+			Description: "Rolls a random number",
+			Options: []models.InteractionCommandOption{
+				{
+					Type:        models.InteractionCommandOptionTypeInteger,
+					Name:        "max",
+					Description: "The highest possible number, defaults to 100",
+				},
+			},
+		},
+	}
+}
+
 var (
 	splitChooseRegex *regexp.Regexp
 )
@@ -14,6 +14,8 @@ import (
 
 	"sync"
 
+	"unicode"
+
 	"github.com/Jeffail/gabs"
 	"github.com/Seklfreak/Robyul2/helpers"
 	"github.com/Seklfreak/Robyul2/metrics"
@@ -37,15 +39,18 @@ func (cc *CustomCommands) Commands() []string {
 }
 
 var (
-	customCommandsCache            []models.CustomCommandsEntry
-	customCommandsCacheLock        sync.Mutex
 	customCommandsAllowedFiletypes = []string{"image/jpeg", "image/png", "image/gif", "video/mp4", "video/webm"}
+
+	// the maximum cooldown of a command in seconds
+	customCommandsMaxCooldown = 86400
+
+	customCommandsCooldowns         = make(map[string]time.Time)
+	customCommandsCooldownsPrunedAt time.Time
+	customCommandsCooldownsLock     sync.Mutex
 )
 
 func (cc *CustomCommands) Init(session *discordgo.Session) {
-	var err error
-	customCommandsCache, err = cc.getAllCustomCommands()
-	helpers.Relax(err)
+	go customCommandsInvalidateLoop()
 }
 
 func (cc *CustomCommands) Uninit(session *discordgo.Session) {
@@ -134,11 +139,7 @@ func (cc *CustomCommands) Action(command string, content string, msg *discordgo.
 				return
 			}
 
-			var entryBucket models.CustomCommandsEntry
-			err = helpers.MdbOne(
-				helpers.MdbCollection(models.CustomCommandsTable).Find(bson.M{"guildid": channel.GuildID, "keyword": args[1]}),
-				&entryBucket,
-			)
+			_, err = findCustomCommand(channel.GuildID, args[1])
 			if err == nil {
 				_, err := helpers.SendMessage(msg.ChannelID, helpers.GetText("plugins.customcommands.add-keyword-already-exists"))
 				helpers.Relax(err)
@@ -221,9 +222,7 @@ func (cc *CustomCommands) Action(command string, content string, msg *discordgo.
 
 			_, err = helpers.SendMessage(msg.ChannelID, helpers.GetText("plugins.customcommands.add-success"))
 			helpers.Relax(err)
-			customCommandsCacheLock.Lock()
-			defer customCommandsCacheLock.Unlock()
-			customCommandsCache, err = cc.getAllCustomCommands()
+			err = invalidateCustomCommands(channel.GuildID)
 			helpers.Relax(err)
 			return
 		case "random": // [p]commands random
@@ -393,11 +392,7 @@ func (cc *CustomCommands) Action(command string, content string, msg *discordgo.
 			channel, err := helpers.GetChannel(msg.ChannelID)
 			helpers.Relax(err)
 
-			var entryBucket models.CustomCommandsEntry
-			err = helpers.MdbOne(
-				helpers.MdbCollection(models.CustomCommandsTable).Find(bson.M{"guildid": channel.GuildID, "keyword": args[1]}),
-				&entryBucket,
-			)
+			entryBucket, err := findCustomCommand(channel.GuildID, args[1])
 			if helpers.IsMdbNotFound(err) {
 				_, err := helpers.SendMessage(msg.ChannelID, helpers.GetText("plugins.customcommands.delete-not-found"))
 				helpers.Relax(err)
@@ -437,9 +432,7 @@ func (cc *CustomCommands) Action(command string, content string, msg *discordgo.
 
 			_, err = helpers.SendMessage(msg.ChannelID, helpers.GetText("plugins.customcommands.delete-success"))
 			helpers.Relax(err)
-			customCommandsCacheLock.Lock()
-			defer customCommandsCacheLock.Unlock()
-			customCommandsCache, err = cc.getAllCustomCommands()
+			err = invalidateCustomCommands(channel.GuildID)
 			helpers.Relax(err)
 			return
 		case "replace", "edit": // [p]commands edit <command name> <new content>
@@ -451,11 +444,7 @@ func (cc *CustomCommands) Action(command string, content string, msg *discordgo.
 			channel, err := helpers.GetChannel(msg.ChannelID)
 			helpers.Relax(err)
 
-			var entryBucket models.CustomCommandsEntry
-			err = helpers.MdbOne(
-				helpers.MdbCollection(models.CustomCommandsTable).Find(bson.M{"guildid": channel.GuildID, "keyword": args[1]}),
-				&entryBucket,
-			)
+			entryBucket, err := findCustomCommand(channel.GuildID, args[1])
 			if helpers.IsMdbNotFound(err) {
 				_, err := helpers.SendMessage(msg.ChannelID, helpers.GetText("plugins.customcommands.edit-not-found"))
 				helpers.Relax(err)
@@ -551,18 +540,21 @@ func (cc *CustomCommands) Action(command string, content string, msg *discordgo.
 
 			_, err = helpers.SendMessage(msg.ChannelID, helpers.GetText("plugins.customcommands.edit-success"))
 			helpers.Relax(err)
-			customCommandsCacheLock.Lock()
-			defer customCommandsCacheLock.Unlock()
-			customCommandsCache, err = cc.getAllCustomCommands()
+			err = invalidateCustomCommands(channel.GuildID)
 			helpers.Relax(err)
 			return
+		case "cooldown", "restrict-role", "restrict-channel", "alias":
+			// [p]commands cooldown <command name> <seconds or off>
+			// [p]commands restrict-role <command name> <role name, id or mention>
+			// [p]commands restrict-channel <command name> <#channel>
+			// [p]commands alias <command name> <alias>
+			session.ChannelTyping(msg.ChannelID)
+			cc.setCommandOption(args, msg)
+			return
 		case "refresh": // [p]commands refresh
 			helpers.RequireBotAdmin(msg, func() {
 				session.ChannelTyping(msg.ChannelID)
-				var err error
-				customCommandsCacheLock.Lock()
-				defer customCommandsCacheLock.Unlock()
-				customCommandsCache, err = cc.getAllCustomCommands()
+				err := loadAllCustomCommands()
 				helpers.Relax(err)
 				_, err = helpers.SendMessage(msg.ChannelID, helpers.GetText("plugins.customcommands.refreshed-commands"))
 				helpers.Relax(err)
@@ -614,11 +606,7 @@ func (cc *CustomCommands) Action(command string, content string, msg *discordgo.
 			channel, err := helpers.GetChannel(msg.ChannelID)
 			helpers.Relax(err)
 
-			var entryBucket models.CustomCommandsEntry
-			err = helpers.MdbOne(
-				helpers.MdbCollection(models.CustomCommandsTable).Find(bson.M{"guildid": channel.GuildID, "keyword": args[1]}),
-				&entryBucket,
-			)
+			entryBucket, err := findCustomCommand(channel.GuildID, args[1])
 			if helpers.IsMdbNotFound(err) {
 				_, err := helpers.SendMessage(msg.ChannelID, helpers.GetText("plugins.customcommands.info-not-found"))
 				helpers.Relax(err)
@@ -641,6 +629,22 @@ func (cc *CustomCommands) Action(command string, content string, msg *discordgo.
 					},
 				},
 			}
+			if len(entryBucket.Aliases) > 0 {
+				messageSend.Embed.Fields = append(messageSend.Embed.Fields, &discordgo.MessageEmbedField{
+					Name: "Aliases", Value: "`" + strings.Join(entryBucket.Aliases, "`, `") + "`"})
+			}
+			if entryBucket.Cooldown > 0 {
+				messageSend.Embed.Fields = append(messageSend.Embed.Fields, &discordgo.MessageEmbedField{
+					Name: "Cooldown", Value: fmt.Sprintf("%d seconds", entryBucket.Cooldown)})
+			}
+			if len(entryBucket.AllowedRoleIDs) > 0 {
+				messageSend.Embed.Fields = append(messageSend.Embed.Fields, &discordgo.MessageEmbedField{
+					Name: "Allowed Roles", Value: "<@&" + strings.Join(entryBucket.AllowedRoleIDs, ">, <@&") + ">"})
+			}
+			if len(entryBucket.AllowedChannelIDs) > 0 {
+				messageSend.Embed.Fields = append(messageSend.Embed.Fields, &discordgo.MessageEmbedField{
+					Name: "Allowed Channels", Value: "<#" + strings.Join(entryBucket.AllowedChannelIDs, ">, <#") + ">"})
+			}
 			if data != nil && len(data) > 0 {
 				messageSend.Files = []*discordgo.File{
 					{
@@ -695,9 +699,14 @@ func (cc *CustomCommands) Action(command string, content string, msg *discordgo.
 				for newCustomCommandName, newCustomCommandContent := range commandsContainer {
 					commandExists := false
 					for _, customCommand := range entryBucket {
-						if customCommand.Keyword == newCustomCommandName {
+						if strings.EqualFold(customCommand.Keyword, newCustomCommandName) {
 							commandExists = true
 						}
+						for _, alias := range customCommand.Aliases {
+							if strings.EqualFold(alias, newCustomCommandName) {
+								commandExists = true
+							}
+						}
 					}
 					if commandExists {
 						helpers.SendMessage(msg.ChannelID, fmt.Sprintf("Command with the name `%s` already exists.", newCustomCommandName))
@@ -737,9 +746,7 @@ func (cc *CustomCommands) Action(command string, content string, msg *discordgo.
 
 				_, err = helpers.SendMessage(msg.ChannelID, fmt.Sprintf("<@%s> I imported **%s** custom commands.", msg.Author.ID, humanize.Comma(int64(i))))
 				helpers.Relax(err)
-				customCommandsCacheLock.Lock()
-				defer customCommandsCacheLock.Unlock()
-				customCommandsCache, err = cc.getAllCustomCommands()
+				err = invalidateCustomCommands(channel.GuildID)
 				helpers.Relax(err)
 			})
 			return
@@ -780,6 +787,183 @@ func (cc *CustomCommands) Action(command string, content string, msg *discordgo.
 	}
 }
 
+// changes the cooldown or toggles a role or channel restriction of a command
+// args	: the option, the command name and the new value
+// msg	: the message requesting the change
+func (cc *CustomCommands) setCommandOption(args []string, msg *discordgo.Message) {
+	if len(args) < 3 {
+		_, err := helpers.SendMessage(msg.ChannelID, helpers.GetText("bot.arguments.too-few"))
+		helpers.Relax(err)
+		return
+	}
+
+	channel, err := helpers.GetChannel(msg.ChannelID)
+	helpers.Relax(err)
+
+	entryBucket, err := findCustomCommand(channel.GuildID, args[1])
+	if helpers.IsMdbNotFound(err) {
+		_, err := helpers.SendMessage(msg.ChannelID, helpers.GetText("plugins.customcommands.edit-not-found"))
+		helpers.Relax(err)
+		return
+	}
+	helpers.Relax(err)
+
+	if !cc.canAddCommand(channel.GuildID, msg.Author.ID, &entryBucket) {
+		helpers.SendMessage(msg.ChannelID, helpers.GetText("mod.no_permission"))
+		return
+	}
+
+	var change models.ElasticEventlogChange
+	var message string
+	switch strings.ToLower(args[0]) {
+	case "cooldown":
+		var cooldown int
+		if strings.ToLower(args[2]) != "off" {
+			cooldown, err = strconv.Atoi(strings.TrimSuffix(strings.ToLower(args[2]), "s"))
+			if err != nil || cooldown < 0 || cooldown > customCommandsMaxCooldown {
+				helpers.SendMessage(msg.ChannelID, helpers.GetTextF("plugins.customcommands.cooldown-invalid", customCommandsMaxCooldown))
+				return
+			}
+		}
+
+		change = models.ElasticEventlogChange{
+			Key:      "command_cooldown",
+			OldValue: strconv.Itoa(entryBucket.Cooldown),
+			NewValue: strconv.Itoa(cooldown),
+		}
+		entryBucket.Cooldown = cooldown
+		message = helpers.GetTextF("plugins.customcommands.cooldown-set", entryBucket.Keyword, cooldown)
+		if cooldown <= 0 {
+			message = helpers.GetTextF("plugins.customcommands.cooldown-removed", entryBucket.Keyword)
+		}
+	case "restrict-role":
+		guild, err := helpers.GetGuild(channel.GuildID)
+		helpers.Relax(err)
+
+		roleText := strings.TrimSuffix(strings.TrimPrefix(strings.Join(args[2:], " "), "<@&"), ">")
+		var targetRole *discordgo.Role
+		for _, guildRole := range guild.Roles {
+			if strings.ToLower(guildRole.Name) == strings.ToLower(roleText) || guildRole.ID == roleText {
+				targetRole = guildRole
+			}
+		}
+		if targetRole == nil {
+			helpers.SendMessage(msg.ChannelID, helpers.GetText("bot.arguments.invalid"))
+			return
+		}
+
+		var removed bool
+		change = models.ElasticEventlogChange{
+			Key:      "command_allowed_roleids",
+			OldValue: strings.Join(entryBucket.AllowedRoleIDs, ";"),
+			Type:     models.EventlogTargetTypeRole,
+		}
+		entryBucket.AllowedRoleIDs, removed = cc.toggleID(entryBucket.AllowedRoleIDs, targetRole.ID)
+		change.NewValue = strings.Join(entryBucket.AllowedRoleIDs, ";")
+		message = helpers.GetTextF("plugins.customcommands.role-restriction-added", entryBucket.Keyword, targetRole.Name)
+		if removed {
+			message = helpers.GetTextF("plugins.customcommands.role-restriction-removed", entryBucket.Keyword, targetRole.Name)
+		}
+	case "alias":
+		alias := args[2]
+		if helpers.CommandExists(alias) {
+			helpers.SendMessage(msg.ChannelID, helpers.GetText("plugins.customcommands.add-command-already-exists"))
+			return
+		}
+		existingCommand, err := findCustomCommand(channel.GuildID, alias)
+		if err == nil && existingCommand.ID != entryBucket.ID {
+			helpers.SendMessage(msg.ChannelID, helpers.GetText("plugins.customcommands.add-keyword-already-exists"))
+			return
+		}
+		if err != nil && !helpers.IsMdbNotFound(err) {
+			helpers.Relax(err)
+		}
+		if strings.EqualFold(alias, entryBucket.Keyword) {
+			helpers.SendMessage(msg.ChannelID, helpers.GetText("bot.arguments.invalid"))
+			return
+		}
+
+		var removed bool
+		change = models.ElasticEventlogChange{
+			Key:      "command_aliases",
+			OldValue: strings.Join(entryBucket.Aliases, ";"),
+		}
+		newAliases := make([]string, 0, len(entryBucket.Aliases)+1)
+		for _, existingAlias := range entryBucket.Aliases {
+			if strings.EqualFold(existingAlias, alias) {
+				removed = true
+				continue
+			}
+			newAliases = append(newAliases, existingAlias)
+		}
+		if !removed {
+			newAliases = append(newAliases, alias)
+		}
+		entryBucket.Aliases = newAliases
+		change.NewValue = strings.Join(entryBucket.Aliases, ";")
+		message = helpers.GetTextF("plugins.customcommands.alias-added", alias, entryBucket.Keyword)
+		if removed {
+			message = helpers.GetTextF("plugins.customcommands.alias-removed", alias, entryBucket.Keyword)
+		}
+	case "restrict-channel":
+		targetChannel, err := helpers.GetChannelFromMention(msg, args[2])
+		if err != nil || targetChannel.GuildID != channel.GuildID {
+			helpers.SendMessage(msg.ChannelID, helpers.GetText("bot.arguments.invalid"))
+			return
+		}
+
+		var removed bool
+		change = models.ElasticEventlogChange{
+			Key:      "command_allowed_channelids",
+			OldValue: strings.Join(entryBucket.AllowedChannelIDs, ";"),
+			Type:     models.EventlogTargetTypeChannel,
+		}
+		entryBucket.AllowedChannelIDs, removed = cc.toggleID(entryBucket.AllowedChannelIDs, targetChannel.ID)
+		change.NewValue = strings.Join(entryBucket.AllowedChannelIDs, ";")
+		message = helpers.GetTextF("plugins.customcommands.channel-restriction-added", entryBucket.Keyword, targetChannel.ID)
+		if removed {
+			message = helpers.GetTextF("plugins.customcommands.channel-restriction-removed", entryBucket.Keyword, targetChannel.ID)
+		}
+	}
+
+	err = helpers.MDbUpdate(models.CustomCommandsTable, entryBucket.ID, entryBucket)
+	helpers.Relax(err)
+
+	_, err = helpers.EventlogLog(time.Now(), channel.GuildID, channel.GuildID,
+		models.EventlogTargetTypeGuild, msg.Author.ID,
+		models.EventlogTypeRobyulCommandsUpdate, "",
+		[]models.ElasticEventlogChange{change},
+		[]models.ElasticEventlogOption{
+			{
+				Key:   "command_keyword",
+				Value: entryBucket.Keyword,
+			},
+		}, false)
+	helpers.RelaxLog(err)
+
+	_, err = helpers.SendMessage(msg.ChannelID, message)
+	helpers.Relax(err)
+
+	err = invalidateCustomCommands(channel.GuildID)
+	helpers.Relax(err)
+}
+
+// adds an ID to a list, or removes it if the list contains it already
+func (cc *CustomCommands) toggleID(ids []string, id string) (newIDs []string, removed bool) {
+	newIDs = make([]string, 0, len(ids)+1)
+	for _, existingID := range ids {
+		if existingID == id {
+			removed = true
+			continue
+		}
+		newIDs = append(newIDs, existingID)
+	}
+	if !removed {
+		newIDs = append(newIDs, id)
+	}
+	return newIDs, removed
+}
+
 // checks if the user can add or edit a command
 // guildID		: the guild on which the user wants to add a command
 // userID		: the user which wants to add the command
@@ -830,6 +1014,33 @@ func (cc *CustomCommands) isAllowedFiletype(filetype string) (allowed bool) {
 }
 
 func (cc *CustomCommands) OnMessage(content string, msg *discordgo.Message, session *discordgo.Session) {
+	guildID := msg.GuildID
+	if guildID == "" {
+		channel, err := helpers.GetChannelWithoutApi(msg.ChannelID)
+		if err != nil {
+			return
+		}
+		guildID = channel.GuildID
+	}
+
+	prefix := helpers.GetPrefixForServer(guildID)
+	if prefix == "" || !strings.HasPrefix(content, prefix) {
+		return
+	}
+	fields := strings.Fields(content[len(prefix):])
+	if len(fields) <= 0 {
+		return
+	}
+
+	customCommand, ok := customCommandsIndex.get(guildID, fields[0])
+	if !ok {
+		return
+	}
+	args, matched := cc.matchCommand(content, prefix+fields[0])
+	if !matched {
+		return
+	}
+
 	if !helpers.ModuleIsAllowedSilent(msg.ChannelID, msg.ID, msg.Author.ID, helpers.ModulePermCustomCommands) {
 		return
 	}
@@ -839,50 +1050,136 @@ func (cc *CustomCommands) OnMessage(content string, msg *discordgo.Message, sess
 		helpers.RelaxLog(err)
 		return
 	}
-	prefix := helpers.GetPrefixForServer(channel.GuildID)
 
-	for i, customCommand := range customCommandsCache {
-		if customCommand.GuildID == channel.GuildID && prefix+customCommand.Keyword == content {
-			session.ChannelTyping(msg.ChannelID)
-			content, filename, data := cc.getCommandContent(customCommand)
-			messageSend := &discordgo.MessageSend{
-				Content: content,
-			}
-			if data != nil && len(data) > 0 {
-				messageSend.Files = []*discordgo.File{
-					{
-						Name:   filename,
-						Reader: bytes.NewReader(data),
-					},
-				}
-			}
-			_, err = helpers.SendComplex(msg.ChannelID, messageSend)
-			if err != nil {
-				if errD, ok := err.(*discordgo.RESTError); ok {
-					if errD.Message.Code == discordgo.ErrCodeMissingPermissions {
-						return
-					}
-				}
-				helpers.RelaxLog(err)
+	if !cc.isAllowedToUse(customCommand, msg.Author.ID, channel) || !cc.cooldownPassed(customCommand, msg.Author.ID) {
+		return
+	}
+
+	session.ChannelTyping(msg.ChannelID)
+	customCommand.Content = renderCustomCommand(customCommand.Content, args, msg, channel)
+	content, filename, data := cc.getCommandContent(customCommand)
+	if strings.TrimSpace(content) == "" && len(data) <= 0 {
+		return
+	}
+	messageSend := &discordgo.MessageSend{
+		Content: content,
+	}
+	if helpers.IsEmbedCode(content) {
+		ptext, embed, err := helpers.ParseEmbedCode(content)
+		if err == nil {
+			messageSend.Content = ptext
+			messageSend.Embed = embed
+		}
+	}
+	if data != nil && len(data) > 0 {
+		messageSend.Files = []*discordgo.File{
+			{
+				Name:   filename,
+				Reader: bytes.NewReader(data),
+			},
+		}
+	}
+	_, err = helpers.SendComplex(msg.ChannelID, messageSend)
+	if err != nil {
+		if errD, ok := err.(*discordgo.RESTError); ok {
+			if errD.Message.Code == discordgo.ErrCodeMissingPermissions {
 				return
 			}
+		}
+		helpers.RelaxLog(err)
+		return
+	}
+
+	customCommandsIndex.triggered(guildID, fields[0])
+
+	// increase triggered in DB by one
+	err = helpers.MDbUpdate(models.CustomCommandsTable, customCommand.ID, bson.M{"$inc": bson.M{"triggered": 1}})
+	if err != nil && !helpers.IsMdbNotFound(err) {
+		helpers.RelaxLog(err)
+	}
 
-			customCommandsCacheLock.Lock()
-			if len(customCommandsCache) > i {
-				customCommandsCache[i].Triggered += 1
+	metrics.CustomCommandsTriggered.Add(1)
+}
+
+// checks if a message triggers a command, the command can be followed by arguments
+// content	: the message content
+// trigger	: the prefix and keyword of the command
+func (cc *CustomCommands) matchCommand(content, trigger string) (args []string, matched bool) {
+	if !strings.HasPrefix(content, trigger) {
+		return nil, false
+	}
+	rest := content[len(trigger):]
+	if rest != "" && !unicode.IsSpace([]rune(rest)[0]) {
+		return nil, false
+	}
+	return strings.Fields(rest), true
+}
+
+// checks the role and channel restrictions of a command
+// customCommand	: the command to check
+// userID			: the user which wants to use the command
+// channel			: the channel the command would be used in
+func (cc *CustomCommands) isAllowedToUse(customCommand models.CustomCommandsEntry, userID string, channel *discordgo.Channel) (allowed bool) {
+	if len(customCommand.AllowedChannelIDs) > 0 {
+		var allowedChannel bool
+		for _, allowedChannelID := range customCommand.AllowedChannelIDs {
+			if allowedChannelID == channel.ID || allowedChannelID == channel.ParentID {
+				allowedChannel = true
 			}
-			customCommandsCacheLock.Unlock()
+		}
+		if !allowedChannel {
+			return false
+		}
+	}
 
-			// increase triggered in DB by one
-			err = helpers.MDbUpdate(models.CustomCommandsTable, customCommand.ID, bson.M{"$inc": bson.M{"triggered": 1}})
-			if err != nil && !helpers.IsMdbNotFound(err) {
-				helpers.RelaxLog(err)
+	if len(customCommand.AllowedRoleIDs) > 0 {
+		member, err := helpers.GetGuildMemberWithoutApi(channel.GuildID, userID)
+		if err != nil {
+			return false
+		}
+		for _, memberRoleID := range member.Roles {
+			for _, allowedRoleID := range customCommand.AllowedRoleIDs {
+				if memberRoleID == allowedRoleID {
+					return true
+				}
 			}
+		}
+		return false
+	}
 
-			metrics.CustomCommandsTriggered.Add(1)
-			return
+	return true
+}
+
+// checks if the cooldown of a command for a user passed and starts a new cooldown if so
+// customCommand	: the command the user wants to use
+// userID			: the user which wants to use the command
+func (cc *CustomCommands) cooldownPassed(customCommand models.CustomCommandsEntry, userID string) (passed bool) {
+	if customCommand.Cooldown <= 0 {
+		return true
+	}
+
+	key := customCommand.ID.Hex() + userID
+	now := time.Now()
+
+	customCommandsCooldownsLock.Lock()
+	defer customCommandsCooldownsLock.Unlock()
+
+	if cooldownUntil, ok := customCommandsCooldowns[key]; ok && now.Before(cooldownUntil) {
+		return false
+	}
+	customCommandsCooldowns[key] = now.Add(time.Duration(customCommand.Cooldown) * time.Second)
+
+	// remove cooldowns that passed already from time to time
+	if now.Sub(customCommandsCooldownsPrunedAt) > 10*time.Minute {
+		for cooldownKey, cooldownUntil := range customCommandsCooldowns {
+			if now.After(cooldownUntil) {
+				delete(customCommandsCooldowns, cooldownKey)
+			}
 		}
+		customCommandsCooldownsPrunedAt = now
 	}
+
+	return true
 }
 
 func (cc *CustomCommands) getCommandContent(customCommand models.CustomCommandsEntry) (content, filename string, data []byte) {
@@ -939,16 +1236,6 @@ func (cc *CustomCommands) OnGuildMemberAdd(member *discordgo.Member, session *di
 func (cc *CustomCommands) OnGuildMemberRemove(member *discordgo.Member, session *discordgo.Session) {
 }
 
-func (cc *CustomCommands) getAllCustomCommands() (ccommands []models.CustomCommandsEntry, err error) {
-	err = helpers.MDbIter(helpers.MdbCollection(models.CustomCommandsTable).Find(nil)).All(&ccommands)
-	if err != nil {
-		return ccommands, err
-	}
-
-	metrics.CustomCommandsCount.Set(int64(len(ccommands)))
-	return ccommands, nil
-}
-
 func (cc *CustomCommands) OnReactionAdd(reaction *discordgo.MessageReactionAdd, session *discordgo.Session) {
 
 }
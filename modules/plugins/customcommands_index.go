@@ -0,0 +1,192 @@
+package plugins
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Seklfreak/Robyul2/cache"
+	"github.com/Seklfreak/Robyul2/helpers"
+	"github.com/Seklfreak/Robyul2/metrics"
+	"github.com/Seklfreak/Robyul2/models"
+	"github.com/globalsign/mgo/bson"
+)
+
+const (
+	// customCommandsInvalidateChannel is the redis channel the guild IDs of changed commands are published to
+	customCommandsInvalidateChannel = "robyul2-discord:customcommands:invalidate"
+)
+
+// customCommandsIndexType contains the commands of every guild by lower case keyword and alias
+type customCommandsIndexType struct {
+	sync.RWMutex
+	guilds map[string]map[string]*models.CustomCommandsEntry
+	count  map[string]int
+}
+
+var (
+	customCommandsIndex = &customCommandsIndexType{
+		guilds: make(map[string]map[string]*models.CustomCommandsEntry),
+		count:  make(map[string]int),
+	}
+)
+
+// set replaces the commands of a guild in the index
+func (index *customCommandsIndexType) set(guildID string, entries []models.CustomCommandsEntry) {
+	guildIndex := buildCustomCommandsGuildIndex(entries)
+
+	index.Lock()
+	defer index.Unlock()
+
+	if len(guildIndex) > 0 {
+		index.guilds[guildID] = guildIndex
+		index.count[guildID] = len(entries)
+	} else {
+		delete(index.guilds, guildID)
+		delete(index.count, guildID)
+	}
+	index.updateMetrics()
+}
+
+// replace replaces the commands of every guild in the index
+func (index *customCommandsIndexType) replace(entries []models.CustomCommandsEntry) {
+	byGuild := make(map[string][]models.CustomCommandsEntry)
+	for _, entry := range entries {
+		byGuild[entry.GuildID] = append(byGuild[entry.GuildID], entry)
+	}
+
+	guilds := make(map[string]map[string]*models.CustomCommandsEntry, len(byGuild))
+	count := make(map[string]int, len(byGuild))
+	for guildID, guildEntries := range byGuild {
+		guilds[guildID] = buildCustomCommandsGuildIndex(guildEntries)
+		count[guildID] = len(guildEntries)
+	}
+
+	index.Lock()
+	defer index.Unlock()
+
+	index.guilds = guilds
+	index.count = count
+	index.updateMetrics()
+}
+
+// updateMetrics sets the custom commands count, the index has to be locked
+func (index *customCommandsIndexType) updateMetrics() {
+	var total int
+	for _, count := range index.count {
+		total += count
+	}
+	metrics.CustomCommandsCount.Set(int64(total))
+}
+
+// buildCustomCommandsGuildIndex maps the lower case keywords and aliases of the commands of a guild to the commands
+// keywords take precedence over aliases
+func buildCustomCommandsGuildIndex(entries []models.CustomCommandsEntry) map[string]*models.CustomCommandsEntry {
+	guildIndex := make(map[string]*models.CustomCommandsEntry, len(entries))
+	for i := range entries {
+		guildIndex[strings.ToLower(entries[i].Keyword)] = &entries[i]
+	}
+	for i := range entries {
+		for _, alias := range entries[i].Aliases {
+			if _, ok := guildIndex[strings.ToLower(alias)]; !ok {
+				guildIndex[strings.ToLower(alias)] = &entries[i]
+			}
+		}
+	}
+	return guildIndex
+}
+
+// get returns a copy of the command of a guild with the keyword or alias, case insensitive
+func (index *customCommandsIndexType) get(guildID, keyword string) (entry models.CustomCommandsEntry, ok bool) {
+	index.RLock()
+	defer index.RUnlock()
+
+	found, ok := index.guilds[guildID][strings.ToLower(keyword)]
+	if !ok {
+		return entry, false
+	}
+	return *found, true
+}
+
+// triggered increases the triggered count of an indexed command by one
+func (index *customCommandsIndexType) triggered(guildID, keyword string) {
+	index.Lock()
+	defer index.Unlock()
+
+	if found, ok := index.guilds[guildID][strings.ToLower(keyword)]; ok {
+		found.Triggered++
+	}
+}
+
+// loadAllCustomCommands builds the index for every guild
+func loadAllCustomCommands() (err error) {
+	var entries []models.CustomCommandsEntry
+	err = helpers.MDbIter(helpers.MdbCollection(models.CustomCommandsTable).Find(nil)).All(&entries)
+	if err != nil {
+		return err
+	}
+
+	customCommandsIndex.replace(entries)
+	return nil
+}
+
+// loadGuildCustomCommands rebuilds the index of a guild
+func loadGuildCustomCommands(guildID string) (err error) {
+	var entries []models.CustomCommandsEntry
+	err = helpers.MDbIterWithoutLogging(helpers.MdbCollection(models.CustomCommandsTable).Find(bson.M{"guildid": guildID})).All(&entries)
+	if err != nil {
+		return err
+	}
+
+	customCommandsIndex.set(guildID, entries)
+	return nil
+}
+
+// invalidateCustomCommands rebuilds the index of a guild after its commands changed and tells other bot processes to do the same
+func invalidateCustomCommands(guildID string) (err error) {
+	err = loadGuildCustomCommands(guildID)
+	if err != nil {
+		return err
+	}
+
+	return cache.GetRedisClient().Publish(customCommandsInvalidateChannel, guildID).Err()
+}
+
+// customCommandsInvalidateLoop rebuilds the index of guilds published by other bot processes
+func customCommandsInvalidateLoop() {
+	defer helpers.Recover()
+	defer func() {
+		go func() {
+			cache.GetLogger().WithField("module", "customcommands").Error(
+				"the customCommandsInvalidateLoop died. Please investigate! Will be restarted in 60 seconds")
+			time.Sleep(60 * time.Second)
+			customCommandsInvalidateLoop()
+		}()
+	}()
+
+	pubSub := cache.GetRedisClient().Subscribe(customCommandsInvalidateChannel)
+	defer pubSub.Close()
+
+	// the index is built after subscribing, so no change published in between gets lost
+	err := loadAllCustomCommands()
+	helpers.Relax(err)
+
+	for message := range pubSub.Channel() {
+		err = loadGuildCustomCommands(message.Payload)
+		helpers.RelaxLog(err)
+	}
+}
+
+// findCustomCommand returns the command of a guild with the keyword or alias from the database, case insensitive
+func findCustomCommand(guildID, keyword string) (entry models.CustomCommandsEntry, err error) {
+	pattern := bson.RegEx{Pattern: "^" + regexp.QuoteMeta(keyword) + "$", Options: "i"}
+	err = helpers.MdbOne(
+		helpers.MdbCollection(models.CustomCommandsTable).Find(bson.M{
+			"guildid": guildID,
+			"$or":     []bson.M{{"keyword": pattern}, {"aliases": pattern}},
+		}),
+		&entry,
+	)
+	return entry, err
+}
@@ -0,0 +1,26 @@
+package plugins
+
+import (
+	"testing"
+
+	"github.com/Seklfreak/Robyul2/models"
+)
+
+func TestBuildCustomCommandsGuildIndex(t *testing.T) {
+	guildIndex := buildCustomCommandsGuildIndex([]models.CustomCommandsEntry{
+		{Keyword: "Hello", Aliases: []string{"hi", "bye"}},
+		{Keyword: "bye"},
+	})
+
+	tests := map[string]string{
+		"hello": "Hello",
+		"hi":    "Hello",
+		"bye":   "bye",
+	}
+	for keyword, expected := range tests {
+		entry, ok := guildIndex[keyword]
+		if !ok || entry.Keyword != expected {
+			t.Errorf("plugins.buildCustomCommandsGuildIndex() maps %q to %+v, expected %q", keyword, entry, expected)
+		}
+	}
+}
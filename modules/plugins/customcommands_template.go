@@ -0,0 +1,86 @@
+package plugins
+
+import (
+	"fmt"
+	"math/rand"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/Seklfreak/Robyul2/helpers"
+	"github.com/bwmarrin/discordgo"
+)
+
+// Custom command templates support these placeholders:
+// {1}, {2}, …			: the arguments after the keyword, empty if not given
+// {args}				: all arguments
+// {USER_USERNAME}, {USER_ID}, {USER_DISCRIMINATOR}, {USER_MENTION}, {USER_AVATARURL}
+// {CHANNEL_NAME}, {CHANNEL_ID}, {CHANNEL_MENTION}, {GUILD_NAME}, {GUILD_ID}
+// {random:a;b;c}		: one of the choices, choices can contain placeholders
+// the result is sent as embed if it is embed code
+
+var (
+	customCommandsRandomRegex      = regexp.MustCompile(`\{random:((?:[^{}]|\{[^{}]*\})*)\}`)
+	customCommandsPlaceholderRegex = regexp.MustCompile(`\{([A-Za-z_]+|[0-9]+)\}`)
+)
+
+// renderCustomCommandTemplate fills in the placeholders of a custom command template
+// arguments are inserted as they are, placeholders inside of them are not replaced
+func renderCustomCommandTemplate(template string, args []string, placeholders map[string]string, intn func(n int) int) string {
+	template = customCommandsRandomRegex.ReplaceAllStringFunc(template, func(block string) string {
+		choices := strings.Split(customCommandsRandomRegex.FindStringSubmatch(block)[1], ";")
+		return choices[intn(len(choices))]
+	})
+
+	return customCommandsPlaceholderRegex.ReplaceAllStringFunc(template, func(placeholder string) string {
+		name := placeholder[1 : len(placeholder)-1]
+
+		if position, err := strconv.Atoi(name); err == nil {
+			if position >= 1 && position <= len(args) {
+				return args[position-1]
+			}
+			return ""
+		}
+
+		if strings.ToLower(name) == "args" {
+			return strings.Join(args, " ")
+		}
+
+		if value, ok := placeholders[name]; ok {
+			return value
+		}
+		return placeholder
+	})
+}
+
+// getCustomCommandPlaceholders returns the values of the user, channel and guild placeholders for a message
+func getCustomCommandPlaceholders(msg *discordgo.Message, channel *discordgo.Channel) map[string]string {
+	placeholders := map[string]string{
+		"USER_USERNAME":      msg.Author.Username,
+		"USER_ID":            msg.Author.ID,
+		"USER_DISCRIMINATOR": msg.Author.Discriminator,
+		"USER_MENTION":       fmt.Sprintf("<@%s>", msg.Author.ID),
+		"USER_AVATARURL":     msg.Author.AvatarURL(""),
+		"CHANNEL_NAME":       channel.Name,
+		"CHANNEL_ID":         channel.ID,
+		"CHANNEL_MENTION":    fmt.Sprintf("<#%s>", channel.ID),
+		"GUILD_ID":           channel.GuildID,
+	}
+
+	guild, err := helpers.GetGuildWithoutApi(channel.GuildID)
+	if err == nil {
+		placeholders["GUILD_NAME"] = guild.Name
+	}
+
+	return placeholders
+}
+
+// renderCustomCommand renders the template of a custom command for a message, arguments can not mention everyone
+func renderCustomCommand(template string, args []string, msg *discordgo.Message, channel *discordgo.Channel) string {
+	cleanArgs := make([]string, len(args))
+	for i, arg := range args {
+		cleanArgs[i] = helpers.CleanDiscordContent(arg)
+	}
+
+	return renderCustomCommandTemplate(template, cleanArgs, getCustomCommandPlaceholders(msg, channel), rand.Intn)
+}
@@ -0,0 +1,55 @@
+package plugins
+
+import (
+	"testing"
+)
+
+func TestRenderCustomCommandTemplate(t *testing.T) {
+	placeholders := map[string]string{"USER_MENTION": "<@1>", "GUILD_NAME": "Robyul"}
+	first := func(n int) int { return 0 }
+	last := func(n int) int { return n - 1 }
+
+	tests := []struct {
+		template string
+		args     []string
+		intn     func(n int) int
+		expected string
+	}{
+		{"hello {1}!", []string{"world"}, first, "hello world!"},
+		{"{1} and {2}", []string{"a"}, first, "a and "},
+		{"you said: {args}", []string{"a", "b", "c"}, first, "you said: a b c"},
+		{"{USER_MENTION} welcome to {GUILD_NAME}", nil, first, "<@1> welcome to Robyul"},
+		{"{random:yes;no;maybe}", nil, last, "maybe"},
+		{"{random:hi {USER_MENTION};bye}", nil, first, "hi <@1>"},
+		{"{unknown} stays", nil, first, "{unknown} stays"},
+		{"{1}", []string{"{USER_MENTION}"}, first, "{USER_MENTION}"},
+	}
+
+	for _, test := range tests {
+		if result := renderCustomCommandTemplate(test.template, test.args, placeholders, test.intn); result != test.expected {
+			t.Errorf("plugins.renderCustomCommandTemplate(%q, %q) returned %q, expected %q", test.template, test.args, result, test.expected)
+		}
+	}
+}
+
+func TestCustomCommandsMatchCommand(t *testing.T) {
+	cc := &CustomCommands{}
+
+	tests := []struct {
+		content string
+		matched bool
+		args    int
+	}{
+		{"_hello", true, 0},
+		{"_hello a b", true, 2},
+		{"_helloworld", false, 0},
+		{"_hell", false, 0},
+	}
+
+	for _, test := range tests {
+		args, matched := cc.matchCommand(test.content, "_hello")
+		if matched != test.matched || len(args) != test.args {
+			t.Errorf("plugins.CustomCommands.matchCommand(%q) returned %q, %t", test.content, args, matched)
+		}
+	}
+}
@@ -143,13 +143,7 @@ func (h *Handler) OnReactionAdd(reaction *discordgo.MessageReactionAdd, session
 	}
 
 	// check if happend in log channel
-	var logChannel bool
-	for _, logChannelID := range helpers.GuildSettingsGetCached(channel.GuildID).EventlogChannelIDs {
-		if logChannelID == reaction.ChannelID {
-			logChannel = true
-		}
-	}
-	if !logChannel {
+	if !helpers.IsEventlogChannel(channel.GuildID, reaction.ChannelID) {
 		return
 	}
 
@@ -402,21 +396,6 @@ func (h *Handler) OnGuildRoleCreate(session *discordgo.Session, role *discordgo.
 	}()
 }
 
-func (h *Handler) OnGuildRoleDelete(session *discordgo.Session, role *discordgo.GuildRoleDelete) {
-	go func() {
-		defer helpers.Recover()
-
-		leftAt := time.Now()
-
-		added, err := helpers.EventlogLog(leftAt, role.GuildID, role.RoleID, models.EventlogTargetTypeRole, "", models.EventlogTypeRoleDelete, "", nil, nil, true)
-		helpers.RelaxLog(err)
-		if added {
-			err := helpers.RequestAuditLogBackfill(role.GuildID, models.AuditLogBackfillTypeRoleDelete, "")
-			helpers.RelaxLog(err)
-		}
-	}()
-}
-
 func (h *Handler) OnGuildBanAdd(user *discordgo.GuildBanAdd, session *discordgo.Session) {
 	if helpers.GetMemberPermissions(user.GuildID, cache.GetSession().State.User.ID)&discordgo.PermissionBanMembers != discordgo.PermissionBanMembers &&
 		helpers.GetMemberPermissions(user.GuildID, cache.GetSession().State.User.ID)&discordgo.PermissionAdministrator != discordgo.PermissionAdministrator {
@@ -0,0 +1,113 @@
+package eventlog
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Seklfreak/Robyul2/helpers"
+	"github.com/bwmarrin/discordgo"
+)
+
+var (
+	exportTimeLayouts = []string{"2006-01-02T15:04", "2006-01-02"}
+)
+
+// [p]eventlog export <from> [<to>] [csv or ndjson] [type=<action types>] [user=<user>]
+// from and to are either dates like 2018-05-01 or times ago like 30m, 2h or 7d, to defaults to now
+func (h *Handler) actionExport(args []string, in *discordgo.Message, out **discordgo.MessageSend) action {
+	if !helpers.IsMod(in) {
+		*out = h.newMsg("mod.no_permission")
+		return h.actionFinish
+	}
+
+	if len(args) < 2 {
+		*out = h.newMsg("bot.arguments.too-few")
+		return h.actionFinish
+	}
+
+	channel, err := helpers.GetChannel(in.ChannelID)
+	helpers.Relax(err)
+
+	now := time.Now()
+	filter := helpers.EventlogExportFilter{To: now}
+	format := helpers.EventlogExportFormatCSV
+
+	filter.From, err = parseExportTime(now, args[1])
+	if err != nil {
+		*out = h.newMsg("plugins.eventlog.export-invalid-time")
+		return h.actionFinish
+	}
+
+	for i, arg := range args[2:] {
+		switch {
+		case strings.EqualFold(arg, string(helpers.EventlogExportFormatCSV)):
+			format = helpers.EventlogExportFormatCSV
+		case strings.EqualFold(arg, string(helpers.EventlogExportFormatNDJSON)), strings.EqualFold(arg, "json"):
+			format = helpers.EventlogExportFormatNDJSON
+		case strings.HasPrefix(strings.ToLower(arg), "type="):
+			filter.ActionTypes = strings.Split(arg[len("type="):], ",")
+		case strings.HasPrefix(strings.ToLower(arg), "user="):
+			targetUser, err := helpers.GetUserFromMention(arg[len("user="):])
+			if err != nil || targetUser == nil {
+				*out = h.newMsg("bot.arguments.invalid")
+				return h.actionFinish
+			}
+			filter.UserID = targetUser.ID
+		case i == 0:
+			filter.To, err = parseExportTime(now, arg)
+			if err != nil {
+				*out = h.newMsg("plugins.eventlog.export-invalid-time")
+				return h.actionFinish
+			}
+		default:
+			*out = h.newMsg("bot.arguments.invalid")
+			return h.actionFinish
+		}
+	}
+
+	if !filter.From.Before(filter.To) {
+		*out = h.newMsg("plugins.eventlog.export-invalid-time")
+		return h.actionFinish
+	}
+
+	link, count, err := helpers.ExportEventlog(channel.GuildID, in.Author.ID, filter, format)
+	if err == helpers.ErrEventlogExportEmpty {
+		*out = h.newMsg("plugins.eventlog.export-empty")
+		return h.actionFinish
+	}
+	helpers.Relax(err)
+
+	*out = h.newMsg("plugins.eventlog.export-done", count, link)
+	if count >= helpers.EventlogExportMaxEntries {
+		*out = h.newMsg("plugins.eventlog.export-done-truncated", count, link)
+	}
+	return h.actionFinish
+}
+
+// parseExportTime parses dates like 2018-05-01 or 2018-05-01T13:37 in UTC, and times ago like 30m, 2h or 7d
+func parseExportTime(now time.Time, text string) (result time.Time, err error) {
+	if parts := undoSinceRegex.FindStringSubmatch(text); parts != nil {
+		amount, err := strconv.Atoi(parts[1])
+		if err != nil {
+			return result, err
+		}
+
+		unit := time.Minute
+		switch parts[2] {
+		case "h":
+			unit = time.Hour
+		case "d":
+			unit = 24 * time.Hour
+		}
+		return now.Add(-time.Duration(amount) * unit), nil
+	}
+
+	for _, layout := range exportTimeLayouts {
+		result, err = time.Parse(layout, text)
+		if err == nil {
+			return result, nil
+		}
+	}
+	return result, err
+}
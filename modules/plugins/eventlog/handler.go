@@ -31,10 +31,12 @@ func (h *Handler) Init(session *discordgo.Session) {
 	session.AddHandler(h.OnChannelCreate)
 	session.AddHandler(h.OnChannelDelete)
 	session.AddHandler(h.OnGuildRoleCreate)
-	session.AddHandler(h.OnGuildRoleDelete)
 
 	go auditlogBackfillLoop()
 	logger().Info("started auditlogBackfillLoop loop (1m)")
+
+	go retentionLoop()
+	logger().Info("started retentionLoop loop (6h)")
 }
 
 func (h *Handler) Uninit(session *discordgo.Session) {
@@ -69,6 +71,14 @@ func (h *Handler) actionStart(args []string, in *discordgo.Message, out **discor
 	switch strings.ToLower(args[0]) {
 	case "set-log", "set-log-channel":
 		return h.actionSetLogChannel
+	case "undo":
+		return h.actionUndo
+	case "rules", "rule":
+		return h.actionRules
+	case "export":
+		return h.actionExport
+	case "retention":
+		return h.actionRetention
 	}
 
 	*out = h.newMsg("bot.arguments.invalid")
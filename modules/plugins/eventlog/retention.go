@@ -0,0 +1,147 @@
+package eventlog
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Seklfreak/Robyul2/cache"
+	"github.com/Seklfreak/Robyul2/helpers"
+	"github.com/Seklfreak/Robyul2/models"
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	retentionInterval = 6 * time.Hour
+)
+
+// retentionLoop deletes eventlog entries and messages older than the retention of their guild,
+// and presence updates older than elasticsearch.presence_updates_retention_days, presence updates do not belong to a guild
+func retentionLoop() {
+	defer helpers.Recover()
+	defer func() {
+		go func() {
+			logger().Error("the retentionLoop died. Please investigate! Will be restarted in 60 seconds")
+			time.Sleep(60 * time.Second)
+			retentionLoop()
+		}()
+	}()
+
+	for {
+		time.Sleep(retentionInterval)
+
+		if !cache.HasElastic() {
+			continue
+		}
+
+		start := time.Now()
+		var deletedEventlogs, deletedMessages, deletedPresenceUpdates int64
+
+		for _, guild := range cache.GetSession().State.Guilds {
+			settings := helpers.GuildSettingsGetCached(guild.ID)
+
+			if settings.EventlogRetentionDays > 0 {
+				deleted, err := helpers.ElasticDeleteOlderThan(models.ElasticIndexEventlogs, guild.ID,
+					time.Now().AddDate(0, 0, -settings.EventlogRetentionDays))
+				if err != nil {
+					logger().WithField("GuildID", guild.ID).Errorf("deleting old eventlog entries failed: %s", err.Error())
+				}
+				deletedEventlogs += deleted
+			}
+
+			if settings.ChatlogRetentionDays > 0 {
+				deleted, err := helpers.ElasticDeleteOlderThan(models.ElasticIndexMessages, guild.ID,
+					time.Now().AddDate(0, 0, -settings.ChatlogRetentionDays))
+				if err != nil {
+					logger().WithField("GuildID", guild.ID).Errorf("deleting old messages failed: %s", err.Error())
+				}
+				deletedMessages += deleted
+			}
+		}
+
+		if presenceRetentionDays, ok := helpers.GetConfig().Path("elasticsearch.presence_updates_retention_days").Data().(float64); ok && presenceRetentionDays > 0 {
+			deleted, err := helpers.ElasticDeleteOlderThan(models.ElasticIndexPresenceUpdates, "",
+				time.Now().AddDate(0, 0, -int(presenceRetentionDays)))
+			if err != nil {
+				logger().Errorf("deleting old presence updates failed: %s", err.Error())
+			}
+			deletedPresenceUpdates += deleted
+		}
+
+		logger().Infof("deleted %d eventlog entries, %d messages and %d presence updates past their retention, took %s",
+			deletedEventlogs, deletedMessages, deletedPresenceUpdates, time.Since(start).String())
+	}
+}
+
+// [p]eventlog retention
+// [p]eventlog retention <eventlog or messages> <days or off>
+func (h *Handler) actionRetention(args []string, in *discordgo.Message, out **discordgo.MessageSend) action {
+	if !helpers.IsAdmin(in) {
+		*out = h.newMsg("admin.no_permission")
+		return h.actionFinish
+	}
+
+	channel, err := helpers.GetChannel(in.ChannelID)
+	helpers.Relax(err)
+
+	settings := helpers.GuildSettingsGetCached(channel.GuildID)
+
+	if len(args) < 3 {
+		*out = h.newMsg("plugins.eventlog.retention-status",
+			formatRetentionDays(settings.EventlogRetentionDays), formatRetentionDays(settings.ChatlogRetentionDays))
+		return h.actionFinish
+	}
+
+	var days int
+	if strings.ToLower(args[2]) != "off" {
+		days, err = strconv.Atoi(strings.TrimSuffix(strings.ToLower(args[2]), "d"))
+		if err != nil || days < helpers.EventlogRetentionMinDays || days > helpers.EventlogRetentionMaxDays {
+			*out = h.newMsg("plugins.eventlog.retention-invalid-days",
+				helpers.EventlogRetentionMinDays, helpers.EventlogRetentionMaxDays)
+			return h.actionFinish
+		}
+	}
+
+	var key string
+	var oldDays int
+	switch strings.ToLower(args[1]) {
+	case "eventlog":
+		key = "eventlog_retention_days"
+		oldDays = settings.EventlogRetentionDays
+		settings.EventlogRetentionDays = days
+	case "messages", "chatlog":
+		key = "chatlog_retention_days"
+		oldDays = settings.ChatlogRetentionDays
+		settings.ChatlogRetentionDays = days
+	default:
+		*out = h.newMsg("bot.arguments.invalid")
+		return h.actionFinish
+	}
+
+	_, err = helpers.EventlogLog(time.Now(), channel.GuildID, channel.GuildID,
+		models.EventlogTargetTypeGuild, in.Author.ID,
+		models.EventlogTypeRobyulEventlogConfigUpdate, "",
+		[]models.ElasticEventlogChange{
+			{
+				Key:      key,
+				OldValue: strconv.Itoa(oldDays),
+				NewValue: strconv.Itoa(days),
+			},
+		},
+		nil, false)
+	helpers.RelaxLog(err)
+
+	err = helpers.GuildSettingsSet(channel.GuildID, settings)
+	helpers.Relax(err)
+
+	*out = h.newMsg("plugins.eventlog.retention-set",
+		formatRetentionDays(settings.EventlogRetentionDays), formatRetentionDays(settings.ChatlogRetentionDays))
+	return h.actionFinish
+}
+
+func formatRetentionDays(days int) string {
+	if days <= 0 {
+		return "forever"
+	}
+	return strconv.Itoa(days) + " days"
+}
@@ -0,0 +1,106 @@
+package eventlog
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/Seklfreak/Robyul2/helpers"
+	"github.com/Seklfreak/Robyul2/models"
+	"github.com/bwmarrin/discordgo"
+)
+
+// [p]eventlog rules
+// [p]eventlog rules add <drop or #channel> [type=<action types>] [target=<target types>] [user=<users>] [role=<roles>] [channel=<channels>] [change=<change keys>]
+// [p]eventlog rules remove <rule number>
+func (h *Handler) actionRules(args []string, in *discordgo.Message, out **discordgo.MessageSend) action {
+	if !helpers.IsMod(in) {
+		*out = h.newMsg("mod.no_permission")
+		return h.actionFinish
+	}
+
+	channel, err := helpers.GetChannel(in.ChannelID)
+	helpers.Relax(err)
+
+	rules := helpers.GuildSettingsGetCached(channel.GuildID).EventlogRules
+
+	if len(args) < 2 {
+		if len(rules) <= 0 {
+			*out = h.newMsg("plugins.eventlog.rules-none")
+			return h.actionFinish
+		}
+
+		rulesText := helpers.GetText("plugins.eventlog.rules-list-title") + "\n"
+		for i, rule := range rules {
+			rulesText += "`#" + strconv.Itoa(i+1) + "` " + helpers.FormatEventlogRule(rule) + "\n"
+		}
+		rulesText += helpers.GetText("plugins.eventlog.rules-list-footer")
+
+		for _, page := range helpers.Pagify(rulesText, "\n") {
+			_, err = helpers.SendMessage(in.ChannelID, page)
+			helpers.RelaxMessage(err, in.ChannelID, in.ID)
+		}
+		return nil
+	}
+
+	switch strings.ToLower(args[1]) {
+	case "add":
+		if len(args) < 4 {
+			*out = h.newMsg("bot.arguments.too-few")
+			return h.actionFinish
+		}
+
+		if len(rules) >= helpers.EventlogRulesMax {
+			*out = h.newMsg("plugins.eventlog.rules-too-many", helpers.EventlogRulesMax)
+			return h.actionFinish
+		}
+
+		rule, err := helpers.ParseEventlogRule(strings.Join(args[2:], " "))
+		if err != nil {
+			*out = h.newMsg("plugins.eventlog.rules-invalid", err.Error())
+			return h.actionFinish
+		}
+
+		if rule.Action == models.EventlogRuleActionRoute {
+			targetChannel, err := helpers.GetChannel(rule.ChannelID)
+			if err != nil || targetChannel.GuildID != channel.GuildID {
+				*out = h.newMsg("bot.arguments.invalid")
+				return h.actionFinish
+			}
+		}
+
+		newRules := make([]models.EventlogRule, 0, len(rules)+1)
+		newRules = append(newRules, rules...)
+		newRules = append(newRules, rule)
+
+		err = helpers.SetEventlogRules(channel.GuildID, in.Author.ID, newRules)
+		helpers.Relax(err)
+
+		*out = h.newMsg("plugins.eventlog.rules-added", len(newRules), helpers.FormatEventlogRule(rule))
+		return h.actionFinish
+	case "remove", "delete":
+		if len(args) < 3 {
+			*out = h.newMsg("bot.arguments.too-few")
+			return h.actionFinish
+		}
+
+		number, err := strconv.Atoi(strings.TrimPrefix(args[2], "#"))
+		if err != nil || number < 1 || number > len(rules) {
+			*out = h.newMsg("plugins.eventlog.rules-not-found")
+			return h.actionFinish
+		}
+
+		removedRule := rules[number-1]
+		newRules := make([]models.EventlogRule, 0, len(rules)-1)
+		newRules = append(newRules, rules[:number-1]...)
+		newRules = append(newRules, rules[number:]...)
+
+		err = helpers.SetEventlogRules(channel.GuildID, in.Author.ID, newRules)
+		helpers.Relax(err)
+
+		*out = h.newMsg("plugins.eventlog.rules-removed", number, helpers.FormatEventlogRule(removedRule))
+		return h.actionFinish
+	}
+
+	*out = h.newMsg("bot.arguments.invalid")
+	return h.actionFinish
+}
@@ -0,0 +1,141 @@
+package eventlog
+
+import (
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/Seklfreak/Robyul2/helpers"
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	// the maximum of eventlog entries undone at once
+	undoMaxEntries = 500
+	undoMaxAge     = 7 * 24 * time.Hour
+	// how often the progress message gets updated
+	undoProgressInterval = 5 * time.Second
+)
+
+var (
+	undoSinceRegex = regexp.MustCompile(`^([0-9]+)(m|h|d)$`)
+)
+
+// [p]eventlog undo <user> <since, for example 30m, 2h or 1d>
+// reverts every revertable action by the user since the given time, newest first, using the revert bucket of the author
+func (h *Handler) actionUndo(args []string, in *discordgo.Message, out **discordgo.MessageSend) action {
+	if !helpers.IsAdmin(in) {
+		*out = h.newMsg("admin.no_permission")
+		return h.actionFinish
+	}
+
+	if len(args) < 3 {
+		*out = h.newMsg("bot.arguments.too-few")
+		return h.actionFinish
+	}
+
+	targetUser, err := helpers.GetUserFromMention(args[1])
+	if err != nil || targetUser == nil {
+		*out = h.newMsg("bot.arguments.invalid")
+		return h.actionFinish
+	}
+
+	since, ok := parseUndoSince(time.Now(), args[2])
+	if !ok {
+		*out = h.newMsg("plugins.eventlog.undo-invalid-since")
+		return h.actionFinish
+	}
+
+	channel, err := helpers.GetChannel(in.ChannelID)
+	helpers.Relax(err)
+
+	items, err := helpers.GetElasticEventlogsByUser(channel.GuildID, targetUser.ID, since, undoMaxEntries)
+	helpers.Relax(err)
+
+	revertableItems := make([]helpers.GetElasticEventlogsResult, 0)
+	for _, item := range items {
+		if helpers.CanRevert(item.Entry) {
+			revertableItems = append(revertableItems, item)
+		}
+	}
+
+	if len(revertableItems) <= 0 {
+		*out = h.newMsg("plugins.eventlog.undo-nothing", targetUser.Username)
+		return h.actionFinish
+	}
+
+	if !helpers.ConfirmEmbed(in.ChannelID, in.Author,
+		helpers.GetTextF("plugins.eventlog.undo-confirm", len(revertableItems), targetUser.Username, since.UTC().Format(time.ANSIC)),
+		"✅", "🚫") {
+		return nil
+	}
+
+	progressMessages, err := helpers.SendMessage(in.ChannelID,
+		helpers.GetTextF("plugins.eventlog.undo-progress", targetUser.Username, 0, len(revertableItems), 0))
+	helpers.RelaxMessage(err, in.ChannelID, in.ID)
+
+	var reverted, failed int
+	lastProgress := time.Now()
+	for i, item := range revertableItems {
+		// wait for the revert bucket to refill instead of failing
+		for Container.Drain(1, in.Author.ID) != nil {
+			time.Sleep(DROP_INTERVAL)
+		}
+
+		err = helpers.Revert(item.ElasticID, in.Author.ID, item.Entry)
+		if err != nil {
+			failed++
+			logger().WithField("GuildID", channel.GuildID).WithField("EventlogID", item.ElasticID).Warnf(
+				"undoing %s failed: %s", item.Entry.ActionType, err.Error())
+		} else {
+			reverted++
+		}
+
+		if len(progressMessages) > 0 && time.Since(lastProgress) > undoProgressInterval && i+1 < len(revertableItems) {
+			helpers.EditMessage(in.ChannelID, progressMessages[0].ID,
+				helpers.GetTextF("plugins.eventlog.undo-progress", targetUser.Username, i+1, len(revertableItems), failed))
+			lastProgress = time.Now()
+		}
+	}
+
+	logger().WithField("GuildID", channel.GuildID).WithField("UserID", in.Author.ID).Infof(
+		"undid %d actions by %s since %s, %d failed", reverted, targetUser.ID, since.Format(time.RFC3339), failed)
+
+	doneText := helpers.GetTextF("plugins.eventlog.undo-done", reverted, targetUser.Username, failed)
+	if len(progressMessages) > 0 {
+		_, err = helpers.EditMessage(in.ChannelID, progressMessages[0].ID, doneText)
+		if err == nil {
+			return nil
+		}
+	}
+	*out = &discordgo.MessageSend{Content: doneText}
+	return h.actionFinish
+}
+
+// parseUndoSince returns the time a duration like 30m, 2h or 1d ago, durations over undoMaxAge are invalid
+func parseUndoSince(now time.Time, text string) (since time.Time, ok bool) {
+	parts := undoSinceRegex.FindStringSubmatch(text)
+	if parts == nil {
+		return time.Time{}, false
+	}
+
+	amount, err := strconv.Atoi(parts[1])
+	if err != nil || amount <= 0 {
+		return time.Time{}, false
+	}
+
+	unit := time.Minute
+	switch parts[2] {
+	case "h":
+		unit = time.Hour
+	case "d":
+		unit = 24 * time.Hour
+	}
+
+	duration := time.Duration(amount) * unit
+	if duration <= 0 || duration > undoMaxAge {
+		return time.Time{}, false
+	}
+
+	return now.Add(-duration), true
+}
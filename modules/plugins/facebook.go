@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/Seklfreak/Robyul2/cache"
+	"github.com/Seklfreak/Robyul2/feedpoller"
 	"github.com/Seklfreak/Robyul2/helpers"
 	"github.com/Seklfreak/Robyul2/models"
 	"github.com/bwmarrin/discordgo"
@@ -55,101 +56,98 @@ func (m *Facebook) Commands() []string {
 }
 
 func (m *Facebook) Init(session *discordgo.Session) {
-	go m.checkFacebookFeedsLoop()
-	cache.GetLogger().WithField("module", "facebook").Info("Started Facebook loop (10m)")
+	feedpoller.Start(m, feedpoller.Options{
+		Name:        "facebook",
+		Interval:    10 * time.Minute,
+		Jitter:      0.2,
+		Workers:     1,
+		MaxBackoff:  time.Hour,
+		ErrorBudget: 10,
+		MinDelay:    10 * time.Second,
+	})
 }
-func (m *Facebook) checkFacebookFeedsLoop() {
-	log := cache.GetLogger()
-
-	defer helpers.Recover()
-	defer func() {
-		go func() {
-			log.WithField("module", "facebook").Error("The checkFacebookFeedsLoop died. Please investigate! Will be restarted in 60 seconds")
-			time.Sleep(60 * time.Second)
-			m.checkFacebookFeedsLoop()
-		}()
-	}()
 
+// Targets returns the Facebook pages of all feeds in channels the bot can see
+func (m *Facebook) Targets() (targets []string, err error) {
 	var entries []models.FacebookEntry
-	var bundledEntries map[string][]models.FacebookEntry
-
-	for {
-		err := helpers.MDbIter(helpers.MdbCollection(models.FacebookTable).Find(nil)).All(&entries)
-		helpers.Relax(err)
-
-		bundledEntries = make(map[string][]models.FacebookEntry, 0)
+	err = helpers.MDbIterWithoutLogging(helpers.MdbCollection(models.FacebookTable).Find(nil)).All(&entries)
+	if err != nil {
+		return nil, err
+	}
 
-		for _, entry := range entries {
-			channel, err := helpers.GetChannelWithoutApi(entry.ChannelID)
-			if err != nil || channel == nil || channel.ID == "" {
-				//cache.GetLogger().WithField("module", "facebook").Warn(fmt.Sprintf("skipped facebook @%s for Channel #%s on Guild #%s: channel not found!",
-				//	entry.Username, entry.ChannelID, entry.ServerID))
-				continue
-			}
+	added := make(map[string]bool)
+	for _, entry := range entries {
+		if added[entry.Username] {
+			continue
+		}
 
-			if _, ok := bundledEntries[entry.Username]; ok {
-				bundledEntries[entry.Username] = append(bundledEntries[entry.Username], entry)
-			} else {
-				bundledEntries[entry.Username] = []models.FacebookEntry{entry}
-			}
+		channel, err := helpers.GetChannelWithoutApi(entry.ChannelID)
+		if err != nil || channel == nil || channel.ID == "" {
+			continue
 		}
 
-		cache.GetLogger().WithField("module", "facebook").Infof("checking %d pages for %d feeds", len(bundledEntries), len(entries))
+		targets = append(targets, entry.Username)
+		added[entry.Username] = true
+	}
 
-		for facebookUsername, entries := range bundledEntries {
-			//log.WithField("module", "facebook").Debug(fmt.Sprintf("checking Facebook Page %s", facebookUsername))
+	return targets, nil
+}
 
-			facebookPage, err := m.lookupFacebookPage(facebookUsername)
-			if err != nil {
-				if strings.Contains(err.Error(), "Application request limit reached") {
-					log.WithField("module", "facebook").Infoln("facebook api limit reached, retrying in one minute")
-					time.Sleep(time.Minute)
-					continue
-				}
-				log.WithField("module", "facebook").Warnf("updating facebook account %s failed: %s", facebookUsername, err.Error())
-				continue
-			}
+// Check posts new posts of the Facebook page to all feeds of it
+func (m *Facebook) Check(facebookUsername string) (err error) {
+	var entries []models.FacebookEntry
+	err = helpers.MDbIterWithoutLogging(helpers.MdbCollection(models.FacebookTable).Find(
+		bson.M{"username": facebookUsername},
+	)).All(&entries)
+	if err != nil {
+		return err
+	}
 
-			// https://github.com/golang/go/wiki/SliceTricks#reversing
-			for i := len(facebookPage.Posts)/2 - 1; i >= 0; i-- {
-				opp := len(facebookPage.Posts) - 1 - i
-				facebookPage.Posts[i], facebookPage.Posts[opp] = facebookPage.Posts[opp], facebookPage.Posts[i]
-			}
+	facebookPage, err := m.lookupFacebookPage(facebookUsername)
+	if err != nil {
+		if strings.Contains(err.Error(), "Application request limit reached") {
+			return &feedpoller.RateLimitError{Err: err, RetryAfter: time.Minute}
+		}
+		return err
+	}
 
-			for _, entry := range entries {
-				changes := false
+	// https://github.com/golang/go/wiki/SliceTricks#reversing
+	for i := len(facebookPage.Posts)/2 - 1; i >= 0; i-- {
+		opp := len(facebookPage.Posts) - 1 - i
+		facebookPage.Posts[i], facebookPage.Posts[opp] = facebookPage.Posts[opp], facebookPage.Posts[i]
+	}
 
-				for _, post := range facebookPage.Posts {
-					postAlreadyPosted := false
-					for _, postedPost := range entry.PostedPosts {
-						if postedPost.ID == post.ID {
-							postAlreadyPosted = true
-						}
-					}
-					if postAlreadyPosted == false {
-						log.WithField("module", "facebook").Info(fmt.Sprintf("Posting Post: #%s", post.ID))
-						entry.PostedPosts = append(entry.PostedPosts, models.FacebookPostEntry{ID: post.ID, CreatedAt: post.CreatedAt})
-						changes = true
-						go m.postPostToChannel(entry.ChannelID, post, facebookPage)
-					}
+	for _, entry := range entries {
+		changes := false
 
-				}
-				if changes == true {
-					err = helpers.MDbUpsertID(
-						models.FacebookTable,
-						entry.ID,
-						entry,
-					)
-					helpers.Relax(err)
+		for _, post := range facebookPage.Posts {
+			postAlreadyPosted := false
+			for _, postedPost := range entry.PostedPosts {
+				if postedPost.ID == post.ID {
+					postAlreadyPosted = true
 				}
 			}
-			time.Sleep(10 * time.Second)
-		}
+			if postAlreadyPosted == false {
+				cache.GetLogger().WithField("module", "facebook").Info(fmt.Sprintf("Posting Post: #%s", post.ID))
+				entry.PostedPosts = append(entry.PostedPosts, models.FacebookPostEntry{ID: post.ID, CreatedAt: post.CreatedAt})
+				changes = true
+				go m.postPostToChannel(entry, post, facebookPage)
+			}
 
-		if len(entries) <= 10 {
-			time.Sleep(1 * time.Minute)
+		}
+		if changes == true {
+			err = helpers.MDbUpsertID(
+				models.FacebookTable,
+				entry.ID,
+				entry,
+			)
+			if err != nil {
+				return err
+			}
 		}
 	}
+
+	return nil
 }
 
 func (m *Facebook) Action(command string, content string, msg *discordgo.Message, session *discordgo.Session) {
@@ -160,7 +158,7 @@ func (m *Facebook) Action(command string, content string, msg *discordgo.Message
 	args := strings.Fields(content)
 	if len(args) >= 1 {
 		switch args[0] {
-		case "add": // [p]facebook add <facebook page name> <discord channel>
+		case "add": // [p]facebook add <facebook page name> <discord channel> [feed options]
 			helpers.RequireMod(msg, func() {
 				session.ChannelTyping(msg.ChannelID)
 				// get target channel
@@ -179,6 +177,15 @@ func (m *Facebook) Action(command string, content string, msg *discordgo.Message
 				}
 				targetGuild, err = helpers.GetGuild(targetChannel.GuildID)
 				helpers.Relax(err)
+				feedOptions, rest, err := helpers.ParseFeedOptions(targetGuild.ID, args[3:], content)
+				if err != nil {
+					helpers.SendMessage(msg.ChannelID, helpers.GetTextF("bot.arguments.invalid-feed-options", err.Error()))
+					return
+				}
+				if len(rest) > 0 {
+					helpers.SendMessage(msg.ChannelID, helpers.GetTextF("bot.arguments.invalid"))
+					return
+				}
 				// get facebook account and tweets
 				facebookPage, err := m.lookupFacebookPage(args[1])
 				if err != nil {
@@ -205,6 +212,7 @@ func (m *Facebook) Action(command string, content string, msg *discordgo.Message
 						ChannelID:   targetChannel.ID,
 						Username:    facebookPage.Username,
 						PostedPosts: dbPosts,
+						FeedOptions: feedOptions,
 					},
 				)
 				helpers.Relax(err)
@@ -213,7 +221,7 @@ func (m *Facebook) Action(command string, content string, msg *discordgo.Message
 					models.EventlogTargetTypeRobyulFacebookFeed, msg.Author.ID,
 					models.EventlogTypeRobyulFacebookFeedAdd, "",
 					nil,
-					[]models.ElasticEventlogOption{
+					append([]models.ElasticEventlogOption{
 						{
 							Key:   "facebook_channelid",
 							Value: targetChannel.ID,
@@ -223,7 +231,7 @@ func (m *Facebook) Action(command string, content string, msg *discordgo.Message
 							Key:   "facebook_facebookusername",
 							Value: facebookPage.Username,
 						},
-					}, false)
+					}, helpers.FeedOptionsEventlogOptions("facebook", feedOptions)...), false)
 				helpers.RelaxLog(err)
 
 				helpers.SendMessage(msg.ChannelID, helpers.GetTextF("plugins.facebook.account-added-success", facebookPage.Username, targetChannel.ID))
@@ -250,7 +258,7 @@ func (m *Facebook) Action(command string, content string, msg *discordgo.Message
 							models.EventlogTargetTypeRobyulFacebookFeed, msg.Author.ID,
 							models.EventlogTypeRobyulFacebookFeedRemove, "",
 							nil,
-							[]models.ElasticEventlogOption{
+							append([]models.ElasticEventlogOption{
 								{
 									Key:   "facebook_channelid",
 									Value: entryBucket.ChannelID,
@@ -259,7 +267,7 @@ func (m *Facebook) Action(command string, content string, msg *discordgo.Message
 									Key:   "facebook_facebookusername",
 									Value: entryBucket.Username,
 								},
-							}, false)
+							}, helpers.FeedOptionsEventlogOptions("facebook", entryBucket.FeedOptions)...), false)
 						helpers.RelaxLog(err)
 
 						helpers.SendMessage(msg.ChannelID, helpers.GetTextF("plugins.facebook.account-delete-success", entryBucket.Username))
@@ -289,7 +297,8 @@ func (m *Facebook) Action(command string, content string, msg *discordgo.Message
 
 			resultMessage := ""
 			for _, entry := range entryBucket {
-				resultMessage += fmt.Sprintf("`%s`: Facebook Page `%s` posting to <#%s>\n", helpers.MdbIdToHuman(entry.ID), entry.Username, entry.ChannelID)
+				resultMessage += fmt.Sprintf("`%s`: Facebook Page `%s` posting to <#%s>%s\n", helpers.MdbIdToHuman(entry.ID), entry.Username, entry.ChannelID,
+					helpers.FeedOptionsText(currentChannel.GuildID, entry.FeedOptions))
 			}
 			resultMessage += fmt.Sprintf("Found **%d** Facebook Pages in total.", len(entryBucket))
 			for _, resultPage := range helpers.Pagify(resultMessage, "\n") {
@@ -436,7 +445,17 @@ func (m *Facebook) lookupFacebookPage(siteName string) (Facebook_Page, error) {
 	return facebookPage, nil
 }
 
-func (m *Facebook) postPostToChannel(channelID string, post Facebook_Post, facebookPage Facebook_Page) {
+func (m *Facebook) postPostToChannel(entry models.FacebookEntry, post Facebook_Post, facebookPage Facebook_Page) {
+	feedPost := helpers.FeedPost{
+		Author:   facebookPage.Name,
+		URL:      post.Url,
+		Content:  post.Message,
+		MediaURL: post.PictureUrl,
+	}
+	if !helpers.FeedPostMatches(entry.FeedOptions, feedPost) {
+		return
+	}
+
 	facebookNameModifier := ""
 	if facebookPage.Verified {
 		facebookNameModifier += " ☑"
@@ -458,11 +477,11 @@ func (m *Facebook) postPostToChannel(channelID string, post Facebook_Post, faceb
 		channelEmbed.Image = &discordgo.MessageEmbedImage{URL: post.PictureUrl}
 	}
 
-	_, err := helpers.SendComplex(channelID, &discordgo.MessageSend{
+	_, err := helpers.SendComplex(entry.ChannelID, helpers.FeedMessageSend(entry.FeedOptions, feedPost, &discordgo.MessageSend{
 		Content: fmt.Sprintf("<%s>", post.Url),
 		Embed:   channelEmbed,
-	})
+	}))
 	if err != nil {
-		cache.GetLogger().WithField("module", "facebook").Warnf("posting post: #%s to channel: #%s failed: %s", post.ID, channelID, err)
+		cache.GetLogger().WithField("module", "facebook").Warnf("posting post: #%s to channel: #%s failed: %s", post.ID, entry.ChannelID, err)
 	}
 }
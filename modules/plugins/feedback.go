@@ -53,6 +53,11 @@ func (f *Feedback) Uninit(session *discordgo.Session) {
 
 }
 
+// Reloadable is true, Init only connects to trello
+func (f *Feedback) Reloadable() bool {
+	return true
+}
+
 func (f *Feedback) Action(command string, content string, msg *discordgo.Message, session *discordgo.Session) {
 	if !helpers.ModuleIsAllowed(msg.ChannelID, msg.ID, msg.Author.ID, helpers.ModulePermFeedback) {
 		return
@@ -0,0 +1,535 @@
+package feeds
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/Seklfreak/Robyul2/helpers"
+)
+
+const (
+	// maxFeedSize is the largest feed that is parsed
+	maxFeedSize = 5 * 1024 * 1024
+	// maxFeedRedirects is the number of redirects followed for a feed
+	maxFeedRedirects = 10
+)
+
+var (
+	errNotPublicAddress = errors.New("the feed is not on a public address")
+
+	// privateNetworks are the networks besides loopback and link-local addresses feeds can't be fetched from
+	privateNetworks = parseNetworks(
+		"0.0.0.0/8", "10.0.0.0/8", "100.64.0.0/10", "172.16.0.0/12", "192.0.0.0/24", "192.168.0.0/16",
+		"198.18.0.0/15", "240.0.0.0/4", "fc00::/7",
+	)
+)
+
+// Feed is a RSS, Atom or JSON Feed
+type Feed struct {
+	Title string
+	Link  string
+	// Items are sorted oldest first
+	Items []Item
+}
+
+// Item is a single post of a feed
+type Item struct {
+	GUID      string
+	Title     string
+	Link      string
+	Author    string
+	Content   string
+	ImageURL  string
+	Published time.Time
+}
+
+// fetchResult is a fetched feed, with the validators for the next conditional request
+type fetchResult struct {
+	Feed         *Feed
+	NotModified  bool
+	ETag         string
+	LastModified string
+}
+
+// newFeedClient returns a client which only connects to public addresses, so feeds can't reach the network of the bot
+// the addresses are checked after resolving the host, for the feed and for every redirect
+func newFeedClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   10 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control:   checkDialAddress,
+	}
+
+	return &http.Client{
+		Timeout: 15 * time.Second,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: 10 * time.Second,
+			IdleConnTimeout:     90 * time.Second,
+		},
+		CheckRedirect: checkFeedRedirect,
+	}
+}
+
+// checkDialAddress is the net.Dialer.Control of newFeedClient, the address has already been resolved
+func checkDialAddress(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil || !isPublicIP(ip) {
+		return errNotPublicAddress
+	}
+	return nil
+}
+
+// checkFeedRedirect only follows redirects to http and https URLs on public addresses
+func checkFeedRedirect(request *http.Request, via []*http.Request) error {
+	if len(via) >= maxFeedRedirects {
+		return fmt.Errorf("stopped after %d redirects", maxFeedRedirects)
+	}
+	if request.URL.Scheme != "http" && request.URL.Scheme != "https" {
+		return fmt.Errorf("unsupported redirect to %s", request.URL.Scheme)
+	}
+
+	addresses, err := net.DefaultResolver.LookupIPAddr(request.Context(), request.URL.Hostname())
+	if err != nil {
+		return err
+	}
+	for _, address := range addresses {
+		if !isPublicIP(address.IP) {
+			return errNotPublicAddress
+		}
+	}
+	return nil
+}
+
+// isPublicIP returns false for loopback, link-local, private, multicast and unspecified addresses
+func isPublicIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() ||
+		ip.IsMulticast() || ip.IsUnspecified() {
+		return false
+	}
+
+	for _, network := range privateNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+func parseNetworks(cidrs ...string) (networks []*net.IPNet) {
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
+
+// fetchFeed downloads and parses the feed, using a conditional request if etag or lastModified are set
+func fetchFeed(client *http.Client, feedURL, etag, lastModified string) (result fetchResult, err error) {
+	request, err := http.NewRequest("GET", feedURL, nil)
+	if err != nil {
+		return result, err
+	}
+	request.Header.Set("User-Agent", helpers.DEFAULT_UA)
+	request.Header.Set("Accept", "application/rss+xml, application/atom+xml, application/feed+json, application/json, application/xml, text/xml;q=0.9, */*;q=0.8")
+	if etag != "" {
+		request.Header.Set("If-None-Match", etag)
+	}
+	if lastModified != "" {
+		request.Header.Set("If-Modified-Since", lastModified)
+	}
+
+	response, err := client.Do(request)
+	if err != nil {
+		return result, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode == http.StatusNotModified {
+		result.NotModified = true
+		result.ETag = etag
+		result.LastModified = lastModified
+		return result, nil
+	}
+	if response.StatusCode != http.StatusOK {
+		return result, fmt.Errorf("expected status 200; got %d", response.StatusCode)
+	}
+
+	data, err := ioutil.ReadAll(io.LimitReader(response.Body, maxFeedSize))
+	if err != nil {
+		return result, err
+	}
+
+	result.Feed, err = parseFeed(data)
+	if err != nil {
+		return result, err
+	}
+	result.ETag = response.Header.Get("ETag")
+	result.LastModified = response.Header.Get("Last-Modified")
+	return result, nil
+}
+
+// parseFeed parses a RSS 2.0, Atom or JSON Feed
+func parseFeed(data []byte) (feed *Feed, err error) {
+	data = bytes.TrimSpace(data)
+	if len(data) <= 0 {
+		return nil, errors.New("empty feed")
+	}
+
+	if data[0] == '{' {
+		feed, err = parseJSONFeed(data)
+	} else {
+		feed, err = parseXMLFeed(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range feed.Items {
+		item := &feed.Items[i]
+		item.Title = strings.TrimSpace(stripHTML(item.Title))
+		item.Content = strings.TrimSpace(stripHTML(item.Content))
+		if item.GUID == "" {
+			item.GUID = item.Link
+		}
+		if item.GUID == "" {
+			item.GUID = item.Title
+		}
+	}
+	sortItems(feed.Items)
+
+	return feed, nil
+}
+
+// sortItems sorts the items oldest first, feeds without dates are assumed to be sorted newest first
+func sortItems(items []Item) {
+	for _, item := range items {
+		if item.Published.IsZero() {
+			for i := len(items)/2 - 1; i >= 0; i-- {
+				opp := len(items) - 1 - i
+				items[i], items[opp] = items[opp], items[i]
+			}
+			return
+		}
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].Published.Before(items[j].Published)
+	})
+}
+
+type rssFeed struct {
+	Channel struct {
+		Title string `xml:"title"`
+		// Links contains atom:link elements without text as well
+		Links []string  `xml:"link"`
+		Items []rssItem `xml:"item"`
+	} `xml:"channel"`
+}
+
+type rssItem struct {
+	GUID           string `xml:"guid"`
+	Title          string `xml:"title"`
+	Link           string `xml:"link"`
+	Author         string `xml:"author"`
+	Creator        string `xml:"http://purl.org/dc/elements/1.1/ creator"`
+	Description    string `xml:"description"`
+	ContentEncoded string `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+	PubDate        string `xml:"pubDate"`
+	Enclosures     []struct {
+		URL  string `xml:"url,attr"`
+		Type string `xml:"type,attr"`
+	} `xml:"enclosure"`
+	MediaContents []struct {
+		URL    string `xml:"url,attr"`
+		Medium string `xml:"medium,attr"`
+		Type   string `xml:"type,attr"`
+	} `xml:"http://search.yahoo.com/mrss/ content"`
+	MediaThumbnails []struct {
+		URL string `xml:"url,attr"`
+	} `xml:"http://search.yahoo.com/mrss/ thumbnail"`
+}
+
+type atomFeed struct {
+	Title   string      `xml:"title"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomEntry struct {
+	ID              string     `xml:"id"`
+	Title           string     `xml:"title"`
+	Links           []atomLink `xml:"link"`
+	Author          string     `xml:"author>name"`
+	Summary         string     `xml:"summary"`
+	Content         string     `xml:"content"`
+	Published       string     `xml:"published"`
+	Updated         string     `xml:"updated"`
+	MediaThumbnails []struct {
+		URL string `xml:"url,attr"`
+	} `xml:"http://search.yahoo.com/mrss/ group>thumbnail"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr"`
+}
+
+func parseXMLFeed(data []byte) (feed *Feed, err error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.Strict = false
+	decoder.CharsetReader = func(label string, input io.Reader) (io.Reader, error) {
+		// non UTF-8 feeds are parsed as is, most of them are ASCII compatible
+		return input, nil
+	}
+
+	var root xml.StartElement
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse feed: %s", err.Error())
+		}
+		if startElement, ok := token.(xml.StartElement); ok {
+			root = startElement
+			break
+		}
+	}
+
+	switch root.Name.Local {
+	case "rss":
+		var rss rssFeed
+		err = decoder.DecodeElement(&rss, &root)
+		if err != nil {
+			return nil, err
+		}
+		return rss.toFeed(), nil
+	case "feed":
+		var atom atomFeed
+		err = decoder.DecodeElement(&atom, &root)
+		if err != nil {
+			return nil, err
+		}
+		return atom.toFeed(), nil
+	}
+
+	return nil, fmt.Errorf("unsupported feed format <%s>", root.Name.Local)
+}
+
+func (rss rssFeed) toFeed() *Feed {
+	feed := &Feed{
+		Title: strings.TrimSpace(rss.Channel.Title),
+	}
+	for _, link := range rss.Channel.Links {
+		if strings.TrimSpace(link) != "" {
+			feed.Link = strings.TrimSpace(link)
+			break
+		}
+	}
+
+	for _, rssItem := range rss.Channel.Items {
+		item := Item{
+			GUID:    strings.TrimSpace(rssItem.GUID),
+			Title:   rssItem.Title,
+			Link:    strings.TrimSpace(rssItem.Link),
+			Author:  strings.TrimSpace(rssItem.Creator),
+			Content: rssItem.Description,
+		}
+		if item.Author == "" {
+			item.Author = strings.TrimSpace(rssItem.Author)
+		}
+		if item.Content == "" {
+			item.Content = rssItem.ContentEncoded
+		}
+		item.Published, _ = parseTime(rssItem.PubDate)
+
+		for _, enclosure := range rssItem.Enclosures {
+			if strings.HasPrefix(enclosure.Type, "image/") {
+				item.ImageURL = enclosure.URL
+				break
+			}
+		}
+		if item.ImageURL == "" {
+			for _, mediaContent := range rssItem.MediaContents {
+				if mediaContent.Medium == "image" || strings.HasPrefix(mediaContent.Type, "image/") {
+					item.ImageURL = mediaContent.URL
+					break
+				}
+			}
+		}
+		if item.ImageURL == "" && len(rssItem.MediaThumbnails) > 0 {
+			item.ImageURL = rssItem.MediaThumbnails[0].URL
+		}
+
+		feed.Items = append(feed.Items, item)
+	}
+
+	return feed
+}
+
+func (atom atomFeed) toFeed() *Feed {
+	feed := &Feed{
+		Title: strings.TrimSpace(atom.Title),
+		Link:  atomAlternateLink(atom.Links),
+	}
+
+	for _, atomEntry := range atom.Entries {
+		item := Item{
+			GUID:    strings.TrimSpace(atomEntry.ID),
+			Title:   atomEntry.Title,
+			Link:    atomAlternateLink(atomEntry.Links),
+			Author:  strings.TrimSpace(atomEntry.Author),
+			Content: atomEntry.Summary,
+		}
+		if item.Content == "" {
+			item.Content = atomEntry.Content
+		}
+		var err error
+		item.Published, err = parseTime(atomEntry.Published)
+		if err != nil {
+			item.Published, _ = parseTime(atomEntry.Updated)
+		}
+		if len(atomEntry.MediaThumbnails) > 0 {
+			item.ImageURL = atomEntry.MediaThumbnails[0].URL
+		}
+
+		feed.Items = append(feed.Items, item)
+	}
+
+	return feed
+}
+
+// atomAlternateLink returns the alternate link, links without rel are alternate links
+func atomAlternateLink(links []atomLink) string {
+	for _, link := range links {
+		if link.Rel == "" || link.Rel == "alternate" {
+			return strings.TrimSpace(link.Href)
+		}
+	}
+	return ""
+}
+
+type jsonFeed struct {
+	Version     string `json:"version"`
+	Title       string `json:"title"`
+	HomePageURL string `json:"home_page_url"`
+	Items       []struct {
+		ID            json.RawMessage `json:"id"`
+		URL           string          `json:"url"`
+		Title         string          `json:"title"`
+		ContentText   string          `json:"content_text"`
+		ContentHTML   string          `json:"content_html"`
+		Summary       string          `json:"summary"`
+		Image         string          `json:"image"`
+		BannerImage   string          `json:"banner_image"`
+		DatePublished string          `json:"date_published"`
+		Author        struct {
+			Name string `json:"name"`
+		} `json:"author"`
+	} `json:"items"`
+}
+
+func parseJSONFeed(data []byte) (feed *Feed, err error) {
+	var jsonFeed jsonFeed
+	err = json.Unmarshal(data, &jsonFeed)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(jsonFeed.Version, "https://jsonfeed.org/version/") {
+		return nil, errors.New("unsupported feed format, missing JSON Feed version")
+	}
+
+	feed = &Feed{
+		Title: strings.TrimSpace(jsonFeed.Title),
+		Link:  strings.TrimSpace(jsonFeed.HomePageURL),
+	}
+
+	for _, jsonItem := range jsonFeed.Items {
+		// ids should be strings, but some feeds use numbers
+		var id string
+		if json.Unmarshal(jsonItem.ID, &id) != nil {
+			id = string(jsonItem.ID)
+		}
+
+		item := Item{
+			GUID:     strings.TrimSpace(id),
+			Title:    jsonItem.Title,
+			Link:     strings.TrimSpace(jsonItem.URL),
+			Author:   strings.TrimSpace(jsonItem.Author.Name),
+			Content:  jsonItem.Summary,
+			ImageURL: jsonItem.Image,
+		}
+		if item.Content == "" {
+			item.Content = jsonItem.ContentText
+		}
+		if item.Content == "" {
+			item.Content = jsonItem.ContentHTML
+		}
+		if item.ImageURL == "" {
+			item.ImageURL = jsonItem.BannerImage
+		}
+		item.Published, _ = parseTime(jsonItem.DatePublished)
+
+		feed.Items = append(feed.Items, item)
+	}
+
+	return feed, nil
+}
+
+var timeLayouts = []string{
+	time.RFC3339,
+	time.RFC1123Z,
+	time.RFC1123,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"2 Jan 2006 15:04:05 -0700",
+	time.RFC822Z,
+	time.RFC822,
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+}
+
+// parseTime parses the date formats used by RSS, Atom and JSON Feed
+func parseTime(text string) (parsed time.Time, err error) {
+	text = strings.TrimSpace(text)
+	for _, layout := range timeLayouts {
+		parsed, err = time.Parse(layout, text)
+		if err == nil {
+			return parsed, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unable to parse time %s", text)
+}
+
+// stripHTML returns the text of the HTML
+func stripHTML(text string) string {
+	if !strings.Contains(text, "<") && !strings.Contains(text, "&") {
+		return text
+	}
+
+	document, err := goquery.NewDocumentFromReader(strings.NewReader(text))
+	if err != nil {
+		return text
+	}
+	return document.Text()
+}
@@ -0,0 +1,251 @@
+package feeds
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Seklfreak/Robyul2/helpers"
+	"github.com/Seklfreak/Robyul2/models"
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	testRSSFeed = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:atom="http://www.w3.org/2005/Atom" xmlns:dc="http://purl.org/dc/elements/1.1/">
+<channel>
+	<title>Example News</title>
+	<atom:link href="https://example.com/rss.xml" rel="self" type="application/rss+xml"/>
+	<link>https://example.com/</link>
+	<item>
+		<title>Second &amp; newest</title>
+		<link>https://example.com/2</link>
+		<guid>https://example.com/2</guid>
+		<dc:creator>Jane</dc:creator>
+		<description>&lt;p&gt;New &lt;b&gt;comeback&lt;/b&gt; announced&lt;/p&gt;</description>
+		<pubDate>Tue, 02 Jan 2018 10:00:00 +0000</pubDate>
+		<enclosure url="https://example.com/2.jpg" type="image/jpeg" length="1"/>
+	</item>
+	<item>
+		<title>First</title>
+		<link>https://example.com/1</link>
+		<description>Teaser</description>
+		<pubDate>Mon, 01 Jan 2018 10:00:00 +0000</pubDate>
+	</item>
+</channel>
+</rss>`
+	testAtomFeed = `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+	<title>Example Blog</title>
+	<link href="https://blog.example.com/feed.atom" rel="self"/>
+	<link href="https://blog.example.com/"/>
+	<entry>
+		<id>tag:blog.example.com,2018:1</id>
+		<title type="html">Hello &lt;em&gt;World&lt;/em&gt;</title>
+		<link rel="alternate" href="https://blog.example.com/1"/>
+		<author><name>John</name></author>
+		<updated>2018-01-01T10:00:00Z</updated>
+		<summary>Summary</summary>
+	</entry>
+</feed>`
+	testJSONFeed = `{
+	"version": "https://jsonfeed.org/version/1",
+	"title": "Example JSON",
+	"home_page_url": "https://json.example.com/",
+	"items": [
+		{"id": 2, "url": "https://json.example.com/2", "title": "Two", "content_html": "<p>Second</p>", "date_published": "2018-01-02T10:00:00+00:00"},
+		{"id": "1", "url": "https://json.example.com/1", "title": "One", "content_text": "First", "image": "https://json.example.com/1.png", "date_published": "2018-01-01T10:00:00+00:00"}
+	]
+}`
+)
+
+func TestFetchFeed(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		switch r.URL.Path {
+		case "/rss":
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set("ETag", `"v1"`)
+			w.Write([]byte(testRSSFeed))
+		case "/atom":
+			w.Header().Set("Last-Modified", "Mon, 01 Jan 2018 10:00:00 GMT")
+			w.Write([]byte(testAtomFeed))
+		case "/json":
+			w.Write([]byte(testJSONFeed))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	result, err := fetchFeed(server.Client(), server.URL+"/rss", "", "")
+	if err != nil {
+		t.Fatalf("feeds.fetchFeed() returned error for RSS feed: %v", err)
+	}
+	if result.ETag != `"v1"` || result.Feed.Title != "Example News" || result.Feed.Link != "https://example.com/" {
+		t.Errorf("feeds.fetchFeed() returned unexpected RSS feed: %+v, %+v", result, result.Feed)
+	}
+	if len(result.Feed.Items) != 2 {
+		t.Fatalf("feeds.fetchFeed() returned %d RSS items, expected 2", len(result.Feed.Items))
+	}
+	first, second := result.Feed.Items[0], result.Feed.Items[1]
+	if first.GUID != "https://example.com/1" || first.Title != "First" {
+		t.Errorf("feeds.fetchFeed() returned unexpected first RSS item: %+v", first)
+	}
+	if second.Title != "Second & newest" || second.Content != "New comeback announced" ||
+		second.Author != "Jane" || second.ImageURL != "https://example.com/2.jpg" {
+		t.Errorf("feeds.fetchFeed() returned unexpected second RSS item: %+v", second)
+	}
+
+	result, err = fetchFeed(server.Client(), server.URL+"/rss", result.ETag, "")
+	if err != nil || !result.NotModified || result.ETag != `"v1"` {
+		t.Errorf("feeds.fetchFeed() with ETag returned %+v, %v, expected not modified", result, err)
+	}
+
+	result, err = fetchFeed(server.Client(), server.URL+"/atom", "", "")
+	if err != nil {
+		t.Fatalf("feeds.fetchFeed() returned error for Atom feed: %v", err)
+	}
+	if result.LastModified != "Mon, 01 Jan 2018 10:00:00 GMT" || result.Feed.Link != "https://blog.example.com/" ||
+		len(result.Feed.Items) != 1 {
+		t.Fatalf("feeds.fetchFeed() returned unexpected Atom feed: %+v, %+v", result, result.Feed)
+	}
+	if item := result.Feed.Items[0]; item.GUID != "tag:blog.example.com,2018:1" || item.Title != "Hello World" ||
+		item.Link != "https://blog.example.com/1" || item.Author != "John" || item.Published.IsZero() {
+		t.Errorf("feeds.fetchFeed() returned unexpected Atom item: %+v", item)
+	}
+
+	result, err = fetchFeed(server.Client(), server.URL+"/json", "", "")
+	if err != nil {
+		t.Fatalf("feeds.fetchFeed() returned error for JSON Feed: %v", err)
+	}
+	if len(result.Feed.Items) != 2 || result.Feed.Items[0].GUID != "1" || result.Feed.Items[1].GUID != "2" ||
+		result.Feed.Items[0].ImageURL != "https://json.example.com/1.png" || result.Feed.Items[1].Content != "Second" {
+		t.Errorf("feeds.fetchFeed() returned unexpected JSON Feed items: %+v", result.Feed.Items)
+	}
+
+	if _, err = fetchFeed(server.Client(), server.URL+"/missing", "", ""); err == nil {
+		t.Errorf("feeds.fetchFeed() accepted a missing feed")
+	}
+	if requests != 5 {
+		t.Errorf("fixture server received %d requests, expected 5", requests)
+	}
+}
+
+func TestFeedFilters(t *testing.T) {
+	item := Item{Title: "New Comeback", Content: "Teaser video released"}
+
+	tests := []struct {
+		args     []string
+		expected bool
+	}{
+		{nil, true},
+		{[]string{"include:comeback,tour"}, true},
+		{[]string{"include:tour"}, false},
+		{[]string{"exclude:teaser_video"}, false},
+		{[]string{"include:comeback", "exclude:teaser"}, false},
+		{[]string{"include:/^new\\s+comeback/"}, true},
+		{[]string{"exclude:/vid(eo|s)/"}, false},
+	}
+
+	for _, test := range tests {
+		options, rest, err := helpers.ParseFeedOptions("", test.args, "")
+		if err != nil || len(rest) > 0 {
+			t.Fatalf("helpers.ParseFeedOptions(%v) returned %v, %v", test.args, rest, err)
+		}
+		if matches := helpers.FeedPostMatches(options, item.feedPost()); matches != test.expected {
+			t.Errorf("helpers.FeedPostMatches(%v) returned %v, expected %v", test.args, matches, test.expected)
+		}
+	}
+
+	if _, _, err := helpers.ParseFeedOptions("", []string{"include:/(/"}, ""); err == nil {
+		t.Errorf("helpers.ParseFeedOptions() accepted an invalid regular expression")
+	}
+}
+
+func TestFeedOptionsEmbedCode(t *testing.T) {
+	content := "_feeds add https://example.com/rss #news include:/embed:/ embed:ptext=New:  {TITLE} | title={TITLE}"
+	args := strings.Fields(content)[4:]
+
+	options, rest, err := helpers.ParseFeedOptions("", args, content)
+	if err != nil || len(rest) > 0 {
+		t.Fatalf("helpers.ParseFeedOptions(%v) returned %v, %v", args, rest, err)
+	}
+	if len(options.IncludeFilters) != 1 || options.IncludeFilters[0] != "/embed:/" {
+		t.Errorf("helpers.ParseFeedOptions() returned the include filters %v, expected /embed:/", options.IncludeFilters)
+	}
+	if options.EmbedCode != "ptext=New:  {TITLE} | title={TITLE}" {
+		t.Errorf("helpers.ParseFeedOptions() returned the embed code %q, expected the content after the embed: argument", options.EmbedCode)
+	}
+}
+
+func TestFeedMessageSend(t *testing.T) {
+	post := helpers.FeedPost{
+		Author:  "Jane",
+		Title:   "New Comeback",
+		URL:     "https://example.com/1",
+		Content: "Teaser video released",
+	}
+
+	message := helpers.FeedMessageSend(models.FeedOptions{
+		EmbedCode: "ptext={AUTHOR} posted | title={TITLE} | description={CONTENT} | image={MEDIA_URL}",
+	}, post, &discordgo.MessageSend{Content: "default"})
+	if message.Content != "Jane posted" || message.Embed == nil ||
+		message.Embed.Title != "New Comeback" || message.Embed.Description != "Teaser video released" {
+		t.Fatalf("helpers.FeedMessageSend() returned %+v, %+v, expected the placeholders to be replaced", message, message.Embed)
+	}
+	if message.Embed.Image != nil {
+		t.Errorf("helpers.FeedMessageSend() kept the image %+v of a post without media", message.Embed.Image)
+	}
+
+	message = helpers.FeedMessageSend(models.FeedOptions{EmbedCode: "{TITLE}: {URL}"}, post, nil)
+	if message.Content != "New Comeback: https://example.com/1" || message.Embed != nil {
+		t.Errorf("helpers.FeedMessageSend() returned %+v for a text template", message)
+	}
+
+	message = helpers.FeedMessageSend(models.FeedOptions{MentionRoleID: "123"}, post, &discordgo.MessageSend{Content: "default"})
+	if message.Content != "<@&123>\ndefault" {
+		t.Errorf("helpers.FeedMessageSend() returned %q, expected the role mention above the default message", message.Content)
+	}
+}
+
+func TestFeedClientAddresses(t *testing.T) {
+	tests := []struct {
+		ip     string
+		public bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.1.2.3", false},
+		{"172.20.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"fe80::1", false},
+		{"fd00::1", false},
+		{"0.0.0.0", false},
+		{"::ffff:127.0.0.1", false},
+	}
+
+	for _, test := range tests {
+		if public := isPublicIP(net.ParseIP(test.ip)); public != test.public {
+			t.Errorf("feeds.isPublicIP(%s) returned %t, expected %t", test.ip, public, test.public)
+		}
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(testRSSFeed))
+	}))
+	defer server.Close()
+
+	if _, err := fetchFeed(newFeedClient(), server.URL, "", ""); err == nil {
+		t.Errorf("feeds.fetchFeed() fetched a feed on a loopback address")
+	}
+}
@@ -0,0 +1,274 @@
+package feeds
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Seklfreak/Robyul2/cache"
+	"github.com/Seklfreak/Robyul2/feedpoller"
+	"github.com/Seklfreak/Robyul2/helpers"
+	"github.com/Seklfreak/Robyul2/models"
+	"github.com/bwmarrin/discordgo"
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+	"github.com/sirupsen/logrus"
+)
+
+type action func(args []string, in *discordgo.Message, out **discordgo.MessageSend) (next action)
+
+type Handler struct {
+	client *http.Client
+}
+
+const (
+	feedsColor = "f26522"
+	// maxPostedGUIDs is the number of GUIDs remembered per feed, feeds rarely contain more items
+	maxPostedGUIDs = 200
+)
+
+func (h *Handler) Commands() []string {
+	return []string{
+		"feeds",
+		"feed",
+		"rss",
+	}
+}
+
+func (h *Handler) Init(session *discordgo.Session) {
+	defer helpers.Recover()
+
+	h.client = newFeedClient()
+
+	collection := helpers.MdbCollection(models.FeedsTable)
+	helpers.RelaxLog(collection.EnsureIndex(mgo.Index{Key: []string{"url"}}))
+	helpers.RelaxLog(collection.EnsureIndex(mgo.Index{Key: []string{"guildid"}}))
+
+	feedpoller.Start(h, feedpoller.Options{
+		Name:       "feeds",
+		Interval:   5 * time.Minute,
+		Jitter:     0.2,
+		Workers:    5,
+		MaxBackoff: 6 * time.Hour,
+	})
+}
+
+func (h *Handler) Action(command string, content string, msg *discordgo.Message, session *discordgo.Session) {
+	if !helpers.ModuleIsAllowed(msg.ChannelID, msg.ID, msg.Author.ID, helpers.ModulePermFeeds) {
+		return
+	}
+
+	session.ChannelTyping(msg.ChannelID)
+
+	var result *discordgo.MessageSend
+	args := strings.Fields(content)
+
+	action := h.actionStart
+	for action != nil {
+		action = action(args, msg, &result)
+	}
+}
+
+func (h *Handler) actionStart(args []string, in *discordgo.Message, out **discordgo.MessageSend) action {
+	if len(args) < 1 {
+		*out = h.newMsg("bot.arguments.too-few")
+		return h.actionFinish
+	}
+
+	switch args[0] {
+	case "add":
+		return h.actionAdd
+	case "delete", "remove":
+		return h.actionRemove
+	case "list":
+		return h.actionList
+	}
+
+	*out = h.newMsg("bot.arguments.invalid")
+	return h.actionFinish
+}
+
+// [p]feeds add <feed url> <#channel> [include:<filters>] [exclude:<filters>] [mention:<role>] [embed:<embed code>]
+func (h *Handler) actionAdd(args []string, in *discordgo.Message, out **discordgo.MessageSend) action {
+	if !helpers.IsMod(in) {
+		*out = h.newMsg(helpers.GetText("mod.no_permission"))
+		return h.actionFinish
+	}
+
+	if len(args) < 3 {
+		*out = h.newMsg("bot.arguments.too-few")
+		return h.actionFinish
+	}
+
+	feedURL := strings.Trim(args[1], "<>")
+	parsedURL, err := url.Parse(feedURL)
+	if err != nil || (parsedURL.Scheme != "http" && parsedURL.Scheme != "https") || parsedURL.Host == "" {
+		*out = h.newMsg("plugins.feeds.add-error-invalid-url")
+		return h.actionFinish
+	}
+
+	targetChannel, err := helpers.GetChannelFromMention(in, args[2])
+	if err != nil {
+		*out = h.newMsg("bot.arguments.invalid")
+		return h.actionFinish
+	}
+
+	options, rest, err := helpers.ParseFeedOptions(targetChannel.GuildID, args[3:], in.Content)
+	if err != nil {
+		*out = h.newMsg("bot.arguments.invalid-feed-options", err.Error())
+		return h.actionFinish
+	}
+	if len(rest) > 0 {
+		*out = h.newMsg("bot.arguments.invalid")
+		return h.actionFinish
+	}
+
+	result, err := fetchFeed(h.client, feedURL, "", "")
+	if err != nil {
+		h.logger().WithError(err).Infof("adding feed %s failed", feedURL)
+		*out = h.newMsg("plugins.feeds.add-error-invalid-feed")
+		return h.actionFinish
+	}
+
+	// mark all current items as posted to prevent spam
+	postedGUIDs := make([]string, 0, len(result.Feed.Items))
+	for _, item := range result.Feed.Items {
+		postedGUIDs = append(postedGUIDs, item.GUID)
+	}
+
+	entry := models.FeedEntry{
+		GuildID:       targetChannel.GuildID,
+		ChannelID:     targetChannel.ID,
+		URL:           feedURL,
+		Title:         result.Feed.Title,
+		AddedByUserID: in.Author.ID,
+		AddedAt:       time.Now(),
+		PostedGUIDs:   limitGUIDs(postedGUIDs),
+		FeedOptions:   options,
+	}
+	if entry.Title == "" {
+		entry.Title = parsedURL.Host
+	}
+
+	newID, err := helpers.MDbInsert(models.FeedsTable, entry)
+	helpers.Relax(err)
+
+	_, err = helpers.EventlogLog(time.Now(), targetChannel.GuildID, helpers.MdbIdToHuman(newID),
+		models.EventlogTargetTypeRobyulFeedsFeed, in.Author.ID,
+		models.EventlogTypeRobyulFeedsFeedAdd, "",
+		nil,
+		h.eventlogOptions(entry), false)
+	helpers.RelaxLog(err)
+
+	*out = h.newMsg("plugins.feeds.add-success", entry.Title, targetChannel.ID)
+	return h.actionFinish
+}
+
+func (h *Handler) actionList(args []string, in *discordgo.Message, out **discordgo.MessageSend) action {
+	channel, err := helpers.GetChannel(in.ChannelID)
+	helpers.Relax(err)
+
+	var entries []models.FeedEntry
+	err = helpers.MDbIter(helpers.MdbCollection(models.FeedsTable).Find(bson.M{"guildid": channel.GuildID})).All(&entries)
+	helpers.Relax(err)
+
+	if len(entries) <= 0 {
+		*out = h.newMsg("plugins.feeds.list-none")
+		return h.actionFinish
+	}
+
+	var listText string
+	for _, entry := range entries {
+		listText += fmt.Sprintf("`%s`: Feed `%s` (<%s>) posting to <#%s>%s\n",
+			helpers.MdbIdToHuman(entry.ID), entry.Title, entry.URL, entry.ChannelID, helpers.FeedOptionsText(entry.GuildID, entry.FeedOptions))
+	}
+	listText += fmt.Sprintf("Found **%d** Feeds in total.", len(entries))
+
+	*out = &discordgo.MessageSend{Content: listText}
+	return h.actionFinish
+}
+
+func (h *Handler) actionRemove(args []string, in *discordgo.Message, out **discordgo.MessageSend) action {
+	if !helpers.IsMod(in) {
+		*out = h.newMsg(helpers.GetText("mod.no_permission"))
+		return h.actionFinish
+	}
+
+	if len(args) < 2 {
+		*out = h.newMsg("bot.arguments.too-few")
+		return h.actionFinish
+	}
+
+	channel, err := helpers.GetChannel(in.ChannelID)
+	helpers.Relax(err)
+
+	var entry models.FeedEntry
+	err = helpers.MdbOne(
+		helpers.MdbCollection(models.FeedsTable).Find(bson.M{"guildid": channel.GuildID, "_id": helpers.HumanToMdbId(args[1])}),
+		&entry,
+	)
+	if helpers.IsMdbNotFound(err) {
+		*out = h.newMsg("plugins.feeds.remove-error-not-found")
+		return h.actionFinish
+	}
+	helpers.Relax(err)
+
+	err = helpers.MDbDelete(models.FeedsTable, entry.ID)
+	helpers.Relax(err)
+
+	_, err = helpers.EventlogLog(time.Now(), channel.GuildID, helpers.MdbIdToHuman(entry.ID),
+		models.EventlogTargetTypeRobyulFeedsFeed, in.Author.ID,
+		models.EventlogTypeRobyulFeedsFeedRemove, "",
+		nil,
+		h.eventlogOptions(entry), false)
+	helpers.RelaxLog(err)
+
+	*out = h.newMsg("plugins.feeds.remove-success", entry.Title)
+	return h.actionFinish
+}
+
+func (h *Handler) eventlogOptions(entry models.FeedEntry) []models.ElasticEventlogOption {
+	return append([]models.ElasticEventlogOption{
+		{
+			Key:   "feeds_channelid",
+			Value: entry.ChannelID,
+			Type:  models.EventlogTargetTypeChannel,
+		},
+		{
+			Key:   "feeds_url",
+			Value: entry.URL,
+		},
+		{
+			Key:   "feeds_title",
+			Value: entry.Title,
+		},
+	}, helpers.FeedOptionsEventlogOptions("feeds", entry.FeedOptions)...)
+}
+
+func (h *Handler) actionFinish(args []string, in *discordgo.Message, out **discordgo.MessageSend) action {
+	_, err := helpers.SendComplex(in.ChannelID, *out)
+	helpers.RelaxMessage(err, in.ChannelID, in.ID)
+
+	return nil
+}
+
+func (h *Handler) newMsg(content string, replacements ...interface{}) *discordgo.MessageSend {
+	if len(replacements) < 1 {
+		return &discordgo.MessageSend{Content: helpers.GetText(content)}
+	}
+	return &discordgo.MessageSend{Content: helpers.GetTextF(content, replacements...)}
+}
+
+func (h *Handler) logger() *logrus.Entry {
+	return cache.GetLogger().WithField("module", "feeds")
+}
+
+// limitGUIDs keeps the latest GUIDs
+func limitGUIDs(guids []string) []string {
+	if len(guids) > maxPostedGUIDs {
+		return guids[len(guids)-maxPostedGUIDs:]
+	}
+	return guids
+}
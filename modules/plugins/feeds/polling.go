@@ -0,0 +1,136 @@
+package feeds
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Seklfreak/Robyul2/cache"
+	"github.com/Seklfreak/Robyul2/helpers"
+	"github.com/Seklfreak/Robyul2/models"
+	"github.com/bwmarrin/discordgo"
+	"github.com/globalsign/mgo/bson"
+)
+
+const (
+	// validatorsKey stores the ETag and Last-Modified header of a feed url for conditional requests
+	validatorsKey        = "robyul2-discord:feeds:validators:%s"
+	validatorsExpiration = 24 * time.Hour
+)
+
+// Targets returns the urls of all feeds in channels the bot can see
+func (h *Handler) Targets() (targets []string, err error) {
+	var entries []models.FeedEntry
+	err = helpers.MDbIterWithoutLogging(helpers.MdbCollection(models.FeedsTable).Find(nil).
+		Select(bson.M{"url": 1, "channelid": 1})).All(&entries)
+	if err != nil {
+		return nil, err
+	}
+
+	added := make(map[string]bool)
+	for _, entry := range entries {
+		if added[entry.URL] {
+			continue
+		}
+
+		channel, err := helpers.GetChannelWithoutApi(entry.ChannelID)
+		if err != nil || channel == nil || channel.ID == "" {
+			continue
+		}
+
+		targets = append(targets, entry.URL)
+		added[entry.URL] = true
+	}
+
+	return targets, nil
+}
+
+// Check posts new items of the feed to all channels subscribed to it
+func (h *Handler) Check(feedURL string) (err error) {
+	var entries []models.FeedEntry
+	err = helpers.MDbIterWithoutLogging(helpers.MdbCollection(models.FeedsTable).Find(
+		bson.M{"url": feedURL},
+	)).All(&entries)
+	if err != nil {
+		return err
+	}
+	if len(entries) <= 0 {
+		return nil
+	}
+
+	key := fmt.Sprintf(validatorsKey, feedURL)
+	validators, err := cache.GetRedisClient().HGetAll(key).Result()
+	if err != nil {
+		return err
+	}
+
+	result, err := fetchFeed(h.client, feedURL, validators["etag"], validators["lastmodified"])
+	if err != nil {
+		return err
+	}
+	if result.NotModified {
+		return nil
+	}
+
+	for _, entry := range entries {
+		channel, err := helpers.GetChannelWithoutApi(entry.ChannelID)
+		if err != nil || channel == nil || channel.ID == "" {
+			continue
+		}
+
+		posted := make(map[string]bool, len(entry.PostedGUIDs))
+		for _, guid := range entry.PostedGUIDs {
+			posted[guid] = true
+		}
+
+		changes := false
+		for _, item := range result.Feed.Items {
+			if posted[item.GUID] {
+				continue
+			}
+			posted[item.GUID] = true
+			entry.PostedGUIDs = append(entry.PostedGUIDs, item.GUID)
+			changes = true
+
+			if !helpers.FeedPostMatches(entry.FeedOptions, item.feedPost()) {
+				continue
+			}
+
+			go func(postEntry models.FeedEntry, postItem Item) {
+				defer helpers.Recover()
+
+				err := h.postItem(postEntry, result.Feed, postItem)
+				if err != nil {
+					if errD, ok := err.(*discordgo.RESTError); ok && errD.Message != nil {
+						if errD.Message.Code != discordgo.ErrCodeMissingPermissions &&
+							errD.Message.Code != discordgo.ErrCodeUnknownChannel &&
+							errD.Message.Code != discordgo.ErrCodeMissingAccess {
+							helpers.RelaxLog(err)
+						}
+					} else {
+						helpers.RelaxLog(err)
+					}
+				}
+			}(entry, item)
+		}
+
+		if changes {
+			entry.PostedGUIDs = limitGUIDs(entry.PostedGUIDs)
+			err = helpers.MDbUpdateWithoutLogging(models.FeedsTable, entry.ID, entry)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if result.ETag == "" && result.LastModified == "" {
+		return nil
+	}
+	err = cache.GetRedisClient().HMSet(key, map[string]interface{}{
+		"etag":         result.ETag,
+		"lastmodified": result.LastModified,
+	}).Err()
+	if err != nil {
+		return err
+	}
+	return cache.GetRedisClient().Expire(key, validatorsExpiration).Err()
+}
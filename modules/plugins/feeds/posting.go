@@ -0,0 +1,81 @@
+package feeds
+
+import (
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Seklfreak/Robyul2/helpers"
+	"github.com/Seklfreak/Robyul2/models"
+	"github.com/bwmarrin/discordgo"
+)
+
+// postItem posts the item with an embed laid out like reddit submissions
+func (h *Handler) postItem(entry models.FeedEntry, feed *Feed, item Item) (err error) {
+	data := &discordgo.MessageSend{}
+
+	if item.Link != "" {
+		data.Content = "<" + item.Link + ">"
+	}
+
+	footerText := helpers.GetText("plugins.feeds.embed-footer")
+	if feed.Title != "" {
+		footerText += " | " + feed.Title
+	}
+	if feedHost := hostOf(feed.Link); feedHost != "" {
+		footerText += " | " + feedHost
+	}
+
+	data.Embed = &discordgo.MessageEmbed{
+		Footer: &discordgo.MessageEmbedFooter{
+			Text: footerText,
+		},
+		URL:   item.Link,
+		Color: helpers.GetDiscordColorFromHex(feedsColor),
+	}
+	if item.Author != "" {
+		data.Embed.Author = &discordgo.MessageEmbedAuthor{Name: item.Author}
+	} else if feed.Title != "" {
+		data.Embed.Author = &discordgo.MessageEmbedAuthor{Name: feed.Title, URL: feed.Link}
+	}
+	if !item.Published.IsZero() {
+		data.Embed.Timestamp = item.Published.Format(time.RFC3339)
+	}
+
+	data.Embed.Title = truncate(item.Title, 128)
+	data.Embed.Description = truncate(item.Content, 500)
+	if strings.HasPrefix(item.ImageURL, "http") {
+		data.Embed.Image = &discordgo.MessageEmbedImage{URL: item.ImageURL}
+	}
+
+	_, err = helpers.SendComplex(entry.ChannelID, helpers.FeedMessageSend(entry.FeedOptions, item.feedPost(), data))
+	return err
+}
+
+// feedPost returns the values of the item for filters and embed codes
+func (item Item) feedPost() helpers.FeedPost {
+	return helpers.FeedPost{
+		Author:   item.Author,
+		Title:    item.Title,
+		URL:      item.Link,
+		Content:  item.Content,
+		MediaURL: item.ImageURL,
+	}
+}
+
+func hostOf(link string) string {
+	parsedURL, err := url.Parse(link)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(parsedURL.Host, "www.")
+}
+
+// truncate shortens the text to max runes, feeds are often not in English
+func truncate(text string, max int) string {
+	runes := []rune(text)
+	if len(runes) > max {
+		return string(runes[0:max-1]) + "…"
+	}
+	return text
+}
@@ -45,6 +45,11 @@ func (g *Gallery) Uninit(session *discordgo.Session) {
 
 }
 
+// Reloadable is true, Init only reloads the galleries
+func (g *Gallery) Reloadable() bool {
+	return true
+}
+
 func (g *Gallery) Action(command string, content string, msg *discordgo.Message, session *discordgo.Session) {
 	if !helpers.ModuleIsAllowed(msg.ChannelID, msg.ID, msg.Author.ID, helpers.ModulePermGallery) {
 		return
@@ -33,6 +33,11 @@ func (m *GuildAnnouncements) Uninit(session *discordgo.Session) {
 
 }
 
+// Reloadable is true, guild announcements have nothing to initialize
+func (m *GuildAnnouncements) Reloadable() bool {
+	return true
+}
+
 func (m *GuildAnnouncements) Action(command string, content string, msg *discordgo.Message, session *discordgo.Session) {
 	if !helpers.ModuleIsAllowed(msg.ChannelID, msg.ID, msg.Author.ID, helpers.ModulePermGuildAnnouncements) {
 		return
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/Seklfreak/Robyul2/cache"
+	"github.com/Seklfreak/Robyul2/feedpoller"
 	"github.com/Seklfreak/Robyul2/helpers"
 	"github.com/Seklfreak/Robyul2/models"
 	"github.com/bwmarrin/discordgo"
@@ -37,15 +38,14 @@ func (m *Handler) Commands() []string {
 }
 
 func (m *Handler) Init(session *discordgo.Session) {
-	go func() {
-		defer helpers.Recover()
-
-		go func() {
-			defer helpers.Recover()
-			m.checkInstagramPublicFeedLoop()
-		}()
-		cache.GetLogger().WithField("module", "instagram").Info("Started Instagram GraphQl Feed loop")
-	}()
+	feedpoller.Start(m, feedpoller.Options{
+		Name:        "instagram",
+		Interval:    time.Minute,
+		Jitter:      0.2,
+		Workers:     InstagramGraphQlWorkers,
+		MaxBackoff:  time.Hour,
+		ErrorBudget: 100,
+	})
 }
 
 func (m *Handler) Action(command string, content string, msg *discordgo.Message, session *discordgo.Session) {
@@ -56,7 +56,7 @@ func (m *Handler) Action(command string, content string, msg *discordgo.Message,
 	args := strings.Fields(content)
 	if len(args) >= 1 {
 		switch args[0] {
-		case "add": // [p]instagram add <instagram account name (with or without @)> <discord channel>
+		case "add": // [p]instagram add <instagram account name (with or without @)> <discord channel> [feed options] [direct link mode]
 			helpers.RequireMod(msg, func() {
 				session.ChannelTyping(msg.ChannelID)
 				// get target channel
@@ -75,6 +75,13 @@ func (m *Handler) Action(command string, content string, msg *discordgo.Message,
 				}
 				targetGuild, err = helpers.GetGuild(targetChannel.GuildID)
 				helpers.Relax(err)
+				feedOptions, rest, err := helpers.ParseFeedOptions(targetGuild.ID, args[3:], content)
+				if err != nil {
+					helpers.SendMessage(msg.ChannelID, helpers.GetTextF("bot.arguments.invalid-feed-options", err.Error()))
+					return
+				}
+				// the embed code could end with the direct link mode
+				flagsText := " " + strings.Join(rest, " ")
 				// proxy
 				proxy, err := helpers.GetRandomProxy()
 				helpers.Relax(err)
@@ -119,9 +126,9 @@ func (m *Handler) Action(command string, content string, msg *discordgo.Message,
 				// create new entry in db
 				var specialText string
 				postMode := models.InstagramSendPostTypeRobyulEmbed
-				if strings.HasSuffix(content, " direct link mode") ||
-					strings.HasSuffix(content, " link mode") ||
-					strings.HasSuffix(content, " links") {
+				if strings.HasSuffix(flagsText, " direct link mode") ||
+					strings.HasSuffix(flagsText, " link mode") ||
+					strings.HasSuffix(flagsText, " links") {
 					postMode = models.InstagramSendPostTypeDirectLinks
 					specialText += " using direct links"
 				}
@@ -137,6 +144,7 @@ func (m *Handler) Action(command string, content string, msg *discordgo.Message,
 						IsLive:                false,
 						SendPostType:          postMode,
 						LastPostCheck:         time.Now(),
+						FeedOptions:           feedOptions,
 					},
 				)
 				helpers.Relax(err)
@@ -145,7 +153,7 @@ func (m *Handler) Action(command string, content string, msg *discordgo.Message,
 					models.EventlogTargetTypeRobyulInstagramFeed, msg.Author.ID,
 					models.EventlogTypeRobyulInstagramFeedAdd, "",
 					nil,
-					[]models.ElasticEventlogOption{
+					append([]models.ElasticEventlogOption{
 						{
 							Key:   "instagram_channelid",
 							Value: targetChannel.ID,
@@ -163,7 +171,7 @@ func (m *Handler) Action(command string, content string, msg *discordgo.Message,
 							Key:   "instagram_instagramusername",
 							Value: instagramUser.Username,
 						},
-					}, false)
+					}, helpers.FeedOptionsEventlogOptions("instagram", feedOptions)...), false)
 				helpers.RelaxLog(err)
 
 				helpers.SendMessage(msg.ChannelID, helpers.GetTextF("plugins.instagram.account-added-success", instagramUser.Username, targetChannel.ID, specialText))
@@ -198,7 +206,7 @@ func (m *Handler) Action(command string, content string, msg *discordgo.Message,
 						models.EventlogTargetTypeRobyulInstagramFeed, msg.Author.ID,
 						models.EventlogTypeRobyulInstagramFeedRemove, "",
 						nil,
-						[]models.ElasticEventlogOption{
+						append([]models.ElasticEventlogOption{
 							{
 								Key:   "instagram_channelid",
 								Value: entryBucket.ChannelID,
@@ -216,7 +224,7 @@ func (m *Handler) Action(command string, content string, msg *discordgo.Message,
 								Key:   "instagram_instagramusername",
 								Value: entryBucket.Username,
 							},
-						}, false)
+						}, helpers.FeedOptionsEventlogOptions("instagram", entryBucket.FeedOptions)...), false)
 					helpers.RelaxLog(err)
 
 					helpers.SendMessage(msg.ChannelID, helpers.GetTextF("plugins.instagram.account-delete-success", entryBucket.Username))
@@ -248,8 +256,9 @@ func (m *Handler) Action(command string, content string, msg *discordgo.Message,
 					directLinkModeText = " (direct link mode)"
 				}
 
-				resultMessage += fmt.Sprintf("`%s`: Instagram Account `@%s` posting to <#%s>%s\n",
-					helpers.MdbIdToHuman(entry.ID), entry.Username, entry.ChannelID, directLinkModeText)
+				resultMessage += fmt.Sprintf("`%s`: Instagram Account `@%s` posting to <#%s>%s%s\n",
+					helpers.MdbIdToHuman(entry.ID), entry.Username, entry.ChannelID, directLinkModeText,
+					helpers.FeedOptionsText(currentChannel.GuildID, entry.FeedOptions))
 			}
 			resultMessage += fmt.Sprintf("Found **%d** Instagram Accounts in total.", len(entryBucket))
 			for _, resultPage := range helpers.Pagify(resultMessage, "\n") {
@@ -337,10 +337,13 @@ type InstagramAuthorInformations struct {
 	Biography     string
 }
 
-func (m *Handler) getBundledEntries() (bundledEntries map[string][]models.InstagramEntry, entriesCount int, err error) {
+func (m *Handler) getBundledEntries(query interface{}) (bundledEntries map[string][]models.InstagramEntry, entriesCount int, err error) {
 	var entries []models.InstagramEntry
 
-	err = helpers.MDbIter(helpers.MdbCollection(models.InstagramTable).Find(nil)).All(&entries)
+	err = helpers.MDbIterWithoutLogging(helpers.MdbCollection(models.InstagramTable).Find(query)).All(&entries)
+	if err != nil {
+		return nil, 0, err
+	}
 
 	bundledEntries = make(map[string][]models.InstagramEntry, 0)
 
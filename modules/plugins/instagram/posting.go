@@ -11,7 +11,19 @@ import (
 	"github.com/bwmarrin/discordgo"
 )
 
-func (m *Handler) postPostToChannel(channelID string, post InstagramPostInformation, postType models.InstagramSendPostType) {
+func (m *Handler) postPostToChannel(entry models.InstagramEntry, post InstagramPostInformation) {
+	feedPost := helpers.FeedPost{
+		Author:  fmt.Sprintf("%s (@%s)", post.Author.FullName, post.Author.Username),
+		URL:     fmt.Sprintf(instagramFriendlyPost, post.Shortcode),
+		Content: post.Caption,
+	}
+	if len(post.MediaUrls) > 0 {
+		feedPost.MediaURL = post.MediaUrls[0]
+	}
+	if !helpers.FeedPostMatches(entry.FeedOptions, feedPost) {
+		return
+	}
+
 	instagramNameModifier := ""
 	if post.Author.IsVerified {
 		instagramNameModifier += " ☑"
@@ -40,7 +52,7 @@ func (m *Handler) postPostToChannel(channelID string, post InstagramPostInformat
 		Description: post.Caption,
 		Color:       helpers.GetDiscordColorFromHex(hexColor),
 	}
-	if postType == models.InstagramSendPostTypeDirectLinks {
+	if entry.SendPostType == models.InstagramSendPostTypeDirectLinks {
 		content[0] += "**" + helpers.GetTextF("plugins.instagram.post-embed-title", post.Author.FullName, post.Author.Username, instagramNameModifier, mediaModifier) + "** _" + helpers.GetText("plugins.instagram.embed-footer") + "_\n"
 		if post.Caption != "" {
 			content[0] += post.Caption + "\n"
@@ -59,7 +71,7 @@ func (m *Handler) postPostToChannel(channelID string, post InstagramPostInformat
 	if len(mediaUrls) > 0 {
 		channelEmbed.Description += "\n\n`Links:` "
 		for i, mediaUrl := range mediaUrls {
-			if postType == models.InstagramSendPostTypeDirectLinks {
+			if entry.SendPostType == models.InstagramSendPostTypeDirectLinks {
 				index := int(math.Floor((float64(i+1) / 5.0) - 0.01))
 
 				if index >= len(content) {
@@ -76,18 +88,18 @@ func (m *Handler) postPostToChannel(channelID string, post InstagramPostInformat
 	messageSend := &discordgo.MessageSend{
 		Content: content[0],
 	}
-	if postType != models.InstagramSendPostTypeDirectLinks {
+	if entry.SendPostType != models.InstagramSendPostTypeDirectLinks {
 		messageSend.Embed = channelEmbed
 	}
 
-	_, err := helpers.SendComplex(channelID, messageSend)
+	_, err := helpers.SendComplex(entry.ChannelID, helpers.FeedMessageSend(entry.FeedOptions, feedPost, messageSend))
 	if err != nil {
 		return
 	}
 
 	if len(content) > 1 {
 		for _, text := range content[1:] {
-			_, err = helpers.SendMessage(channelID, text)
+			_, err = helpers.SendMessage(entry.ChannelID, text)
 			if err != nil {
 				return
 			}
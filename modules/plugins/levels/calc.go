@@ -3,33 +3,201 @@ package levels
 import (
 	"math"
 	"math/rand"
+	"sort"
 	"time"
+
+	"github.com/Seklfreak/Robyul2/helpers"
+	"github.com/Seklfreak/Robyul2/models"
+)
+
+const (
+	defaultLevelsExpMin   = 10
+	defaultLevelsExpMax   = 14
+	defaultLevelsCooldown = 60 * time.Second
 )
 
+// defaultLevelsCurve is used for global levels and guilds without a valid curve
+var defaultLevelsCurve = models.LevelsCurve{Type: models.LevelsCurveQuadratic, Factor: 0.1}
+
+// GetLevelsCurve returns the level curve of a guild, or the default curve for global levels
+func GetLevelsCurve(guildID string) models.LevelsCurve {
+	if guildID == "" || guildID == "global" {
+		return defaultLevelsCurve
+	}
+
+	curve := helpers.GuildSettingsGetCached(guildID).LevelsCurve
+	if !IsValidLevelsCurve(curve) {
+		return defaultLevelsCurve
+	}
+	return curve
+}
+
+// IsValidLevelsCurve checks if the factor or table of a curve can be used to calculate levels
+func IsValidLevelsCurve(curve models.LevelsCurve) bool {
+	switch curve.Type {
+	case models.LevelsCurveQuadratic, models.LevelsCurveLinear:
+		return curve.Factor > 0
+	case models.LevelsCurveTable:
+		if len(curve.Table) <= 0 || curve.Table[0] <= 0 {
+			return false
+		}
+		for i := 1; i < len(curve.Table); i++ {
+			if curve.Table[i] <= curve.Table[i-1] {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
 func GetLevelFromExp(exp int64) int {
-	calculatedLevel := 0.1 * math.Sqrt(float64(exp))
+	return GetLevelFromExpOnCurve(defaultLevelsCurve, exp)
+}
+
+func GetExpForLevel(level int) int64 {
+	return GetExpForLevelOnCurve(defaultLevelsCurve, level)
+}
+
+func GetProgressToNextLevelFromExp(exp int64) int {
+	return GetProgressToNextLevelFromExpOnCurve(defaultLevelsCurve, exp)
+}
+
+// GetGuildLevelFromExp returns the level on the curve of a guild, guildID global returns the global level
+func GetGuildLevelFromExp(guildID string, exp int64) int {
+	return GetLevelFromExpOnCurve(GetLevelsCurve(guildID), exp)
+}
+
+func GetGuildExpForLevel(guildID string, level int) int64 {
+	return GetExpForLevelOnCurve(GetLevelsCurve(guildID), level)
+}
+
+func GetGuildProgressToNextLevelFromExp(guildID string, exp int64) int {
+	return GetProgressToNextLevelFromExpOnCurve(GetLevelsCurve(guildID), exp)
+}
+
+func GetLevelFromExpOnCurve(curve models.LevelsCurve, exp int64) int {
+	if exp <= 0 {
+		return 0
+	}
+
+	switch curve.Type {
+	case models.LevelsCurveLinear:
+		return int(math.Floor(float64(exp) / curve.Factor))
+	case models.LevelsCurveTable:
+		level := sort.Search(len(curve.Table), func(i int) bool {
+			return curve.Table[i] > exp
+		})
+		// levels after the end of the table continue with the last step of the table
+		if level == len(curve.Table) {
+			level += int((exp - curve.Table[len(curve.Table)-1]) / getLevelsTableStep(curve.Table))
+		}
+		return level
+	}
+
+	calculatedLevel := curve.Factor * math.Sqrt(float64(exp))
 
 	return int(math.Floor(calculatedLevel))
 }
 
-func GetExpForLevel(level int) int64 {
+func GetExpForLevelOnCurve(curve models.LevelsCurve, level int) int64 {
 	if level <= 0 {
 		return 0
 	}
 
-	calculatedExp := math.Pow(float64(level)/0.1, 2)
+	switch curve.Type {
+	case models.LevelsCurveLinear:
+		return int64(math.Ceil(float64(level) * curve.Factor))
+	case models.LevelsCurveTable:
+		if level <= len(curve.Table) {
+			return curve.Table[level-1]
+		}
+		return curve.Table[len(curve.Table)-1] + int64(level-len(curve.Table))*getLevelsTableStep(curve.Table)
+	}
+
+	calculatedExp := math.Pow(float64(level)/curve.Factor, 2)
 	return int64(calculatedExp)
 }
 
-func GetProgressToNextLevelFromExp(exp int64) int {
-	expLevelCurrently := exp - GetExpForLevel(GetLevelFromExp(exp))
-	expLevelNext := GetExpForLevel(GetLevelFromExp(exp)+1) - GetExpForLevel(GetLevelFromExp(exp))
-	return int(expLevelCurrently / (expLevelNext / 100))
+func GetProgressToNextLevelFromExpOnCurve(curve models.LevelsCurve, exp int64) int {
+	level := GetLevelFromExpOnCurve(curve, exp)
+	expLevelCurrently := exp - GetExpForLevelOnCurve(curve, level)
+	expLevelNext := GetExpForLevelOnCurve(curve, level+1) - GetExpForLevelOnCurve(curve, level)
+	if expLevelNext <= 0 {
+		return 100
+	}
+	return int(expLevelCurrently * 100 / expLevelNext)
 }
 
-func getRandomExpForMessage() int64 {
-	min := 10
-	max := 15
-	rand.Seed(time.Now().Unix())
-	return int64(rand.Intn(max-min) + min)
+func getLevelsTableStep(table []int64) int64 {
+	if len(table) < 2 {
+		return table[0]
+	}
+	return table[len(table)-1] - table[len(table)-2]
+}
+
+// getExpForMessage picks a random amount of EXP from the range of the guild,
+// multiplied by the multiplier of the channel and the highest multiplier of the roles of the member
+func getExpForMessage(guildID, channelID, userID string) int64 {
+	settings := helpers.GuildSettingsGetCached(guildID)
+
+	min := settings.LevelsExpMin
+	max := settings.LevelsExpMax
+	if min <= 0 && max <= 0 {
+		min = defaultLevelsExpMin
+		max = defaultLevelsExpMax
+	}
+	if max < min {
+		max = min
+	}
+
+	exp := float64(rand.Intn(max-min+1) + min)
+	exp *= getLevelsChannelMultiplier(settings, channelID)
+	exp *= getLevelsRoleMultiplier(settings, guildID, userID)
+
+	return int64(math.Round(exp))
+}
+
+func getLevelsChannelMultiplier(settings models.Config, channelID string) float64 {
+	for _, multiplier := range settings.LevelsChannelMultipliers {
+		if multiplier.ID == channelID {
+			return multiplier.Multiplier
+		}
+	}
+	return 1
+}
+
+func getLevelsRoleMultiplier(settings models.Config, guildID, userID string) float64 {
+	if len(settings.LevelsRoleMultipliers) <= 0 {
+		return 1
+	}
+
+	member, err := helpers.GetGuildMemberWithoutApi(guildID, userID)
+	if err != nil || member == nil {
+		return 1
+	}
+
+	var found bool
+	var highest float64
+	for _, multiplier := range settings.LevelsRoleMultipliers {
+		for _, memberRole := range member.Roles {
+			if multiplier.ID == memberRole && (!found || multiplier.Multiplier > highest) {
+				highest = multiplier.Multiplier
+				found = true
+			}
+		}
+	}
+	if !found {
+		return 1
+	}
+	return highest
+}
+
+// getLevelsCooldown returns the time a member has to wait between messages that give EXP on a guild
+func getLevelsCooldown(guildID string) time.Duration {
+	cooldownSeconds := helpers.GuildSettingsGetCached(guildID).LevelsCooldownSeconds
+	if cooldownSeconds <= 0 {
+		return defaultLevelsCooldown
+	}
+	return time.Duration(cooldownSeconds) * time.Second
 }
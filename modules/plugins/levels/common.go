@@ -9,7 +9,6 @@ import (
 
 	"github.com/Seklfreak/Robyul2/cache"
 	"github.com/Seklfreak/Robyul2/helpers"
-	"github.com/Seklfreak/Robyul2/metrics"
 	"github.com/Seklfreak/Robyul2/models"
 	"github.com/bwmarrin/discordgo"
 	raven "github.com/getsentry/raven-go"
@@ -146,7 +145,7 @@ func cacheTopLoop() {
 					rankData = Levels_Cache_Ranking_Item{
 						UserID:  level.Key,
 						EXP:     level.Value,
-						Level:   GetLevelFromExp(level.Value),
+						Level:   GetGuildLevelFromExp(guildCache.GuildID, level.Value),
 						Ranking: i,
 					}
 
@@ -193,94 +192,67 @@ func cacheTopLoop() {
 	}
 }
 
-func processExpStackLoop() {
-	log := cache.GetLogger()
-
-	defer helpers.Recover()
-	defer func() {
-		go func() {
-			log.WithField("module", "levels").Info("The processExpStackLoop died. Please investigate! Will be restarted in 60 seconds")
-			time.Sleep(60 * time.Second)
-			processExpStackLoop()
-		}()
-	}()
-
-	for {
-		metrics.LevelsStackSize.Set(int64(expStack.Size()))
-		if !expStack.Empty() {
-			expItem := expStack.Pop().(ProcessExpInfo)
-			levelsServerUser, err := getLevelsServerUserOrCreateNewWithoutLogging(expItem.GuildID, expItem.UserID)
-			helpers.Relax(err)
-
-			expBefore := levelsServerUser.Exp
-			levelBefore := GetLevelFromExp(levelsServerUser.Exp)
-
-			levelsServerUser.Exp += getRandomExpForMessage()
-
-			levelAfter := GetLevelFromExp(levelsServerUser.Exp)
-
-			err = helpers.MDbUpdateWithoutLogging(models.LevelsServerusersTable, levelsServerUser.ID, levelsServerUser)
-			helpers.Relax(err)
-
-			if expBefore <= 0 || levelBefore != levelAfter {
-				// apply roles
-				err := applyLevelsRoles(expItem.GuildID, expItem.UserID, levelAfter)
-				if errD, ok := err.(*discordgo.RESTError); !ok || (errD.Message.Message != "404: Not Found" &&
-					errD.Message.Code != discordgo.ErrCodeUnknownMember &&
-					errD.Message.Code != discordgo.ErrCodeMissingAccess) {
-					helpers.RelaxLog(err)
-				}
-				guildSettings := helpers.GuildSettingsGetCached(expItem.GuildID)
-				// send level notifications
-				if levelAfter > levelBefore && guildSettings.LevelsNotificationCode != "" {
-					go func() {
-						defer helpers.Recover()
-
-						member, err := helpers.GetGuildMemberWithoutApi(expItem.GuildID, expItem.UserID)
-						helpers.RelaxLog(err)
+// onExpGained applies the level roles and sends the level notification after a member gained EXP on a guild
+// the notification is sent to the channel of the last message that gave EXP
+func onExpGained(guildID, channelID, userID string, expBefore, expAfter int64) {
+	levelBefore := GetGuildLevelFromExp(guildID, expBefore)
+	levelAfter := GetGuildLevelFromExp(guildID, expAfter)
+
+	if expBefore <= 0 || levelBefore != levelAfter {
+		// apply roles
+		err := applyLevelsRoles(guildID, userID, levelAfter)
+		if errD, ok := err.(*discordgo.RESTError); !ok || (errD.Message.Message != "404: Not Found" &&
+			errD.Message.Code != discordgo.ErrCodeUnknownMember &&
+			errD.Message.Code != discordgo.ErrCodeMissingAccess) {
+			helpers.RelaxLog(err)
+		}
+		guildSettings := helpers.GuildSettingsGetCached(guildID)
+		// send level notifications
+		if levelAfter > levelBefore && guildSettings.LevelsNotificationCode != "" {
+			go func() {
+				defer helpers.Recover()
+
+				member, err := helpers.GetGuildMemberWithoutApi(guildID, userID)
+				helpers.RelaxLog(err)
+				if err == nil {
+					levelNotificationText := replaceLevelNotificationText(guildSettings.LevelsNotificationCode, member, levelAfter)
+					if levelNotificationText == "" {
+						return
+					}
+					messageSend := &discordgo.MessageSend{
+						Content: levelNotificationText,
+					}
+					if helpers.IsEmbedCode(levelNotificationText) {
+						ptext, embed, err := helpers.ParseEmbedCode(levelNotificationText)
 						if err == nil {
-							levelNotificationText := replaceLevelNotificationText(guildSettings.LevelsNotificationCode, member, levelAfter)
-							if levelNotificationText == "" {
-								return
-							}
-							messageSend := &discordgo.MessageSend{
-								Content: levelNotificationText,
-							}
-							if helpers.IsEmbedCode(levelNotificationText) {
-								ptext, embed, err := helpers.ParseEmbedCode(levelNotificationText)
-								if err == nil {
-									messageSend.Content = ptext
-									messageSend.Embed = embed
-								}
-							}
-							messages, err := helpers.SendComplex(expItem.ChannelID, messageSend)
-							if err != nil {
-								if errD, ok := err.(*discordgo.RESTError); ok {
-									if errD.Message.Code == discordgo.ErrCodeMissingPermissions {
-										return
-									}
-								}
-								helpers.RelaxLog(err)
+							messageSend.Content = ptext
+							messageSend.Embed = embed
+						}
+					}
+					messages, err := helpers.SendComplex(channelID, messageSend)
+					if err != nil {
+						if errD, ok := err.(*discordgo.RESTError); ok {
+							if errD.Message.Code == discordgo.ErrCodeMissingPermissions {
 								return
 							}
-							if messages != nil && guildSettings.LevelsNotificationDeleteAfter > 0 {
-								go func() {
-									defer helpers.Recover()
+						}
+						helpers.RelaxLog(err)
+						return
+					}
+					if messages != nil && guildSettings.LevelsNotificationDeleteAfter > 0 {
+						go func() {
+							defer helpers.Recover()
 
-									time.Sleep(time.Duration(guildSettings.LevelsNotificationDeleteAfter) * time.Second)
+							time.Sleep(time.Duration(guildSettings.LevelsNotificationDeleteAfter) * time.Second)
 
-									for _, message := range messages {
-										cache.GetSession().ChannelMessageDelete(message.ChannelID, message.ID)
-									}
-								}()
+							for _, message := range messages {
+								cache.GetSession().ChannelMessageDelete(message.ChannelID, message.ID)
 							}
-						}
-						return
-					}()
+						}()
+					}
 				}
-			}
-		} else {
-			time.Sleep(250 * time.Millisecond)
+				return
+			}()
 		}
 	}
 }
@@ -325,12 +297,34 @@ func (p PairList) Len() int           { return len(p) }
 func (p PairList) Less(i, j int) bool { return p[i].Value < p[j].Value }
 func (p PairList) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
 
-func (b *Levels) BucketInit() {
-	b.Lock()
-	b.buckets = make(map[string]int8)
-	b.Unlock()
+// expCooldownPassed checks if a member may receive EXP on a guild again and starts a new cooldown if so
+func (m *Levels) expCooldownPassed(guildID string, userID string) bool {
+	key := guildID + userID
+	now := time.Now()
+
+	m.Lock()
+	defer m.Unlock()
+
+	if m.cooldowns == nil {
+		m.cooldowns = make(map[string]time.Time)
+	}
+
+	if cooldownUntil, ok := m.cooldowns[key]; ok && now.Before(cooldownUntil) {
+		return false
+	}
+	m.cooldowns[key] = now.Add(getLevelsCooldown(guildID))
+
+	// remove cooldowns that passed already from time to time
+	if now.Sub(m.cooldownsPrunedAt) > 10*time.Minute {
+		for cooldownKey, cooldownUntil := range m.cooldowns {
+			if now.After(cooldownUntil) {
+				delete(m.cooldowns, cooldownKey)
+			}
+		}
+		m.cooldownsPrunedAt = now
+	}
 
-	go b.BucketRefiller()
+	return true
 }
 
 func applyLevelsRoles(guildID string, userID string, level int) (err error) {
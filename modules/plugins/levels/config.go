@@ -0,0 +1,284 @@
+package levels
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Seklfreak/Robyul2/helpers"
+	"github.com/Seklfreak/Robyul2/models"
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	levelsConfigMaxExp        = 1000
+	levelsConfigMaxMultiplier = 10
+	levelsConfigMaxCooldown   = 24 * 60 * 60
+)
+
+// actionConfig shows or changes the leveling rules of a guild
+// [p]levels config [curve <default|quadratic|linear|table> [<factor or exp for each level>]|exp <min> <max>|channel <channel> <multiplier>|role <role> <multiplier>|cooldown <seconds>]
+func (m *Levels) actionConfig(args []string, msg *discordgo.Message, channel *discordgo.Channel) {
+	helpers.RequireAdmin(msg, func() {
+		settings := helpers.GuildSettingsGetCached(channel.GuildID)
+
+		if len(args) < 2 {
+			m.sendLevelsConfig(msg, channel.GuildID, settings)
+			return
+		}
+
+		if len(args) < 3 {
+			_, err := helpers.SendMessage(msg.ChannelID, helpers.GetText("bot.arguments.too-few"))
+			helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+			return
+		}
+
+		var key, oldValue, newValue string
+		message := helpers.GetText("plugins.levels.config-updated")
+
+		switch args[1] {
+		case "curve":
+			curve := models.LevelsCurve{Type: models.LevelsCurveType(args[2])}
+			switch curve.Type {
+			case "default":
+				curve = models.LevelsCurve{}
+			case models.LevelsCurveQuadratic, models.LevelsCurveLinear:
+				if len(args) >= 4 {
+					curve.Factor, _ = strconv.ParseFloat(args[3], 64)
+				}
+			case models.LevelsCurveTable:
+				for _, arg := range args[3:] {
+					exp, err := strconv.ParseInt(strings.Trim(arg, ","), 10, 64)
+					if err != nil {
+						curve.Table = nil
+						break
+					}
+					curve.Table = append(curve.Table, exp)
+				}
+			}
+			if curve.Type != "" && !IsValidLevelsCurve(curve) {
+				_, err := helpers.SendMessage(msg.ChannelID, helpers.GetText("plugins.levels.config-invalid-curve"))
+				helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+				return
+			}
+
+			key = "levels_curve"
+			oldValue = formatLevelsCurve(settings.LevelsCurve)
+			settings.LevelsCurve = curve
+			newValue = formatLevelsCurve(settings.LevelsCurve)
+			message = helpers.GetTextF("plugins.levels.config-curve-updated", helpers.GetPrefixForServer(channel.GuildID))
+		case "exp":
+			var min, max int
+			if args[2] != "default" {
+				if len(args) < 4 {
+					_, err := helpers.SendMessage(msg.ChannelID, helpers.GetText("bot.arguments.too-few"))
+					helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+					return
+				}
+
+				var errMin, errMax error
+				min, errMin = strconv.Atoi(args[2])
+				max, errMax = strconv.Atoi(args[3])
+				if errMin != nil || errMax != nil || min < 1 || max < min || max > levelsConfigMaxExp {
+					_, err := helpers.SendMessage(msg.ChannelID, helpers.GetText("bot.arguments.invalid"))
+					helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+					return
+				}
+			}
+
+			key = "levels_exp"
+			oldValue = formatLevelsExpRange(settings)
+			settings.LevelsExpMin = min
+			settings.LevelsExpMax = max
+			newValue = formatLevelsExpRange(settings)
+		case "channel":
+			if len(args) < 4 {
+				_, err := helpers.SendMessage(msg.ChannelID, helpers.GetText("bot.arguments.too-few"))
+				helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+				return
+			}
+
+			targetChannel, err := helpers.GetChannelFromMention(msg, args[2])
+			multiplier, errMultiplier := strconv.ParseFloat(args[3], 64)
+			if err != nil || targetChannel == nil || targetChannel.GuildID != channel.GuildID ||
+				errMultiplier != nil || multiplier < 0 || multiplier > levelsConfigMaxMultiplier {
+				_, err := helpers.SendMessage(msg.ChannelID, helpers.GetText("bot.arguments.invalid"))
+				helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+				return
+			}
+
+			key = "levels_channelmultipliers"
+			oldValue = formatLevelsMultipliers(settings.LevelsChannelMultipliers)
+			settings.LevelsChannelMultipliers = setLevelsMultiplier(settings.LevelsChannelMultipliers, targetChannel.ID, multiplier)
+			newValue = formatLevelsMultipliers(settings.LevelsChannelMultipliers)
+		case "role":
+			if len(args) < 4 {
+				_, err := helpers.SendMessage(msg.ChannelID, helpers.GetText("bot.arguments.too-few"))
+				helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+				return
+			}
+
+			guild, err := helpers.GetGuild(channel.GuildID)
+			helpers.Relax(err)
+
+			// the role can be a name with spaces, the multiplier is always the last argument
+			roleNameToMatch := strings.Join(args[2:len(args)-1], " ")
+			roleIDToMatch := strings.TrimSuffix(strings.TrimPrefix(roleNameToMatch, "<@&"), ">")
+
+			var targetRole *discordgo.Role
+			for _, role := range guild.Roles {
+				if role.ID == roleIDToMatch || strings.ToLower(role.Name) == strings.ToLower(roleNameToMatch) {
+					targetRole = role
+				}
+			}
+
+			multiplier, err := strconv.ParseFloat(args[len(args)-1], 64)
+			if targetRole == nil || err != nil || multiplier < 0 || multiplier > levelsConfigMaxMultiplier {
+				_, err := helpers.SendMessage(msg.ChannelID, helpers.GetText("bot.arguments.invalid"))
+				helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+				return
+			}
+
+			key = "levels_rolemultipliers"
+			oldValue = formatLevelsMultipliers(settings.LevelsRoleMultipliers)
+			settings.LevelsRoleMultipliers = setLevelsMultiplier(settings.LevelsRoleMultipliers, targetRole.ID, multiplier)
+			newValue = formatLevelsMultipliers(settings.LevelsRoleMultipliers)
+		case "cooldown":
+			cooldownSeconds, err := strconv.Atoi(args[2])
+			if err != nil || cooldownSeconds < 0 || cooldownSeconds > levelsConfigMaxCooldown {
+				_, err := helpers.SendMessage(msg.ChannelID, helpers.GetText("bot.arguments.invalid"))
+				helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+				return
+			}
+
+			key = "levels_cooldownseconds"
+			oldValue = getLevelsCooldown(channel.GuildID).String()
+			settings.LevelsCooldownSeconds = cooldownSeconds
+			newValue = (time.Duration(cooldownSeconds) * time.Second).String()
+			if cooldownSeconds <= 0 {
+				newValue = defaultLevelsCooldown.String()
+			}
+		default:
+			_, err := helpers.SendMessage(msg.ChannelID, helpers.GetText("bot.arguments.invalid"))
+			helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+			return
+		}
+
+		err := helpers.GuildSettingsSet(channel.GuildID, settings)
+		helpers.Relax(err)
+
+		_, err = helpers.EventlogLog(time.Now(), channel.GuildID, channel.GuildID,
+			models.EventlogTargetTypeGuild, msg.Author.ID,
+			models.EventlogTypeRobyulLevelsConfigUpdate, "",
+			[]models.ElasticEventlogChange{
+				{
+					Key:      key,
+					OldValue: oldValue,
+					NewValue: newValue,
+				},
+			},
+			nil, false)
+		helpers.RelaxLog(err)
+
+		_, err = helpers.SendMessage(msg.ChannelID, message)
+		helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+	})
+}
+
+func (m *Levels) sendLevelsConfig(msg *discordgo.Message, guildID string, settings models.Config) {
+	guild, err := helpers.GetGuild(guildID)
+	helpers.Relax(err)
+
+	channelMultipliers := make([]string, 0)
+	for _, multiplier := range settings.LevelsChannelMultipliers {
+		channelMultipliers = append(channelMultipliers, fmt.Sprintf("<#%s>: `x%g`", multiplier.ID, multiplier.Multiplier))
+	}
+	roleMultipliers := make([]string, 0)
+	for _, multiplier := range settings.LevelsRoleMultipliers {
+		roleMultipliers = append(roleMultipliers, fmt.Sprintf("<@&%s>: `x%g`", multiplier.ID, multiplier.Multiplier))
+	}
+
+	configEmbed := &discordgo.MessageEmbed{
+		Color: 0x0FADED,
+		Title: helpers.GetTextF("plugins.levels.config-embed-title", guild.Name),
+		Fields: []*discordgo.MessageEmbedField{
+			{
+				Name:   helpers.GetText("plugins.levels.config-embed-field-curve"),
+				Value:  "`" + formatLevelsCurve(settings.LevelsCurve) + "`",
+				Inline: true,
+			},
+			{
+				Name:   helpers.GetText("plugins.levels.config-embed-field-exp"),
+				Value:  "`" + formatLevelsExpRange(settings) + "`",
+				Inline: true,
+			},
+			{
+				Name:   helpers.GetText("plugins.levels.config-embed-field-cooldown"),
+				Value:  "`" + getLevelsCooldown(guildID).String() + "`",
+				Inline: true,
+			},
+			{
+				Name:  helpers.GetText("plugins.levels.config-embed-field-channel-multipliers"),
+				Value: strings.Join(channelMultipliers, "\n"),
+			},
+			{
+				Name:  helpers.GetText("plugins.levels.config-embed-field-role-multipliers"),
+				Value: strings.Join(roleMultipliers, "\n"),
+			},
+		},
+	}
+	for _, field := range configEmbed.Fields {
+		if field.Value == "" {
+			field.Value = helpers.GetText("plugins.levels.config-none")
+		}
+	}
+
+	_, err = helpers.SendEmbed(msg.ChannelID, configEmbed)
+	helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+}
+
+// setLevelsMultiplier sets the multiplier for a channel or role, a multiplier of 1 removes it
+func setLevelsMultiplier(multipliers []models.LevelsMultiplier, id string, value float64) []models.LevelsMultiplier {
+	newMultipliers := make([]models.LevelsMultiplier, 0)
+	for _, multiplier := range multipliers {
+		if multiplier.ID != id {
+			newMultipliers = append(newMultipliers, multiplier)
+		}
+	}
+	if value != 1 {
+		newMultipliers = append(newMultipliers, models.LevelsMultiplier{ID: id, Multiplier: value})
+	}
+	return newMultipliers
+}
+
+func formatLevelsCurve(curve models.LevelsCurve) string {
+	if !IsValidLevelsCurve(curve) {
+		return helpers.GetText("plugins.levels.config-curve-default")
+	}
+
+	if curve.Type == models.LevelsCurveTable {
+		table := make([]string, 0, len(curve.Table))
+		for _, exp := range curve.Table {
+			table = append(table, strconv.FormatInt(exp, 10))
+		}
+		return string(curve.Type) + " " + strings.Join(table, ", ")
+	}
+
+	return fmt.Sprintf("%s %g", curve.Type, curve.Factor)
+}
+
+func formatLevelsExpRange(settings models.Config) string {
+	if settings.LevelsExpMin <= 0 && settings.LevelsExpMax <= 0 {
+		return fmt.Sprintf("%d-%d", defaultLevelsExpMin, defaultLevelsExpMax)
+	}
+	return fmt.Sprintf("%d-%d", settings.LevelsExpMin, settings.LevelsExpMax)
+}
+
+func formatLevelsMultipliers(multipliers []models.LevelsMultiplier) string {
+	formatted := make([]string, 0, len(multipliers))
+	for _, multiplier := range multipliers {
+		formatted = append(formatted, fmt.Sprintf("%s:%g", multiplier.ID, multiplier.Multiplier))
+	}
+	return strings.Join(formatted, ";")
+}
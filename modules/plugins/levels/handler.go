@@ -25,7 +25,6 @@ import (
 	"github.com/Seklfreak/Robyul2/helpers"
 	"github.com/Seklfreak/Robyul2/metrics"
 	"github.com/Seklfreak/Robyul2/models"
-	"github.com/Seklfreak/Robyul2/ratelimits"
 	"github.com/Seklfreak/lastfm-go/lastfm"
 	"github.com/andybons/gogif"
 	"github.com/bradfitz/slice"
@@ -36,39 +35,27 @@ import (
 	"github.com/globalsign/mgo/bson"
 	colorful "github.com/lucasb-eyer/go-colorful"
 	"github.com/nfnt/resize"
-	lane "gopkg.in/oleiade/lane.v1"
 )
 
 type Levels struct {
 	sync.RWMutex
 
-	buckets map[string]int8
+	// maps guildid + userid => end of the exp cooldown
+	cooldowns         map[string]time.Time
+	cooldownsPrunedAt time.Time
 }
 
 type ProcessExpInfo struct {
 	GuildID   string
 	ChannelID string
 	UserID    string
+	Exp       int64
+	// StreamID is the ID of the entry in the exp queue
+	StreamID string
 }
 
 var (
-	LevelsBucket = &ratelimits.BucketContainer{}
-
-	// How many keys a bucket may contain when created
-	BUCKET_INITIAL_FILL int8 = 1
-
-	// The maximum amount of keys a user may possess
-	BUCKET_UPPER_BOUND int8 = 1
-
-	// How often new keys drip into the buckets
-	DROP_INTERVAL = 60 * time.Second
-
-	// How many keys may drop at a time
-	DROP_SIZE int8 = 1
-
 	temporaryIgnoredGuilds []string
-
-	expStack = lane.NewStack()
 )
 
 func (m *Levels) Commands() []string {
@@ -114,8 +101,6 @@ const (
 )
 
 func (m *Levels) Init(session *discordgo.Session) {
-	m.BucketInit()
-
 	log := cache.GetLogger()
 
 	cachePath = helpers.GetConfig().Path("cache_folder").Data().(string)
@@ -124,8 +109,7 @@ func (m *Levels) Init(session *discordgo.Session) {
 	helpers.Relax(err)
 	htmlTemplateString = string(htmlTemplate)
 
-	go processExpStackLoop()
-	log.WithField("module", "levels").Info("Started processExpStackLoop")
+	startExpQueue()
 
 	go cacheTopLoop()
 	log.WithField("module", "levels").Info("Started processCacheTopLoop")
@@ -1854,7 +1838,7 @@ func (m *Levels) Action(command string, content string, msg *discordgo.Message,
 
 					topLevelEmbed.Fields = append(topLevelEmbed.Fields, &discordgo.MessageEmbedField{
 						Name:   fmt.Sprintf("%d. %s", displayRanking, fullUsername),
-						Value:  fmt.Sprintf("Level: %d", GetLevelFromExp(levelsServersUsers[i-offset].Exp)),
+						Value:  fmt.Sprintf("Level: %d", GetGuildLevelFromExp(channel.GuildID, levelsServersUsers[i-offset].Exp)),
 						Inline: false,
 					})
 					displayRanking++
@@ -1886,7 +1870,7 @@ func (m *Levels) Action(command string, content string, msg *discordgo.Message,
 
 					topLevelEmbed.Fields = append(topLevelEmbed.Fields, &discordgo.MessageEmbedField{
 						Name:   "Your Rank: " + serverRank,
-						Value:  fmt.Sprintf("Level: %d", GetLevelFromExp(thislevelUser.Exp)),
+						Value:  fmt.Sprintf("Level: %d", GetGuildLevelFromExp(channel.GuildID, thislevelUser.Exp)),
 						Inline: false,
 					})
 
@@ -2801,6 +2785,9 @@ func (m *Levels) Action(command string, content string, msg *discordgo.Message,
 				_, err = helpers.SendMessage(msg.ChannelID, helpers.GetText("bot.arguments.invalid"))
 				helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
 				return
+			case "config", "settings": // [p]levels config [<setting> <value>]
+				m.actionConfig(args, msg, channel)
+				return
 			case "set-level-notification", "set-level-notifications", "set-level-noti", "set-level-notis":
 				helpers.RequireMod(msg, func() {
 					channel, err := helpers.GetChannel(msg.ChannelID)
@@ -2912,7 +2899,8 @@ func (m *Levels) Action(command string, content string, msg *discordgo.Message,
 		zeroWidthWhitespace, err := strconv.Unquote(`'\u200b'`)
 		helpers.Relax(err)
 
-		localExpForLevel := GetExpForLevel(GetLevelFromExp(levelThisServerUser.Exp))
+		localLevel := GetGuildLevelFromExp(channel.GuildID, levelThisServerUser.Exp)
+		localExpForLevel := GetGuildExpForLevel(channel.GuildID, localLevel)
 		globalExpForLevel := GetExpForLevel(GetLevelFromExp(totalExp))
 
 		userLevelEmbed := &discordgo.MessageEmbed{
@@ -2925,14 +2913,14 @@ func (m *Levels) Action(command string, content string, msg *discordgo.Message,
 			Fields: []*discordgo.MessageEmbedField{
 				{
 					Name:   "Level",
-					Value:  strconv.Itoa(GetLevelFromExp(levelThisServerUser.Exp)),
+					Value:  strconv.Itoa(localLevel),
 					Inline: true,
 				},
 				{
 					Name: "Level Progress",
 					Value: fmt.Sprintf("%s/%s EXP (%d %%)",
-						humanize.Comma(levelThisServerUser.Exp-localExpForLevel), humanize.Comma(GetExpForLevel(GetLevelFromExp(levelThisServerUser.Exp)+1)-localExpForLevel),
-						GetProgressToNextLevelFromExp(levelThisServerUser.Exp),
+						humanize.Comma(levelThisServerUser.Exp-localExpForLevel), humanize.Comma(GetGuildExpForLevel(channel.GuildID, localLevel+1)-localExpForLevel),
+						GetGuildProgressToNextLevelFromExp(channel.GuildID, levelThisServerUser.Exp),
 					),
 					Inline: true,
 				},
@@ -3263,9 +3251,9 @@ func (m *Levels) GetProfileHTML(member *discordgo.Member, guild *discordgo.Guild
 	tempTemplateHtml = strings.Replace(tempTemplateHtml, "{USER_AVATAR_URL}", html.EscapeString(avatarUrl), -1)
 	tempTemplateHtml = strings.Replace(tempTemplateHtml, "{USER_TITLE}", html.EscapeString(title), -1)
 	tempTemplateHtml = strings.Replace(tempTemplateHtml, "{USER_BIO}", html.EscapeString(bio), -1)
-	tempTemplateHtml = strings.Replace(tempTemplateHtml, "{USER_SERVER_LEVEL}", strconv.Itoa(GetLevelFromExp(levelThisServerUser.Exp)), -1)
+	tempTemplateHtml = strings.Replace(tempTemplateHtml, "{USER_SERVER_LEVEL}", strconv.Itoa(GetGuildLevelFromExp(guild.ID, levelThisServerUser.Exp)), -1)
 	tempTemplateHtml = strings.Replace(tempTemplateHtml, "{USER_SERVER_RANK}", serverRank, -1)
-	tempTemplateHtml = strings.Replace(tempTemplateHtml, "{USER_SERVER_LEVEL_PERCENT}", strconv.Itoa(GetProgressToNextLevelFromExp(levelThisServerUser.Exp)), -1)
+	tempTemplateHtml = strings.Replace(tempTemplateHtml, "{USER_SERVER_LEVEL_PERCENT}", strconv.Itoa(GetGuildProgressToNextLevelFromExp(guild.ID, levelThisServerUser.Exp)), -1)
 	tempTemplateHtml = strings.Replace(tempTemplateHtml, "{USER_GLOBAL_LEVEL}", strconv.Itoa(GetLevelFromExp(totalExp)), -1)
 	tempTemplateHtml = strings.Replace(tempTemplateHtml, "{USER_GLOBAL_RANK}", globalRank, -1)
 	tempTemplateHtml = strings.Replace(tempTemplateHtml, "{USER_BACKGROUND_URL}", m.GetProfileBackgroundUrl(userData), -1)
@@ -3548,16 +3536,17 @@ func (m *Levels) ProcessMessage(msg *discordgo.Message, session *discordgo.Sessi
 		}
 	}
 
-	// check if bucket is empty
-	if !m.BucketHasKeys(channel.GuildID + msg.Author.ID) {
-		//m.BucketSet(channel.GuildID+msg.Author.ID, -1)
+	if !m.expCooldownPassed(channel.GuildID, msg.Author.ID) {
 		return
 	}
 
-	err = m.BucketDrain(1, channel.GuildID+msg.Author.ID)
-	helpers.Relax(err)
-
-	expStack.Push(ProcessExpInfo{UserID: msg.Author.ID, GuildID: channel.GuildID, ChannelID: msg.ChannelID})
+	// the EXP is picked when the message is queued, so replaying the queue gives the same result
+	queueExp(ProcessExpInfo{
+		UserID:    msg.Author.ID,
+		GuildID:   channel.GuildID,
+		ChannelID: msg.ChannelID,
+		Exp:       getExpForMessage(channel.GuildID, msg.ChannelID, msg.Author.ID),
+	})
 }
 
 func (m *Levels) OnGuildMemberAdd(member *discordgo.Member, session *discordgo.Session) {
@@ -3600,99 +3589,6 @@ func (m *Levels) getLevelsServerUserOrCreateNew(guildid string, userid string) (
 }
 
 // Refills user buckets in a set interval
-func (b *Levels) BucketRefiller() {
-	for {
-		b.Lock()
-		for user, keys := range b.buckets {
-			// Chill zone
-			if keys == -1 {
-				b.buckets[user]++
-				continue
-			}
-
-			// Chill zone exit
-			if keys == 0 {
-				b.buckets[user] = BUCKET_INITIAL_FILL
-				continue
-			}
-
-			// More free keys for nice users :3
-			if keys < BUCKET_UPPER_BOUND {
-				b.buckets[user] += DROP_SIZE
-				continue
-			}
-		}
-		b.Unlock()
-
-		time.Sleep(DROP_INTERVAL)
-	}
-}
-
-// Check if the user has a bucket. If not create one
-func (b *Levels) CreateBucketIfNotExists(user string) {
-	if b.buckets == nil {
-		return
-	}
-
-	b.RLock()
-	_, e := b.buckets[user]
-	b.RUnlock()
-
-	if !e {
-		b.Lock()
-		b.buckets[user] = BUCKET_INITIAL_FILL
-		b.Unlock()
-	}
-}
-
-// Drains $amount from $user if he has enough keys left
-func (b *Levels) BucketDrain(amount int8, user string) error {
-	b.CreateBucketIfNotExists(user)
-
-	// Check if there are enough keys left
-	b.RLock()
-	userAmount := b.buckets[user]
-	b.RUnlock()
-
-	if amount > userAmount {
-		return errors.New("No keys left")
-	}
-
-	// Remove keys from bucket
-	b.Lock()
-	b.buckets[user] -= amount
-	b.Unlock()
-
-	return nil
-}
-
-// Check if the user still has keys
-func (b *Levels) BucketHasKeys(user string) bool {
-	b.CreateBucketIfNotExists(user)
-
-	b.RLock()
-	defer b.RUnlock()
-
-	return b.buckets[user] > 0
-}
-
-func (b *Levels) BucketGet(user string) int8 {
-	b.RLock()
-	defer b.RUnlock()
-
-	return b.buckets[user]
-}
-
-func (b *Levels) BucketSet(user string, value int8) {
-	if b.buckets == nil {
-		return
-	}
-
-	b.Lock()
-	b.buckets[user] = value
-	b.Unlock()
-}
-
 func (b *Levels) OnReactionAdd(reaction *discordgo.MessageReactionAdd, session *discordgo.Session) {
 
 }
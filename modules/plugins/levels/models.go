@@ -10,23 +10,6 @@ import (
 	"github.com/globalsign/mgo/bson"
 )
 
-func getLevelsServerUserOrCreateNewWithoutLogging(guildid string, userid string) (serveruser models.LevelsServerusersEntry, err error) {
-	err = helpers.MdbOneWithoutLogging(
-		helpers.MdbCollection(models.LevelsServerusersTable).Find(bson.M{"userid": userid, "guildid": guildid}),
-		&serveruser,
-	)
-
-	if err != nil && helpers.IsMdbNotFound(err) {
-		serveruser.UserID = userid
-		serveruser.GuildID = guildid
-		newid, err := helpers.MDbInsertWithoutLogging(models.LevelsServerusersTable, serveruser)
-		serveruser.ID = newid
-		return serveruser, err
-	}
-
-	return serveruser, err
-}
-
 func getLevelsRoles(guildID string, currentLevel int) (apply []*discordgo.Role, remove []*discordgo.Role) {
 	apply = make([]*discordgo.Role, 0)
 	remove = make([]*discordgo.Role, 0)
@@ -87,7 +70,7 @@ func getLevelForUser(userID string, guildID string) int {
 	} else {
 		for _, levelsServerUser := range levelsServersUser {
 			if levelsServerUser.GuildID == guildID {
-				return GetLevelFromExp(levelsServerUser.Exp)
+				return GetGuildLevelFromExp(guildID, levelsServerUser.Exp)
 			}
 		}
 	}
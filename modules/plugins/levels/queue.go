@@ -0,0 +1,570 @@
+package levels
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Seklfreak/Robyul2/cache"
+	"github.com/Seklfreak/Robyul2/helpers"
+	"github.com/Seklfreak/Robyul2/metrics"
+	"github.com/Seklfreak/Robyul2/models"
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+	"github.com/go-redis/redis"
+)
+
+const (
+	expQueueStream          = "robyul2-discord:levels:exp-queue"
+	expQueueGroup           = "levels"
+	expQueueBatchSize       = 500
+	expQueueBlock           = time.Second
+	expQueueClaimEvery      = time.Minute
+	expQueueClaimMinIdle    = 2 * time.Minute
+	expQueueClaimMaxPages   = 20
+	expQueueMetricsEvery    = 10 * time.Second
+	expQueueRetryAfterError = 5 * time.Second
+	// expAppliedIDsLimit is the number of exp queue entry IDs stored per member to skip entries that have been added before
+	expAppliedIDsLimit = 500
+)
+
+var (
+	expQueueOnce     sync.Once
+	expQueueConsumer string
+)
+
+// startExpQueue starts processing the exp queue once, reloading the plugin keeps the running loop
+func startExpQueue() {
+	expQueueOnce.Do(func() {
+		hostname, err := os.Hostname()
+		if err != nil || hostname == "" {
+			hostname = "robyul"
+		}
+		expQueueConsumer = hostname
+
+		go processExpQueueLoop()
+		cache.GetLogger().WithField("module", "levels").Info("Started processExpQueueLoop")
+	})
+}
+
+// queueExp adds the EXP for a message to the exp queue
+// the queue is stored in redis, so messages are not lost if the bot restarts before they have been processed
+func queueExp(item ProcessExpInfo) {
+	err := cache.GetRedisClient().XAdd(&redis.XAddArgs{
+		Stream: expQueueStream,
+		Values: map[string]interface{}{
+			"guildid":   item.GuildID,
+			"channelid": item.ChannelID,
+			"userid":    item.UserID,
+			"exp":       item.Exp,
+		},
+	}).Err()
+	helpers.RelaxLog(err)
+}
+
+// processExpQueueLoop reads the exp queue in batches and adds the EXP to the members with one bulk write per guild
+// entries are acknowledged after they have been written, entries of a crashed bot are claimed and processed again
+func processExpQueueLoop() {
+	log := cache.GetLogger()
+
+	defer helpers.Recover()
+	defer func() {
+		go func() {
+			log.WithField("module", "levels").Error("The processExpQueueLoop died. Please investigate! Will be restarted in 60 seconds")
+			time.Sleep(60 * time.Second)
+			processExpQueueLoop()
+		}()
+	}()
+
+	redisClient := cache.GetRedisClient()
+
+	err := redisClient.Do("XGROUP", "CREATE", expQueueStream, expQueueGroup, "0", "MKSTREAM").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		helpers.Relax(err)
+	}
+
+	// process entries that have been read by this consumer before a restart first
+	lastID := "0"
+	for {
+		entries := readExpQueue(lastID)
+		if len(entries) == 0 {
+			break
+		}
+		processExpQueueEntries(entries)
+		lastID = entries[len(entries)-1].ID
+	}
+
+	var lastClaim, lastMetrics time.Time
+	var lastProcessed int64
+	for {
+		if time.Since(lastClaim) >= expQueueClaimEvery {
+			processExpQueueEntries(claimStaleExpQueueEntries())
+			lastClaim = time.Now()
+		}
+
+		processExpQueueEntries(readExpQueue(">"))
+
+		if time.Since(lastMetrics) >= expQueueMetricsEvery {
+			processed := metrics.LevelsExpProcessed.Value()
+			if !lastMetrics.IsZero() {
+				metrics.LevelsExpThroughput.Set(float64(processed-lastProcessed) / time.Since(lastMetrics).Seconds())
+			}
+			updateExpQueueMetrics()
+			lastProcessed = processed
+			lastMetrics = time.Now()
+		}
+	}
+}
+
+// readExpQueue reads the next batch of the exp queue, > reads new entries, an ID reads entries of this consumer after the ID
+func readExpQueue(start string) []redis.XMessage {
+	streams, err := cache.GetRedisClient().XReadGroup(&redis.XReadGroupArgs{
+		Group:    expQueueGroup,
+		Consumer: expQueueConsumer,
+		Streams:  []string{expQueueStream, start},
+		Count:    expQueueBatchSize,
+		Block:    expQueueBlock,
+	}).Result()
+	if err != nil {
+		if err != redis.Nil {
+			helpers.RelaxLog(err)
+			time.Sleep(expQueueRetryAfterError)
+		}
+		return nil
+	}
+
+	entries := make([]redis.XMessage, 0)
+	for _, stream := range streams {
+		entries = append(entries, stream.Messages...)
+	}
+	return entries
+}
+
+// claimStaleExpQueueEntries claims entries that have been read, but not acknowledged for a while
+// this happens if a consumer stopped while processing a batch, or the batch could not be written
+// the pending list is paged through until a batch of stale entries has been found, it ended or expQueueClaimMaxPages have been read
+func claimStaleExpQueueEntries() []redis.XMessage {
+	redisClient := cache.GetRedisClient()
+
+	staleIDs := make([]string, 0)
+	start := "-"
+	for page := 0; page < expQueueClaimMaxPages && len(staleIDs) < expQueueBatchSize; page++ {
+		pending, err := redisClient.XPendingExt(&redis.XPendingExtArgs{
+			Stream: expQueueStream,
+			Group:  expQueueGroup,
+			Start:  start,
+			End:    "+",
+			Count:  expQueueBatchSize,
+		}).Result()
+		if err != nil {
+			if err != redis.Nil {
+				helpers.RelaxLog(err)
+			}
+			break
+		}
+
+		for _, entry := range pending {
+			if entry.Idle >= expQueueClaimMinIdle && len(staleIDs) < expQueueBatchSize {
+				staleIDs = append(staleIDs, entry.Id)
+			}
+		}
+		if len(pending) < expQueueBatchSize {
+			break
+		}
+
+		// the range is inclusive, so the next page starts after the last returned entry
+		lastID, err := parseExpQueueID(pending[len(pending)-1].Id)
+		if err != nil {
+			helpers.RelaxLog(err)
+			break
+		}
+		start = lastID.next().String()
+	}
+	if len(staleIDs) == 0 {
+		return nil
+	}
+
+	entries, err := redisClient.XClaim(&redis.XClaimArgs{
+		Stream:   expQueueStream,
+		Group:    expQueueGroup,
+		Consumer: expQueueConsumer,
+		MinIdle:  expQueueClaimMinIdle,
+		Messages: staleIDs,
+	}).Result()
+	if err != nil {
+		helpers.RelaxLog(err)
+		return nil
+	}
+
+	if len(entries) > 0 {
+		cache.GetLogger().WithField("module", "levels").Infof("claimed %d stale exp queue entries", len(entries))
+	}
+	return entries
+}
+
+// processExpQueueEntries writes a batch of the exp queue, and removes the entries of all members that have been written from the queue
+func processExpQueueEntries(entries []redis.XMessage) {
+	if len(entries) == 0 {
+		return
+	}
+
+	guildIDs := make([]string, 0)
+	itemsByGuild := make(map[string][]ProcessExpInfo)
+	doneIDs := make([]string, 0)
+
+	for _, entry := range entries {
+		item, err := parseExpQueueEntry(entry)
+		if err != nil {
+			// broken entries would be claimed again forever
+			cache.GetLogger().WithField("module", "levels").Warnf("dropping exp queue entry %s: %s", entry.ID, err.Error())
+			doneIDs = append(doneIDs, entry.ID)
+			continue
+		}
+
+		if _, ok := itemsByGuild[item.GuildID]; !ok {
+			guildIDs = append(guildIDs, item.GuildID)
+		}
+		itemsByGuild[item.GuildID] = append(itemsByGuild[item.GuildID], item)
+	}
+
+	var processed int
+	for _, guildID := range guildIDs {
+		doneUserIDs, err := applyExpBatch(guildID, itemsByGuild[guildID])
+		if err != nil {
+			helpers.RelaxLog(err)
+		}
+
+		// the entries of all other members stay pending and will be claimed again
+		for _, item := range itemsByGuild[guildID] {
+			if doneUserIDs[item.UserID] {
+				doneIDs = append(doneIDs, item.StreamID)
+				processed++
+			}
+		}
+		metrics.LevelsExpBatches.Add(1)
+	}
+
+	if len(doneIDs) > 0 {
+		redisClient := cache.GetRedisClient()
+		helpers.RelaxLog(redisClient.XAck(expQueueStream, expQueueGroup, doneIDs...).Err())
+
+		args := []interface{}{"XDEL", expQueueStream}
+		for _, id := range doneIDs {
+			args = append(args, id)
+		}
+		helpers.RelaxLog(redisClient.Do(args...).Err())
+	}
+
+	metrics.LevelsExpProcessed.Add(int64(processed))
+}
+
+// expGain is the EXP of a batch added to a member
+type expGain struct {
+	userID    string
+	channelID string
+	expBefore int64
+	expAfter  int64
+	// version and lastID identify the update, see confirmExpGains
+	version int
+	lastID  string
+}
+
+// applyExpBatch adds the EXP of a batch to the members of a guild with a single bulk write, and returns the members whose entries have been written
+// every member stores the IDs of the entries added to its EXP, those entries are skipped, so processing a batch twice does not add EXP twice,
+// updates only match if the member did not change since it was read, members without a matching update are retried with the next claim
+func applyExpBatch(guildID string, items []ProcessExpInfo) (doneUserIDs map[string]bool, err error) {
+	userIDs := make([]string, 0)
+	itemsByUser := make(map[string][]ProcessExpInfo)
+	for _, item := range items {
+		if _, ok := itemsByUser[item.UserID]; !ok {
+			userIDs = append(userIDs, item.UserID)
+		}
+		itemsByUser[item.UserID] = append(itemsByUser[item.UserID], item)
+	}
+
+	var serverUsers []models.LevelsServerusersEntry
+	err = helpers.MDbIterWithoutLogging(helpers.MdbCollection(models.LevelsServerusersTable).Find(
+		bson.M{"guildid": guildID, "userid": bson.M{"$in": userIDs}},
+	)).All(&serverUsers)
+	if err != nil {
+		return nil, err
+	}
+	serverUsersByUser := make(map[string]models.LevelsServerusersEntry)
+	for _, serverUser := range serverUsers {
+		if _, ok := serverUsersByUser[serverUser.UserID]; !ok {
+			serverUsersByUser[serverUser.UserID] = serverUser
+		}
+	}
+
+	doneUserIDs = make(map[string]bool)
+	gains := make([]expGain, 0)
+	var updates int
+
+	bulk := helpers.MdbCollection(models.LevelsServerusersTable).Bulk()
+	bulk.Unordered()
+
+	for _, userID := range userIDs {
+		serverUser, exists := serverUsersByUser[userID]
+
+		update, ok := getExpUpdate(serverUser, itemsByUser[userID])
+		if !ok {
+			// all entries have been added before
+			doneUserIDs[userID] = true
+			continue
+		}
+
+		if exists {
+			selector := bson.M{"_id": serverUser.ID, "expversion": serverUser.ExpVersion}
+			if serverUser.ExpVersion == 0 {
+				selector["expversion"] = bson.M{"$in": []interface{}{0, nil}}
+			}
+			bulk.Update(selector, bson.M{
+				"$inc": bson.M{"exp": update.exp, "expversion": 1},
+				"$set": bson.M{"expstreamid": update.floor, "expappliedids": update.appliedIDs},
+			})
+			updates++
+		} else {
+			// a concurrent insert fails because of the unique index of guildid and userid
+			bulk.Insert(models.LevelsServerusersEntry{
+				ID:            bson.NewObjectId(),
+				UserID:        userID,
+				GuildID:       guildID,
+				Exp:           update.exp,
+				ExpStreamID:   update.floor,
+				ExpAppliedIDs: update.appliedIDs,
+				ExpVersion:    1,
+			})
+		}
+
+		gains = append(gains, expGain{
+			userID:    userID,
+			channelID: update.channelID,
+			expBefore: serverUser.Exp,
+			expAfter:  serverUser.Exp + update.exp,
+			version:   serverUser.ExpVersion + 1,
+			lastID:    update.lastID,
+		})
+	}
+
+	if len(gains) == 0 {
+		return doneUserIDs, nil
+	}
+
+	result, err := bulk.Run()
+	if err != nil && !mgo.IsDup(err) {
+		// some writes might have been applied, they are skipped when the entries are claimed again
+		return doneUserIDs, err
+	}
+	if err != nil || result == nil || result.Matched < updates {
+		gains, err = confirmExpGains(guildID, gains)
+		if err != nil {
+			return doneUserIDs, err
+		}
+	}
+
+	for _, gain := range gains {
+		doneUserIDs[gain.userID] = true
+		onExpGained(guildID, gain.channelID, gain.userID, gain.expBefore, gain.expAfter)
+	}
+	return doneUserIDs, nil
+}
+
+// confirmExpGains returns the gains which have been written, if some writes of a bulk did not match
+func confirmExpGains(guildID string, gains []expGain) (confirmed []expGain, err error) {
+	userIDs := make([]string, 0, len(gains))
+	for _, gain := range gains {
+		userIDs = append(userIDs, gain.userID)
+	}
+
+	var serverUsers []models.LevelsServerusersEntry
+	err = helpers.MDbIterWithoutLogging(helpers.MdbCollection(models.LevelsServerusersTable).Find(
+		bson.M{"guildid": guildID, "userid": bson.M{"$in": userIDs}},
+	)).All(&serverUsers)
+	if err != nil {
+		return nil, err
+	}
+	serverUsersByUser := make(map[string]models.LevelsServerusersEntry)
+	for _, serverUser := range serverUsers {
+		serverUsersByUser[serverUser.UserID] = serverUser
+	}
+
+	confirmed = make([]expGain, 0, len(gains))
+	for _, gain := range gains {
+		serverUser := serverUsersByUser[gain.userID]
+		if serverUser.ExpVersion != gain.version || !isExpQueueEntryApplied(serverUser, gain.lastID) {
+			cache.GetLogger().WithField("module", "levels").Infof(
+				"exp of #%s on #%s changed while writing, retrying", gain.userID, guildID)
+			continue
+		}
+		confirmed = append(confirmed, gain)
+	}
+	return confirmed, nil
+}
+
+// isExpQueueEntryApplied checks if an exp queue entry has been added to the EXP of a member
+func isExpQueueEntryApplied(serverUser models.LevelsServerusersEntry, streamID string) bool {
+	id, err := parseExpQueueID(streamID)
+	if err != nil {
+		return false
+	}
+
+	floor, err := parseExpQueueID(serverUser.ExpStreamID)
+	if err == nil && !floor.less(id) {
+		return true
+	}
+
+	for _, appliedID := range serverUser.ExpAppliedIDs {
+		if parsed, err := parseExpQueueID(appliedID); err == nil && parsed == id {
+			return true
+		}
+	}
+	return false
+}
+
+// expUpdate is the EXP of the entries of a batch which have not been added to a member yet
+type expUpdate struct {
+	exp        int64
+	channelID  string
+	lastID     string
+	floor      string
+	appliedIDs []string
+}
+
+// getExpUpdate filters the entries of a member which have not been added to its EXP yet
+// entries up to ExpStreamID, and entries in ExpAppliedIDs have been added before,
+// the applied IDs are limited to the latest expAppliedIDsLimit, dropped IDs move ExpStreamID forward
+func getExpUpdate(serverUser models.LevelsServerusersEntry, items []ProcessExpInfo) (update expUpdate, ok bool) {
+	floor, err := parseExpQueueID(serverUser.ExpStreamID)
+	if err != nil {
+		floor = expQueueID{}
+	}
+
+	applied := make(map[expQueueID]bool)
+	ids := make([]expQueueID, 0, len(serverUser.ExpAppliedIDs)+len(items))
+	for _, appliedID := range serverUser.ExpAppliedIDs {
+		id, err := parseExpQueueID(appliedID)
+		if err != nil || applied[id] {
+			continue
+		}
+		applied[id] = true
+		ids = append(ids, id)
+	}
+
+	for _, item := range items {
+		id, err := parseExpQueueID(item.StreamID)
+		if err != nil || !floor.less(id) || applied[id] {
+			continue
+		}
+		applied[id] = true
+		ids = append(ids, id)
+
+		update.exp += item.Exp
+		update.channelID = item.ChannelID
+		update.lastID = id.String()
+		ok = true
+	}
+	if !ok {
+		return update, false
+	}
+
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i].less(ids[j])
+	})
+	if len(ids) > expAppliedIDsLimit {
+		floor = ids[len(ids)-expAppliedIDsLimit-1]
+		ids = ids[len(ids)-expAppliedIDsLimit:]
+	}
+
+	update.floor = floor.String()
+	update.appliedIDs = make([]string, 0, len(ids))
+	for _, id := range ids {
+		update.appliedIDs = append(update.appliedIDs, id.String())
+	}
+	return update, true
+}
+
+func parseExpQueueEntry(entry redis.XMessage) (item ProcessExpInfo, err error) {
+	item.GuildID, _ = entry.Values["guildid"].(string)
+	item.ChannelID, _ = entry.Values["channelid"].(string)
+	item.UserID, _ = entry.Values["userid"].(string)
+	if item.GuildID == "" || item.UserID == "" {
+		return item, fmt.Errorf("missing guild or user")
+	}
+
+	exp, _ := entry.Values["exp"].(string)
+	item.Exp, err = strconv.ParseInt(exp, 10, 64)
+	if err != nil {
+		return item, err
+	}
+
+	_, err = parseExpQueueID(entry.ID)
+	item.StreamID = entry.ID
+	return item, err
+}
+
+// expQueueID is a parsed stream ID, stream IDs have to be compared by their parts, not as strings
+type expQueueID struct {
+	milliseconds uint64
+	sequence     uint64
+}
+
+// parseExpQueueID parses a stream ID, an empty ID is before all entries
+func parseExpQueueID(id string) (parsed expQueueID, err error) {
+	if id == "" {
+		return parsed, nil
+	}
+
+	parts := strings.SplitN(id, "-", 2)
+	if len(parts) != 2 {
+		return parsed, fmt.Errorf("invalid stream id %s", id)
+	}
+
+	parsed.milliseconds, err = strconv.ParseUint(parts[0], 10, 64)
+	if err != nil {
+		return parsed, err
+	}
+	parsed.sequence, err = strconv.ParseUint(parts[1], 10, 64)
+	return parsed, err
+}
+
+func (id expQueueID) less(other expQueueID) bool {
+	if id.milliseconds != other.milliseconds {
+		return id.milliseconds < other.milliseconds
+	}
+	return id.sequence < other.sequence
+}
+
+// next returns the smallest ID after the ID
+func (id expQueueID) next() expQueueID {
+	if id.sequence == math.MaxUint64 {
+		return expQueueID{milliseconds: id.milliseconds + 1}
+	}
+	return expQueueID{milliseconds: id.milliseconds, sequence: id.sequence + 1}
+}
+
+func (id expQueueID) String() string {
+	if id == (expQueueID{}) {
+		return ""
+	}
+	return fmt.Sprintf("%d-%d", id.milliseconds, id.sequence)
+}
+
+func updateExpQueueMetrics() {
+	redisClient := cache.GetRedisClient()
+
+	// processed entries are deleted from the stream, so its length is the backlog
+	length, err := redisClient.XLen(expQueueStream).Result()
+	if err == nil {
+		metrics.LevelsStackSize.Set(length)
+	}
+
+	pending, err := redisClient.XPending(expQueueStream, expQueueGroup).Result()
+	if err == nil {
+		metrics.LevelsExpQueuePending.Set(pending.Count)
+	}
+}
@@ -0,0 +1,101 @@
+package levels
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/Seklfreak/Robyul2/models"
+)
+
+func TestParseExpQueueID(t *testing.T) {
+	earlier, err := parseExpQueueID("1528200000000-9")
+	if err != nil {
+		t.Fatalf("levels.parseExpQueueID() returned error: %v", err)
+	}
+	later, err := parseExpQueueID("1528200000000-10")
+	if err != nil {
+		t.Fatalf("levels.parseExpQueueID() returned error: %v", err)
+	}
+	if !earlier.less(later) || later.less(earlier) {
+		t.Errorf("levels.expQueueID.less() did not compare the sequence numerically")
+	}
+
+	padded, err := parseExpQueueID("00000001528200000000-00000000000000000010")
+	if err != nil || padded != later {
+		t.Errorf("levels.parseExpQueueID() returned %v, %v for a padded ID, expected %v", padded, err, later)
+	}
+
+	for _, invalid := range []string{"1528200000000", "a-1", "1-b"} {
+		if _, err = parseExpQueueID(invalid); err == nil {
+			t.Errorf("levels.parseExpQueueID() accepted the invalid ID %q", invalid)
+		}
+	}
+}
+
+func TestExpQueueIDNext(t *testing.T) {
+	tests := []struct {
+		id   expQueueID
+		next string
+	}{
+		{expQueueID{milliseconds: 1528200000000, sequence: 9}, "1528200000000-10"},
+		{expQueueID{milliseconds: 1528200000000, sequence: math.MaxUint64}, "1528200000001-0"},
+	}
+
+	for _, test := range tests {
+		if next := test.id.next().String(); next != test.next {
+			t.Errorf("levels.expQueueID.next() returned %s for %s, expected %s", next, test.id, test.next)
+		}
+	}
+}
+
+func TestGetExpUpdate(t *testing.T) {
+	serverUser := models.LevelsServerusersEntry{
+		ExpStreamID:   "100-0",
+		ExpAppliedIDs: []string{"100-10"},
+	}
+	items := []ProcessExpInfo{
+		{ChannelID: "1", Exp: 1, StreamID: "99-0"},
+		{ChannelID: "2", Exp: 2, StreamID: "100-9"},
+		{ChannelID: "3", Exp: 4, StreamID: "100-10"},
+		{ChannelID: "4", Exp: 8, StreamID: "100-11"},
+	}
+
+	update, ok := getExpUpdate(serverUser, items)
+	if !ok {
+		t.Fatalf("levels.getExpUpdate() returned no update")
+	}
+	if update.exp != 10 || update.channelID != "4" || update.lastID != "100-11" || update.floor != "100-0" {
+		t.Errorf("levels.getExpUpdate() returned unexpected update: %+v", update)
+	}
+	if !reflect.DeepEqual(update.appliedIDs, []string{"100-9", "100-10", "100-11"}) {
+		t.Errorf("levels.getExpUpdate() returned applied IDs %v", update.appliedIDs)
+	}
+
+	serverUser.ExpAppliedIDs = update.appliedIDs
+	if _, ok = getExpUpdate(serverUser, items); ok {
+		t.Errorf("levels.getExpUpdate() added entries twice")
+	}
+	for _, item := range items[1:] {
+		if !isExpQueueEntryApplied(serverUser, item.StreamID) {
+			t.Errorf("levels.isExpQueueEntryApplied() returned false for %s", item.StreamID)
+		}
+	}
+}
+
+func TestGetExpUpdateLimit(t *testing.T) {
+	serverUser := models.LevelsServerusersEntry{}
+	items := make([]ProcessExpInfo, 0)
+	for i := 1; i <= expAppliedIDsLimit+2; i++ {
+		items = append(items, ProcessExpInfo{Exp: 1, StreamID: expQueueID{milliseconds: 100, sequence: uint64(i)}.String()})
+	}
+
+	update, ok := getExpUpdate(serverUser, items)
+	if !ok || update.exp != int64(len(items)) {
+		t.Fatalf("levels.getExpUpdate() returned %+v, expected %d EXP", update.exp, len(items))
+	}
+	if len(update.appliedIDs) != expAppliedIDsLimit || update.floor != "100-2" || update.appliedIDs[0] != "100-3" {
+		t.Errorf("levels.getExpUpdate() kept %d applied IDs starting at %s with floor %s",
+			len(update.appliedIDs), update.appliedIDs[0], update.floor)
+	}
+}
@@ -0,0 +1,655 @@
+package mod
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Seklfreak/Robyul2/cache"
+	"github.com/Seklfreak/Robyul2/helpers"
+	"github.com/Seklfreak/Robyul2/models"
+	"github.com/bwmarrin/discordgo"
+	humanize "github.com/dustin/go-humanize"
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+	"github.com/karrick/tparse/v2"
+)
+
+const (
+	modActionsMaxSleep       = time.Minute
+	modActionsMinSleep       = time.Second
+	modActionsBatchSize      = 100
+	modActionsLease          = 5 * time.Minute
+	modActionsMaxAttempts    = 3
+	modActionsReconcileDelay = 5 * time.Minute
+)
+
+var (
+	modActionsSchedulerOnce sync.Once
+	modActionsWakeup        = make(chan bool, 1)
+
+	modActionDurationRegex = regexp.MustCompile(`^[0-9]+(m|h|d|w|mo|y)$`)
+)
+
+// startModActionsScheduler starts the scheduler for timed mutes, bans and roles once, reloading the plugin keeps the running scheduler
+func startModActionsScheduler() {
+	modActionsSchedulerOnce.Do(func() {
+		go func() {
+			defer helpers.Recover()
+
+			prepareModActions()
+			go reconcileModActions()
+			runModActionsScheduler()
+		}()
+
+		cache.GetLogger().WithField("module", "mod").Info("Started mod actions scheduler")
+	})
+}
+
+// wakeupModActionsScheduler makes the scheduler look at the next expiry right away, for example after an action has been added
+func wakeupModActionsScheduler() {
+	select {
+	case modActionsWakeup <- true:
+	default:
+	}
+}
+
+// prepareModActions creates the indexes for the expiry query and moves unmutes scheduled with machinery to the mod actions
+func prepareModActions() {
+	collection := helpers.MdbCollection(models.ModActionsTable)
+	helpers.RelaxLog(collection.EnsureIndex(mgo.Index{Key: []string{"active", "expiresat"}}))
+	helpers.RelaxLog(collection.EnsureIndex(mgo.Index{Key: []string{"guildid", "active", "type"}}))
+
+	migrated, err := helpers.MigrateMachineryUnmutes()
+	helpers.RelaxLog(err)
+	if migrated > 0 {
+		cache.GetLogger().WithField("module", "mod").Infof("moved %d machinery unmutes to the mod actions", migrated)
+	}
+}
+
+func runModActionsScheduler() {
+	for {
+		// keep expiring while there are full batches of expired actions
+		for expireDueModActions() >= modActionsBatchSize {
+			continue
+		}
+
+		select {
+		case <-time.After(getModActionsSchedulerSleep()):
+		case <-modActionsWakeup:
+		}
+	}
+}
+
+// getModActionsSchedulerSleep returns the time until the next action expires
+func getModActionsSchedulerSleep() time.Duration {
+	var nextAction models.ModActionEntry
+	err := helpers.MdbOneWithoutLogging(
+		helpers.MdbCollection(models.ModActionsTable).Find(bson.M{"active": true}).Sort("expiresat"),
+		&nextAction,
+	)
+	if err != nil {
+		if !helpers.IsMdbNotFound(err) {
+			helpers.RelaxLog(err)
+		}
+		return modActionsMaxSleep
+	}
+
+	sleep := time.Until(nextAction.ExpiresAt)
+	if sleep < modActionsMinSleep {
+		return modActionsMinSleep
+	}
+	if sleep > modActionsMaxSleep {
+		return modActionsMaxSleep
+	}
+	return sleep
+}
+
+// expireDueModActions reverts the next batch of expired actions, returns the size of the batch
+func expireDueModActions() (batchSize int) {
+	defer helpers.Recover()
+
+	var dueActions []models.ModActionEntry
+	err := helpers.MDbIterWithoutLogging(helpers.MdbCollection(models.ModActionsTable).Find(bson.M{
+		"active":    true,
+		"expiresat": bson.M{"$lte": time.Now()},
+	}).Sort("expiresat").Limit(modActionsBatchSize)).All(&dueActions)
+	if err != nil {
+		helpers.RelaxLog(err)
+		return 0
+	}
+
+	for _, action := range dueActions {
+		if !claimModAction(&action) {
+			continue
+		}
+
+		err = revertModAction(action)
+		if err != nil {
+			if action.Attempts < modActionsMaxAttempts {
+				// the lease expires and the action will be reverted again
+				continue
+			}
+			cache.GetLogger().WithField("module", "mod").Warnf(
+				"giving up on %s action %s on guild %s after %d attempts: %s",
+				action.Type, helpers.MdbIdToHuman(action.ID), action.GuildID, action.Attempts, err.Error(),
+			)
+		}
+
+		err = helpers.EndModAction(action, models.ModActionEndReasonExpired, cache.GetSession().State.User.ID)
+		if err != nil {
+			helpers.RelaxLog(err)
+			continue
+		}
+		logModActionExpiry(action)
+	}
+
+	return len(dueActions)
+}
+
+// claimModAction moves the expiry of an action by the lease, if it has not been changed since it was read
+// only one scheduler can claim an action, if the revert does not complete the action expires again once the lease expired
+func claimModAction(action *models.ModActionEntry) bool {
+	err := helpers.MDbUpdateQueryWithoutLogging(
+		models.ModActionsTable,
+		bson.M{"_id": action.ID, "active": true, "expiresat": action.ExpiresAt},
+		bson.M{
+			"$set": bson.M{"expiresat": time.Now().Add(modActionsLease)},
+			"$inc": bson.M{"attempts": 1},
+		},
+	)
+	if err != nil {
+		if !helpers.IsMdbNotFound(err) {
+			helpers.RelaxLog(err)
+		}
+		return false
+	}
+
+	action.Attempts++
+	return true
+}
+
+// revertModAction unmutes, unbans or removes the role of an expired action
+// targets that do not exist anymore are not an error
+func revertModAction(action models.ModActionEntry) (err error) {
+	session := cache.GetSession()
+
+	switch action.Type {
+	case models.ModActionTypeMute:
+		errRole := helpers.RemoveMuteRole(action.GuildID, action.UserID)
+		errDatabase := helpers.RemoveMuteDatabase(action.GuildID, action.UserID)
+		errPersistency := helpers.RemoveMutePersistency(action.GuildID, action.UserID)
+		if errRole != nil {
+			return errRole
+		}
+		if errDatabase != nil {
+			return errDatabase
+		}
+		return errPersistency
+	case models.ModActionTypeBan:
+		err = session.GuildBanDelete(action.GuildID, action.UserID)
+	case models.ModActionTypeRole:
+		err = session.GuildMemberRoleRemove(action.GuildID, action.UserID, action.RoleID)
+	case models.ModActionTypeBatchRole:
+		err = session.GuildRoleDelete(action.GuildID, action.RoleID)
+	}
+
+	if isModActionTargetGone(err) {
+		return nil
+	}
+	return err
+}
+
+func isModActionTargetGone(err error) bool {
+	if errD, ok := err.(*discordgo.RESTError); ok {
+		if errD.Response != nil && errD.Response.StatusCode == 404 {
+			return true
+		}
+		if errD.Message != nil &&
+			(errD.Message.Code == discordgo.ErrCodeUnknownMember ||
+				errD.Message.Code == discordgo.ErrCodeUnknownUser ||
+				errD.Message.Code == discordgo.ErrCodeUnknownRole ||
+				errD.Message.Code == discordgo.ErrCodeUnknownGuild) {
+			return true
+		}
+	}
+	return false
+}
+
+func logModActionExpiry(action models.ModActionEntry) {
+	targetID := action.UserID
+	targetType := models.EventlogTargetTypeUser
+	options := []models.ElasticEventlogOption{
+		{
+			Key:   "modaction_id",
+			Value: helpers.MdbIdToHuman(action.ID),
+			Type:  models.EventlogTargetTypeRobyulModAction,
+		},
+	}
+
+	var actionType, reason string
+	switch action.Type {
+	case models.ModActionTypeMute:
+		actionType = models.EventlogTypeRobyulUnmute
+		reason = "timed mute expired"
+	case models.ModActionTypeBan:
+		actionType = models.EventlogTypeRobyulUnban
+		reason = "temp-ban expired"
+	case models.ModActionTypeRole:
+		actionType = models.EventlogTypeRobyulTempRoleRemove
+		reason = "temp-role expired"
+		options = append(options, models.ElasticEventlogOption{
+			Key:   "temprole_roleid",
+			Value: action.RoleID,
+			Type:  models.EventlogTargetTypeRole,
+		})
+	case models.ModActionTypeBatchRole:
+		actionType = models.EventlogTypeRobyulBatchRolesDelete
+		reason = "batch role expired"
+		targetID = action.RoleID
+		targetType = models.EventlogTargetTypeRole
+	default:
+		return
+	}
+
+	_, err := helpers.EventlogLog(time.Now(), action.GuildID, targetID,
+		targetType, cache.GetSession().State.User.ID,
+		actionType, reason,
+		nil,
+		options, false)
+	helpers.RelaxLog(err)
+}
+
+// reconcileModActions ends active actions that have been reverted while the bot was offline, or without the bot
+// for example members that have been unbanned manually, or roles that have been deleted
+func reconcileModActions() {
+	defer helpers.Recover()
+
+	// wait for the guilds to become available
+	time.Sleep(modActionsReconcileDelay)
+
+	var actions []models.ModActionEntry
+	err := helpers.MDbIterWithoutLogging(
+		helpers.MdbCollection(models.ModActionsTable).Find(bson.M{"active": true}).Sort("guildid"),
+	).All(&actions)
+	if err != nil {
+		helpers.RelaxLog(err)
+		return
+	}
+
+	bansByGuild := make(map[string]map[string]bool)
+	var reconciled int
+	for _, action := range actions {
+		if modActionExistsOnGuild(action, bansByGuild) {
+			continue
+		}
+
+		err = helpers.EndModAction(action, models.ModActionEndReasonReconciled, "")
+		if err != nil {
+			if !helpers.IsMdbNotFound(err) {
+				helpers.RelaxLog(err)
+			}
+			continue
+		}
+		reconciled++
+	}
+
+	cache.GetLogger().WithField("module", "mod").Infof(
+		"reconciled mod actions, ended %d of %d active actions", reconciled, len(actions))
+}
+
+// modActionExistsOnGuild checks if an active action is still in place on its guild
+// if the state of the guild is not known the action is treated as in place
+func modActionExistsOnGuild(action models.ModActionEntry, bansByGuild map[string]map[string]bool) bool {
+	session := cache.GetSession()
+
+	guild, err := session.State.Guild(action.GuildID)
+	if err != nil {
+		// the guild is unavailable or not cached yet
+		return true
+	}
+
+	switch action.Type {
+	case models.ModActionTypeBan:
+		bannedUserIDs, ok := bansByGuild[action.GuildID]
+		if !ok {
+			guildBans, err := session.GuildBans(action.GuildID)
+			if err == nil {
+				bannedUserIDs = make(map[string]bool)
+				for _, guildBan := range guildBans {
+					if guildBan.User != nil {
+						bannedUserIDs[guildBan.User.ID] = true
+					}
+				}
+			}
+			bansByGuild[action.GuildID] = bannedUserIDs
+		}
+		if bannedUserIDs == nil {
+			return true
+		}
+		return bannedUserIDs[action.UserID]
+	case models.ModActionTypeMute:
+		settings := helpers.GuildSettingsGetCached(action.GuildID)
+		var muteRoleID string
+		for _, role := range guild.Roles {
+			if role.Name == settings.MutedRoleName {
+				muteRoleID = role.ID
+			}
+		}
+		if muteRoleID == "" {
+			return false
+		}
+		return modActionMemberHasRole(action.GuildID, action.UserID, muteRoleID)
+	case models.ModActionTypeRole, models.ModActionTypeBatchRole:
+		var roleFound bool
+		for _, role := range guild.Roles {
+			if role.ID == action.RoleID {
+				roleFound = true
+			}
+		}
+		if !roleFound {
+			return false
+		}
+		if action.Type == models.ModActionTypeBatchRole {
+			return true
+		}
+		return modActionMemberHasRole(action.GuildID, action.UserID, action.RoleID)
+	}
+
+	return true
+}
+
+// modActionMemberHasRole checks if a member has a role, members that are not on the guild keep their roles through persistency
+func modActionMemberHasRole(guildID, userID, roleID string) bool {
+	member, err := cache.GetSession().State.Member(guildID, userID)
+	if err != nil || member == nil {
+		return true
+	}
+
+	for _, memberRole := range member.Roles {
+		if memberRole == roleID {
+			return true
+		}
+	}
+	return false
+}
+
+// parseModActionDuration parses durations like 30m, 12h, 7d or 2w, returns the time after the duration
+func parseModActionDuration(start time.Time, text string) (end time.Time, ok bool) {
+	text = strings.ToLower(strings.TrimSpace(text))
+	if !modActionDurationRegex.MatchString(text) {
+		return time.Time{}, false
+	}
+
+	end, err := tparse.AddDuration(start, text)
+	if err != nil || !end.After(start) {
+		return time.Time{}, false
+	}
+	return end, true
+}
+
+// actionTimedActions lists, cancels or extends the timed actions of a guild
+// [p]timed-actions [<mute|ban|role|batch-role>] [<user>]
+// [p]timed-actions cancel <id>
+// [p]timed-actions extend <id> <duration>
+func (m *Mod) actionTimedActions(args []string, msg *discordgo.Message) {
+	if len(args) >= 1 {
+		switch args[0] {
+		case "cancel", "extend":
+			if len(args) < 2 || (args[0] == "extend" && len(args) < 3) {
+				_, err := helpers.SendMessage(msg.ChannelID, helpers.GetText("bot.arguments.too-few"))
+				helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+				return
+			}
+
+			action, err := helpers.GetModAction(msg.GuildID, strings.TrimPrefix(args[1], "#"))
+			if err != nil || !action.Active {
+				if err != nil && !helpers.IsMdbNotFound(err) {
+					helpers.Relax(err)
+				}
+				_, err = helpers.SendMessage(msg.ChannelID, helpers.GetText("plugins.mod.timed-actions-not-found"))
+				helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+				return
+			}
+
+			m.updateTimedAction(args, msg, action)
+			return
+		}
+	}
+
+	var actionType models.ModActionType
+	var userID string
+	for _, arg := range args {
+		switch models.ModActionType(arg) {
+		case models.ModActionTypeMute, models.ModActionTypeBan, models.ModActionTypeRole, models.ModActionTypeBatchRole:
+			actionType = models.ModActionType(arg)
+			continue
+		}
+
+		targetUser, err := helpers.GetUserFromMention(arg)
+		if err != nil || targetUser == nil {
+			_, err = helpers.SendMessage(msg.ChannelID, helpers.GetText("bot.arguments.invalid"))
+			helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+			return
+		}
+		userID = targetUser.ID
+	}
+
+	m.listTimedActions(msg, actionType, userID)
+}
+
+func (m *Mod) updateTimedAction(args []string, msg *discordgo.Message, action models.ModActionEntry) {
+	oldExpiry := action.ExpiresAt.UTC().Format(models.ISO8601)
+	var newExpiry, message string
+
+	switch args[0] {
+	case "cancel":
+		err := helpers.EndModAction(action, models.ModActionEndReasonCancelled, msg.Author.ID)
+		helpers.Relax(err)
+
+		message = helpers.GetTextF("plugins.mod.timed-actions-cancelled", helpers.MdbIdToHuman(action.ID))
+	case "extend":
+		expiresAt, ok := parseModActionDuration(action.ExpiresAt, args[2])
+		if !ok {
+			_, err := helpers.SendMessage(msg.ChannelID, helpers.GetText("plugins.mod.invalid-duration"))
+			helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+			return
+		}
+
+		err := helpers.ExtendModAction(action, expiresAt)
+		helpers.Relax(err)
+		wakeupModActionsScheduler()
+
+		newExpiry = expiresAt.UTC().Format(models.ISO8601)
+		message = helpers.GetTextF("plugins.mod.timed-actions-extended",
+			helpers.MdbIdToHuman(action.ID), expiresAt.UTC().Format(time.ANSIC))
+	}
+
+	options := []models.ElasticEventlogOption{
+		{
+			Key:   "modaction_type",
+			Value: string(action.Type),
+		},
+	}
+	if action.UserID != "" {
+		options = append(options, models.ElasticEventlogOption{
+			Key:   "modaction_userid",
+			Value: action.UserID,
+			Type:  models.EventlogTargetTypeUser,
+		})
+	}
+	if action.RoleID != "" {
+		options = append(options, models.ElasticEventlogOption{
+			Key:   "modaction_roleid",
+			Value: action.RoleID,
+			Type:  models.EventlogTargetTypeRole,
+		})
+	}
+
+	_, err := helpers.EventlogLog(time.Now(), msg.GuildID, helpers.MdbIdToHuman(action.ID),
+		models.EventlogTargetTypeRobyulModAction, msg.Author.ID,
+		models.EventlogTypeRobyulModActionUpdate, "",
+		[]models.ElasticEventlogChange{
+			{
+				Key:      "modaction_expiresat",
+				OldValue: oldExpiry,
+				NewValue: newExpiry,
+			},
+		},
+		options, false)
+	helpers.RelaxLog(err)
+
+	_, err = helpers.SendMessage(msg.ChannelID, message)
+	helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+}
+
+func (m *Mod) listTimedActions(msg *discordgo.Message, actionType models.ModActionType, userID string) {
+	actions, err := helpers.GetActiveModActions(msg.GuildID, actionType, userID)
+	helpers.Relax(err)
+
+	if len(actions) == 0 {
+		_, err = helpers.SendMessage(msg.ChannelID, helpers.GetText("plugins.mod.timed-actions-none"))
+		helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+		return
+	}
+
+	resultText := helpers.GetText("plugins.mod.timed-actions-list-title") + "\n"
+	for _, action := range actions {
+		resultText += helpers.GetTextF("plugins.mod.timed-actions-list-entry",
+			helpers.MdbIdToHuman(action.ID),
+			describeModAction(action),
+			action.ExpiresAt.UTC().Format(time.ANSIC),
+			humanize.Time(action.ExpiresAt),
+		) + "\n"
+	}
+
+	for _, page := range helpers.Pagify(resultText, "\n") {
+		_, err = helpers.SendMessage(msg.ChannelID, page)
+		helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+	}
+}
+
+func describeModAction(action models.ModActionEntry) string {
+	var userText, roleText string
+	if action.UserID != "" {
+		userText = "N/A (#" + action.UserID + ")"
+		user, err := helpers.GetUser(action.UserID)
+		if err == nil {
+			userText = user.Username + " (#" + user.ID + ")"
+		}
+	}
+	if action.RoleID != "" {
+		roleText = "#" + action.RoleID
+		role, err := cache.GetSession().State.Role(action.GuildID, action.RoleID)
+		if err == nil {
+			roleText = role.Name + " (#" + role.ID + ")"
+		}
+	}
+
+	switch action.Type {
+	case models.ModActionTypeMute:
+		return helpers.GetTextF("plugins.mod.timed-actions-type-mute", userText)
+	case models.ModActionTypeBan:
+		return helpers.GetTextF("plugins.mod.timed-actions-type-ban", userText)
+	case models.ModActionTypeRole:
+		return helpers.GetTextF("plugins.mod.timed-actions-type-role", roleText, userText)
+	case models.ModActionTypeBatchRole:
+		return helpers.GetTextF("plugins.mod.timed-actions-type-batch-role", roleText)
+	}
+	return string(action.Type)
+}
+
+// actionTempRole gives a member a role until the duration ends
+// [p]temp-role <user> <duration> <role name or id>
+func (m *Mod) actionTempRole(args []string, msg *discordgo.Message) {
+	if len(args) < 3 {
+		_, err := helpers.SendMessage(msg.ChannelID, helpers.GetText("bot.arguments.too-few"))
+		helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+		return
+	}
+
+	targetUser, err := helpers.GetUserFromMention(args[0])
+	if err != nil || targetUser == nil {
+		_, err = helpers.SendMessage(msg.ChannelID, helpers.GetText("bot.arguments.invalid"))
+		helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+		return
+	}
+
+	expiresAt, ok := parseModActionDuration(time.Now(), args[1])
+	if !ok {
+		_, err = helpers.SendMessage(msg.ChannelID, helpers.GetText("plugins.mod.invalid-duration"))
+		helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+		return
+	}
+
+	guild, err := helpers.GetGuild(msg.GuildID)
+	helpers.Relax(err)
+
+	roleNameToMatch := strings.Join(args[2:], " ")
+	roleIDToMatch := strings.TrimSuffix(strings.TrimPrefix(roleNameToMatch, "<@&"), ">")
+	var targetRole *discordgo.Role
+	for _, role := range guild.Roles {
+		if role.ID == roleIDToMatch || strings.ToLower(role.Name) == strings.ToLower(roleNameToMatch) {
+			targetRole = role
+		}
+	}
+	if targetRole == nil {
+		_, err = helpers.SendMessage(msg.ChannelID, helpers.GetText("bot.arguments.invalid"))
+		helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+		return
+	}
+
+	err = cache.GetSession().GuildMemberRoleAdd(guild.ID, targetUser.ID, targetRole.ID)
+	if err != nil {
+		if errD, ok := err.(*discordgo.RESTError); ok && errD.Message != nil &&
+			(errD.Message.Code == discordgo.ErrCodeMissingPermissions || errD.Message.Code == discordgo.ErrCodeMissingAccess) {
+			_, err = helpers.SendMessage(msg.ChannelID, helpers.GetText("plugins.mod.temp-role-error-permissions"))
+			helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+			return
+		}
+		if isModActionTargetGone(err) {
+			_, err = helpers.SendMessage(msg.ChannelID, helpers.GetText("plugins.mod.user-not-found"))
+			helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+			return
+		}
+		helpers.Relax(err)
+	}
+
+	action, err := helpers.CreateModAction(models.ModActionEntry{
+		GuildID:         guild.ID,
+		UserID:          targetUser.ID,
+		RoleID:          targetRole.ID,
+		Type:            models.ModActionTypeRole,
+		CreatedByUserID: msg.Author.ID,
+		ExpiresAt:       expiresAt,
+	})
+	helpers.Relax(err)
+	wakeupModActionsScheduler()
+
+	_, err = helpers.EventlogLog(time.Now(), guild.ID, targetUser.ID,
+		models.EventlogTargetTypeUser, msg.Author.ID,
+		models.EventlogTypeRobyulTempRoleAdd, "",
+		nil,
+		[]models.ElasticEventlogOption{
+			{
+				Key:   "temprole_roleid",
+				Value: targetRole.ID,
+				Type:  models.EventlogTargetTypeRole,
+			},
+			{
+				Key:   "temprole_until",
+				Value: expiresAt.UTC().Format(models.ISO8601),
+			},
+			{
+				Key:   "modaction_id",
+				Value: helpers.MdbIdToHuman(action.ID),
+				Type:  models.EventlogTargetTypeRobyulModAction,
+			},
+		}, false)
+	helpers.RelaxLog(err)
+
+	_, err = helpers.SendMessage(msg.ChannelID, helpers.GetTextF("plugins.mod.temp-role-success",
+		targetUser.Username, targetUser.ID, targetRole.Name, expiresAt.UTC().Format(time.ANSIC)))
+	helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+}
@@ -0,0 +1,701 @@
+package mod
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Seklfreak/Robyul2/cache"
+	"github.com/Seklfreak/Robyul2/helpers"
+	"github.com/Seklfreak/Robyul2/models"
+	"github.com/bwmarrin/discordgo"
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+)
+
+const (
+	automodRulesCacheTTL    = 5 * time.Minute
+	automodDuplicatesWindow = 30 * time.Second
+	// automodPunishCooldown prevents warning or muting a member more than once per rule while they are still spamming
+	automodPunishCooldown = time.Minute
+	automodMaxRules       = 25
+)
+
+// automodGuildRules are the cached rules of a guild, guilds without rules are cached as well
+type automodGuildRules struct {
+	rules    []*automodRule
+	loadedAt time.Time
+}
+
+type automodRecentMessage struct {
+	content string
+	sentAt  time.Time
+}
+
+var (
+	automodRulesCache     = make(map[string]automodGuildRules)
+	automodRulesCacheLock sync.RWMutex
+
+	automodRecentMessages     = make(map[string][]automodRecentMessage)
+	automodPunishments        = make(map[string]time.Time)
+	automodRecentMessagesLock sync.Mutex
+
+	automodCleanupOnce sync.Once
+)
+
+// prepareAutomod creates the indexes for the rule lookups and starts the cleanup of the spam tracking once
+func prepareAutomod() {
+	defer helpers.Recover()
+
+	helpers.RelaxLog(helpers.MdbCollection(models.AutomodRulesTable).EnsureIndex(mgo.Index{Key: []string{"guildid"}}))
+
+	automodCleanupOnce.Do(func() {
+		go func() {
+			defer helpers.Recover()
+
+			for {
+				time.Sleep(time.Minute)
+				cleanupAutomodRecentMessages()
+			}
+		}()
+	})
+}
+
+// getAutomodRules returns the compiled rules of a guild, rules are cached for automodRulesCacheTTL
+func getAutomodRules(guildID string) []*automodRule {
+	automodRulesCacheLock.RLock()
+	guildRules, ok := automodRulesCache[guildID]
+	automodRulesCacheLock.RUnlock()
+	if ok && time.Since(guildRules.loadedAt) < automodRulesCacheTTL {
+		return guildRules.rules
+	}
+
+	var entries []models.AutomodRuleEntry
+	err := helpers.MDbIterWithoutLogging(
+		helpers.MdbCollection(models.AutomodRulesTable).Find(bson.M{"guildid": guildID}).Sort("createdat"),
+	).All(&entries)
+	if err != nil {
+		helpers.RelaxLog(err)
+		return guildRules.rules
+	}
+
+	guildRules = automodGuildRules{loadedAt: time.Now()}
+	for _, entry := range entries {
+		rule, err := newAutomodRule(entry)
+		if err != nil {
+			cache.GetLogger().WithField("module", "mod").Warnf("skipping invalid automod rule #%s: %s",
+				helpers.MdbIdToHuman(entry.ID), err.Error())
+			continue
+		}
+		guildRules.rules = append(guildRules.rules, rule)
+	}
+
+	automodRulesCacheLock.Lock()
+	automodRulesCache[guildID] = guildRules
+	automodRulesCacheLock.Unlock()
+
+	return guildRules.rules
+}
+
+func invalidateAutomodRules(guildID string) {
+	automodRulesCacheLock.Lock()
+	delete(automodRulesCache, guildID)
+	automodRulesCacheLock.Unlock()
+}
+
+// recordAutomodMessage remembers a message of a member, returns the number of identical messages within the duplicates window
+func recordAutomodMessage(guildID, userID, content string) (duplicates int) {
+	content = strings.ToLower(strings.TrimSpace(content))
+	if content == "" {
+		return 0
+	}
+	key := guildID + ":" + userID
+	now := time.Now()
+
+	automodRecentMessagesLock.Lock()
+	defer automodRecentMessagesLock.Unlock()
+
+	recentMessages := make([]automodRecentMessage, 0, len(automodRecentMessages[key])+1)
+	for _, recentMessage := range automodRecentMessages[key] {
+		if now.Sub(recentMessage.sentAt) > automodDuplicatesWindow {
+			continue
+		}
+		recentMessages = append(recentMessages, recentMessage)
+		if recentMessage.content == content {
+			duplicates++
+		}
+	}
+	automodRecentMessages[key] = append(recentMessages, automodRecentMessage{content: content, sentAt: now})
+
+	return duplicates + 1
+}
+
+// claimAutomodPunishment returns false if the member has been punished for the rule within automodPunishCooldown
+func claimAutomodPunishment(guildID, userID string, ruleID bson.ObjectId) bool {
+	key := guildID + ":" + userID + ":" + string(ruleID)
+
+	automodRecentMessagesLock.Lock()
+	defer automodRecentMessagesLock.Unlock()
+
+	if time.Since(automodPunishments[key]) < automodPunishCooldown {
+		return false
+	}
+	automodPunishments[key] = time.Now()
+	return true
+}
+
+func cleanupAutomodRecentMessages() {
+	automodRecentMessagesLock.Lock()
+	defer automodRecentMessagesLock.Unlock()
+
+	for key, recentMessages := range automodRecentMessages {
+		if len(recentMessages) <= 0 || time.Since(recentMessages[len(recentMessages)-1].sentAt) > automodDuplicatesWindow {
+			delete(automodRecentMessages, key)
+		}
+	}
+	for key, punishedAt := range automodPunishments {
+		if time.Since(punishedAt) > automodPunishCooldown {
+			delete(automodPunishments, key)
+		}
+	}
+}
+
+// automodCheckMessage checks a message against the rules of its guild, only the first matching rule is applied
+func (m *Mod) automodCheckMessage(msg *discordgo.Message) {
+	if msg.Author == nil || msg.Author.Bot {
+		return
+	}
+
+	channel, err := helpers.GetChannel(msg.ChannelID)
+	if err != nil || channel.GuildID == "" {
+		return
+	}
+
+	rules := getAutomodRules(channel.GuildID)
+	if len(rules) <= 0 {
+		return
+	}
+
+	message := automodMessage{
+		content:  msg.Content,
+		mentions: len(msg.Mentions) + len(msg.MentionRoles),
+	}
+	if msg.MentionEveryone {
+		message.mentions++
+	}
+	for _, attachment := range msg.Attachments {
+		message.attachments = append(message.attachments, attachment.Filename)
+	}
+	for _, rule := range rules {
+		if rule.Type == models.AutomodRuleTypeDuplicates && rule.Enabled {
+			message.duplicates = recordAutomodMessage(channel.GuildID, msg.Author.ID, msg.Content)
+			break
+		}
+	}
+
+	for _, rule := range rules {
+		if !rule.Enabled || rule.isExempt(channel.ID) {
+			continue
+		}
+
+		matched, match := rule.match(message)
+		if !matched {
+			continue
+		}
+
+		// moderators and admins are checked last, the role lookup is not needed for most messages
+		if helpers.IsModByID(channel.GuildID, msg.Author.ID) {
+			return
+		}
+
+		m.applyAutomodRule(rule, msg, channel.GuildID, match)
+		return
+	}
+}
+
+// applyAutomodRule runs the actions of a rule for a message and records them in the eventlog
+func (m *Mod) applyAutomodRule(rule *automodRule, msg *discordgo.Message, guildID, match string) {
+	session := cache.GetSession()
+	botID := session.State.User.ID
+	ruleID := helpers.MdbIdToHuman(rule.ID)
+	reason := helpers.GetTextF("plugins.mod.automod-reason", ruleID, rule.Type)
+	punish := claimAutomodPunishment(guildID, msg.Author.ID, rule.ID)
+
+	appliedActions := make([]string, 0, len(rule.Actions))
+	for _, action := range rule.Actions {
+		var err error
+		switch action {
+		case models.AutomodActionTypeDelete:
+			err = session.ChannelMessageDelete(msg.ChannelID, msg.ID)
+		case models.AutomodActionTypeWarn:
+			if !punish {
+				continue
+			}
+			err = m.automodWarn(guildID, msg.Author, reason)
+		case models.AutomodActionTypeMute:
+			if !punish {
+				continue
+			}
+			err = m.automodMute(guildID, msg.Author, rule.MuteDuration, reason)
+		}
+		if err != nil {
+			if errD, ok := err.(*discordgo.RESTError); !ok || errD.Message == nil ||
+				(errD.Message.Code != discordgo.ErrCodeMissingPermissions && errD.Message.Code != discordgo.ErrCodeMissingAccess &&
+					errD.Message.Code != discordgo.ErrCodeUnknownMessage) {
+				helpers.RelaxLog(err)
+			}
+			appliedActions = append(appliedActions, string(action)+" (failed)")
+			continue
+		}
+		appliedActions = append(appliedActions, string(action))
+	}
+
+	if matchRunes := []rune(match); len(matchRunes) > automodMatchMaxLength {
+		match = string(matchRunes[:automodMatchMaxLength]) + "…"
+	}
+
+	_, err := helpers.EventlogLog(time.Now(), guildID, msg.Author.ID,
+		models.EventlogTargetTypeUser, botID,
+		models.EventlogTypeRobyulAutomodAction, reason,
+		nil,
+		[]models.ElasticEventlogOption{
+			{
+				Key:   "automod_ruleid",
+				Value: ruleID,
+				Type:  models.EventlogTargetTypeRobyulAutomodRule,
+			},
+			{
+				Key:   "automod_ruletype",
+				Value: string(rule.Type),
+			},
+			{
+				Key:   "automod_actions",
+				Value: strings.Join(appliedActions, ","),
+			},
+			{
+				Key:   "automod_match",
+				Value: match,
+			},
+			{
+				Key:   "automod_channelid",
+				Value: msg.ChannelID,
+				Type:  models.EventlogTargetTypeChannel,
+			},
+			{
+				Key:   "automod_messageid",
+				Value: msg.ID,
+				Type:  models.EventlogTargetTypeMessage,
+			},
+		}, false)
+	helpers.RelaxLog(err)
+}
+
+// automodWarn warns a member like the warn command does, including escalations
+func (m *Mod) automodWarn(guildID string, user *discordgo.User, reason string) (err error) {
+	guild, err := helpers.GetGuild(guildID)
+	if err != nil {
+		return err
+	}
+	botID := cache.GetSession().State.User.ID
+
+	_, err = helpers.CreateModCase(models.ModCaseEntry{
+		GuildID:         guild.ID,
+		Type:            models.ModCaseTypeWarn,
+		UserID:          user.ID,
+		ModeratorUserID: botID,
+		Reason:          reason,
+		Automatic:       true,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = helpers.EventlogLog(time.Now(), guild.ID, user.ID,
+		models.EventlogTargetTypeUser, botID,
+		models.EventlogTypeRobyulWarn, reason,
+		nil,
+		nil, false)
+	helpers.RelaxLog(err)
+
+	dmChannel, err := cache.GetSession().UserChannelCreate(user.ID)
+	if err == nil {
+		// members can disable DMs
+		helpers.SendMessage(dmChannel.ID,
+			helpers.GetTextF("plugins.mod.warn-dm", guild.Name)+"\n"+helpers.GetTextF("plugins.mod.warn-dm-reason", reason))
+	}
+
+	m.escalateWarnings(guild, user)
+	return nil
+}
+
+// automodMute mutes a member, for duration if it is set
+func (m *Mod) automodMute(guildID string, user *discordgo.User, duration, reason string) (err error) {
+	var expiresAt time.Time
+	if duration != "" {
+		expiresAt, _ = parseModActionDuration(time.Now(), duration)
+	}
+	botID := cache.GetSession().State.User.ID
+
+	err = helpers.MuteUser(guildID, user.ID, expiresAt, botID, reason)
+	if err != nil {
+		return err
+	}
+	wakeupModActionsScheduler()
+
+	_, err = helpers.CreateModCase(models.ModCaseEntry{
+		GuildID:         guildID,
+		Type:            models.ModCaseTypeMute,
+		UserID:          user.ID,
+		ModeratorUserID: botID,
+		Reason:          reason,
+		ExpiresAt:       expiresAt,
+		Automatic:       true,
+	})
+	helpers.RelaxLog(err)
+
+	var options []models.ElasticEventlogOption
+	if !expiresAt.IsZero() {
+		options = []models.ElasticEventlogOption{
+			{
+				Key:   "mute_until",
+				Value: expiresAt.Format(models.ISO8601),
+			},
+		}
+	}
+	_, err = helpers.EventlogLog(time.Now(), guildID, user.ID,
+		models.EventlogTargetTypeUser, botID,
+		models.EventlogTypeRobyulMute, reason,
+		nil,
+		options, false)
+	helpers.RelaxLog(err)
+
+	return nil
+}
+
+// actionAutomod lists and changes the automod rules of a guild
+// [p]automod [list]
+// [p]automod add <type> <action[,action]> [<values or threshold>]
+// [p]automod remove <rule id>
+// [p]automod enable|disable <rule id>
+// [p]automod exempt <rule id> <#channel>
+// [p]automod mute-duration <rule id> [<duration>]
+// [p]automod test <text>
+func (m *Mod) actionAutomod(content string, msg *discordgo.Message) {
+	args := strings.Fields(content)
+	if len(args) < 1 || args[0] == "list" {
+		m.listAutomodRules(msg)
+		return
+	}
+
+	switch args[0] {
+	case "add":
+		helpers.RequireAdmin(msg, func() {
+			m.addAutomodRule(content, args, msg)
+		})
+		return
+	case "test":
+		m.testAutomodRules(strings.TrimSpace(strings.TrimPrefix(content, args[0])), msg)
+		return
+	case "remove", "delete", "enable", "disable", "exempt", "mute-duration":
+	default:
+		_, err := helpers.SendMessage(msg.ChannelID, helpers.GetText("bot.arguments.invalid"))
+		helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+		return
+	}
+
+	helpers.RequireAdmin(msg, func() {
+		if len(args) < 2 || (args[0] == "exempt" && len(args) < 3) {
+			_, err := helpers.SendMessage(msg.ChannelID, helpers.GetText("bot.arguments.too-few"))
+			helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+			return
+		}
+
+		var rule models.AutomodRuleEntry
+		err := helpers.MdbOne(
+			helpers.MdbCollection(models.AutomodRulesTable).Find(bson.M{"_id": helpers.HumanToMdbId(args[1]), "guildid": msg.GuildID}),
+			&rule,
+		)
+		if err != nil {
+			if helpers.IsMdbNotFound(err) {
+				_, err = helpers.SendMessage(msg.ChannelID, helpers.GetText("plugins.mod.automod-not-found"))
+				helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+				return
+			}
+			helpers.Relax(err)
+		}
+
+		if args[0] == "remove" || args[0] == "delete" {
+			err = helpers.MDbDelete(models.AutomodRulesTable, rule.ID)
+			helpers.Relax(err)
+			invalidateAutomodRules(msg.GuildID)
+
+			_, err = helpers.EventlogLog(time.Now(), msg.GuildID, helpers.MdbIdToHuman(rule.ID),
+				models.EventlogTargetTypeRobyulAutomodRule, msg.Author.ID,
+				models.EventlogTypeRobyulAutomodRuleRemove, "",
+				nil,
+				automodRuleEventlogOptions(rule), false)
+			helpers.RelaxLog(err)
+
+			_, err = helpers.SendMessage(msg.ChannelID, helpers.GetTextF("plugins.mod.automod-removed", helpers.MdbIdToHuman(rule.ID)))
+			helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+			return
+		}
+
+		m.updateAutomodRule(args, msg, rule)
+	})
+}
+
+// updateAutomodRule changes a setting of an existing rule
+func (m *Mod) updateAutomodRule(args []string, msg *discordgo.Message, rule models.AutomodRuleEntry) {
+	var change models.ElasticEventlogChange
+	switch args[0] {
+	case "enable", "disable":
+		change = models.ElasticEventlogChange{
+			Key:      "automod_enabled",
+			OldValue: helpers.StoreBoolAsString(rule.Enabled),
+		}
+		rule.Enabled = args[0] == "enable"
+		change.NewValue = helpers.StoreBoolAsString(rule.Enabled)
+	case "exempt":
+		targetChannel, err := helpers.GetChannelFromMention(msg, args[2])
+		if err != nil || targetChannel.GuildID != msg.GuildID {
+			_, err = helpers.SendMessage(msg.ChannelID, helpers.GetText("bot.arguments.invalid"))
+			helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+			return
+		}
+
+		change = models.ElasticEventlogChange{
+			Key:      "automod_exemptchannelids",
+			OldValue: strings.Join(rule.ExemptChannelIDs, ","),
+			Type:     models.EventlogTargetTypeChannel,
+		}
+		exemptChannelIDs := make([]string, 0)
+		for _, exemptChannelID := range rule.ExemptChannelIDs {
+			if exemptChannelID != targetChannel.ID {
+				exemptChannelIDs = append(exemptChannelIDs, exemptChannelID)
+			}
+		}
+		if len(exemptChannelIDs) == len(rule.ExemptChannelIDs) {
+			exemptChannelIDs = append(exemptChannelIDs, targetChannel.ID)
+		}
+		rule.ExemptChannelIDs = exemptChannelIDs
+		change.NewValue = strings.Join(rule.ExemptChannelIDs, ",")
+	case "mute-duration":
+		change = models.ElasticEventlogChange{
+			Key:      "automod_muteduration",
+			OldValue: rule.MuteDuration,
+		}
+		rule.MuteDuration = ""
+		if len(args) >= 3 {
+			if _, ok := parseModActionDuration(time.Now(), args[2]); !ok {
+				_, err := helpers.SendMessage(msg.ChannelID, helpers.GetText("plugins.mod.invalid-duration"))
+				helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+				return
+			}
+			rule.MuteDuration = strings.ToLower(args[2])
+		}
+		change.NewValue = rule.MuteDuration
+	}
+
+	err := helpers.MDbUpdate(models.AutomodRulesTable, rule.ID, rule)
+	helpers.Relax(err)
+	invalidateAutomodRules(msg.GuildID)
+
+	_, err = helpers.EventlogLog(time.Now(), msg.GuildID, helpers.MdbIdToHuman(rule.ID),
+		models.EventlogTargetTypeRobyulAutomodRule, msg.Author.ID,
+		models.EventlogTypeRobyulAutomodRuleUpdate, "",
+		[]models.ElasticEventlogChange{change},
+		nil, false)
+	helpers.RelaxLog(err)
+
+	_, err = helpers.SendMessage(msg.ChannelID,
+		helpers.GetTextF("plugins.mod.automod-updated", helpers.MdbIdToHuman(rule.ID))+"\n"+formatAutomodRule(rule))
+	helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+}
+
+// addAutomodRule creates a rule, regex rules take the rest of the message as pattern
+func (m *Mod) addAutomodRule(content string, args []string, msg *discordgo.Message) {
+	if len(args) < 3 {
+		_, err := helpers.SendMessage(msg.ChannelID, helpers.GetText("bot.arguments.too-few"))
+		helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+		return
+	}
+
+	rule := models.AutomodRuleEntry{
+		GuildID:         msg.GuildID,
+		Type:            models.AutomodRuleType(strings.ToLower(args[1])),
+		Enabled:         true,
+		CreatedByUserID: msg.Author.ID,
+		CreatedAt:       time.Now(),
+	}
+	if !isValidAutomodRuleType(rule.Type) {
+		_, err := helpers.SendMessage(msg.ChannelID, helpers.GetText("plugins.mod.automod-invalid-type"))
+		helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+		return
+	}
+	for _, action := range strings.Split(strings.ToLower(args[2]), ",") {
+		if !isValidAutomodActionType(models.AutomodActionType(action)) {
+			_, err := helpers.SendMessage(msg.ChannelID, helpers.GetText("plugins.mod.automod-invalid-action"))
+			helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+			return
+		}
+		rule.Actions = append(rule.Actions, models.AutomodActionType(action))
+	}
+
+	switch rule.Type {
+	case models.AutomodRuleTypeMentions, models.AutomodRuleTypeDuplicates, models.AutomodRuleTypeCaps:
+		rule.Threshold = automodRuleDefaultThresholds[rule.Type]
+		if len(args) >= 4 {
+			threshold, err := strconv.Atoi(args[3])
+			if err != nil || threshold < 1 || (rule.Type == models.AutomodRuleTypeCaps && threshold > 100) {
+				_, err = helpers.SendMessage(msg.ChannelID, helpers.GetText("bot.arguments.invalid"))
+				helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+				return
+			}
+			rule.Threshold = threshold
+		}
+	case models.AutomodRuleTypeRegex:
+		pattern := strings.TrimSpace(strings.SplitN(content, args[2], 2)[1])
+		if pattern != "" {
+			rule.Values = []string{pattern}
+		}
+	default:
+		rule.Values = normalizeAutomodValues(rule.Type, strings.FieldsFunc(strings.Join(args[3:], " "), func(r rune) bool {
+			return r == ',' || r == ' '
+		}))
+	}
+	if len(rule.Values) <= 0 && rule.Type != models.AutomodRuleTypeInvites && rule.Threshold <= 0 {
+		_, err := helpers.SendMessage(msg.ChannelID, helpers.GetText("bot.arguments.too-few"))
+		helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+		return
+	}
+	if _, err := newAutomodRule(rule); err != nil {
+		_, err = helpers.SendMessage(msg.ChannelID, helpers.GetText("plugins.mod.automod-invalid-regex"))
+		helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+		return
+	}
+
+	count, err := helpers.MdbCollection(models.AutomodRulesTable).Find(bson.M{"guildid": msg.GuildID}).Count()
+	helpers.Relax(err)
+	if count >= automodMaxRules {
+		_, err = helpers.SendMessage(msg.ChannelID, helpers.GetText("plugins.mod.automod-too-many"))
+		helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+		return
+	}
+
+	rule.ID, err = helpers.MDbInsert(models.AutomodRulesTable, rule)
+	helpers.Relax(err)
+	invalidateAutomodRules(msg.GuildID)
+
+	_, err = helpers.EventlogLog(time.Now(), msg.GuildID, helpers.MdbIdToHuman(rule.ID),
+		models.EventlogTargetTypeRobyulAutomodRule, msg.Author.ID,
+		models.EventlogTypeRobyulAutomodRuleAdd, "",
+		nil,
+		automodRuleEventlogOptions(rule), false)
+	helpers.RelaxLog(err)
+
+	_, err = helpers.SendMessage(msg.ChannelID,
+		helpers.GetTextF("plugins.mod.automod-added", helpers.MdbIdToHuman(rule.ID))+"\n"+formatAutomodRule(rule))
+	helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+}
+
+// listAutomodRules lists all rules of a guild
+func (m *Mod) listAutomodRules(msg *discordgo.Message) {
+	var rules []models.AutomodRuleEntry
+	err := helpers.MDbIter(
+		helpers.MdbCollection(models.AutomodRulesTable).Find(bson.M{"guildid": msg.GuildID}).Sort("createdat"),
+	).All(&rules)
+	helpers.Relax(err)
+
+	if len(rules) <= 0 {
+		_, err = helpers.SendMessage(msg.ChannelID, helpers.GetText("plugins.mod.automod-none"))
+		helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+		return
+	}
+
+	resultText := helpers.GetText("plugins.mod.automod-list-title") + "\n"
+	for _, rule := range rules {
+		resultText += formatAutomodRule(rule) + "\n"
+	}
+
+	for _, page := range helpers.Pagify(resultText, "\n") {
+		_, err = helpers.SendMessage(msg.ChannelID, page)
+		helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+	}
+}
+
+// testAutomodRules shows which rules match a text, without applying them
+func (m *Mod) testAutomodRules(text string, msg *discordgo.Message) {
+	if text == "" {
+		_, err := helpers.SendMessage(msg.ChannelID, helpers.GetText("bot.arguments.too-few"))
+		helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+		return
+	}
+
+	message := automodMessage{content: text}
+	resultText := ""
+	for _, rule := range getAutomodRules(msg.GuildID) {
+		if matched, match := rule.match(message); matched {
+			resultText += helpers.GetTextF("plugins.mod.automod-test-match", helpers.MdbIdToHuman(rule.ID), rule.Type, match) + "\n"
+		}
+	}
+	if resultText == "" {
+		resultText = helpers.GetText("plugins.mod.automod-test-no-match")
+	}
+
+	_, err := helpers.SendMessage(msg.ChannelID, resultText)
+	helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+}
+
+func formatAutomodRule(rule models.AutomodRuleEntry) string {
+	actions := make([]string, 0, len(rule.Actions))
+	for _, action := range rule.Actions {
+		if action == models.AutomodActionTypeMute && rule.MuteDuration != "" {
+			actions = append(actions, string(action)+" "+rule.MuteDuration)
+			continue
+		}
+		actions = append(actions, string(action))
+	}
+
+	details := strings.Join(rule.Values, ", ")
+	if rule.Threshold > 0 {
+		details = strconv.Itoa(rule.Threshold)
+	}
+
+	text := helpers.GetTextF("plugins.mod.automod-list-entry",
+		helpers.MdbIdToHuman(rule.ID), rule.Type, strings.Join(actions, ", "), details)
+	if !rule.Enabled {
+		text += " " + helpers.GetText("plugins.mod.automod-list-entry-disabled")
+	}
+	if len(rule.ExemptChannelIDs) > 0 {
+		text += " " + helpers.GetTextF("plugins.mod.automod-list-entry-exempt", "<#"+strings.Join(rule.ExemptChannelIDs, ">, <#")+">")
+	}
+	return text
+}
+
+func automodRuleEventlogOptions(rule models.AutomodRuleEntry) []models.ElasticEventlogOption {
+	actions := make([]string, 0, len(rule.Actions))
+	for _, action := range rule.Actions {
+		actions = append(actions, string(action))
+	}
+
+	return []models.ElasticEventlogOption{
+		{
+			Key:   "automod_ruletype",
+			Value: string(rule.Type),
+		},
+		{
+			Key:   "automod_actions",
+			Value: strings.Join(actions, ","),
+		},
+		{
+			Key:   "automod_values",
+			Value: strings.Join(rule.Values, ","),
+		},
+		{
+			Key:   "automod_threshold",
+			Value: strconv.Itoa(rule.Threshold),
+		},
+	}
+}
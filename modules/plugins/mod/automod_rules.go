@@ -0,0 +1,204 @@
+package mod
+
+import (
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/Seklfreak/Robyul2/models"
+)
+
+const (
+	// automodCapsMinLetters is the minimum of cased letters a message needs before the caps ratio is checked
+	automodCapsMinLetters = 10
+	// automodMatchMaxLength is the maximum length of a match stored in the eventlog
+	automodMatchMaxLength = 100
+)
+
+var (
+	automodInviteRegex = regexp.MustCompile(`(?i)(?:discord\.gg|discord\.io|discord\.me|discord(?:app)?\.com/invite)/([a-z0-9-]+)`)
+	automodLinkRegex   = regexp.MustCompile(`(?i)https?://([^/\s<>"'?#]+)`)
+
+	automodRuleDefaultThresholds = map[models.AutomodRuleType]int{
+		models.AutomodRuleTypeMentions:   5,
+		models.AutomodRuleTypeDuplicates: 3,
+		models.AutomodRuleTypeCaps:       70,
+	}
+)
+
+// automodRule is a rule with its patterns compiled
+type automodRule struct {
+	models.AutomodRuleEntry
+	regexes []*regexp.Regexp
+}
+
+// automodMessage contains everything about a message the rules can match
+// duplicates is the number of identical messages of the author within the duplicates window, including this one
+type automodMessage struct {
+	content     string
+	mentions    int
+	attachments []string
+	duplicates  int
+}
+
+// newAutomodRule compiles the patterns of a rule
+func newAutomodRule(entry models.AutomodRuleEntry) (rule *automodRule, err error) {
+	rule = &automodRule{AutomodRuleEntry: entry}
+
+	switch entry.Type {
+	case models.AutomodRuleTypeRegex:
+		for _, value := range entry.Values {
+			regex, err := regexp.Compile(value)
+			if err != nil {
+				return nil, err
+			}
+			rule.regexes = append(rule.regexes, regex)
+		}
+	case models.AutomodRuleTypeWords:
+		if len(entry.Values) <= 0 {
+			break
+		}
+		words := make([]string, 0, len(entry.Values))
+		for _, value := range entry.Values {
+			words = append(words, regexp.QuoteMeta(value))
+		}
+		// \b only knows ASCII word characters, so the word boundaries are built from unicode classes instead
+		regex, err := regexp.Compile(`(?i)(?:^|[^\pL\pN])(` + strings.Join(words, "|") + `)(?:$|[^\pL\pN])`)
+		if err != nil {
+			return nil, err
+		}
+		rule.regexes = append(rule.regexes, regex)
+	}
+
+	return rule, nil
+}
+
+// isValidAutomodRuleType returns true if ruleType is a known rule type
+func isValidAutomodRuleType(ruleType models.AutomodRuleType) bool {
+	switch ruleType {
+	case models.AutomodRuleTypeRegex, models.AutomodRuleTypeWords, models.AutomodRuleTypeInvites,
+		models.AutomodRuleTypeMentions, models.AutomodRuleTypeDuplicates, models.AutomodRuleTypeCaps,
+		models.AutomodRuleTypeAttachments, models.AutomodRuleTypeDomains:
+		return true
+	}
+	return false
+}
+
+// isValidAutomodActionType returns true if actionType is a known action type
+func isValidAutomodActionType(actionType models.AutomodActionType) bool {
+	switch actionType {
+	case models.AutomodActionTypeDelete, models.AutomodActionTypeWarn,
+		models.AutomodActionTypeMute, models.AutomodActionTypeLog:
+		return true
+	}
+	return false
+}
+
+// isExempt returns true if the rule is not checked in the channel
+func (r *automodRule) isExempt(channelID string) bool {
+	for _, exemptChannelID := range r.ExemptChannelIDs {
+		if exemptChannelID == channelID {
+			return true
+		}
+	}
+	return false
+}
+
+// match checks a message against the rule, returns the matched part of the message if it matches
+func (r *automodRule) match(message automodMessage) (matched bool, match string) {
+	switch r.Type {
+	case models.AutomodRuleTypeRegex:
+		for _, regex := range r.regexes {
+			if found := regex.FindString(message.content); found != "" {
+				return true, found
+			}
+		}
+	case models.AutomodRuleTypeWords:
+		for _, regex := range r.regexes {
+			if found := regex.FindStringSubmatch(message.content); found != nil {
+				return true, found[1]
+			}
+		}
+	case models.AutomodRuleTypeInvites:
+	InvitesLoop:
+		for _, invite := range automodInviteRegex.FindAllStringSubmatch(message.content, -1) {
+			for _, allowedCode := range r.Values {
+				if strings.EqualFold(invite[1], allowedCode) {
+					continue InvitesLoop
+				}
+			}
+			return true, invite[0]
+		}
+	case models.AutomodRuleTypeMentions:
+		if message.mentions >= r.Threshold {
+			return true, strconv.Itoa(message.mentions)
+		}
+	case models.AutomodRuleTypeDuplicates:
+		if message.duplicates >= r.Threshold {
+			return true, message.content
+		}
+	case models.AutomodRuleTypeCaps:
+		var upper, cased int
+		for _, character := range message.content {
+			if unicode.IsUpper(character) {
+				upper++
+				cased++
+			} else if unicode.IsLower(character) {
+				cased++
+			}
+		}
+		if cased >= automodCapsMinLetters && upper*100 >= r.Threshold*cased {
+			return true, strconv.Itoa(upper*100/cased) + "%"
+		}
+	case models.AutomodRuleTypeAttachments:
+		for _, filename := range message.attachments {
+			extension := strings.ToLower(strings.TrimPrefix(path.Ext(filename), "."))
+			for _, blockedExtension := range r.Values {
+				if extension == blockedExtension {
+					return true, filename
+				}
+			}
+		}
+	case models.AutomodRuleTypeDomains:
+		for _, link := range automodLinkRegex.FindAllStringSubmatch(message.content, -1) {
+			host := strings.ToLower(link[1])
+			if index := strings.LastIndex(host, "@"); index >= 0 {
+				host = host[index+1:]
+			}
+			host = strings.TrimSuffix(strings.Split(host, ":")[0], ".")
+			for _, domain := range r.Values {
+				if host == domain || strings.HasSuffix(host, "."+domain) {
+					return true, host
+				}
+			}
+		}
+	}
+
+	return false, ""
+}
+
+// normalizeAutomodValues cleans up the values of a rule depending on its type
+func normalizeAutomodValues(ruleType models.AutomodRuleType, values []string) (normalized []string) {
+	for _, value := range values {
+		switch ruleType {
+		case models.AutomodRuleTypeWords, models.AutomodRuleTypeDomains:
+			value = strings.ToLower(value)
+		case models.AutomodRuleTypeAttachments:
+			value = strings.ToLower(strings.TrimPrefix(value, "."))
+		case models.AutomodRuleTypeInvites:
+			if invite := automodInviteRegex.FindStringSubmatch(value); invite != nil {
+				value = invite[1]
+			}
+		}
+		if ruleType == models.AutomodRuleTypeDomains {
+			value = strings.TrimPrefix(strings.TrimPrefix(value, "https://"), "http://")
+			value = strings.TrimSuffix(strings.TrimPrefix(value, "www."), "/")
+		}
+		if value != "" {
+			normalized = append(normalized, value)
+		}
+	}
+	return normalized
+}
@@ -0,0 +1,38 @@
+package mod
+
+import (
+	"testing"
+
+	"github.com/Seklfreak/Robyul2/models"
+)
+
+func TestAutomodRuleMatch(t *testing.T) {
+	tests := []struct {
+		entry   models.AutomodRuleEntry
+		message automodMessage
+		matched bool
+	}{
+		{models.AutomodRuleEntry{Type: models.AutomodRuleTypeWords, Values: []string{"bad"}}, automodMessage{content: "this is BAD!"}, true},
+		{models.AutomodRuleEntry{Type: models.AutomodRuleTypeWords, Values: []string{"bad"}}, automodMessage{content: "badge"}, false},
+		{models.AutomodRuleEntry{Type: models.AutomodRuleTypeRegex, Values: []string{`fr[e3]{2}`}}, automodMessage{content: "get fr33 stuff"}, true},
+		{models.AutomodRuleEntry{Type: models.AutomodRuleTypeInvites}, automodMessage{content: "join discord.gg/abc"}, true},
+		{models.AutomodRuleEntry{Type: models.AutomodRuleTypeInvites, Values: []string{"abc"}}, automodMessage{content: "join https://discordapp.com/invite/ABC"}, false},
+		{models.AutomodRuleEntry{Type: models.AutomodRuleTypeMentions, Threshold: 5}, automodMessage{mentions: 4}, false},
+		{models.AutomodRuleEntry{Type: models.AutomodRuleTypeDuplicates, Threshold: 3}, automodMessage{duplicates: 3}, true},
+		{models.AutomodRuleEntry{Type: models.AutomodRuleTypeCaps, Threshold: 70}, automodMessage{content: "WHY IS NOBODY ANSWERING"}, true},
+		{models.AutomodRuleEntry{Type: models.AutomodRuleTypeCaps, Threshold: 70}, automodMessage{content: "OK"}, false},
+		{models.AutomodRuleEntry{Type: models.AutomodRuleTypeAttachments, Values: []string{"exe"}}, automodMessage{attachments: []string{"setup.EXE"}}, true},
+		{models.AutomodRuleEntry{Type: models.AutomodRuleTypeDomains, Values: []string{"example.com"}}, automodMessage{content: "see https://cdn.example.com/a"}, true},
+		{models.AutomodRuleEntry{Type: models.AutomodRuleTypeDomains, Values: []string{"example.com"}}, automodMessage{content: "see https://notexample.com/a"}, false},
+	}
+
+	for _, test := range tests {
+		rule, err := newAutomodRule(test.entry)
+		if err != nil {
+			t.Fatalf("mod.newAutomodRule() failed for %+v: %s", test.entry, err.Error())
+		}
+		if matched, _ := rule.match(test.message); matched != test.matched {
+			t.Errorf("mod.automodRule.match() returned %t for %s rule and %+v", matched, test.entry.Type, test.message)
+		}
+	}
+}
@@ -4,13 +4,16 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/Seklfreak/Robyul2/cache"
 	"github.com/Seklfreak/Robyul2/helpers"
+	"github.com/Seklfreak/Robyul2/models"
 	"github.com/bwmarrin/discordgo"
 )
 
-// banHandler [p]ban <User> [<Days>] [<Reason>], checks for IsMod and Ban Permissions
+// banHandler [p]ban <User> [<Days>] [<Duration>] [<Reason>], checks for IsMod and Ban Permissions
+// users banned with a duration like 7d are unbanned once it ends
 func banHandler(msg *discordgo.Message, content string, confirmation bool) {
 	if !helpers.IsMod(msg) {
 		helpers.SendMessage(msg.ChannelID, helpers.GetText("mod.no_permission"))
@@ -71,6 +74,16 @@ func banHandler(msg *discordgo.Message, content string, confirmation bool) {
 		}
 	}
 
+	// Duration Argument
+	var unbanAt time.Time
+	if len(args) >= offset+1 {
+		var ok bool
+		unbanAt, ok = parseModActionDuration(time.Now(), args[offset])
+		if ok {
+			offset++
+		}
+	}
+
 	// Bot can ban?
 	var botCanBan bool
 	guild, err := helpers.GetGuild(msg.GuildID)
@@ -121,8 +134,10 @@ func banHandler(msg *discordgo.Message, content string, confirmation bool) {
 		msg.Author.Username, msg.Author.Discriminator, msg.Author.ID, days,
 	)
 
+	var reason string
 	if len(args) >= offset+1 {
-		reasonText += strings.TrimSpace(strings.Replace(content, strings.Join(args[:offset], " "), "", 1))
+		reason = strings.TrimSpace(strings.Replace(content, strings.Join(args[:offset], " "), "", 1))
+		reasonText += reason
 	}
 
 	if strings.HasSuffix(reasonText, "Reason: ") {
@@ -136,15 +151,14 @@ func banHandler(msg *discordgo.Message, content string, confirmation bool) {
 	}
 	usersToBanText = strings.TrimRight(usersToBanText, ", ")
 
+	confirmText := helpers.GetTextF("plugins.mod.confirm-ban", usersToBanText, days, reasonText)
+	if !unbanAt.IsZero() {
+		confirmText = helpers.GetTextF("plugins.mod.confirm-ban-timed",
+			usersToBanText, days, reasonText, unbanAt.UTC().Format(time.ANSIC)+" UTC")
+	}
+
 	if !confirmation ||
-		helpers.ConfirmEmbed(
-			msg.ChannelID, msg.Author,
-			helpers.GetTextF(
-				"plugins.mod.confirm-ban",
-				usersToBanText,
-				days,
-				reasonText,
-			), "✅", "🚫") {
+		helpers.ConfirmEmbed(msg.ChannelID, msg.Author, confirmText, "✅", "🚫") {
 		for _, userToBan := range usersToBan {
 			err = cache.GetSession().GuildBanCreateWithReason(guild.ID, userToBan.ID, reasonText, days)
 			if err != nil {
@@ -166,7 +180,32 @@ func banHandler(msg *discordgo.Message, content string, confirmation bool) {
 				"Banned User %s (#%s) on Guild %s (#%s) by %s (#%s)",
 				userToBan.Username, userToBan.ID, guild.Name, guild.ID, msg.Author.Username, msg.Author.ID,
 			))
-			_, err = helpers.SendMessage(msg.ChannelID, helpers.GetTextF("plugins.mod.user-banned-success", userToBan.Username, userToBan.ID))
+
+			successText := helpers.GetTextF("plugins.mod.user-banned-success", userToBan.Username, userToBan.ID)
+			if !unbanAt.IsZero() {
+				_, err = helpers.CreateModAction(models.ModActionEntry{
+					GuildID:         guild.ID,
+					UserID:          userToBan.ID,
+					Type:            models.ModActionTypeBan,
+					Reason:          reasonText,
+					CreatedByUserID: msg.Author.ID,
+					ExpiresAt:       unbanAt,
+				})
+				helpers.RelaxLog(err)
+				wakeupModActionsScheduler()
+
+				successText = helpers.GetTextF("plugins.mod.user-banned-success-timed",
+					userToBan.Username, userToBan.ID, unbanAt.UTC().Format(time.ANSIC)+" UTC")
+			} else {
+				// a permanent ban replaces a temp-ban
+				err = helpers.EndModActions(guild.ID, userToBan.ID, models.ModActionTypeBan, "",
+					models.ModActionEndReasonReplaced, msg.Author.ID)
+				helpers.RelaxLog(err)
+			}
+
+			successText += recordModCase(guild.ID, userToBan.ID, msg.Author.ID, models.ModCaseTypeBan, reason, unbanAt)
+
+			_, err = helpers.SendMessage(msg.ChannelID, successText)
 			helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
 		}
 	}
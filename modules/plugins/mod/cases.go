@@ -0,0 +1,471 @@
+package mod
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Seklfreak/Robyul2/cache"
+	"github.com/Seklfreak/Robyul2/helpers"
+	"github.com/Seklfreak/Robyul2/models"
+	"github.com/bwmarrin/discordgo"
+	"github.com/globalsign/mgo"
+)
+
+const (
+	modCasesMaxListEntries = 50
+	modCasesMaxEscalations = 10
+)
+
+// prepareModCases creates the indexes for the case lookups
+func prepareModCases() {
+	defer helpers.Recover()
+
+	collection := helpers.MdbCollection(models.ModCasesTable)
+	helpers.RelaxLog(collection.EnsureIndex(mgo.Index{Key: []string{"guildid", "caseid"}, Unique: true}))
+	helpers.RelaxLog(collection.EnsureIndex(mgo.Index{Key: []string{"guildid", "userid", "type"}}))
+}
+
+// recordModCase creates a case for an action of a moderator, returns a text with the case ID to add to the success message
+func recordModCase(guildID, userID, moderatorUserID string, caseType models.ModCaseType, reason string, expiresAt time.Time) string {
+	modCase, err := helpers.CreateModCase(models.ModCaseEntry{
+		GuildID:         guildID,
+		Type:            caseType,
+		UserID:          userID,
+		ModeratorUserID: moderatorUserID,
+		Reason:          reason,
+		ExpiresAt:       expiresAt,
+	})
+	if err != nil {
+		helpers.RelaxLog(err)
+		return ""
+	}
+
+	return " " + helpers.GetTextF("plugins.mod.case-suffix", modCase.CaseID)
+}
+
+// actionWarn warns a member, the member is notified by DM and escalations are applied
+// [p]warn <user> [<reason>]
+func (m *Mod) actionWarn(content string, msg *discordgo.Message) {
+	args := strings.Fields(content)
+	if len(args) < 1 {
+		_, err := helpers.SendMessage(msg.ChannelID, helpers.GetText("bot.arguments.too-few"))
+		helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+		return
+	}
+
+	targetUser, err := helpers.GetUserFromMention(args[0])
+	if err != nil || targetUser == nil {
+		_, err = helpers.SendMessage(msg.ChannelID, helpers.GetText("bot.arguments.invalid"))
+		helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+		return
+	}
+	reason := strings.TrimSpace(strings.TrimPrefix(content, args[0]))
+
+	guild, err := helpers.GetGuild(msg.GuildID)
+	helpers.Relax(err)
+
+	caseText := recordModCase(guild.ID, targetUser.ID, msg.Author.ID, models.ModCaseTypeWarn, reason, time.Time{})
+
+	_, err = helpers.EventlogLog(time.Now(), guild.ID, targetUser.ID,
+		models.EventlogTargetTypeUser, msg.Author.ID,
+		models.EventlogTypeRobyulWarn, reason,
+		nil,
+		nil, false)
+	helpers.RelaxLog(err)
+
+	dmText := helpers.GetTextF("plugins.mod.warn-dm", guild.Name)
+	if reason != "" {
+		dmText += "\n" + helpers.GetTextF("plugins.mod.warn-dm-reason", reason)
+	}
+	dmChannel, err := cache.GetSession().UserChannelCreate(targetUser.ID)
+	if err == nil {
+		_, err = helpers.SendMessage(dmChannel.ID, dmText)
+	}
+	// members can disable DMs
+	if err != nil {
+		caseText += " " + helpers.GetText("plugins.mod.warn-dm-failed")
+	}
+
+	_, err = helpers.SendMessage(msg.ChannelID,
+		helpers.GetTextF("plugins.mod.user-warned-success", targetUser.Username, targetUser.ID)+caseText)
+	helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+
+	escalationText := m.escalateWarnings(guild, targetUser)
+	if escalationText != "" {
+		_, err = helpers.SendMessage(msg.ChannelID, escalationText)
+		helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+	}
+}
+
+// escalateWarnings applies the escalation for the number of warnings of a member, returns a text describing the escalation
+func (m *Mod) escalateWarnings(guild *discordgo.Guild, targetUser *discordgo.User) string {
+	warns, err := helpers.CountModCases(guild.ID, targetUser.ID, models.ModCaseTypeWarn)
+	if err != nil {
+		helpers.RelaxLog(err)
+		return ""
+	}
+
+	var escalation *models.ModEscalation
+	for _, guildEscalation := range helpers.GuildSettingsGetCached(guild.ID).ModEscalations {
+		if guildEscalation.Warns == warns {
+			escalation = &guildEscalation
+			break
+		}
+	}
+	if escalation == nil {
+		return ""
+	}
+
+	var expiresAt time.Time
+	if escalation.Duration != "" {
+		expiresAt, _ = parseModActionDuration(time.Now(), escalation.Duration)
+	}
+
+	session := cache.GetSession()
+	botID := session.State.User.ID
+	reason := helpers.GetTextF("plugins.mod.escalation-reason", warns)
+
+	switch escalation.Action {
+	case models.ModCaseTypeMute:
+		err = helpers.MuteUser(guild.ID, targetUser.ID, expiresAt, botID, reason)
+		if err == nil {
+			var options []models.ElasticEventlogOption
+			if !expiresAt.IsZero() {
+				options = []models.ElasticEventlogOption{
+					{
+						Key:   "mute_until",
+						Value: expiresAt.Format(models.ISO8601),
+					},
+				}
+			}
+			_, err := helpers.EventlogLog(time.Now(), guild.ID, targetUser.ID,
+				models.EventlogTargetTypeUser, botID,
+				models.EventlogTypeRobyulMute, reason,
+				nil,
+				options, false)
+			helpers.RelaxLog(err)
+			wakeupModActionsScheduler()
+		}
+	case models.ModCaseTypeKick:
+		err = session.GuildMemberDeleteWithReason(guild.ID, targetUser.ID, reason)
+	case models.ModCaseTypeBan:
+		err = session.GuildBanCreateWithReason(guild.ID, targetUser.ID, reason, 0)
+		if err == nil && !expiresAt.IsZero() {
+			_, errAction := helpers.CreateModAction(models.ModActionEntry{
+				GuildID:         guild.ID,
+				UserID:          targetUser.ID,
+				Type:            models.ModActionTypeBan,
+				Reason:          reason,
+				CreatedByUserID: botID,
+				ExpiresAt:       expiresAt,
+			})
+			helpers.RelaxLog(errAction)
+			wakeupModActionsScheduler()
+		}
+	default:
+		return ""
+	}
+	if err != nil {
+		if errD, ok := err.(*discordgo.RESTError); ok && errD.Message != nil &&
+			(errD.Message.Code == discordgo.ErrCodeMissingPermissions || errD.Message.Code == discordgo.ErrCodeMissingAccess) {
+			return helpers.GetTextF("plugins.mod.escalation-failed-permissions", escalation.Action)
+		}
+		helpers.RelaxLog(err)
+		return helpers.GetTextF("plugins.mod.escalation-failed", escalation.Action)
+	}
+
+	modCase, err := helpers.CreateModCase(models.ModCaseEntry{
+		GuildID:         guild.ID,
+		Type:            escalation.Action,
+		UserID:          targetUser.ID,
+		ModeratorUserID: botID,
+		Reason:          reason,
+		ExpiresAt:       expiresAt,
+		Automatic:       true,
+	})
+	helpers.RelaxLog(err)
+
+	return helpers.GetTextF("plugins.mod.escalation-applied",
+		targetUser.Username, targetUser.ID, formatModEscalation(*escalation), modCase.CaseID)
+}
+
+// actionNote adds a note about a member, notes are only visible to moderators
+// [p]note <user> <text>
+func (m *Mod) actionNote(content string, msg *discordgo.Message) {
+	args := strings.Fields(content)
+	if len(args) < 2 {
+		_, err := helpers.SendMessage(msg.ChannelID, helpers.GetText("bot.arguments.too-few"))
+		helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+		return
+	}
+
+	targetUser, err := helpers.GetUserFromMention(args[0])
+	if err != nil || targetUser == nil {
+		_, err = helpers.SendMessage(msg.ChannelID, helpers.GetText("bot.arguments.invalid"))
+		helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+		return
+	}
+
+	caseText := recordModCase(msg.GuildID, targetUser.ID, msg.Author.ID, models.ModCaseTypeNote,
+		strings.TrimSpace(strings.TrimPrefix(content, args[0])), time.Time{})
+
+	_, err = helpers.SendMessage(msg.ChannelID,
+		helpers.GetTextF("plugins.mod.note-added", targetUser.Username, targetUser.ID)+caseText)
+	helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+}
+
+// actionCases lists the cases of a member
+// [p]cases <user> [<warn|note|mute|kick|ban>]
+func (m *Mod) actionCases(args []string, msg *discordgo.Message) {
+	if len(args) < 1 {
+		_, err := helpers.SendMessage(msg.ChannelID, helpers.GetText("bot.arguments.too-few"))
+		helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+		return
+	}
+
+	targetUser, err := helpers.GetUserFromMention(args[0])
+	if err != nil || targetUser == nil {
+		_, err = helpers.SendMessage(msg.ChannelID, helpers.GetText("bot.arguments.invalid"))
+		helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+		return
+	}
+
+	var caseType models.ModCaseType
+	if len(args) >= 2 {
+		caseType = models.ModCaseType(strings.ToLower(strings.TrimSuffix(args[1], "s")))
+		if !isValidModCaseType(caseType) {
+			_, err = helpers.SendMessage(msg.ChannelID, helpers.GetText("bot.arguments.invalid"))
+			helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+			return
+		}
+	}
+
+	modCases, count, err := helpers.GetModCases(msg.GuildID, targetUser.ID, caseType, 0, modCasesMaxListEntries)
+	helpers.Relax(err)
+
+	if count == 0 {
+		_, err = helpers.SendMessage(msg.ChannelID,
+			helpers.GetTextF("plugins.mod.cases-none", targetUser.Username, targetUser.ID))
+		helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+		return
+	}
+
+	resultText := helpers.GetTextF("plugins.mod.cases-title", targetUser.Username, targetUser.ID, count) + "\n"
+	for _, modCase := range modCases {
+		resultText += formatModCase(modCase) + "\n"
+	}
+	if count > len(modCases) {
+		resultText += helpers.GetTextF("plugins.mod.cases-more", count-len(modCases)) + "\n"
+	}
+
+	for _, page := range helpers.Pagify(resultText, "\n") {
+		_, err = helpers.SendMessage(msg.ChannelID, page)
+		helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+	}
+}
+
+// actionCase shows a case, or changes its reason
+// [p]case <case id> [reason <new reason>]
+func (m *Mod) actionCase(content string, msg *discordgo.Message) {
+	args := strings.Fields(content)
+	if len(args) < 1 {
+		_, err := helpers.SendMessage(msg.ChannelID, helpers.GetText("bot.arguments.too-few"))
+		helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+		return
+	}
+
+	caseID, err := strconv.Atoi(strings.TrimPrefix(args[0], "#"))
+	if err != nil {
+		_, err = helpers.SendMessage(msg.ChannelID, helpers.GetText("bot.arguments.invalid"))
+		helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+		return
+	}
+
+	modCase, err := helpers.GetModCase(msg.GuildID, caseID)
+	if err != nil {
+		if helpers.IsMdbNotFound(err) {
+			_, err = helpers.SendMessage(msg.ChannelID, helpers.GetText("plugins.mod.case-not-found"))
+			helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+			return
+		}
+		helpers.Relax(err)
+	}
+
+	if len(args) < 2 {
+		_, err = helpers.SendMessage(msg.ChannelID, formatModCase(modCase))
+		helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+		return
+	}
+
+	if args[1] != "reason" || len(args) < 3 {
+		_, err = helpers.SendMessage(msg.ChannelID, helpers.GetText("bot.arguments.invalid"))
+		helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+		return
+	}
+
+	reason := strings.TrimSpace(strings.SplitN(content, "reason", 2)[1])
+	err = helpers.UpdateModCaseReason(modCase, reason, msg.Author.ID)
+	helpers.Relax(err)
+
+	_, err = helpers.SendMessage(msg.ChannelID, helpers.GetTextF("plugins.mod.case-reason-updated", modCase.CaseID))
+	helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+}
+
+// actionEscalations shows or changes the escalations of a guild
+// [p]escalations [add <warns> <mute|kick|ban> [<duration>]|remove <warns>]
+func (m *Mod) actionEscalations(args []string, msg *discordgo.Message) {
+	settings := helpers.GuildSettingsGetCached(msg.GuildID)
+
+	if len(args) < 1 {
+		if len(settings.ModEscalations) == 0 {
+			_, err := helpers.SendMessage(msg.ChannelID, helpers.GetText("plugins.mod.escalations-none"))
+			helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+			return
+		}
+
+		resultText := helpers.GetText("plugins.mod.escalations-title") + "\n"
+		for _, escalation := range settings.ModEscalations {
+			resultText += helpers.GetTextF("plugins.mod.escalations-entry", escalation.Warns, formatModEscalation(escalation)) + "\n"
+		}
+		_, err := helpers.SendMessage(msg.ChannelID, resultText)
+		helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+		return
+	}
+
+	if len(args) < 2 {
+		_, err := helpers.SendMessage(msg.ChannelID, helpers.GetText("bot.arguments.too-few"))
+		helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+		return
+	}
+
+	warns, err := strconv.Atoi(args[1])
+	if err != nil || warns < 1 {
+		_, err = helpers.SendMessage(msg.ChannelID, helpers.GetText("bot.arguments.invalid"))
+		helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+		return
+	}
+
+	oldValue := formatModEscalations(settings.ModEscalations)
+	newEscalations := make([]models.ModEscalation, 0)
+	for _, escalation := range settings.ModEscalations {
+		if escalation.Warns != warns {
+			newEscalations = append(newEscalations, escalation)
+		}
+	}
+
+	var message string
+	switch args[0] {
+	case "add", "set":
+		if len(args) < 3 {
+			_, err = helpers.SendMessage(msg.ChannelID, helpers.GetText("bot.arguments.too-few"))
+			helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+			return
+		}
+
+		escalation := models.ModEscalation{Warns: warns, Action: models.ModCaseType(strings.ToLower(args[2]))}
+		if escalation.Action != models.ModCaseTypeMute &&
+			escalation.Action != models.ModCaseTypeKick &&
+			escalation.Action != models.ModCaseTypeBan {
+			_, err = helpers.SendMessage(msg.ChannelID, helpers.GetText("bot.arguments.invalid"))
+			helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+			return
+		}
+		if len(args) >= 4 && escalation.Action != models.ModCaseTypeKick {
+			if _, ok := parseModActionDuration(time.Now(), args[3]); !ok {
+				_, err = helpers.SendMessage(msg.ChannelID, helpers.GetText("plugins.mod.invalid-duration"))
+				helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+				return
+			}
+			escalation.Duration = strings.ToLower(args[3])
+		}
+		if len(newEscalations) >= modCasesMaxEscalations {
+			_, err = helpers.SendMessage(msg.ChannelID, helpers.GetText("plugins.mod.escalations-too-many"))
+			helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+			return
+		}
+
+		newEscalations = append(newEscalations, escalation)
+		sort.Slice(newEscalations, func(i, j int) bool { return newEscalations[i].Warns < newEscalations[j].Warns })
+		message = helpers.GetTextF("plugins.mod.escalations-added", warns, formatModEscalation(escalation))
+	case "remove", "delete":
+		if len(newEscalations) == len(settings.ModEscalations) {
+			_, err = helpers.SendMessage(msg.ChannelID, helpers.GetText("plugins.mod.escalations-not-found"))
+			helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+			return
+		}
+		message = helpers.GetTextF("plugins.mod.escalations-removed", warns)
+	default:
+		_, err = helpers.SendMessage(msg.ChannelID, helpers.GetText("bot.arguments.invalid"))
+		helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+		return
+	}
+
+	settings.ModEscalations = newEscalations
+	err = helpers.GuildSettingsSet(msg.GuildID, settings)
+	helpers.Relax(err)
+
+	_, err = helpers.EventlogLog(time.Now(), msg.GuildID, msg.GuildID,
+		models.EventlogTargetTypeGuild, msg.Author.ID,
+		models.EventlogTypeRobyulModEscalationsUpdate, "",
+		[]models.ElasticEventlogChange{
+			{
+				Key:      "mod_escalations",
+				OldValue: oldValue,
+				NewValue: formatModEscalations(settings.ModEscalations),
+			},
+		},
+		nil, false)
+	helpers.RelaxLog(err)
+
+	_, err = helpers.SendMessage(msg.ChannelID, message)
+	helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+}
+
+func isValidModCaseType(caseType models.ModCaseType) bool {
+	switch caseType {
+	case models.ModCaseTypeWarn, models.ModCaseTypeNote, models.ModCaseTypeMute, models.ModCaseTypeKick, models.ModCaseTypeBan:
+		return true
+	}
+	return false
+}
+
+func formatModCase(modCase models.ModCaseEntry) string {
+	moderatorName := "N/A"
+	moderator, err := helpers.GetUserWithoutAPI(modCase.ModeratorUserID)
+	if err == nil && moderator != nil {
+		moderatorName = moderator.Username
+	}
+
+	reason := modCase.Reason
+	if reason == "" {
+		reason = helpers.GetText("plugins.mod.case-no-reason")
+	}
+
+	text := helpers.GetTextF("plugins.mod.case-entry",
+		modCase.CaseID, modCase.Type, modCase.CreatedAt.UTC().Format(time.ANSIC), moderatorName, reason)
+	if !modCase.ExpiresAt.IsZero() {
+		text += " " + helpers.GetTextF("plugins.mod.case-entry-until", modCase.ExpiresAt.UTC().Format(time.ANSIC))
+	}
+	if modCase.Automatic {
+		text += " " + helpers.GetText("plugins.mod.case-entry-automatic")
+	}
+	return text
+}
+
+func formatModEscalation(escalation models.ModEscalation) string {
+	if escalation.Duration != "" {
+		return fmt.Sprintf("%s %s", escalation.Action, escalation.Duration)
+	}
+	return string(escalation.Action)
+}
+
+func formatModEscalations(escalations []models.ModEscalation) string {
+	formatted := make([]string, 0, len(escalations))
+	for _, escalation := range escalations {
+		formatted = append(formatted, fmt.Sprintf("%d:%s", escalation.Warns, formatModEscalation(escalation)))
+	}
+	return strings.Join(formatted, ";")
+}
@@ -64,6 +64,14 @@ func (m *Mod) Commands() []string {
 		"toggle-chatlog",
 		"pending-unmutes",
 		"pending-mutes",
+		"timed-actions",
+		"temp-role",
+		"warn",
+		"note",
+		"cases",
+		"case",
+		"escalations",
+		"automod",
 		"batch-roles",
 		"set-bot-dp",
 		"pin",
@@ -137,6 +145,10 @@ func (m *Mod) Init(session *discordgo.Session) {
 		cache.GetLogger().WithField("module", "mod").Info(fmt.Sprintf("got invite link cache of %d servers", len(invitesCache)))
 	}()
 	go m.cacheBans()
+
+	startModActionsScheduler()
+	go prepareModCases()
+	go prepareAutomod()
 }
 
 func (m *Mod) Uninit(session *discordgo.Session) {
@@ -483,57 +495,58 @@ func (m *Mod) Action(command string, content string, msg *discordgo.Message, ses
 		helpers.RequireMod(msg, func() {
 			session.ChannelTyping(msg.ChannelID)
 
-			channel, err := helpers.GetChannel(msg.ChannelID)
-			helpers.Relax(err)
-
-			key := "delayed_tasks"
-			delayedTasks, err := cache.GetMachineryRedisClient().ZCard(key).Result()
-			helpers.Relax(err)
-
-			tasksJson, err := cache.GetMachineryRedisClient().ZRange(key, 0, delayedTasks).Result()
-			helpers.Relax(err)
-
-			resultText := ""
-
-			for _, taskJson := range tasksJson {
-				task, err := gabs.ParseJSON([]byte(taskJson))
-				helpers.Relax(err)
-
-				if task.Path("Name").Data().(string) != "unmute_user" {
-					continue
-				}
-
-				guildID := task.Path("Args").Index(0).Path("Value").Data().(string)
-				userID := task.Path("Args").Index(1).Path("Value").Data().(string)
-				etaString := task.Path("ETA").Data().(string)
-				eta, err := time.Parse(time.RFC3339, etaString)
-				helpers.Relax(err)
+			m.listTimedActions(msg, models.ModActionTypeMute, "")
+		})
+	case "timed-actions": // [p]timed-actions [<type>] [<user>] | cancel <id> | extend <id> <duration>
+		helpers.RequireMod(msg, func() {
+			session.ChannelTyping(msg.ChannelID)
 
-				if guildID != channel.GuildID {
-					continue
-				}
+			m.actionTimedActions(strings.Fields(content), msg)
+		})
+		return
+	case "warn": // [p]warn <user> [<reason>]
+		helpers.RequireMod(msg, func() {
+			session.ChannelTyping(msg.ChannelID)
 
-				user, err := helpers.GetUser(userID)
-				if err != nil {
-					user = new(discordgo.User)
-					user.Username = "N/A"
-					user.ID = userID
-				}
+			m.actionWarn(content, msg)
+		})
+		return
+	case "note": // [p]note <user> <text>
+		helpers.RequireMod(msg, func() {
+			m.actionNote(content, msg)
+		})
+		return
+	case "cases": // [p]cases <user> [<type>]
+		helpers.RequireMod(msg, func() {
+			session.ChannelTyping(msg.ChannelID)
 
-				resultText += fmt.Sprintf("Unmuting %s (`#%s`) at %s UTC\n", user.Username, user.ID, eta.Format(time.ANSIC))
-			}
+			m.actionCases(strings.Fields(content), msg)
+		})
+		return
+	case "case": // [p]case <case id> [reason <new reason>]
+		helpers.RequireMod(msg, func() {
+			m.actionCase(content, msg)
+		})
+		return
+	case "escalations": // [p]escalations [add <warns> <mute|kick|ban> [<duration>]|remove <warns>]
+		helpers.RequireAdmin(msg, func() {
+			m.actionEscalations(strings.Fields(content), msg)
+		})
+		return
+	case "automod": // [p]automod [list|add|remove|enable|disable|exempt|mute-duration|test]
+		helpers.RequireMod(msg, func() {
+			session.ChannelTyping(msg.ChannelID)
 
-			if resultText == "" {
-				resultText = "Found no pending unmutes."
-			} else {
-				resultText = "Found the following pending unmutes:\n" + resultText
-			}
+			m.actionAutomod(content, msg)
+		})
+		return
+	case "temp-role": // [p]temp-role <user> <duration> <role>
+		helpers.RequireMod(msg, func() {
+			session.ChannelTyping(msg.ChannelID)
 
-			for _, page := range helpers.Pagify(resultText, "\n") {
-				_, err = helpers.SendMessage(msg.ChannelID, page)
-				helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
-			}
+			m.actionTempRole(strings.Fields(content), msg)
 		})
+		return
 	case "mute": // [p]mute server <User>
 		helpers.RequireMod(msg, func() {
 			session.ChannelTyping(msg.ChannelID)
@@ -559,9 +572,12 @@ func (m *Mod) Action(command string, content string, msg *discordgo.Message, ses
 				channel, err := helpers.GetChannel(msg.ChannelID)
 				helpers.Relax(err)
 
-				err = helpers.MuteUser(channel.GuildID, targetUser.ID, timeToUnmuteAt)
+				err = helpers.MuteUser(channel.GuildID, targetUser.ID, timeToUnmuteAt, msg.Author.ID, "")
+				helpers.RelaxLog(err)
+				wakeupModActionsScheduler()
 
 				successText := helpers.GetTextF("plugins.mod.user-muted-success", targetUser.Username, targetUser.ID)
+				caseText := recordModCase(channel.GuildID, targetUser.ID, msg.Author.ID, models.ModCaseTypeMute, "", timeToUnmuteAt)
 
 				var options []models.ElasticEventlogOption
 				if time.Now().Before(timeToUnmuteAt) {
@@ -581,7 +597,7 @@ func (m *Mod) Action(command string, content string, msg *discordgo.Message, ses
 					options, false)
 				helpers.RelaxLog(err)
 
-				_, err = helpers.SendMessage(msg.ChannelID, successText)
+				_, err = helpers.SendMessage(msg.ChannelID, successText+caseText)
 				helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
 				return
 			} else {
@@ -1797,7 +1813,7 @@ func (m *Mod) Action(command string, content string, msg *discordgo.Message, ses
 			return
 		})
 		return
-	case "batch-roles": // [p]batch-roles role a | role b | role c [| [after=role name] [color=hex code]]
+	case "batch-roles": // [p]batch-roles role a | role b | role c [| [after=role name] [color=hex code] [expires=duration]]
 		// todo: permission settings
 		session.ChannelTyping(msg.ChannelID)
 		helpers.RequireMod(msg, func() {
@@ -1851,9 +1867,19 @@ func (m *Mod) Action(command string, content string, msg *discordgo.Message, ses
 					return
 				}
 			}
+			var expiresAt time.Time
+			if expiresText, ok := data["expires"]; ok {
+				expiresAt, ok = parseModActionDuration(time.Now(), expiresText)
+				if !ok {
+					_, err = helpers.SendMessage(msg.ChannelID, helpers.GetText("plugins.mod.invalid-duration"))
+					helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+					return
+				}
+			}
 
 			rolesToCreate := strings.Split(content, "|")
 			var rolesCreated int
+			createdRoleIDs := make([]string, 0)
 			roleErrors := make([]error, 0)
 			for _, roleToCreate := range rolesToCreate {
 				if strings.Contains(roleToCreate, "=") {
@@ -1899,9 +1925,28 @@ func (m *Mod) Action(command string, content string, msg *discordgo.Message, ses
 					serverRoles = newServerRoles
 				}
 				rolesCreated++
+				createdRoleIDs = append(createdRoleIDs, newRole.ID)
+			}
+
+			// the created roles will be deleted once they expire
+			if !expiresAt.IsZero() {
+				for _, createdRoleID := range createdRoleIDs {
+					_, err = helpers.CreateModAction(models.ModActionEntry{
+						GuildID:         channel.GuildID,
+						RoleID:          createdRoleID,
+						Type:            models.ModActionTypeBatchRole,
+						CreatedByUserID: msg.Author.ID,
+						ExpiresAt:       expiresAt,
+					})
+					helpers.RelaxLog(err)
+				}
+				wakeupModActionsScheduler()
 			}
 
 			resultText := fmt.Sprintf("Successfully created %d roles, failed to create %d roles", rolesCreated, len(roleErrors))
+			if !expiresAt.IsZero() && rolesCreated > 0 {
+				resultText += fmt.Sprintf(", the roles will be deleted at %s UTC", expiresAt.UTC().Format(time.ANSIC))
+			}
 
 			if afterRole != nil && afterRole.ID != "" {
 				_, err = session.GuildRoleReorder(channel.GuildID, serverRoles)
@@ -1928,6 +1973,12 @@ func (m *Mod) Action(command string, content string, msg *discordgo.Message, ses
 					Value: helpers.GetHexFromDiscordColor(colour),
 				})
 			}
+			if !expiresAt.IsZero() {
+				options = append(options, models.ElasticEventlogOption{
+					Key:   "batchroles_expires",
+					Value: expiresAt.UTC().Format(models.ISO8601),
+				})
+			}
 			if afterRole != nil {
 				options = append(options, models.ElasticEventlogOption{
 					Key:   "batchroles_afteroleid",
@@ -2451,21 +2502,25 @@ func (m *Mod) OnGuildMemberAdd(member *discordgo.Member, session *discordgo.Sess
 		go func() {
 			defer helpers.Recover()
 
-			settings := helpers.GuildSettingsGetCached(member.GuildID)
+			mutedCount, err := helpers.MdbCountWithoutLogging(models.MutedMembersTable,
+				bson.M{"guildid": member.GuildID, "userid": member.User.ID})
+			if err != nil {
+				helpers.RelaxLog(err)
+				return
+			}
+			if mutedCount <= 0 {
+				return
+			}
 
-			for _, mutedMember := range settings.MutedMembers {
-				if mutedMember == member.User.ID {
-					muteRole, err := helpers.GetMuteRole(member.GuildID)
-					if err != nil {
-						helpers.RelaxLog(err)
-						return
-					}
-					err = session.GuildMemberRoleAdd(member.GuildID, member.User.ID, muteRole.ID)
-					if err != nil {
-						helpers.RelaxLog(err)
-						return
-					}
-				}
+			muteRole, err := helpers.GetMuteRole(member.GuildID)
+			if err != nil {
+				helpers.RelaxLog(err)
+				return
+			}
+			err = session.GuildMemberRoleAdd(member.GuildID, member.User.ID, muteRole.ID)
+			if err != nil {
+				helpers.RelaxLog(err)
+				return
 			}
 		}()
 		go func() {
@@ -2499,6 +2554,7 @@ func (m *Mod) GetJoins(userID string, guildID string) (joins []models.ModJoinlog
 }
 
 func (m *Mod) OnMessage(content string, msg *discordgo.Message, session *discordgo.Session) {
+	m.automodCheckMessage(msg)
 }
 
 func (m *Mod) OnGuildMemberRemove(member *discordgo.Member, session *discordgo.Session) {
@@ -2537,6 +2593,12 @@ func (m *Mod) OnGuildBanAdd(user *discordgo.GuildBanAdd, session *discordgo.Sess
 
 func (m *Mod) OnGuildBanRemove(user *discordgo.GuildBanRemove, session *discordgo.Session) {
 	m.removeBanFromCache(user)
+
+	// the timed ban has been removed before it expired
+	if user.User != nil {
+		err := helpers.EndRevertedModActions(user.GuildID, user.User.ID, models.ModActionTypeBan, "")
+		helpers.RelaxLog(err)
+	}
 }
 func (m *Mod) OnMessageDelete(msg *discordgo.MessageDelete, session *discordgo.Session) {
 
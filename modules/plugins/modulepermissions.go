@@ -33,6 +33,11 @@ func (mp *ModulePermissions) Uninit(session *discordgo.Session) {
 
 }
 
+// Reloadable is true, Init only refreshes the permissions cache
+func (mp *ModulePermissions) Reloadable() bool {
+	return true
+}
+
 func (mp *ModulePermissions) Action(command string, content string, msg *discordgo.Message, session *discordgo.Session) {
 	session.ChannelTyping(msg.ChannelID)
 
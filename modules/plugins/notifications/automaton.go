@@ -0,0 +1,107 @@
+package notifications
+
+// ahoCorasick is an Aho-Corasick automaton that finds all occurrences of multiple patterns in a text in one pass
+// it works on bytes, matches of valid UTF-8 patterns in valid UTF-8 texts always start and end at rune boundaries
+type ahoCorasick struct {
+	nodes []ahoCorasickNode
+}
+
+type ahoCorasickNode struct {
+	next map[byte]int
+	// fail is the node of the longest proper suffix of this node that is in the trie
+	fail int
+	// pattern is the pattern ending at this node, -1 if none
+	pattern int
+	// output is the next node on the fail chain a pattern ends at, -1 if none
+	output int
+	depth  int
+}
+
+// newAhoCorasick builds the automaton for the patterns, empty patterns are never found
+func newAhoCorasick(patterns []string) *ahoCorasick {
+	automaton := &ahoCorasick{
+		nodes: []ahoCorasickNode{{next: make(map[byte]int), pattern: -1, output: -1}},
+	}
+
+	for i, pattern := range patterns {
+		if pattern == "" {
+			continue
+		}
+
+		current := 0
+		for j := 0; j < len(pattern); j++ {
+			next, ok := automaton.nodes[current].next[pattern[j]]
+			if !ok {
+				next = len(automaton.nodes)
+				automaton.nodes = append(automaton.nodes, ahoCorasickNode{
+					next:    make(map[byte]int),
+					pattern: -1,
+					output:  -1,
+					depth:   automaton.nodes[current].depth + 1,
+				})
+				automaton.nodes[current].next[pattern[j]] = next
+			}
+			current = next
+		}
+		automaton.nodes[current].pattern = i
+	}
+
+	// breadth first, so the fail nodes are always complete
+	queue := make([]int, 0, len(automaton.nodes))
+	for _, child := range automaton.nodes[0].next {
+		queue = append(queue, child)
+	}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+
+		for character, child := range automaton.nodes[current].next {
+			fail := automaton.nodes[current].fail
+			for {
+				if next, ok := automaton.nodes[fail].next[character]; ok && next != child {
+					automaton.nodes[child].fail = next
+					break
+				}
+				if fail == 0 {
+					automaton.nodes[child].fail = 0
+					break
+				}
+				fail = automaton.nodes[fail].fail
+			}
+
+			failNode := automaton.nodes[automaton.nodes[child].fail]
+			if failNode.pattern >= 0 {
+				automaton.nodes[child].output = automaton.nodes[child].fail
+			} else {
+				automaton.nodes[child].output = failNode.output
+			}
+
+			queue = append(queue, child)
+		}
+	}
+
+	return automaton
+}
+
+// findAll calls found with the pattern, start and end offset of every occurrence of a pattern in the text
+func (automaton *ahoCorasick) findAll(text string, found func(pattern, start, end int)) {
+	var current int
+	for i := 0; i < len(text); i++ {
+		for {
+			if next, ok := automaton.nodes[current].next[text[i]]; ok {
+				current = next
+				break
+			}
+			if current == 0 {
+				break
+			}
+			current = automaton.nodes[current].fail
+		}
+
+		for match := current; match > 0; match = automaton.nodes[match].output {
+			if automaton.nodes[match].pattern >= 0 {
+				found(automaton.nodes[match].pattern, i+1-automaton.nodes[match].depth, i+1)
+			}
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package notifications
 
 import (
+	"fmt"
 	"strings"
 
 	"github.com/bwmarrin/discordgo"
@@ -9,82 +10,48 @@ import (
 	"github.com/Seklfreak/Robyul2/models"
 )
 
-func getAllDelimiterCombinations() []delimiterCombination {
-	var result []delimiterCombination
-	for _, delimiterStart := range ValidTextDelimiters {
-		for _, delimiterEnd := range ValidTextDelimiters {
-			result = append(result, delimiterCombination{
-				Start: delimiterStart,
-				End:   delimiterEnd,
-			})
+func getTextDelimiterRunes() map[rune]bool {
+	result := make(map[rune]bool)
+	for _, delimiter := range ValidTextDelimiters {
+		for _, character := range delimiter {
+			result[character] = true
 		}
 	}
 	return result
 }
 
-func keywordMatches(message, keyword string) bool {
-	// early match if message is keyword
-	if message == keyword {
-		return true
-	}
-
-	// early skip if word is not found
-	if !strings.Contains(message, keyword) {
-		return false
-	}
-
-	var lookup strings.Builder
-
-	// check common cases first
-	// spaces around word
-	lookup.WriteString(" ")
-	lookup.WriteString(keyword)
-	lookup.WriteString(" ")
-	if strings.Contains(message, lookup.String()) {
-		return true
-	}
-
-	// spaces after word
-	lookup.Reset()
-	lookup.WriteString(keyword)
-	lookup.WriteString(" ")
-	if strings.HasPrefix(message, lookup.String()) {
-		return true
-	}
-
-	// check delimiter around word
-	for _, combination := range generatedDelimiterCombinations {
-		lookup.Reset()
-		lookup.WriteString(combination.Start)
-		lookup.WriteString(keyword)
-		lookup.WriteString(combination.End)
-
-		if strings.Contains(message, lookup.String()) {
-			return true
+// formatKeywords lists the keywords like `a`, `b` and `c`
+func formatKeywords(keywords []string) (text string) {
+	for i, keyword := range keywords {
+		text += fmt.Sprintf("`%s`", keyword)
+		if i+2 < len(keywords) {
+			text += ", "
+		} else if (len(keywords) - (i + 1)) > 0 {
+			text += " and "
 		}
 	}
-	// check delimiter after word
-	for _, delimiter := range ValidTextDelimiters {
-		lookup.Reset()
-		lookup.WriteString(keyword)
-		lookup.WriteString(delimiter)
+	return text
+}
 
-		if strings.HasPrefix(message, lookup.String()) {
-			return true
-		}
-	}
-	// check delimiter before word
-	for _, delimiter := range ValidTextDelimiters {
-		lookup.Reset()
-		lookup.WriteString(delimiter)
-		lookup.WriteString(keyword)
+// escapeNotificationContent makes message content safe to put into a code block
+func escapeNotificationContent(content string) string {
+	content = strings.Replace(content, "```", "", -1)
+	content = strings.Replace(content, "`", "'", -1)
+	return strings.TrimSpace(strings.Trim(content, "\n"))
+}
 
-		if strings.HasSuffix(message, lookup.String()) {
-			return true
+// parseKeywordType splits the keyword type off the start of the keywords
+func parseKeywordType(keywords string) (string, models.NotificationsKeywordType) {
+	for _, keywordType := range []models.NotificationsKeywordType{
+		models.NotificationsKeywordTypePhrase,
+		models.NotificationsKeywordTypeWildcard,
+		models.NotificationsKeywordTypeRegex,
+	} {
+		if strings.HasPrefix(keywords, string(keywordType)+" ") {
+			return strings.TrimSpace(strings.TrimPrefix(keywords, string(keywordType)+" ")), keywordType
 		}
 	}
-
-	return false
+	return keywords, models.NotificationsKeywordTypeDefault
 }
 
 func refreshNotificationSettingsCache() (err error) {
@@ -93,12 +60,7 @@ func refreshNotificationSettingsCache() (err error) {
 	if err != nil {
 		return err
 	}
-	for i := range temporaryNotificationSettingsCache {
-		temporaryNotificationSettingsCache[i].Keyword = strings.ToLower(
-			temporaryNotificationSettingsCache[i].Keyword,
-		)
-	}
-	notificationSettingsCache = temporaryNotificationSettingsCache
+	notificationKeywordMatcher = newKeywordMatcher(temporaryNotificationSettingsCache)
 
 	err = helpers.MDbIter(helpers.MdbCollection(models.NotificationsIgnoredChannelsTable).Find(nil)).All(&ignoredChannelsCache)
 	if err != nil {
@@ -0,0 +1,457 @@
+package notifications
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Seklfreak/Robyul2/cache"
+	"github.com/Seklfreak/Robyul2/helpers"
+	"github.com/Seklfreak/Robyul2/metrics"
+	"github.com/Seklfreak/Robyul2/models"
+	"github.com/bwmarrin/discordgo"
+	"github.com/globalsign/mgo/bson"
+)
+
+var (
+	quietHoursRegex = regexp.MustCompile(`^([0-9]{1,2})(?::([0-9]{2}))?-([0-9]{1,2})(?::([0-9]{2}))?$`)
+
+	notificationsRateLimiter = &rateLimiter{windows: make(map[string]rateLimitWindow)}
+)
+
+// deliverySettings are the notification delivery preferences of a user
+type deliverySettings struct {
+	// digestInterval is zero for immediate notifications
+	digestInterval time.Duration
+	quietHours     quietHours
+	location       *time.Location
+}
+
+// quietHours are the minutes of the day from start to end, wrapping around midnight if end is before start
+type quietHours struct {
+	enabled bool
+	start   int
+	end     int
+}
+
+type rateLimiter struct {
+	sync.Mutex
+	windows  map[string]rateLimitWindow
+	prunedAt time.Time
+}
+
+type rateLimitWindow struct {
+	start time.Time
+	count int
+}
+
+func getDeliverySettings(userID string) (settings deliverySettings) {
+	settings.digestInterval = time.Duration(
+		helpers.GetUserConfigInt(userID, UserConfigNotificationsDigestIntervalKey, 0)) * time.Minute
+	settings.quietHours, _ = parseQuietHours(helpers.GetUserConfigString(userID, UserConfigNotificationsQuietHoursKey, ""))
+	settings.location = getUserLocation(userID)
+	return settings
+}
+
+// getUserLocation returns the location of the timezone in the profile of the user, UTC if none is set
+func getUserLocation(userID string) (location *time.Location) {
+	userData, err := helpers.GetUserUserdata(userID)
+	if err != nil || userData.Timezone == "" {
+		return time.UTC
+	}
+	location, err = time.LoadLocation(userData.Timezone)
+	if err != nil || location == nil {
+		return time.UTC
+	}
+	return location
+}
+
+// parseQuietHours parses quiet hours like 22-7 or 22:30-07:00, off disables quiet hours
+func parseQuietHours(text string) (hours quietHours, err error) {
+	if text == "" || text == "off" {
+		return hours, nil
+	}
+
+	parts := quietHoursRegex.FindStringSubmatch(text)
+	if parts == nil {
+		return hours, QuietHoursInvalidError
+	}
+
+	minutes := make([]int, 4)
+	for i, part := range parts[1:] {
+		if part != "" {
+			minutes[i], err = strconv.Atoi(part)
+			if err != nil {
+				return hours, err
+			}
+		}
+	}
+	if minutes[0] > 23 || minutes[1] > 59 || minutes[2] > 23 || minutes[3] > 59 {
+		return hours, QuietHoursInvalidError
+	}
+
+	hours.start = minutes[0]*60 + minutes[1]
+	hours.end = minutes[2]*60 + minutes[3]
+	if hours.start == hours.end {
+		return hours, QuietHoursInvalidError
+	}
+	hours.enabled = true
+	return hours, nil
+}
+
+func (hours quietHours) String() string {
+	if !hours.enabled {
+		return "off"
+	}
+	return fmt.Sprintf("%02d:%02d-%02d:%02d", hours.start/60, hours.start%60, hours.end/60, hours.end%60)
+}
+
+// contains returns true if the time of day is within the quiet hours
+func (hours quietHours) contains(t time.Time) bool {
+	if !hours.enabled {
+		return false
+	}
+
+	minute := t.Hour()*60 + t.Minute()
+	if hours.start < hours.end {
+		return minute >= hours.start && minute < hours.end
+	}
+	return minute >= hours.start || minute < hours.end
+}
+
+// allow counts a notification for every key if none of them reached its limit in the current window yet
+func (limiter *rateLimiter) allow(now time.Time, limits map[string]int) bool {
+	limiter.Lock()
+	defer limiter.Unlock()
+
+	for key, limit := range limits {
+		if window, ok := limiter.windows[key]; ok && now.Sub(window.start) < RateLimitWindow && window.count >= limit {
+			return false
+		}
+	}
+
+	for key := range limits {
+		window, ok := limiter.windows[key]
+		if !ok || now.Sub(window.start) >= RateLimitWindow {
+			window = rateLimitWindow{start: now}
+		}
+		window.count++
+		limiter.windows[key] = window
+	}
+
+	// remove passed windows from time to time
+	if now.Sub(limiter.prunedAt) > 10*time.Minute {
+		for key, window := range limiter.windows {
+			if now.Sub(window.start) >= RateLimitWindow {
+				delete(limiter.windows, key)
+			}
+		}
+		limiter.prunedAt = now
+	}
+
+	return true
+}
+
+// getDigestReason returns why a notification has to wait for a digest, or an empty reason if it can be sent now
+func getDigestReason(userID, channelID string, keywords []string) models.NotificationsDigestReason {
+	now := time.Now()
+	settings := getDeliverySettings(userID)
+
+	if settings.quietHours.contains(now.In(settings.location)) {
+		return models.NotificationsDigestReasonQuietHours
+	}
+
+	if settings.digestInterval > 0 {
+		return models.NotificationsDigestReasonDigest
+	}
+
+	limits := map[string]int{"channel:" + userID + ":" + channelID: ChannelRateLimit}
+	for _, keyword := range keywords {
+		limits["keyword:"+userID+":"+strings.ToLower(keyword)] = KeywordRateLimit
+	}
+	if !notificationsRateLimiter.allow(now, limits) {
+		return models.NotificationsDigestReasonRateLimit
+	}
+
+	return ""
+}
+
+// isDigestDue returns true if any of the pending notifications of a user should be sent now
+func (settings deliverySettings) isDigestDue(now time.Time, entries []models.NotificationsDigestEntry) bool {
+	if settings.quietHours.contains(now.In(settings.location)) {
+		return false
+	}
+
+	for _, entry := range entries {
+		switch entry.Reason {
+		case models.NotificationsDigestReasonQuietHours:
+			return true
+		case models.NotificationsDigestReasonRateLimit:
+			if now.Sub(entry.CreatedAt) >= RateLimitWindow {
+				return true
+			}
+		default:
+			if now.Sub(entry.CreatedAt) >= settings.digestInterval {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func newDigestEntry(userID, guildID string, msg *discordgo.Message, keywords []string, contextMessages []*discordgo.Message,
+	messageTime time.Time, reason models.NotificationsDigestReason) models.NotificationsDigestEntry {
+	entry := models.NotificationsDigestEntry{
+		UserID:         userID,
+		GuildID:        guildID,
+		ChannelID:      msg.ChannelID,
+		MessageID:      msg.ID,
+		AuthorUsername: msg.Author.Username + "#" + msg.Author.Discriminator,
+		Keywords:       keywords,
+		Content:        msg.Content,
+		Reason:         reason,
+		CreatedAt:      messageTime,
+	}
+
+	for _, contextMessage := range contextMessages {
+		contextMessageTime, err := contextMessage.Timestamp.Parse()
+		if err != nil {
+			contextMessageTime = messageTime
+		}
+		entry.ContextMessages = append(entry.ContextMessages, models.NotificationsDigestMessage{
+			AuthorUsername: contextMessage.Author.Username + "#" + contextMessage.Author.Discriminator,
+			Content:        contextMessage.Content,
+			CreatedAt:      contextMessageTime,
+		})
+	}
+
+	return entry
+}
+
+// queueDigestEntry saves a notification for the next digest of its user, notifications over the digest limit are dropped
+func queueDigestEntry(entry models.NotificationsDigestEntry) (err error) {
+	count, err := helpers.MdbCountWithoutLogging(models.NotificationsDigestTable, bson.M{"userid": entry.UserID})
+	if err != nil {
+		return err
+	}
+	if count >= DigestMaxEntries {
+		return nil
+	}
+
+	_, err = helpers.MDbInsertWithoutLogging(models.NotificationsDigestTable, entry)
+	if err != nil {
+		return err
+	}
+
+	metrics.KeywordNotificationsDigestedCount.Add(1)
+	return nil
+}
+
+func digestLoop() {
+	defer helpers.Recover()
+	defer func() {
+		go func() {
+			cache.GetLogger().WithField("module", "notifications").Error(
+				"the digestLoop died. Please investigate! Will be restarted in 60 seconds")
+			time.Sleep(60 * time.Second)
+			digestLoop()
+		}()
+	}()
+
+	for {
+		err := sendDueDigests(time.Now())
+		helpers.RelaxLog(err)
+
+		time.Sleep(1 * time.Minute)
+	}
+}
+
+// sendDueDigests sends the pending notifications of every user whose digest is due
+func sendDueDigests(now time.Time) (err error) {
+	var entries []models.NotificationsDigestEntry
+	err = helpers.MDbIterWithoutLogging(
+		helpers.MdbCollection(models.NotificationsDigestTable).Find(nil).Sort("userid", "createdat"),
+	).All(&entries)
+	if err != nil {
+		return err
+	}
+
+	entriesByUser := make(map[string][]models.NotificationsDigestEntry)
+	for _, entry := range entries {
+		entriesByUser[entry.UserID] = append(entriesByUser[entry.UserID], entry)
+	}
+
+	for userID, userEntries := range entriesByUser {
+		settings := getDeliverySettings(userID)
+		if !settings.isDigestDue(now, userEntries) {
+			continue
+		}
+
+		claimID, claimedEntries, err := claimDigestEntries(userEntries, now)
+		if err != nil {
+			helpers.RelaxLog(err)
+			continue
+		}
+		if len(claimedEntries) == 0 {
+			continue
+		}
+
+		// the notifications are removed even if the digest could not be sent, users might not accept DMs
+		err = sendDigest(userID, claimedEntries, settings.location)
+		if err != nil {
+			cache.GetLogger().WithField("module", "notifications").WithField("userID", userID).Warn(
+				"failed to send digest: " + err.Error())
+		}
+
+		_, err = helpers.MdbCollection(models.NotificationsDigestTable).RemoveAll(bson.M{"claimid": claimID})
+		helpers.RelaxLog(err)
+	}
+
+	return nil
+}
+
+// claimDigestEntries claims the notifications no other instance is sending, so every notification is sent in one digest
+// notifications stay claimed for DigestClaimLease, if the digest could not be sent by then they are sent again
+func claimDigestEntries(entries []models.NotificationsDigestEntry, now time.Time) (claimID bson.ObjectId, claimed []models.NotificationsDigestEntry, err error) {
+	ids := make([]bson.ObjectId, 0, len(entries))
+	for _, entry := range entries {
+		ids = append(ids, entry.ID)
+	}
+
+	claimID = bson.NewObjectId()
+	_, err = helpers.MdbCollection(models.NotificationsDigestTable).UpdateAll(
+		bson.M{
+			"_id": bson.M{"$in": ids},
+			"$or": []bson.M{
+				{"claimeduntil": bson.M{"$exists": false}},
+				{"claimeduntil": bson.M{"$lt": now}},
+			},
+		},
+		bson.M{"$set": bson.M{"claimid": claimID, "claimeduntil": now.Add(DigestClaimLease)}},
+	)
+	if err != nil {
+		return claimID, nil, err
+	}
+
+	err = helpers.MDbIterWithoutLogging(
+		helpers.MdbCollection(models.NotificationsDigestTable).Find(bson.M{"claimid": claimID}).Sort("createdat"),
+	).All(&claimed)
+	return claimID, claimed, err
+}
+
+func sendDigest(userID string, entries []models.NotificationsDigestEntry, location *time.Location) (err error) {
+	dmChannel, err := cache.GetSession().UserChannelCreate(userID)
+	if err != nil {
+		return err
+	}
+
+	digestEntries := make([]digestEntryText, 0, len(entries))
+	for _, entry := range entries {
+		guildName := "N/A"
+		guild, err := helpers.GetGuildWithoutApi(entry.GuildID)
+		if err == nil {
+			guildName = guild.Name
+		}
+
+		digestEntry := digestEntryText{
+			header: helpers.GetTextF("plugins.notifications.digest-entry",
+				entry.AuthorUsername,
+				formatKeywords(entry.Keywords),
+				entry.ChannelID,
+				guildName,
+				entry.CreatedAt.In(location).Format("Jan 02 15:04 MST"),
+				helpers.MessageDeeplink(entry.ChannelID, entry.MessageID),
+			),
+		}
+		for _, contextMessage := range entry.ContextMessages {
+			digestEntry.lines = append(digestEntry.lines,
+				contextMessage.AuthorUsername+": "+escapeNotificationContent(contextMessage.Content))
+		}
+		digestEntry.lines = append(digestEntry.lines, "🔔 "+entry.AuthorUsername+": "+escapeNotificationContent(entry.Content))
+
+		digestEntries = append(digestEntries, digestEntry)
+	}
+
+	for _, page := range pagifyDigest(helpers.GetTextF("plugins.notifications.digest-title", len(entries)), digestEntries) {
+		_, err = helpers.SendMessage(dmChannel.ID, page)
+		if err != nil {
+			return err
+		}
+	}
+
+	metrics.KeywordNotificationsSentCount.Add(int64(len(entries)))
+	return nil
+}
+
+// digestEntryText is a notification of a digest, its lines are sent in a code block
+type digestEntryText struct {
+	header string
+	lines  []string
+}
+
+// pagifyDigest splits a digest into messages, code blocks split across messages are closed and opened again
+// lines which do not fit into one message are shortened
+func pagifyDigest(title string, entries []digestEntryText) (pages []string) {
+	const (
+		maxLength  = 1992
+		blockStart = "```" + helpers.ZERO_WIDTH_SPACE
+		blockEnd   = "```\n"
+	)
+	maxLineLength := maxLength - len(blockStart) - len(blockEnd)
+
+	page := title + "\n"
+	flush := func() {
+		if page != "" {
+			pages = append(pages, strings.TrimSuffix(page, "\n"))
+		}
+		page = ""
+	}
+
+	for _, entry := range entries {
+		header := entry.header + "\n"
+		if len(page)+len(header)+len(blockStart)+len(blockEnd) > maxLength {
+			flush()
+		}
+		page += header
+
+		block := ""
+		for _, line := range entry.lines {
+			line = truncateDigestLine(line, maxLineLength)
+			if block != "" {
+				line = "\n" + line
+			}
+
+			if len(page)+len(blockStart)+len(block)+len(line)+len(blockEnd) > maxLength {
+				if block != "" {
+					page += blockStart + block + blockEnd
+				}
+				flush()
+				block = ""
+				line = strings.TrimPrefix(line, "\n")
+			}
+			block += line
+		}
+		page += blockStart + block + blockEnd
+	}
+	flush()
+
+	return pages
+}
+
+// truncateDigestLine shortens a line to at most maxLength bytes without splitting runes
+func truncateDigestLine(line string, maxLength int) string {
+	if len(line) <= maxLength {
+		return line
+	}
+
+	const ellipsis = "…"
+	cut := maxLength - len(ellipsis)
+	for cut > 0 && !utf8.RuneStart(line[cut]) {
+		cut--
+	}
+	return line[:cut] + ellipsis
+}
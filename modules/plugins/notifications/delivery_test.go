@@ -0,0 +1,97 @@
+package notifications
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestQuietHours(t *testing.T) {
+	tests := []struct {
+		text     string
+		valid    bool
+		at       string
+		contains bool
+	}{
+		{"22-7", true, "23:30", true},
+		{"22-7", true, "06:59", true},
+		{"22-7", true, "07:00", false},
+		{"22:30-07:00", true, "22:15", false},
+		{"09:00-17:30", true, "12:00", true},
+		{"09:00-17:30", true, "18:00", false},
+		{"off", true, "12:00", false},
+		{"24-7", false, "", false},
+		{"7-7", false, "", false},
+		{"night", false, "", false},
+	}
+
+	for _, test := range tests {
+		hours, err := parseQuietHours(test.text)
+		if (err == nil) != test.valid {
+			t.Errorf("notifications.parseQuietHours(%q) returned error %v, expected valid %t", test.text, err, test.valid)
+			continue
+		}
+		if !test.valid {
+			continue
+		}
+
+		at, _ := time.Parse("15:04", test.at)
+		if hours.contains(at) != test.contains {
+			t.Errorf("notifications.quietHours(%s).contains(%s) returned %t, expected %t", hours, test.at, !test.contains, test.contains)
+		}
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	limiter := &rateLimiter{windows: make(map[string]rateLimitWindow)}
+	now := time.Now()
+	limits := map[string]int{"channel": 3, "keyword": 2}
+
+	for i, expected := range []bool{true, true, false} {
+		if limiter.allow(now, limits) != expected {
+			t.Errorf("notifications.rateLimiter.allow() call %d returned %t, expected %t", i+1, !expected, expected)
+		}
+	}
+	if !limiter.allow(now, map[string]int{"channel": 3}) {
+		t.Error("notifications.rateLimiter.allow() denied a key below its limit")
+	}
+	if !limiter.allow(now.Add(RateLimitWindow), limits) {
+		t.Error("notifications.rateLimiter.allow() denied after the window passed")
+	}
+}
+
+func TestPagifyDigest(t *testing.T) {
+	longLine := strings.Repeat("a", 3000)
+	manyLines := make([]string, 0, 100)
+	for i := 0; i < 100; i++ {
+		manyLines = append(manyLines, strings.Repeat("b", 50))
+	}
+
+	tests := []struct {
+		name    string
+		entries []digestEntryText
+		pages   int
+	}{
+		{"short", []digestEntryText{{"header", []string{"line"}}}, 1},
+		{"many entries", []digestEntryText{
+			{"header", manyLines[:30]}, {"header", manyLines[:30]}, {"header", manyLines[:30]},
+		}, 3},
+		{"long entry", []digestEntryText{{"header", manyLines}}, 3},
+		{"long line", []digestEntryText{{"header", []string{"line", longLine, "line"}}}, 3},
+	}
+
+	for _, test := range tests {
+		pages := pagifyDigest("title", test.entries)
+		if len(pages) != test.pages {
+			t.Errorf("notifications.pagifyDigest(%s) returned %d pages, expected %d", test.name, len(pages), test.pages)
+		}
+		for i, page := range pages {
+			if len(page) > 1992 {
+				t.Errorf("notifications.pagifyDigest(%s) page %d is %d characters long", test.name, i, len(page))
+			}
+			if strings.Count(page, "```")%2 != 0 {
+				t.Errorf("notifications.pagifyDigest(%s) page %d contains an unclosed code block", test.name, i)
+			}
+		}
+	}
+}
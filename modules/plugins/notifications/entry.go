@@ -30,6 +30,7 @@ func (m *Handler) Commands() []string {
 
 func (m *Handler) Init(session *discordgo.Session) {
 	session.AddHandler(m.OnMessage)
+	go digestLoop()
 	go func() {
 		defer helpers.Recover()
 
@@ -50,7 +51,7 @@ func (m *Handler) Action(command string, content string, msg *discordgo.Message,
 	args := strings.Fields(content)
 	if len(args) > 0 {
 		switch args[0] {
-		case "add": // [p]notifications add <keyword(s)>
+		case "add": // [p]notifications add [global] [phrase, wildcard or regex] <keyword(s)>
 			if len(args) < 2 {
 				helpers.SendMessage(msg.ChannelID, helpers.GetTextF("bot.arguments.too-few"))
 				return
@@ -66,6 +67,24 @@ func (m *Handler) Action(command string, content string, msg *discordgo.Message,
 				keywords = strings.TrimSpace(strings.TrimPrefix(keywords, "global "))
 				keywordGuild = "global"
 			}
+			keywords, keywordType := parseKeywordType(keywords)
+
+			_, _, err = compileKeyword(keywords, keywordType)
+			if err != nil {
+				helpers.SendMessage(msg.ChannelID, helpers.GetTextF("plugins.notifications.keyword-add-invalid", msg.Author.ID, err.Error()))
+				return
+			}
+
+			if keywordType == models.NotificationsKeywordTypeRegex {
+				regexKeywords, err := helpers.MdbCollection(models.NotificationsTable).Find(
+					bson.M{"userid": msg.Author.ID, "type": models.NotificationsKeywordTypeRegex},
+				).Count()
+				helpers.Relax(err)
+				if regexKeywords >= RegexKeywordsPerUserLimit {
+					helpers.SendMessage(msg.ChannelID, helpers.GetTextF("plugins.notifications.keyword-add-invalid", msg.Author.ID, RegexKeywordLimitError.Error()))
+					return
+				}
+			}
 
 			var entryBucket models.NotificationsEntry
 			err = helpers.MdbOne(
@@ -92,6 +111,7 @@ func (m *Handler) Action(command string, content string, msg *discordgo.Message,
 				bson.M{"userid": msg.Author.ID, "guildid": keywordGuild, "keyword": keywords},
 				models.NotificationsEntry{
 					Keyword: keywords,
+					Type:    keywordType,
 					GuildID: keywordGuild,
 					UserID:  msg.Author.ID,
 				},
@@ -158,6 +178,12 @@ func (m *Handler) Action(command string, content string, msg *discordgo.Message,
 		case "ignore":
 			handleIgnore(session, content, msg, args)
 			return
+		case "digest": // [p]notifications digest [<minutes> or off]
+			handleDigest(msg, args)
+			return
+		case "quiet-hours", "quiet": // [p]notifications quiet-hours <start-end or off>
+			handleQuietHours(msg, args)
+			return
 		case "ignore-channel":
 			if len(args) < 2 {
 				helpers.SendMessage(msg.ChannelID, helpers.GetTextF("bot.arguments.too-few"))
@@ -403,167 +429,161 @@ func (m *Handler) OnMessage(session *discordgo.Session, msg *discordgo.MessageCr
 
 	var pendingNotifications []PendingNotification
 
-	textToMatch := strings.ToLower(strings.TrimSpace(msg.Content))
-
 NextKeyword:
-	for _, notificationSetting := range notificationSettingsCache {
-		if notificationSetting.GuildID == guild.ID || notificationSetting.GuildID == "global" {
-			// check if message should be ignored for specific keyword
-			if isIgnored(notificationSetting, msg.Message) {
-				continue NextKeyword
-			}
+	for _, notificationSetting := range notificationKeywordMatcher.match(guild.ID, msg.Content) {
+		// check if message should be ignored for specific keyword
+		if isIgnored(notificationSetting, msg.Message) {
+			continue NextKeyword
+		}
 
-			if keywordMatches(textToMatch, notificationSetting.Keyword) {
-				memberToNotify, err := helpers.GetGuildMemberWithoutApi(guild.ID, notificationSetting.UserID)
+		memberToNotify, err := helpers.GetGuildMemberWithoutApi(guild.ID, notificationSetting.UserID)
+		if err != nil {
+			//cache.GetLogger().WithField("module", "notifications").WithField("channelID", channel.ID).WithField("userID", notificationSetting.UserID).Warn("error getting member to notify: " + err.Error())
+			continue NextKeyword
+		}
+		if memberToNotify == nil {
+			//cache.GetLogger().WithField("module", "notifications").WithField("channelID", channel.ID).WithField("userID", notificationSetting.UserID).Warn("member to notify not found")
+			continue NextKeyword
+		}
+		messageAuthor, err := helpers.GetGuildMemberWithoutApi(guild.ID, msg.Author.ID)
+		if err != nil {
+			messageAuthor = new(discordgo.Member)
+			messageAuthor.User = msg.Author
+		}
+		hasReadPermissions := false
+		hasHistoryPermissions := false
+		// ignore messages if the users roles have no read permission to the server
+		memberAllPermissions := helpers.GetAllPermissions(guild, memberToNotify)
+		if memberAllPermissions&discordgo.PermissionReadMessageHistory == discordgo.PermissionReadMessageHistory {
+			hasHistoryPermissions = true
+			//fmt.Println(msg.Content, ": allowed History: A")
+		}
+		if memberAllPermissions&discordgo.PermissionReadMessages == discordgo.PermissionReadMessages {
+			hasReadPermissions = true
+			//fmt.Println(msg.Content, ": allowed Read: B")
+		}
+		// ignore messages if the users roles have no read permission to the channel
+	NextPermOverwriteEveryone:
+		for _, overwrite := range channel.PermissionOverwrites {
+			if overwrite.Type == "role" {
+				roleToCheck, err := session.State.Role(channel.GuildID, overwrite.ID)
 				if err != nil {
-					//cache.GetLogger().WithField("module", "notifications").WithField("channelID", channel.ID).WithField("userID", notificationSetting.UserID).Warn("error getting member to notify: " + err.Error())
-					continue NextKeyword
+					cache.GetLogger().WithField("module", "notifications").Warn("error getting role: " + err.Error())
+					continue NextPermOverwriteEveryone
 				}
-				if memberToNotify == nil {
-					//cache.GetLogger().WithField("module", "notifications").WithField("channelID", channel.ID).WithField("userID", notificationSetting.UserID).Warn("member to notify not found")
-					continue NextKeyword
+				//fmt.Printf("%s: %#v\n", roleToCheck.Name, overwrite)
+
+				if roleToCheck.Name == "@everyone" {
+					if overwrite.Allow&discordgo.PermissionReadMessageHistory == discordgo.PermissionReadMessageHistory {
+						hasHistoryPermissions = true
+						//fmt.Println(msg.Content, ": allowed History: C")
+					}
+					if overwrite.Allow&discordgo.PermissionReadMessages == discordgo.PermissionReadMessages {
+						hasReadPermissions = true
+						//fmt.Println(msg.Content, ": allowed Read: D")
+					}
+					if overwrite.Deny&discordgo.PermissionReadMessageHistory == discordgo.PermissionReadMessageHistory {
+						hasHistoryPermissions = false
+						//fmt.Println(msg.Content, ": rejected History: E")
+					}
+					if overwrite.Deny&discordgo.PermissionReadMessages == discordgo.PermissionReadMessages {
+						hasReadPermissions = false
+						//fmt.Println(msg.Content, ": rejected Read: F")
+					}
 				}
-				messageAuthor, err := helpers.GetGuildMemberWithoutApi(guild.ID, msg.Author.ID)
+			}
+		}
+	NextPermOverwriteNotEveryone:
+		for _, overwrite := range channel.PermissionOverwrites {
+			if overwrite.Type == "role" {
+				roleToCheck, err := session.State.Role(channel.GuildID, overwrite.ID)
 				if err != nil {
-					messageAuthor = new(discordgo.Member)
-					messageAuthor.User = msg.Author
-				}
-				hasReadPermissions := false
-				hasHistoryPermissions := false
-				// ignore messages if the users roles have no read permission to the server
-				memberAllPermissions := helpers.GetAllPermissions(guild, memberToNotify)
-				if memberAllPermissions&discordgo.PermissionReadMessageHistory == discordgo.PermissionReadMessageHistory {
-					hasHistoryPermissions = true
-					//fmt.Println(msg.Content, ": allowed History: A")
+					cache.GetLogger().WithField("module", "notifications").Warn("error getting role: " + err.Error())
+					continue NextPermOverwriteNotEveryone
 				}
-				if memberAllPermissions&discordgo.PermissionReadMessages == discordgo.PermissionReadMessages {
-					hasReadPermissions = true
-					//fmt.Println(msg.Content, ": allowed Read: B")
-				}
-				// ignore messages if the users roles have no read permission to the channel
-			NextPermOverwriteEveryone:
-				for _, overwrite := range channel.PermissionOverwrites {
-					if overwrite.Type == "role" {
-						roleToCheck, err := session.State.Role(channel.GuildID, overwrite.ID)
-						if err != nil {
-							cache.GetLogger().WithField("module", "notifications").Warn("error getting role: " + err.Error())
-							continue NextPermOverwriteEveryone
-						}
-						//fmt.Printf("%s: %#v\n", roleToCheck.Name, overwrite)
+				//fmt.Printf("%s: %#v\n", roleToCheck.Name, overwrite)
 
-						if roleToCheck.Name == "@everyone" {
+				if roleToCheck.Name != "@everyone" {
+					for _, memberRoleId := range memberToNotify.Roles {
+						if memberRoleId == overwrite.ID {
 							if overwrite.Allow&discordgo.PermissionReadMessageHistory == discordgo.PermissionReadMessageHistory {
 								hasHistoryPermissions = true
-								//fmt.Println(msg.Content, ": allowed History: C")
+								//fmt.Println(msg.Content, ": allowed History: G")
 							}
 							if overwrite.Allow&discordgo.PermissionReadMessages == discordgo.PermissionReadMessages {
 								hasReadPermissions = true
-								//fmt.Println(msg.Content, ": allowed Read: D")
+								//fmt.Println(msg.Content, ": allowed Read: H")
 							}
 							if overwrite.Deny&discordgo.PermissionReadMessageHistory == discordgo.PermissionReadMessageHistory {
 								hasHistoryPermissions = false
-								//fmt.Println(msg.Content, ": rejected History: E")
+								//fmt.Println(msg.Content, ": rejected History: I")
 							}
 							if overwrite.Deny&discordgo.PermissionReadMessages == discordgo.PermissionReadMessages {
 								hasReadPermissions = false
-								//fmt.Println(msg.Content, ": rejected Read: F")
+								//fmt.Println(msg.Content, ": rejected Read: J")
 							}
 						}
 					}
 				}
-			NextPermOverwriteNotEveryone:
-				for _, overwrite := range channel.PermissionOverwrites {
-					if overwrite.Type == "role" {
-						roleToCheck, err := session.State.Role(channel.GuildID, overwrite.ID)
-						if err != nil {
-							cache.GetLogger().WithField("module", "notifications").Warn("error getting role: " + err.Error())
-							continue NextPermOverwriteNotEveryone
-						}
-						//fmt.Printf("%s: %#v\n", roleToCheck.Name, overwrite)
-
-						if roleToCheck.Name != "@everyone" {
-							for _, memberRoleId := range memberToNotify.Roles {
-								if memberRoleId == overwrite.ID {
-									if overwrite.Allow&discordgo.PermissionReadMessageHistory == discordgo.PermissionReadMessageHistory {
-										hasHistoryPermissions = true
-										//fmt.Println(msg.Content, ": allowed History: G")
-									}
-									if overwrite.Allow&discordgo.PermissionReadMessages == discordgo.PermissionReadMessages {
-										hasReadPermissions = true
-										//fmt.Println(msg.Content, ": allowed Read: H")
-									}
-									if overwrite.Deny&discordgo.PermissionReadMessageHistory == discordgo.PermissionReadMessageHistory {
-										hasHistoryPermissions = false
-										//fmt.Println(msg.Content, ": rejected History: I")
-									}
-									if overwrite.Deny&discordgo.PermissionReadMessages == discordgo.PermissionReadMessages {
-										hasReadPermissions = false
-										//fmt.Println(msg.Content, ": rejected Read: J")
-									}
-								}
-							}
-						}
+			}
+		}
+		for _, overwrite := range channel.PermissionOverwrites {
+			if overwrite.Type == "member" {
+				//memberToCheck, err := helpers.GetGuildMember(channel.GuildID, overwrite.ID)
+				//if err == nil {
+				//	fmt.Printf("%s: %#v\n", memberToCheck.User.Username, overwrite)
+				//}
+
+				if memberToNotify.User.ID == overwrite.ID {
+					if overwrite.Allow&discordgo.PermissionReadMessageHistory == discordgo.PermissionReadMessageHistory {
+						hasHistoryPermissions = true
+						//fmt.Println(msg.Content, ": allowed History: K")
 					}
-				}
-				for _, overwrite := range channel.PermissionOverwrites {
-					if overwrite.Type == "member" {
-						//memberToCheck, err := helpers.GetGuildMember(channel.GuildID, overwrite.ID)
-						//if err == nil {
-						//	fmt.Printf("%s: %#v\n", memberToCheck.User.Username, overwrite)
-						//}
-
-						if memberToNotify.User.ID == overwrite.ID {
-							if overwrite.Allow&discordgo.PermissionReadMessageHistory == discordgo.PermissionReadMessageHistory {
-								hasHistoryPermissions = true
-								//fmt.Println(msg.Content, ": allowed History: K")
-							}
-							if overwrite.Allow&discordgo.PermissionReadMessages == discordgo.PermissionReadMessages {
-								hasReadPermissions = true
-								//fmt.Println(msg.Content, ": allowed Read: L")
-							}
-							if overwrite.Deny&discordgo.PermissionReadMessageHistory == discordgo.PermissionReadMessageHistory {
-								hasHistoryPermissions = false
-								//fmt.Println(msg.Content, ": rejected History: M")
-							}
-							if overwrite.Deny&discordgo.PermissionReadMessages == discordgo.PermissionReadMessages {
-								hasReadPermissions = false
-								//fmt.Println(msg.Content, ": rejected Read: N")
-							}
-						}
+					if overwrite.Allow&discordgo.PermissionReadMessages == discordgo.PermissionReadMessages {
+						hasReadPermissions = true
+						//fmt.Println(msg.Content, ": allowed Read: L")
+					}
+					if overwrite.Deny&discordgo.PermissionReadMessageHistory == discordgo.PermissionReadMessageHistory {
+						hasHistoryPermissions = false
+						//fmt.Println(msg.Content, ": rejected History: M")
+					}
+					if overwrite.Deny&discordgo.PermissionReadMessages == discordgo.PermissionReadMessages {
+						hasReadPermissions = false
+						//fmt.Println(msg.Content, ": rejected Read: N")
 					}
 				}
-				if hasReadPermissions == true && hasHistoryPermissions == true {
-					addedToExistingPendingNotifications := false
-					for i, pendingNotification := range pendingNotifications {
-						if pendingNotification.Member.User.ID == memberToNotify.User.ID {
-							addedToExistingPendingNotifications = true
-							alreadyInKeywordList := false
-							for _, keyword := range pendingNotifications[i].Keywords {
-								if keyword == notificationSetting.Keyword {
-									alreadyInKeywordList = true
-								}
-							}
-							if alreadyInKeywordList == false {
-								pendingNotifications[i].Keywords = append(pendingNotification.Keywords, notificationSetting.Keyword)
-							}
+			}
+		}
+		if hasReadPermissions == true && hasHistoryPermissions == true {
+			addedToExistingPendingNotifications := false
+			for i, pendingNotification := range pendingNotifications {
+				if pendingNotification.Member.User.ID == memberToNotify.User.ID {
+					addedToExistingPendingNotifications = true
+					alreadyInKeywordList := false
+					for _, keyword := range pendingNotifications[i].Keywords {
+						if keyword == notificationSetting.Keyword {
+							alreadyInKeywordList = true
 						}
 					}
-					if addedToExistingPendingNotifications == false {
-						pendingNotifications = append(pendingNotifications, PendingNotification{
-							Member:   memberToNotify,
-							Author:   messageAuthor,
-							Keywords: []string{notificationSetting.Keyword},
-						})
+					if alreadyInKeywordList == false {
+						pendingNotifications[i].Keywords = append(pendingNotification.Keywords, notificationSetting.Keyword)
 					}
-					idToIncrease := notificationSetting.ID
-					go func() {
-						defer helpers.Recover()
-
-						err = helpers.MDbUpdateWithoutLogging(models.NotificationsTable, idToIncrease, bson.M{"$inc": bson.M{"triggered": 1}})
-						helpers.RelaxLog(err)
-					}()
 				}
 			}
+			if addedToExistingPendingNotifications == false {
+				pendingNotifications = append(pendingNotifications, PendingNotification{
+					Member:   memberToNotify,
+					Author:   messageAuthor,
+					Keywords: []string{notificationSetting.Keyword},
+				})
+			}
+			idToIncrease := notificationSetting.ID
+			go func() {
+				defer helpers.Recover()
+
+				err = helpers.MDbUpdateWithoutLogging(models.NotificationsTable, idToIncrease, bson.M{"$inc": bson.M{"triggered": 1}})
+				helpers.RelaxLog(err)
+			}()
 		}
 	}
 
@@ -577,28 +597,29 @@ NextKeyword:
 			continue
 		}
 
-		dmChannel, err := session.UserChannelCreate(pendingNotification.Member.User.ID)
-		if err != nil {
+		if pendingNotification.Author == nil {
+			cache.GetLogger().WithField("module", "notifications").WithField("channelID", channel.ID).Warn("notification source member is nil")
 			continue
 		}
-		keywordsTriggeredText := ""
-		for i, keyword := range pendingNotification.Keywords {
-			keywordsTriggeredText += fmt.Sprintf("`%s`", keyword)
-			if i+2 < len(pendingNotification.Keywords) {
-				keywordsTriggeredText += ", "
-			} else if (len(pendingNotification.Keywords) - (i + 1)) > 0 {
-				keywordsTriggeredText += " and "
-			}
+
+		// notifications during quiet hours, for digests, or over the rate limits are sent later
+		reason := getDigestReason(pendingNotification.Member.User.ID, channel.ID, pendingNotification.Keywords)
+		if reason != "" {
+			err = queueDigestEntry(newDigestEntry(
+				pendingNotification.Member.User.ID, guild.ID, msg.Message, pendingNotification.Keywords, contextMessages,
+				messageTime, reason,
+			))
+			helpers.RelaxLog(err)
+			continue
 		}
 
-		if pendingNotification.Author == nil {
-			cache.GetLogger().WithField("module", "notifications").WithField("channelID", channel.ID).Warn("notification source member is nil")
+		dmChannel, err := session.UserChannelCreate(pendingNotification.Member.User.ID)
+		if err != nil {
 			continue
 		}
+		keywordsTriggeredText := formatKeywords(pendingNotification.Keywords)
 
-		escapedContent := strings.Replace(msg.Content, "```", "", -1)
-		escapedContent = strings.Replace(escapedContent, "`", "'", -1)
-		escapedContent = strings.TrimSpace(strings.Trim(escapedContent, "\n"))
+		escapedContent := escapeNotificationContent(msg.Content)
 
 		switch helpers.GetUserConfigInt(pendingNotification.Member.User.ID, UserConfigNotificationsLayoutModeKey, 1) {
 		case 2:
@@ -686,8 +707,3 @@ NextKeyword:
 		metrics.KeywordNotificationsSentCount.Add(1)
 	}
 }
-
-type delimiterCombination struct {
-	Start string
-	End   string
-}
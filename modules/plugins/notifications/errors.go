@@ -3,5 +3,10 @@ package notifications
 import "github.com/pkg/errors"
 
 var (
-	KeywordsNotFoundError = errors.New("keyword(s) not found")
+	KeywordsNotFoundError    = errors.New("keyword(s) not found")
+	KeywordEmptyError        = errors.New("keyword is empty")
+	KeywordTooLongError      = errors.New("keyword is too long")
+	RegexKeywordLimitError   = errors.New("too many regex keywords")
+	WildcardWithoutTextError = errors.New("wildcard keyword has no text besides wildcards")
+	QuietHoursInvalidError   = errors.New("invalid quiet hours")
 )
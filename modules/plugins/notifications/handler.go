@@ -3,6 +3,7 @@ package notifications
 import (
 	"fmt"
 	"regexp"
+	"strconv"
 	"strings"
 
 	"github.com/Seklfreak/Robyul2/helpers"
@@ -32,6 +33,10 @@ func handleList(session *discordgo.Session, msg *discordgo.Message) {
 	for _, entry := range entryBucket {
 		resultMessage += fmt.Sprintf("`%s` (triggered `%d` times)", entry.Keyword, entry.Triggered)
 
+		if entry.Type != models.NotificationsKeywordTypeDefault {
+			resultMessage += fmt.Sprintf(" [%s]", strings.Title(string(entry.Type)))
+		}
+
 		if len(entry.IgnoredGuildIDs) > 0 {
 			resultMessage += " [Ignored in these Guild(s): "
 			for _, ignoredGuildID := range entry.IgnoredGuildIDs {
@@ -196,3 +201,70 @@ func ignoreKeywordInGuildOrChannel(userID, keywords, guildID, channelID string)
 
 	return added, nil
 }
+
+// _noti digest [<minutes> or off]
+func handleDigest(msg *discordgo.Message, args []string) {
+	if len(args) < 2 {
+		settings := getDeliverySettings(msg.Author.ID)
+
+		message := helpers.GetText("plugins.notifications.digest-status-immediate")
+		if settings.digestInterval > 0 {
+			message = helpers.GetTextF("plugins.notifications.digest-status-digest", int(settings.digestInterval.Minutes()))
+		}
+		if settings.quietHours.enabled {
+			message += "\n" + helpers.GetTextF("plugins.notifications.quiet-hours-status", settings.quietHours.String(), settings.location.String())
+		}
+
+		_, err := helpers.SendMessage(msg.ChannelID, message)
+		helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+		return
+	}
+
+	var interval int
+	if args[1] != "off" {
+		var err error
+		interval, err = strconv.Atoi(args[1])
+		if err != nil || interval < DigestMinInterval || interval > DigestMaxInterval {
+			helpers.SendMessage(msg.ChannelID, helpers.GetTextF("plugins.notifications.digest-invalid", DigestMinInterval, DigestMaxInterval))
+			return
+		}
+	}
+
+	err := helpers.SetUserConfigInt(msg.Author.ID, UserConfigNotificationsDigestIntervalKey, interval)
+	helpers.Relax(err)
+
+	message := helpers.GetText("plugins.notifications.digest-disabled")
+	if interval > 0 {
+		message = helpers.GetTextF("plugins.notifications.digest-enabled", interval)
+	}
+	_, err = helpers.SendMessage(msg.ChannelID, message)
+	helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+}
+
+// _noti quiet-hours <start-end or off>
+func handleQuietHours(msg *discordgo.Message, args []string) {
+	if len(args) < 2 {
+		helpers.SendMessage(msg.ChannelID, helpers.GetText("bot.arguments.too-few"))
+		return
+	}
+
+	hours, err := parseQuietHours(args[1])
+	if err != nil {
+		helpers.SendMessage(msg.ChannelID, helpers.GetText("plugins.notifications.quiet-hours-invalid"))
+		return
+	}
+
+	value := ""
+	if hours.enabled {
+		value = hours.String()
+	}
+	err = helpers.SetUserConfigString(msg.Author.ID, UserConfigNotificationsQuietHoursKey, value)
+	helpers.Relax(err)
+
+	message := helpers.GetText("plugins.notifications.quiet-hours-disabled")
+	if hours.enabled {
+		message = helpers.GetTextF("plugins.notifications.quiet-hours-enabled", hours.String(), getUserLocation(msg.Author.ID).String())
+	}
+	_, err = helpers.SendMessage(msg.ChannelID, message)
+	helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+}
@@ -0,0 +1,239 @@
+package notifications
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/Seklfreak/Robyul2/cache"
+	"github.com/Seklfreak/Robyul2/models"
+	"golang.org/x/text/unicode/norm"
+)
+
+// compiledKeyword is a notification keyword prepared for the keyword matcher
+type compiledKeyword struct {
+	entry *models.NotificationsEntry
+	// regex verifies wildcard and regex keywords, nil for default and phrase keywords
+	regex *regexp.Regexp
+}
+
+// keywordMatcher finds all notification keywords in a message in one pass of its automaton
+type keywordMatcher struct {
+	keywords  []compiledKeyword
+	automaton *ahoCorasick
+	// literals contains the keywords for every pattern of the automaton
+	literals [][]int
+	// unanchored contains the regex keywords by guild ID, "global" for global keywords,
+	// they have no literal and are checked for every message in their guild
+	unanchored map[string][]int
+}
+
+// newKeywordMatcher compiles the keywords and builds the automaton, invalid keywords are skipped
+// regex keywords of a user over RegexKeywordsPerUserLimit are skipped as well
+func newKeywordMatcher(entries []*models.NotificationsEntry) *keywordMatcher {
+	matcher := &keywordMatcher{unanchored: make(map[string][]int)}
+
+	var patterns []string
+	patternIndex := make(map[string]int)
+	regexKeywordsPerUser := make(map[string]int)
+	for _, entry := range entries {
+		if entry.Type == models.NotificationsKeywordTypeRegex {
+			if regexKeywordsPerUser[entry.UserID] >= RegexKeywordsPerUserLimit {
+				cache.GetLogger().WithField("module", "notifications").WithField("keywordID", entry.ID.Hex()).Warn(
+					"skipping regex keyword over the limit of user #" + entry.UserID)
+				continue
+			}
+			regexKeywordsPerUser[entry.UserID]++
+		}
+
+		literal, regex, err := compileKeyword(entry.Keyword, entry.Type)
+		if err != nil {
+			cache.GetLogger().WithField("module", "notifications").WithField("keywordID", entry.ID.Hex()).Warn(
+				"skipping invalid keyword: " + err.Error())
+			continue
+		}
+
+		keywordIndex := len(matcher.keywords)
+		matcher.keywords = append(matcher.keywords, compiledKeyword{entry: entry, regex: regex})
+
+		if literal == "" {
+			matcher.unanchored[entry.GuildID] = append(matcher.unanchored[entry.GuildID], keywordIndex)
+			continue
+		}
+
+		pattern, ok := patternIndex[literal]
+		if !ok {
+			pattern = len(patterns)
+			patternIndex[literal] = pattern
+			patterns = append(patterns, literal)
+			matcher.literals = append(matcher.literals, nil)
+		}
+		matcher.literals[pattern] = append(matcher.literals[pattern], keywordIndex)
+	}
+
+	matcher.automaton = newAhoCorasick(patterns)
+	return matcher
+}
+
+// compileKeyword returns the normalized text the automaton looks for, and the regex verifying a match if required
+// regex keywords have no literal
+func compileKeyword(keyword string, keywordType models.NotificationsKeywordType) (literal string, regex *regexp.Regexp, err error) {
+	switch keywordType {
+	case models.NotificationsKeywordTypeRegex:
+		if len(keyword) > RegexKeywordMaxLength {
+			return "", nil, KeywordTooLongError
+		}
+		regex, err = regexp.Compile("(?i)" + keyword)
+		return "", regex, err
+	case models.NotificationsKeywordTypeWildcard:
+		keyword = normalizeText(keyword)
+
+		var expression strings.Builder
+		expression.WriteString(textDelimiterExpression("^"))
+		for _, character := range keyword {
+			switch character {
+			case '*':
+				expression.WriteString(`\S*`)
+			case '?':
+				expression.WriteString(`\S`)
+			default:
+				expression.WriteString(regexp.QuoteMeta(string(character)))
+			}
+		}
+		expression.WriteString(textDelimiterExpression("$"))
+
+		// the longest part without wildcards is the literal
+		for _, part := range strings.FieldsFunc(keyword, func(r rune) bool { return r == '*' || r == '?' }) {
+			if len(part) > len(literal) {
+				literal = part
+			}
+		}
+		if strings.TrimSpace(literal) == "" {
+			return "", nil, WildcardWithoutTextError
+		}
+
+		regex, err = regexp.Compile(expression.String())
+		return literal, regex, err
+	default:
+		literal = normalizeText(keyword)
+		if literal == "" {
+			return "", nil, KeywordEmptyError
+		}
+		return literal, nil, nil
+	}
+}
+
+// match returns the keywords of the guild and the global keywords found in the message content
+func (matcher *keywordMatcher) match(guildID, content string) (entries []*models.NotificationsEntry) {
+	if matcher == nil {
+		return nil
+	}
+
+	text := normalizeText(content)
+	matched := make([]bool, len(matcher.keywords))
+	checked := make([]bool, len(matcher.keywords))
+
+	matcher.automaton.findAll(text, func(pattern, start, end int) {
+		for _, keywordIndex := range matcher.literals[pattern] {
+			if matched[keywordIndex] || checked[keywordIndex] {
+				continue
+			}
+
+			keyword := matcher.keywords[keywordIndex]
+			if keyword.entry.GuildID != guildID && keyword.entry.GuildID != "global" {
+				checked[keywordIndex] = true
+				continue
+			}
+
+			switch {
+			case keyword.regex != nil:
+				// the regex checks the whole message, once is enough
+				checked[keywordIndex] = true
+				matched[keywordIndex] = keyword.regex.MatchString(text)
+			case keyword.entry.Type == models.NotificationsKeywordTypePhrase:
+				matched[keywordIndex] = isWholePhrase(text, start, end)
+			default:
+				matched[keywordIndex] = isDelimited(text, start, end)
+			}
+		}
+	})
+
+	for _, bucket := range []string{guildID, "global"} {
+		for _, keywordIndex := range matcher.unanchored[bucket] {
+			matched[keywordIndex] = matcher.keywords[keywordIndex].regex.MatchString(text)
+		}
+	}
+
+	for keywordIndex := range matched {
+		if matched[keywordIndex] {
+			entries = append(entries, matcher.keywords[keywordIndex].entry)
+		}
+	}
+	return entries
+}
+
+// normalizeText composes the text in NFC, so for example Hangul written in jamo matches Hangul syllables,
+// lower cases it, and collapses all whitespace to single spaces
+func normalizeText(text string) string {
+	return strings.Join(strings.Fields(strings.ToLower(norm.NFC.String(text))), " ")
+}
+
+// textDelimiterExpression returns a regex group matching any text delimiter, or the anchor
+func textDelimiterExpression(anchor string) string {
+	alternatives := []string{anchor}
+	for _, delimiter := range ValidTextDelimiters {
+		alternatives = append(alternatives, regexp.QuoteMeta(delimiter))
+	}
+	return "(?:" + strings.Join(alternatives, "|") + ")"
+}
+
+// isDelimited returns true if the match from start to end is at the start of the text or after a text delimiter,
+// and at the end of the text or before a text delimiter
+func isDelimited(text string, start, end int) bool {
+	if start > 0 {
+		before, _ := utf8.DecodeLastRuneInString(text[:start])
+		if !textDelimiterRunes[before] {
+			return false
+		}
+	}
+	if end < len(text) {
+		after, _ := utf8.DecodeRuneInString(text[end:])
+		if !textDelimiterRunes[after] {
+			return false
+		}
+	}
+	return true
+}
+
+// isWholePhrase returns true if the match from start to end is not part of a longer word
+// words in scripts without spaces, like Hangul with its particles, can be preceded or followed by other letters
+func isWholePhrase(text string, start, end int) bool {
+	if start > 0 {
+		before, _ := utf8.DecodeLastRuneInString(text[:start])
+		first, _ := utf8.DecodeRuneInString(text[start:end])
+		if continuesWord(before, first) {
+			return false
+		}
+	}
+	if end < len(text) {
+		after, _ := utf8.DecodeRuneInString(text[end:])
+		last, _ := utf8.DecodeLastRuneInString(text[start:end])
+		if continuesWord(after, last) {
+			return false
+		}
+	}
+	return true
+}
+
+// continuesWord returns true if the rune next to the edge of a phrase makes the edge part of a longer word
+func continuesWord(next, edge rune) bool {
+	if !isWordRune(next) || !isWordRune(edge) {
+		return false
+	}
+	return !unicode.In(next, unspacedScripts...) && !unicode.In(edge, unspacedScripts...)
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsNumber(r) || unicode.IsMark(r)
+}
@@ -0,0 +1,102 @@
+package notifications
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/Seklfreak/Robyul2/cache"
+	"github.com/Seklfreak/Robyul2/models"
+	"github.com/sirupsen/logrus"
+)
+
+func init() {
+	logger := logrus.New()
+	logger.Out = ioutil.Discard
+	cache.SetLogger(logger)
+}
+
+func TestKeywordMatcher(t *testing.T) {
+	tests := []struct {
+		keyword     string
+		keywordType models.NotificationsKeywordType
+		content     string
+		matched     bool
+	}{
+		{"robyul", models.NotificationsKeywordTypeDefault, "Robyul", true},
+		{"robyul", models.NotificationsKeywordTypeDefault, "hey robyul!", true},
+		{"robyul", models.NotificationsKeywordTypeDefault, "(robyul)", true},
+		{"robyul", models.NotificationsKeywordTypeDefault, "robyuls", false},
+		{"robyul", models.NotificationsKeywordTypeDefault, "arobyul robyul", true},
+		{"red velvet", models.NotificationsKeywordTypeDefault, "I love Red\n Velvet.", true},
+		{"chaeyoung", models.NotificationsKeywordTypePhrase, "chaeyoung's", true},
+		{"chaeyoung", models.NotificationsKeywordTypePhrase, "chaeyoungie", false},
+		{"민지", models.NotificationsKeywordTypePhrase, "민지가 왔어요", true},
+		{"민지", models.NotificationsKeywordTypePhrase, "민지 hi", true},
+		{"jisoo", models.NotificationsKeywordTypePhrase, "jisoo가", true},
+		{"민지", models.NotificationsKeywordTypePhrase, "\u1106\u1175\u11ab\u110c\u1175 hi", true},
+		{"black*", models.NotificationsKeywordTypeWildcard, "go blackpinks!", true},
+		{"black*", models.NotificationsKeywordTypeWildcard, "unblackpink", false},
+		{"b?s", models.NotificationsKeywordTypeWildcard, "I like BTS", true},
+		{`^hello \d+$`, models.NotificationsKeywordTypeRegex, "Hello 123", true},
+		{`^hello \d+$`, models.NotificationsKeywordTypeRegex, "hello world", false},
+	}
+
+	for _, test := range tests {
+		entry := &models.NotificationsEntry{Keyword: test.keyword, Type: test.keywordType, GuildID: "global"}
+		matches := newKeywordMatcher([]*models.NotificationsEntry{entry}).match("1", test.content)
+		if (len(matches) > 0) != test.matched {
+			t.Errorf("notifications.keywordMatcher.match(%q) for %s keyword %q returned %d matches, expected %t",
+				test.content, test.keywordType, test.keyword, len(matches), test.matched)
+		}
+	}
+}
+
+func TestKeywordMatcherAllKeywords(t *testing.T) {
+	entries := []*models.NotificationsEntry{
+		{Keyword: "he", GuildID: "1"},
+		{Keyword: "she", GuildID: "1"},
+		{Keyword: "hers", GuildID: "global"},
+		{Keyword: "his", GuildID: "1"},
+		{Keyword: "she", Type: models.NotificationsKeywordTypePhrase, GuildID: "1"},
+	}
+
+	matches := newKeywordMatcher(entries).match("1", "ushers and she said hers")
+	if len(matches) != 3 || matches[0] != entries[1] || matches[1] != entries[2] || matches[2] != entries[4] {
+		t.Errorf("notifications.keywordMatcher.match() returned %+v, expected she, hers and she", matches)
+	}
+}
+
+func TestKeywordMatcherGuilds(t *testing.T) {
+	entries := []*models.NotificationsEntry{
+		{Keyword: "robyul", GuildID: "1"},
+		{Keyword: "robyul", GuildID: "2"},
+		{Keyword: "^robyul", Type: models.NotificationsKeywordTypeRegex, GuildID: "2"},
+		{Keyword: "robyul$", Type: models.NotificationsKeywordTypeRegex, GuildID: "global"},
+	}
+	matcher := newKeywordMatcher(entries)
+
+	if len(matcher.unanchored["1"]) != 0 || len(matcher.unanchored["2"]) != 1 || len(matcher.unanchored["global"]) != 1 {
+		t.Errorf("notifications.newKeywordMatcher() put the regex keywords into the buckets %+v", matcher.unanchored)
+	}
+
+	matches := matcher.match("1", "robyul")
+	if len(matches) != 2 || matches[0] != entries[0] || matches[1] != entries[3] {
+		t.Errorf("notifications.keywordMatcher.match() returned %+v in guild 1, expected the keyword of guild 1 and the global keyword", matches)
+	}
+}
+
+func TestKeywordMatcherRegexLimit(t *testing.T) {
+	var entries []*models.NotificationsEntry
+	for i := 0; i <= RegexKeywordsPerUserLimit; i++ {
+		entries = append(entries, &models.NotificationsEntry{
+			Keyword: "robyul", Type: models.NotificationsKeywordTypeRegex, GuildID: "global", UserID: "1"})
+	}
+	entries = append(entries, &models.NotificationsEntry{
+		Keyword: "robyul", Type: models.NotificationsKeywordTypeRegex, GuildID: "global", UserID: "2"})
+
+	matches := newKeywordMatcher(entries).match("1", "robyul")
+	if len(matches) != RegexKeywordsPerUserLimit+1 || matches[len(matches)-1].UserID != "2" {
+		t.Errorf("notifications.keywordMatcher.match() returned %d matches, expected %d regex keywords of user 1 and one of user 2",
+			len(matches), RegexKeywordsPerUserLimit)
+	}
+}
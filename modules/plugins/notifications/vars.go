@@ -1,12 +1,17 @@
 package notifications
 
-import "github.com/Seklfreak/Robyul2/models"
+import (
+	"time"
+	"unicode"
+
+	"github.com/Seklfreak/Robyul2/models"
+)
 
 var (
-	notificationSettingsCache []*models.NotificationsEntry
-	ignoredChannelsCache      []models.NotificationsIgnoredChannelsEntry
-	ValidTextDelimiters       = []string{" ", ".", ",", "?", "!", ";", "(", ")", "=", "\"", "'", "`", "´", "_", "~", "+", "-", "/", ":", "*", "\n", "…", "’", "“", "‘"}
-	WhitelistedBotIDs         = []string{
+	notificationKeywordMatcher *keywordMatcher
+	ignoredChannelsCache       []models.NotificationsIgnoredChannelsEntry
+	ValidTextDelimiters        = []string{" ", ".", ",", "?", "!", ";", "(", ")", "=", "\"", "'", "`", "´", "_", "~", "+", "-", "/", ":", "*", "\n", "…", "’", "“", "‘"}
+	WhitelistedBotIDs          = []string{
 		"430101373397368842", // Test Webhook (Sekl)
 
 		"178215222614556673", // Fiscord-IRC (Kakkela)
@@ -19,9 +24,28 @@ var (
 		"308942526570561536", // TrelleIRC (Kakkela, Webhook)
 		"430089364417150976", // TrelleIRC (Kakkela, Webhook)
 	}
-	generatedDelimiterCombinations = getAllDelimiterCombinations()
+	textDelimiterRunes = getTextDelimiterRunes()
+	// scripts that do not separate words with spaces, or attach particles to words
+	unspacedScripts = []*unicode.RangeTable{
+		unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul, unicode.Thai, unicode.Lao, unicode.Khmer, unicode.Myanmar,
+	}
 )
 
 const (
-	UserConfigNotificationsLayoutModeKey = "notifications:layout-mode"
+	UserConfigNotificationsLayoutModeKey     = "notifications:layout-mode"
+	UserConfigNotificationsDigestIntervalKey = "notifications:digest-interval"
+	UserConfigNotificationsQuietHoursKey     = "notifications:quiet-hours"
+	RegexKeywordMaxLength                    = 200
+	// RegexKeywordsPerUserLimit is the maximum of regex keywords of a user, in all guilds
+	RegexKeywordsPerUserLimit = 10
+	// digest intervals are in minutes
+	DigestMinInterval = 5
+	DigestMaxInterval = 1440
+	DigestMaxEntries  = 50
+	// notifications of a digest that could not be sent within the lease are sent again
+	DigestClaimLease = 5 * time.Minute
+	// notifications over the rate limits within the window are sent in a digest after the window
+	KeywordRateLimit = 5
+	ChannelRateLimit = 10
+	RateLimitWindow  = 10 * time.Minute
 )
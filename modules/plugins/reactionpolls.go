@@ -46,6 +46,11 @@ func (rp *ReactionPolls) Uninit(session *discordgo.Session) {
 
 }
 
+// Reloadable is true, Init only reloads the active poll IDs
+func (rp *ReactionPolls) Reloadable() bool {
+	return true
+}
+
 func (rp *ReactionPolls) Action(command string, content string, msg *discordgo.Message, session *discordgo.Session) {
 	if !helpers.ModuleIsAllowed(msg.ChannelID, msg.ID, msg.Author.ID, helpers.ModulePermReactionPolls) {
 		return
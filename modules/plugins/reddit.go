@@ -13,6 +13,7 @@ import (
 	"html"
 
 	"github.com/Seklfreak/Robyul2/cache"
+	"github.com/Seklfreak/Robyul2/feedpoller"
 	"github.com/Seklfreak/Robyul2/helpers"
 	"github.com/Seklfreak/Robyul2/models"
 	"github.com/Seklfreak/Robyul2/version"
@@ -67,119 +68,133 @@ func (r *Reddit) Init(session *discordgo.Session) {
 		return
 	}
 	r.redditLoggedIn = true
-	go r.checkSubredditLoop()
-	r.logger().Info("Started checkSubredditLoop loop (0s)")
+	feedpoller.Start(r, feedpoller.Options{
+		Name:       "reddit",
+		Interval:   time.Minute,
+		Jitter:     0.2,
+		Workers:    5,
+		MaxBackoff: 30 * time.Minute,
+	})
 }
 
-func (r *Reddit) checkSubredditLoop() {
-	defer helpers.Recover()
-	defer func() {
-		go func() {
-			r.logger().Error("The checkSubredditLoop died. Please investigate! Will be restarted in 60 seconds")
-			time.Sleep(60 * time.Second)
-			r.checkSubredditLoop()
-		}()
-	}()
-
+// Targets returns the subreddits of all feeds in channels the bot can see
+func (r *Reddit) Targets() (targets []string, err error) {
 	var entries []models.RedditSubredditEntry
-	var bundledEntries map[string][]models.RedditSubredditEntry
-	var newPost bool
+	err = helpers.MDbIterWithoutLogging(helpers.MdbCollection(models.RedditSubredditsTable).Find(nil)).All(&entries)
+	if err != nil {
+		return nil, err
+	}
 
-	for {
-		err := helpers.MDbIterWithoutLogging(helpers.MdbCollection(models.RedditSubredditsTable).Find(nil)).All(&entries)
-		helpers.Relax(err)
+	added := make(map[string]bool)
+	for _, entry := range entries {
+		if added[entry.SubredditName] {
+			continue
+		}
 
-		bundledEntries = make(map[string][]models.RedditSubredditEntry, 0)
+		channel, err := helpers.GetChannelWithoutApi(entry.ChannelID)
+		if err != nil || channel == nil || channel.ID == "" {
+			continue
+		}
 
-		for _, entry := range entries {
-			channel, err := helpers.GetChannelWithoutApi(entry.ChannelID)
-			if err != nil || channel == nil || channel.ID == "" {
-				//r.logger().Warn(fmt.Sprintf("skipped subreddit r/%s for Channel #%s on Guild #%s: channel not found!",
-				//	entry.SubredditName, entry.ChannelID, entry.GuildID))
-				continue
-			}
+		targets = append(targets, entry.SubredditName)
+		added[entry.SubredditName] = true
+	}
 
-			if _, ok := bundledEntries[entry.SubredditName]; ok {
-				bundledEntries[entry.SubredditName] = append(bundledEntries[entry.SubredditName], entry)
-			} else {
-				bundledEntries[entry.SubredditName] = []models.RedditSubredditEntry{entry}
-			}
+	return targets, nil
+}
+
+// Check posts new submissions of the subreddit to all feeds of it
+func (r *Reddit) Check(subredditName string) (err error) {
+	var entries []models.RedditSubredditEntry
+	err = helpers.MDbIterWithoutLogging(helpers.MdbCollection(models.RedditSubredditsTable).Find(
+		bson.M{"subredditname": subredditName},
+	)).All(&entries)
+	if err != nil {
+		return err
+	}
+
+	newSubmissions, err := redditSession.SubredditSubmissions(subredditName, geddit.NewSubmissions, geddit.ListingOptions{
+		Limit: 30,
+	})
+	if err != nil {
+		if !strings.Contains(err.Error(), "oauth2: token expired and refresh token is not set") {
+			return err
 		}
 
-		r.logger().Infof("checking %d subreddits for %d feeds", len(bundledEntries), len(entries))
+		// login when token expired
+		err = redditSession.LoginAuth(
+			helpers.GetConfig().Path("reddit.username").Data().(string),
+			helpers.GetConfig().Path("reddit.password").Data().(string),
+		)
+		if err != nil {
+			return err
+		}
+		r.logger().Warn("logged in again after token expired")
 
-		for subredditName, entries := range bundledEntries {
-		BundleStart:
-			// r.logger().Info(fmt.Sprintf("checking subreddit r/%s for %d channels", subredditName, len(entries)))
-			newSubmissions, err := redditSession.SubredditSubmissions(subredditName, geddit.NewSubmissions, geddit.ListingOptions{
-				Limit: 30,
-			})
-			if err != nil {
-				if strings.Contains(err.Error(), "oauth2: token expired and refresh token is not set") {
-					// login when token expired
-					err = redditSession.LoginAuth(
-						helpers.GetConfig().Path("reddit.username").Data().(string),
-						helpers.GetConfig().Path("reddit.password").Data().(string),
-					)
-					helpers.Relax(err)
-					r.logger().Warn("logged in again after token expired")
-					goto BundleStart
-				}
-				r.logger().Warnf("updating subreddit r/%s failed: %s", subredditName, err.Error())
-				time.Sleep(2 * time.Second)
+		newSubmissions, err = redditSession.SubredditSubmissions(subredditName, geddit.NewSubmissions, geddit.ListingOptions{
+			Limit: 30,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, entry := range entries {
+		newPost := false
+		hasToBeBefore := time.Now().Add(-(time.Duration(entry.PostDelay) * time.Minute))
+		hasToBeAfter := entry.LastChecked
+
+		for _, submission := range newSubmissions {
+			submissionTime := time.Unix(int64(submission.DateCreated), 0)
+			if !submissionTime.Before(hasToBeBefore) || !submissionTime.After(hasToBeAfter) {
 				continue
 			}
-			for _, entry := range entries {
-				newPost = false
-				hasToBeBefore := time.Now().Add(-(time.Duration(entry.PostDelay) * time.Minute))
-				hasToBeAfter := entry.LastChecked
-
-				for _, submission := range newSubmissions {
-					submissionTime := time.Unix(int64(submission.DateCreated), 0)
-					if !submissionTime.Before(hasToBeBefore) || !submissionTime.After(hasToBeAfter) {
-						continue
-					}
-					newPost = true
-
-					postSubmission := submission
-					postChannelID := entry.ChannelID
-					go func() {
-						defer helpers.Recover()
-
-						r.logger().Info(fmt.Sprintf("posting submission: #%s (%s) on r/%s (%s) to #%s",
-							postSubmission.ID, submissionTime.Format(time.ANSIC), subredditName,
-							RedditBaseUrl+"/r/"+subredditName+"/comments/"+postSubmission.ID+"/", entry.ChannelID))
-
-						err = r.postSubmission(postChannelID, postSubmission, entry.PostDirectLinks)
-						if err != nil {
-							if errD, ok := err.(*discordgo.RESTError); ok && errD.Message != nil {
-								if errD.Message.Code != discordgo.ErrCodeMissingPermissions &&
-									errD.Message.Code != discordgo.ErrCodeUnknownChannel &&
-									errD.Message.Code != discordgo.ErrCodeMissingAccess {
-									helpers.Relax(err)
-								}
-							} else {
-								helpers.Relax(err)
-							}
+			newPost = true
+
+			go func(postEntry models.RedditSubredditEntry, postSubmission *geddit.Submission) {
+				defer helpers.Recover()
+
+				r.logger().Info(fmt.Sprintf("posting submission: #%s (%s) on r/%s (%s) to #%s",
+					postSubmission.ID, submissionTime.Format(time.ANSIC), subredditName,
+					RedditBaseUrl+"/r/"+subredditName+"/comments/"+postSubmission.ID+"/", postEntry.ChannelID))
+
+				err := r.postSubmission(postEntry, postSubmission)
+				if err != nil {
+					if errD, ok := err.(*discordgo.RESTError); ok && errD.Message != nil {
+						if errD.Message.Code != discordgo.ErrCodeMissingPermissions &&
+							errD.Message.Code != discordgo.ErrCodeUnknownChannel &&
+							errD.Message.Code != discordgo.ErrCodeMissingAccess {
+							helpers.Relax(err)
 						}
-					}()
-				}
-				if newPost {
-					entry.LastChecked = hasToBeBefore
-					err = helpers.MDbUpdateWithoutLogging(models.RedditSubredditsTable, entry.ID, entry)
-					helpers.Relax(err)
+					} else {
+						helpers.Relax(err)
+					}
 				}
-			}
-			time.Sleep(2 * time.Second)
+			}(entry, submission)
 		}
-
-		if len(entries) <= 10 {
-			time.Sleep(time.Second * 60)
+		if newPost {
+			entry.LastChecked = hasToBeBefore
+			err = helpers.MDbUpdateWithoutLogging(models.RedditSubredditsTable, entry.ID, entry)
+			if err != nil {
+				return err
+			}
 		}
 	}
+
+	return nil
 }
 
-func (r *Reddit) postSubmission(channelID string, submission *geddit.Submission, postDirectLinks bool) (err error) {
+func (r *Reddit) postSubmission(entry models.RedditSubredditEntry, submission *geddit.Submission) (err error) {
+	post := helpers.FeedPost{
+		Author:  "/u/" + submission.Author,
+		Title:   html.UnescapeString(submission.Title),
+		URL:     RedditBaseUrl + submission.Permalink,
+		Content: html.UnescapeString(submission.Selftext),
+	}
+	if !helpers.FeedPostMatches(entry.FeedOptions, post) {
+		return nil
+	}
+
 	data := &discordgo.MessageSend{}
 
 	data.Content = "<" + RedditBaseUrl + submission.Permalink + ">"
@@ -225,11 +240,13 @@ func (r *Reddit) postSubmission(channelID string, submission *geddit.Submission,
 		strings.HasSuffix(strings.ToLower(submission.URL), ".gif") ||
 		strings.HasSuffix(strings.ToLower(submission.URL), ".png") {
 		data.Embed.Image = &discordgo.MessageEmbedImage{URL: submission.URL}
+		post.MediaURL = submission.URL
 	} else if submission.ThumbnailURL != "" && strings.HasPrefix(submission.ThumbnailURL, "http") {
 		data.Embed.Image = &discordgo.MessageEmbedImage{URL: submission.ThumbnailURL}
+		post.MediaURL = submission.ThumbnailURL
 	}
 
-	if postDirectLinks {
+	if entry.PostDirectLinks {
 		content += textModeTitle + " _" + helpers.GetText("plugins.reddit.embed-footer") + "_\n"
 		content += "<" + RedditBaseUrl + submission.Permalink + "> by `/u/" + submission.Author + "`\n"
 		if textModeSelftext != "" {
@@ -242,7 +259,7 @@ func (r *Reddit) postSubmission(channelID string, submission *geddit.Submission,
 		data.Embed = nil
 	}
 
-	_, err = helpers.SendComplex(channelID, data)
+	_, err = helpers.SendComplex(entry.ChannelID, helpers.FeedMessageSend(entry.FeedOptions, post, data))
 	return err
 }
 
@@ -287,6 +304,7 @@ func (r *Reddit) actionStart(args []string, in *discordgo.Message, out **discord
 	}
 }
 
+// [p]reddit add <subreddit> <#channel> [<post delay in minutes>] [feed options] [direct link mode]
 func (r *Reddit) actionAdd(args []string, in *discordgo.Message, out **discordgo.MessageSend) redditAction {
 	var err error
 	if !helpers.IsMod(in) {
@@ -299,20 +317,26 @@ func (r *Reddit) actionAdd(args []string, in *discordgo.Message, out **discordgo
 		return r.actionFinish
 	}
 
-	var postDelay int
-	if len(args) > 3 {
-		postDelay, err = strconv.Atoi(args[3])
-		if err != nil {
-			postDelay = 0
-		}
-	}
-
 	targetChannel, err := helpers.GetChannelFromMention(in, args[2])
 	if err != nil {
 		*out = r.newMsg("bot.arguments.invalid")
 		return r.actionFinish
 	}
 
+	feedOptions, rest, err := helpers.ParseFeedOptions(targetChannel.GuildID, args[3:], in.Content)
+	if err != nil {
+		*out = r.newMsg("bot.arguments.invalid-feed-options", err.Error())
+		return r.actionFinish
+	}
+
+	var postDelay int
+	if len(rest) > 0 {
+		postDelay, err = strconv.Atoi(rest[0])
+		if err != nil {
+			postDelay = 0
+		}
+	}
+
 	subredditName := strings.TrimLeft(args[1], "/")
 	subredditName = strings.Replace(subredditName, "r/", "", -1)
 
@@ -321,10 +345,12 @@ func (r *Reddit) actionAdd(args []string, in *discordgo.Message, out **discordgo
 		specialText += fmt.Sprintf(" with a %d minutes delay", postDelay)
 	}
 
+	// the embed code could end with the direct link mode
+	flagsText := " " + strings.Join(rest, " ")
 	var linkMode bool
-	if strings.HasSuffix(in.Content, " direct link mode") ||
-		strings.HasSuffix(in.Content, " link mode") ||
-		strings.HasSuffix(in.Content, " links") {
+	if strings.HasSuffix(flagsText, " direct link mode") ||
+		strings.HasSuffix(flagsText, " link mode") ||
+		strings.HasSuffix(flagsText, " links") {
 		linkMode = true
 		specialText += " using direct links"
 	}
@@ -347,6 +373,7 @@ func (r *Reddit) actionAdd(args []string, in *discordgo.Message, out **discordgo
 			AddedAt:         time.Now(),
 			PostDelay:       postDelay,
 			PostDirectLinks: linkMode,
+			FeedOptions:     feedOptions,
 		})
 	helpers.Relax(err)
 
@@ -354,7 +381,7 @@ func (r *Reddit) actionAdd(args []string, in *discordgo.Message, out **discordgo
 		models.EventlogTargetTypeRobyulRedditFeed, in.Author.ID,
 		models.EventlogTypeRobyulRedditFeedAdd, "",
 		nil,
-		[]models.ElasticEventlogOption{
+		append([]models.ElasticEventlogOption{
 			{
 				Key:   "reddit_channelid",
 				Value: targetChannel.ID,
@@ -372,7 +399,7 @@ func (r *Reddit) actionAdd(args []string, in *discordgo.Message, out **discordgo
 				Key:   "reddit_subredditname",
 				Value: subredditData.Name,
 			},
-		}, false)
+		}, helpers.FeedOptionsEventlogOptions("reddit", feedOptions)...), false)
 	helpers.RelaxLog(err)
 
 	// TODO: Post preview post
@@ -401,9 +428,9 @@ func (r *Reddit) actionList(args []string, in *discordgo.Message, out **discordg
 			directLinkModeText = ", direct link mode"
 		}
 
-		subredditListText += fmt.Sprintf("`%s`: Subreddit `r/%s` posting to <#%s> (Delay: %d minutes%s)\n",
+		subredditListText += fmt.Sprintf("`%s`: Subreddit `r/%s` posting to <#%s>%s (Delay: %d minutes%s)\n",
 			helpers.MdbIdToHuman(subredditEntry.ID), subredditEntry.SubredditName, subredditEntry.ChannelID,
-			subredditEntry.PostDelay, directLinkModeText)
+			helpers.FeedOptionsText(channel.GuildID, subredditEntry.FeedOptions), subredditEntry.PostDelay, directLinkModeText)
 	}
 	subredditListText += fmt.Sprintf("Found **%d** Subreddits in total.", len(subredditEntries))
 
@@ -443,7 +470,7 @@ func (r *Reddit) actionRemove(args []string, in *discordgo.Message, out **discor
 		models.EventlogTargetTypeRobyulRedditFeed, in.Author.ID,
 		models.EventlogTypeRobyulRedditFeedRemove, "",
 		nil,
-		[]models.ElasticEventlogOption{
+		append([]models.ElasticEventlogOption{
 			{
 				Key:   "reddit_channelid",
 				Value: subredditEntry.ChannelID,
@@ -461,7 +488,7 @@ func (r *Reddit) actionRemove(args []string, in *discordgo.Message, out **discor
 				Key:   "reddit_subredditname",
 				Value: subredditEntry.SubredditName,
 			},
-		}, false)
+		}, helpers.FeedOptionsEventlogOptions("reddit", subredditEntry.FeedOptions)...), false)
 	helpers.RelaxLog(err)
 
 	*out = r.newMsg("plugins.reddit.remove-subreddit-success", subredditEntry.SubredditName)
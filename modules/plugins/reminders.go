@@ -6,11 +6,11 @@ import (
 
 	"fmt"
 
-	"github.com/Seklfreak/Robyul2/cache"
 	"github.com/Seklfreak/Robyul2/helpers"
 	"github.com/Seklfreak/Robyul2/models"
 	"github.com/bwmarrin/discordgo"
 	"github.com/globalsign/mgo/bson"
+	"github.com/karrick/tparse/v2"
 	"github.com/olebedev/when"
 	"github.com/olebedev/when/rules/common"
 	"github.com/olebedev/when/rules/en"
@@ -33,62 +33,32 @@ func (r *Reminders) Commands() []string {
 	}
 }
 
+func (r *Reminders) CommandDescriptors() []models.CommandDescriptor {
+	return []models.CommandDescriptor{
+		{
+			Name:        "rm",
+			Description: "Reminds you about something. Start with `here` to be reminded in this channel, or with `every` for a recurring reminder.",
+			Usage:       "[here] [every <recurrence>] <time> <message>",
+			Arguments: []models.CommandArgument{
+				{Name: "reminder", Description: "For example `in 2 hours check the oven` or `every monday 9am standup`.", Type: models.CommandArgumentTypeText, Required: true, Variadic: true},
+			},
+			Module: helpers.ModulePermReminders,
+		},
+		{
+			Name:        "rms",
+			Description: "Lists, cancels, snoozes or edits your reminders.",
+			Usage:       "[list|cancel <id>|snooze <id> [duration]|edit <id> <time and/or message>]",
+			Module:      helpers.ModulePermReminders,
+		},
+	}
+}
+
 func (r *Reminders) Init(session *discordgo.Session) {
 	r.parser = when.New(nil)
 	r.parser.Add(en.All...)
 	r.parser.Add(common.All...)
 
-	go func() {
-		defer helpers.Recover()
-
-		for {
-			reminderBucket := make([]models.RemindersEntry, 0)
-			err := helpers.MDbIterWithoutLogging(helpers.MdbCollection(models.RemindersTable).Find(nil)).All(&reminderBucket)
-			if err != nil {
-				helpers.RelaxLog(err)
-				time.Sleep(10 * time.Second)
-				continue
-			}
-
-			for _, reminders := range reminderBucket {
-				changes := false
-
-				// Downward loop for in-loop element removal
-				for idx := len(reminders.Reminders) - 1; idx >= 0; idx-- {
-					reminder := reminders.Reminders[idx]
-
-					if reminder.Timestamp <= time.Now().Unix() {
-						dmChannel, err := session.UserChannelCreate(reminders.UserID)
-						if err == nil {
-							content := ":alarm_clock: You wanted me to remind you about this:\n" + "```" + helpers.ZERO_WIDTH_SPACE + reminder.Message + "```"
-							if reminder.Message == "" {
-								content = ":alarm_clock: You wanted me to remind you about something, but you didn't tell me about what. <:blobthinking:317028940885524490>"
-							}
-
-							helpers.SendMessage(
-								dmChannel.ID,
-								content,
-							)
-						}
-
-						reminders.Reminders = append(reminders.Reminders[:idx], reminders.Reminders[idx+1:]...)
-						changes = true
-					}
-				}
-
-				if changes {
-					err = helpers.MDbUpsertID(
-						models.RemindersTable,
-						reminders.ID,
-						reminders,
-					)
-					helpers.RelaxLog(err)
-				}
-			}
-
-			time.Sleep(5 * time.Second)
-		}
-	}()
+	startReminderScheduler(session)
 
 	// Setup custom reminder messages.
 	//  Could eventually be loaded from a db if we wanted guilds to set up there own. not an important enough plugin to need that atm
@@ -97,8 +67,6 @@ func (r *Reminders) Init(session *discordgo.Session) {
 		"403003926720413699": "Ok I'll remind you at `%s` <:nayoungok:424683077793611777>", // snakeyesz dev
 		"208673735580844032": "Ok I'll remind you at `%s` <:nayoungok:424683077793611777>", // sekl dev
 	}
-
-	cache.GetLogger().WithField("module", "reminders").Info("Started reminder loop (10s)")
 }
 
 func (r *Reminders) Action(command string, content string, msg *discordgo.Message, session *discordgo.Session) {
@@ -106,123 +74,294 @@ func (r *Reminders) Action(command string, content string, msg *discordgo.Messag
 		return
 	}
 
+	content = strings.TrimSpace(content)
+	args := strings.Fields(content)
+
 	switch command {
 	case "rm", "remind", "remindme":
-		session.ChannelTyping(msg.ChannelID)
+		// _rm cancel <id> etc. work as well, as long as the second argument is a reminder ID
+		if len(args) >= 2 && isReminderSubcommand(args[0]) && bson.IsObjectIdHex(args[1]) {
+			r.actionManage(args, content, msg, session)
+			return
+		}
 
-		channel, err := helpers.GetChannel(msg.ChannelID)
-		helpers.Relax(err)
+		r.actionAdd(args, content, msg, session)
+		break
+
+	case "rms", "reminders":
+		if len(args) < 1 {
+			r.actionList(msg, session)
+			return
+		}
+
+		r.actionManage(args, content, msg, session)
+		break
+	}
+}
+
+func isReminderSubcommand(arg string) bool {
+	switch strings.ToLower(arg) {
+	case "list", "cancel", "delete", "remove", "snooze", "edit":
+		return true
+	}
+	return false
+}
+
+func (r *Reminders) actionAdd(args []string, content string, msg *discordgo.Message, session *discordgo.Session) {
+	session.ChannelTyping(msg.ChannelID)
+
+	channel, err := helpers.GetChannel(msg.ChannelID)
+	helpers.Relax(err)
 
-		parts := strings.Fields(content)
+	delivery := models.ReminderDeliveryDM
+	if len(args) > 0 && strings.ToLower(args[0]) == "here" && channel.GuildID != "" {
+		delivery = models.ReminderDeliveryChannel
+		content = strings.TrimSpace(content[len(args[0]):])
+		args = args[1:]
+	}
+
+	if len(args) < 3 {
+		helpers.SendMessage(msg.ChannelID, ":x: Please check if the format is correct")
+		return
+	}
+
+	userLocation := getUserReminderLocation(msg.Author.ID)
+	now := time.Now().In(userLocation)
+
+	reminder := models.ScheduledReminderEntry{
+		UserID:    msg.Author.ID,
+		ChannelID: channel.ID,
+		GuildID:   channel.GuildID,
+		Delivery:  delivery,
+		Timezone:  userLocation.String(),
+		CreatedAt: time.Now(),
+	}
 
-		if len(parts) < 3 {
+	if strings.ToLower(args[0]) == "every" {
+		recurrence, rest, ok := parseReminderRecurrence(content[len(args[0]):])
+		if !ok {
 			helpers.SendMessage(msg.ChannelID, ":x: Please check if the format is correct")
 			return
 		}
 
-		r, err := r.parser.Parse(content, time.Now())
-		helpers.Relax(err)
-		if r == nil {
+		interval, err := tparse.AddDuration(now, recurrence)
+		if err != nil {
 			helpers.SendMessage(msg.ChannelID, ":x: Please check if the format is correct")
 			return
 		}
+		if interval.Sub(now) < remindersMinRecurrence {
+			helpers.SendMessage(msg.ChannelID, helpers.GetTextF("plugins.reminders.recurrence-too-short", remindersMinRecurrence.String()))
+			return
+		}
 
-		reminders := getReminders(msg.Author.ID)
-		reminders.Reminders = append(reminders.Reminders, models.RemindersReminderEntry{
-			Message:   strings.Replace(content, r.Text, "", 1),
-			ChannelID: channel.ID,
-			GuildID:   channel.GuildID,
-			Timestamp: r.Time.Unix(),
-		})
+		first := interval
+		result, err := r.parser.Parse(rest, now)
+		helpers.Relax(err)
+		if result != nil {
+			first = result.Time.Truncate(time.Minute)
+			rest = strings.Replace(rest, result.Text, "", 1)
+		}
 
-		err = helpers.MDbUpsertID(
-			models.RemindersTable,
-			reminders.ID,
-			reminders,
-		)
+		reminder.Message = strings.TrimSpace(rest)
+		reminder.Recurrence = recurrence
+		reminder.ScheduledAt = first
+		reminder.ScheduledAt, err = getNextReminderOccurrence(reminder, now)
 		helpers.Relax(err)
+		reminder.DueAt = reminder.ScheduledAt
+	} else {
+		result, err := r.parser.Parse(content, now)
+		helpers.Relax(err)
+		if result == nil {
+			helpers.SendMessage(msg.ChannelID, ":x: Please check if the format is correct")
+			return
+		}
+
+		reminder.Message = strings.TrimSpace(strings.Replace(content, result.Text, "", 1))
+		reminder.ScheduledAt = result.Time
+		reminder.DueAt = result.Time
+	}
+
+	_, err = helpers.MDbInsert(models.ScheduledRemindersTable, reminder)
+	helpers.Relax(err)
+	wakeupReminderScheduler()
+
+	dueAt := reminder.DueAt.In(userLocation).Format(time.UnixDate)
+
+	if reminder.Recurrence != "" {
+		helpers.SendMessage(msg.ChannelID, helpers.GetTextF("plugins.reminders.added-recurring", reminder.Recurrence, dueAt))
+		return
+	}
+
+	// Check if guild has a custom message set
+	if customMsg, ok := customReminderMsgMap[channel.GuildID]; ok {
+		helpers.SendMessage(msg.ChannelID, fmt.Sprintf(customMsg, dueAt))
+	} else {
+		helpers.SendMessage(msg.ChannelID, "Ok I'll remind you at `"+dueAt+" ` <:blobokhand:317032017164238848>")
+	}
+}
 
-		var userLocation *time.Location
-		userData, err := helpers.GetUserUserdata(msg.Author.ID)
-		if err == nil {
-			userLocation, _ = time.LoadLocation(userData.Timezone)
+func (r *Reminders) actionList(msg *discordgo.Message, session *discordgo.Session) {
+	session.ChannelTyping(msg.ChannelID)
+
+	var reminders []models.ScheduledReminderEntry
+	err := helpers.MDbIter(helpers.MdbCollection(models.ScheduledRemindersTable).Find(bson.M{
+		"userid":    msg.Author.ID,
+		"delivered": false,
+	}).Sort("dueat")).All(&reminders)
+	helpers.Relax(err)
+
+	if len(reminders) == 0 {
+		helpers.SendMessage(msg.ChannelID, helpers.GetText("plugins.reminders.empty"))
+		return
+	}
+
+	userLocation := getUserReminderLocation(msg.Author.ID)
+
+	var embedFields []*discordgo.MessageEmbedField
+	for i, reminder := range reminders {
+		if i >= remindersMaxListEntries {
+			break
+		}
+
+		value := reminder.Message
+		if value == "" {
+			value = helpers.GetText("plugins.reminders.list-no-message")
+		}
+		if reminder.Recurrence != "" {
+			value += "\n" + helpers.GetTextF("plugins.reminders.list-recurring", reminder.Recurrence)
 		}
-		if userLocation == nil {
-			userLocation, _ = time.LoadLocation("UTC")
+		if reminder.Delivery == models.ReminderDeliveryChannel {
+			value += "\n" + helpers.GetTextF("plugins.reminders.list-channel", reminder.ChannelID)
 		}
 
-		// Check if guild has a custom message set
-		if customMsg, ok := customReminderMsgMap[channel.GuildID]; ok {
-			helpers.SendMessage(msg.ChannelID, fmt.Sprintf(customMsg, r.Time.In(userLocation).Format(time.UnixDate)))
-		} else {
-			helpers.SendMessage(msg.ChannelID, "Ok I'll remind you at `"+r.Time.In(userLocation).Format(time.UnixDate)+" ` <:blobokhand:317032017164238848>")
+		embedFields = append(embedFields, &discordgo.MessageEmbedField{
+			Inline: false,
+			Name:   "`" + helpers.MdbIdToHuman(reminder.ID) + "` at " + reminder.DueAt.In(userLocation).Format(time.UnixDate),
+			Value:  value,
+		})
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title:  "Pending reminders",
+		Fields: embedFields,
+		Color:  0x0FADED,
+	}
+	if len(reminders) > remindersMaxListEntries {
+		embed.Footer = &discordgo.MessageEmbedFooter{
+			Text: helpers.GetTextF("plugins.reminders.list-more", len(reminders)-remindersMaxListEntries),
 		}
+	}
+
+	helpers.SendEmbed(msg.ChannelID, embed)
+}
+
+func (r *Reminders) actionManage(args []string, content string, msg *discordgo.Message, session *discordgo.Session) {
+	switch strings.ToLower(args[0]) {
+	case "list":
+		r.actionList(msg, session)
+		return
+	case "cancel", "delete", "remove", "snooze", "edit":
 		break
+	default:
+		helpers.SendMessage(msg.ChannelID, helpers.GetText("bot.arguments.invalid"))
+		return
+	}
+
+	if len(args) < 2 {
+		helpers.SendMessage(msg.ChannelID, helpers.GetText("bot.arguments.too-few"))
+		return
+	}
 
-	case "rms", "reminders": // TODO: better interface
-		session.ChannelTyping(msg.ChannelID)
+	session.ChannelTyping(msg.ChannelID)
 
-		reminders := getReminders(msg.Author.ID)
-		var embedFields []*discordgo.MessageEmbedField
+	reminder, err := getReminder(msg.Author.ID, args[1])
+	if helpers.IsMdbNotFound(err) {
+		helpers.SendMessage(msg.ChannelID, helpers.GetText("plugins.reminders.not-found"))
+		return
+	}
+	helpers.Relax(err)
 
-		var userLocation *time.Location
-		userData, err := helpers.GetUserUserdata(msg.Author.ID)
-		if err == nil {
-			userLocation, _ = time.LoadLocation(userData.Timezone)
-		}
-		if userLocation == nil {
-			userLocation, _ = time.LoadLocation("UTC")
+	reminderID := helpers.MdbIdToHuman(reminder.ID)
+	userLocation := getUserReminderLocation(msg.Author.ID)
+	now := time.Now().In(userLocation)
+
+	switch strings.ToLower(args[0]) {
+	case "cancel", "delete", "remove":
+		err = helpers.MDbDelete(models.ScheduledRemindersTable, reminder.ID)
+		helpers.Relax(err)
+
+		helpers.SendMessage(msg.ChannelID, helpers.GetTextF("plugins.reminders.cancelled", reminderID))
+		return
+
+	case "snooze":
+		duration := remindersDefaultSnooze
+		if len(args) >= 3 {
+			duration = args[2]
 		}
 
-		for _, reminder := range reminders.Reminders {
-			ts := time.Unix(reminder.Timestamp, 0)
+		until, err := tparse.AddDuration(now, duration)
+		if err != nil || !until.After(now) {
+			helpers.SendMessage(msg.ChannelID, helpers.GetText("plugins.reminders.invalid-duration"))
+			return
+		}
 
-			embedFields = append(embedFields, &discordgo.MessageEmbedField{
-				Inline: false,
-				Name:   "At " + ts.In(userLocation).Format(time.UnixDate),
-				Value:  reminder.Message,
-			})
+		// recurring reminders keep their schedule, only the upcoming delivery is moved
+		update := bson.M{"dueat": until, "delivered": false, "attempts": 0}
+		if reminder.Recurrence == "" {
+			update["scheduledat"] = until
 		}
+		err = helpers.MDbUpdate(models.ScheduledRemindersTable, reminder.ID, bson.M{"$set": update})
+		helpers.Relax(err)
+		wakeupReminderScheduler()
 
-		if len(embedFields) == 0 {
-			helpers.SendMessage(msg.ChannelID, helpers.GetText("plugins.reminders.empty"))
+		helpers.SendMessage(msg.ChannelID, helpers.GetTextF("plugins.reminders.snoozed", reminderID, until.Format(time.UnixDate)))
+		return
+
+	case "edit":
+		text := strings.TrimSpace(content[strings.Index(content, args[1])+len(args[1]):])
+		if text == "" {
+			helpers.SendMessage(msg.ChannelID, helpers.GetText("bot.arguments.too-few"))
 			return
 		}
 
-		helpers.SendEmbed(msg.ChannelID, &discordgo.MessageEmbed{
-			Title:  "Pending reminders",
-			Fields: embedFields,
-			Color:  0x0FADED,
-		})
-		break
-	}
-}
+		update := bson.M{"message": text}
 
-func getReminders(userID string) (reminder models.RemindersEntry) {
-	err := helpers.MdbOne(
-		helpers.MdbCollection(models.RemindersTable).Find(bson.M{"userid": userID}),
-		&reminder,
-	)
+		result, err := r.parser.Parse(text, now)
+		helpers.Relax(err)
+		if result != nil {
+			update["message"] = strings.TrimSpace(strings.Replace(text, result.Text, "", 1))
+
+			reminder.ScheduledAt = result.Time
+			if reminder.Recurrence != "" {
+				reminder.ScheduledAt = reminder.ScheduledAt.Truncate(time.Minute)
+				reminder.ScheduledAt, err = getNextReminderOccurrence(reminder, now)
+				helpers.Relax(err)
+			} else if !reminder.ScheduledAt.After(now) {
+				helpers.SendMessage(msg.ChannelID, ":x: Please check if the format is correct")
+				return
+			}
+			reminder.DueAt = reminder.ScheduledAt
 
-	// If user has no DB entries create an empty document
-	if helpers.IsMdbNotFound(err) {
-		err = helpers.MDbUpsert(
-			models.RemindersTable,
-			bson.M{"userid": userID},
-			models.RemindersEntry{
-				UserID:    userID,
-				Reminders: make([]models.RemindersReminderEntry, 0),
-			},
-		)
-		// If the creation was successful read the document
-		if err != nil {
-			panic(err)
-		} else {
-			return getReminders(userID)
+			update["scheduledat"] = reminder.ScheduledAt
+			update["dueat"] = reminder.DueAt
+			update["delivered"] = false
+			update["attempts"] = 0
 		}
-	} else if err != nil {
-		panic(err)
+
+		err = helpers.MDbUpdate(models.ScheduledRemindersTable, reminder.ID, bson.M{"$set": update})
+		helpers.Relax(err)
+		wakeupReminderScheduler()
+
+		helpers.SendMessage(msg.ChannelID, helpers.GetTextF("plugins.reminders.edited",
+			reminderID, reminder.DueAt.In(userLocation).Format(time.UnixDate)))
+		return
 	}
+}
 
-	return reminder
+func getReminder(userID, reminderID string) (reminder models.ScheduledReminderEntry, err error) {
+	err = helpers.MdbOne(
+		helpers.MdbCollection(models.ScheduledRemindersTable).Find(bson.M{"_id": helpers.HumanToMdbId(reminderID), "userid": userID}),
+		&reminder,
+	)
+	return reminder, err
 }
@@ -0,0 +1,437 @@
+package plugins
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Seklfreak/Robyul2/cache"
+	"github.com/Seklfreak/Robyul2/helpers"
+	"github.com/Seklfreak/Robyul2/models"
+	"github.com/bwmarrin/discordgo"
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+)
+
+const (
+	remindersMaxSleep       = time.Minute
+	remindersMinSleep       = time.Second
+	remindersBatchSize      = 100
+	remindersRetryDelay     = time.Minute
+	remindersClaimLease     = 5 * time.Minute
+	remindersMaxAttempts    = 3
+	remindersKeepDelivered  = 24 * time.Hour
+	remindersCleanupEvery   = time.Hour
+	remindersMinRecurrence  = 10 * time.Minute
+	remindersDefaultSnooze  = "10m"
+	remindersMaxListEntries = 25
+)
+
+var (
+	remindersSchedulerOnce sync.Once
+	remindersWakeup        = make(chan bool, 1)
+	remindersLastCleanup   time.Time
+
+	reminderRecurrenceUnits = map[string]string{
+		"minute": "m",
+		"hour":   "h",
+		"day":    "d",
+		"week":   "w",
+		"month":  "mo",
+		"year":   "y",
+	}
+	reminderRecurrenceDurationRegex = regexp.MustCompile(`^([0-9]+)(m|h|d|w|mo|y)$`)
+)
+
+// startReminderScheduler starts the scheduler once, reloading the plugin keeps the running scheduler
+func startReminderScheduler(session *discordgo.Session) {
+	remindersSchedulerOnce.Do(func() {
+		go func() {
+			defer helpers.Recover()
+
+			prepareScheduledReminders()
+			runReminderScheduler(session)
+		}()
+
+		cache.GetLogger().WithField("module", "reminders").Info("Started reminder scheduler")
+	})
+}
+
+// wakeupReminderScheduler makes the scheduler look for due reminders right away, for example after a reminder has been added
+func wakeupReminderScheduler() {
+	select {
+	case remindersWakeup <- true:
+	default:
+	}
+}
+
+// prepareScheduledReminders creates the indexes for the due time query and moves legacy reminders to the new collection
+func prepareScheduledReminders() {
+	collection := helpers.MdbCollection(models.ScheduledRemindersTable)
+	helpers.RelaxLog(collection.EnsureIndex(mgo.Index{Key: []string{"delivered", "dueat"}}))
+	helpers.RelaxLog(collection.EnsureIndex(mgo.Index{Key: []string{"claimeduntil"}, Sparse: true}))
+	helpers.RelaxLog(collection.EnsureIndex(mgo.Index{Key: []string{"userid", "delivered"}}))
+	helpers.RelaxLog(collection.EnsureIndex(mgo.Index{Key: []string{"legacyid"}, Sparse: true}))
+
+	var legacyEntries []models.RemindersEntry
+	err := helpers.MDbIterWithoutLogging(helpers.MdbCollection(models.RemindersTable).Find(nil)).All(&legacyEntries)
+	if err != nil {
+		helpers.RelaxLog(err)
+		return
+	}
+
+	var migrated int
+	for _, legacyEntry := range legacyEntries {
+		for i, legacyReminder := range legacyEntry.Reminders {
+			dueAt := time.Unix(legacyReminder.Timestamp, 0)
+
+			// a rerun after a failed delete of the legacy entry does not add the reminder again
+			legacyID := helpers.MdbIdToHuman(legacyEntry.ID) + "-" + strconv.Itoa(i)
+			_, err = helpers.MdbCollection(models.ScheduledRemindersTable).Upsert(
+				bson.M{"legacyid": legacyID},
+				bson.M{"$setOnInsert": models.ScheduledReminderEntry{
+					UserID:      legacyEntry.UserID,
+					ChannelID:   legacyReminder.ChannelID,
+					GuildID:     legacyReminder.GuildID,
+					Message:     legacyReminder.Message,
+					Delivery:    models.ReminderDeliveryDM,
+					DueAt:       dueAt,
+					ScheduledAt: dueAt,
+					Timezone:    "UTC",
+					CreatedAt:   time.Now(),
+					LegacyID:    legacyID,
+				}},
+			)
+			if err != nil {
+				helpers.RelaxLog(err)
+				return
+			}
+			migrated++
+		}
+
+		err = helpers.MDbDeleteWithoutLogging(models.RemindersTable, legacyEntry.ID)
+		if err != nil {
+			helpers.RelaxLog(err)
+			return
+		}
+	}
+
+	if migrated > 0 {
+		cache.GetLogger().WithField("module", "reminders").Infof("moved %d legacy reminders", migrated)
+	}
+}
+
+func runReminderScheduler(session *discordgo.Session) {
+	for {
+		// keep delivering while there are full batches of due reminders
+		for deliverDueReminders(session) >= remindersBatchSize {
+			continue
+		}
+		cleanupDeliveredReminders()
+
+		select {
+		case <-time.After(getReminderSchedulerSleep()):
+		case <-remindersWakeup:
+		}
+	}
+}
+
+// getReminderSchedulerSleep returns the time until the next reminder is due
+func getReminderSchedulerSleep() time.Duration {
+	var nextReminder models.ScheduledReminderEntry
+	err := helpers.MdbOneWithoutLogging(
+		helpers.MdbCollection(models.ScheduledRemindersTable).Find(bson.M{
+			"delivered": false,
+			"$or":       getUnclaimedReminderQuery(time.Now()),
+		}).Sort("dueat"),
+		&nextReminder,
+	)
+	if err != nil {
+		if !helpers.IsMdbNotFound(err) {
+			helpers.RelaxLog(err)
+		}
+		return remindersMaxSleep
+	}
+
+	sleep := time.Until(nextReminder.DueAt)
+	if sleep < remindersMinSleep {
+		return remindersMinSleep
+	}
+	if sleep > remindersMaxSleep {
+		return remindersMaxSleep
+	}
+	return sleep
+}
+
+// deliverDueReminders delivers the next batch of due reminders, returns the size of the batch
+func deliverDueReminders(session *discordgo.Session) (batchSize int) {
+	defer helpers.Recover()
+
+	now := time.Now()
+
+	var dueReminders []models.ScheduledReminderEntry
+	err := helpers.MDbIterWithoutLogging(helpers.MdbCollection(models.ScheduledRemindersTable).Find(bson.M{
+		"delivered": false,
+		"dueat":     bson.M{"$lte": now},
+		"$or":       getUnclaimedReminderQuery(now),
+	}).Sort("dueat").Limit(remindersBatchSize)).All(&dueReminders)
+	if err != nil {
+		helpers.RelaxLog(err)
+		return 0
+	}
+
+	for _, reminder := range dueReminders {
+		claimedUntil, ok := claimReminder(&reminder)
+		if !ok {
+			continue
+		}
+
+		err = deliverReminder(session, reminder)
+		if err == nil {
+			helpers.RelaxLog(completeReminder(reminder, claimedUntil))
+			continue
+		}
+
+		if reminder.Attempts < remindersMaxAttempts {
+			helpers.RelaxLog(releaseReminder(reminder, claimedUntil))
+			continue
+		}
+		cache.GetLogger().WithField("module", "reminders").Warnf(
+			"giving up on reminder %s for user %s after %d attempts: %s",
+			helpers.MdbIdToHuman(reminder.ID), reminder.UserID, reminder.Attempts, err.Error(),
+		)
+		helpers.RelaxLog(completeReminder(reminder, claimedUntil))
+	}
+
+	return len(dueReminders)
+}
+
+// getUnclaimedReminderQuery matches reminders no scheduler is delivering, including reminders whose claim expired
+// because the bot stopped while sending them
+func getUnclaimedReminderQuery(now time.Time) []bson.M {
+	return []bson.M{
+		{"claimeduntil": bson.M{"$exists": false}},
+		{"claimeduntil": bson.M{"$lt": now}},
+	}
+}
+
+// claimReminder claims a reminder for remindersClaimLease before it is sent
+// the update only matches if the reminder has not been changed since it was read, so only one scheduler sends it
+// if the bot stops while sending it the claim expires and the reminder is sent again
+// returns the end of the claim, it identifies the claim for releaseReminder and completeReminder
+func claimReminder(reminder *models.ScheduledReminderEntry) (claimedUntil time.Time, ok bool) {
+	now := time.Now()
+	claimedUntil = now.Add(remindersClaimLease).Truncate(time.Millisecond)
+
+	err := helpers.MDbUpdateQueryWithoutLogging(
+		models.ScheduledRemindersTable,
+		bson.M{
+			"_id":       reminder.ID,
+			"delivered": false,
+			"dueat":     reminder.DueAt,
+			"$or":       getUnclaimedReminderQuery(now),
+		},
+		bson.M{
+			"$set": bson.M{"claimeduntil": claimedUntil},
+			"$inc": bson.M{"attempts": 1},
+		},
+	)
+	if err != nil {
+		if !helpers.IsMdbNotFound(err) {
+			helpers.RelaxLog(err)
+		}
+		return claimedUntil, false
+	}
+
+	reminder.Attempts++
+	return claimedUntil, true
+}
+
+// releaseReminder makes a reminder due again after its delivery failed, if it has not been changed since it was claimed
+func releaseReminder(reminder models.ScheduledReminderEntry, claimedUntil time.Time) (err error) {
+	err = helpers.MDbUpdateQueryWithoutLogging(
+		models.ScheduledRemindersTable,
+		bson.M{"_id": reminder.ID, "dueat": reminder.DueAt, "claimeduntil": claimedUntil},
+		bson.M{
+			"$set":   bson.M{"dueat": time.Now().Add(remindersRetryDelay)},
+			"$unset": bson.M{"claimeduntil": 1},
+		},
+	)
+	if helpers.IsMdbNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// completeReminder marks a reminder as delivered, or schedules the next occurrence of a recurring reminder,
+// if it has not been changed since it was claimed
+func completeReminder(reminder models.ScheduledReminderEntry, claimedUntil time.Time) (err error) {
+	deliveredAt := time.Now()
+
+	update := bson.M{
+		"delivered":   true,
+		"deliveredat": deliveredAt,
+		"attempts":    0,
+	}
+	if reminder.Recurrence != "" {
+		next, err := getNextReminderOccurrence(reminder, deliveredAt)
+		if err == nil {
+			update = bson.M{
+				"dueat":       next,
+				"scheduledat": next,
+				"deliveredat": deliveredAt,
+				"attempts":    0,
+			}
+		} else {
+			// an invalid recurrence is delivered once
+			helpers.RelaxLog(err)
+		}
+	}
+
+	err = helpers.MDbUpdateQueryWithoutLogging(
+		models.ScheduledRemindersTable,
+		bson.M{"_id": reminder.ID, "dueat": reminder.DueAt, "claimeduntil": claimedUntil},
+		bson.M{
+			"$set":   update,
+			"$unset": bson.M{"claimeduntil": 1},
+		},
+	)
+	if helpers.IsMdbNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// deliverReminder sends a reminder to its channel or to the user, reminders for channels fall back to DMs
+func deliverReminder(session *discordgo.Session, reminder models.ScheduledReminderEntry) (err error) {
+	content := ":alarm_clock: You wanted me to remind you about this:\n" + "```" + helpers.ZERO_WIDTH_SPACE + reminder.Message + "```"
+	if reminder.Message == "" {
+		content = ":alarm_clock: You wanted me to remind you about something, but you didn't tell me about what. <:blobthinking:317028940885524490>"
+	}
+	reminderID := helpers.MdbIdToHuman(reminder.ID)
+	content += helpers.GetTextF("plugins.reminders.delivery-footer",
+		reminderID, helpers.GetPrefixForServer(reminder.GuildID), reminderID)
+
+	if reminder.Delivery == models.ReminderDeliveryChannel {
+		_, err = helpers.SendMessage(reminder.ChannelID, "<@"+reminder.UserID+"> "+content)
+		if err == nil {
+			return nil
+		}
+	}
+
+	dmChannel, err := session.UserChannelCreate(reminder.UserID)
+	if err != nil {
+		return err
+	}
+
+	_, err = helpers.SendMessage(dmChannel.ID, content)
+	return err
+}
+
+// cleanupDeliveredReminders removes delivered reminders once they can not be snoozed anymore
+func cleanupDeliveredReminders() {
+	if time.Since(remindersLastCleanup) < remindersCleanupEvery {
+		return
+	}
+	remindersLastCleanup = time.Now()
+
+	_, err := helpers.MdbCollection(models.ScheduledRemindersTable).RemoveAll(bson.M{
+		"delivered":   true,
+		"deliveredat": bson.M{"$lt": time.Now().Add(-remindersKeepDelivered)},
+	})
+	helpers.RelaxLog(err)
+}
+
+// getNextReminderOccurrence returns the first occurrence of a recurring reminder after the given time
+// occurrences are calculated in the timezone of the user, so daily reminders keep their time across DST changes
+func getNextReminderOccurrence(reminder models.ScheduledReminderEntry, after time.Time) (next time.Time, err error) {
+	next = reminder.ScheduledAt.In(getReminderLocation(reminder.Timezone))
+	for !next.After(after) {
+		next, err = addReminderRecurrence(next, reminder.Recurrence)
+		if err != nil {
+			return next, err
+		}
+	}
+	return next, nil
+}
+
+// addReminderRecurrence adds a recurrence to a time, days and longer units are added as calendar days,
+// so they keep the local time across DST changes, unlike tparse which adds them as multiples of 24 hours
+func addReminderRecurrence(t time.Time, recurrence string) (time.Time, error) {
+	parts := reminderRecurrenceDurationRegex.FindStringSubmatch(recurrence)
+	if parts == nil {
+		return t, fmt.Errorf("invalid recurrence %s", recurrence)
+	}
+	number, err := strconv.Atoi(parts[1])
+	if err != nil || number <= 0 {
+		return t, fmt.Errorf("invalid recurrence %s", recurrence)
+	}
+
+	switch parts[2] {
+	case "m":
+		return t.Add(time.Duration(number) * time.Minute), nil
+	case "h":
+		return t.Add(time.Duration(number) * time.Hour), nil
+	case "d":
+		return t.AddDate(0, 0, number), nil
+	case "w":
+		return t.AddDate(0, 0, 7*number), nil
+	case "mo":
+		return t.AddDate(0, number, 0), nil
+	}
+	return t.AddDate(number, 0, 0), nil
+}
+
+// parseReminderRecurrence parses the text after every, for example "monday 9am standup", "day stretch" or "2 hours drink water"
+// returns the recurrence as tparse duration and the rest of the text
+// weekdays are kept in the rest, so the first occurrence can be parsed from it
+func parseReminderRecurrence(text string) (recurrence, rest string, ok bool) {
+	fields := strings.Fields(text)
+	if len(fields) == 0 {
+		return "", "", false
+	}
+
+	first := strings.ToLower(fields[0])
+	singular := strings.TrimSuffix(first, "s")
+
+	for weekday := time.Sunday; weekday <= time.Saturday; weekday++ {
+		if singular == strings.ToLower(weekday.String()) {
+			return "1w", text, true
+		}
+	}
+
+	if unit, ok := reminderRecurrenceUnits[singular]; ok {
+		return "1" + unit, strings.Join(fields[1:], " "), true
+	}
+
+	if reminderRecurrenceDurationRegex.MatchString(first) {
+		return first, strings.Join(fields[1:], " "), true
+	}
+
+	if number, err := strconv.Atoi(first); err == nil && number > 0 && len(fields) >= 2 {
+		if unit, ok := reminderRecurrenceUnits[strings.TrimSuffix(strings.ToLower(fields[1]), "s")]; ok {
+			return strconv.Itoa(number) + unit, strings.Join(fields[2:], " "), true
+		}
+	}
+
+	return "", "", false
+}
+
+func getReminderLocation(timezone string) (location *time.Location) {
+	location, err := time.LoadLocation(timezone)
+	if err != nil || location == nil {
+		location = time.UTC
+	}
+	return location
+}
+
+func getUserReminderLocation(userID string) (location *time.Location) {
+	userData, err := helpers.GetUserUserdata(userID)
+	if err != nil {
+		return time.UTC
+	}
+	return getReminderLocation(userData.Timezone)
+}
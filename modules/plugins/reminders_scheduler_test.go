@@ -0,0 +1,72 @@
+package plugins
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Seklfreak/Robyul2/models"
+)
+
+func TestParseReminderRecurrence(t *testing.T) {
+	tests := []struct {
+		text       string
+		recurrence string
+		rest       string
+		ok         bool
+	}{
+		{"day stretch", "1d", "stretch", true},
+		{"hours drink water", "1h", "drink water", true},
+		{"2 hours drink water", "2h", "drink water", true},
+		{"30m check the oven", "30m", "check the oven", true},
+		{"3mo pay rent", "3mo", "pay rent", true},
+		{"monday 9am standup", "1w", "monday 9am standup", true},
+		{"Fridays 5pm weekend", "1w", "Fridays 5pm weekend", true},
+		{"0 days nothing", "", "", false},
+		{"sometimes maybe", "", "", false},
+		{"", "", "", false},
+	}
+
+	for _, test := range tests {
+		recurrence, rest, ok := parseReminderRecurrence(test.text)
+		if recurrence != test.recurrence || rest != test.rest || ok != test.ok {
+			t.Errorf("plugins.parseReminderRecurrence(%q) returned %q, %q, %v, expected %q, %q, %v",
+				test.text, recurrence, rest, ok, test.recurrence, test.rest, test.ok)
+		}
+	}
+}
+
+func TestGetNextReminderOccurrence(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("timezone data is not available: %v", err)
+	}
+
+	// the day before the change to daylight saving time
+	scheduledAt := time.Date(2018, 3, 24, 9, 0, 0, 0, berlin)
+	reminder := models.ScheduledReminderEntry{
+		ScheduledAt: scheduledAt,
+		Recurrence:  "1d",
+		Timezone:    "Europe/Berlin",
+	}
+
+	next, err := getNextReminderOccurrence(reminder, scheduledAt)
+	if err != nil {
+		t.Fatalf("plugins.getNextReminderOccurrence() returned error: %v", err)
+	}
+	if expected := time.Date(2018, 3, 25, 9, 0, 0, 0, berlin); !next.Equal(expected) {
+		t.Errorf("plugins.getNextReminderOccurrence() returned %s, expected %s", next, expected)
+	}
+
+	next, err = getNextReminderOccurrence(reminder, scheduledAt.Add(72*time.Hour))
+	if err != nil {
+		t.Fatalf("plugins.getNextReminderOccurrence() returned error: %v", err)
+	}
+	if expected := time.Date(2018, 3, 28, 9, 0, 0, 0, berlin); !next.Equal(expected) {
+		t.Errorf("plugins.getNextReminderOccurrence() skipping missed occurrences returned %s, expected %s", next, expected)
+	}
+
+	reminder.Recurrence = "invalid"
+	if _, err = getNextReminderOccurrence(reminder, scheduledAt); err == nil {
+		t.Errorf("plugins.getNextReminderOccurrence() accepted an invalid recurrence")
+	}
+}
@@ -48,6 +48,11 @@ func (s *Starboard) Uninit(session *discordgo.Session) {
 
 }
 
+// Reloadable is true, the starboard has nothing to initialize
+func (s *Starboard) Reloadable() bool {
+	return true
+}
+
 func (s *Starboard) Action(command string, content string, msg *discordgo.Message, session *discordgo.Session) {
 	if !helpers.ModuleIsAllowed(msg.ChannelID, msg.ID, msg.Author.ID, helpers.ModulePermStarboard) {
 		return
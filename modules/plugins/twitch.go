@@ -13,8 +13,8 @@ import (
 	"net/url"
 
 	"github.com/Seklfreak/Robyul2/cache"
+	"github.com/Seklfreak/Robyul2/feedpoller"
 	"github.com/Seklfreak/Robyul2/helpers"
-	"github.com/Seklfreak/Robyul2/metrics"
 	"github.com/Seklfreak/Robyul2/models"
 	"github.com/bwmarrin/discordgo"
 	humanize "github.com/dustin/go-humanize"
@@ -97,84 +97,84 @@ func (m *Twitch) Commands() []string {
 }
 
 func (m *Twitch) Init(session *discordgo.Session) {
-	go m.checkTwitchFeedsLoop()
-	cache.GetLogger().WithField("module", "twitch").Info("Started twitch loop (60s)")
+	feedpoller.Start(m, feedpoller.Options{
+		Name:        "twitch",
+		Interval:    time.Minute,
+		Jitter:      0.2,
+		Workers:     5,
+		MaxBackoff:  30 * time.Minute,
+		ErrorBudget: 50,
+	})
 }
-func (m *Twitch) checkTwitchFeedsLoop() {
-	defer helpers.Recover()
-	defer func() {
-		go func() {
-			cache.GetLogger().WithField("module", "twitch").Info("The checkTwitchFeedsLoop died. Please investigate! Will be restarted in 60 seconds")
-			time.Sleep(60 * time.Second)
-			m.checkTwitchFeedsLoop()
-		}()
-	}()
 
+// Targets returns the Twitch channels of all feeds in channels the bot can see
+func (m *Twitch) Targets() (targets []string, err error) {
 	var entries []models.TwitchEntry
-	var bundledEntries map[string][]models.TwitchEntry
+	err = helpers.MDbIterWithoutLogging(helpers.MdbCollection(models.TwitchTable).Find(nil)).All(&entries)
+	if err != nil {
+		return nil, err
+	}
 
-	for {
-		err := helpers.MDbIterWithoutLogging(helpers.MdbCollection(models.TwitchTable).Find(nil)).All(&entries)
-		helpers.Relax(err)
+	added := make(map[string]bool)
+	for _, entry := range entries {
+		if added[entry.TwitchChannelName] {
+			continue
+		}
 
-		bundledEntries = make(map[string][]models.TwitchEntry, 0)
+		channel, err := helpers.GetChannelWithoutApi(entry.ChannelID)
+		if err != nil || channel == nil || channel.ID == "" {
+			continue
+		}
 
-		for _, entry := range entries {
-			channel, err := helpers.GetChannelWithoutApi(entry.ChannelID)
-			if err != nil || channel == nil || channel.ID == "" {
-				//cache.GetLogger().WithField("module", "twitch").Warn(fmt.Sprintf("skipped twitch @%s for Channel #%s on Guild #%s: channel not found!",
-				//	entry.TwitchChannelName, entry.ChannelID, entry.ServerID))
-				continue
-			}
+		targets = append(targets, entry.TwitchChannelName)
+		added[entry.TwitchChannelName] = true
+	}
 
-			if _, ok := bundledEntries[entry.TwitchChannelName]; ok {
-				bundledEntries[entry.TwitchChannelName] = append(bundledEntries[entry.TwitchChannelName], entry)
-			} else {
-				bundledEntries[entry.TwitchChannelName] = []models.TwitchEntry{entry}
-			}
-		}
+	return targets, nil
+}
 
-		cache.GetLogger().WithField("module", "twitch").Infof("checking %d channels for %d feeds", len(bundledEntries), len(entries))
-		start := time.Now()
-
-		// TODO: Check multiple entries at once
-		for twitchChannelName, entries := range bundledEntries {
-			//cache.GetLogger().WithField("module", "twitch").Info(fmt.Sprintf("checking Twitch Channel %s", twitchChannelName))
-			twitchStatus := m.getTwitchStatus(twitchChannelName)
-
-			for _, entry := range entries {
-				changes := false
-				if twitchStatus.Links.Channel != "" {
-					if entry.IsLive == false {
-						if twitchStatus.Stream.ID != 0 {
-							go func(gEntry models.TwitchEntry, gTwitchStatus TwitchStatus) {
-								defer helpers.Recover()
-								m.postTwitchLiveToChannel(gEntry, gTwitchStatus)
-							}(entry, twitchStatus)
-							entry.IsLive = true
-							changes = true
-						}
-					} else {
-						if twitchStatus.Stream.ID == 0 {
-							entry.IsLive = false
-							changes = true
-						}
-					}
-				}
+// Check posts the Twitch channel to all feeds of it once it went live
+func (m *Twitch) Check(twitchChannelName string) (err error) {
+	var entries []models.TwitchEntry
+	err = helpers.MDbIterWithoutLogging(helpers.MdbCollection(models.TwitchTable).Find(
+		bson.M{"twitchchannelname": twitchChannelName},
+	)).All(&entries)
+	if err != nil {
+		return err
+	}
 
-				if changes == true {
-					err = helpers.MDbUpdateWithoutLogging(models.TwitchTable, entry.ID, entry)
-					helpers.Relax(err)
-				}
+	twitchStatus := m.getTwitchStatus(twitchChannelName)
+	if twitchStatus.Links.Channel == "" {
+		return nil
+	}
+
+	for _, entry := range entries {
+		changes := false
+		if entry.IsLive == false {
+			if twitchStatus.Stream.ID != 0 {
+				go func(gEntry models.TwitchEntry, gTwitchStatus TwitchStatus) {
+					defer helpers.Recover()
+					m.postTwitchLiveToChannel(gEntry, gTwitchStatus)
+				}(entry, twitchStatus)
+				entry.IsLive = true
+				changes = true
+			}
+		} else {
+			if twitchStatus.Stream.ID == 0 {
+				entry.IsLive = false
+				changes = true
 			}
 		}
 
-		elapsed := time.Since(start)
-		cache.GetLogger().WithField("module", "twitch").Infof("checked %d channels for %d feeds, took %s", len(bundledEntries), len(entries), elapsed)
-		metrics.TwitchRefreshTime.Set(elapsed.Seconds())
-
-		time.Sleep(30 * time.Second)
+		if changes == true {
+			err = helpers.MDbUpdateWithoutLogging(models.TwitchTable, entry.ID, entry)
+			if err != nil {
+				return err
+			}
+		}
 	}
+
+	return nil
 }
 
 func (m *Twitch) Action(command string, content string, msg *discordgo.Message, session *discordgo.Session) {
@@ -185,7 +185,7 @@ func (m *Twitch) Action(command string, content string, msg *discordgo.Message,
 	args := strings.Fields(content)
 	if len(args) >= 1 {
 		switch args[0] {
-		case "add": // [p]twitch add <twitch channel name> <channel>
+		case "add": // [p]twitch add <twitch channel name> <channel> [<mention role>] [feed options]
 			helpers.RequireMod(msg, func() {
 				session.ChannelTyping(msg.ChannelID)
 				// get target channel
@@ -206,9 +206,14 @@ func (m *Twitch) Action(command string, content string, msg *discordgo.Message,
 				}
 				targetGuild, err = helpers.GetGuild(targetChannel.GuildID)
 				helpers.Relax(err)
+				feedOptions, rest, err := helpers.ParseFeedOptions(targetGuild.ID, args[3:], content)
+				if err != nil {
+					helpers.SendMessage(msg.ChannelID, helpers.GetTextF("bot.arguments.invalid-feed-options", err.Error()))
+					return
+				}
 				mentionRole := new(discordgo.Role)
-				if len(args) >= 4 {
-					mentionRoleName := strings.ToLower(args[3])
+				if len(rest) >= 1 {
+					mentionRoleName := strings.ToLower(rest[0])
 					serverRoles, err := session.GuildRoles(targetGuild.ID)
 					if err != nil {
 						if errD, ok := err.(*discordgo.RESTError); ok {
@@ -229,6 +234,9 @@ func (m *Twitch) Action(command string, content string, msg *discordgo.Message,
 						}
 					}
 				}
+				if mentionRole.ID != "" {
+					feedOptions.MentionRoleID = mentionRole.ID
+				}
 				// create new entry in db
 				newID, err := helpers.MDbInsert(
 					models.TwitchTable,
@@ -237,7 +245,7 @@ func (m *Twitch) Action(command string, content string, msg *discordgo.Message,
 						ChannelID:         targetChannel.ID,
 						TwitchChannelName: targetTwitchChannelName,
 						IsLive:            false,
-						MentionRoleID:     mentionRole.ID,
+						FeedOptions:       feedOptions,
 					},
 				)
 				helpers.Relax(err)
@@ -246,17 +254,12 @@ func (m *Twitch) Action(command string, content string, msg *discordgo.Message,
 					models.EventlogTargetTypeRobyulTwitchFeed, msg.Author.ID,
 					models.EventlogTypeRobyulTwitchFeedAdd, "",
 					nil,
-					[]models.ElasticEventlogOption{
+					append([]models.ElasticEventlogOption{
 						{
 							Key:   "twitch_feed_channelname",
 							Value: targetTwitchChannelName,
 						},
-						{
-							Key:   "twitch_feed_mentionroleid",
-							Value: mentionRole.ID,
-							Type:  models.EventlogTargetTypeRole,
-						},
-					}, false)
+					}, helpers.FeedOptionsEventlogOptions("twitch_feed", feedOptions)...), false)
 				helpers.RelaxLog(err)
 
 				_, err = helpers.SendMessage(msg.ChannelID, helpers.GetTextF("plugins.twitch.channel-added-success", targetTwitchChannelName, targetChannel.ID))
@@ -291,17 +294,12 @@ func (m *Twitch) Action(command string, content string, msg *discordgo.Message,
 						models.EventlogTargetTypeRobyulTwitchFeed, msg.Author.ID,
 						models.EventlogTypeRobyulTwitchFeedRemove, "",
 						nil,
-						[]models.ElasticEventlogOption{
+						append([]models.ElasticEventlogOption{
 							{
 								Key:   "twitch_feed_channelname",
 								Value: entryBucket.TwitchChannelName,
 							},
-							{
-								Key:   "twitch_feed_mentionroleid",
-								Value: entryBucket.MentionRoleID,
-								Type:  models.EventlogTargetTypeRole,
-							},
-						}, false)
+						}, helpers.FeedOptionsEventlogOptions("twitch_feed", entryBucket.FeedOptions)...), false)
 					helpers.RelaxLog(err)
 
 					helpers.SendMessage(msg.ChannelID, helpers.GetTextF("plugins.twitch.channel-delete-success", entryBucket.TwitchChannelName))
@@ -328,13 +326,8 @@ func (m *Twitch) Action(command string, content string, msg *discordgo.Message,
 
 			resultMessage := ""
 			for _, entry := range entryBucket {
-				var mentionText string
-				if entry.MentionRoleID != "" {
-					role, err := session.State.Role(currentChannel.GuildID, entry.MentionRoleID)
-					helpers.Relax(err)
-					mentionText += fmt.Sprintf(" mentioning `@%s`", role.Name)
-				}
-				resultMessage += fmt.Sprintf("`%s`: Twitch Channel `%s` posting to <#%s>%s\n", helpers.MdbIdToHuman(entry.ID), entry.TwitchChannelName, entry.ChannelID, mentionText)
+				resultMessage += fmt.Sprintf("`%s`: Twitch Channel `%s` posting to <#%s>%s\n", helpers.MdbIdToHuman(entry.ID), entry.TwitchChannelName, entry.ChannelID,
+					helpers.FeedOptionsText(currentChannel.GuildID, entry.FeedOptions))
 			}
 			resultMessage += fmt.Sprintf("Found **%d** Twitch Channels in total.", len(entryBucket))
 			_, err = helpers.SendMessage(msg.ChannelID, resultMessage)
@@ -431,9 +424,15 @@ func (m *Twitch) postTwitchLiveToChannel(entry models.TwitchEntry, twitchStatus
 	if strings.ToLower(twitchStatus.Stream.Channel.Name) != strings.ToLower(twitchStatus.Stream.Channel.DisplayName) {
 		twitchStreamName += fmt.Sprintf(" (%s)", twitchStatus.Stream.Channel.Name)
 	}
-	var mentionText string
-	if entry.MentionRoleID != "" {
-		mentionText = fmt.Sprintf("<@&%s>\n", entry.MentionRoleID)
+	post := helpers.FeedPost{
+		Author:   twitchStreamName,
+		Title:    twitchStatus.Stream.Channel.Status,
+		URL:      twitchStatus.Stream.Channel.URL,
+		Content:  twitchStatus.Stream.Game,
+		MediaURL: twitchStatus.Stream.Preview.Medium,
+	}
+	if !helpers.FeedPostMatches(entry.FeedOptions, post) {
+		return
 	}
 
 	twitchChannelEmbed := &discordgo.MessageEmbed{
@@ -460,9 +459,9 @@ func (m *Twitch) postTwitchLiveToChannel(entry models.TwitchEntry, twitchStatus
 	if twitchChannelEmbed.Description != "" {
 		twitchChannelEmbed.Description = strings.Trim(twitchChannelEmbed.Description, "\n")
 	}
-	_, err := helpers.SendComplex(entry.ChannelID, &discordgo.MessageSend{
-		Content: mentionText + fmt.Sprintf("<%s>", twitchStatus.Stream.Channel.URL),
+	_, err := helpers.SendComplex(entry.ChannelID, helpers.FeedMessageSend(entry.FeedOptions, post, &discordgo.MessageSend{
+		Content: fmt.Sprintf("<%s>", twitchStatus.Stream.Channel.URL),
 		Embed:   twitchChannelEmbed,
-	})
+	}))
 	helpers.Relax(err)
 }
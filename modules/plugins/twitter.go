@@ -15,8 +15,8 @@ import (
 	"github.com/ChimeraCoder/anaconda"
 	"github.com/Seklfreak/Robyul2/cache"
 	"github.com/Seklfreak/Robyul2/emojis"
+	"github.com/Seklfreak/Robyul2/feedpoller"
 	"github.com/Seklfreak/Robyul2/helpers"
-	"github.com/Seklfreak/Robyul2/metrics"
 	"github.com/Seklfreak/Robyul2/models"
 	"github.com/bwmarrin/discordgo"
 	"github.com/dghubble/go-twitter/twitter"
@@ -35,13 +35,15 @@ var (
 	twitterStreamNeedsUpdate bool
 	twitterEntriesCache      []models.TwitterEntry
 	twitterStreamIsStarting  sync.Mutex
-	twitterEntryLocks        = make(map[string]*sync.Mutex)
 )
 
 const (
 	TwitterFriendlyUser   = "https://twitter.com/%s"
 	TwitterFriendlyStatus = "https://twitter.com/%s/status/%s"
 	rfc2822               = "Mon Jan 02 15:04:05 -0700 2006"
+	// https://developer.twitter.com/en/docs/basics/response-codes
+	twitterRateLimitExceededCode = 88
+	twitterRateLimitWindow       = 15 * time.Minute
 )
 
 func (m *Twitter) Commands() []string {
@@ -92,48 +94,15 @@ func (t *Twitter) Init(session *discordgo.Session) {
 							continue
 						}
 
-						entryID := entry.ID
-						t.lockEntry(entryID)
-
-						err := helpers.MdbOneWithoutLogging(
-							helpers.MdbCollection(models.TwitterTable).Find(bson.M{"_id": entry.ID}),
-							&entry,
-						)
+						claimed, err := t.claimTweet(entry.ID, models.TwitterTweetEntry{ID: item.IdStr, CreatedAt: item.CreatedAt})
 						if err != nil {
-							t.unlockEntry(entryID)
 							helpers.RelaxLog(err)
 							continue
 						}
-
-						changes := false
-						tweetAlreadyPosted := false
-
-						for _, postedTweet := range entry.PostedTweets {
-							if postedTweet.ID == item.IdStr {
-								tweetAlreadyPosted = true
-							}
-						}
-						if tweetAlreadyPosted == false {
+						if claimed {
 							// cache.GetLogger().WithField("module", "twitter").Info(fmt.Sprintf("posting tweet (via streaming): #%s to: #%s", item.IdStr, entry.ChannelID))
-							entry.PostedTweets = append(entry.PostedTweets, models.TwitterTweetEntry{ID: item.IdStr, CreatedAt: item.CreatedAt})
-							changes = true
 							go t.postAnacondaTweetToChannel(entry.ChannelID, &item, &item.User, entry)
 						}
-
-						if changes == true {
-							err = helpers.MDbUpsertIDWithoutLogging(
-								models.TwitterTable,
-								entry.ID,
-								entry,
-							)
-							if err != nil {
-								t.unlockEntry(entryID)
-								helpers.RelaxLog(err)
-								continue
-							}
-						}
-
-						t.unlockEntry(entryID)
 					}
 				case anaconda.StallWarning:
 					cache.GetLogger().WithField("module", "twitter").Warn("received stall warning from twitter stream:", item.Message)
@@ -149,8 +118,15 @@ func (t *Twitter) Init(session *discordgo.Session) {
 		// wait for twitterEntriesCache to initialize
 		time.Sleep(30 * time.Second)
 		// TODO: only to REST API check on start or after stream restarts
-		go t.checkTwitterFeedsLoop()
-		cache.GetLogger().WithField("module", "twitter").Info("started twitter loop (10m)")
+		feedpoller.Start(t, feedpoller.Options{
+			Name:        "twitter",
+			Interval:    10 * time.Minute,
+			Jitter:      0.2,
+			Workers:     1,
+			MaxBackoff:  time.Hour,
+			ErrorBudget: 10,
+			MinDelay:    5 * time.Second,
+		})
 	}()
 }
 
@@ -273,157 +249,106 @@ func (t *Twitter) updateTwitterStreamLoop() {
 	}
 }
 
-func (m *Twitter) checkTwitterFeedsLoop() {
-	defer helpers.Recover()
-	defer func() {
-		go func() {
-			cache.GetLogger().WithField("module", "twitter").Error("The checkTwitterFeedsLoop died. Please investigate! Will be restarted in 60 seconds")
-			time.Sleep(60 * time.Second)
-			m.checkTwitterFeedsLoop()
-		}()
-	}()
-
-	var bundledEntries map[string][]models.TwitterEntry
+// Targets returns the Twitter accounts of all feeds in channels the bot can post to
+func (m *Twitter) Targets() (targets []string, err error) {
+	added := make(map[string]bool)
+	for _, entry := range twitterEntriesCache {
+		if added[entry.AccountScreenName] || !m.canPostToChannel(entry) {
+			continue
+		}
 
-	for {
-		bundledEntries = make(map[string][]models.TwitterEntry, 0)
+		targets = append(targets, entry.AccountScreenName)
+		added[entry.AccountScreenName] = true
+	}
 
-		for _, entry := range twitterEntriesCache {
-			// check if channel exists
-			channel, err := helpers.GetChannelWithoutApi(entry.ChannelID)
-			if err != nil || channel == nil || channel.ID == "" {
-				continue
-			}
+	return targets, nil
+}
 
-			// check if we can send messages
-			channelPermission, err := cache.GetSession().State.UserChannelPermissions(cache.GetSession().State.User.ID, channel.ID)
-			if err != nil {
-				continue
-			}
+// Check posts new tweets of the Twitter account to all feeds of it, in addition to the stream
+func (m *Twitter) Check(twitterAccountScreenName string) (err error) {
+	twitterUser, _, err := twitterClient.Users.Show(&twitter.UserShowParams{
+		ScreenName: twitterAccountScreenName,
+	})
+	if err != nil {
+		return m.wrapRateLimitError(err)
+	}
 
-			if channelPermission&discordgo.PermissionSendMessages != discordgo.PermissionSendMessages {
-				continue
-			}
+	twitterUserTweets, _, err := twitterClient.Timelines.UserTimeline(&twitter.UserTimelineParams{
+		ScreenName:      twitterAccountScreenName,
+		Count:           10,
+		ExcludeReplies:  twitter.Bool(true),
+		IncludeRetweets: twitter.Bool(true),
+	})
+	if err != nil {
+		return m.wrapRateLimitError(err)
+	}
 
-			if entry.PostMode == models.TwitterPostModeRobyulEmbed {
-				if channelPermission&discordgo.PermissionEmbedLinks != discordgo.PermissionEmbedLinks {
-					continue
-				}
-			}
+	// https://github.com/golang/go/wiki/SliceTricks#reversing
+	for i := len(twitterUserTweets)/2 - 1; i >= 0; i-- {
+		opp := len(twitterUserTweets) - 1 - i
+		twitterUserTweets[i], twitterUserTweets[opp] = twitterUserTweets[opp], twitterUserTweets[i]
+	}
 
-			if _, ok := bundledEntries[entry.AccountScreenName]; ok {
-				bundledEntries[entry.AccountScreenName] = append(bundledEntries[entry.AccountScreenName], entry)
-			} else {
-				bundledEntries[entry.AccountScreenName] = []models.TwitterEntry{entry}
-			}
+	for _, entry := range twitterEntriesCache {
+		if entry.AccountScreenName != twitterAccountScreenName || !m.canPostToChannel(entry) {
+			continue
 		}
 
-		cache.GetLogger().WithField("module", "twitter").Infof("checking %d accounts for %d feeds", len(bundledEntries), len(twitterEntriesCache))
-		start := time.Now()
+		for _, tweet := range twitterUserTweets {
+			tweetCreatedAt, err := tweet.CreatedAtTime()
+			if err != nil || time.Now().Sub(tweetCreatedAt) > time.Hour {
+				continue
+			}
 
-		for twitterAccoutnScreenName, entries := range bundledEntries {
-			// cache.GetLogger().WithField("module", "twitter").Info(fmt.Sprintf("checking Twitter Account @%s", twitterAccoutnScreenName))
+			// exclude RTs?
+			if entry.ExcludeRTs && tweet.RetweetedStatus != nil {
+				continue
+			}
 
-			twitterUser, _, err := twitterClient.Users.Show(&twitter.UserShowParams{
-				ScreenName: twitterAccoutnScreenName,
-			})
-			if err != nil {
-				cache.GetLogger().WithField("module", "twitter").Warnf("updating twitter account @%s failed: %s", twitterAccoutnScreenName, err.Error())
+			// exclude Mentions?
+			if entry.ExcludeMentions && strings.HasPrefix(tweet.Text, "@") {
 				continue
 			}
 
-			twitterUserTweets, _, err := twitterClient.Timelines.UserTimeline(&twitter.UserTimelineParams{
-				ScreenName:      twitterAccoutnScreenName,
-				Count:           10,
-				ExcludeReplies:  twitter.Bool(true),
-				IncludeRetweets: twitter.Bool(true),
-			})
+			claimed, err := m.claimTweet(entry.ID, models.TwitterTweetEntry{ID: tweet.IDStr, CreatedAt: tweet.CreatedAt})
 			if err != nil {
-				cache.GetLogger().WithField("module", "twitter").Warnf("getting tweets of @%s failed: %s", twitterAccoutnScreenName, err.Error())
+				helpers.RelaxLog(err)
 				continue
 			}
-
-			// https://github.com/golang/go/wiki/SliceTricks#reversing
-			for i := len(twitterUserTweets)/2 - 1; i >= 0; i-- {
-				opp := len(twitterUserTweets) - 1 - i
-				twitterUserTweets[i], twitterUserTweets[opp] = twitterUserTweets[opp], twitterUserTweets[i]
+			if claimed {
+				// cache.GetLogger().WithField("module", "twitter").Info(fmt.Sprintf("posting tweet (via REST): #%s to: #%s", tweet.IDStr, entry.ChannelID))
+				tweetToPost := tweet
+				go m.postTweetToChannel(entry.ChannelID, &tweetToPost, twitterUser, entry)
 			}
+		}
+	}
 
-			for _, entry := range entries {
-				entryID := entry.ID
-				m.lockEntry(entryID)
-
-				err = helpers.MdbOneWithoutLogging(
-					helpers.MdbCollection(models.TwitterTable).Find(bson.M{"_id": entry.ID}),
-					&entry,
-				)
-				if err != nil {
-					m.unlockEntry(entryID)
-					if !helpers.IsMdbNotFound(err) {
-						helpers.RelaxLog(err)
-					}
-					continue
-				}
-
-				changes := false
-
-				for _, tweet := range twitterUserTweets {
-					tweetCreatedAt, err := tweet.CreatedAtTime()
-					if err != nil || time.Now().Sub(tweetCreatedAt) > time.Hour {
-						continue
-					}
-
-					// exclude RTs?
-					if entry.ExcludeRTs && tweet.RetweetedStatus != nil {
-						continue
-					}
-
-					// exclude Mentions?
-					if entry.ExcludeMentions && strings.HasPrefix(tweet.Text, "@") {
-						continue
-					}
-
-					tweetAlreadyPosted := false
-					for _, postedTweet := range entry.PostedTweets {
-						if postedTweet.ID == tweet.IDStr {
-							tweetAlreadyPosted = true
-						}
-					}
-					if tweetAlreadyPosted == false {
-						// cache.GetLogger().WithField("module", "twitter").Info(fmt.Sprintf("posting tweet (via REST): #%s to: #%s", tweet.IDStr, entry.ChannelID))
-						entry.PostedTweets = append(entry.PostedTweets, models.TwitterTweetEntry{ID: tweet.IDStr, CreatedAt: tweet.CreatedAt})
-						changes = true
-						tweetToPost := tweet
-						go m.postTweetToChannel(entry.ChannelID, &tweetToPost, twitterUser, entry)
-					}
+	return nil
+}
 
-				}
-				if changes == true {
-					err = helpers.MDbUpsertIDWithoutLogging(
-						models.TwitterTable,
-						entry.ID,
-						entry,
-					)
-					if err != nil {
-						m.unlockEntry(entryID)
-						helpers.RelaxLog(err)
-						continue
-					}
-				}
+// canPostToChannel checks if the channel of the entry exists and the bot can post to it
+func (m *Twitter) canPostToChannel(entry models.TwitterEntry) bool {
+	channel, err := helpers.GetChannelWithoutApi(entry.ChannelID)
+	if err != nil || channel == nil || channel.ID == "" {
+		return false
+	}
 
-				m.unlockEntry(entryID)
-			}
-			time.Sleep(5 * time.Second)
-		}
+	channelPermission, err := cache.GetSession().State.UserChannelPermissions(cache.GetSession().State.User.ID, channel.ID)
+	if err != nil {
+		return false
+	}
 
-		elapsed := time.Since(start)
-		cache.GetLogger().WithField("module", "twitter").Infof("checked %d accounts for %d feeds, took %s", len(bundledEntries), len(twitterEntriesCache), elapsed)
-		metrics.TwitterRefreshTime.Set(elapsed.Seconds())
+	if channelPermission&discordgo.PermissionSendMessages != discordgo.PermissionSendMessages {
+		return false
+	}
 
-		if len(bundledEntries) <= 10 {
-			time.Sleep(10 * time.Minute)
+	if entry.PostMode == models.TwitterPostModeRobyulEmbed {
+		if channelPermission&discordgo.PermissionEmbedLinks != discordgo.PermissionEmbedLinks {
+			return false
 		}
 	}
+
+	return true
 }
 
 func (m *Twitter) Action(command string, content string, msg *discordgo.Message, session *discordgo.Session) {
@@ -434,7 +359,7 @@ func (m *Twitter) Action(command string, content string, msg *discordgo.Message,
 	args := strings.Fields(content)
 	if len(args) >= 1 {
 		switch args[0] {
-		case "add": // [p]twitter add <twitter account name (with or without @)> <discord channel>
+		case "add": // [p]twitter add <twitter account name (with or without @)> <discord channel> [feed options]
 			helpers.RequireMod(msg, func() {
 				session.ChannelTyping(msg.ChannelID)
 				// get target channel
@@ -478,9 +403,17 @@ func (m *Twitter) Action(command string, content string, msg *discordgo.Message,
 				}
 				helpers.Relax(err)
 
+				feedOptions, rest, err := helpers.ParseFeedOptions(targetGuild.ID, args[3:], content)
+				if err != nil {
+					helpers.SendMessage(msg.ChannelID, helpers.GetTextF("bot.arguments.invalid-feed-options", err.Error()))
+					return
+				}
+				// the remaining arguments are the mention role and the flags, the embed code could contain the flags
+				flagsText := strings.ToLower(" " + strings.Join(rest, " "))
+
 				mentionRole := new(discordgo.Role)
-				if len(args) >= 4 && (args[3] != "discord-embed" && args[3] != "text") {
-					mentionRoleName := args[3]
+				if len(rest) >= 1 && (rest[0] != "discord-embed" && rest[0] != "text") {
+					mentionRoleName := rest[0]
 					serverRoles, err := session.GuildRoles(targetGuild.ID)
 					if err != nil {
 						if errD, ok := err.(*discordgo.RESTError); ok {
@@ -503,10 +436,10 @@ func (m *Twitter) Action(command string, content string, msg *discordgo.Message,
 					}
 				}
 				postMode := models.TwitterPostModeRobyulEmbed
-				if strings.Contains(strings.ToLower(content), " discord-embed") {
+				if strings.Contains(flagsText, " discord-embed") {
 					postMode = models.TwitterPostModeDiscordEmbed
 				}
-				if strings.Contains(strings.ToLower(content), " text") {
+				if strings.Contains(flagsText, " text") {
 					postMode = models.TwitterPostModeText
 				}
 				// Create DB Entries
@@ -518,12 +451,15 @@ func (m *Twitter) Action(command string, content string, msg *discordgo.Message,
 				}
 				// exclude RTs or Mentions?
 				var excludeRTs, excludeMentions bool
-				if strings.Contains(strings.ToLower(msg.Content), " exclude-rts") {
+				if strings.Contains(flagsText, " exclude-rts") {
 					excludeRTs = true
 				}
-				if strings.Contains(strings.ToLower(msg.Content), " exclude-mentions") {
+				if strings.Contains(flagsText, " exclude-mentions") {
 					excludeMentions = true
 				}
+				if mentionRole.ID != "" {
+					feedOptions.MentionRoleID = mentionRole.ID
+				}
 				// create new entry in db
 				newID, err := helpers.MDbInsert(
 					models.TwitterTable,
@@ -533,10 +469,10 @@ func (m *Twitter) Action(command string, content string, msg *discordgo.Message,
 						AccountScreenName: twitterUser.ScreenName,
 						AccountID:         twitterUser.IDStr,
 						PostedTweets:      dbTweets,
-						MentionRoleID:     mentionRole.ID,
 						PostMode:          postMode,
 						ExcludeRTs:        excludeRTs,
 						ExcludeMentions:   excludeMentions,
+						FeedOptions:       feedOptions,
 					},
 				)
 				helpers.Relax(err)
@@ -555,7 +491,7 @@ func (m *Twitter) Action(command string, content string, msg *discordgo.Message,
 					models.EventlogTargetTypeRobyulTwitterFeed, msg.Author.ID,
 					models.EventlogTypeRobyulTwitterFeedAdd, "",
 					nil,
-					[]models.ElasticEventlogOption{
+					append([]models.ElasticEventlogOption{
 						{
 							Key:   "twitter_channelid",
 							Value: targetChannel.ID,
@@ -569,11 +505,6 @@ func (m *Twitter) Action(command string, content string, msg *discordgo.Message,
 							Key:   "twitter_accountid",
 							Value: twitterUser.IDStr,
 						},
-						{
-							Key:   "twitter_mentionroleid",
-							Value: mentionRole.ID,
-							Type:  models.EventlogTargetTypeRole,
-						},
 						{
 							Key:   "twitter_postmode",
 							Value: postModeText,
@@ -586,7 +517,7 @@ func (m *Twitter) Action(command string, content string, msg *discordgo.Message,
 							Key:   "twitter_exclude_mentions",
 							Value: helpers.StoreBoolAsString(excludeMentions),
 						},
-					}, false)
+					}, helpers.FeedOptionsEventlogOptions("twitter", feedOptions)...), false)
 				helpers.RelaxLog(err)
 
 				helpers.SendMessage(msg.ChannelID, helpers.GetTextF("plugins.twitter.account-added-success", twitterUser.ScreenName, targetChannel.ID))
@@ -629,7 +560,7 @@ func (m *Twitter) Action(command string, content string, msg *discordgo.Message,
 						models.EventlogTargetTypeRobyulTwitterFeed, msg.Author.ID,
 						models.EventlogTypeRobyulTwitterFeedRemove, "",
 						nil,
-						[]models.ElasticEventlogOption{
+						append([]models.ElasticEventlogOption{
 							{
 								Key:   "twitter_channelid",
 								Value: entryBucket.ChannelID,
@@ -643,11 +574,6 @@ func (m *Twitter) Action(command string, content string, msg *discordgo.Message,
 								Key:   "twitter_accountid",
 								Value: entryBucket.AccountID,
 							},
-							{
-								Key:   "twitter_mentionroleid",
-								Value: entryBucket.MentionRoleID,
-								Type:  models.EventlogTargetTypeRole,
-							},
 							{
 								Key:   "twitter_postmode",
 								Value: postModeText,
@@ -660,7 +586,7 @@ func (m *Twitter) Action(command string, content string, msg *discordgo.Message,
 								Key:   "twitter_exclude_mentions",
 								Value: helpers.StoreBoolAsString(entryBucket.ExcludeMentions),
 							},
-						}, false)
+						}, helpers.FeedOptionsEventlogOptions("twitter", entryBucket.FeedOptions)...), false)
 					helpers.RelaxLog(err)
 
 					helpers.SendMessage(msg.ChannelID, helpers.GetTextF("plugins.twitter.account-delete-success", entryBucket.AccountScreenName))
@@ -694,20 +620,13 @@ func (m *Twitter) Action(command string, content string, msg *discordgo.Message,
 				case models.TwitterPostModeText:
 					specialText += " as text"
 				}
-				if entry.MentionRoleID != "" {
-					role, err := session.State.Role(currentChannel.GuildID, entry.MentionRoleID)
-					if err == nil {
-						specialText += fmt.Sprintf(" mentioning `@%s`", role.Name)
-					} else {
-						specialText += " mentioning N/A"
-					}
-				}
 				if entry.ExcludeRTs {
 					specialText += " ignoring RTs"
 				}
 				if entry.ExcludeMentions {
 					specialText += " ignoring Mentions"
 				}
+				specialText += helpers.FeedOptionsText(currentChannel.GuildID, entry.FeedOptions)
 				resultMessage += fmt.Sprintf("`%s`: Twitter Account `@%s` posting to <#%s>%s\n",
 					helpers.MdbIdToHuman(entry.ID), entry.AccountScreenName, entry.ChannelID, specialText)
 			}
@@ -786,14 +705,23 @@ func (m *Twitter) Action(command string, content string, msg *discordgo.Message,
 }
 
 func (m *Twitter) postTweetToChannel(channelID string, tweet *twitter.Tweet, twitterUser *twitter.User, entry models.TwitterEntry) {
+	post := helpers.FeedPost{
+		Author:  fmt.Sprintf("%s (@%s)", twitterUser.Name, twitterUser.ScreenName),
+		URL:     fmt.Sprintf(TwitterFriendlyStatus, twitterUser.ScreenName, tweet.IDStr),
+		Content: html.UnescapeString(tweet.Text),
+	}
+	if tweet.Entities != nil && len(tweet.Entities.Media) > 0 {
+		post.MediaURL = tweet.Entities.Media[0].MediaURLHttps
+	}
+	if !helpers.FeedPostMatches(entry.FeedOptions, post) {
+		return
+	}
+
 	if entry.PostMode == models.TwitterPostModeDiscordEmbed || entry.PostMode == models.TwitterPostModeText {
 		content := fmt.Sprintf("%s", fmt.Sprintf(TwitterFriendlyStatus, twitterUser.ScreenName, tweet.IDStr))
 		if entry.PostMode == models.TwitterPostModeText {
 			content = "<" + content + ">"
 		}
-		if entry.MentionRoleID != "" {
-			content = fmt.Sprintf("<@&%s>\n%s", entry.MentionRoleID, content)
-		}
 		if entry.PostMode == models.TwitterPostModeText {
 			// hide URL previews
 			content += "\n" + helpers.URLRegex.ReplaceAllStringFunc(tweet.Text, func(link string) string {
@@ -817,9 +745,9 @@ func (m *Twitter) postTweetToChannel(channelID string, tweet *twitter.Tweet, twi
 		}
 
 		helpers.SendComplex(
-			channelID, &discordgo.MessageSend{
+			channelID, helpers.FeedMessageSend(entry.FeedOptions, post, &discordgo.MessageSend{
 				Content: content,
-			})
+			}))
 		return
 	}
 
@@ -891,26 +819,32 @@ func (m *Twitter) postTweetToChannel(channelID string, tweet *twitter.Tweet, twi
 	}
 
 	content := fmt.Sprintf("<%s>", fmt.Sprintf(TwitterFriendlyStatus, twitterUser.ScreenName, tweet.IDStr))
-	if entry.MentionRoleID != "" {
-		content = fmt.Sprintf("<@&%s>\n%s", entry.MentionRoleID, content)
-	}
 
 	helpers.SendComplex(
-		channelID, &discordgo.MessageSend{
+		channelID, helpers.FeedMessageSend(entry.FeedOptions, post, &discordgo.MessageSend{
 			Content: content,
 			Embed:   channelEmbed,
-		})
+		}))
 }
 
 func (m *Twitter) postAnacondaTweetToChannel(channelID string, tweet *anaconda.Tweet, twitterUser *anaconda.User, entry models.TwitterEntry) {
+	post := helpers.FeedPost{
+		Author:  fmt.Sprintf("%s (@%s)", twitterUser.Name, twitterUser.ScreenName),
+		URL:     fmt.Sprintf(TwitterFriendlyStatus, twitterUser.ScreenName, tweet.IdStr),
+		Content: html.UnescapeString(tweet.Text),
+	}
+	if len(tweet.Entities.Media) > 0 {
+		post.MediaURL = tweet.Entities.Media[0].Media_url_https
+	}
+	if !helpers.FeedPostMatches(entry.FeedOptions, post) {
+		return
+	}
+
 	if entry.PostMode == models.TwitterPostModeDiscordEmbed || entry.PostMode == models.TwitterPostModeText {
 		content := fmt.Sprintf("%s", fmt.Sprintf(TwitterFriendlyStatus, twitterUser.ScreenName, tweet.IdStr))
 		if entry.PostMode == models.TwitterPostModeText {
 			content = "<" + content + ">"
 		}
-		if entry.MentionRoleID != "" {
-			content = fmt.Sprintf("<@&%s>\n%s", entry.MentionRoleID, content)
-		}
 		if entry.PostMode == models.TwitterPostModeText {
 			// hide URL previews
 			content += "\n" + helpers.URLRegex.ReplaceAllStringFunc(tweet.Text, func(link string) string {
@@ -935,9 +869,9 @@ func (m *Twitter) postAnacondaTweetToChannel(channelID string, tweet *anaconda.T
 		}
 
 		helpers.SendComplex(
-			channelID, &discordgo.MessageSend{
+			channelID, helpers.FeedMessageSend(entry.FeedOptions, post, &discordgo.MessageSend{
 				Content: content,
-			})
+			}))
 		return
 	}
 
@@ -1005,15 +939,12 @@ func (m *Twitter) postAnacondaTweetToChannel(channelID string, tweet *anaconda.T
 	}
 
 	content := fmt.Sprintf("<%s>", fmt.Sprintf(TwitterFriendlyStatus, twitterUser.ScreenName, tweet.IdStr))
-	if entry.MentionRoleID != "" {
-		content = fmt.Sprintf("<@&%s>\n%s", entry.MentionRoleID, content)
-	}
 
 	helpers.SendComplex(
-		channelID, &discordgo.MessageSend{
+		channelID, helpers.FeedMessageSend(entry.FeedOptions, post, &discordgo.MessageSend{
 			Content: content,
 			Embed:   channelEmbed,
-		})
+		}))
 }
 
 func (m *Twitter) bestVideoVariant(videoVariants []twitter.VideoVariant) (bestVariant twitter.VideoVariant) {
@@ -1085,21 +1016,25 @@ func (m *Twitter) handleError(err error) string {
 	panic(err)
 }
 
-func (m *Twitter) lockEntry(entryID bson.ObjectId) {
-	key := string(entryID)
-	if _, ok := twitterEntryLocks[key]; ok && twitterEntryLocks[key] != nil {
-		twitterEntryLocks[key].Lock()
-		return
+// wrapRateLimitError turns rate limit errors of the Twitter API into feedpoller.RateLimitErrors, until the rate limit window ends
+func (m *Twitter) wrapRateLimitError(err error) error {
+	if apiError, ok := err.(twitter.APIError); ok && !apiError.Empty() && apiError.Errors[0].Code == twitterRateLimitExceededCode {
+		return &feedpoller.RateLimitError{Err: err, RetryAfter: twitterRateLimitWindow}
 	}
-	twitterEntryLocks[key] = new(sync.Mutex)
-	twitterEntryLocks[key].Lock()
+	return err
 }
 
-func (m *Twitter) unlockEntry(entryID bson.ObjectId) {
-	key := string(entryID)
-	if _, ok := twitterEntryLocks[key]; ok && twitterEntryLocks[key] != nil {
-		twitterEntryLocks[key].Unlock()
+// claimTweet marks the tweet as posted to the feed, it returns false if the tweet has been posted to the feed already
+// the stream and the REST checks of all bot instances claim tweets, so each tweet is posted once
+func (m *Twitter) claimTweet(entryID bson.ObjectId, tweet models.TwitterTweetEntry) (claimed bool, err error) {
+	err = helpers.MdbCollection(models.TwitterTable).Update(
+		bson.M{"_id": entryID, "postedtweets.id": bson.M{"$ne": tweet.ID}},
+		bson.M{"$push": bson.M{"postedtweets": tweet}},
+	)
+	if helpers.IsMdbNotFound(err) {
+		return false, nil
 	}
+	return err == nil, err
 }
 
 func (t *Twitter) OnMessage(content string, msg *discordgo.Message, session *discordgo.Session) {
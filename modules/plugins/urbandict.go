@@ -8,6 +8,7 @@ import (
 
 	"github.com/Jeffail/gabs"
 	"github.com/Seklfreak/Robyul2/helpers"
+	"github.com/Seklfreak/Robyul2/models"
 	"github.com/bwmarrin/discordgo"
 )
 
@@ -20,6 +21,38 @@ func (u *UrbanDict) Commands() []string {
 	}
 }
 
+func (u *UrbanDict) CommandDescriptors() []models.CommandDescriptor {
+	return []models.CommandDescriptor{
+		{
+			Name:        "urban",
+			Aliases:     []string{"ub", "ud"},
+			Description: "Looks up the definition of a term on Urban Dictionary.",
+			Usage:       "<term>",
+			Arguments: []models.CommandArgument{
+				{Name: "term", Description: "The term to look up", Type: models.CommandArgumentTypeText, Required: true, Variadic: true},
+			},
+			Module: helpers.ModulePermUrban,
+		},
+	}
+}
+
+func (u *UrbanDict) InteractionCommands() []models.InteractionCommand {
+	return []models.InteractionCommand{
+		{
+			Name:        "urban",
+			Description: "Looks up a term on Urban Dictionary",
+			Options: []models.InteractionCommandOption{
+				{
+					Type:        models.InteractionCommandOptionTypeString,
+					Name:        "term",
+					Description: "The term to look up",
+					Required:    true,
+				},
+			},
+		},
+	}
+}
+
 func (u *UrbanDict) Init(session *discordgo.Session) {
 
 }
@@ -10,6 +10,7 @@ import (
 
 	"github.com/PuerkitoBio/goquery"
 	"github.com/Seklfreak/Robyul2/cache"
+	"github.com/Seklfreak/Robyul2/feedpoller"
 	"github.com/Seklfreak/Robyul2/helpers"
 	"github.com/Seklfreak/Robyul2/metrics"
 	"github.com/Seklfreak/Robyul2/models"
@@ -48,189 +49,152 @@ func (r *VLive) Commands() []string {
 }
 
 func (r *VLive) Init(session *discordgo.Session) {
-	go r.checkVliveFeedsLoop()
-	cache.GetLogger().WithField("module", "vlive").Info("Started vlive loop (0s)")
+	feedpoller.Start(r, feedpoller.Options{
+		Name:       "vlive",
+		Interval:   time.Minute,
+		Jitter:     0.2,
+		Workers:    VLiveWorkers,
+		MaxBackoff: 30 * time.Minute,
+	})
 }
-func (r *VLive) checkVliveFeedsLoop() {
+
+// Targets returns the V Live channels of all feeds in channels the bot can post to
+func (r *VLive) Targets() (targets []string, err error) {
 	var entries []models.VliveEntry
-	var bundledEntries map[string][]models.VliveEntry
+	err = helpers.MDbIterWithoutLogging(helpers.MdbCollection(models.VliveTable).Find(nil)).All(&entries)
+	if err != nil {
+		return nil, err
+	}
 
-	defer helpers.Recover()
-	defer func() {
-		go func() {
-			cache.GetLogger().WithField("module", "vlive").Error("The checkVliveFeedsLoop died. Please investigate! Will be restarted in 60 seconds")
-			time.Sleep(60 * time.Second)
-			r.checkVliveFeedsLoop()
-		}()
-	}()
+	added := make(map[string]bool)
+	for _, entry := range entries {
+		if added[entry.VLiveChannel.Code] || !r.canPostToChannel(entry.ChannelID) {
+			continue
+		}
 
-	for {
-		bundledEntries = make(map[string][]models.VliveEntry, 0)
+		targets = append(targets, entry.VLiveChannel.Code)
+		added[entry.VLiveChannel.Code] = true
+	}
 
-		err := helpers.MDbIterWithoutLogging(helpers.MdbCollection(models.VliveTable).Find(nil)).All(&entries)
-		helpers.Relax(err)
+	return targets, nil
+}
 
-		for _, entry := range entries {
-			// check if channel exists
-			channel, err := helpers.GetChannelWithoutApi(entry.ChannelID)
-			if err != nil || channel == nil || channel.ID == "" {
-				continue
-			}
+// Check posts new videos, notices and celeb posts of the V Live channel to all feeds of it
+func (r *VLive) Check(channelCode string) (err error) {
+	var entries []models.VliveEntry
+	err = helpers.MDbIterWithoutLogging(helpers.MdbCollection(models.VliveTable).Find(
+		bson.M{"vlivechannel.code": channelCode},
+	)).All(&entries)
+	if err != nil {
+		return err
+	}
 
-			// check if we can send messages
-			channelPermission, err := cache.GetSession().State.UserChannelPermissions(cache.GetSession().State.User.ID, channel.ID)
-			if err != nil {
-				continue
-			}
+	updatedVliveChannel, err := r.getVLiveChannelByVliveChannelId(channelCode)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if !r.canPostToChannel(entry.ChannelID) {
+			continue
+		}
 
-			if channelPermission&discordgo.PermissionSendMessages != discordgo.PermissionSendMessages ||
-				channelPermission&discordgo.PermissionEmbedLinks != discordgo.PermissionEmbedLinks {
+		changes := false
+
+		for _, vod := range updatedVliveChannel.VOD {
+			// don't post playlists
+			if vod.Type == "PLAYLIST" {
 				continue
 			}
-
-			if _, ok := bundledEntries[entry.VLiveChannel.Code]; ok {
-				bundledEntries[entry.VLiveChannel.Code] = append(bundledEntries[entry.VLiveChannel.Code], entry)
-			} else {
-				bundledEntries[entry.VLiveChannel.Code] = []models.VliveEntry{entry}
+			videoAlreadyPosted := false
+			for _, postedVod := range entry.PostedVOD {
+				if postedVod.Seq == vod.Seq {
+					videoAlreadyPosted = true
+				}
+			}
+			if videoAlreadyPosted == false {
+				entry.PostedVOD = append(entry.PostedVOD, vod)
+				changes = true
+				go r.postVodToChannel(entry, vod, updatedVliveChannel)
 			}
 		}
-
-		cache.GetLogger().WithField("module", "vlive").Info(fmt.Sprintf("checking %d channels for %d feeds with %d workers", len(bundledEntries), len(entries), VLiveWorkers))
-		start := time.Now()
-
-		jobs := make(chan map[string][]models.VliveEntry, 0)
-		results := make(chan int, 0)
-
-		workerEntries := make(map[int]map[string][]models.VliveEntry, 0)
-		for w := 1; w <= VLiveWorkers; w++ {
-			go r.feedWorker(w, jobs, results)
-			workerEntries[w] = make(map[string][]models.VliveEntry)
+		for _, upcoming := range updatedVliveChannel.Upcoming {
+			videoAlreadyPosted := false
+			for _, postedUpcoming := range entry.PostedUpcoming {
+				if postedUpcoming.Seq == upcoming.Seq {
+					videoAlreadyPosted = true
+				}
+			}
+			if videoAlreadyPosted == false {
+				entry.PostedUpcoming = append(entry.PostedUpcoming, upcoming)
+				changes = true
+				go r.postUpcomingToChannel(entry, upcoming, updatedVliveChannel)
+			}
 		}
-
-		lastWorker := 1
-		for code, codeEntries := range bundledEntries {
-			workerEntries[lastWorker][code] = codeEntries
-			lastWorker++
-			if lastWorker > VLiveWorkers {
-				lastWorker = 1
+		for _, live := range updatedVliveChannel.Live {
+			videoAlreadyPosted := false
+			for _, postedLive := range entry.PostedLive {
+				if postedLive.Seq == live.Seq {
+					videoAlreadyPosted = true
+				}
+			}
+			if videoAlreadyPosted == false {
+				entry.PostedLive = append(entry.PostedLive, live)
+				changes = true
+				go r.postLiveToChannel(entry, live, updatedVliveChannel)
 			}
 		}
-
-		for _, workerEntry := range workerEntries {
-			jobs <- workerEntry
+		for _, notice := range updatedVliveChannel.Notices {
+			noticeAlreadyPosted := false
+			for _, postedNotice := range entry.PostedNotices {
+				if postedNotice.Number == notice.Number {
+					noticeAlreadyPosted = true
+				}
+			}
+			if noticeAlreadyPosted == false {
+				entry.PostedNotices = append(entry.PostedNotices, notice)
+				changes = true
+				go r.postNoticeToChannel(entry, notice, updatedVliveChannel)
+			}
 		}
-		close(jobs)
-
-		for a := 1; a <= VLiveWorkers; a++ {
-			<-results
+		for _, celeb := range updatedVliveChannel.Celebs {
+			celebAlreadyPosted := false
+			for _, postedCeleb := range entry.PostedCelebs {
+				if postedCeleb.ID == celeb.ID {
+					celebAlreadyPosted = true
+				}
+			}
+			if celebAlreadyPosted == false {
+				entry.PostedCelebs = append(entry.PostedCelebs, celeb)
+				changes = true
+				go r.postCelebToChannel(entry, celeb, updatedVliveChannel)
+			}
 		}
-		elapsed := time.Since(start)
-		cache.GetLogger().WithField("module", "vlive").Info(fmt.Sprintf("checked %d channels for %d feeds with %d workers, took %s", len(bundledEntries), len(entries), VLiveWorkers, elapsed))
-		metrics.VliveRefreshTime.Set(elapsed.Seconds())
-
-		if len(entries) <= 10 {
-			time.Sleep(60 * time.Second)
+		if changes == true {
+			err = helpers.MDbUpdateWithoutLogging(models.VliveTable, entry.ID, entry)
+			if err != nil {
+				return err
+			}
 		}
 	}
-}
 
-func (r *VLive) feedWorker(id int, jobs <-chan map[string][]models.VliveEntry, results chan<- int) {
-	defer helpers.Recover()
+	return nil
+}
 
-	for job := range jobs {
-		//cache.GetLogger().WithField("module", "vlive").WithField("worker", id).Infof("worker %d started for %d channels", id, len(job))
-		for channelCode, entries := range job {
-			//cache.GetLogger().WithField("module", "vlive").WithField("worker", id).Info(fmt.Sprintf("checking V Live Channel %s for %d channels", entries[0].VLiveChannel.Name, len(entries)))
-			updatedVliveChannel, err := r.getVLiveChannelByVliveChannelId(channelCode)
-			if err != nil {
-				cache.GetLogger().WithField("module", "vlive").WithField("worker", id).Warnf("updating vlive channel %s failed: %s", channelCode, err.Error())
-				continue
-			}
-			for _, entry := range entries {
-				changes := false
+// canPostToChannel checks if the channel exists and the bot can post embeds to it
+func (r *VLive) canPostToChannel(channelID string) bool {
+	channel, err := helpers.GetChannelWithoutApi(channelID)
+	if err != nil || channel == nil || channel.ID == "" {
+		return false
+	}
 
-				for _, vod := range updatedVliveChannel.VOD {
-					// don't post playlists
-					if vod.Type == "PLAYLIST" {
-						continue
-					}
-					videoAlreadyPosted := false
-					for _, postedVod := range entry.PostedVOD {
-						if postedVod.Seq == vod.Seq {
-							videoAlreadyPosted = true
-						}
-					}
-					if videoAlreadyPosted == false {
-						// cache.GetLogger().WithField("module", "vlive").WithField("worker", id).Info(fmt.Sprintf("Posting VOD: #%d", vod.Seq))
-						entry.PostedVOD = append(entry.PostedVOD, vod)
-						changes = true
-						go r.postVodToChannel(entry, vod, updatedVliveChannel)
-					}
-				}
-				for _, upcoming := range updatedVliveChannel.Upcoming {
-					videoAlreadyPosted := false
-					for _, postedUpcoming := range entry.PostedUpcoming {
-						if postedUpcoming.Seq == upcoming.Seq {
-							videoAlreadyPosted = true
-						}
-					}
-					if videoAlreadyPosted == false {
-						// cache.GetLogger().WithField("module", "vlive").WithField("worker", id).Info(fmt.Sprintf("Posting Upcoming: #%d", upcoming.Seq))
-						entry.PostedUpcoming = append(entry.PostedUpcoming, upcoming)
-						changes = true
-						go r.postUpcomingToChannel(entry, upcoming, updatedVliveChannel)
-					}
-				}
-				for _, live := range updatedVliveChannel.Live {
-					videoAlreadyPosted := false
-					for _, postedLive := range entry.PostedLive {
-						if postedLive.Seq == live.Seq {
-							videoAlreadyPosted = true
-						}
-					}
-					if videoAlreadyPosted == false {
-						// cache.GetLogger().WithField("module", "vlive").WithField("worker", id).Info(fmt.Sprintf("Posting Live: #%d", live.Seq))
-						entry.PostedLive = append(entry.PostedLive, live)
-						changes = true
-						go r.postLiveToChannel(entry, live, updatedVliveChannel)
-					}
-				}
-				for _, notice := range updatedVliveChannel.Notices {
-					noticeAlreadyPosted := false
-					for _, postedNotice := range entry.PostedNotices {
-						if postedNotice.Number == notice.Number {
-							noticeAlreadyPosted = true
-						}
-					}
-					if noticeAlreadyPosted == false {
-						// cache.GetLogger().WithField("module", "vlive").WithField("worker", id).Info(fmt.Sprintf("Posting Notice: #%d", notice.Number))
-						entry.PostedNotices = append(entry.PostedNotices, notice)
-						changes = true
-						go r.postNoticeToChannel(entry, notice, updatedVliveChannel)
-					}
-				}
-				for _, celeb := range updatedVliveChannel.Celebs {
-					celebAlreadyPosted := false
-					for _, postedCeleb := range entry.PostedCelebs {
-						if postedCeleb.ID == celeb.ID {
-							celebAlreadyPosted = true
-						}
-					}
-					if celebAlreadyPosted == false {
-						// cache.GetLogger().WithField("module", "vlive").WithField("worker", id).Info(fmt.Sprintf("Posting Celeb: #%s", celeb.ID))
-						entry.PostedCelebs = append(entry.PostedCelebs, celeb)
-						changes = true
-						go r.postCelebToChannel(entry, celeb, updatedVliveChannel)
-					}
-				}
-				if changes == true {
-					err = helpers.MDbUpdateWithoutLogging(models.VliveTable, entry.ID, entry)
-					helpers.Relax(err)
-				}
-			}
-		}
+	channelPermission, err := cache.GetSession().State.UserChannelPermissions(cache.GetSession().State.User.ID, channel.ID)
+	if err != nil {
+		return false
 	}
-	results <- len(jobs)
+
+	return channelPermission&discordgo.PermissionSendMessages == discordgo.PermissionSendMessages &&
+		channelPermission&discordgo.PermissionEmbedLinks == discordgo.PermissionEmbedLinks
 }
 
 func (r *VLive) Action(command string, content string, msg *discordgo.Message, session *discordgo.Session) {
@@ -241,7 +205,7 @@ func (r *VLive) Action(command string, content string, msg *discordgo.Message, s
 	args := strings.Fields(content)
 	if len(args) >= 1 {
 		switch args[0] {
-		case "add": // [p]vlive add <vlive channel name/vlive channel id> <discord channel> [<Name or ID of the role to mention>]
+		case "add": // [p]vlive add <vlive channel name/vlive channel id> <discord channel> [<Name or ID of the role to mention>] [feed options]
 			helpers.RequireMod(msg, func() {
 				session.ChannelTyping(msg.ChannelID)
 				// get target channel
@@ -261,9 +225,14 @@ func (r *VLive) Action(command string, content string, msg *discordgo.Message, s
 				targetGuild, err = helpers.GetGuild(targetChannel.GuildID)
 				helpers.Relax(err)
 
+				feedOptions, rest, err := helpers.ParseFeedOptions(targetGuild.ID, args[3:], content)
+				if err != nil {
+					helpers.SendMessage(msg.ChannelID, helpers.GetTextF("bot.arguments.invalid-feed-options", err.Error()))
+					return
+				}
 				mentionRole := new(discordgo.Role)
-				if len(args) >= 4 {
-					mentionRoleName := args[3]
+				if len(rest) >= 1 {
+					mentionRoleName := rest[0]
 					serverRoles, err := session.GuildRoles(targetGuild.ID)
 					if err != nil {
 						if errD, ok := err.(*discordgo.RESTError); ok {
@@ -302,6 +271,9 @@ func (r *VLive) Action(command string, content string, msg *discordgo.Message, s
 					helpers.SendMessage(msg.ChannelID, helpers.GetTextF("plugins.vlive.channel-not-found"))
 					return
 				}
+				if mentionRole.ID != "" {
+					feedOptions.MentionRoleID = mentionRole.ID
+				}
 				// create new entry in db
 				newID, err := helpers.MDbInsert(models.VliveTable, models.VliveEntry{
 					GuildID:        targetChannel.GuildID,
@@ -312,7 +284,7 @@ func (r *VLive) Action(command string, content string, msg *discordgo.Message, s
 					PostedLive:     vliveChannel.Live,
 					PostedNotices:  vliveChannel.Notices,
 					PostedCelebs:   vliveChannel.Celebs,
-					MentionRoleID:  mentionRole.ID,
+					FeedOptions:    feedOptions,
 				})
 				helpers.Relax(err)
 
@@ -320,7 +292,7 @@ func (r *VLive) Action(command string, content string, msg *discordgo.Message, s
 					models.EventlogTargetTypeRobyulVliveFeed, msg.Author.ID,
 					models.EventlogTypeRobyulVliveFeedAdd, "",
 					nil,
-					[]models.ElasticEventlogOption{
+					append([]models.ElasticEventlogOption{
 						{
 							Key:   "vlive_feed_channelid",
 							Value: targetChannel.ID,
@@ -334,12 +306,7 @@ func (r *VLive) Action(command string, content string, msg *discordgo.Message, s
 							Key:   "vlive_feed_vlivechannel_code",
 							Value: vliveChannel.Code,
 						},
-						{
-							Key:   "vlive_feed_mentionroleid",
-							Value: mentionRole.ID,
-							Type:  models.EventlogTargetTypeRole,
-						},
-					}, false)
+					}, helpers.FeedOptionsEventlogOptions("vlive_feed", feedOptions)...), false)
 				helpers.RelaxLog(err)
 
 				successMessage := helpers.GetTextF("plugins.vlive.channel-added-success", vliveChannel.Name, targetChannel.ID)
@@ -379,7 +346,7 @@ func (r *VLive) Action(command string, content string, msg *discordgo.Message, s
 						models.EventlogTargetTypeRobyulVliveFeed, msg.Author.ID,
 						models.EventlogTypeRobyulVliveFeedRemove, "",
 						nil,
-						[]models.ElasticEventlogOption{
+						append([]models.ElasticEventlogOption{
 							{
 								Key:   "vlive_feed_channelid",
 								Value: helpers.MdbIdToHuman(entryBucket.ID),
@@ -393,12 +360,7 @@ func (r *VLive) Action(command string, content string, msg *discordgo.Message, s
 								Key:   "vlive_feed_vlivechannel_code",
 								Value: entryBucket.VLiveChannel.Code,
 							},
-							{
-								Key:   "vlive_feed_mentionroleid",
-								Value: entryBucket.MentionRoleID,
-								Type:  models.EventlogTargetTypeRole,
-							},
-						}, false)
+						}, helpers.FeedOptionsEventlogOptions("vlive_feed", entryBucket.FeedOptions)...), false)
 					helpers.RelaxLog(err)
 
 					helpers.SendMessage(msg.ChannelID, helpers.GetTextF("plugins.vlive.channel-delete-success", entryBucket.VLiveChannel.Name))
@@ -422,13 +384,8 @@ func (r *VLive) Action(command string, content string, msg *discordgo.Message, s
 
 			resultMessage := ""
 			for _, entry := range entryBucket {
-				mentionText := ""
-				if entry.MentionRoleID != "" {
-					role, err := session.State.Role(currentChannel.GuildID, entry.MentionRoleID)
-					helpers.Relax(err)
-					mentionText += fmt.Sprintf(" mentioning `@%s`", role.Name)
-				}
-				resultMessage += fmt.Sprintf("`%s`: V Live Channel `%s` posting to <#%s>%s\n", helpers.MdbIdToHuman(entry.ID), entry.VLiveChannel.Name, entry.ChannelID, mentionText)
+				resultMessage += fmt.Sprintf("`%s`: V Live Channel `%s` posting to <#%s>%s\n", helpers.MdbIdToHuman(entry.ID), entry.VLiveChannel.Name, entry.ChannelID,
+					helpers.FeedOptionsText(currentChannel.GuildID, entry.FeedOptions))
 			}
 			resultMessage += fmt.Sprintf("Found **%d** V Live Channels in total.", len(entryBucket))
 			for _, resultPage := range helpers.Pagify(resultMessage, "\n") {
@@ -703,14 +660,19 @@ func (r *VLive) postVodToChannel(entry models.VliveEntry, vod models.VliveVideoI
 		Image:       &discordgo.MessageEmbedImage{URL: vod.Thumbnail},
 		Color:       helpers.GetDiscordColorFromHex(vliveChannel.Color),
 	}
-	mentionText := ""
-	if entry.MentionRoleID != "" {
-		mentionText = fmt.Sprintf("<@&%s>\n", entry.MentionRoleID)
+	post := helpers.FeedPost{
+		Author:   vliveChannel.Name,
+		Title:    vod.Title,
+		URL:      vod.Url,
+		MediaURL: vod.Thumbnail,
+	}
+	if !helpers.FeedPostMatches(entry.FeedOptions, post) {
+		return
 	}
-	helpers.SendComplex(entry.ChannelID, &discordgo.MessageSend{
-		Content: mentionText + fmt.Sprintf("<%s>", vod.Url),
+	helpers.SendComplex(entry.ChannelID, helpers.FeedMessageSend(entry.FeedOptions, post, &discordgo.MessageSend{
+		Content: fmt.Sprintf("<%s>", vod.Url),
 		Embed:   channelEmbed,
-	})
+	}))
 	// if err != nil {
 	//	 cache.GetLogger().WithField("module", "vlive").Warnf("posting vod: #%d to channel: #%s failed: %s", vod.Seq, entry.ChannelID, err)
 	// }
@@ -729,15 +691,19 @@ func (r *VLive) postUpcomingToChannel(entry models.VliveEntry, vod models.VliveV
 		Image:       &discordgo.MessageEmbedImage{URL: vod.Thumbnail},
 		Color:       helpers.GetDiscordColorFromHex(vliveChannel.Color),
 	}
-	mentionText := ""
-	if entry.MentionRoleID != "" {
-		mentionText = fmt.Sprintf("<@&%s>\n", entry.MentionRoleID)
+	post := helpers.FeedPost{
+		Author:   vliveChannel.Name,
+		Title:    vod.Title,
+		URL:      vliveChannel.Url,
+		MediaURL: vod.Thumbnail,
+	}
+	if !helpers.FeedPostMatches(entry.FeedOptions, post) {
+		return
 	}
-	postText := fmt.Sprintf("<%s>", vliveChannel.Url)
-	helpers.SendComplex(entry.ChannelID, &discordgo.MessageSend{
-		Content: mentionText + postText,
+	helpers.SendComplex(entry.ChannelID, helpers.FeedMessageSend(entry.FeedOptions, post, &discordgo.MessageSend{
+		Content: fmt.Sprintf("<%s>", vliveChannel.Url),
 		Embed:   channelEmbed,
-	})
+	}))
 	// if err != nil {
 	// 	cache.GetLogger().WithField("module", "vlive").Warnf("posting upcoming: #%d to channel: #%s failed: %s", vod.Seq, entry.ChannelID, err)
 	// }
@@ -756,14 +722,19 @@ func (r *VLive) postLiveToChannel(entry models.VliveEntry, vod models.VliveVideo
 		Image:       &discordgo.MessageEmbedImage{URL: vod.Thumbnail},
 		Color:       helpers.GetDiscordColorFromHex(vliveChannel.Color),
 	}
-	mentionText := ""
-	if entry.MentionRoleID != "" {
-		mentionText = fmt.Sprintf("<@&%s>\n", entry.MentionRoleID)
+	post := helpers.FeedPost{
+		Author:   vliveChannel.Name,
+		Title:    vod.Title,
+		URL:      vod.Url,
+		MediaURL: vod.Thumbnail,
 	}
-	helpers.SendComplex(entry.ChannelID, &discordgo.MessageSend{
-		Content: mentionText + fmt.Sprintf("<%s>", vod.Url),
+	if !helpers.FeedPostMatches(entry.FeedOptions, post) {
+		return
+	}
+	helpers.SendComplex(entry.ChannelID, helpers.FeedMessageSend(entry.FeedOptions, post, &discordgo.MessageSend{
+		Content: fmt.Sprintf("<%s>", vod.Url),
 		Embed:   channelEmbed,
-	})
+	}))
 	// if err != nil {
 	// 	cache.GetLogger().WithField("module", "vlive").Warnf("posting live: #%d to channel: #%s failed: %s", vod.Seq, entry.ChannelID, err)
 	// }
@@ -782,14 +753,19 @@ func (r *VLive) postNoticeToChannel(entry models.VliveEntry, notice models.Vlive
 		Image:       &discordgo.MessageEmbedImage{URL: notice.ImageUrl},
 		Color:       helpers.GetDiscordColorFromHex(vliveChannel.Color),
 	}
-	mentionText := ""
-	if entry.MentionRoleID != "" {
-		mentionText = fmt.Sprintf("<@&%s>\n", entry.MentionRoleID)
+	post := helpers.FeedPost{
+		Author:   vliveChannel.Name,
+		Title:    notice.Title,
+		URL:      notice.Url,
+		MediaURL: notice.ImageUrl,
 	}
-	helpers.SendComplex(entry.ChannelID, &discordgo.MessageSend{
-		Content: mentionText + fmt.Sprintf("<%s>", notice.Url),
+	if !helpers.FeedPostMatches(entry.FeedOptions, post) {
+		return
+	}
+	helpers.SendComplex(entry.ChannelID, helpers.FeedMessageSend(entry.FeedOptions, post, &discordgo.MessageSend{
+		Content: fmt.Sprintf("<%s>", notice.Url),
 		Embed:   channelEmbed,
-	})
+	}))
 	// if err != nil {
 	// 	cache.GetLogger().WithField("module", "vlive").Warnf("posting notice: #%d to channel: #%s failed: %s", notice.Number, entry.ChannelID, err)
 	// }
@@ -807,14 +783,18 @@ func (r *VLive) postCelebToChannel(entry models.VliveEntry, celeb models.VliveCe
 		Description: fmt.Sprintf("%s ...", celeb.Summary),
 		Color:       helpers.GetDiscordColorFromHex(vliveChannel.Color),
 	}
-	mentionText := ""
-	if entry.MentionRoleID != "" {
-		mentionText = fmt.Sprintf("<@&%s>\n", entry.MentionRoleID)
+	post := helpers.FeedPost{
+		Author:  vliveChannel.Name,
+		Content: celeb.Summary,
+		URL:     celeb.Url,
 	}
-	helpers.SendComplex(entry.ChannelID, &discordgo.MessageSend{
-		Content: mentionText + fmt.Sprintf("<%s>", celeb.Url),
+	if !helpers.FeedPostMatches(entry.FeedOptions, post) {
+		return
+	}
+	helpers.SendComplex(entry.ChannelID, helpers.FeedMessageSend(entry.FeedOptions, post, &discordgo.MessageSend{
+		Content: fmt.Sprintf("<%s>", celeb.Url),
 		Embed:   channelEmbed,
-	})
+	}))
 	// if err != nil {
 	// 	cache.GetLogger().WithField("module", "vlive").Warnf("posting celeb: #%s to channel: #%s failed: %s", celeb.ID, entry.ChannelID, err)
 	// }
@@ -29,6 +29,36 @@ func (w *Weather) Commands() []string {
 	}
 }
 
+func (w *Weather) CommandDescriptors() []models.CommandDescriptor {
+	return []models.CommandDescriptor{
+		{
+			Name:        "weather",
+			Description: "Shows the weather forecast for a location. Without a location your last location will be used.",
+			Usage:       "[<location>]",
+			Arguments: []models.CommandArgument{
+				{Name: "location", Description: "The location to show the forecast for", Type: models.CommandArgumentTypeText, Variadic: true},
+			},
+			Module: helpers.ModulePermWeather,
+		},
+	}
+}
+
+func (w *Weather) InteractionCommands() []models.InteractionCommand {
+	return []models.InteractionCommand{
+		{
+			Name:        "weather",
+			Description: "Shows the weather forecast for a location",
+			Options: []models.InteractionCommandOption{
+				{
+					Type:        models.InteractionCommandOptionTypeString,
+					Name:        "location",
+					Description: "The location, defaults to your last location",
+				},
+			},
+		},
+	}
+}
+
 func (w *Weather) Init(session *discordgo.Session) {
 	w.darkSkyClient = darksky.New(helpers.GetConfig().Path("darksky.api_key").Data().(string))
 }
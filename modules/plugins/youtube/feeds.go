@@ -5,11 +5,12 @@ import (
 	"sync/atomic"
 	"time"
 
-	"gopkg.in/mgo.v2/bson"
+	"github.com/globalsign/mgo/bson"
 
 	youtubeService "github.com/Seklfreak/Robyul2/services/youtube"
 
 	"github.com/Seklfreak/Robyul2/cache"
+	"github.com/Seklfreak/Robyul2/feedpoller"
 	"github.com/Seklfreak/Robyul2/helpers"
 	"github.com/Seklfreak/Robyul2/models"
 	"github.com/bwmarrin/discordgo"
@@ -21,79 +22,94 @@ type feeds struct {
 	running uint32
 }
 
+// video is a new upload of a YouTube channel, from the activities API or a WebSub notification
+type video struct {
+	ID           string
+	ChannelID    string
+	ChannelTitle string
+	Title        string
+	Description  string
+	ThumbnailURL string
+}
+
+const (
+	// postedVideoKey is claimed when posting a video to a feed, so polling and WebSub post it once
+	postedVideoKey        = "robyul2-discord:youtube:feed:%s:posted:%s"
+	postedVideoExpiration = 7 * 24 * time.Hour
+)
+
 func (f *feeds) Init(e *youtubeService.Service) {
 	if e == nil {
 		helpers.Relax(fmt.Errorf("feeds loop initialize failed"))
 	}
 	f.service = e
 
-	f.start()
-}
-
-func (f *feeds) start() {
-	// Checks if feeds loop is already running
+	// Checks if the feeds are already polled, restarting the service only replaces it
 	if atomic.SwapUint32(&f.running, uint32(1)) == 1 {
-		logger().Error("feeds loop already running")
 		return
 	}
 
-	go f.run()
-}
-
-func (f *feeds) run() {
-	defer helpers.Recover()
-	defer func() {
-		// Atomically set 'running' variable to 0(false)
-		atomic.StoreUint32(&f.running, uint32(0))
+	feedpoller.Start(f, feedpoller.Options{
+		Name:       "youtube",
+		Interval:   time.Minute,
+		Jitter:     0.2,
+		Workers:    5,
+		MaxBackoff: time.Hour,
+	})
 
-		logger().Error("The feeds loop died. Please investigate! Will be restarted in 60 seconds")
-		time.Sleep(60 * time.Second)
-
-		f.start()
-	}()
+	if WebSubEnabled() {
+		startWebSub(f)
+	}
+}
 
-	for ; ; time.Sleep(10 * time.Second) {
-		err := f.service.UpdateCheckingInterval()
-		helpers.Relax(err)
+// Interval returns the checking interval allowed by the remaining API quota
+func (f *feeds) Interval() time.Duration {
+	err := f.service.UpdateCheckingInterval()
+	helpers.RelaxLog(err)
 
-		f.check()
-	}
+	return f.checkingInterval()
 }
 
-func (f *feeds) check() {
-	t := time.Now().Unix()
+// Targets returns the IDs of all feeds that are due
+func (f *feeds) Targets() (targets []string, err error) {
 	var entries []models.YoutubeChannelEntry
-	err := helpers.MDbIterWithoutLogging(helpers.MdbCollection(models.YoutubeChannelTable).Find(
-		bson.M{"nextchecktime": bson.M{"$lte": t}},
-	)).All(&entries)
-	helpers.Relax(err)
-	// TODO: test
+	err = helpers.MDbIterWithoutLogging(helpers.MdbCollection(models.YoutubeChannelTable).Find(
+		bson.M{"nextchecktime": bson.M{"$lte": time.Now().Unix()}},
+	).Select(bson.M{"_id": 1})).All(&entries)
+	if err != nil {
+		return nil, err
+	}
 
 	for _, e := range entries {
-		e = f.checkChannelFeeds(e)
-
-		// update next check time
-		e = f.setNextCheckTime(e)
-		err = helpers.MDbUpdateWithoutLogging(models.YoutubeChannelTable, e.ID, e)
-		helpers.Relax(err)
+		targets = append(targets, helpers.MdbIdToHuman(e.ID))
 	}
-}
 
-func (f *feeds) checkChannelFeeds(e models.YoutubeChannelEntry) models.YoutubeChannelEntry {
-	// check if we have access to channel
-	channel, err := helpers.GetChannelWithoutApi(e.ChannelID)
-	if err != nil || channel == nil || channel.ID == "" {
-		return e
-	}
+	return targets, nil
+}
 
-	// check if we can send messages and embed links in channel
-	channelPermission, err := cache.GetSession().State.UserChannelPermissions(cache.GetSession().State.User.ID, channel.ID)
+// Check posts new videos of the feed and sets its next check time
+func (f *feeds) Check(id string) (err error) {
+	var e models.YoutubeChannelEntry
+	err = helpers.MdbOneWithoutLogging(
+		helpers.MdbCollection(models.YoutubeChannelTable).Find(bson.M{"_id": helpers.HumanToMdbId(id)}),
+		&e,
+	)
 	if err != nil {
-		return e
+		if helpers.IsMdbNotFound(err) {
+			return nil
+		}
+		return err
 	}
 
-	if channelPermission&discordgo.PermissionSendMessages != discordgo.PermissionSendMessages ||
-		channelPermission&discordgo.PermissionEmbedLinks != discordgo.PermissionEmbedLinks {
+	e = f.checkChannelFeeds(e)
+
+	// update next check time
+	e = f.setNextCheckTime(e)
+	return helpers.MDbUpdateWithoutLogging(models.YoutubeChannelTable, e.ID, e)
+}
+
+func (f *feeds) checkChannelFeeds(e models.YoutubeChannelEntry) models.YoutubeChannelEntry {
+	if !f.canPostToChannel(e.ChannelID) {
 		return e
 	}
 
@@ -129,35 +145,26 @@ func (f *feeds) checkChannelFeeds(e models.YoutubeChannelEntry) models.YoutubeCh
 			continue
 		}
 
-		// make a message and send to discord channel
-		msg := &discordgo.MessageSend{
-			Content: fmt.Sprintf(youtubeVideoBaseUrl, videoId),
-			Embed: &discordgo.MessageEmbed{
-				Author: &discordgo.MessageEmbedAuthor{
-					Name: feed.Snippet.ChannelTitle,
-					URL:  fmt.Sprintf(youtubeChannelBaseUrl, feed.Snippet.ChannelId),
-				},
-				Title:       helpers.GetTextF("plugins.youtube.channel-embed-title-vod", feed.Snippet.ChannelTitle),
-				URL:         fmt.Sprintf(youtubeVideoBaseUrl, videoId),
-				Description: fmt.Sprintf("**%s**", feed.Snippet.Title),
-				Image:       &discordgo.MessageEmbedImage{URL: feed.Snippet.Thumbnails.High.Url},
-				Footer:      &discordgo.MessageEmbedFooter{Text: "YouTube"},
-				Color:       helpers.GetDiscordColorFromHex(youtubeColor),
-			},
-		}
-
-		_, err = helpers.SendComplex(e.ChannelID, msg)
+		var posted bool
+		posted, err = f.postVideo(e, video{
+			ID:           videoId,
+			ChannelID:    feed.Snippet.ChannelId,
+			ChannelTitle: feed.Snippet.ChannelTitle,
+			Title:        feed.Snippet.Title,
+			Description:  feed.Snippet.Description,
+			ThumbnailURL: feed.Snippet.Thumbnails.High.Url,
+		})
 		if err != nil {
 			logger().Warn(err)
 			break
 		}
 
+		// videos posted by a WebSub notification count as already posted
+		if !posted {
+			alreadyPostedVideos = append(alreadyPostedVideos, videoId)
+			continue
+		}
 		newPostedVideos = append(newPostedVideos, videoId)
-
-		logger().WithFields(logrus.Fields{
-			"title":   feed.Snippet.Title,
-			"channel": e.ChannelID,
-		}).Info("posting video")
 	}
 
 	if err == nil {
@@ -169,14 +176,95 @@ func (f *feeds) checkChannelFeeds(e models.YoutubeChannelEntry) models.YoutubeCh
 	return e
 }
 
+// canPostToChannel checks if we can send messages and embed links in the channel
+func (f *feeds) canPostToChannel(channelID string) bool {
+	channel, err := helpers.GetChannelWithoutApi(channelID)
+	if err != nil || channel == nil || channel.ID == "" {
+		return false
+	}
+
+	channelPermission, err := cache.GetSession().State.UserChannelPermissions(cache.GetSession().State.User.ID, channel.ID)
+	if err != nil {
+		return false
+	}
+
+	return channelPermission&discordgo.PermissionSendMessages == discordgo.PermissionSendMessages &&
+		channelPermission&discordgo.PermissionEmbedLinks == discordgo.PermissionEmbedLinks
+}
+
+// postVideo posts the video to the feed, posted is false if the video has been posted by polling or WebSub already
+// filtered videos count as posted
+func (f *feeds) postVideo(e models.YoutubeChannelEntry, v video) (posted bool, err error) {
+	postedKey := fmt.Sprintf(postedVideoKey, helpers.MdbIdToHuman(e.ID), v.ID)
+	claimed, err := cache.GetRedisClient().SetNX(postedKey, time.Now().Unix(), postedVideoExpiration).Result()
+	if err != nil {
+		return false, err
+	}
+	if !claimed {
+		return false, nil
+	}
+
+	post := helpers.FeedPost{
+		Author:   v.ChannelTitle,
+		Title:    v.Title,
+		URL:      fmt.Sprintf(youtubeVideoBaseUrl, v.ID),
+		Content:  v.Description,
+		MediaURL: v.ThumbnailURL,
+	}
+	if !helpers.FeedPostMatches(e.FeedOptions, post) {
+		return true, nil
+	}
+
+	// make a message and send to discord channel
+	msg := &discordgo.MessageSend{
+		Content: fmt.Sprintf(youtubeVideoBaseUrl, v.ID),
+		Embed: &discordgo.MessageEmbed{
+			Author: &discordgo.MessageEmbedAuthor{
+				Name: v.ChannelTitle,
+				URL:  fmt.Sprintf(youtubeChannelBaseUrl, v.ChannelID),
+			},
+			Title:       helpers.GetTextF("plugins.youtube.channel-embed-title-vod", v.ChannelTitle),
+			URL:         fmt.Sprintf(youtubeVideoBaseUrl, v.ID),
+			Description: fmt.Sprintf("**%s**", v.Title),
+			Image:       &discordgo.MessageEmbedImage{URL: v.ThumbnailURL},
+			Footer:      &discordgo.MessageEmbedFooter{Text: "YouTube"},
+			Color:       helpers.GetDiscordColorFromHex(youtubeColor),
+		},
+	}
+
+	_, err = helpers.SendComplex(e.ChannelID, helpers.FeedMessageSend(e.FeedOptions, post, msg))
+	if err != nil {
+		// allow the next check to post the video
+		helpers.RelaxLog(cache.GetRedisClient().Del(postedKey).Err())
+		return false, err
+	}
+
+	logger().WithFields(logrus.Fields{
+		"title":   v.Title,
+		"channel": e.ChannelID,
+	}).Info("posting video")
+
+	return true, nil
+}
+
 func (f *feeds) setNextCheckTime(e models.YoutubeChannelEntry) models.YoutubeChannelEntry {
 	e.NextCheckTime = time.Now().
-		Add(time.Duration(f.service.GetCheckingInterval()) * time.Second).
+		Add(f.checkingInterval()).
 		Unix()
 
 	return e
 }
 
+// checkingInterval is the polling interval allowed by the remaining API quota
+// polling is only a fallback for missed notifications while WebSub is enabled
+func (f *feeds) checkingInterval() time.Duration {
+	interval := time.Duration(f.service.GetCheckingInterval()) * time.Second
+	if WebSubEnabled() && interval < websubPollingInterval {
+		return websubPollingInterval
+	}
+	return interval
+}
+
 func (f *feeds) isPosted(id string, postedIds []string) bool {
 	for _, posted := range postedIds {
 		if id == posted {
@@ -189,19 +189,33 @@ func (h *Handler) actionChannel(args []string, in *discordgo.Message, out **disc
 	return h.actionFinish
 }
 
-// _yt channel add <channel id/link/search keywords> <discord channel>
+// _yt channel add <channel id/link/search keywords> <discord channel> [feed options]
 func (h *Handler) actionAddChannel(args []string, in *discordgo.Message, out **discordgo.MessageSend) action {
-	if len(args) < 4 {
-		*out = h.newMsg("bot.arguments.too-few")
-		return h.actionFinish
-	}
-
 	// check permission
 	if helpers.IsMod(in) == false {
 		*out = h.newMsg("mod.no_permission")
 		return h.actionFinish
 	}
 
+	ch, err := helpers.GetChannel(in.ChannelID)
+	if err != nil {
+		*out = h.newMsg(err.Error())
+		return h.actionFinish
+	}
+
+	// strip feed options, the discord channel is the last argument before them
+	options, rest, err := helpers.ParseFeedOptions(ch.GuildID, args[2:], in.Content)
+	if err != nil {
+		*out = h.newMsg("bot.arguments.invalid-feed-options", err.Error())
+		return h.actionFinish
+	}
+	args = append(args[:2], rest...)
+
+	if len(args) < 4 {
+		*out = h.newMsg("bot.arguments.too-few")
+		return h.actionFinish
+	}
+
 	// check discord channel
 	dc, err := helpers.GetChannelFromMention(in, args[len(args)-1])
 	if err != nil {
@@ -245,6 +259,7 @@ func (h *Handler) actionAddChannel(args []string, in *discordgo.Message, out **d
 
 		YoutubeChannelID:   channelId,
 		YoutubeChannelName: channelTitle,
+		FeedOptions:        options,
 	}
 
 	if entry.YoutubeChannelID == "" || entry.YoutubeChannelName == "" {
@@ -266,7 +281,7 @@ func (h *Handler) actionAddChannel(args []string, in *discordgo.Message, out **d
 		models.EventlogTargetTypeRobyulYouTubeChannelFeed, in.Author.ID,
 		models.EventlogTypeRobyulYouTubeChannelFeedAdd, "",
 		nil,
-		[]models.ElasticEventlogOption{
+		append([]models.ElasticEventlogOption{
 			{
 				Key:   "youtube_channel_channelid",
 				Value: dc.ID,
@@ -280,7 +295,7 @@ func (h *Handler) actionAddChannel(args []string, in *discordgo.Message, out **d
 				Key:   "youtube_channel_ytchannelname",
 				Value: channelTitle,
 			},
-		}, false)
+		}, helpers.FeedOptionsEventlogOptions("youtube_channel", options)...), false)
 	helpers.RelaxLog(err)
 
 	*out = h.newMsg("plugins.youtube.channel-added-success", channelTitle, dc.ID)
@@ -369,7 +384,8 @@ func (h *Handler) actionListChannel(args []string, in *discordgo.Message, out **
 
 	msg := ""
 	for _, e := range entries {
-		msg += helpers.GetTextF("plugins.youtube.channel-list-entry", helpers.MdbIdToHuman(e.ID), e.YoutubeChannelName, e.ChannelID)
+		msg += helpers.GetTextF("plugins.youtube.channel-list-entry", helpers.MdbIdToHuman(e.ID), e.YoutubeChannelName, e.ChannelID,
+			helpers.FeedOptionsText(ch.GuildID, e.FeedOptions))
 	}
 
 	for _, resultPage := range helpers.Pagify(msg, "\n") {
@@ -0,0 +1,350 @@
+package youtube
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"hash"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Seklfreak/Robyul2/cache"
+	"github.com/Seklfreak/Robyul2/feedpoller"
+	"github.com/Seklfreak/Robyul2/helpers"
+	"github.com/Seklfreak/Robyul2/metrics"
+	"github.com/Seklfreak/Robyul2/models"
+	"github.com/globalsign/mgo/bson"
+)
+
+const (
+	websubHubUrl   = "https://pubsubhubbub.appspot.com/subscribe"
+	websubTopicUrl = "https://www.youtube.com/xml/feeds/videos.xml?channel_id=%s"
+
+	// websubLeasesKey is a hash of the YouTube channel IDs and the unix time their subscription expires at
+	websubLeasesKey = "robyul2-discord:youtube:websub:leases"
+	// websubPendingKey is set while a subscribe or unsubscribe request waits for the confirmation of the hub, by mode and YouTube channel ID
+	websubPendingKey = "robyul2-discord:youtube:websub:pending:%s:%s"
+	// websubPendingExpiration is the time the hub has to confirm a request
+	websubPendingExpiration = time.Hour
+	// websubLeaseSeconds is the requested lease, the hub can grant a shorter one, longer leases are capped to it
+	websubLeaseSeconds = 5 * 24 * 60 * 60
+	// websubRenewBefore is the time before the end of a lease the subscription is renewed
+	websubRenewBefore = 24 * time.Hour
+	// websubPollingInterval is the shortest polling interval while WebSub is enabled
+	websubPollingInterval = time.Hour
+	// websubMaxVideoAge prevents posting old videos, the hub sends notifications for title and description changes too
+	websubMaxVideoAge = 24 * time.Hour
+
+	youtubeThumbnailUrl = "https://i.ytimg.com/vi/%s/hqdefault.jpg"
+)
+
+var (
+	// websubFeeds receives the notifications, it is set once the feeds are running
+	websubFeeds *feeds
+
+	errWebSubNotRunning = errors.New("youtube feeds are not running")
+)
+
+// websubNotification is the Atom feed the hub posts for new or changed videos
+type websubNotification struct {
+	Entries []websubEntry `xml:"entry"`
+}
+
+type websubEntry struct {
+	VideoID   string    `xml:"http://www.youtube.com/xml/schemas/2015 videoId"`
+	ChannelID string    `xml:"http://www.youtube.com/xml/schemas/2015 channelId"`
+	Title     string    `xml:"title"`
+	Author    string    `xml:"author>name"`
+	Published time.Time `xml:"published"`
+}
+
+// websubSubscriber subscribes to the YouTube channels of all feeds and renews their leases
+type websubSubscriber struct {
+	client      *http.Client
+	callbackURL string
+	secret      string
+}
+
+// WebSubEnabled checks if new videos are pushed by the WebSub hub, requires youtube.websub_callback_url and youtube.websub_secret
+func WebSubEnabled() bool {
+	config := helpers.GetConfig()
+	return config.ExistsP("youtube.websub_callback_url") &&
+		config.ExistsP("youtube.websub_secret") &&
+		config.Path("youtube.websub_callback_url").Data().(string) != "" &&
+		config.Path("youtube.websub_secret").Data().(string) != ""
+}
+
+func startWebSub(f *feeds) {
+	websubFeeds = f
+
+	feedpoller.Start(&websubSubscriber{
+		client:      &http.Client{Timeout: 15 * time.Second},
+		callbackURL: helpers.GetConfig().Path("youtube.websub_callback_url").Data().(string),
+		secret:      helpers.GetConfig().Path("youtube.websub_secret").Data().(string),
+	}, feedpoller.Options{
+		Name:       "youtube-websub",
+		Interval:   time.Hour,
+		Jitter:     0.2,
+		Workers:    2,
+		MaxBackoff: 12 * time.Hour,
+	})
+}
+
+// Targets returns the YouTube channels without a subscription or with a lease about to expire,
+// and unfollowed YouTube channels with a lease to unsubscribe from
+func (s *websubSubscriber) Targets() (targets []string, err error) {
+	var youtubeChannelIDs []string
+	err = helpers.MdbCollection(models.YoutubeChannelTable).Find(nil).Distinct("youtubechannelid", &youtubeChannelIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	leases, err := cache.GetRedisClient().HGetAll(websubLeasesKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	followed := make(map[string]bool)
+	for _, youtubeChannelID := range youtubeChannelIDs {
+		followed[youtubeChannelID] = true
+
+		expiresAt, _ := strconv.ParseInt(leases[youtubeChannelID], 10, 64)
+		if time.Until(time.Unix(expiresAt, 0)) < websubRenewBefore {
+			targets = append(targets, youtubeChannelID)
+		}
+	}
+
+	for youtubeChannelID, lease := range leases {
+		if followed[youtubeChannelID] {
+			continue
+		}
+
+		expiresAt, _ := strconv.ParseInt(lease, 10, 64)
+		if time.Now().Before(time.Unix(expiresAt, 0)) {
+			targets = append(targets, youtubeChannelID)
+			continue
+		}
+		helpers.RelaxLog(cache.GetRedisClient().HDel(websubLeasesKey, youtubeChannelID).Err())
+	}
+
+	return targets, nil
+}
+
+// Check requests a subscription of a followed YouTube channel, or the unsubscription of an unfollowed one
+// the request is recorded as pending, the hub confirms it asynchronously with VerifyWebSubIntent
+func (s *websubSubscriber) Check(youtubeChannelID string) (err error) {
+	count, err := helpers.MdbCountWithoutLogging(models.YoutubeChannelTable, bson.M{"youtubechannelid": youtubeChannelID})
+	if err != nil {
+		return err
+	}
+	mode := "subscribe"
+	if count <= 0 {
+		mode = "unsubscribe"
+	}
+
+	pendingKey := fmt.Sprintf(websubPendingKey, mode, youtubeChannelID)
+	err = cache.GetRedisClient().Set(pendingKey, time.Now().Unix(), websubPendingExpiration).Err()
+	if err != nil {
+		return err
+	}
+
+	response, err := s.client.PostForm(websubHubUrl, url.Values{
+		"hub.callback":      {s.callbackURL},
+		"hub.topic":         {fmt.Sprintf(websubTopicUrl, youtubeChannelID)},
+		"hub.mode":          {mode},
+		"hub.verify":        {"async"},
+		"hub.lease_seconds": {strconv.Itoa(websubLeaseSeconds)},
+		"hub.secret":        {s.secret},
+	})
+	if err == nil {
+		defer response.Body.Close()
+		if response.StatusCode != http.StatusAccepted && response.StatusCode != http.StatusNoContent {
+			err = fmt.Errorf("%s to %s failed with status %d", mode, youtubeChannelID, response.StatusCode)
+		}
+	}
+	if err != nil {
+		helpers.RelaxLog(cache.GetRedisClient().Del(pendingKey).Err())
+		return err
+	}
+
+	return nil
+}
+
+// VerifyWebSubIntent confirms a subscription or unsubscription of the hub, only requests pending since Check are confirmed,
+// and subscriptions only for followed YouTube channels
+// the lease of confirmed subscriptions is stored for renewal, it is capped to the requested lease
+func VerifyWebSubIntent(mode, topic string, leaseSeconds int) bool {
+	youtubeChannelID := websubTopicChannelID(topic)
+	if youtubeChannelID == "" || (mode != "subscribe" && mode != "unsubscribe") {
+		return false
+	}
+
+	pending, err := cache.GetRedisClient().Del(fmt.Sprintf(websubPendingKey, mode, youtubeChannelID)).Result()
+	if err != nil {
+		helpers.RelaxLog(err)
+		return false
+	}
+	if pending <= 0 {
+		logger().Warnf("refused WebSub %s of %s without a pending request", mode, youtubeChannelID)
+		return false
+	}
+
+	switch mode {
+	case "subscribe":
+		count, err := helpers.MdbCountWithoutLogging(models.YoutubeChannelTable, bson.M{"youtubechannelid": youtubeChannelID})
+		if err != nil || count <= 0 {
+			return false
+		}
+
+		leaseSeconds = websubLease(leaseSeconds)
+		err = cache.GetRedisClient().HSet(websubLeasesKey, youtubeChannelID,
+			time.Now().Add(time.Duration(leaseSeconds)*time.Second).Unix()).Err()
+		helpers.RelaxLog(err)
+
+		logger().Infof("subscribed to WebSub notifications of %s for %d seconds", youtubeChannelID, leaseSeconds)
+		return true
+	case "unsubscribe":
+		helpers.RelaxLog(cache.GetRedisClient().HDel(websubLeasesKey, youtubeChannelID).Err())
+		return true
+	}
+
+	return false
+}
+
+// websubLease returns the lease granted by the hub, capped to the requested lease
+func websubLease(leaseSeconds int) int {
+	if leaseSeconds <= 0 || leaseSeconds > websubLeaseSeconds {
+		return websubLeaseSeconds
+	}
+	return leaseSeconds
+}
+
+// websubTopicChannelID returns the YouTube channel ID of a topic URL, or an empty string for other topics
+func websubTopicChannelID(topic string) string {
+	topicURL, err := url.Parse(topic)
+	if err != nil || topicURL.Host != "www.youtube.com" || topicURL.Path != "/xml/feeds/videos.xml" {
+		return ""
+	}
+
+	return topicURL.Query().Get("channel_id")
+}
+
+// VerifyWebSubSignature checks the X-Hub-Signature of a notification, the HMAC of the body using the secret
+func VerifyWebSubSignature(signature string, body []byte) bool {
+	if !WebSubEnabled() {
+		return false
+	}
+
+	return verifyWebSubSignature(helpers.GetConfig().Path("youtube.websub_secret").Data().(string), signature, body)
+}
+
+func verifyWebSubSignature(secret, signature string, body []byte) bool {
+	parts := strings.SplitN(signature, "=", 2)
+	if len(parts) != 2 {
+		return false
+	}
+
+	var newHash func() hash.Hash
+	switch parts[0] {
+	case "sha1":
+		newHash = sha1.New
+	case "sha256":
+		newHash = sha256.New
+	case "sha512":
+		newHash = sha512.New
+	default:
+		return false
+	}
+
+	expected, err := hex.DecodeString(parts[1])
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(newHash, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
+
+// ReceiveWebSubNotification posts the new videos of a notification to all feeds of their YouTube channel
+// the videos are posted in the background, so the hub gets a response in time
+func ReceiveWebSubNotification(body []byte) (err error) {
+	if websubFeeds == nil {
+		return errWebSubNotRunning
+	}
+
+	entries, err := parseWebSubNotification(body)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		defer helpers.Recover()
+
+		for _, entry := range entries {
+			metrics.YoutubeWebSubNotifications.Add(1)
+
+			if entry.VideoID == "" || entry.ChannelID == "" || time.Since(entry.Published) > websubMaxVideoAge {
+				continue
+			}
+
+			websubFeeds.receiveVideo(video{
+				ID:           entry.VideoID,
+				ChannelID:    entry.ChannelID,
+				ChannelTitle: entry.Author,
+				Title:        entry.Title,
+				ThumbnailURL: fmt.Sprintf(youtubeThumbnailUrl, entry.VideoID),
+			})
+		}
+	}()
+
+	return nil
+}
+
+func parseWebSubNotification(body []byte) (entries []websubEntry, err error) {
+	var notification websubNotification
+	err = xml.Unmarshal(body, &notification)
+	if err != nil {
+		return nil, err
+	}
+
+	return notification.Entries, nil
+}
+
+// receiveVideo posts a video of a notification to all feeds of its YouTube channel
+func (f *feeds) receiveVideo(v video) {
+	var entries []models.YoutubeChannelEntry
+	err := helpers.MDbIterWithoutLogging(helpers.MdbCollection(models.YoutubeChannelTable).Find(
+		bson.M{"youtubechannelid": v.ChannelID},
+	)).All(&entries)
+	if err != nil {
+		logger().WithError(err).Warn("getting feeds of WebSub notification failed")
+		return
+	}
+
+	for _, e := range entries {
+		if f.isPosted(v.ID, e.YoutubePostedVideos) || !f.canPostToChannel(e.ChannelID) {
+			continue
+		}
+
+		posted, err := f.postVideo(e, v)
+		if err != nil {
+			logger().Warn(err)
+			continue
+		}
+		if !posted {
+			continue
+		}
+
+		err = helpers.MDbUpdateWithoutLogging(models.YoutubeChannelTable, e.ID, bson.M{"$addToSet": bson.M{"youtubepostedvideos": v.ID}})
+		helpers.RelaxLog(err)
+	}
+}
@@ -0,0 +1,105 @@
+package youtube
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/hex"
+	"testing"
+	"time"
+)
+
+const testWebSubNotification = `<?xml version='1.0' encoding='UTF-8'?>
+<feed xmlns:yt="http://www.youtube.com/xml/schemas/2015" xmlns="http://www.w3.org/2005/Atom">
+  <link rel="hub" href="https://pubsubhubbub.appspot.com"/>
+  <link rel="self" href="https://www.youtube.com/xml/feeds/videos.xml?channel_id=UCexample"/>
+  <title>YouTube video feed</title>
+  <updated>2018-06-05T12:00:01.000000000+00:00</updated>
+  <entry>
+    <id>yt:video:VIDEO_ID</id>
+    <yt:videoId>VIDEO_ID</yt:videoId>
+    <yt:channelId>UCexample</yt:channelId>
+    <title>New Music Video</title>
+    <link rel="alternate" href="https://www.youtube.com/watch?v=VIDEO_ID"/>
+    <author>
+      <name>Example Channel</name>
+      <uri>https://www.youtube.com/channel/UCexample</uri>
+    </author>
+    <published>2018-06-05T12:00:00+00:00</published>
+    <updated>2018-06-05T12:00:01.000000000+00:00</updated>
+  </entry>
+</feed>`
+
+func TestParseWebSubNotification(t *testing.T) {
+	entries, err := parseWebSubNotification([]byte(testWebSubNotification))
+	if err != nil {
+		t.Fatalf("youtube.parseWebSubNotification() returned error: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("youtube.parseWebSubNotification() returned %d entries, expected 1", len(entries))
+	}
+
+	entry := entries[0]
+	if entry.VideoID != "VIDEO_ID" || entry.ChannelID != "UCexample" || entry.Title != "New Music Video" ||
+		entry.Author != "Example Channel" || !entry.Published.Equal(time.Date(2018, 6, 5, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("youtube.parseWebSubNotification() returned unexpected entry: %+v", entry)
+	}
+
+	if _, err = parseWebSubNotification([]byte("not xml")); err == nil {
+		t.Errorf("youtube.parseWebSubNotification() accepted an invalid notification")
+	}
+}
+
+func TestVerifyWebSubSignature(t *testing.T) {
+	body := []byte(testWebSubNotification)
+	mac := hmac.New(sha1.New, []byte("secret"))
+	mac.Write(body)
+	signature := "sha1=" + hex.EncodeToString(mac.Sum(nil))
+
+	if !verifyWebSubSignature("secret", signature, body) {
+		t.Errorf("youtube.verifyWebSubSignature() rejected a valid signature")
+	}
+	if verifyWebSubSignature("other secret", signature, body) {
+		t.Errorf("youtube.verifyWebSubSignature() accepted a signature of another secret")
+	}
+	if verifyWebSubSignature("secret", signature, append(body, ' ')) {
+		t.Errorf("youtube.verifyWebSubSignature() accepted a signature of another body")
+	}
+	for _, invalid := range []string{"", "sha1", "md5=" + signature[5:], "sha1=zz"} {
+		if verifyWebSubSignature("secret", invalid, body) {
+			t.Errorf("youtube.verifyWebSubSignature() accepted the invalid signature %q", invalid)
+		}
+	}
+}
+
+func TestWebSubTopicChannelID(t *testing.T) {
+	tests := map[string]string{
+		"https://www.youtube.com/xml/feeds/videos.xml?channel_id=UCexample": "UCexample",
+		"https://www.youtube.com/xml/feeds/videos.xml?user=example":         "",
+		"https://example.com/xml/feeds/videos.xml?channel_id=UCexample":     "",
+		"%": "",
+	}
+
+	for topic, expected := range tests {
+		if channelID := websubTopicChannelID(topic); channelID != expected {
+			t.Errorf("youtube.websubTopicChannelID(%q) returned %q, expected %q", topic, channelID, expected)
+		}
+	}
+}
+
+func TestWebSubLease(t *testing.T) {
+	tests := []struct {
+		leaseSeconds int
+		expected     int
+	}{
+		{0, websubLeaseSeconds},
+		{-1, websubLeaseSeconds},
+		{3600, 3600},
+		{websubLeaseSeconds * 100, websubLeaseSeconds},
+	}
+
+	for _, test := range tests {
+		if lease := websubLease(test.leaseSeconds); lease != test.expected {
+			t.Errorf("youtube.websubLease(%d) returned %d, expected %d", test.leaseSeconds, lease, test.expected)
+		}
+	}
+}
@@ -7,10 +7,8 @@ import (
 	"strings"
 
 	"github.com/Seklfreak/Robyul2/cache"
-	"github.com/Seklfreak/Robyul2/generator"
 	"github.com/Seklfreak/Robyul2/helpers"
 	"github.com/Seklfreak/Robyul2/metrics"
-	"github.com/Seklfreak/Robyul2/modules/plugins/levels"
 	"github.com/Seklfreak/Robyul2/ratelimits"
 	"github.com/bwmarrin/discordgo"
 )
@@ -19,67 +17,43 @@ import (
 func Init(session *discordgo.Session) {
 	checkDuplicateCommands()
 
-	pluginCount := len(PluginList)
-	extendedPluginCount := len(PluginExtendedList)
-	pluginCache = make(map[string]*Plugin)
-	extendedPluginCache = make(map[string]*ExtendedPlugin)
+	pluginStatesLock.Lock()
+	defer pluginStatesLock.Unlock()
 
-	logTemplate := "[PLUG] %s reacts to [ %s]"
-	listeners := ""
+	initPluginStates()
 
-	for i := 0; i < pluginCount; i++ {
-		ref := &PluginList[i]
+	logTemplate := "[PLUG] %s reacts to [ %s]"
+	for _, state := range pluginStates {
+		if state.extendedRef != nil {
+			logTemplate = "[EXTENDED-PLUG] %s reacts to [ %s]"
+		}
 
-		for _, cmd := range (*ref).Commands() {
-			pluginCache[cmd] = ref
-			listeners += cmd + " "
+		if state.disabled {
+			cache.GetLogger().WithField("module", "modules").Info(fmt.Sprintf(
+				"[PLUG] %s is disabled", state.name,
+			))
+			continue
 		}
 
 		cache.GetLogger().WithField("module", "modules").Info(fmt.Sprintf(
 			logTemplate,
-			helpers.Typeof(*ref),
-			listeners,
+			helpers.Typeof(state.plugin()),
+			strings.Join(state.commands(), " ")+" ",
 		))
-		listeners = ""
 
-		(*ref).Init(session)
+		helpers.RelaxLog(state.load(session))
 	}
 
-	listeners = ""
-	logTemplate = "[EXTENDED-PLUG] %s reacts to [ %s]"
-	for i := 0; i < extendedPluginCount; i++ {
-		ref := &PluginExtendedList[i]
+	rebuildPluginCaches()
 
-		for _, cmd := range (*ref).Commands() {
-			extendedPluginCache[cmd] = ref
-			listeners += cmd + " "
-		}
+	if InteractionsEnabled() {
+		go func() {
+			defer helpers.Recover()
 
-		cache.GetLogger().WithField("module", "modules").Info(fmt.Sprintf(
-			logTemplate,
-			helpers.Typeof(*ref),
-			listeners,
-		))
-		listeners = ""
-
-		if helpers.Typeof(*ref) == "*Levels" {
-			generator.SetProfileGenerator((*ref).(*levels.Levels))
-		}
-
-		(*ref).Init(session)
+			helpers.RelaxLog(RegisterInteractionCommands(session))
+		}()
 	}
 
-	pluginCommands := make([]string, 0)
-	for k := range pluginCache {
-		pluginCommands = append(pluginCommands, k)
-	}
-	cache.SetPluginList(pluginCommands)
-	extendedPluginCommands := make([]string, 0)
-	for k := range extendedPluginCache {
-		extendedPluginCommands = append(extendedPluginCommands, k)
-	}
-	cache.SetPluginExtendedList(extendedPluginCommands)
-
 	cache.GetLogger().WithField("module", "modules").Info(
 		"modules",
 		"Initializer finished. Loaded "+strconv.Itoa(len(PluginList))+" plugins and "+strconv.Itoa(len(PluginExtendedList))+" extended plugins",
@@ -88,23 +62,25 @@ func Init(session *discordgo.Session) {
 
 // Uninit deintializes the plugins
 func Uninit(session *discordgo.Session) {
-	extendedPluginCount := len(PluginExtendedList)
-	extendedPluginCache = make(map[string]*ExtendedPlugin)
+	stopExtendedPluginDispatchers()
 
-	logTemplate := "[EXTENDED-PLUG] %s deintializing…"
-	for i := 0; i < extendedPluginCount; i++ {
-		ref := &PluginExtendedList[i]
+	pluginStatesLock.Lock()
+	defer pluginStatesLock.Unlock()
 
-		for _, cmd := range (*ref).Commands() {
-			extendedPluginCache[cmd] = ref
+	logTemplate := "[EXTENDED-PLUG] %s deintializing…"
+	for _, state := range pluginStates {
+		if state.extendedRef == nil || !state.initialized {
+			continue
 		}
 
 		cache.GetLogger().WithField("module", "modules").Info(fmt.Sprintf(
 			logTemplate,
-			helpers.Typeof(*ref),
+			helpers.Typeof(*state.extendedRef),
 		))
 
-		(*ref).Uninit(session)
+		(*state.extendedRef).Uninit(session)
+		state.initialized = false
+		state.loaded = false
 	}
 
 	cache.GetLogger().WithField("module", "modules").Info(
@@ -124,83 +100,99 @@ func CallBotPlugin(command string, content string, msg *discordgo.Message) {
 	// Consume a key for this action
 	ratelimits.Container.Drain(1, msg.Author.ID)
 
+	// Resolve aliases, check the permission and validate arguments of described commands
+	command = resolveCommandAlias(command)
+	descriptor := GetCommandDescriptor(command)
+	if descriptor == nil {
+		callBotPlugin(command, content, msg)
+		return
+	}
+
+	requireCommandPermission(msg, descriptor, func() {
+		if err := ValidateCommandArguments(descriptor, content); err != nil {
+			sendCommandUsage(msg, descriptor)
+			return
+		}
+
+		callBotPlugin(command, content, msg)
+	})
+}
+
+func callBotPlugin(command string, content string, msg *discordgo.Message) {
 	// Track metrics
 	metrics.CommandsExecuted.Add(1)
 
+	pluginCacheLock.RLock()
+	pluginRef, isPlugin := pluginCache[command]
+	extendedPluginRef, isExtendedPlugin := extendedPluginCache[command]
+	pluginCacheLock.RUnlock()
+
 	// Call the module
-	if ref, ok := pluginCache[command]; ok {
-		(*ref).Action(command, content, msg, cache.GetSession())
+	if isPlugin {
+		(*pluginRef).Action(command, content, msg, cache.GetSession())
 	}
 	// call the extended module
-	if ref, ok := extendedPluginCache[command]; ok {
-		(*ref).Action(command, content, msg, cache.GetSession())
+	if isExtendedPlugin {
+		(*extendedPluginRef).Action(command, content, msg, cache.GetSession())
 	}
 }
 
 func CallExtendedPlugin(content string, msg *discordgo.Message) {
-	defer helpers.Recover()
+	content = strings.TrimSpace(content)
 
-	for _, extendedPlugin := range PluginExtendedList {
-		extendedPlugin.OnMessage(strings.TrimSpace(content), msg, cache.GetSession())
-	}
-	//go safePluginExtendedCall(strings.TrimSpace(content), msg, plug)
+	dispatchExtendedPluginEvent("OnMessage", getEventKey(msg.GuildID, msg.ChannelID), func(plugin ExtendedPlugin, session *discordgo.Session) {
+		plugin.OnMessage(content, msg, session)
+	})
 }
 
 func CallExtendedPluginOnMessageDelete(message *discordgo.MessageDelete) {
-	defer helpers.Recover()
-
-	for _, extendedPlugin := range PluginExtendedList {
-		extendedPlugin.OnMessageDelete(message, cache.GetSession())
-	}
+	dispatchExtendedPluginEvent("OnMessageDelete", getEventKey(message.GuildID, message.ChannelID), func(plugin ExtendedPlugin, session *discordgo.Session) {
+		plugin.OnMessageDelete(message, session)
+	})
 }
 
 func CallExtendedPluginOnGuildMemberAdd(member *discordgo.Member) {
-	defer helpers.Recover()
-
-	// Iterate over all plugins
-	for _, extendedPlugin := range PluginExtendedList {
-		extendedPlugin.OnGuildMemberAdd(member, cache.GetSession())
-	}
+	dispatchExtendedPluginEvent("OnGuildMemberAdd", member.GuildID, func(plugin ExtendedPlugin, session *discordgo.Session) {
+		plugin.OnGuildMemberAdd(member, session)
+	})
 }
-func CallExtendedPluginOnGuildMemberRemove(member *discordgo.Member) {
-	defer helpers.Recover()
 
-	// Iterate over all plugins
-	for _, extendedPlugin := range PluginExtendedList {
-		extendedPlugin.OnGuildMemberRemove(member, cache.GetSession())
-	}
+func CallExtendedPluginOnGuildMemberRemove(member *discordgo.Member) {
+	dispatchExtendedPluginEvent("OnGuildMemberRemove", member.GuildID, func(plugin ExtendedPlugin, session *discordgo.Session) {
+		plugin.OnGuildMemberRemove(member, session)
+	})
 }
-func CallExtendedPluginOnReactionAdd(reaction *discordgo.MessageReactionAdd) {
-	defer helpers.Recover()
 
-	// Iterate over all plugins
-	for _, extendedPlugin := range PluginExtendedList {
-		extendedPlugin.OnReactionAdd(reaction, cache.GetSession())
-	}
+func CallExtendedPluginOnReactionAdd(reaction *discordgo.MessageReactionAdd) {
+	dispatchExtendedPluginEvent("OnReactionAdd", getEventKey(reaction.GuildID, reaction.ChannelID), func(plugin ExtendedPlugin, session *discordgo.Session) {
+		plugin.OnReactionAdd(reaction, session)
+	})
 }
-func CallExtendedPluginOnReactionRemove(reaction *discordgo.MessageReactionRemove) {
-	defer helpers.Recover()
 
-	// Iterate over all plugins
-	for _, extendedPlugin := range PluginExtendedList {
-		extendedPlugin.OnReactionRemove(reaction, cache.GetSession())
-	}
+func CallExtendedPluginOnReactionRemove(reaction *discordgo.MessageReactionRemove) {
+	dispatchExtendedPluginEvent("OnReactionRemove", getEventKey(reaction.GuildID, reaction.ChannelID), func(plugin ExtendedPlugin, session *discordgo.Session) {
+		plugin.OnReactionRemove(reaction, session)
+	})
 }
-func CallExtendedPluginOnGuildBanAdd(user *discordgo.GuildBanAdd) {
-	defer helpers.Recover()
 
-	// Iterate over all plugins
-	for _, extendedPlugin := range PluginExtendedList {
-		extendedPlugin.OnGuildBanAdd(user, cache.GetSession())
-	}
+func CallExtendedPluginOnGuildBanAdd(user *discordgo.GuildBanAdd) {
+	dispatchExtendedPluginEvent("OnGuildBanAdd", user.GuildID, func(plugin ExtendedPlugin, session *discordgo.Session) {
+		plugin.OnGuildBanAdd(user, session)
+	})
 }
+
 func CallExtendedPluginOnGuildBanRemove(user *discordgo.GuildBanRemove) {
-	defer helpers.Recover()
+	dispatchExtendedPluginEvent("OnGuildBanRemove", user.GuildID, func(plugin ExtendedPlugin, session *discordgo.Session) {
+		plugin.OnGuildBanRemove(user, session)
+	})
+}
 
-	// Iterate over all plugins
-	for _, extendedPlugin := range PluginExtendedList {
-		extendedPlugin.OnGuildBanRemove(user, cache.GetSession())
+// getEventKey returns the key to keep the order of extended plugin events, the guild ID, or the channel ID for direct messages
+func getEventKey(guildID, channelID string) string {
+	if guildID != "" {
+		return guildID
 	}
+	return channelID
 }
 
 func checkDuplicateCommands() {
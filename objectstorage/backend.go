@@ -0,0 +1,29 @@
+package objectstorage
+
+import (
+	"errors"
+	"io"
+	"path/filepath"
+)
+
+var (
+	// ErrNotFound is returned by backends for objects which do not exist
+	ErrNotFound = errors.New("object not found")
+
+	errInvalidKey = errors.New("invalid object key")
+)
+
+// Backend stores objects by their key, putting an existing key replaces the object
+type Backend interface {
+	Put(key string, data []byte, contentType string) error
+	// PutReader stores an object of a known size without reading it into memory, the reader is rewound for retries
+	PutReader(key string, reader io.ReadSeeker, size int64, contentType string) error
+	Get(key string) ([]byte, error)
+	Exists(key string) (bool, error)
+	Delete(key string) error
+}
+
+// validKey prevents keys from escaping the folder of filesystem backends and caches
+func validKey(key string) bool {
+	return key != "" && key != "." && key != ".." && filepath.Base(key) == key
+}
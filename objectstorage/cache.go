@@ -0,0 +1,202 @@
+package objectstorage
+
+import (
+	"bytes"
+	"container/list"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Cache keeps recently used objects in a folder, the least recently used objects are evicted once the folder exceeds maxSize
+// a nil Cache caches nothing
+type Cache struct {
+	path    string
+	maxSize int64
+
+	lock    sync.Mutex
+	size    int64
+	entries map[string]*list.Element
+	// recent is ordered from the most to the least recently used object
+	recent *list.List
+}
+
+type cacheEntry struct {
+	key  string
+	size int64
+}
+
+// NewCache creates the folder if it doesn't exist yet and picks up the objects cached by previous runs, ordered by their modification time
+// a maxSize of zero or less disables eviction
+func NewCache(path string, maxSize int64) (*Cache, error) {
+	err := os.MkdirAll(path, os.ModePerm)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := ioutil.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		return files[i].ModTime().Before(files[j].ModTime())
+	})
+
+	c := &Cache{
+		path:    path,
+		maxSize: maxSize,
+		entries: make(map[string]*list.Element),
+		recent:  list.New(),
+	}
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+		if strings.HasPrefix(file.Name(), ".tmp-") {
+			os.Remove(filepath.Join(path, file.Name()))
+			continue
+		}
+		c.add(file.Name(), file.Size())
+	}
+	c.evict()
+
+	return c, nil
+}
+
+// Get returns the cached object, or nil if it isn't cached
+func (c *Cache) Get(key string) []byte {
+	if c == nil || !validKey(key) {
+		return nil
+	}
+
+	c.lock.Lock()
+	element, ok := c.entries[key]
+	if ok {
+		c.recent.MoveToFront(element)
+	}
+	c.lock.Unlock()
+	if !ok {
+		return nil
+	}
+
+	data, err := ioutil.ReadFile(c.objectPath(key))
+	if err != nil {
+		c.remove(key)
+		return nil
+	}
+
+	// keeps the order for the next run
+	now := time.Now()
+	os.Chtimes(c.objectPath(key), now, now)
+
+	return data
+}
+
+// Has checks if an object is cached
+func (c *Cache) Has(key string) bool {
+	if c == nil {
+		return false
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	_, ok := c.entries[key]
+	return ok
+}
+
+// Set caches an object and evicts the least recently used objects if the cache is full
+func (c *Cache) Set(key string, data []byte) (err error) {
+	if c == nil {
+		return nil
+	}
+	if !validKey(key) {
+		return errInvalidKey
+	}
+
+	err = writeFileAtomic(c.objectPath(key), bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.add(key, int64(len(data)))
+	c.evict()
+	return nil
+}
+
+// Delete removes an object from the cache
+func (c *Cache) Delete(key string) (err error) {
+	if c == nil || !validKey(key) {
+		return nil
+	}
+
+	c.remove(key)
+
+	err = os.Remove(c.objectPath(key))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// Size returns the total size of all cached objects in bytes
+func (c *Cache) Size() int64 {
+	if c == nil {
+		return 0
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.size
+}
+
+func (c *Cache) remove(key string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if element, ok := c.entries[key]; ok {
+		c.size -= element.Value.(*cacheEntry).size
+		c.recent.Remove(element)
+		delete(c.entries, key)
+	}
+}
+
+// add requires the lock
+func (c *Cache) add(key string, size int64) {
+	if element, ok := c.entries[key]; ok {
+		entry := element.Value.(*cacheEntry)
+		c.size += size - entry.size
+		entry.size = size
+		c.recent.MoveToFront(element)
+		return
+	}
+
+	c.entries[key] = c.recent.PushFront(&cacheEntry{key: key, size: size})
+	c.size += size
+}
+
+// evict requires the lock, the most recently used object is kept even if it exceeds maxSize on its own
+func (c *Cache) evict() {
+	if c.maxSize <= 0 {
+		return
+	}
+
+	for c.size > c.maxSize && c.recent.Len() > 1 {
+		entry := c.recent.Remove(c.recent.Back()).(*cacheEntry)
+		delete(c.entries, entry.key)
+		c.size -= entry.size
+		os.Remove(c.objectPath(entry.key))
+	}
+}
+
+func (c *Cache) objectPath(key string) string {
+	return filepath.Join(c.path, key)
+}
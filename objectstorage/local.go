@@ -0,0 +1,112 @@
+package objectstorage
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// LocalBackend stores objects as files, sharded into folders by the first two characters of their key
+type LocalBackend struct {
+	path string
+}
+
+// NewLocalBackend creates the folder if it doesn't exist yet
+func NewLocalBackend(path string) (*LocalBackend, error) {
+	err := os.MkdirAll(path, os.ModePerm)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LocalBackend{path: path}, nil
+}
+
+func (b *LocalBackend) Put(key string, data []byte, contentType string) (err error) {
+	return b.PutReader(key, bytes.NewReader(data), int64(len(data)), contentType)
+}
+
+func (b *LocalBackend) PutReader(key string, reader io.ReadSeeker, size int64, contentType string) (err error) {
+	if !validKey(key) {
+		return errInvalidKey
+	}
+
+	err = os.MkdirAll(filepath.Dir(b.objectPath(key)), os.ModePerm)
+	if err != nil {
+		return err
+	}
+
+	_, err = reader.Seek(0, io.SeekStart)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(b.objectPath(key), io.LimitReader(reader, size))
+}
+
+func (b *LocalBackend) Get(key string) (data []byte, err error) {
+	if !validKey(key) {
+		return nil, errInvalidKey
+	}
+
+	data, err = ioutil.ReadFile(b.objectPath(key))
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	return data, err
+}
+
+func (b *LocalBackend) Exists(key string) (exists bool, err error) {
+	if !validKey(key) {
+		return false, errInvalidKey
+	}
+
+	_, err = os.Stat(b.objectPath(key))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (b *LocalBackend) Delete(key string) (err error) {
+	if !validKey(key) {
+		return errInvalidKey
+	}
+
+	err = os.Remove(b.objectPath(key))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func (b *LocalBackend) objectPath(key string) string {
+	shard := key
+	if len(shard) > 2 {
+		shard = shard[:2]
+	}
+	return filepath.Join(b.path, shard, key)
+}
+
+// writeFileAtomic writes to a temporary file first, so readers never see partially written objects
+func writeFileAtomic(path string, reader io.Reader) (err error) {
+	file, err := ioutil.TempFile(filepath.Dir(path), ".tmp-")
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(file, reader)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(file.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), path)
+	}
+	if err != nil {
+		os.Remove(file.Name())
+	}
+	return err
+}
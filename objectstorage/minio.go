@@ -0,0 +1,120 @@
+package objectstorage
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	minio "github.com/minio/minio-go"
+)
+
+const (
+	minioLocation = "ams3"
+	// minioRetries is the number of retries for rate limited requests and network errors
+	minioRetries = 5
+)
+
+// MinioBackend stores objects in a bucket of a Minio or S3 compatible object storage
+type MinioBackend struct {
+	client *minio.Client
+	bucket string
+}
+
+// NewMinioBackend connects to the object storage and creates the bucket if it doesn't exist yet
+func NewMinioBackend(endpoint, accessKey, secretKey, bucket string) (*MinioBackend, error) {
+	client, err := minio.New(endpoint, accessKey, secretKey, true)
+	if err != nil {
+		return nil, err
+	}
+
+	bucketExists, err := client.BucketExists(bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	if !bucketExists {
+		err = client.MakeBucket(bucket, minioLocation)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &MinioBackend{client: client, bucket: bucket}, nil
+}
+
+func (b *MinioBackend) Put(key string, data []byte, contentType string) error {
+	return b.PutReader(key, bytes.NewReader(data), int64(len(data)), contentType)
+}
+
+func (b *MinioBackend) PutReader(key string, reader io.ReadSeeker, size int64, contentType string) error {
+	return retry(func() error {
+		_, err := reader.Seek(0, io.SeekStart)
+		if err != nil {
+			return err
+		}
+		_, err = b.client.PutObject(b.bucket, key, reader, size, minio.PutObjectOptions{ContentType: contentType})
+		return err
+	})
+}
+
+func (b *MinioBackend) Get(key string) (data []byte, err error) {
+	err = retry(func() error {
+		object, err := b.client.GetObject(b.bucket, key, minio.GetObjectOptions{})
+		if err != nil {
+			return err
+		}
+		defer object.Close()
+
+		data, err = ioutil.ReadAll(object)
+		return err
+	})
+	if isMinioNotFound(err) {
+		return nil, ErrNotFound
+	}
+	return data, err
+}
+
+func (b *MinioBackend) Exists(key string) (exists bool, err error) {
+	var info minio.ObjectInfo
+	err = retry(func() error {
+		info, err = b.client.StatObject(b.bucket, key, minio.StatObjectOptions{})
+		return err
+	})
+	if isMinioNotFound(err) {
+		return false, nil
+	}
+	return err == nil && info.Size > 0, err
+}
+
+func (b *MinioBackend) Delete(key string) error {
+	return retry(func() error {
+		return b.client.RemoveObject(b.bucket, key)
+	})
+}
+
+// retry repeats requests which were rate limited or failed because of network errors
+func retry(request func() error) (err error) {
+	for i := 0; ; i++ {
+		err = request()
+		if err == nil || i >= minioRetries || !isMinioTemporary(err) {
+			return err
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+func isMinioTemporary(err error) bool {
+	return strings.Contains(err.Error(), "Please reduce your request rate.") ||
+		strings.Contains(err.Error(), "net/http") ||
+		strings.Contains(err.Error(), "timeout")
+}
+
+func isMinioNotFound(err error) bool {
+	if err == nil {
+		return false
+	}
+	code := minio.ToErrorResponse(err).Code
+	return code == "NoSuchKey" || code == "NoSuchObject"
+}
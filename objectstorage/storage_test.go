@@ -0,0 +1,107 @@
+package objectstorage
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func tempDir(t *testing.T) string {
+	path, err := ioutil.TempDir("", "objectstorage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLocalBackend(t *testing.T) {
+	path := tempDir(t)
+	defer os.RemoveAll(path)
+
+	backend, err := NewLocalBackend(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = backend.Put("abcdef", []byte("data"), "text/plain"); err != nil {
+		t.Fatalf("objectstorage.LocalBackend.Put() returned error: %v", err)
+	}
+	if exists, err := backend.Exists("abcdef"); !exists || err != nil {
+		t.Errorf("objectstorage.LocalBackend.Exists() returned %v, %v for a stored object", exists, err)
+	}
+	if data, err := backend.Get("abcdef"); !bytes.Equal(data, []byte("data")) || err != nil {
+		t.Errorf("objectstorage.LocalBackend.Get() returned %q, %v, expected \"data\"", data, err)
+	}
+
+	if err = backend.Delete("abcdef"); err != nil {
+		t.Fatalf("objectstorage.LocalBackend.Delete() returned error: %v", err)
+	}
+	if exists, err := backend.Exists("abcdef"); exists || err != nil {
+		t.Errorf("objectstorage.LocalBackend.Exists() returned %v, %v for a deleted object", exists, err)
+	}
+	if _, err = backend.Get("abcdef"); err != ErrNotFound {
+		t.Errorf("objectstorage.LocalBackend.Get() returned %v for a deleted object, expected ErrNotFound", err)
+	}
+
+	reader := bytes.NewReader([]byte("streamed"))
+	reader.Seek(3, io.SeekStart)
+	if err = backend.PutReader("abcdef", reader, 8, "text/plain"); err != nil {
+		t.Fatalf("objectstorage.LocalBackend.PutReader() returned error: %v", err)
+	}
+	if data, err := backend.Get("abcdef"); !bytes.Equal(data, []byte("streamed")) || err != nil {
+		t.Errorf("objectstorage.LocalBackend.Get() returned %q, %v, expected \"streamed\"", data, err)
+	}
+
+	for _, key := range []string{"", "..", "../abcdef", "ab/cdef"} {
+		if err = backend.Put(key, []byte("data"), "text/plain"); err == nil {
+			t.Errorf("objectstorage.LocalBackend.Put() accepted the invalid key %q", key)
+		}
+	}
+}
+
+func TestCacheEviction(t *testing.T) {
+	path := tempDir(t)
+	defer os.RemoveAll(path)
+
+	cache, err := NewCache(path, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range []string{"a", "b", "c"} {
+		if err = cache.Set(key, []byte("1234")); err != nil {
+			t.Fatalf("objectstorage.Cache.Set() returned error: %v", err)
+		}
+		// a stays the most recently used object
+		cache.Get("a")
+	}
+
+	if cache.Get("a") == nil || cache.Get("c") == nil {
+		t.Errorf("objectstorage.Cache evicted recently used objects")
+	}
+	if cache.Get("b") != nil {
+		t.Errorf("objectstorage.Cache didn't evict the least recently used object")
+	}
+	if size := cache.Size(); size != 8 {
+		t.Errorf("objectstorage.Cache.Size() returned %d, expected 8", size)
+	}
+
+	reopened, err := NewCache(path, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reopened.Size() != 4 || !reopened.Has("c") || reopened.Has("a") {
+		t.Errorf("objectstorage.NewCache() didn't evict the cached objects of the previous run down to 4 bytes")
+	}
+
+	if err = cache.Delete("a"); err != nil || cache.Has("a") {
+		t.Errorf("objectstorage.Cache.Delete() returned %v and kept the object", err)
+	}
+
+	var nilCache *Cache
+	if nilCache.Set("a", []byte("1234")) != nil || nilCache.Get("a") != nil {
+		t.Errorf("a nil objectstorage.Cache cached an object")
+	}
+}
@@ -0,0 +1,185 @@
+package rest
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/Seklfreak/Robyul2/helpers"
+	"github.com/Seklfreak/Robyul2/models"
+	restful "github.com/emicklei/go-restful"
+	"github.com/pkg/errors"
+)
+
+const (
+	modCasesDefaultLimit = 50
+	modCasesMaxLimit     = 100
+)
+
+func newModCasesService(prefix string) *restful.WebService {
+	service := new(restful.WebService)
+	service.
+		Path(prefix + "/cases").
+		Consumes(restful.MIME_JSON).
+		Produces(restful.MIME_JSON)
+
+	service.Route(service.GET("/{guild-id}").Filter(sessionAndWebkeyAuthenticate).To(GetModCases).
+		Doc("lists the cases of a guild, newest first").
+		Param(service.QueryParameter("user-id", "only cases of this user")).
+		Param(service.QueryParameter("type", "only cases of this type")).
+		Param(service.QueryParameter("offset", "the number of cases to skip").DataType("integer")).
+		Param(service.QueryParameter("limit", "the number of cases to return").DataType("integer")).
+		Writes(models.Rest_Mod_Cases{}))
+	service.Route(service.GET("/{guild-id}/{case-id}").Filter(sessionAndWebkeyAuthenticate).To(GetModCase).
+		Doc("gets a case of a guild").Writes(models.Rest_Mod_Case{}))
+	service.Route(service.POST("/{guild-id}/{case-id}/reason").Filter(sessionAndWebkeyAuthenticate).To(SetModCaseReason).
+		Doc("sets the reason of a case").
+		Reads(models.Rest_Receive_ModCaseReason{}).Writes(models.Rest_Mod_Case{}))
+
+	return service
+}
+
+// GetModCases lists the cases of a guild, newest first
+// optional query parameters: user-id, type, offset and limit
+func GetModCases(request *restful.Request, response *restful.Response) {
+	guildID := request.PathParameter("guild-id")
+
+	if !isModCasesAuthorized(request, guildID) {
+		writeError(request, response, http.StatusUnauthorized, errNotAuthorized)
+		return
+	}
+
+	offset, _ := strconv.Atoi(request.QueryParameter("offset"))
+	if offset < 0 {
+		offset = 0
+	}
+	limit, err := strconv.Atoi(request.QueryParameter("limit"))
+	if err != nil || limit <= 0 {
+		limit = modCasesDefaultLimit
+	}
+	if limit > modCasesMaxLimit {
+		limit = modCasesMaxLimit
+	}
+
+	modCases, count, err := helpers.GetModCases(guildID, request.QueryParameter("user-id"),
+		models.ModCaseType(request.QueryParameter("type")), offset, limit)
+	if err != nil {
+		writeError(request, response, http.StatusInternalServerError, err)
+		return
+	}
+
+	result := models.Rest_Mod_Cases{
+		Users: make([]models.Rest_User, 0),
+		Cases: make([]models.Rest_Mod_Case, 0),
+		Count: count,
+	}
+
+	lookedUpUserIDs := make(map[string]bool)
+	for _, modCase := range modCases {
+		result.Cases = append(result.Cases, getRestModCase(modCase))
+
+		for _, userID := range []string{modCase.UserID, modCase.ModeratorUserID} {
+			if userID == "" || lookedUpUserIDs[userID] {
+				continue
+			}
+			lookedUpUserIDs[userID] = true
+
+			user, _ := helpers.GetUserWithoutAPI(userID)
+			if user != nil && user.ID != "" {
+				result.Users = append(result.Users, models.Rest_User{
+					ID:            user.ID,
+					Username:      user.Username,
+					AvatarHash:    user.Avatar,
+					Discriminator: user.Discriminator,
+					Bot:           user.Bot,
+				})
+			}
+		}
+	}
+
+	response.WriteEntity(result)
+}
+
+func GetModCase(request *restful.Request, response *restful.Response) {
+	guildID := request.PathParameter("guild-id")
+
+	if !isModCasesAuthorized(request, guildID) {
+		writeError(request, response, http.StatusUnauthorized, errNotAuthorized)
+		return
+	}
+
+	modCase, ok := getModCaseFromRequest(request, response, guildID)
+	if !ok {
+		return
+	}
+
+	response.WriteEntity(getRestModCase(modCase))
+}
+
+func SetModCaseReason(request *restful.Request, response *restful.Response) {
+	guildID := request.PathParameter("guild-id")
+
+	if !isModCasesAuthorized(request, guildID) {
+		writeError(request, response, http.StatusUnauthorized, errNotAuthorized)
+		return
+	}
+
+	modCase, ok := getModCaseFromRequest(request, response, guildID)
+	if !ok {
+		return
+	}
+
+	received := new(models.Rest_Receive_ModCaseReason)
+	err := request.ReadEntity(received)
+	if err != nil {
+		writeError(request, response, http.StatusBadRequest, err)
+		return
+	}
+
+	err = helpers.UpdateModCaseReason(modCase, received.Reason, request.Attribute("UserID").(string))
+	if err != nil {
+		writeError(request, response, http.StatusInternalServerError, err)
+		return
+	}
+
+	modCase.Reason = received.Reason
+	response.WriteEntity(getRestModCase(modCase))
+}
+
+func isModCasesAuthorized(request *restful.Request, guildID string) bool {
+	userID := request.Attribute("UserID").(string)
+	return userID == "global" || helpers.IsModByID(guildID, userID)
+}
+
+func getModCaseFromRequest(request *restful.Request, response *restful.Response, guildID string) (modCase models.ModCaseEntry, ok bool) {
+	caseID, err := strconv.Atoi(request.PathParameter("case-id"))
+	if err != nil {
+		writeError(request, response, http.StatusBadRequest, errors.New("invalid case id"))
+		return modCase, false
+	}
+
+	modCase, err = helpers.GetModCase(guildID, caseID)
+	if err != nil {
+		if helpers.IsMdbNotFound(err) {
+			writeError(request, response, http.StatusNotFound, errors.New("case not found"))
+			return modCase, false
+		}
+		writeError(request, response, http.StatusInternalServerError, err)
+		return modCase, false
+	}
+
+	return modCase, true
+}
+
+func getRestModCase(modCase models.ModCaseEntry) models.Rest_Mod_Case {
+	return models.Rest_Mod_Case{
+		CaseID:          modCase.CaseID,
+		Type:            modCase.Type,
+		UserID:          modCase.UserID,
+		ModeratorUserID: modCase.ModeratorUserID,
+		Reason:          modCase.Reason,
+		CreatedAt:       modCase.CreatedAt.UTC(),
+		UpdatedAt:       modCase.UpdatedAt.UTC(),
+		ExpiresAt:       modCase.ExpiresAt.UTC(),
+		Automatic:       modCase.Automatic,
+	}
+}
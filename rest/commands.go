@@ -0,0 +1,15 @@
+package rest
+
+import (
+	"github.com/Seklfreak/Robyul2/modules"
+	restful "github.com/emicklei/go-restful"
+)
+
+func GetCommands(request *restful.Request, response *restful.Response) {
+	response.WriteEntity(modules.GetCommandDescriptors())
+}
+
+func GetCommandsMarkdown(request *restful.Request, response *restful.Response) {
+	response.AddHeader("Content-Type", "text/markdown; charset=utf-8")
+	response.Write([]byte(modules.GetCommandReferenceMarkdown()))
+}
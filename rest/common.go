@@ -1,7 +1,10 @@
 package rest
 
 import (
+	"errors"
 	"fmt"
+	"strconv"
+	"time"
 
 	"github.com/Seklfreak/Robyul2/cache"
 	"github.com/Seklfreak/Robyul2/helpers"
@@ -80,9 +83,107 @@ func getGuildSettings(guildID, userID string) (settings models.Rest_Settings) {
 		Strings: make([]models.Rest_Setting_String, 0),
 	}
 
+	if userID == "global" || helpers.IsModByID(guildID, userID) {
+		eventlogRules := make([]string, 0)
+		for _, rule := range helpers.GuildSettingsGetCached(guildID).EventlogRules {
+			eventlogRules = append(eventlogRules, helpers.FormatEventlogRule(rule))
+		}
+		settings.Strings = append(settings.Strings, models.Rest_Setting_String{
+			Key:    "eventlog_rules",
+			Level:  helpers.SettingLevelMod,
+			Values: eventlogRules,
+		})
+	}
+
+	if userID == "global" || helpers.IsAdminByID(guildID, userID) {
+		guildSettings := helpers.GuildSettingsGetCached(guildID)
+		settings.Strings = append(settings.Strings,
+			models.Rest_Setting_String{
+				Key:    "eventlog_retention_days",
+				Level:  helpers.SettingLevelAdmin,
+				Values: []string{strconv.Itoa(guildSettings.EventlogRetentionDays)},
+			},
+			models.Rest_Setting_String{
+				Key:    "chatlog_retention_days",
+				Level:  helpers.SettingLevelAdmin,
+				Values: []string{strconv.Itoa(guildSettings.ChatlogRetentionDays)},
+			},
+		)
+	}
+
 	return
 }
 
 func setGuildStringSetting(guildID, userID, key string, values []string) (err error) {
-	return
+	switch key {
+	case "eventlog_rules":
+		if userID != "global" && !helpers.IsModByID(guildID, userID) {
+			return errors.New("not authorized to change eventlog_rules")
+		}
+
+		rules := make([]models.EventlogRule, 0, len(values))
+		for _, value := range values {
+			rule, err := helpers.ParseEventlogRule(value)
+			if err != nil {
+				return err
+			}
+			if rule.Action == models.EventlogRuleActionRoute {
+				channel, err := helpers.GetChannelWithoutApi(rule.ChannelID)
+				if err != nil || channel.GuildID != guildID {
+					return errors.New("eventlog rule channel " + rule.ChannelID + " is not on this server")
+				}
+			}
+			rules = append(rules, rule)
+		}
+
+		if userID == "global" {
+			userID = cache.GetSession().State.User.ID
+		}
+		return helpers.SetEventlogRules(guildID, userID, rules)
+	case "eventlog_retention_days", "chatlog_retention_days":
+		if userID != "global" && !helpers.IsAdminByID(guildID, userID) {
+			return errors.New("not authorized to change " + key)
+		}
+
+		if len(values) != 1 {
+			return errors.New(key + " needs exactly one value")
+		}
+		days, err := strconv.Atoi(values[0])
+		if err != nil {
+			return err
+		}
+		if days != 0 && (days < helpers.EventlogRetentionMinDays || days > helpers.EventlogRetentionMaxDays) {
+			return fmt.Errorf("%s has to be 0 or between %d and %d", key, helpers.EventlogRetentionMinDays, helpers.EventlogRetentionMaxDays)
+		}
+
+		guildSettings := helpers.GuildSettingsGetCached(guildID)
+		var oldDays int
+		if key == "chatlog_retention_days" {
+			oldDays = guildSettings.ChatlogRetentionDays
+			guildSettings.ChatlogRetentionDays = days
+		} else {
+			oldDays = guildSettings.EventlogRetentionDays
+			guildSettings.EventlogRetentionDays = days
+		}
+
+		if userID == "global" {
+			userID = cache.GetSession().State.User.ID
+		}
+		_, err = helpers.EventlogLog(time.Now(), guildID, guildID,
+			models.EventlogTargetTypeGuild, userID,
+			models.EventlogTypeRobyulEventlogConfigUpdate, "",
+			[]models.ElasticEventlogChange{
+				{
+					Key:      key,
+					OldValue: strconv.Itoa(oldDays),
+					NewValue: strconv.Itoa(days),
+				},
+			},
+			nil, false)
+		helpers.RelaxLog(err)
+
+		return helpers.GuildSettingsSet(guildID, guildSettings)
+	}
+
+	return errors.New("unknown setting " + key)
 }
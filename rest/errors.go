@@ -0,0 +1,82 @@
+package rest
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Seklfreak/Robyul2/models"
+	restful "github.com/emicklei/go-restful"
+)
+
+var (
+	errNotAuthorized = errors.New("not authorized")
+	errForbidden     = errors.New("forbidden")
+	errBadRequest    = errors.New("bad request")
+)
+
+// writeError writes the error in the JSON error envelope for versioned routes
+// unversioned routes keep the plain text errors they had before the envelope was introduced
+func writeError(request *restful.Request, response *restful.Response, status int, err error) {
+	message := http.StatusText(status)
+	if err != nil {
+		message = err.Error()
+	}
+
+	if !isVersionedRequest(request) {
+		if err == errNotAuthorized {
+			message = "401: Not Authorized"
+		}
+		response.WriteErrorString(status, message)
+		return
+	}
+
+	response.WriteHeaderAndJson(status, models.Rest_Error{
+		Error: models.Rest_Error_Details{
+			Status:  status,
+			Message: message,
+		},
+	}, restful.MIME_JSON)
+}
+
+// WriteServiceError writes errors of the router, like unknown routes, like writeError
+func WriteServiceError(serviceError restful.ServiceError, request *restful.Request, response *restful.Response) {
+	writeError(request, response, serviceError.Code, errors.New(serviceError.Message))
+}
+
+func isVersionedRequest(request *restful.Request) bool {
+	return request != nil && request.Request != nil && request.Request.URL != nil &&
+		strings.HasPrefix(request.Request.URL.Path, apiVersionPrefix+"/")
+}
+
+// getPagination reads the page and per_page query parameters, per_page defaults to defaultPerPage and is at most maxPerPage
+func getPagination(request *restful.Request, defaultPerPage, maxPerPage int) (pagination models.Rest_Pagination, err error) {
+	pagination.Page = 1
+	pagination.PerPage = defaultPerPage
+
+	if request.QueryParameter("page") != "" {
+		pagination.Page, err = strconv.Atoi(request.QueryParameter("page"))
+		if err != nil || pagination.Page < 1 {
+			return pagination, errors.New("invalid page")
+		}
+	}
+
+	if request.QueryParameter("per_page") != "" {
+		pagination.PerPage, err = strconv.Atoi(request.QueryParameter("per_page"))
+		if err != nil || pagination.PerPage < 1 || pagination.PerPage > maxPerPage {
+			return pagination, errors.New("invalid per_page, it has to be between 1 and " + strconv.Itoa(maxPerPage))
+		}
+	}
+
+	return pagination, nil
+}
+
+// paginationParameters documents the query parameters read by getPagination, use it with RouteBuilder.Do
+func paginationParameters(service *restful.WebService) func(*restful.RouteBuilder) {
+	return func(builder *restful.RouteBuilder) {
+		builder.
+			Param(service.QueryParameter("page", "the page, starting at 1").DataType("integer")).
+			Param(service.QueryParameter("per_page", "the number of items per page").DataType("integer"))
+	}
+}
@@ -0,0 +1,43 @@
+package rest
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	restful "github.com/emicklei/go-restful"
+)
+
+func TestWriteError(t *testing.T) {
+	tests := []struct {
+		path   string
+		err    error
+		status int
+		body   string
+	}{
+		{"/v1/user/1", errNotAuthorized, http.StatusUnauthorized, `{"Error":{"Status":401,"Message":"not authorized"}}`},
+		{"/user/1", errNotAuthorized, http.StatusUnauthorized, "401: Not Authorized"},
+		{"/user/1", errBadRequest, http.StatusBadRequest, "bad request"},
+		{"/v1", errBadRequest, http.StatusBadRequest, "bad request"},
+	}
+
+	for _, test := range tests {
+		recorder := httptest.NewRecorder()
+		request := restful.NewRequest(httptest.NewRequest(http.MethodGet, test.path, nil))
+		response := restful.NewResponse(recorder)
+
+		writeError(request, response, test.status, test.err)
+
+		var compacted bytes.Buffer
+		body := strings.TrimSpace(recorder.Body.String())
+		if json.Compact(&compacted, []byte(body)) == nil {
+			body = compacted.String()
+		}
+		if recorder.Code != test.status || body != test.body {
+			t.Errorf("rest.writeError() wrote %d %q for %s, expected %d %q", recorder.Code, body, test.path, test.status, test.body)
+		}
+	}
+}
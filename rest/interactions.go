@@ -0,0 +1,97 @@
+package rest
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/Seklfreak/Robyul2/helpers"
+	"github.com/Seklfreak/Robyul2/models"
+	"github.com/Seklfreak/Robyul2/modules"
+	restful "github.com/emicklei/go-restful"
+)
+
+var (
+	interactionsPublicKey     ed25519.PublicKey
+	interactionsPublicKeyOnce sync.Once
+)
+
+func newInteractionsService(prefix string) *restful.WebService {
+	service := new(restful.WebService)
+	service.
+		Path(prefix + "/interactions").
+		Consumes(restful.MIME_JSON).
+		Produces(restful.MIME_JSON)
+
+	service.Route(service.POST("").Filter(interactionsAuthenticate).To(ReceiveInteraction).
+		Doc("receives Discord interactions").Reads(models.Interaction{}))
+
+	return service
+}
+
+func getInteractionsPublicKey() ed25519.PublicKey {
+	interactionsPublicKeyOnce.Do(func() {
+		if interactionsPublicKey != nil || !modules.InteractionsEnabled() {
+			return
+		}
+
+		publicKey, err := hex.DecodeString(helpers.GetConfig().Path("discord.public_key").Data().(string))
+		helpers.RelaxLog(err)
+		if err == nil {
+			interactionsPublicKey = publicKey
+		}
+	})
+
+	return interactionsPublicKey
+}
+
+// verifyInteractionSignature checks the signature discord attaches to every interaction
+func verifyInteractionSignature(publicKey ed25519.PublicKey, signature, timestamp string, body []byte) bool {
+	if len(publicKey) != ed25519.PublicKeySize {
+		return false
+	}
+
+	signatureBytes, err := hex.DecodeString(signature)
+	if err != nil || len(signatureBytes) != ed25519.SignatureSize {
+		return false
+	}
+
+	return ed25519.Verify(publicKey, append([]byte(timestamp), body...), signatureBytes)
+}
+
+func interactionsAuthenticate(request *restful.Request, response *restful.Response, chain *restful.FilterChain) {
+	body, err := ioutil.ReadAll(request.Request.Body)
+	if err != nil {
+		writeError(request, response, http.StatusBadRequest, errBadRequest)
+		return
+	}
+	request.Request.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	if !verifyInteractionSignature(
+		getInteractionsPublicKey(),
+		strings.TrimSpace(request.HeaderParameter("X-Signature-Ed25519")),
+		strings.TrimSpace(request.HeaderParameter("X-Signature-Timestamp")),
+		body,
+	) {
+		writeError(request, response, http.StatusUnauthorized, errNotAuthorized)
+		return
+	}
+
+	chain.ProcessFilter(request, response)
+	return
+}
+
+func ReceiveInteraction(request *restful.Request, response *restful.Response) {
+	interaction := new(models.Interaction)
+	err := request.ReadEntity(interaction)
+	if err != nil {
+		writeError(request, response, http.StatusBadRequest, errBadRequest)
+		return
+	}
+
+	response.WriteEntity(modules.CallInteraction(interaction))
+}
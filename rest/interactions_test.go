@@ -0,0 +1,63 @@
+package rest
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Seklfreak/Robyul2/models"
+	restful "github.com/emicklei/go-restful"
+)
+
+func TestReceiveInteraction(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	interactionsPublicKey = publicKey
+
+	container := restful.NewContainer()
+	container.Add(newInteractionsService(""))
+	server := httptest.NewServer(container)
+	defer server.Close()
+
+	post := func(body []byte, signature string) *http.Response {
+		request, err := http.NewRequest("POST", server.URL+"/interactions", bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		request.Header.Set("Content-Type", restful.MIME_JSON)
+		request.Header.Set("X-Signature-Ed25519", signature)
+		request.Header.Set("X-Signature-Timestamp", "1546300800")
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return response
+	}
+
+	body := []byte(`{"id":"1","type":1}`)
+
+	response := post(body, hex.EncodeToString(make([]byte, ed25519.SignatureSize)))
+	response.Body.Close()
+	if response.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("rest.ReceiveInteraction() accepted invalid signature, got status %d", response.StatusCode)
+	}
+
+	response = post(body, hex.EncodeToString(ed25519.Sign(privateKey, append([]byte("1546300800"), body...))))
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("rest.ReceiveInteraction() rejected valid signature, got status %d", response.StatusCode)
+	}
+
+	var interactionResponse models.InteractionResponse
+	err = json.NewDecoder(response.Body).Decode(&interactionResponse)
+	if err != nil || interactionResponse.Type != models.InteractionResponseTypePong {
+		t.Fatalf("rest.ReceiveInteraction() did not respond to ping with pong")
+	}
+}
@@ -0,0 +1,329 @@
+package rest
+
+import (
+	"net/http"
+	"path"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Seklfreak/Robyul2/models"
+	restful "github.com/emicklei/go-restful"
+)
+
+var (
+	openApiPathParameterRegex = regexp.MustCompile(`{([^}:]+)(:[^}]*)?}`)
+	openApiTimeType           = reflect.TypeOf(time.Time{})
+)
+
+// openApiSpec is a Swagger 2.0 document, only the fields used by the REST API are supported
+type openApiSpec struct {
+	Swagger             string                                  `json:"swagger"`
+	Info                openApiInfo                             `json:"info"`
+	BasePath            string                                  `json:"basePath"`
+	Paths               map[string]map[string]*openApiOperation `json:"paths"`
+	Definitions         map[string]*openApiSchema               `json:"definitions"`
+	SecurityDefinitions map[string]openApiSecurityScheme        `json:"securityDefinitions"`
+	Security            []map[string][]string                   `json:"security"`
+}
+
+type openApiInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type openApiOperation struct {
+	OperationID string                     `json:"operationId,omitempty"`
+	Summary     string                     `json:"summary,omitempty"`
+	Description string                     `json:"description,omitempty"`
+	Tags        []string                   `json:"tags,omitempty"`
+	Consumes    []string                   `json:"consumes,omitempty"`
+	Produces    []string                   `json:"produces,omitempty"`
+	Parameters  []openApiParameter         `json:"parameters,omitempty"`
+	Responses   map[string]openApiResponse `json:"responses"`
+	Deprecated  bool                       `json:"deprecated,omitempty"`
+}
+
+type openApiParameter struct {
+	Name        string         `json:"name"`
+	In          string         `json:"in"`
+	Description string         `json:"description,omitempty"`
+	Required    bool           `json:"required"`
+	Type        string         `json:"type,omitempty"`
+	Format      string         `json:"format,omitempty"`
+	Default     string         `json:"default,omitempty"`
+	Schema      *openApiSchema `json:"schema,omitempty"`
+}
+
+type openApiResponse struct {
+	Description string         `json:"description"`
+	Schema      *openApiSchema `json:"schema,omitempty"`
+}
+
+type openApiSchema struct {
+	Ref                  string                    `json:"$ref,omitempty"`
+	Type                 string                    `json:"type,omitempty"`
+	Format               string                    `json:"format,omitempty"`
+	Items                *openApiSchema            `json:"items,omitempty"`
+	Properties           map[string]*openApiSchema `json:"properties,omitempty"`
+	AdditionalProperties *openApiSchema            `json:"additionalProperties,omitempty"`
+}
+
+type openApiSecurityScheme struct {
+	Type        string `json:"type"`
+	Name        string `json:"name"`
+	In          string `json:"in"`
+	Description string `json:"description,omitempty"`
+}
+
+// newOpenApiService serves the spec of the services at prefix/openapi.json, it does not require authentication
+func newOpenApiService(prefix string, services []*restful.WebService) *restful.WebService {
+	spec := newOpenApiSpec(prefix, services)
+
+	service := new(restful.WebService)
+	service.
+		Path(prefix + "/openapi.json").
+		Produces(restful.MIME_JSON)
+
+	service.Route(service.GET("").To(func(request *restful.Request, response *restful.Response) {
+		response.WriteEntity(spec)
+	}).Operation("GetOpenApiSpec").Doc("gets the OpenAPI spec of the REST API"))
+
+	return service
+}
+
+// newOpenApiSpec generates the spec from the routes of the services, the models of the routes are read with reflection
+// routes have to be in a service below the prefix
+func newOpenApiSpec(prefix string, services []*restful.WebService) *openApiSpec {
+	spec := &openApiSpec{
+		Swagger: "2.0",
+		Info: openApiInfo{
+			Title:   "Robyul REST API",
+			Version: strings.TrimPrefix(prefix, "/"),
+		},
+		BasePath:    prefix,
+		Paths:       make(map[string]map[string]*openApiOperation),
+		Definitions: make(map[string]*openApiSchema),
+		SecurityDefinitions: map[string]openApiSecurityScheme{
+			"webkey": {
+				Type: "apiKey", Name: "Authorization", In: "header",
+				Description: "Webkey <webkey>, accepted by all routes",
+			},
+			"session": {
+				Type: "apiKey", Name: "Authorization", In: "header",
+				Description: "Session <session token>, accepted by routes for guild moderators",
+			},
+			"token": {
+				Type: "apiKey", Name: "Authorization", In: "header",
+				Description: "Token <api token>, accepted by routes matching the scopes of the token",
+			},
+		},
+		Security: []map[string][]string{{"webkey": {}}, {"session": {}}, {"token": {}}},
+	}
+	definitionTypes := make(map[string]reflect.Type)
+
+	errorSchema := openApiSchemaFor(reflect.TypeOf(models.Rest_Error{}), spec.Definitions, definitionTypes)
+
+	for _, service := range services {
+		tag := strings.Trim(strings.TrimPrefix(service.RootPath(), prefix), "/")
+		if tag == "" {
+			tag = "default"
+		}
+
+		for _, route := range service.Routes() {
+			routePath := strings.TrimPrefix(route.Path, prefix)
+			if routePath == "" {
+				routePath = "/"
+			}
+			routePath = openApiPathParameterRegex.ReplaceAllString(routePath, "{$1}")
+
+			operation := &openApiOperation{
+				OperationID: route.Operation,
+				Summary:     route.Doc,
+				Description: route.Notes,
+				Tags:        []string{tag},
+				Produces:    route.Produces,
+				Responses:   make(map[string]openApiResponse),
+				Deprecated:  route.Deprecated,
+			}
+			if route.ReadSample != nil {
+				operation.Consumes = route.Consumes
+			}
+
+			documentedParameters := make(map[string]bool)
+			for _, parameter := range route.ParameterDocs {
+				documentedParameters[parameter.Data().Name] = true
+				operation.Parameters = append(operation.Parameters, openApiParameterFor(parameter.Data()))
+			}
+			for _, match := range openApiPathParameterRegex.FindAllStringSubmatch(route.Path, -1) {
+				if documentedParameters[match[1]] {
+					continue
+				}
+				operation.Parameters = append(operation.Parameters, openApiParameter{
+					Name:     match[1],
+					In:       "path",
+					Required: true,
+					Type:     "string",
+				})
+			}
+			if route.ReadSample != nil {
+				operation.Parameters = append(operation.Parameters, openApiParameter{
+					Name:     "body",
+					In:       "body",
+					Required: true,
+					Schema:   openApiSchemaFor(reflect.TypeOf(route.ReadSample), spec.Definitions, definitionTypes),
+				})
+			}
+
+			if len(route.ResponseErrors) > 0 {
+				for code, responseError := range route.ResponseErrors {
+					response := openApiResponse{Description: responseError.Message}
+					if responseError.Model != nil {
+						response.Schema = openApiSchemaFor(reflect.TypeOf(responseError.Model), spec.Definitions, definitionTypes)
+					}
+					operation.Responses[strconv.Itoa(code)] = response
+				}
+			} else {
+				response := openApiResponse{Description: http.StatusText(http.StatusOK)}
+				if route.WriteSample != nil {
+					response.Schema = openApiSchemaFor(reflect.TypeOf(route.WriteSample), spec.Definitions, definitionTypes)
+				}
+				operation.Responses[strconv.Itoa(http.StatusOK)] = response
+			}
+			operation.Responses["default"] = openApiResponse{Description: "error", Schema: errorSchema}
+
+			if _, ok := spec.Paths[routePath]; !ok {
+				spec.Paths[routePath] = make(map[string]*openApiOperation)
+			}
+			spec.Paths[routePath][strings.ToLower(route.Method)] = operation
+		}
+	}
+
+	return spec
+}
+
+func openApiParameterFor(data restful.ParameterData) openApiParameter {
+	parameter := openApiParameter{
+		Name:        data.Name,
+		Description: data.Description,
+		Required:    data.Required,
+		Type:        data.DataType,
+		Format:      data.DataFormat,
+		Default:     data.DefaultValue,
+	}
+
+	switch data.Kind {
+	case restful.PathParameterKind:
+		parameter.In = "path"
+		parameter.Required = true
+	case restful.QueryParameterKind:
+		parameter.In = "query"
+	case restful.HeaderParameterKind:
+		parameter.In = "header"
+	case restful.FormParameterKind:
+		parameter.In = "formData"
+	case restful.BodyParameterKind:
+		parameter.In = "body"
+		parameter.Type = ""
+		parameter.Schema = &openApiSchema{Type: data.DataType}
+	}
+	if parameter.Type == "" && parameter.Schema == nil {
+		parameter.Type = "string"
+	}
+
+	return parameter
+}
+
+// openApiSchemaFor returns the schema of the type, named structs are added to the definitions and referenced
+// definitionTypes is used to give structs with the same name from different packages different definition names
+func openApiSchemaFor(t reflect.Type, definitions map[string]*openApiSchema, definitionTypes map[string]reflect.Type) *openApiSchema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t == openApiTimeType {
+		return &openApiSchema{Type: "string", Format: "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &openApiSchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &openApiSchema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return &openApiSchema{Type: "integer", Format: "int64"}
+	case reflect.Float32:
+		return &openApiSchema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &openApiSchema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &openApiSchema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &openApiSchema{Type: "string", Format: "byte"}
+		}
+		return &openApiSchema{Type: "array", Items: openApiSchemaFor(t.Elem(), definitions, definitionTypes)}
+	case reflect.Map:
+		return &openApiSchema{Type: "object", AdditionalProperties: openApiSchemaFor(t.Elem(), definitions, definitionTypes)}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return openApiStructSchema(t, definitions, definitionTypes)
+		}
+
+		name := t.Name()
+		if definitionType, ok := definitionTypes[name]; ok && definitionType != t {
+			name = path.Base(t.PkgPath()) + "." + t.Name()
+		}
+		if _, ok := definitionTypes[name]; !ok {
+			// the type is registered before reading the fields, so recursive types end in a reference
+			definitionTypes[name] = t
+			definitions[name] = openApiStructSchema(t, definitions, definitionTypes)
+		}
+		return &openApiSchema{Ref: "#/definitions/" + name}
+	}
+
+	// interfaces can be anything
+	return &openApiSchema{}
+}
+
+// openApiStructSchema reads the fields of a struct like encoding/json, embedded structs are flattened
+func openApiStructSchema(t reflect.Type, definitions map[string]*openApiSchema, definitionTypes map[string]reflect.Type) *openApiSchema {
+	schema := &openApiSchema{Type: "object", Properties: make(map[string]*openApiSchema)}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		name := field.Name
+		if tag, ok := field.Tag.Lookup("json"); ok {
+			tagName := strings.Split(tag, ",")[0]
+			if tagName == "-" {
+				continue
+			}
+			if tagName != "" {
+				name = tagName
+			}
+		}
+
+		fieldType := field.Type
+		for fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+		if field.Anonymous && fieldType.Kind() == reflect.Struct && name == field.Name {
+			for embeddedName, embeddedSchema := range openApiStructSchema(fieldType, definitions, definitionTypes).Properties {
+				if _, ok := schema.Properties[embeddedName]; !ok {
+					schema.Properties[embeddedName] = embeddedSchema
+				}
+			}
+			continue
+		}
+		if field.PkgPath != "" {
+			continue
+		}
+
+		schema.Properties[name] = openApiSchemaFor(field.Type, definitions, definitionTypes)
+	}
+
+	return schema
+}
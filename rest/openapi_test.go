@@ -0,0 +1,50 @@
+package rest
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestNewOpenApiSpec(t *testing.T) {
+	spec := newOpenApiSpec(apiVersionPrefix, newRestServices(apiVersionPrefix))
+
+	_, err := json.Marshal(spec)
+	if err != nil {
+		t.Fatalf("rest.newOpenApiSpec() returned a spec that can not be marshalled: %v", err)
+	}
+
+	operationIDs := make(map[string]bool)
+	for path, operations := range spec.Paths {
+		for method, operation := range operations {
+			if operation.OperationID == "" || operationIDs[operation.OperationID] {
+				t.Errorf("rest.newOpenApiSpec() returned %s %s with missing or duplicate operation id %q", method, path, operation.OperationID)
+			}
+			operationIDs[operation.OperationID] = true
+		}
+	}
+
+	rankings, ok := spec.Paths["/rankings/{guild-id}"]["get"]
+	if !ok {
+		t.Fatalf("rest.newOpenApiSpec() is missing GET /rankings/{guild-id}")
+	}
+	parameters := make(map[string]string)
+	for _, parameter := range rankings.Parameters {
+		parameters[parameter.Name] = parameter.In
+	}
+	for name, in := range map[string]string{"guild-id": "path", "page": "query", "per_page": "query"} {
+		if parameters[name] != in {
+			t.Errorf("rest.newOpenApiSpec() GET /rankings/{guild-id} has parameter %s in %q, expected %q", name, parameters[name], in)
+		}
+	}
+	if rankings.Responses["200"].Schema == nil || rankings.Responses["200"].Schema.Ref != "#/definitions/Rest_Ranking" {
+		t.Errorf("rest.newOpenApiSpec() GET /rankings/{guild-id} returned %+v, expected a Rest_Ranking", rankings.Responses["200"].Schema)
+	}
+
+	ranking, ok := spec.Definitions["Rest_Ranking"]
+	if !ok || ranking.Properties["Pagination"] == nil {
+		t.Errorf("rest.newOpenApiSpec() is missing the pagination of Rest_Ranking")
+	}
+	if spec.Definitions["Rest_Error"] == nil {
+		t.Errorf("rest.newOpenApiSpec() is missing the Rest_Error definition")
+	}
+}
@@ -0,0 +1,48 @@
+package rest
+
+import (
+	"net/http"
+
+	"github.com/Seklfreak/Robyul2/modules"
+	restful "github.com/emicklei/go-restful"
+	"github.com/pkg/errors"
+)
+
+func GetPlugins(request *restful.Request, response *restful.Response) {
+	response.WriteEntity(modules.GetPluginStatuses())
+}
+
+func SetPluginState(request *restful.Request, response *restful.Response) {
+	pluginName := request.PathParameter("plugin-name")
+
+	var err error
+	switch request.PathParameter("action") {
+	case "unload":
+		err = modules.UnloadPlugin(pluginName)
+	case "load":
+		err = modules.LoadPlugin(pluginName)
+	case "reload":
+		err = modules.ReloadPlugin(pluginName)
+	case "disable":
+		err = modules.DisablePlugin(pluginName)
+	case "enable":
+		err = modules.EnablePlugin(pluginName)
+	default:
+		writeError(request, response, http.StatusBadRequest, errors.New("Unknown action."))
+		return
+	}
+	if err != nil {
+		switch err {
+		case modules.ErrPluginNotFound:
+			writeError(request, response, http.StatusNotFound, err)
+		case modules.ErrPluginAmbiguous, modules.ErrPluginProtected, modules.ErrPluginAlreadyLoaded,
+			modules.ErrPluginNotLoaded, modules.ErrPluginDisabled, modules.ErrPluginNotReloadable:
+			writeError(request, response, http.StatusConflict, err)
+		default:
+			writeError(request, response, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	response.WriteEntity(modules.GetPluginStatuses())
+}
@@ -3,6 +3,7 @@ package rest
 import (
 	"context"
 	"fmt"
+	"mime"
 	"net/http"
 	"sort"
 	"strconv"
@@ -29,138 +30,227 @@ import (
 	"github.com/vmihailenco/msgpack"
 )
 
+const (
+	// apiVersionPrefix is the path prefix of the current version of the REST API
+	apiVersionPrefix = "/v1"
+
+	rankingsDefaultPerPage = 100
+	rankingsMaxPerPage     = 100
+	eventlogDefaultPerPage = 50
+	eventlogMaxPerPage     = 100
+	// elasticMaxResultWindow is the default index.max_result_window, elastic refuses searches for hits past it
+	elasticMaxResultWindow = 10000
+)
+
+// NewRestServices returns the services of the REST API under apiVersionPrefix, including the OpenAPI spec,
+// and the same services without a prefix for existing clients
 func NewRestServices() []*restful.WebService {
+	services := newRestServices(apiVersionPrefix)
+	services = append(services, newOpenApiService(apiVersionPrefix, services))
+	return append(services, newRestServices("")...)
+}
+
+func newRestServices(prefix string) []*restful.WebService {
 	services := make([]*restful.WebService, 0)
 
 	service := new(restful.WebService)
 	service.
-		Path("/bot/guilds").
+		Path(prefix + "/bot/guilds").
 		Consumes(restful.MIME_JSON).
 		Produces(restful.MIME_JSON)
-	service.Route(service.GET("").Filter(webkeyAuthenticate).To(GetAllBotGuilds))
+	service.Route(service.GET("").Filter(webkeyAuthenticate).To(GetAllBotGuilds).
+		Doc("lists all guilds of the bot").Writes([]models.Rest_Guild{}))
 	services = append(services, service)
 
 	service = new(restful.WebService)
 	service.
-		Path("/user").
+		Path(prefix + "/user").
 		Consumes(restful.MIME_JSON).
 		Produces(restful.MIME_JSON)
 
-	service.Route(service.GET("/{user-id}").Filter(sessionAndWebkeyAuthenticate).To(FindUser))
-	service.Route(service.GET("/{user-id}/guilds").Filter(webkeyAuthenticate).To(FindUserGuilds))
+	service.Route(service.GET("/{user-id}").Filter(sessionAndWebkeyAuthenticate).To(FindUser).
+		Doc("gets a user").Writes(models.Rest_User{}))
+	service.Route(service.GET("/{user-id}/guilds").Filter(webkeyAuthenticate).To(FindUserGuilds).
+		Doc("lists the guilds of a user shared with the bot").Writes([]models.Rest_Member_Guild{}))
 	services = append(services, service)
 
 	service = new(restful.WebService)
 	service.
-		Path("/member").
+		Path(prefix + "/member").
 		Consumes(restful.MIME_JSON).
 		Produces(restful.MIME_JSON)
 
-	service.Route(service.GET("/{guild-id}/{user-id}").Filter(webkeyAuthenticate).To(FindMember))
-	service.Route(service.GET("/{guild-id}/{user-id}/is").Filter(webkeyAuthenticate).To(IsMember))
-	service.Route(service.GET("/{guild-id}/{user-id}/status").Filter(webkeyAuthenticate).To(StatusMember))
+	service.Route(service.GET("/{guild-id}/{user-id}").Filter(webkeyAuthenticate).To(FindMember).
+		Doc("gets a member of a guild").Writes(models.Rest_Member{}))
+	service.Route(service.GET("/{guild-id}/{user-id}/is").Filter(webkeyAuthenticate).To(IsMember).
+		Doc("checks if a user is a member of a guild").Writes(models.Rest_Is_Member{}))
+	service.Route(service.GET("/{guild-id}/{user-id}/status").Filter(webkeyAuthenticate).To(StatusMember).
+		Doc("gets the permissions of a member in a guild").Writes(models.Rest_Status_Member{}))
 	services = append(services, service)
 
 	service = new(restful.WebService)
 	service.
-		Path("/profile").
+		Path(prefix + "/profile").
 		Consumes(restful.MIME_JSON).
 		Produces("text/html")
 
-	service.Route(service.GET("/{user-id}/{guild-id}").Filter(webkeyAuthenticate).To(GetProfile))
+	service.Route(service.GET("/{user-id}/{guild-id}").Filter(webkeyAuthenticate).To(GetProfile).
+		Doc("renders the profile of a user as HTML"))
+	services = append(services, service)
+
+	service = new(restful.WebService)
+	service.
+		Path(prefix + "/rankings").
+		Consumes(restful.MIME_JSON).
+		Produces(restful.MIME_JSON)
+
+	service.Route(service.GET("/{guild-id}").Filter(withApiToken(models.ApiTokenScopeReadRankings, webkeyAuthenticate)).To(GetRankings).
+		Doc("lists the rankings of a guild, use global as guild-id for the global rankings").
+		Do(paginationParameters(service)).Writes(models.Rest_Ranking{}))
+	service.Route(service.GET("/user/{user-id}/{guild-id}").Filter(withApiToken(models.ApiTokenScopeReadRankings, webkeyAuthenticate)).To(GetUserRanking).
+		Doc("gets the ranking of a user in a guild").Writes(models.Rest_Ranking_Rank_Item{}))
+	service.Route(service.GET("/user/{user-id}/all").Filter(withApiToken(models.ApiTokenScopeReadRankings, webkeyAuthenticate)).To(GetAllUserRanking).
+		Doc("lists the rankings of a user in all guilds").Writes([]models.Rest_Ranking_Rank_Item{}))
 	services = append(services, service)
 
 	service = new(restful.WebService)
 	service.
-		Path("/rankings").
+		Path(prefix + "/guild").
 		Consumes(restful.MIME_JSON).
 		Produces(restful.MIME_JSON)
 
-	service.Route(service.GET("/{guild-id}").Filter(webkeyAuthenticate).To(GetRankings))
-	service.Route(service.GET("/user/{user-id}/{guild-id}").Filter(webkeyAuthenticate).To(GetUserRanking))
-	service.Route(service.GET("/user/{user-id}/all").Filter(webkeyAuthenticate).To(GetAllUserRanking))
+	service.Route(service.GET("/{guild-id}").Filter(sessionAndWebkeyAuthenticate).To(FindGuild).
+		Doc("gets a guild with its settings").Writes(models.Rest_Guild{}))
+	service.Route(service.POST("/{guild-id}/set-settings").Filter(withApiToken(models.ApiTokenScopeWriteSettings, sessionAndWebkeyAuthenticate)).To(SetGuildSettings).
+		Doc("updates the settings of a guild").Reads(&models.Rest_Receive_SetSettings{}))
 	services = append(services, service)
 
 	service = new(restful.WebService)
 	service.
-		Path("/guild").
+		Path(prefix + "/randompictures").
 		Consumes(restful.MIME_JSON).
 		Produces(restful.MIME_JSON)
 
-	service.Route(service.GET("/{guild-id}").Filter(sessionAndWebkeyAuthenticate).To(FindGuild))
-	service.Route(service.POST("/{guild-id}/set-settings").Filter(sessionAndWebkeyAuthenticate).To(SetGuildSettings).Reads(&models.Rest_Receive_SetSettings{}))
+	service.Route(service.GET("/history/{guild-id}/{start}/{end}").Filter(webkeyAuthenticate).To(GetRandomPicturesGuildHistory).
+		Doc("lists the random pictures posted in a guild").Writes([]models.Rest_RandomPictures_HistoryItem{}))
 	services = append(services, service)
 
 	service = new(restful.WebService)
 	service.
-		Path("/randompictures").
+		Path(prefix + "/statistics").
 		Consumes(restful.MIME_JSON).
 		Produces(restful.MIME_JSON)
 
-	service.Route(service.GET("/history/{guild-id}/{start}/{end}").Filter(webkeyAuthenticate).To(GetRandomPicturesGuildHistory))
+	service.Route(service.GET("/{guild-id}/messages/{interval}/count").Filter(sessionAndWebkeyAuthenticate).To(GetMessageStatisticsCount).
+		Doc("counts the messages in a guild").Writes(models.Rest_Statistics_Count{}))
+	service.Route(service.GET("/{guild-id}/joins/{interval}/count").Filter(sessionAndWebkeyAuthenticate).To(GetJoinsStatisticsCount).
+		Doc("counts the joins of a guild").Writes(models.Rest_Statistics_Count{}))
+	service.Route(service.GET("/{guild-id}/leaves/{interval}/count").Filter(sessionAndWebkeyAuthenticate).To(GetLeavesStatisticsCount).
+		Doc("counts the leaves of a guild").Writes(models.Rest_Statistics_Count{}))
+	service.Route(service.GET("/{guild-id}/by-uniques/{interval}/count").Filter(sessionAndWebkeyAuthenticate).To(GetMessageByUniqueUsersStatisticsCount).
+		Doc("counts the unique users that posted messages in a guild").Writes(models.Rest_Statistics_Count{}))
+	service.Route(service.GET("/{guild-id}/serveractivity/{interval}/histogram/{count}").Filter(sessionAndWebkeyAuthenticate).To(GetServerActivityStatisticsHistogram).
+		Doc("gets a histogram of the messages, joins and leaves of a guild").Writes([]models.Rest_Statistics_Histogram_Three{}))
+	service.Route(service.GET("/{guild-id}/vanityinvite/{interval}/histogram/{count}").Filter(sessionAndWebkeyAuthenticate).To(GetVanityInviteStatistics).
+		Doc("gets a histogram of the vanity invite clicks and joins of a guild").Writes([]models.Rest_Statistics_Histogram_TwoSub{}))
+	service.Route(service.GET("/bot").Filter(webkeyAuthenticate).To(GotBotStatistics).
+		Doc("gets the statistics of the bot").Writes(models.Rest_Statitics_Bot{}))
 	services = append(services, service)
 
 	service = new(restful.WebService)
 	service.
-		Path("/statistics").
+		Path(prefix + "/chatlog").
 		Consumes(restful.MIME_JSON).
 		Produces(restful.MIME_JSON)
 
-	service.Route(service.GET("/{guild-id}/messages/{interval}/count").Filter(sessionAndWebkeyAuthenticate).To(GetMessageStatisticsCount))
-	service.Route(service.GET("/{guild-id}/joins/{interval}/count").Filter(sessionAndWebkeyAuthenticate).To(GetJoinsStatisticsCount))
-	service.Route(service.GET("/{guild-id}/leaves/{interval}/count").Filter(sessionAndWebkeyAuthenticate).To(GetLeavesStatisticsCount))
-	service.Route(service.GET("/{guild-id}/by-uniques/{interval}/count").Filter(sessionAndWebkeyAuthenticate).To(GetMessageByUniqueUsersStatisticsCount))
-	service.Route(service.GET("/{guild-id}/serveractivity/{interval}/histogram/{count}").Filter(sessionAndWebkeyAuthenticate).To(GetServerActivityStatisticsHistogram))
-	service.Route(service.GET("/{guild-id}/vanityinvite/{interval}/histogram/{count}").Filter(sessionAndWebkeyAuthenticate).To(GetVanityInviteStatistics))
-	service.Route(service.GET("/bot").Filter(webkeyAuthenticate).To(GotBotStatistics))
+	service.Route(service.GET("/{guild-id}/{channel-id}/around/{message-id}").Filter(sessionAndWebkeyAuthenticate).To(GetChatlogAroundMessageID).
+		Doc("lists the messages around a message").Writes([]models.Rest_Chatlog_Message{}))
 	services = append(services, service)
 
 	service = new(restful.WebService)
 	service.
-		Path("/chatlog").
+		Path(prefix + "/eventlog").
 		Consumes(restful.MIME_JSON).
 		Produces(restful.MIME_JSON)
 
-	service.Route(service.GET("/{guild-id}/{channel-id}/around/{message-id}").Filter(sessionAndWebkeyAuthenticate).To(GetChatlogAroundMessageID))
+	service.Route(service.GET("/{guild-id}").Filter(withApiToken(models.ApiTokenScopeReadEventlog, sessionAndWebkeyAuthenticate)).To(GetEventlog).
+		Doc("lists the eventlog entries of a guild, newest first").
+		Do(paginationParameters(service)).Writes(models.Rest_Eventlog{}))
+	service.Route(service.GET("/{guild-id}/export").Filter(withApiToken(models.ApiTokenScopeReadEventlog, sessionAndWebkeyAuthenticate)).To(GetEventlogExport).
+		Doc("exports the eventlog of a guild").Writes(models.Rest_Eventlog_Export{}))
 	services = append(services, service)
 
 	service = new(restful.WebService)
 	service.
-		Path("/eventlog").
+		Path(prefix+"/eventlog-exports").
+		Consumes(restful.MIME_JSON).
+		Produces("text/csv", "application/x-ndjson", restful.MIME_JSON)
+
+	service.Route(service.GET("/{object-name}").To(DownloadEventlogExport).
+		Doc("downloads an eventlog export, the expires and signature query parameters of the export link authorize the download"))
+	services = append(services, service)
+
+	service = new(restful.WebService)
+	service.
+		Path(prefix + "/vanityinvite").
 		Consumes(restful.MIME_JSON).
 		Produces(restful.MIME_JSON)
 
-	service.Route(service.GET("/{guild-id}").Filter(sessionAndWebkeyAuthenticate).To(GetEventlog))
+	service.Route(service.GET("/{vanity-name}").Filter(webkeyAuthenticate).To(GetVanityInviteByName).
+		Doc("gets a vanity invite by its name").Writes(models.Rest_VanityInvite_Invite{}))
 	services = append(services, service)
 
 	service = new(restful.WebService)
 	service.
-		Path("/vanityinvite").
+		Path(prefix + "/file").
 		Consumes(restful.MIME_JSON).
 		Produces(restful.MIME_JSON)
 
-	service.Route(service.GET("/{vanity-name}").Filter(webkeyAuthenticate).To(GetVanityInviteByName))
+	service.Route(service.GET("/{filehash}").Filter(webkeyAuthenticate).To(GetFileByFilehash).
+		Doc("gets a file by its hash").Writes(models.Rest_File{}))
 	services = append(services, service)
 
 	service = new(restful.WebService)
 	service.
-		Path("/file").
+		Path(prefix + "/backgrounds").
 		Consumes(restful.MIME_JSON).
 		Produces(restful.MIME_JSON)
+	service.Route(service.GET("").Filter(webkeyAuthenticate).To(GetAllBackgrounds).
+		Doc("lists all profile backgrounds").Writes([]models.Rest_Background{}))
+	services = append(services, service)
 
-	service.Route(service.GET("/{filehash}").Filter(webkeyAuthenticate).To(GetFileByFilehash))
+	service = new(restful.WebService)
+	service.
+		Path(prefix+"/commands").
+		Consumes(restful.MIME_JSON).
+		Produces(restful.MIME_JSON, "text/markdown")
+	service.Route(service.GET("").Filter(webkeyAuthenticate).To(GetCommands).
+		Doc("lists all commands").Writes([]models.CommandDescriptor{}))
+	service.Route(service.GET("/markdown").Filter(webkeyAuthenticate).To(GetCommandsMarkdown).
+		Doc("lists all commands as markdown"))
 	services = append(services, service)
 
 	service = new(restful.WebService)
 	service.
-		Path("/backgrounds").
+		Path(prefix + "/plugins").
 		Consumes(restful.MIME_JSON).
 		Produces(restful.MIME_JSON)
-	service.Route(service.GET("").Filter(webkeyAuthenticate).To(GetAllBackgrounds))
+	service.Route(service.GET("").Filter(webkeyAuthenticate).To(GetPlugins).
+		Doc("lists the state of all plugins").Writes([]models.Rest_Plugin_Status{}))
+	service.Route(service.POST("/{plugin-name}/{action}").Filter(webkeyAuthenticate).To(SetPluginState).
+		Doc("enables or disables a plugin").Writes([]models.Rest_Plugin_Status{}))
 	services = append(services, service)
 
+	services = append(services, newInteractionsService(prefix))
+	services = append(services, newModCasesService(prefix))
+	services = append(services, newApiTokensService(prefix))
+	services = append(services, newSessionsService(prefix))
+	services = append(services, newYouTubeWebSubService(prefix))
+
 	service = new(restful.WebService)
-	service.Route(service.GET("/ping").Filter(webkeyAuthenticate).To(Ping))
+	service.Path(prefix)
+	service.Route(service.GET("/ping").Filter(webkeyAuthenticate).To(Ping).
+		Doc("checks if the REST API is available"))
 	services = append(services, service)
 
 	return services
@@ -186,7 +276,7 @@ func webkeyAuthenticate(request *restful.Request, response *restful.Response, ch
 	}
 
 	if isAuthenticated == false {
-		response.WriteErrorString(401, "401: Not Authorized")
+		writeError(request, response, http.StatusUnauthorized, errNotAuthorized)
 		return
 	}
 
@@ -213,6 +303,15 @@ func sessionAndWebkeyAuthenticate(request *restful.Request, response *restful.Re
 		request.SetAttribute("UserID", "global")
 	}
 
+	// sessions created with Discord OAuth2 by CreateSession
+	if strings.HasPrefix(authorizationHeader, "Session ") {
+		session, err := getApiSession(strings.TrimSpace(strings.TrimPrefix(authorizationHeader, "Session ")))
+		if err == nil {
+			isAuthenticated = true
+			request.SetAttribute("UserID", session.UserID)
+		}
+	}
+
 	if strings.HasPrefix(authorizationHeader, "PHP-Session ") {
 		sessionID := strings.TrimSpace(strings.Replace(authorizationHeader, "PHP-Session ", "", -1))
 		key := "robyul2-web:robyul-session:" + sessionID
@@ -229,7 +328,7 @@ func sessionAndWebkeyAuthenticate(request *restful.Request, response *restful.Re
 	}
 
 	if isAuthenticated == false {
-		response.WriteErrorString(401, "401: Not Authorized")
+		writeError(request, response, http.StatusUnauthorized, errNotAuthorized)
 		return
 	}
 
@@ -265,7 +364,7 @@ func FindUser(request *restful.Request, response *restful.Response) {
 	userID := request.PathParameter("user-id")
 
 	if request.Attribute("UserID").(string) != "global" && request.Attribute("UserID").(string) != userID {
-		response.WriteErrorString(401, "401: Not Authorized")
+		writeError(request, response, http.StatusUnauthorized, errNotAuthorized)
 		return
 	}
 
@@ -281,7 +380,7 @@ func FindUser(request *restful.Request, response *restful.Response) {
 
 		response.WriteEntity(returnUser)
 	} else {
-		response.WriteError(http.StatusNotFound, errors.New("User not found."))
+		writeError(request, response, http.StatusNotFound, errors.New("User not found."))
 	}
 }
 
@@ -362,7 +461,7 @@ func FindMember(request *restful.Request, response *restful.Response) {
 
 		response.WriteEntity(returnUser)
 	} else {
-		response.WriteError(http.StatusNotFound, errors.New("Member not found."))
+		writeError(request, response, http.StatusNotFound, errors.New("Member not found."))
 	}
 }
 
@@ -421,7 +520,7 @@ func GetProfile(request *restful.Request, response *restful.Response) {
 	if guildID == "global" {
 		user, err := helpers.GetUser(userID)
 		if err != nil || user == nil || user.ID == "" {
-			response.WriteError(http.StatusNotFound, errors.New("Profile not found."))
+			writeError(request, response, http.StatusNotFound, errors.New("Profile not found."))
 			return
 		}
 
@@ -433,25 +532,25 @@ func GetProfile(request *restful.Request, response *restful.Response) {
 
 		profileHtml, err := generator.GetProfileGenerator().GetProfileHTML(fakeMember, fakeGuild, true)
 		if err != nil {
-			response.WriteError(http.StatusInternalServerError, err)
+			writeError(request, response, http.StatusInternalServerError, err)
 			return
 		}
 		response.Write([]byte(profileHtml))
 	} else {
 		guild, err := helpers.GetGuild(guildID)
 		if err != nil || guild == nil || guild.ID == "" {
-			response.WriteError(http.StatusNotFound, errors.New("Profile not found."))
+			writeError(request, response, http.StatusNotFound, errors.New("Profile not found."))
 			return
 		}
 		member, err := helpers.GetGuildMemberWithoutApi(guildID, userID)
 		if err != nil || member == nil || member.User == nil || member.User.ID == "" {
-			response.WriteError(http.StatusNotFound, errors.New("Profile not found."))
+			writeError(request, response, http.StatusNotFound, errors.New("Profile not found."))
 			return
 		}
 
 		profileHtml, err := generator.GetProfileGenerator().GetProfileHTML(member, guild, true)
 		if err != nil {
-			response.WriteError(http.StatusInternalServerError, err)
+			writeError(request, response, http.StatusInternalServerError, err)
 			return
 		}
 		response.Write([]byte(profileHtml))
@@ -464,27 +563,33 @@ func GetRankings(request *restful.Request, response *restful.Response) {
 	if guildID != "global" {
 		guild, err := helpers.GetGuild(guildID)
 		if err != nil || guild == nil || guild.ID == "" {
-			response.WriteError(http.StatusNotFound, errors.New("Guild not found"))
+			writeError(request, response, http.StatusNotFound, errors.New("Guild not found"))
 			return
 		}
 	}
 
-	var err error
+	pagination, err := getPagination(request, rankingsDefaultPerPage, rankingsMaxPerPage)
+	if err != nil {
+		writeError(request, response, http.StatusBadRequest, err)
+		return
+	}
+
 	var rankingsCount int
 	rankingsCountKey := fmt.Sprintf("robyul2-discord:levels:ranking:%s:by-rank:count", guildID)
 	cacheCodec := cache.GetRedisCacheCodec()
 
 	if err = cacheCodec.Get(rankingsCountKey, &rankingsCount); err != nil {
-		response.WriteError(http.StatusInternalServerError, err)
+		writeError(request, response, http.StatusInternalServerError, err)
 		return
 	}
 
 	result := new(models.Rest_Ranking)
 	result.Ranks = make([]models.Rest_Ranking_Rank_Item, 0)
 	result.Count = rankingsCount
+	pagination.Total = rankingsCount
+	result.Pagination = pagination
 
-	// TODO: i stuff
-	i := 1
+	i := (pagination.Page-1)*pagination.PerPage + 1
 	var keyByRank string
 	var rankingItem levels.Levels_Cache_Ranking_Item
 	var userItem models.Rest_User
@@ -526,7 +631,7 @@ func GetRankings(request *restful.Request, response *restful.Response) {
 				}
 			}
 
-			expForLevel := levels.GetExpForLevel(levels.GetLevelFromExp(rankingItem.EXP))
+			expForLevel := levels.GetGuildExpForLevel(guildID, levels.GetGuildLevelFromExp(guildID, rankingItem.EXP))
 
 			result.Ranks = append(result.Ranks, models.Rest_Ranking_Rank_Item{
 				User:                userItem,
@@ -536,12 +641,12 @@ func GetRankings(request *restful.Request, response *restful.Response) {
 				Level:               rankingItem.Level,
 				Ranking:             i,
 				NextLevelCurrentEXP: rankingItem.EXP - expForLevel,
-				NextLevelTotalEXP:   levels.GetExpForLevel(levels.GetLevelFromExp(rankingItem.EXP)+1) - expForLevel,
-				Progress:            levels.GetProgressToNextLevelFromExp(rankingItem.EXP),
+				NextLevelTotalEXP:   levels.GetGuildExpForLevel(guildID, levels.GetGuildLevelFromExp(guildID, rankingItem.EXP)+1) - expForLevel,
+				Progress:            levels.GetGuildProgressToNextLevelFromExp(guildID, rankingItem.EXP),
 			})
 		}
 		i += 1
-		if i > 100 {
+		if i > pagination.Page*pagination.PerPage {
 			break
 		}
 	}
@@ -556,7 +661,7 @@ func GetUserRanking(request *restful.Request, response *restful.Response) {
 	if guildID != "global" {
 		guild, err := helpers.GetGuild(guildID)
 		if err != nil || guild == nil || guild.ID == "" {
-			response.WriteError(http.StatusNotFound, errors.New("Guild not found"))
+			writeError(request, response, http.StatusNotFound, errors.New("Guild not found"))
 			return
 		}
 	}
@@ -567,13 +672,13 @@ func GetUserRanking(request *restful.Request, response *restful.Response) {
 	cacheCodec := cache.GetRedisCacheCodec()
 
 	if err = cacheCodec.Get(rankingsKey, &rankingItem); err != nil {
-		response.WriteError(http.StatusNotFound, errors.New("Member not found."))
+		writeError(request, response, http.StatusNotFound, errors.New("Member not found."))
 		return
 	}
 
 	user, _ := helpers.GetUser(userID)
 	if user == nil || user.ID == "" {
-		response.WriteError(http.StatusNotFound, errors.New("User not found."))
+		writeError(request, response, http.StatusNotFound, errors.New("User not found."))
 		return
 	}
 
@@ -595,7 +700,7 @@ func GetUserRanking(request *restful.Request, response *restful.Response) {
 		Bot:           user.Bot,
 	}
 
-	expForLevel := levels.GetExpForLevel(levels.GetLevelFromExp(rankingItem.EXP))
+	expForLevel := levels.GetGuildExpForLevel(guildID, levels.GetGuildLevelFromExp(guildID, rankingItem.EXP))
 
 	result := models.Rest_Ranking_Rank_Item{
 		User:                userItem,
@@ -605,8 +710,8 @@ func GetUserRanking(request *restful.Request, response *restful.Response) {
 		IsMember:            isMember,
 		GuildID:             guildID,
 		NextLevelCurrentEXP: rankingItem.EXP - expForLevel,
-		NextLevelTotalEXP:   levels.GetExpForLevel(levels.GetLevelFromExp(rankingItem.EXP)+1) - expForLevel,
-		Progress:            levels.GetProgressToNextLevelFromExp(rankingItem.EXP),
+		NextLevelTotalEXP:   levels.GetGuildExpForLevel(guildID, levels.GetGuildLevelFromExp(guildID, rankingItem.EXP)+1) - expForLevel,
+		Progress:            levels.GetGuildProgressToNextLevelFromExp(guildID, rankingItem.EXP),
 	}
 
 	response.WriteEntity(result)
@@ -622,7 +727,7 @@ func GetAllUserRanking(request *restful.Request, response *restful.Response) {
 
 	user, _ := helpers.GetUser(userID)
 	if user == nil || user.ID == "" {
-		response.WriteError(http.StatusNotFound, errors.New("User not found."))
+		writeError(request, response, http.StatusNotFound, errors.New("User not found."))
 		return
 	}
 	userItem := models.Rest_User{
@@ -645,7 +750,7 @@ func GetAllUserRanking(request *restful.Request, response *restful.Response) {
 			continue
 		}
 
-		expForLevel := levels.GetExpForLevel(levels.GetLevelFromExp(rankingItem.EXP))
+		expForLevel := levels.GetGuildExpForLevel(guild.ID, levels.GetGuildLevelFromExp(guild.ID, rankingItem.EXP))
 
 		result = append(result, models.Rest_Ranking_Rank_Item{
 			User:                userItem,
@@ -655,8 +760,8 @@ func GetAllUserRanking(request *restful.Request, response *restful.Response) {
 			Level:               rankingItem.Level,
 			Ranking:             rankingItem.Ranking,
 			NextLevelCurrentEXP: rankingItem.EXP - expForLevel,
-			NextLevelTotalEXP:   levels.GetExpForLevel(levels.GetLevelFromExp(rankingItem.EXP)+1) - expForLevel,
-			Progress:            levels.GetProgressToNextLevelFromExp(rankingItem.EXP),
+			NextLevelTotalEXP:   levels.GetGuildExpForLevel(guild.ID, levels.GetGuildLevelFromExp(guild.ID, rankingItem.EXP)+1) - expForLevel,
+			Progress:            levels.GetGuildProgressToNextLevelFromExp(guild.ID, rankingItem.EXP),
 		})
 	}
 
@@ -669,7 +774,7 @@ func FindGuild(request *restful.Request, response *restful.Response) {
 	guildID := request.PathParameter("guild-id")
 
 	if request.Attribute("UserID").(string) != "global" && !helpers.GetIsInGuild(guildID, request.Attribute("UserID").(string)) {
-		response.WriteErrorString(401, "401: Not Authorized")
+		writeError(request, response, http.StatusUnauthorized, errNotAuthorized)
 		return
 	}
 
@@ -715,7 +820,7 @@ func FindGuild(request *restful.Request, response *restful.Response) {
 
 		response.WriteEntity(returnGuild)
 	} else {
-		response.WriteError(http.StatusNotFound, errors.New("Guild not found."))
+		writeError(request, response, http.StatusNotFound, errors.New("Guild not found."))
 	}
 }
 
@@ -724,13 +829,13 @@ func GetRandomPicturesGuildHistory(request *restful.Request, response *restful.R
 	startString := request.PathParameter("start")
 	start, err := strconv.Atoi(startString)
 	if err != nil {
-		response.WriteError(http.StatusBadRequest, errors.New("Invalid arguments."))
+		writeError(request, response, http.StatusBadRequest, errors.New("Invalid arguments."))
 		return
 	}
 	endString := request.PathParameter("end")
 	end, err := strconv.Atoi(endString)
 	if err != nil {
-		response.WriteError(http.StatusBadRequest, errors.New("Invalid arguments."))
+		writeError(request, response, http.StatusBadRequest, errors.New("Invalid arguments."))
 		return
 	}
 
@@ -739,7 +844,7 @@ func GetRandomPicturesGuildHistory(request *restful.Request, response *restful.R
 
 	result, err := redis.LRange(key, int64(start-1), int64(end-1)).Result()
 	if err != nil {
-		response.WriteError(http.StatusInternalServerError, err)
+		writeError(request, response, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -771,7 +876,7 @@ func GetMessageStatisticsCount(request *restful.Request, response *restful.Respo
 
 	if request.Attribute("UserID").(string) != "global" {
 		if !helpers.IsModByID(guildID, request.Attribute("UserID").(string)) && !helpers.IsAdminByID(guildID, request.Attribute("UserID").(string)) {
-			response.WriteErrorString(401, "401: Not Authorized")
+			writeError(request, response, http.StatusUnauthorized, errNotAuthorized)
 			return
 		}
 	}
@@ -787,7 +892,7 @@ func GetMessageStatisticsCount(request *restful.Request, response *restful.Respo
 		Query(finalQuery).
 		Do(context.Background())
 	if err != nil {
-		response.WriteError(http.StatusInternalServerError, err)
+		writeError(request, response, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -800,7 +905,7 @@ func GetJoinsStatisticsCount(request *restful.Request, response *restful.Respons
 
 	if request.Attribute("UserID").(string) != "global" {
 		if !helpers.IsModByID(guildID, request.Attribute("UserID").(string)) && !helpers.IsAdminByID(guildID, request.Attribute("UserID").(string)) {
-			response.WriteErrorString(401, "401: Not Authorized")
+			writeError(request, response, http.StatusUnauthorized, errNotAuthorized)
 			return
 		}
 	}
@@ -816,7 +921,7 @@ func GetJoinsStatisticsCount(request *restful.Request, response *restful.Respons
 		Query(finalQuery).
 		Do(context.Background())
 	if err != nil {
-		response.WriteError(http.StatusInternalServerError, err)
+		writeError(request, response, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -829,7 +934,7 @@ func GetLeavesStatisticsCount(request *restful.Request, response *restful.Respon
 
 	if request.Attribute("UserID").(string) != "global" {
 		if !helpers.IsModByID(guildID, request.Attribute("UserID").(string)) && !helpers.IsAdminByID(guildID, request.Attribute("UserID").(string)) {
-			response.WriteErrorString(401, "401: Not Authorized")
+			writeError(request, response, http.StatusUnauthorized, errNotAuthorized)
 			return
 		}
 	}
@@ -845,7 +950,7 @@ func GetLeavesStatisticsCount(request *restful.Request, response *restful.Respon
 		Query(finalQuery).
 		Do(context.Background())
 	if err != nil {
-		response.WriteError(http.StatusInternalServerError, err)
+		writeError(request, response, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -858,7 +963,7 @@ func GetMessageByUniqueUsersStatisticsCount(request *restful.Request, response *
 
 	if request.Attribute("UserID").(string) != "global" {
 		if !helpers.IsModByID(guildID, request.Attribute("UserID").(string)) && !helpers.IsAdminByID(guildID, request.Attribute("UserID").(string)) {
-			response.WriteErrorString(401, "401: Not Authorized")
+			writeError(request, response, http.StatusUnauthorized, errNotAuthorized)
 			return
 		}
 	}
@@ -879,7 +984,7 @@ func GetMessageByUniqueUsersStatisticsCount(request *restful.Request, response *
 		Size(0).
 		Do(context.Background())
 	if err != nil {
-		response.WriteError(http.StatusInternalServerError, err)
+		writeError(request, response, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -887,13 +992,13 @@ func GetMessageByUniqueUsersStatisticsCount(request *restful.Request, response *
 	if agg, found := searchResult.Aggregations.Terms("distinct_user_ids"); found {
 		if raw, found := agg.Aggregations["value"]; found {
 			if raw == nil {
-				response.WriteError(http.StatusInternalServerError, errors.New("invalid storage response"))
+				writeError(request, response, http.StatusInternalServerError, errors.New("invalid storage response"))
 				return
 			}
 
 			result, err = strconv.ParseInt(string(*raw), 10, 64)
 			if err != nil {
-				response.WriteError(http.StatusInternalServerError, err)
+				writeError(request, response, http.StatusInternalServerError, err)
 				return
 			}
 		}
@@ -909,14 +1014,14 @@ func GetServerActivityStatisticsHistogram(request *restful.Request, response *re
 
 	if request.Attribute("UserID").(string) != "global" {
 		if !helpers.IsModByID(guildID, request.Attribute("UserID").(string)) && !helpers.IsAdminByID(guildID, request.Attribute("UserID").(string)) {
-			response.WriteErrorString(401, "401: Not Authorized")
+			writeError(request, response, http.StatusUnauthorized, errNotAuthorized)
 			return
 		}
 	}
 
 	countNumber, err := strconv.Atoi(count)
 	if err != nil {
-		response.WriteError(http.StatusNoContent, errors.New("invalid count"))
+		writeError(request, response, http.StatusBadRequest, errors.New("invalid count"))
 		return
 	}
 
@@ -946,7 +1051,7 @@ func GetServerActivityStatisticsHistogram(request *restful.Request, response *re
 		Size(0).
 		Do(context.Background())
 	if err != nil {
-		response.WriteError(http.StatusInternalServerError, err)
+		writeError(request, response, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -991,7 +1096,7 @@ func GetServerActivityStatisticsHistogram(request *restful.Request, response *re
 		Size(0).
 		Do(context.Background())
 	if err != nil {
-		response.WriteError(http.StatusInternalServerError, err)
+		writeError(request, response, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -1018,7 +1123,7 @@ func GetServerActivityStatisticsHistogram(request *restful.Request, response *re
 		Size(0).
 		Do(context.Background())
 	if err != nil {
-		response.WriteError(http.StatusInternalServerError, err)
+		writeError(request, response, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -1046,20 +1151,20 @@ func GetVanityInviteStatistics(request *restful.Request, response *restful.Respo
 
 	if request.Attribute("UserID").(string) != "global" {
 		if !helpers.IsModByID(guildID, request.Attribute("UserID").(string)) && !helpers.IsAdminByID(guildID, request.Attribute("UserID").(string)) {
-			response.WriteErrorString(401, "401: Not Authorized")
+			writeError(request, response, http.StatusUnauthorized, errNotAuthorized)
 			return
 		}
 	}
 
 	countNumber, err := strconv.Atoi(count)
 	if err != nil {
-		response.WriteError(http.StatusNoContent, errors.New("invalid count"))
+		writeError(request, response, http.StatusBadRequest, errors.New("invalid count"))
 		return
 	}
 
 	vanityInvite, _ := helpers.GetVanityUrlByGuildID(guildID)
 	if vanityInvite.VanityName == "" {
-		response.WriteError(http.StatusNoContent, errors.New("vanity invite not found"))
+		writeError(request, response, http.StatusNotFound, errors.New("vanity invite not found"))
 		return
 	}
 
@@ -1088,7 +1193,7 @@ func GetVanityInviteStatistics(request *restful.Request, response *restful.Respo
 		Size(0).
 		Do(context.Background())
 	if err != nil {
-		response.WriteError(http.StatusInternalServerError, err)
+		writeError(request, response, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -1138,7 +1243,7 @@ func GetVanityInviteStatistics(request *restful.Request, response *restful.Respo
 		Size(0).
 		Do(context.Background())
 	if err != nil {
-		response.WriteError(http.StatusInternalServerError, err)
+		writeError(request, response, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -1183,13 +1288,13 @@ func GetChatlogAroundMessageID(request *restful.Request, response *restful.Respo
 	if request.Attribute("UserID").(string) != "global" {
 		if !helpers.IsModByID(guildID, request.Attribute("UserID").(string)) && !helpers.HasPermissionByID(
 			guildID, request.Attribute("UserID").(string), discordgo.PermissionAdministrator) {
-			response.WriteErrorString(401, "401: Not Authorized")
+			writeError(request, response, http.StatusUnauthorized, errNotAuthorized)
 			return
 		}
 	}
 
 	if helpers.GuildSettingsGetCached(guildID).ChatlogDisabled {
-		response.WriteErrorString(401, "401: Not Authorized")
+		writeError(request, response, http.StatusUnauthorized, errNotAuthorized)
 		return
 	}
 
@@ -1203,7 +1308,7 @@ func GetChatlogAroundMessageID(request *restful.Request, response *restful.Respo
 			Sort("CreatedAt", false).
 			Do(context.Background())
 		if err != nil {
-			response.WriteError(http.StatusInternalServerError, err)
+			writeError(request, response, http.StatusInternalServerError, err)
 			return
 		}
 
@@ -1223,7 +1328,7 @@ func GetChatlogAroundMessageID(request *restful.Request, response *restful.Respo
 	}
 
 	if messageID == "" {
-		response.WriteError(http.StatusNoContent, errors.New("Message not found"))
+		writeError(request, response, http.StatusNotFound, errors.New("Message not found"))
 		return
 	}
 
@@ -1237,12 +1342,12 @@ func GetChatlogAroundMessageID(request *restful.Request, response *restful.Respo
 		//Sort("MessageID.keyword", true).
 		Do(context.Background())
 	if err != nil {
-		response.WriteError(http.StatusInternalServerError, err)
+		writeError(request, response, http.StatusInternalServerError, err)
 		return
 	}
 
 	if searchResult.TotalHits() <= 0 {
-		response.WriteError(http.StatusNoContent, errors.New("Message not found"))
+		writeError(request, response, http.StatusNotFound, errors.New("Message not found"))
 		return
 	}
 
@@ -1290,7 +1395,7 @@ func GetChatlogAroundMessageID(request *restful.Request, response *restful.Respo
 		//Sort("MessageID.keyword", true).
 		Do(context.Background())
 	if err != nil {
-		response.WriteError(http.StatusInternalServerError, err)
+		writeError(request, response, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -1334,7 +1439,7 @@ func GetChatlogAroundMessageID(request *restful.Request, response *restful.Respo
 		//Sort("MessageID.keyword", false).
 		Do(context.Background())
 	if err != nil {
-		response.WriteError(http.StatusInternalServerError, err)
+		writeError(request, response, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -1384,13 +1489,24 @@ func GetEventlog(request *restful.Request, response *restful.Response) {
 
 	if request.Attribute("UserID").(string) != "global" {
 		if !helpers.IsModByID(guildID, request.Attribute("UserID").(string)) {
-			response.WriteErrorString(401, "401: Not Authorized")
+			writeError(request, response, http.StatusUnauthorized, errNotAuthorized)
 			return
 		}
 	}
 
 	if helpers.GuildSettingsGetCached(guildID).EventlogDisabled {
-		response.WriteErrorString(401, "401: Not Authorized")
+		writeError(request, response, http.StatusUnauthorized, errNotAuthorized)
+		return
+	}
+
+	pagination, err := getPagination(request, eventlogDefaultPerPage, eventlogMaxPerPage)
+	if err != nil {
+		writeError(request, response, http.StatusBadRequest, err)
+		return
+	}
+	if pagination.Page*pagination.PerPage > elasticMaxResultWindow {
+		writeError(request, response, http.StatusBadRequest,
+			errors.New("invalid page, only the latest "+strconv.Itoa(elasticMaxResultWindow)+" entries can be paged"))
 		return
 	}
 
@@ -1399,22 +1515,26 @@ func GetEventlog(request *restful.Request, response *restful.Response) {
 		Index(models.ElasticIndexEventlogs).
 		Type("doc").
 		Query(termQuery).
-		Size(50).
+		From((pagination.Page-1)*pagination.PerPage).
+		Size(pagination.PerPage).
 		Sort("CreatedAt", false).
 		Do(context.Background())
 	if err != nil {
-		response.WriteError(http.StatusInternalServerError, err)
+		writeError(request, response, http.StatusInternalServerError, err)
 		return
 	}
 
 	if searchResult.TotalHits() <= 0 {
-		response.WriteError(http.StatusNoContent, errors.New("eventlog empty"))
+		writeError(request, response, http.StatusNotFound, errors.New("eventlog empty"))
 		return
 	}
 
+	pagination.Total = int(searchResult.TotalHits())
+
 	eventlog := models.Rest_Eventlog{
-		Users:   make([]models.Rest_User, 0),
-		Entries: make([]models.Rest_Eventlog_Entry, 0),
+		Users:      make([]models.Rest_User, 0),
+		Entries:    make([]models.Rest_Eventlog_Entry, 0),
+		Pagination: pagination,
 	}
 
 	lookupUserIDs := make([]string, 0)
@@ -1432,7 +1552,7 @@ func GetEventlog(request *restful.Request, response *restful.Response) {
 		var elasticEventlog models.ElasticEventlog
 		err := json.Unmarshal(*item.Source, &elasticEventlog)
 		if err != nil {
-			response.WriteError(http.StatusInternalServerError, err)
+			writeError(request, response, http.StatusInternalServerError, err)
 			return
 		}
 
@@ -1729,13 +1849,13 @@ func GetVanityInviteByName(request *restful.Request, response *restful.Response)
 
 	vanityInvite, _ := helpers.GetVanityUrlByVanityName(vanityName)
 	if vanityInvite.GuildID == "" {
-		response.WriteError(http.StatusNoContent, errors.New("vanity invite not found"))
+		writeError(request, response, http.StatusNotFound, errors.New("vanity invite not found"))
 		return
 	}
 
 	code, _ := helpers.GetDiscordInviteByVanityInvite(vanityInvite)
 	if code == "" {
-		response.WriteError(http.StatusNoContent, errors.New("unable to create invite"))
+		writeError(request, response, http.StatusNotFound, errors.New("unable to create invite"))
 		return
 	}
 
@@ -1755,10 +1875,10 @@ func GetFileByFilehash(request *restful.Request, response *restful.Response) {
 	filename, filetype, data, err := helpers.RetrieveFileByHash(fileHash)
 	if err != nil {
 		if strings.Contains(err.Error(), "file not found") {
-			response.WriteError(http.StatusNotFound, errors.New("file not found"))
+			writeError(request, response, http.StatusNotFound, errors.New("file not found"))
 			return
 		}
-		response.WriteError(http.StatusInternalServerError, err)
+		writeError(request, response, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -1769,25 +1889,130 @@ func GetFileByFilehash(request *restful.Request, response *restful.Response) {
 	})
 }
 
+// GetEventlogExport exports the eventlog of a guild as CSV or NDJSON file and returns the link to it
+// query parameters: from and to as RFC3339 times, format (csv or ndjson), types (comma separated action types) and user
+func GetEventlogExport(request *restful.Request, response *restful.Response) {
+	guildID := request.PathParameter("guild-id")
+	userID := request.Attribute("UserID").(string)
+
+	if userID != "global" {
+		if !helpers.IsModByID(guildID, userID) {
+			writeError(request, response, http.StatusUnauthorized, errNotAuthorized)
+			return
+		}
+	}
+
+	if helpers.GuildSettingsGetCached(guildID).EventlogDisabled {
+		writeError(request, response, http.StatusUnauthorized, errNotAuthorized)
+		return
+	}
+
+	filter := helpers.EventlogExportFilter{
+		To:     time.Now(),
+		UserID: request.QueryParameter("user"),
+	}
+	var err error
+	if request.QueryParameter("from") != "" {
+		filter.From, err = time.Parse(time.RFC3339, request.QueryParameter("from"))
+		if err != nil {
+			writeError(request, response, http.StatusBadRequest, err)
+			return
+		}
+	}
+	if request.QueryParameter("to") != "" {
+		filter.To, err = time.Parse(time.RFC3339, request.QueryParameter("to"))
+		if err != nil {
+			writeError(request, response, http.StatusBadRequest, err)
+			return
+		}
+	}
+	if request.QueryParameter("types") != "" {
+		filter.ActionTypes = strings.Split(request.QueryParameter("types"), ",")
+	}
+
+	format := helpers.EventlogExportFormat(strings.ToLower(request.QueryParameter("format")))
+	if format == "" {
+		format = helpers.EventlogExportFormatCSV
+	}
+	if format != helpers.EventlogExportFormatCSV && format != helpers.EventlogExportFormatNDJSON {
+		writeError(request, response, http.StatusBadRequest, errors.New("unknown export format"))
+		return
+	}
+
+	if userID == "global" {
+		userID = ""
+	}
+	link, count, err := helpers.ExportEventlog(guildID, userID, filter, format)
+	if err != nil {
+		if err == helpers.ErrEventlogExportEmpty {
+			writeError(request, response, http.StatusNotFound, err)
+			return
+		}
+		writeError(request, response, http.StatusInternalServerError, err)
+		return
+	}
+
+	response.WriteEntity(models.Rest_Eventlog_Export{
+		Link:  link,
+		Count: count,
+	})
+}
+
+// DownloadEventlogExport sends an eventlog export to anyone with a valid link to it, see helpers.GetEventlogExportLink
+func DownloadEventlogExport(request *restful.Request, response *restful.Response) {
+	objectName := request.PathParameter("object-name")
+
+	err := helpers.CheckEventlogExportLink(objectName,
+		request.QueryParameter("expires"), request.QueryParameter("signature"), time.Now())
+	if err != nil {
+		if err == helpers.ErrEventlogExportLinkInvalid || err == helpers.ErrEventlogExportLinkExpired {
+			writeError(request, response, http.StatusForbidden, err)
+			return
+		}
+		writeError(request, response, http.StatusInternalServerError, err)
+		return
+	}
+
+	info, err := helpers.RetrieveFileInformation(objectName)
+	if err != nil || info.Source != helpers.EventlogExportSource {
+		if err == nil || helpers.IsMdbNotFound(err) {
+			writeError(request, response, http.StatusNotFound, errors.New("export not found"))
+			return
+		}
+		writeError(request, response, http.StatusInternalServerError, err)
+		return
+	}
+
+	data, err := helpers.RetrieveFile(objectName)
+	if err != nil {
+		writeError(request, response, http.StatusInternalServerError, err)
+		return
+	}
+
+	response.AddHeader("Content-Type", info.MimeType)
+	response.AddHeader("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": info.Filename}))
+	response.Write(data)
+}
+
 func SetGuildSettings(request *restful.Request, response *restful.Response) {
 	guildID := request.PathParameter("guild-id")
 
 	if request.Attribute("UserID").(string) != "global" && !helpers.GetIsInGuild(guildID, request.Attribute("UserID").(string)) {
-		response.WriteErrorString(401, "401: Not Authorized")
+		writeError(request, response, http.StatusUnauthorized, errNotAuthorized)
 		return
 	}
 
 	newSettings := new(models.Rest_Receive_SetSettings)
 	err := request.ReadEntity(&newSettings)
 	if err != nil {
-		response.WriteError(http.StatusInternalServerError, err)
+		writeError(request, response, http.StatusInternalServerError, err)
 		return
 	}
 
 	for _, newStringSetting := range newSettings.Strings {
 		err = setGuildStringSetting(guildID, request.Attribute("UserID").(string), newStringSetting.Key, newStringSetting.Values)
 		if err != nil {
-			response.WriteError(http.StatusUnauthorized, err)
+			writeError(request, response, http.StatusUnauthorized, err)
 			return
 		}
 	}
@@ -1799,7 +2024,7 @@ func GetAllBackgrounds(request *restful.Request, response *restful.Response) {
 	var entryBucket []models.ProfileBackgroundEntry
 	err := helpers.MDbIter(helpers.MdbCollection(models.ProfileBackgroundsTable).Find(nil)).All(&entryBucket)
 	if err != nil {
-		response.WriteError(http.StatusInternalServerError, err)
+		writeError(request, response, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -0,0 +1,167 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Seklfreak/Robyul2/cache"
+	"github.com/Seklfreak/Robyul2/helpers"
+	"github.com/Seklfreak/Robyul2/models"
+	"github.com/bwmarrin/discordgo"
+	restful "github.com/emicklei/go-restful"
+	"github.com/vmihailenco/msgpack"
+	"golang.org/x/oauth2"
+)
+
+const (
+	apiSessionTTL = 7 * 24 * time.Hour
+)
+
+var (
+	discordOAuth2Endpoint = oauth2.Endpoint{
+		AuthURL:  "https://discordapp.com/api/oauth2/authorize",
+		TokenURL: "https://discordapp.com/api/oauth2/token",
+	}
+)
+
+func newSessionsService(prefix string) *restful.WebService {
+	service := new(restful.WebService)
+	service.
+		Path(prefix + "/session").
+		Consumes(restful.MIME_JSON).
+		Produces(restful.MIME_JSON)
+
+	service.Route(service.POST("").To(CreateSession).
+		Doc("creates a session with a Discord OAuth2 authorization code").Reads(models.Rest_Receive_Session{}).
+		Returns(http.StatusCreated, "session created", models.Rest_Session{}))
+	service.Route(service.DELETE("").To(DeleteSession).
+		Doc("ends the session in the authorization header").Returns(http.StatusNoContent, "session ended", nil))
+
+	return service
+}
+
+// getOAuth2Config returns the Discord OAuth2 config, ok is false if no client secret is configured
+func getOAuth2Config() (config *oauth2.Config, ok bool) {
+	clientSecret, _ := helpers.GetConfig().Path("discord.client_secret").Data().(string)
+	redirectURL, _ := helpers.GetConfig().Path("website.oauth2_redirect_url").Data().(string)
+	if clientSecret == "" || redirectURL == "" {
+		return nil, false
+	}
+
+	return &oauth2.Config{
+		ClientID:     helpers.GetConfig().Path("discord.id").Data().(string),
+		ClientSecret: clientSecret,
+		Endpoint:     discordOAuth2Endpoint,
+		RedirectURL:  redirectURL,
+		Scopes:       []string{"identify"},
+	}, true
+}
+
+// getApiSession returns the session for the session token, it fails if the session expired
+func getApiSession(sessionToken string) (session models.ApiSession, err error) {
+	sessionData, err := cache.GetRedisClient().Get(fmt.Sprintf(models.Redis_Key_Api_Session, hashApiToken(sessionToken))).Bytes()
+	if err != nil {
+		return session, err
+	}
+
+	err = msgpack.Unmarshal(sessionData, &session)
+	if err != nil {
+		return session, err
+	}
+
+	if session.UserID == "" || !time.Now().Before(session.ExpiresAt) {
+		return session, errors.New("session expired")
+	}
+	return session, nil
+}
+
+// createApiSession stores a new session for the user, only the hash of the returned session token is stored
+func createApiSession(userID string) (sessionToken string, session models.ApiSession, err error) {
+	sessionToken, err = generateSecret()
+	if err != nil {
+		return "", session, err
+	}
+
+	session = models.ApiSession{
+		UserID:    userID,
+		CreatedAt: time.Now(),
+		ExpiresAt: time.Now().Add(apiSessionTTL),
+	}
+	sessionData, err := msgpack.Marshal(session)
+	if err != nil {
+		return "", session, err
+	}
+
+	err = cache.GetRedisClient().Set(
+		fmt.Sprintf(models.Redis_Key_Api_Session, hashApiToken(sessionToken)), sessionData, apiSessionTTL,
+	).Err()
+	return sessionToken, session, err
+}
+
+// CreateSession exchanges a Discord OAuth2 authorization code for a session of the Discord user
+func CreateSession(request *restful.Request, response *restful.Response) {
+	received := new(models.Rest_Receive_Session)
+	err := request.ReadEntity(received)
+	if err != nil || received.Code == "" {
+		writeError(request, response, http.StatusBadRequest, errors.New("code is required"))
+		return
+	}
+
+	config, ok := getOAuth2Config()
+	if !ok {
+		writeError(request, response, http.StatusNotImplemented, errors.New("oauth2 is not configured"))
+		return
+	}
+
+	oauth2Token, err := config.Exchange(context.Background(), received.Code)
+	if err != nil {
+		writeError(request, response, http.StatusUnauthorized, errNotAuthorized)
+		return
+	}
+
+	// the user is looked up with the access token, so the user ID can not be forged by the client
+	oauth2Session, err := discordgo.New("Bearer " + oauth2Token.AccessToken)
+	if err != nil {
+		writeError(request, response, http.StatusInternalServerError, err)
+		return
+	}
+	user, err := oauth2Session.User("@me")
+	if err != nil || user == nil || user.ID == "" {
+		writeError(request, response, http.StatusUnauthorized, errNotAuthorized)
+		return
+	}
+
+	sessionToken, session, err := createApiSession(user.ID)
+	if err != nil {
+		writeError(request, response, http.StatusInternalServerError, err)
+		return
+	}
+
+	response.WriteHeaderAndEntity(http.StatusCreated, models.Rest_Session{
+		Token:     sessionToken,
+		UserID:    session.UserID,
+		ExpiresAt: session.ExpiresAt,
+	})
+}
+
+// DeleteSession ends the session in the authorization header
+func DeleteSession(request *restful.Request, response *restful.Response) {
+	authorizationHeader := strings.TrimSpace(request.HeaderParameter("Authorization"))
+	if !strings.HasPrefix(authorizationHeader, "Session ") {
+		writeError(request, response, http.StatusUnauthorized, errNotAuthorized)
+		return
+	}
+
+	sessionToken := strings.TrimSpace(strings.TrimPrefix(authorizationHeader, "Session "))
+	err := cache.GetRedisClient().Del(fmt.Sprintf(models.Redis_Key_Api_Session, hashApiToken(sessionToken))).Err()
+	if err != nil {
+		writeError(request, response, http.StatusInternalServerError, err)
+		return
+	}
+
+	response.WriteHeader(http.StatusNoContent)
+}
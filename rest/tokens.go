@@ -0,0 +1,284 @@
+package rest
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Seklfreak/Robyul2/cache"
+	"github.com/Seklfreak/Robyul2/helpers"
+	"github.com/Seklfreak/Robyul2/models"
+	restful "github.com/emicklei/go-restful"
+	"github.com/globalsign/mgo/bson"
+)
+
+const (
+	apiTokenPrefix = "rbl_"
+)
+
+var (
+	errApiTokenExpired = errors.New("api token expired")
+	errApiTokenScope   = errors.New("api token is missing the required scope")
+	errApiTokenGuild   = errors.New("api token is not allowed to access this guild")
+)
+
+func newApiTokensService(prefix string) *restful.WebService {
+	service := new(restful.WebService)
+	service.
+		Path(prefix + "/tokens").
+		Consumes(restful.MIME_JSON).
+		Produces(restful.MIME_JSON)
+
+	service.Route(service.GET("").Filter(webkeyAuthenticate).To(GetApiTokens).
+		Doc("lists all API tokens").Writes([]models.Rest_ApiToken{}))
+	service.Route(service.POST("").Filter(webkeyAuthenticate).To(CreateApiToken).
+		Doc("creates an API token, the token is only returned once").Reads(models.Rest_Receive_ApiToken{}).
+		Returns(http.StatusCreated, "token created", models.Rest_ApiToken_Created{}))
+	service.Route(service.DELETE("/{token-id}").Filter(webkeyAuthenticate).To(RevokeApiToken).
+		Doc("revokes an API token").Returns(http.StatusNoContent, "token revoked", nil))
+
+	return service
+}
+
+// withApiToken authenticates requests with an API token that has the scope, other requests are passed to the fallback filter
+// tokens are limited to the guild in the guild-id path parameter of the route, if they are restricted to guilds
+func withApiToken(scope models.ApiTokenScope, fallback restful.FilterFunction) restful.FilterFunction {
+	return func(request *restful.Request, response *restful.Response, chain *restful.FilterChain) {
+		authorizationHeader := strings.TrimSpace(request.HeaderParameter("Authorization"))
+		if !strings.HasPrefix(authorizationHeader, "Token ") {
+			fallback(request, response, chain)
+			return
+		}
+
+		var token models.ApiTokenEntry
+		err := helpers.MdbOneWithoutLogging(
+			helpers.MdbCollection(models.ApiTokensTable).Find(bson.M{
+				"tokenhash": hashApiToken(strings.TrimSpace(strings.TrimPrefix(authorizationHeader, "Token "))),
+			}),
+			&token,
+		)
+		if err != nil {
+			if !helpers.IsMdbNotFound(err) {
+				helpers.RelaxLog(err)
+			}
+			writeError(request, response, http.StatusUnauthorized, errNotAuthorized)
+			return
+		}
+
+		err = checkApiToken(token, scope, request.PathParameter("guild-id"), time.Now())
+		if err != nil {
+			auditApiToken(token.ID, models.ApiTokenAuditActionDeny, err.Error(), "", request)
+			if err == errApiTokenExpired {
+				writeError(request, response, http.StatusUnauthorized, errNotAuthorized)
+				return
+			}
+			writeError(request, response, http.StatusForbidden, errForbidden)
+			return
+		}
+
+		auditApiToken(token.ID, models.ApiTokenAuditActionUse, string(scope), "", request)
+		go func() {
+			defer helpers.Recover()
+
+			err := helpers.MDbUpdateWithoutLogging(models.ApiTokensTable, token.ID, bson.M{"$set": bson.M{"lastusedat": time.Now()}})
+			helpers.RelaxLog(err)
+		}()
+
+		// the token has been checked for the guild already, so the handlers can treat it like the webkey
+		request.SetAttribute("UserID", "global")
+		request.SetAttribute("ApiTokenID", token.ID.Hex())
+		chain.ProcessFilter(request, response)
+	}
+}
+
+// checkApiToken checks if a token can be used for the scope and guild at the given time
+// tokens restricted to guilds can not be used for routes without a guild
+func checkApiToken(token models.ApiTokenEntry, scope models.ApiTokenScope, guildID string, now time.Time) error {
+	if !token.ExpiresAt.IsZero() && !now.Before(token.ExpiresAt) {
+		return errApiTokenExpired
+	}
+
+	var hasScope bool
+	for _, tokenScope := range token.Scopes {
+		if tokenScope == scope {
+			hasScope = true
+			break
+		}
+	}
+	if !hasScope {
+		return errApiTokenScope
+	}
+
+	if len(token.GuildIDs) > 0 {
+		for _, tokenGuildID := range token.GuildIDs {
+			if guildID != "" && tokenGuildID == guildID {
+				return nil
+			}
+		}
+		return errApiTokenGuild
+	}
+
+	return nil
+}
+
+func hashApiToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// generateSecret returns 32 random bytes encoded as hex
+func generateSecret() (secret string, err error) {
+	secretBytes := make([]byte, 32)
+	_, err = rand.Read(secretBytes)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(secretBytes), nil
+}
+
+// auditApiToken records the action for a token asynchronously
+func auditApiToken(tokenID bson.ObjectId, action models.ApiTokenAuditAction, reason, userID string, request *restful.Request) {
+	entry := models.ApiTokenAuditEntry{
+		TokenID:    tokenID,
+		Action:     action,
+		Reason:     reason,
+		Method:     request.Request.Method,
+		Path:       request.Request.URL.Path,
+		RemoteAddr: request.Request.RemoteAddr,
+		UserID:     userID,
+		CreatedAt:  time.Now(),
+	}
+
+	go func() {
+		defer helpers.Recover()
+
+		_, err := helpers.MDbInsertWithoutLogging(models.ApiTokenAuditTable, entry)
+		helpers.RelaxLog(err)
+	}()
+}
+
+func getRestApiToken(token models.ApiTokenEntry) models.Rest_ApiToken {
+	return models.Rest_ApiToken{
+		ID:              token.ID.Hex(),
+		Name:            token.Name,
+		Scopes:          token.Scopes,
+		GuildIDs:        token.GuildIDs,
+		CreatedByUserID: token.CreatedByUserID,
+		CreatedAt:       token.CreatedAt,
+		ExpiresAt:       token.ExpiresAt,
+		LastUsedAt:      token.LastUsedAt,
+	}
+}
+
+func GetApiTokens(request *restful.Request, response *restful.Response) {
+	var tokens []models.ApiTokenEntry
+	err := helpers.MDbIter(helpers.MdbCollection(models.ApiTokensTable).Find(nil).Sort("-createdat")).All(&tokens)
+	if err != nil {
+		writeError(request, response, http.StatusInternalServerError, err)
+		return
+	}
+
+	returnTokens := make([]models.Rest_ApiToken, 0, len(tokens))
+	for _, token := range tokens {
+		returnTokens = append(returnTokens, getRestApiToken(token))
+	}
+
+	response.WriteEntity(returnTokens)
+}
+
+func CreateApiToken(request *restful.Request, response *restful.Response) {
+	received := new(models.Rest_Receive_ApiToken)
+	err := request.ReadEntity(received)
+	if err != nil {
+		writeError(request, response, http.StatusBadRequest, err)
+		return
+	}
+
+	if strings.TrimSpace(received.Name) == "" {
+		writeError(request, response, http.StatusBadRequest, errors.New("name is required"))
+		return
+	}
+	if len(received.Scopes) <= 0 {
+		writeError(request, response, http.StatusBadRequest, errors.New("at least one scope is required"))
+		return
+	}
+NextScope:
+	for _, scope := range received.Scopes {
+		for _, validScope := range models.ApiTokenScopes {
+			if scope == validScope {
+				continue NextScope
+			}
+		}
+		writeError(request, response, http.StatusBadRequest, errors.New("unknown scope "+string(scope)))
+		return
+	}
+	for _, guildID := range received.GuildIDs {
+		_, err = helpers.GetGuildWithoutApi(guildID)
+		if err != nil {
+			writeError(request, response, http.StatusBadRequest, errors.New("unknown guild "+guildID))
+			return
+		}
+	}
+	if !received.ExpiresAt.IsZero() && received.ExpiresAt.Before(time.Now()) {
+		writeError(request, response, http.StatusBadRequest, errors.New("expiry is in the past"))
+		return
+	}
+
+	secret, err := generateSecret()
+	if err != nil {
+		writeError(request, response, http.StatusInternalServerError, err)
+		return
+	}
+	tokenText := apiTokenPrefix + secret
+
+	token := models.ApiTokenEntry{
+		Name:            strings.TrimSpace(received.Name),
+		TokenHash:       hashApiToken(tokenText),
+		Scopes:          received.Scopes,
+		GuildIDs:        received.GuildIDs,
+		CreatedByUserID: request.Attribute("UserID").(string),
+		CreatedAt:       time.Now(),
+		ExpiresAt:       received.ExpiresAt,
+	}
+	token.ID, err = helpers.MDbInsert(models.ApiTokensTable, token)
+	if err != nil {
+		writeError(request, response, http.StatusInternalServerError, err)
+		return
+	}
+
+	auditApiToken(token.ID, models.ApiTokenAuditActionCreate, "", token.CreatedByUserID, request)
+	cache.GetLogger().WithField("module", "rest").Infof("created API token %s (#%s) with scopes %v for guilds %v",
+		token.Name, token.ID.Hex(), token.Scopes, token.GuildIDs)
+
+	response.WriteHeaderAndEntity(http.StatusCreated, models.Rest_ApiToken_Created{
+		Token:    tokenText,
+		ApiToken: getRestApiToken(token),
+	})
+}
+
+func RevokeApiToken(request *restful.Request, response *restful.Response) {
+	tokenID := request.PathParameter("token-id")
+	if !bson.IsObjectIdHex(tokenID) {
+		writeError(request, response, http.StatusBadRequest, errors.New("invalid token id"))
+		return
+	}
+
+	err := helpers.MDbDelete(models.ApiTokensTable, bson.ObjectIdHex(tokenID))
+	if err != nil {
+		if helpers.IsMdbNotFound(err) {
+			writeError(request, response, http.StatusNotFound, errors.New("token not found"))
+			return
+		}
+		writeError(request, response, http.StatusInternalServerError, err)
+		return
+	}
+
+	auditApiToken(bson.ObjectIdHex(tokenID), models.ApiTokenAuditActionRevoke, "", request.Attribute("UserID").(string), request)
+	cache.GetLogger().WithField("module", "rest").Infof("revoked API token #%s", tokenID)
+
+	response.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,42 @@
+package rest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Seklfreak/Robyul2/models"
+)
+
+func TestCheckApiToken(t *testing.T) {
+	now := time.Now()
+	token := models.ApiTokenEntry{
+		Scopes:    []models.ApiTokenScope{models.ApiTokenScopeReadRankings},
+		GuildIDs:  []string{"1"},
+		ExpiresAt: now.Add(time.Hour),
+	}
+	unrestricted := models.ApiTokenEntry{
+		Scopes: []models.ApiTokenScope{models.ApiTokenScopeReadEventlog},
+	}
+
+	tests := []struct {
+		token    models.ApiTokenEntry
+		scope    models.ApiTokenScope
+		guildID  string
+		at       time.Time
+		expected error
+	}{
+		{token, models.ApiTokenScopeReadRankings, "1", now, nil},
+		{token, models.ApiTokenScopeReadRankings, "2", now, errApiTokenGuild},
+		{token, models.ApiTokenScopeReadRankings, "", now, errApiTokenGuild},
+		{token, models.ApiTokenScopeReadEventlog, "1", now, errApiTokenScope},
+		{token, models.ApiTokenScopeReadRankings, "1", now.Add(time.Hour), errApiTokenExpired},
+		{unrestricted, models.ApiTokenScopeReadEventlog, "", now.Add(24 * 365 * time.Hour), nil},
+		{unrestricted, models.ApiTokenScopeWriteSettings, "1", now, errApiTokenScope},
+	}
+
+	for _, test := range tests {
+		if err := checkApiToken(test.token, test.scope, test.guildID, test.at); err != test.expected {
+			t.Errorf("rest.checkApiToken(%s, %q) returned %v, expected %v", test.scope, test.guildID, err, test.expected)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package rest
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Seklfreak/Robyul2/cache"
+	"github.com/Seklfreak/Robyul2/modules/plugins/youtube"
+	restful "github.com/emicklei/go-restful"
+)
+
+const (
+	// maxYouTubeWebSubBodySize is the largest notification read, notifications contain a single entry
+	maxYouTubeWebSubBodySize = 1024 * 1024
+)
+
+var (
+	errYouTubeWebSubBodyTooLarge = errors.New("notification is too large")
+)
+
+// newYouTubeWebSubService returns the callback of the WebSub hub for YouTube uploads
+func newYouTubeWebSubService(prefix string) *restful.WebService {
+	service := new(restful.WebService)
+	service.
+		Path(prefix + "/youtube/websub").
+		Consumes("*/*").
+		Produces("text/plain")
+
+	service.Route(service.GET("").To(VerifyYouTubeWebSub).
+		Doc("confirms a WebSub subscription of a YouTube channel").
+		Param(service.QueryParameter("hub.mode", "subscribe or unsubscribe")).
+		Param(service.QueryParameter("hub.topic", "the feed URL of the YouTube channel")).
+		Param(service.QueryParameter("hub.challenge", "returned to confirm the subscription")).
+		Param(service.QueryParameter("hub.lease_seconds", "the duration of the subscription")))
+	service.Route(service.POST("").Filter(youTubeWebSubAuthenticate).To(ReceiveYouTubeWebSub).
+		Doc("receives a WebSub notification of new YouTube videos"))
+
+	return service
+}
+
+// youTubeWebSubAuthenticate ignores notifications without a valid signature
+// the hub still gets a success response, as required by WebSub, otherwise it would retry them
+func youTubeWebSubAuthenticate(request *restful.Request, response *restful.Response, chain *restful.FilterChain) {
+	body, err := readYouTubeWebSubBody(request)
+	if err == errYouTubeWebSubBodyTooLarge {
+		writeError(request, response, http.StatusRequestEntityTooLarge, err)
+		return
+	}
+	if err != nil {
+		writeError(request, response, http.StatusBadRequest, errBadRequest)
+		return
+	}
+	request.Request.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	if !youtube.VerifyWebSubSignature(strings.TrimSpace(request.HeaderParameter("X-Hub-Signature")), body) {
+		cache.GetLogger().WithField("module", "rest").Warn("received YouTube WebSub notification with an invalid signature")
+		response.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	chain.ProcessFilter(request, response)
+	return
+}
+
+func VerifyYouTubeWebSub(request *restful.Request, response *restful.Response) {
+	leaseSeconds, _ := strconv.Atoi(request.QueryParameter("hub.lease_seconds"))
+
+	if !youtube.WebSubEnabled() ||
+		!youtube.VerifyWebSubIntent(request.QueryParameter("hub.mode"), request.QueryParameter("hub.topic"), leaseSeconds) {
+		response.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	response.WriteHeader(http.StatusOK)
+	response.Write([]byte(request.QueryParameter("hub.challenge")))
+}
+
+func ReceiveYouTubeWebSub(request *restful.Request, response *restful.Response) {
+	body, err := readYouTubeWebSubBody(request)
+	if err == errYouTubeWebSubBodyTooLarge {
+		writeError(request, response, http.StatusRequestEntityTooLarge, err)
+		return
+	}
+	if err != nil {
+		writeError(request, response, http.StatusBadRequest, errBadRequest)
+		return
+	}
+
+	err = youtube.ReceiveWebSubNotification(body)
+	if err != nil {
+		cache.GetLogger().WithField("module", "rest").WithError(err).Warn("receiving YouTube WebSub notification failed")
+		writeError(request, response, http.StatusBadRequest, errBadRequest)
+		return
+	}
+
+	response.WriteHeader(http.StatusAccepted)
+}
+
+// readYouTubeWebSubBody reads the body of a notification up to maxYouTubeWebSubBodySize
+func readYouTubeWebSubBody(request *restful.Request) (body []byte, err error) {
+	body, err = ioutil.ReadAll(io.LimitReader(request.Request.Body, maxYouTubeWebSubBodySize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > maxYouTubeWebSubBodySize {
+		return nil, errYouTubeWebSubBodyTooLarge
+	}
+	return body, nil
+}
@@ -572,6 +572,7 @@ func (s *Robyulstate) RoleDelete(guildID, roleID string) error {
 	defer s.Unlock()
 
 	if _, ok := s.guildMap[guildID]; !ok || s.guildMap[guildID] == nil {
+		go helpers.OnEventlogRoleDelete(guildID, roleID, nil)
 		return errors.New(discordgo.ErrStateNotFound.Error() + ": RoleDelete (" + guildID + ")")
 	}
 
@@ -579,13 +580,17 @@ func (s *Robyulstate) RoleDelete(guildID, roleID string) error {
 		s.guildMap[guildID].Roles = make([]*discordgo.Role, 0)
 	}
 
+	var deletedRole *discordgo.Role
 	for j, oldRole := range s.guildMap[guildID].Roles {
 		if oldRole.ID == roleID {
 			// remove role
 			//fmt.Println("removed role")
+			deletedRole = oldRole
 			s.guildMap[guildID].Roles = append(s.guildMap[guildID].Roles[:j], s.guildMap[guildID].Roles[j+1:]...)
+			break
 		}
 	}
+	go helpers.OnEventlogRoleDelete(guildID, roleID, deletedRole)
 
 	return nil
 }